@@ -0,0 +1,185 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// AggregateInfo summarizes the events of one aggregate.
+type AggregateInfo struct {
+	AggregateUuid string
+	Domain        string
+	EventCount    int64
+	// highest event version
+	Version int64
+	// created_at of the latest event, unix nano
+	UpdatedAt int64
+}
+
+// AggregateListOption configures ListAggregates.
+type AggregateListOption func(*aggregateListOptions)
+
+type aggregateListOptions struct {
+	TenantUuid string
+	Offset     int64
+	Limit      int64
+	OrderBy    string
+	Ascending  bool
+}
+
+// AggregateListWithTenantUuid only lists aggregates with events of the given tenant.
+func AggregateListWithTenantUuid(tenantUuid string) AggregateListOption {
+	return func(o *aggregateListOptions) { o.TenantUuid = tenantUuid }
+}
+
+// AggregateListWithPage sets offset and limit (-1 disables the limit), defaults to 0 and 100.
+func AggregateListWithPage(offset, limit int64) AggregateListOption {
+	return func(o *aggregateListOptions) {
+		o.Offset = offset
+		o.Limit = limit
+	}
+}
+
+// AggregateListWithOrderBy orders by aggregate_uuid, event_count, version or
+// updated_at (default, descending).
+func AggregateListWithOrderBy(orderBy string, ascending bool) AggregateListOption {
+	return func(o *aggregateListOptions) {
+		o.OrderBy = orderBy
+		o.Ascending = ascending
+	}
+}
+
+// ListAggregates lists the aggregates of a domain (all domains if empty) with
+// their event count, current version and last update, and returns the total
+// number of matching aggregates.
+func (es *eventStoreSQLite) ListAggregates(ctx context.Context, domain string, opts ...AggregateListOption) ([]AggregateInfo, int64, error) {
+	listOpts := aggregateListOptions{
+		Offset:  0,
+		Limit:   100,
+		OrderBy: "updated_at",
+	}
+	for _, opt := range opts {
+		opt(&listOpts)
+	}
+	switch listOpts.OrderBy {
+	case "aggregate_uuid", "event_count", "version", "updated_at":
+	default:
+		return nil, 0, fmt.Errorf("'%s' failed to list aggregates - invalid order by '%s'", es.String(), listOpts.OrderBy)
+	}
+
+	var whereList []string
+	var args []any
+	if len(domain) > 0 {
+		whereList = append(whereList, "domain=?")
+		args = append(args, domain)
+	}
+	if len(listOpts.TenantUuid) > 0 {
+		whereList = append(whereList, "tenant_uuid=?")
+		args = append(args, listOpts.TenantUuid)
+	}
+	var whereSQL string
+	if len(whereList) > 0 {
+		whereSQL = " WHERE " + strings.Join(whereList, " AND ")
+	}
+
+	var total int64
+	totalQuery := fmt.Sprintf("SELECT COUNT(*) FROM (SELECT 1 FROM events%s GROUP BY domain, aggregate_uuid);", whereSQL)
+	if err := es.db.QueryRowContext(ctx, totalQuery, args...).Scan(&total); err != nil {
+		return nil, 0, classifyError(err)
+	}
+
+	direction := "DESC"
+	if listOpts.Ascending {
+		direction = "ASC"
+	}
+	query := fmt.Sprintf(`SELECT aggregate_uuid, domain, COUNT(*) AS event_count, MAX(version) AS version, MAX(created_at) AS updated_at
+		FROM events%s GROUP BY domain, aggregate_uuid ORDER BY %s %s, aggregate_uuid ASC LIMIT %d OFFSET %d;`,
+		whereSQL, listOpts.OrderBy, direction, listOpts.Limit, listOpts.Offset)
+	rows, err := es.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, 0, classifyError(err)
+	}
+	defer rows.Close()
+
+	var aggregates []AggregateInfo
+	for rows.Next() {
+		var info AggregateInfo
+		if err := rows.Scan(&info.AggregateUuid, &info.Domain, &info.EventCount, &info.Version, &info.UpdatedAt); err != nil {
+			return nil, 0, classifyError(err)
+		}
+		aggregates = append(aggregates, info)
+	}
+	return aggregates, total, classifyError(rows.Err())
+}
+
+// DeleteAggregateReport is the result of DeleteAggregate.
+type DeleteAggregateReport struct {
+	AggregateUuid string
+	NumEvents     int64
+	NumSnapshots  int64
+}
+
+// DeleteAggregate removes all events of an aggregate and, if the stores have
+// a snapshot store, its snapshots in one transaction ("hard delete" of an
+// entity). Events already moved to an archive are not affected. The deletion
+// is recorded in the admin audit log. A store bound to a tenant fails with
+// ErrTenantMismatch if the aggregate has events of another tenant.
+func (s *Stores) DeleteAggregate(ctx context.Context, aggregateUuid string) (*DeleteAggregateReport, error) {
+	es, ok := s.EventStore.(*eventStoreSQLite)
+	if !ok {
+		return nil, fmt.Errorf("'%s' failed to delete aggregate - event store is required", s.String())
+	}
+	if es.opts().ReadOnly {
+		return nil, fmt.Errorf("'%s' failed to delete aggregate - instance is readonly", s.String())
+	}
+	if len(aggregateUuid) == 0 {
+		return nil, fmt.Errorf("'%s' failed to delete aggregate - aggregate uuid is required", s.String())
+	}
+	ctx, cancel := es.cfg().Timeouts.write(ctx)
+	defer cancel()
+	if err := es.authorize(ctx, AccessRequest{Operation: OperationDelete, AggregateUuid: aggregateUuid}); err != nil {
+		return nil, err
+	}
+	done, err := s.beginWrite(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+	defer es.invalidateCache()
+
+	report := &DeleteAggregateReport{AggregateUuid: aggregateUuid}
+	err = runTx(ctx, s.db, func(tx *sql.Tx) error {
+		if tenant := es.cfg().Tenant; len(tenant) > 0 {
+			var foreign string
+			err := tx.QueryRowContext(ctx, "SELECT tenant_uuid FROM events WHERE aggregate_uuid=? AND tenant_uuid<>? LIMIT 1;", aggregateUuid, tenant).Scan(&foreign)
+			if err == nil {
+				return checkTenant(tenant, foreign)
+			} else if err != sql.ErrNoRows {
+				return err
+			}
+		}
+		result, err := tx.ExecContext(ctx, "DELETE FROM event_records WHERE aggregate_uuid=?;", aggregateUuid)
+		if err != nil {
+			return err
+		}
+		if report.NumEvents, err = result.RowsAffected(); err != nil {
+			return err
+		}
+		if _, ok := s.SnapshotStore.(*snapshotStoreSQLite); ok {
+			result, err := tx.ExecContext(ctx, "DELETE FROM snapshots WHERE aggregate_uuid=?;", aggregateUuid)
+			if err != nil {
+				return err
+			}
+			if report.NumSnapshots, err = result.RowsAffected(); err != nil {
+				return err
+			}
+		}
+		return insertAuditEntry(ctx, tx, newAuditEntry(ctx, "events", AdminOperationDeleteAggregate, aggregateUuid, report.NumEvents))
+	})
+	if err != nil {
+		return nil, fmt.Errorf("'%s' failed to delete aggregate '%s' - %w", s.String(), aggregateUuid, classifyError(err))
+	}
+	return report, nil
+}
@@ -0,0 +1,118 @@
+package store_test
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	store "github.com/gradientzero/comby-store-sqlite"
+	"github.com/gradientzero/comby/v3"
+)
+
+func TestEventStoreListAggregates(t *testing.T) {
+	ctx := context.Background()
+	eventStore := store.NewEventStoreSQLite(filepath.Join(t.TempDir(), "eventStore-aggregates.db"))
+	if err := eventStore.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer eventStore.Close(ctx)
+
+	for i, e := range []struct {
+		domain, aggregateUuid string
+		version               int64
+	}{
+		{"domain-1", "aggregate-a", 1},
+		{"domain-1", "aggregate-a", 2},
+		{"domain-1", "aggregate-b", 1},
+		{"domain-1", "aggregate-a", 3},
+		{"domain-2", "aggregate-c", 1},
+	} {
+		evt := createTestEvent("tenant-1", e.domain, int64(i+1), int64(i+1)*100)
+		evt.SetAggregateUuid(e.aggregateUuid)
+		evt.SetVersion(e.version)
+		if err := eventStore.Create(ctx, comby.EventStoreCreateOptionWithEvent(evt)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	aggregates, total, err := eventStore.ListAggregates(ctx, "domain-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 2 || len(aggregates) != 2 {
+		t.Fatalf("expected 2 aggregates, got %d", total)
+	}
+	// latest update first
+	if a := aggregates[0]; a.AggregateUuid != "aggregate-a" || a.EventCount != 3 || a.Version != 3 || a.UpdatedAt != 400 {
+		t.Fatalf("unexpected aggregate: %+v", a)
+	}
+
+	aggregates, total, err = eventStore.ListAggregates(ctx, "",
+		store.AggregateListWithOrderBy("aggregate_uuid", true),
+		store.AggregateListWithPage(1, 1),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 3 || len(aggregates) != 1 || aggregates[0].AggregateUuid != "aggregate-b" {
+		t.Fatalf("unexpected page: %d %+v", total, aggregates)
+	}
+	if _, _, err := eventStore.ListAggregates(ctx, "", store.AggregateListWithOrderBy("data_bytes", true)); err == nil {
+		t.Fatal("expected error for invalid order by")
+	}
+}
+
+func TestDeleteAggregate(t *testing.T) {
+	ctx := context.Background()
+	stores := openTestStores(t, filepath.Join(t.TempDir(), "delete-aggregate.db"), "12345678901234567890123456789012")
+
+	for version := int64(1); version <= 4; version++ {
+		evt := createTestEvent("tenant-1", "domain-1", version, version*100)
+		if version < 4 {
+			evt.SetAggregateUuid("aggregate-1")
+		}
+		if err := stores.EventStore.Create(ctx, comby.EventStoreCreateOptionWithEvent(evt)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := stores.SnapshotStore.Save(ctx, &comby.SnapshotStoreModel{
+		AggregateUuid: "aggregate-1",
+		TenantUuid:    "tenant-1",
+		Domain:        "domain-1",
+		Version:       3,
+		Data:          []byte("snapshot"),
+		CreatedAt:     300,
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := stores.DeleteAggregate(store.WithAuditActor(ctx, "ops"), "aggregate-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.NumEvents != 3 || report.NumSnapshots != 1 {
+		t.Fatalf("unexpected report: %+v", report)
+	}
+	snapshot, evts, err := stores.LoadAggregate(ctx, "aggregate-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if snapshot != nil || len(evts) != 0 {
+		t.Fatalf("expected the aggregate to be gone, got %v and %d events", snapshot, len(evts))
+	}
+	// other aggregates are kept
+	if total := stores.EventStore.Total(ctx); total != 1 {
+		t.Fatalf("expected 1 remaining event, got %d", total)
+	}
+
+	entries, err := stores.EventStore.(store.EventStoreSQLite).ListAdminAudit(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Operation != store.AdminOperationDeleteAggregate || entries[0].Target != "aggregate-1" || entries[0].Rows != 3 {
+		t.Fatalf("unexpected audit entries: %+v", entries)
+	}
+	if _, err := stores.DeleteAggregate(ctx, ""); err == nil {
+		t.Fatal("expected an error for an empty aggregate uuid")
+	}
+}
@@ -0,0 +1,60 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// analyticProfile tunes connections for heavy reports: 1 GiB mmap, a 256 MiB
+// page cache per connection, temporary storage in memory and no writes.
+var analyticProfile = readProfile{
+	MmapSize:        1 << 30,
+	CacheSize:       256 << 20,
+	TempStoreMemory: true,
+	QueryOnly:       true,
+}
+
+// OpenAnalytic opens a copy of a production database for heavy reports and
+// initializes the stores. The file is opened read-only and immutable, so
+// SQLite takes no locks and the file can not be changed by any means. The
+// event and command store are opened unless opts request others, snapshot
+// stores are not supported. The file must not be written by other processes
+// while it is open, as cached pages would not be invalidated.
+func OpenAnalytic(ctx context.Context, path string, opts ...OpenOption) (*Stores, error) {
+	config := openConfig{}
+	for _, opt := range opts {
+		opt(&config)
+	}
+	if config.SnapshotStore {
+		return nil, fmt.Errorf("'sqlite - %s' failed to open for analytics - snapshot stores are not supported", path)
+	}
+	if !config.EventStore && !config.CommandStore {
+		opts = append(opts, WithEventStore(), WithCommandStore())
+	}
+	opts = append(opts, func(c *openConfig) { c.Analytic = true })
+
+	stores, err := Open(analyticPath(path), opts...)
+	if err != nil {
+		return nil, err
+	}
+	if err := stores.Init(ctx); err != nil {
+		stores.Close(ctx)
+		return nil, err
+	}
+	return stores, nil
+}
+
+// analyticPath returns the URI of the database at path opened read-only and
+// immutable.
+func analyticPath(path string) string {
+	if !strings.HasPrefix(path, "file:") {
+		// escape characters with a meaning in URIs
+		path = "file:" + strings.NewReplacer("%", "%25", "?", "%3f", "#", "%23").Replace(path)
+	}
+	sep := "?"
+	if strings.Contains(path, "?") {
+		sep = "&"
+	}
+	return path + sep + "mode=ro&immutable=1"
+}
@@ -0,0 +1,73 @@
+package store_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	store "github.com/gradientzero/comby-store-sqlite"
+	"github.com/gradientzero/comby/v3"
+)
+
+func TestOpenAnalytic(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "production.db")
+	production, err := store.Open(path, store.WithEventStore(), store.WithCommandStore())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := production.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	eventStore, commandStore := production.EventStore, production.CommandStore
+	for i := range 10 {
+		evt := createTestEvent("tenant-1", "domain-1", int64(i+1), int64(100+i))
+		if err := eventStore.Create(ctx, comby.EventStoreCreateOptionWithEvent(evt)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cmd := createTestCommand("tenant-1", "domain-1", 100)
+	if err := commandStore.Create(ctx, comby.CommandStoreCreateOptionWithCommand(cmd)); err != nil {
+		t.Fatal(err)
+	}
+	if err := production.Close(ctx); err != nil {
+		t.Fatal(err)
+	}
+	before, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stores, err := store.OpenAnalytic(ctx, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total := stores.EventStore.Total(ctx); total != 10 {
+		t.Fatalf("expected 10 events, got %d", total)
+	}
+	if _, total, err := stores.CommandStore.List(ctx); err != nil || total != 1 {
+		t.Fatalf("expected 1 command, got %d (%v)", total, err)
+	}
+	evt := createTestEvent("tenant-1", "domain-1", 11, 200)
+	if err := stores.EventStore.Create(ctx, comby.EventStoreCreateOptionWithEvent(evt)); err == nil {
+		t.Fatal("expected create to fail")
+	}
+	if err := stores.Close(ctx); err != nil {
+		t.Fatal(err)
+	}
+	after, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(before) != string(after) {
+		t.Fatal("expected the file to be unchanged")
+	}
+
+	if _, err := store.OpenAnalytic(ctx, path, store.WithSnapshotStore()); err == nil {
+		t.Fatal("expected snapshot stores to be rejected")
+	}
+	if _, err := store.OpenAnalytic(ctx, filepath.Join(t.TempDir(), "missing.db")); err == nil {
+		t.Fatal("expected missing files to fail")
+	}
+}
@@ -0,0 +1,61 @@
+package store
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/gradientzero/comby/v3"
+)
+
+// EventStoreListOptionAsOf restricts List to events created at or before
+// timestamp (unix nano), i.e. the store as it looked at that time. It may be
+// combined with a time range, the stricter upper bound wins.
+func EventStoreListOptionAsOf(timestamp int64) comby.EventStoreListOption {
+	return func(opt *comby.EventStoreListOptions) (*comby.EventStoreListOptions, error) {
+		if opt.Before < 0 || opt.Before > timestamp+1 {
+			opt.Before = timestamp + 1
+		}
+		return opt, nil
+	}
+}
+
+// GetAsOf returns the event if it existed at timestamp (created at or before),
+// nil otherwise.
+func (es *eventStoreSQLite) GetAsOf(ctx context.Context, eventUuid string, timestamp int64) (comby.Event, error) {
+	evt, err := es.Get(ctx, comby.EventStoreGetOptionWithEventUuid(eventUuid))
+	if err != nil || evt == nil || evt.GetCreatedAt() > timestamp {
+		return nil, err
+	}
+	return evt, nil
+}
+
+// AggregateAsOf passes the events of an aggregate created at or before
+// timestamp in version order to apply, which folds them into the state of the
+// aggregate at that time. It returns the version of the last applied event, 0
+// if the aggregate did not exist yet.
+func (es *eventStoreSQLite) AggregateAsOf(ctx context.Context, aggregateUuid string, timestamp int64, apply func(evt comby.Event) error) (int64, error) {
+	if len(aggregateUuid) == 0 {
+		return 0, fmt.Errorf("'%s' failed to load aggregate - aggregate uuid is required", es.String())
+	}
+	if err := es.authorize(ctx, AccessRequest{Operation: OperationList, AggregateUuid: aggregateUuid}); err != nil {
+		return 0, err
+	}
+	whereList, args := tenantCondition(es.cfg().Tenant, []string{"aggregate_uuid=?", "created_at<=?"}, []any{aggregateUuid, timestamp})
+	query := fmt.Sprintf("SELECT %s FROM events%s ORDER BY version ASC, id ASC;", eventSelectColumns, whereSQL(whereList))
+	dbRecords, err := es.queryEvents(ctx, query, args)
+	if err != nil {
+		return 0, classifyError(err)
+	}
+	var version int64
+	for _, dbRecord := range dbRecords {
+		evt, err := es.decodeEvent(ctx, dbRecord)
+		if err != nil {
+			return version, err
+		}
+		if err := apply(evt); err != nil {
+			return version, err
+		}
+		version = evt.GetVersion()
+	}
+	return version, nil
+}
@@ -0,0 +1,64 @@
+package store_test
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	store "github.com/gradientzero/comby-store-sqlite"
+	"github.com/gradientzero/comby/v3"
+)
+
+func TestEventStoreAsOf(t *testing.T) {
+	ctx := context.Background()
+	eventStore := store.NewEventStoreSQLite(filepath.Join(t.TempDir(), "eventStore-asof.db"))
+	if err := eventStore.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer eventStore.Close(ctx)
+
+	var evts []comby.Event
+	for i := int64(1); i <= 4; i++ {
+		evt := createTestEvent("tenant-1", "domain-1", i, i*100)
+		evt.SetAggregateUuid("aggregate-1")
+		if err := eventStore.Create(ctx, comby.EventStoreCreateOptionWithEvent(evt)); err != nil {
+			t.Fatal(err)
+		}
+		evts = append(evts, evt)
+	}
+
+	// created_at == timestamp is included
+	_, total, err := eventStore.List(ctx, store.EventStoreListOptionAsOf(200))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 2 {
+		t.Fatalf("expected 2 events as of 200, got %d", total)
+	}
+	before := func(opt *comby.EventStoreListOptions) (*comby.EventStoreListOptions, error) {
+		opt.Before = 150
+		return opt, nil
+	}
+	if _, total, err = eventStore.List(ctx, before, store.EventStoreListOptionAsOf(300)); err != nil || total != 1 {
+		t.Fatalf("expected stricter bound to win: %d %v", total, err)
+	}
+
+	if evt, err := eventStore.GetAsOf(ctx, evts[2].GetEventUuid(), 299); err != nil || evt != nil {
+		t.Fatalf("expected no event before its creation: %v %v", evt, err)
+	}
+	if evt, err := eventStore.GetAsOf(ctx, evts[2].GetEventUuid(), 300); err != nil || evt == nil {
+		t.Fatalf("expected event at its creation: %v", err)
+	}
+
+	var applied []string
+	version, err := eventStore.AggregateAsOf(ctx, "aggregate-1", 350, func(evt comby.Event) error {
+		applied = append(applied, string(evt.GetDomainEvtBytes()))
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version != 3 || len(applied) != 3 || applied[2] != "test-data-3" {
+		t.Fatalf("unexpected state as of 350: version %d, %v", version, applied)
+	}
+}
@@ -0,0 +1,104 @@
+package store
+
+import (
+	"context"
+	"time"
+)
+
+// Administrative operations recorded in the admin audit log.
+const (
+	AdminOperationReset           = "reset"
+	AdminOperationPrune           = "prune"
+	AdminOperationRenumber        = "renumber"
+	AdminOperationEncrypt         = "encrypt"
+	AdminOperationDeleteAggregate = "delete_aggregate"
+)
+
+// AdminAuditEntry records one administrative operation on a store.
+type AdminAuditEntry struct {
+	Id int64
+	// "events" or "commands"
+	Resource  string
+	Operation string
+	// set with WithAuditActor, empty if unknown
+	Actor string
+	// e.g. the archive segment or aggregate uuid, empty for the whole store
+	Target string
+	// number of affected records
+	Rows int64
+	// unix nano
+	CreatedAt int64
+}
+
+var auditTables = []strictTable{
+	{
+		name: "admin_audit",
+		columns: `id INTEGER PRIMARY KEY,
+		resource TEXT NOT NULL,
+		operation TEXT NOT NULL,
+		actor TEXT NOT NULL,
+		target TEXT NOT NULL,
+		rows INTEGER NOT NULL,
+		created_at INTEGER NOT NULL`,
+		copyColumns: `id, resource, operation, actor, target, rows, created_at`,
+	},
+}
+
+type auditActorKey struct{}
+
+// WithAuditActor returns a context which names the actor of administrative
+// operations run with it, e.g. the operator or service account.
+func WithAuditActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, auditActorKey{}, actor)
+}
+
+// newAuditEntry describes an operation by the actor of ctx, happening now.
+func newAuditEntry(ctx context.Context, resource, operation, target string, rows int64) *AdminAuditEntry {
+	actor, _ := ctx.Value(auditActorKey{}).(string)
+	return &AdminAuditEntry{
+		Resource:  resource,
+		Operation: operation,
+		Actor:     actor,
+		Target:    target,
+		Rows:      rows,
+		CreatedAt: time.Now().UnixNano(),
+	}
+}
+
+func insertAuditEntry(ctx context.Context, q queryer, entry *AdminAuditEntry) error {
+	query := `INSERT INTO admin_audit (resource, operation, actor, target, rows, created_at) VALUES (?, ?, ?, ?, ?, ?);`
+	_, err := q.ExecContext(ctx, query, entry.Resource, entry.Operation, entry.Actor, entry.Target, entry.Rows, entry.CreatedAt)
+	return err
+}
+
+func listAuditEntries(ctx context.Context, q queryer, resource string) ([]AdminAuditEntry, error) {
+	query := `SELECT id, resource, operation, actor, target, rows, created_at FROM admin_audit WHERE resource=? ORDER BY id ASC;`
+	rows, err := q.QueryContext(ctx, query, resource)
+	if err != nil {
+		return nil, classifyError(err)
+	}
+	defer rows.Close()
+
+	var entries []AdminAuditEntry
+	for rows.Next() {
+		var entry AdminAuditEntry
+		if err := rows.Scan(&entry.Id, &entry.Resource, &entry.Operation, &entry.Actor, &entry.Target, &entry.Rows, &entry.CreatedAt); err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
+}
+
+// ListAdminAudit returns the administrative operations recorded for the event
+// store in the order they happened. A Reset deletes the database, so it is
+// recorded when the store is initialized again.
+func (es *eventStoreSQLite) ListAdminAudit(ctx context.Context) ([]AdminAuditEntry, error) {
+	return listAuditEntries(ctx, es.db, "events")
+}
+
+// ListAdminAudit returns the administrative operations recorded for the
+// command store, see ListAdminAudit of the event store.
+func (cs *commandStoreSQLite) ListAdminAudit(ctx context.Context) ([]AdminAuditEntry, error) {
+	return listAuditEntries(ctx, cs.db, "commands")
+}
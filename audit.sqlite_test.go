@@ -0,0 +1,88 @@
+package store_test
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	store "github.com/gradientzero/comby-store-sqlite"
+	"github.com/gradientzero/comby/v3"
+)
+
+func TestEventStoreAdminAudit(t *testing.T) {
+	ctx := store.WithAuditActor(context.Background(), "operator")
+	eventStore := store.NewEventStoreSQLite(filepath.Join(t.TempDir(), "audit.db"))
+	if err := eventStore.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer eventStore.Close(ctx)
+
+	var aggregateUuid string
+	for _, version := range []int64{1, 3} {
+		evt := createTestEvent("tenant-1", "domain-1", version, version)
+		if len(aggregateUuid) == 0 {
+			aggregateUuid = evt.GetAggregateUuid()
+		}
+		evt.SetAggregateUuid(aggregateUuid)
+		if err := eventStore.Create(ctx, comby.EventStoreCreateOptionWithEvent(evt)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := eventStore.RenumberAggregate(ctx, aggregateUuid); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := eventStore.ListAdminAudit(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("expected 1 entry, got %+v", entries)
+	}
+	entry := entries[0]
+	if entry.Resource != "events" || entry.Operation != store.AdminOperationRenumber || entry.Actor != "operator" ||
+		entry.Target != aggregateUuid || entry.Rows != 1 || entry.CreatedAt == 0 {
+		t.Fatalf("unexpected entry %+v", entry)
+	}
+
+	// the reset is recorded in the recreated database
+	if err := eventStore.Reset(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := eventStore.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	entries, err = eventStore.ListAdminAudit(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Operation != store.AdminOperationReset || entries[0].Rows != 2 || entries[0].Actor != "operator" {
+		t.Fatalf("expected audited reset, got %+v", entries)
+	}
+}
+
+func TestCommandStoreAdminAudit(t *testing.T) {
+	ctx := context.Background()
+	commandStore := store.NewCommandStoreSQLite(filepath.Join(t.TempDir(), "audit.db"))
+	if err := commandStore.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer commandStore.Close(ctx)
+
+	if err := commandStore.Create(ctx, comby.CommandStoreCreateOptionWithCommand(createTestCommand("tenant-1", "domain-1", 1))); err != nil {
+		t.Fatal(err)
+	}
+	if err := commandStore.Reset(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := commandStore.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	entries, err := commandStore.ListAdminAudit(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Resource != "commands" || entries[0].Operation != store.AdminOperationReset || entries[0].Rows != 1 || entries[0].Actor != "" {
+		t.Fatalf("expected audited reset, got %+v", entries)
+	}
+}
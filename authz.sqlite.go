@@ -0,0 +1,180 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/gradientzero/comby/v3"
+)
+
+// Operations passed to an Authorizer.
+const (
+	OperationGet    = "get"
+	OperationList   = "list"
+	OperationCreate = "create"
+	OperationUpdate = "update"
+	OperationDelete = "delete"
+)
+
+// AccessRequest describes an operation on a store for an Authorizer. Lists
+// carry their filters, empty fields are not filtered on. Get, Update and
+// Delete carry the attributes of the stored record, Create those of the new one.
+type AccessRequest struct {
+	// "events" or "commands"
+	Resource   string
+	Operation  string
+	Uuid       string
+	TenantUuid string
+	// lists of several tenants
+	TenantUuids   []string
+	Domains       []string
+	AggregateUuid string
+}
+
+// ErrAccessDenied is matched by the errors of operations an Authorizer
+// rejected, the error of the Authorizer stays reachable as well.
+var ErrAccessDenied = errors.New("access denied")
+
+// Authorizer decides whether an operation may be executed, e.g. based on the
+// identity carried in ctx. A non-nil error aborts the operation and is
+// returned to the caller wrapped.
+type Authorizer func(ctx context.Context, req AccessRequest) error
+
+// EventStoreSQLiteWithAuthorizer runs fn before Get, List, ListPage,
+// ListAfterUuid, ListEach, ListBatches, ListMetadata, ListDataTypes,
+// UniqueList, UniqueListCounts, UniqueListFields, Replay, AggregateAsOf,
+// Create, Update and Delete (also within transactions, read snapshots and
+// bulks). ListBatches and ListDataTypes are authorized as lists without
+// filters. GetAsOf authorizes through Get, ListEventsByCommand authorizes each
+// event it returns as a get. Diagnostics and maintenance functions, e.g.
+// Inspect or VerifyAll, are not authorized.
+func EventStoreSQLiteWithAuthorizer(fn Authorizer) EventStoreSQLiteOption {
+	return func(c *eventStoreSQLiteConfig) { c.Authorizer = fn }
+}
+
+// CommandStoreSQLiteWithAuthorizer runs fn before Get, List, ListPage,
+// ListAfterUuid, ListEach, ListBatches, ListByStatus, ListDataTypes,
+// ReplayCommands, Create, Update and Delete. GetStatus is authorized as a get,
+// MarkProcessed and MarkFailed as an update of the stored command.
+// ListCommandsByEvent authorizes each command it returns as a get, see
+// EventStoreSQLiteWithAuthorizer.
+func CommandStoreSQLiteWithAuthorizer(fn Authorizer) CommandStoreSQLiteOption {
+	return func(c *commandStoreSQLiteConfig) { c.Authorizer = fn }
+}
+
+func (es *eventStoreSQLite) authorize(ctx context.Context, req AccessRequest) error {
+	fn := es.cfg().Authorizer
+	if fn == nil {
+		return nil
+	}
+	req.Resource = "events"
+	if err := fn(ctx, req); err != nil {
+		return fmt.Errorf("'%s' failed to %s events - %w: %w", es.String(), req.Operation, ErrAccessDenied, err)
+	}
+	return nil
+}
+
+func (es *eventStoreSQLite) authorizeList(ctx context.Context, listOpts comby.EventStoreListOptions, filter eventFilter) error {
+	return es.authorize(ctx, AccessRequest{
+		Operation:     OperationList,
+		TenantUuid:    listOpts.TenantUuid,
+		TenantUuids:   filter.TenantUuids,
+		Domains:       listOpts.Domains,
+		AggregateUuid: listOpts.AggregateUuid,
+	})
+}
+
+func (es *eventStoreSQLite) authorizeEvent(ctx context.Context, operation string, evt comby.Event) error {
+	return es.authorize(ctx, AccessRequest{
+		Operation:     operation,
+		Uuid:          evt.GetEventUuid(),
+		TenantUuid:    evt.GetTenantUuid(),
+		Domains:       []string{evt.GetDomain()},
+		AggregateUuid: evt.GetAggregateUuid(),
+	})
+}
+
+// authorizeGet authorizes a loaded event with its attributes, a missing one
+// with its uuid only.
+func (es *eventStoreSQLite) authorizeGet(ctx context.Context, eventUuid string, evt comby.Event) error {
+	if evt == nil {
+		return es.authorize(ctx, AccessRequest{Operation: OperationGet, Uuid: eventUuid})
+	}
+	return es.authorizeEvent(ctx, OperationGet, evt)
+}
+
+// authorizeStored authorizes an operation on a stored event with its
+// attributes. Unknown events are authorized with their uuid only.
+func (es *eventStoreSQLite) authorizeStored(ctx context.Context, q queryer, operation, eventUuid string) error {
+	if es.cfg().Authorizer == nil {
+		return nil
+	}
+	req := AccessRequest{Operation: operation, Uuid: eventUuid}
+	var domain string
+	err := q.QueryRowContext(ctx, "SELECT tenant_uuid, domain, aggregate_uuid FROM events WHERE uuid=? LIMIT 1;", eventUuid).Scan(&req.TenantUuid, &domain, &req.AggregateUuid)
+	switch {
+	case err == nil:
+		req.Domains = []string{domain}
+	case err != sql.ErrNoRows:
+		return err
+	}
+	return es.authorize(ctx, req)
+}
+
+func (cs *commandStoreSQLite) authorize(ctx context.Context, req AccessRequest) error {
+	fn := cs.cfg().Authorizer
+	if fn == nil {
+		return nil
+	}
+	req.Resource = "commands"
+	if err := fn(ctx, req); err != nil {
+		return fmt.Errorf("'%s' failed to %s commands - %w: %w", cs.String(), req.Operation, ErrAccessDenied, err)
+	}
+	return nil
+}
+
+func (cs *commandStoreSQLite) authorizeList(ctx context.Context, listOpts comby.CommandStoreListOptions, filter commandFilter) error {
+	req := AccessRequest{Operation: OperationList, TenantUuid: listOpts.TenantUuid, TenantUuids: filter.TenantUuids, Domains: filter.Domains}
+	if len(listOpts.Domain) > 0 {
+		req.Domains = append([]string{listOpts.Domain}, req.Domains...)
+	}
+	return cs.authorize(ctx, req)
+}
+
+func (cs *commandStoreSQLite) authorizeCommand(ctx context.Context, operation string, cmd comby.Command) error {
+	return cs.authorize(ctx, AccessRequest{
+		Operation:  operation,
+		Uuid:       cmd.GetCommandUuid(),
+		TenantUuid: cmd.GetTenantUuid(),
+		Domains:    []string{cmd.GetDomain()},
+	})
+}
+
+// authorizeGet authorizes a loaded command with its attributes, a missing one
+// with its uuid only.
+func (cs *commandStoreSQLite) authorizeGet(ctx context.Context, commandUuid string, cmd comby.Command) error {
+	if cmd == nil {
+		return cs.authorize(ctx, AccessRequest{Operation: OperationGet, Uuid: commandUuid})
+	}
+	return cs.authorizeCommand(ctx, OperationGet, cmd)
+}
+
+// authorizeStored authorizes an operation on a stored command with its
+// attributes. Unknown commands are authorized with their uuid only.
+func (cs *commandStoreSQLite) authorizeStored(ctx context.Context, q queryer, operation, commandUuid string) error {
+	if cs.cfg().Authorizer == nil {
+		return nil
+	}
+	req := AccessRequest{Operation: operation, Uuid: commandUuid}
+	var domain string
+	err := q.QueryRowContext(ctx, "SELECT tenant_uuid, domain FROM commands WHERE uuid=? LIMIT 1;", commandUuid).Scan(&req.TenantUuid, &domain)
+	switch {
+	case err == nil:
+		req.Domains = []string{domain}
+	case err != sql.ErrNoRows:
+		return err
+	}
+	return cs.authorize(ctx, req)
+}
@@ -0,0 +1,244 @@
+package store_test
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	store "github.com/gradientzero/comby-store-sqlite"
+	"github.com/gradientzero/comby/v3"
+)
+
+func TestEventStoreAuthorizer(t *testing.T) {
+	ctx := context.Background()
+	errDenied := errors.New("denied")
+	var mu sync.Mutex
+	var requests []store.AccessRequest
+	// only tenant-1 may be accessed, lists must filter by it
+	authorizer := func(ctx context.Context, req store.AccessRequest) error {
+		mu.Lock()
+		requests = append(requests, req)
+		mu.Unlock()
+		if req.TenantUuid != "tenant-1" {
+			return errDenied
+		}
+		return nil
+	}
+
+	eventStore := store.NewEventStoreSQLite(filepath.Join(t.TempDir(), "authz.db"))
+	eventStore.Configure(store.EventStoreSQLiteWithAuthorizer(authorizer))
+	if err := eventStore.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer eventStore.Close(ctx)
+
+	allowed := createTestEvent("tenant-1", "domain-1", 1, 1)
+	if err := eventStore.Create(ctx, comby.EventStoreCreateOptionWithEvent(allowed)); err != nil {
+		t.Fatal(err)
+	}
+	denied := createTestEvent("tenant-2", "domain-1", 1, 2)
+	if err := eventStore.Create(ctx, comby.EventStoreCreateOptionWithEvent(denied)); !errors.Is(err, errDenied) {
+		t.Fatalf("expected create to be denied, got %v", err)
+	}
+	last := requests[len(requests)-1]
+	if last.Resource != "events" || last.Operation != store.OperationCreate || last.Uuid != denied.GetEventUuid() ||
+		len(last.Domains) != 1 || last.Domains[0] != "domain-1" || last.AggregateUuid != denied.GetAggregateUuid() {
+		t.Fatalf("unexpected access request %+v", last)
+	}
+
+	if _, _, err := eventStore.List(ctx); !errors.Is(err, errDenied) {
+		t.Fatalf("expected unfiltered list to be denied, got %v", err)
+	}
+	evts, _, err := eventStore.List(ctx, func(opts *comby.EventStoreListOptions) (*comby.EventStoreListOptions, error) {
+		opts.TenantUuid = "tenant-1"
+		return opts, nil
+	})
+	if err != nil || len(evts) != 1 {
+		t.Fatalf("expected 1 event of tenant-1, got %d, %v", len(evts), err)
+	}
+
+	// get and delete are authorized with the stored attributes
+	if evt, err := eventStore.Get(ctx, comby.EventStoreGetOptionWithEventUuid(allowed.GetEventUuid())); err != nil || evt == nil {
+		t.Fatalf("expected get to be allowed, got %v, %v", evt, err)
+	}
+	if err := eventStore.Delete(ctx, comby.EventStoreDeleteOptionWithEventUuid(allowed.GetEventUuid())); err != nil {
+		t.Fatal(err)
+	}
+	last = requests[len(requests)-1]
+	if last.Operation != store.OperationDelete || last.TenantUuid != "tenant-1" || last.AggregateUuid != allowed.GetAggregateUuid() {
+		t.Fatalf("unexpected access request %+v", last)
+	}
+	if _, err := eventStore.Get(ctx, comby.EventStoreGetOptionWithEventUuid(allowed.GetEventUuid())); !errors.Is(err, errDenied) {
+		t.Fatalf("expected get of a missing event to be authorized by uuid only, got %v", err)
+	}
+}
+
+func TestCommandStoreAuthorizer(t *testing.T) {
+	ctx := context.Background()
+	errDenied := errors.New("denied")
+	commandStore := store.NewCommandStoreSQLite(filepath.Join(t.TempDir(), "authz.db"))
+	commandStore.Configure(store.CommandStoreSQLiteWithAuthorizer(func(ctx context.Context, req store.AccessRequest) error {
+		if req.Resource != "commands" || (req.Operation != store.OperationGet && req.Operation != store.OperationCreate) {
+			return errDenied
+		}
+		return nil
+	}))
+	if err := commandStore.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer commandStore.Close(ctx)
+
+	cmd := createTestCommand("tenant-1", "domain-1", 1)
+	if err := commandStore.Create(ctx, comby.CommandStoreCreateOptionWithCommand(cmd)); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := commandStore.Get(ctx, comby.CommandStoreGetOptionWithCommandUuid(cmd.GetCommandUuid())); err != nil || got == nil {
+		t.Fatalf("expected get to be allowed, got %v, %v", got, err)
+	}
+	if _, _, err := commandStore.List(ctx); !errors.Is(err, errDenied) {
+		t.Fatalf("expected list to be denied, got %v", err)
+	}
+	if err := commandStore.Delete(ctx, comby.CommandStoreDeleteOptionWithCommandUuid(cmd.GetCommandUuid())); !errors.Is(err, errDenied) {
+		t.Fatalf("expected delete to be denied, got %v", err)
+	}
+	if total := commandStore.Total(ctx); total != 1 {
+		t.Fatalf("expected command to remain, got total %d", total)
+	}
+}
+
+func TestEventStoreAuthorizerReadPaths(t *testing.T) {
+	ctx := context.Background()
+	errDenied := errors.New("denied")
+	// only tenant-1 may be read, lists must filter by it
+	eventStore := store.NewEventStoreSQLite(filepath.Join(t.TempDir(), "authz-paths.db"))
+	eventStore.Configure(store.EventStoreSQLiteWithAuthorizer(func(ctx context.Context, req store.AccessRequest) error {
+		if req.Operation != store.OperationCreate && req.TenantUuid != "tenant-1" {
+			return errDenied
+		}
+		return nil
+	}))
+	if err := eventStore.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer eventStore.Close(ctx)
+
+	denied := createTestEvent("tenant-2", "domain-1", 1, 1)
+	if err := eventStore.Create(ctx, comby.EventStoreCreateOptionWithEvent(denied)); err != nil {
+		t.Fatal(err)
+	}
+	getOpt := comby.EventStoreGetOptionWithEventUuid(denied.GetEventUuid())
+
+	if err := eventStore.WithTx(ctx, func(tx store.EventStoreTx) error {
+		_, err := tx.Get(ctx, getOpt)
+		return err
+	}); !errors.Is(err, errDenied) {
+		t.Fatalf("expected get within tx to be denied, got %v", err)
+	}
+
+	snapshot, err := eventStore.ReadSnapshot(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer snapshot.Close()
+	if _, err := snapshot.Get(ctx, getOpt); !errors.Is(err, errDenied) {
+		t.Fatalf("expected get of read snapshot to be denied, got %v", err)
+	}
+	if _, _, err := snapshot.List(ctx); !errors.Is(err, errDenied) {
+		t.Fatalf("expected list of read snapshot to be denied, got %v", err)
+	}
+
+	if _, err := eventStore.Replay(ctx, func(ctx context.Context, seq int64, evt comby.Event) error {
+		return nil
+	}); !errors.Is(err, errDenied) {
+		t.Fatalf("expected replay to be denied, got %v", err)
+	}
+	if _, err := eventStore.GetAsOf(ctx, denied.GetEventUuid(), denied.GetCreatedAt()); !errors.Is(err, errDenied) {
+		t.Fatalf("expected get as of to be denied, got %v", err)
+	}
+	if _, err := eventStore.AggregateAsOf(ctx, denied.GetAggregateUuid(), denied.GetCreatedAt(), func(evt comby.Event) error {
+		return nil
+	}); !errors.Is(err, errDenied) {
+		t.Fatalf("expected aggregate as of to be denied, got %v", err)
+	}
+	if _, err := eventStore.ListEventsByCommand(ctx, denied.GetCommandUuid()); !errors.Is(err, errDenied) {
+		t.Fatalf("expected list by command to be denied, got %v", err)
+	}
+	if err := eventStore.ListBatches(ctx, 10, func(evts []comby.Event) error {
+		return nil
+	}); !errors.Is(err, errDenied) {
+		t.Fatalf("expected list batches to be denied, got %v", err)
+	}
+	if _, _, err := eventStore.UniqueListFields(ctx, []string{"domain"}); !errors.Is(err, errDenied) {
+		t.Fatalf("expected unique list fields to be denied, got %v", err)
+	}
+	if _, err := eventStore.ListDataTypes(ctx); !errors.Is(err, errDenied) {
+		t.Fatalf("expected list data types to be denied, got %v", err)
+	}
+}
+
+func TestCommandStoreAuthorizerReadPaths(t *testing.T) {
+	ctx := context.Background()
+	errDenied := errors.New("denied")
+	commandStore := store.NewCommandStoreSQLite(filepath.Join(t.TempDir(), "authz-paths.db"))
+	commandStore.Configure(store.CommandStoreSQLiteWithAuthorizer(func(ctx context.Context, req store.AccessRequest) error {
+		if req.Operation != store.OperationCreate && req.TenantUuid != "tenant-1" {
+			return errDenied
+		}
+		return nil
+	}))
+	if err := commandStore.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer commandStore.Close(ctx)
+
+	denied := createTestCommand("tenant-2", "domain-1", 1)
+	if err := commandStore.Create(ctx,
+		comby.CommandStoreCreateOptionWithCommand(denied),
+		store.CommandStoreCreateOptionCausedBy("event-1"),
+	); err != nil {
+		t.Fatal(err)
+	}
+	getOpt := comby.CommandStoreGetOptionWithCommandUuid(denied.GetCommandUuid())
+
+	if err := commandStore.WithTx(ctx, func(tx store.CommandStoreTx) error {
+		_, err := tx.Get(ctx, getOpt)
+		return err
+	}); !errors.Is(err, errDenied) {
+		t.Fatalf("expected get within tx to be denied, got %v", err)
+	}
+
+	snapshot, err := commandStore.ReadSnapshot(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer snapshot.Close()
+	if _, err := snapshot.Get(ctx, getOpt); !errors.Is(err, errDenied) {
+		t.Fatalf("expected get of read snapshot to be denied, got %v", err)
+	}
+	if _, _, err := snapshot.List(ctx); !errors.Is(err, errDenied) {
+		t.Fatalf("expected list of read snapshot to be denied, got %v", err)
+	}
+
+	if _, err := commandStore.ListCommandsByEvent(ctx, "event-1"); !errors.Is(err, errDenied) {
+		t.Fatalf("expected list by event to be denied, got %v", err)
+	}
+	if err := commandStore.ListBatches(ctx, 10, func(cmds []comby.Command) error {
+		return nil
+	}); !errors.Is(err, errDenied) {
+		t.Fatalf("expected list batches to be denied, got %v", err)
+	}
+	if _, err := commandStore.ListDataTypes(ctx); !errors.Is(err, errDenied) {
+		t.Fatalf("expected list data types to be denied, got %v", err)
+	}
+	if _, err := commandStore.GetStatus(ctx, denied.GetCommandUuid()); !errors.Is(err, errDenied) {
+		t.Fatalf("expected get status to be denied, got %v", err)
+	}
+	if err := commandStore.MarkProcessed(ctx, denied.GetCommandUuid()); !errors.Is(err, errDenied) {
+		t.Fatalf("expected mark processed to be denied, got %v", err)
+	}
+	if err := commandStore.MarkFailed(ctx, denied.GetCommandUuid(), "failed"); !errors.Is(err, errDenied) {
+		t.Fatalf("expected mark failed to be denied, got %v", err)
+	}
+}
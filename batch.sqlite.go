@@ -0,0 +1,182 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/gradientzero/comby-store-sqlite/internal"
+	"github.com/gradientzero/comby/v3"
+)
+
+// ListBatches passes all events in store order to fn, batchSize events at a
+// time, e.g. to copy them into another store. Pages are read with a cursor on
+// the store sequence, so events created during the iteration are included and
+// none are skipped or repeated. It stops at the first error of fn.
+func (es *eventStoreSQLite) ListBatches(ctx context.Context, batchSize int, fn func([]comby.Event) error) error {
+	if fn == nil {
+		return fmt.Errorf("'%s' failed to list batches - fn is nil", es.String())
+	}
+	if batchSize < 1 {
+		return fmt.Errorf("'%s' failed to list batches - invalid batch size %d", es.String(), batchSize)
+	}
+	// all events are listed, so it is authorized as a list without filters
+	if err := es.authorizeList(ctx, comby.EventStoreListOptions{}, eventFilter{}); err != nil {
+		return err
+	}
+	whereList, args := tenantCondition(es.cfg().Tenant, []string{"id>?"}, nil)
+	query := fmt.Sprintf("SELECT %s FROM events WHERE %s ORDER BY id ASC LIMIT %d;", eventSelectColumns, strings.Join(whereList, " AND "), batchSize)
+	var lastSeq int64
+	for {
+		dbRecords, err := es.queryEvents(ctx, query, append([]any{lastSeq}, args...))
+		if err != nil {
+			return classifyError(err)
+		}
+		if len(dbRecords) == 0 {
+			return nil
+		}
+		evts := make([]comby.Event, 0, len(dbRecords))
+		for _, dbRecord := range dbRecords {
+			evt, err := es.decodeEvent(ctx, dbRecord)
+			if err != nil {
+				return err
+			}
+			evts = append(evts, evt)
+		}
+		if err := fn(evts); err != nil {
+			return err
+		}
+		lastSeq = dbRecords[len(dbRecords)-1].ID.Int64
+	}
+}
+
+// ListBatches passes all commands in store order to fn, batchSize commands at
+// a time, see ListBatches of the event store.
+func (cs *commandStoreSQLite) ListBatches(ctx context.Context, batchSize int, fn func([]comby.Command) error) error {
+	if fn == nil {
+		return fmt.Errorf("'%s' failed to list batches - fn is nil", cs.String())
+	}
+	if batchSize < 1 {
+		return fmt.Errorf("'%s' failed to list batches - invalid batch size %d", cs.String(), batchSize)
+	}
+	if err := cs.authorizeList(ctx, comby.CommandStoreListOptions{}, commandFilter{}); err != nil {
+		return err
+	}
+	whereList, args := tenantCondition(cs.cfg().Tenant, []string{"id>?"}, nil)
+	query := fmt.Sprintf("SELECT %s FROM commands WHERE %s ORDER BY id ASC LIMIT %d;", commandSelectColumns, strings.Join(whereList, " AND "), batchSize)
+	var lastSeq int64
+	for {
+		dbRecords, err := queryCommandRecords(ctx, cs.db, query, append([]any{lastSeq}, args...)...)
+		if err != nil {
+			return classifyError(err)
+		}
+		if len(dbRecords) == 0 {
+			return nil
+		}
+		for _, dbRecord := range dbRecords {
+			if err := cs.decodeDomainData(ctx, dbRecord); err != nil {
+				return err
+			}
+		}
+		cmds, err := internal.DbCommandsToBaseCommands(dbRecords)
+		if err != nil {
+			return err
+		}
+		if err := fn(cmds); err != nil {
+			return err
+		}
+		lastSeq = dbRecords[len(dbRecords)-1].ID.Int64
+	}
+}
+
+// queryCommandRecords returns the records selected by query, which must select commandSelectColumns.
+func queryCommandRecords(ctx context.Context, q queryer, query string, args ...any) ([]*internal.Command, error) {
+	rows, err := q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var dbRecords []*internal.Command
+	scanner := newPayloadScanner(rows)
+	for rows.Next() {
+		var dbRecord internal.Command
+		if err := scanCommand(scanner, &dbRecord); err != nil {
+			return nil, err
+		}
+		dbRecords = append(dbRecords, &dbRecord)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	return dbRecords, rows.Err()
+}
+
+// ListEach passes the events matching the options of List to fn one by one
+// while they are read, so a list without limit (Limit -1) needs constant
+// memory and is not capped by the maximum list limit. Unlike ListBatches it
+// applies the list filters and order. It bypasses the query cache and the
+// archive read-through, stops at the first error of fn and returns the number
+// of passed events. A database connection is held until it returns.
+func (es *eventStoreSQLite) ListEach(ctx context.Context, fn func(evt comby.Event) error, opts ...comby.EventStoreListOption) (int64, error) {
+	if fn == nil {
+		return 0, fmt.Errorf("'%s' failed to list events - fn is nil", es.String())
+	}
+	ctx, cancel := es.cfg().Timeouts.read(ctx)
+	defer cancel()
+	listOpts, filter, err := es.listOptions(opts)
+	if err != nil {
+		return 0, err
+	}
+	if err := es.authorizeList(ctx, listOpts, filter); err != nil {
+		return 0, err
+	}
+	filter.SkipTotal = true
+	var n int64
+	_, err = es.eachRecord(ctx, es.db, "events", eventSelectColumns, listOpts, filter, func(dbRecord *internal.Event) error {
+		evt, err := es.decodeEvent(ctx, dbRecord)
+		if err != nil {
+			return err
+		}
+		if err := fn(evt); err != nil {
+			return err
+		}
+		n++
+		return nil
+	})
+	return n, classifyError(err)
+}
+
+// ListEach passes the commands of a list to fn one by one while they are
+// read, see ListEach of the event store.
+func (cs *commandStoreSQLite) ListEach(ctx context.Context, fn func(cmd comby.Command) error, opts ...comby.CommandStoreListOption) (int64, error) {
+	if fn == nil {
+		return 0, fmt.Errorf("'%s' failed to list commands - fn is nil", cs.String())
+	}
+	ctx, cancel := cs.cfg().Timeouts.read(ctx)
+	defer cancel()
+	listOpts, filter, err := cs.listOptions(opts)
+	if err != nil {
+		return 0, err
+	}
+	if err := cs.authorizeList(ctx, listOpts, filter); err != nil {
+		return 0, err
+	}
+	filter.SkipTotal = true
+	var n int64
+	_, err = cs.eachRecord(ctx, cs.db, listOpts, filter, func(dbRecord *internal.Command) error {
+		if err := cs.decodeDomainData(ctx, dbRecord); err != nil {
+			return err
+		}
+		cmd, err := internal.DbCommandToBaseCommand(dbRecord)
+		if err != nil {
+			return err
+		}
+		if err := fn(cmd); err != nil {
+			return err
+		}
+		n++
+		return nil
+	})
+	return n, classifyError(err)
+}
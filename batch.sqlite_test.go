@@ -0,0 +1,166 @@
+package store_test
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	store "github.com/gradientzero/comby-store-sqlite"
+	"github.com/gradientzero/comby/v3"
+)
+
+func TestEventStoreListBatches(t *testing.T) {
+	ctx := context.Background()
+	eventStore := store.NewEventStoreSQLite(filepath.Join(t.TempDir(), "batch.db"))
+	if err := eventStore.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer eventStore.Close(ctx)
+
+	create := func(version int64) {
+		evt := createTestEvent("tenant-1", "domain-1", version, version)
+		if err := eventStore.Create(ctx, comby.EventStoreCreateOptionWithEvent(evt)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i := int64(1); i <= 5; i++ {
+		create(i)
+	}
+
+	var sizes []int
+	var versions []int64
+	err := eventStore.ListBatches(ctx, 2, func(evts []comby.Event) error {
+		if len(sizes) == 0 {
+			// created during the iteration, delivered in the last batch
+			create(6)
+		}
+		sizes = append(sizes, len(evts))
+		for _, evt := range evts {
+			versions = append(versions, evt.GetVersion())
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sizes) != 3 || sizes[0] != 2 || sizes[1] != 2 || sizes[2] != 2 {
+		t.Fatalf("unexpected batch sizes %v", sizes)
+	}
+	for i, version := range versions {
+		if version != int64(i+1) {
+			t.Fatalf("unexpected order %v", versions)
+		}
+	}
+
+	errStop := errors.New("stop")
+	calls := 0
+	err = eventStore.ListBatches(ctx, 2, func(evts []comby.Event) error {
+		calls++
+		return errStop
+	})
+	if !errors.Is(err, errStop) || calls != 1 {
+		t.Fatalf("expected iteration to stop at first error, got %v after %d calls", err, calls)
+	}
+	if err := eventStore.ListBatches(ctx, 0, func([]comby.Event) error { return nil }); err == nil {
+		t.Fatal("expected invalid batch size to fail")
+	}
+}
+
+func TestCommandStoreListBatches(t *testing.T) {
+	ctx := context.Background()
+	commandStore := store.NewCommandStoreSQLite(filepath.Join(t.TempDir(), "batch.db"))
+	if err := commandStore.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer commandStore.Close(ctx)
+
+	for i := int64(1); i <= 3; i++ {
+		cmd := createTestCommand("tenant-1", "domain-1", i)
+		if err := commandStore.Create(ctx, comby.CommandStoreCreateOptionWithCommand(cmd)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var createdAt []int64
+	err := commandStore.ListBatches(ctx, 2, func(cmds []comby.Command) error {
+		for _, cmd := range cmds {
+			createdAt = append(createdAt, cmd.GetCreatedAt())
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(createdAt) != 3 || createdAt[0] != 1 || createdAt[2] != 3 {
+		t.Fatalf("unexpected commands %v", createdAt)
+	}
+}
+
+func TestEventStoreListEach(t *testing.T) {
+	ctx := context.Background()
+	eventStore := store.NewEventStoreSQLite(filepath.Join(t.TempDir(), "each.db"))
+	eventStore.Configure(store.EventStoreSQLiteWithMaxListLimit(2))
+	if err := eventStore.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer eventStore.Close(ctx)
+	for i := int64(1); i <= 5; i++ {
+		domain := "domain-1"
+		if i%2 == 0 {
+			domain = "domain-2"
+		}
+		if err := eventStore.Create(ctx, comby.EventStoreCreateOptionWithEvent(createTestEvent("tenant-1", domain, i, i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	unlimited := func(opts *comby.EventStoreListOptions) (*comby.EventStoreListOptions, error) {
+		opts.Limit = -1
+		opts.Domains = []string{"domain-1"}
+		return opts, nil
+	}
+	// streaming is not capped by the maximum list limit
+	var versions []int64
+	n, err := eventStore.ListEach(ctx, func(evt comby.Event) error {
+		versions = append(versions, evt.GetVersion())
+		return nil
+	}, unlimited, comby.EventStoreListOptionAscending(false))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 3 || len(versions) != 3 || versions[0] != 5 || versions[2] != 1 {
+		t.Fatalf("unexpected events %v (%d)", versions, n)
+	}
+
+	errStop := errors.New("stop")
+	n, err = eventStore.ListEach(ctx, func(evt comby.Event) error { return errStop }, unlimited)
+	if !errors.Is(err, errStop) || n != 0 {
+		t.Fatalf("expected iteration to stop at first error, got %v after %d events", err, n)
+	}
+}
+
+func TestCommandStoreListEach(t *testing.T) {
+	ctx := context.Background()
+	commandStore := store.NewCommandStoreSQLite(filepath.Join(t.TempDir(), "each.db"))
+	if err := commandStore.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer commandStore.Close(ctx)
+	for i := int64(1); i <= 4; i++ {
+		if err := commandStore.Create(ctx, comby.CommandStoreCreateOptionWithCommand(createTestCommand("tenant-1", "domain-1", i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var createdAt []int64
+	n, err := commandStore.ListEach(ctx, func(cmd comby.Command) error {
+		createdAt = append(createdAt, cmd.GetCreatedAt())
+		return nil
+	}, store.CommandStoreListOptionFromInclusive(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 3 || len(createdAt) != 3 || createdAt[0] != 2 {
+		t.Fatalf("unexpected commands %v (%d)", createdAt, n)
+	}
+}
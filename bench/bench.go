@@ -0,0 +1,339 @@
+// Package bench runs throughput workloads against an event store and reports
+// operations per second and latency percentiles, so pragma and option
+// combinations can be compared on the hardware they will run on:
+//
+//	eventStore := store.NewEventStoreSQLite("bench.db")
+//	eventStore.Configure(store.EventStoreSQLiteWithReadOptimizedProfile())
+//	eventStore.Init(ctx)
+//	result, err := bench.Run(ctx, eventStore, bench.Config{Workload: bench.Mixed, Concurrency: 8})
+//	fmt.Println(result)
+package bench
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	store "github.com/gradientzero/comby-store-sqlite"
+	"github.com/gradientzero/comby/v3"
+)
+
+// Workload selects the operations run by Run.
+type Workload string
+
+const (
+	// AppendHeavy only appends events to aggregates.
+	AppendHeavy Workload = "append-heavy"
+	// ReadHeavy only reads: it lists the events of an aggregate, or gets a
+	// single preloaded event by its uuid every tenth operation.
+	ReadHeavy Workload = "read-heavy"
+	// Mixed reads like ReadHeavy with a share of Config.ReadRatio and appends
+	// otherwise.
+	Mixed Workload = "mixed"
+)
+
+// Config describes a run. Zero values select the defaults.
+type Config struct {
+	// operations to run, AppendHeavy if unset
+	Workload Workload
+	// the run ends after Duration or after Ops operations, whichever comes
+	// first, 10 seconds if both are unset
+	Duration time.Duration
+	Ops      int64
+	// number of goroutines running operations, 1 if unset
+	Concurrency int
+	// approximate size of the JSON payloads of appended events in bytes, 256
+	// if unset
+	PayloadSize int
+	// number of aggregates events are appended to and read from, 100 if unset
+	Aggregates int
+	// events written before the measurement starts, 10000 for ReadHeavy and
+	// Mixed if unset, negative to preload nothing
+	Preload int
+	// share of reads of the Mixed workload between 0 and 1, 0.5 if unset
+	ReadRatio float64
+	// seed of the pseudo-random generators
+	Seed uint64
+}
+
+func (cfg Config) withDefaults() (Config, error) {
+	switch cfg.Workload {
+	case "":
+		cfg.Workload = AppendHeavy
+	case AppendHeavy, ReadHeavy, Mixed:
+	default:
+		return cfg, fmt.Errorf("failed to run benchmark - unknown workload '%s'", cfg.Workload)
+	}
+	if cfg.Duration < 0 || cfg.Ops < 0 || cfg.Concurrency < 0 || cfg.PayloadSize < 0 || cfg.Aggregates < 0 {
+		return cfg, fmt.Errorf("failed to run benchmark - config has negative values")
+	}
+	if cfg.ReadRatio < 0 || cfg.ReadRatio > 1 {
+		return cfg, fmt.Errorf("failed to run benchmark - read ratio %v is not between 0 and 1", cfg.ReadRatio)
+	}
+	if cfg.Duration == 0 && cfg.Ops == 0 {
+		cfg.Duration = 10 * time.Second
+	}
+	cfg.Concurrency = max(cfg.Concurrency, 1)
+	if cfg.PayloadSize == 0 {
+		cfg.PayloadSize = 256
+	}
+	cfg.Aggregates = max(cfg.Aggregates, 1)
+	switch {
+	case cfg.Preload < 0:
+		cfg.Preload = 0
+	case cfg.Preload == 0 && cfg.Workload != AppendHeavy:
+		cfg.Preload = 10000
+	}
+	if cfg.ReadRatio == 0 {
+		cfg.ReadRatio = 0.5
+	}
+	return cfg, nil
+}
+
+// Latency summarizes the latencies of a kind of operation.
+type Latency struct {
+	Count int64
+	Mean  time.Duration
+	P50   time.Duration
+	P90   time.Duration
+	P99   time.Duration
+	Max   time.Duration
+}
+
+func (l Latency) String() string {
+	return fmt.Sprintf("p50=%s p90=%s p99=%s max=%s", l.P50, l.P90, l.P99, l.Max)
+}
+
+// Result is the outcome of a run. Latencies cover all operations and
+// separately appends and reads.
+type Result struct {
+	Workload  Workload
+	Ops       int64
+	Elapsed   time.Duration
+	OpsPerSec float64
+	Latency   Latency
+	Appends   Latency
+	Reads     Latency
+}
+
+func (r *Result) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s: %d ops in %s, %.1f ops/s, %s", r.Workload, r.Ops, r.Elapsed.Round(time.Millisecond), r.OpsPerSec, r.Latency)
+	if r.Appends.Count > 0 && r.Reads.Count > 0 {
+		fmt.Fprintf(&b, " (appends %s; reads %s)", r.Appends, r.Reads)
+	}
+	return b.String()
+}
+
+// aggregate written and read by workers, version is the latest one appended
+type aggregate struct {
+	uuid    string
+	version atomic.Int64
+}
+
+// workload state shared by the workers
+type run struct {
+	cfg        Config
+	eventStore comby.EventStore
+	aggregates []aggregate
+	// uuids of preloaded events, read by Get
+	eventUuids []string
+	// operations started so far, bounds the run to cfg.Ops
+	started atomic.Int64
+}
+
+// Run preloads eventStore as configured and then measures the workload. The
+// store must be initialized; events are written to tenant "bench" and
+// domain "bench", so a dedicated store should be used. The run ends early
+// with the error of the first failed operation or when ctx is done.
+func Run(ctx context.Context, eventStore comby.EventStore, cfg Config) (*Result, error) {
+	cfg, err := cfg.withDefaults()
+	if err != nil {
+		return nil, err
+	}
+	r := &run{cfg: cfg, eventStore: eventStore, aggregates: make([]aggregate, cfg.Aggregates)}
+	for i := range r.aggregates {
+		r.aggregates[i].uuid = comby.NewUuid()
+	}
+	if err := r.preload(ctx); err != nil {
+		return nil, err
+	}
+
+	runCtx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+	if cfg.Duration > 0 {
+		var cancelTimeout context.CancelFunc
+		runCtx, cancelTimeout = context.WithTimeout(runCtx, cfg.Duration)
+		defer cancelTimeout()
+	}
+
+	appends := make([][]time.Duration, cfg.Concurrency)
+	reads := make([][]time.Duration, cfg.Concurrency)
+	var wg sync.WaitGroup
+	start := time.Now()
+	for w := range cfg.Concurrency {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			rng := rand.New(rand.NewPCG(cfg.Seed, uint64(w)+1))
+			for runCtx.Err() == nil && (cfg.Ops == 0 || r.started.Add(1) <= cfg.Ops) {
+				read := r.nextIsRead(rng)
+				opStart := time.Now()
+				var err error
+				if read {
+					err = r.read(runCtx, rng)
+				} else {
+					err = r.append(runCtx, rng)
+				}
+				elapsed := time.Since(opStart)
+				if err != nil {
+					// operations interrupted by the end of the run are no failures
+					if runCtx.Err() == nil {
+						cancel(err)
+					}
+					return
+				}
+				if read {
+					reads[w] = append(reads[w], elapsed)
+				} else {
+					appends[w] = append(appends[w], elapsed)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	elapsed := time.Since(start)
+	if err := context.Cause(runCtx); err != nil && err != context.DeadlineExceeded {
+		return nil, fmt.Errorf("failed to run benchmark - %w", err)
+	}
+
+	allAppends, allReads := slices.Concat(appends...), slices.Concat(reads...)
+	result := &Result{
+		Workload: cfg.Workload,
+		Ops:      int64(len(allAppends) + len(allReads)),
+		Elapsed:  elapsed,
+		Latency:  summarize(slices.Concat(allAppends, allReads)),
+		Appends:  summarize(allAppends),
+		Reads:    summarize(allReads),
+	}
+	if elapsed > 0 {
+		result.OpsPerSec = float64(result.Ops) / elapsed.Seconds()
+	}
+	return result, nil
+}
+
+// preload writes cfg.Preload events spread over the aggregates, within bulk
+// transactions if the store supports them.
+func (r *run) preload(ctx context.Context) (err error) {
+	if r.cfg.Preload == 0 {
+		return nil
+	}
+	if bulk, ok := r.eventStore.(store.BulkWriter); ok {
+		if err := bulk.BeginBulk(ctx, 0); err != nil {
+			return err
+		}
+		defer func() {
+			if endErr := bulk.EndBulk(ctx); endErr != nil && err == nil {
+				err = endErr
+			}
+		}()
+	}
+	rng := rand.New(rand.NewPCG(r.cfg.Seed, 0))
+	r.eventUuids = make([]string, 0, r.cfg.Preload)
+	for i := range r.cfg.Preload {
+		evt := r.newEvent(rng, &r.aggregates[i%len(r.aggregates)])
+		if err := r.eventStore.Create(ctx, comby.EventStoreCreateOptionWithEvent(evt)); err != nil {
+			return fmt.Errorf("failed to preload benchmark events - %w", err)
+		}
+		r.eventUuids = append(r.eventUuids, evt.GetEventUuid())
+	}
+	return nil
+}
+
+func (r *run) nextIsRead(rng *rand.Rand) bool {
+	switch r.cfg.Workload {
+	case ReadHeavy:
+		return true
+	case Mixed:
+		return rng.Float64() < r.cfg.ReadRatio
+	}
+	return false
+}
+
+func (r *run) append(ctx context.Context, rng *rand.Rand) error {
+	evt := r.newEvent(rng, &r.aggregates[rng.IntN(len(r.aggregates))])
+	return r.eventStore.Create(ctx, comby.EventStoreCreateOptionWithEvent(evt))
+}
+
+func (r *run) read(ctx context.Context, rng *rand.Rand) error {
+	if len(r.eventUuids) > 0 && rng.IntN(10) == 0 {
+		_, err := r.eventStore.Get(ctx, comby.EventStoreGetOptionWithEventUuid(r.eventUuids[rng.IntN(len(r.eventUuids))]))
+		return err
+	}
+	aggregateUuid := r.aggregates[rng.IntN(len(r.aggregates))].uuid
+	_, _, err := r.eventStore.List(ctx, func(opts *comby.EventStoreListOptions) (*comby.EventStoreListOptions, error) {
+		opts.TenantUuid = "bench"
+		opts.AggregateUuid = aggregateUuid
+		return opts, nil
+	})
+	return err
+}
+
+// newEvent returns the next event of agg with a payload of about
+// cfg.PayloadSize bytes.
+func (r *run) newEvent(rng *rand.Rand, agg *aggregate) comby.Event {
+	version := agg.version.Add(1)
+	evt := comby.NewBaseEvent()
+	evt.SetEventUuid(comby.NewUuid())
+	evt.SetInstanceId(1)
+	evt.SetTenantUuid("bench")
+	evt.SetCommandUuid(comby.NewUuid())
+	evt.SetDomain("bench")
+	evt.SetAggregateUuid(agg.uuid)
+	evt.SetVersion(version)
+	evt.SetCreatedAt(time.Now().UnixNano())
+	evt.SetDomainEvtName("BenchEvent")
+	evt.SetDomainEvtBytes(payload(rng, version, r.cfg.PayloadSize))
+	return evt
+}
+
+// payload returns a JSON object of about size bytes.
+func payload(rng *rand.Rand, version int64, size int) []byte {
+	const alphabet = "abcdefghijklmnopqrstuvwxyz"
+	prefix := fmt.Sprintf(`{"version":%d,"value":"`, version)
+	value := make([]byte, max(size-len(prefix)-2, 0))
+	for i := range value {
+		value[i] = alphabet[rng.IntN(len(alphabet))]
+	}
+	return []byte(prefix + string(value) + `"}`)
+}
+
+// summarize returns mean, nearest-rank percentiles and maximum of latencies,
+// which it sorts.
+func summarize(latencies []time.Duration) Latency {
+	if len(latencies) == 0 {
+		return Latency{}
+	}
+	slices.Sort(latencies)
+	var sum time.Duration
+	for _, l := range latencies {
+		sum += l
+	}
+	percentile := func(p int) time.Duration {
+		rank := (len(latencies)*p + 99) / 100
+		return latencies[max(rank, 1)-1]
+	}
+	return Latency{
+		Count: int64(len(latencies)),
+		Mean:  sum / time.Duration(len(latencies)),
+		P50:   percentile(50),
+		P90:   percentile(90),
+		P99:   percentile(99),
+		Max:   latencies[len(latencies)-1],
+	}
+}
@@ -0,0 +1,94 @@
+package bench_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gradientzero/comby-store-sqlite/bench"
+	"github.com/gradientzero/comby-store-sqlite/storetest"
+)
+
+func TestRunWorkloads(t *testing.T) {
+	for _, workload := range []bench.Workload{bench.AppendHeavy, bench.ReadHeavy, bench.Mixed} {
+		t.Run(string(workload), func(t *testing.T) {
+			ctx := context.Background()
+			eventStore := storetest.NewTempEventStore(t)
+			result, err := bench.Run(ctx, eventStore, bench.Config{
+				Workload:    workload,
+				Ops:         200,
+				Concurrency: 4,
+				PayloadSize: 128,
+				Aggregates:  10,
+				Preload:     100,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result.Ops != 200 || result.Latency.Count != 200 || result.Appends.Count+result.Reads.Count != 200 {
+				t.Fatalf("expected 200 ops, got %+v", result)
+			}
+			if result.OpsPerSec <= 0 || result.Elapsed <= 0 {
+				t.Fatalf("expected throughput, got %+v", result)
+			}
+			l := result.Latency
+			if l.P50 <= 0 || l.P50 > l.P90 || l.P90 > l.P99 || l.P99 > l.Max || l.Mean > l.Max {
+				t.Fatalf("unexpected latencies %+v", l)
+			}
+
+			var wantAppends int64
+			switch workload {
+			case bench.AppendHeavy:
+				wantAppends = 200
+			case bench.Mixed:
+				if result.Appends.Count == 0 || result.Reads.Count == 0 {
+					t.Fatalf("expected appends and reads, got %+v", result)
+				}
+				wantAppends = result.Appends.Count
+			}
+			if result.Appends.Count != wantAppends {
+				t.Fatalf("expected %d appends, got %d", wantAppends, result.Appends.Count)
+			}
+			if total := eventStore.Total(ctx); total != 100+wantAppends {
+				t.Fatalf("expected %d events, got %d", 100+wantAppends, total)
+			}
+			if !strings.HasPrefix(result.String(), string(workload)+": 200 ops") {
+				t.Fatalf("unexpected summary %q", result.String())
+			}
+		})
+	}
+}
+
+func TestRunDuration(t *testing.T) {
+	eventStore := storetest.NewMemoryEventStore(t)
+	result, err := bench.Run(context.Background(), eventStore, bench.Config{Duration: 100 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Ops == 0 || result.Elapsed < 100*time.Millisecond || result.Elapsed > 5*time.Second {
+		t.Fatalf("unexpected result %+v", result)
+	}
+}
+
+func TestRunInvalidConfig(t *testing.T) {
+	eventStore := storetest.NewMemoryEventStore(t)
+	for _, cfg := range []bench.Config{
+		{Workload: "unknown"},
+		{Concurrency: -1},
+		{ReadRatio: 2},
+	} {
+		if _, err := bench.Run(context.Background(), eventStore, cfg); err == nil {
+			t.Fatalf("expected an error for %+v", cfg)
+		}
+	}
+}
+
+func TestRunFailure(t *testing.T) {
+	ctx := context.Background()
+	eventStore := storetest.NewTempEventStore(t)
+	eventStore.Close(ctx)
+	if _, err := bench.Run(ctx, eventStore, bench.Config{Ops: 10, Preload: -1}); err == nil {
+		t.Fatal("expected the error of the closed store")
+	}
+}
@@ -0,0 +1,194 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sync"
+
+	"github.com/gradientzero/comby/v3"
+)
+
+// default number of writes per bulk transaction
+const defaultBulkBatchSize = 1000
+
+// BulkWriter is implemented by the SQLite event and command stores. Between
+// BeginBulk and EndBulk, Create (and Write) of all goroutines is collected in
+// large transactions, which are committed every batchSize writes and by
+// EndBulk. This turns a sync of many single creates, e.g. comby.SyncEventStore,
+// into a few transactions.
+//
+// A created record is durable only once its batch is committed. Other writes
+// of the store, like Update and Delete, wait until then and must not be called
+// by the goroutine filling the batch. Reads do not see uncommitted batches.
+type BulkWriter interface {
+	BeginBulk(ctx context.Context, batchSize int) error
+	EndBulk(ctx context.Context) error
+}
+
+// bulkBatch collects writes in one open transaction.
+type bulkBatch struct {
+	mu   sync.Mutex
+	size int
+	// opens a transaction, the returned func releases the write lock
+	begin func(ctx context.Context) (*sql.Tx, func(), error)
+	tx    *sql.Tx
+	done  func()
+	n     int
+	// versions assigned to events of the open transaction
+	versions versionAssignments
+}
+
+func (b *bulkBatch) write(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.tx == nil {
+		tx, done, err := b.begin(ctx)
+		if err != nil {
+			return err
+		}
+		b.tx, b.done = tx, done
+	}
+	// a failed statement is rolled back on its own, the batch stays usable
+	if err := fn(b.tx); err != nil {
+		return classifyError(err)
+	}
+	b.n++
+	if b.n >= b.size {
+		return b.commit()
+	}
+	return nil
+}
+
+func (b *bulkBatch) flush() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.commit()
+}
+
+func (b *bulkBatch) commit() error {
+	if b.tx == nil {
+		return nil
+	}
+	err := b.tx.Commit()
+	b.done()
+	b.versions.apply(err == nil)
+	b.tx, b.done, b.n = nil, nil, 0
+	return classifyError(err)
+}
+
+// beginBulkTx opens a batch transaction. The transaction outlives the context
+// of the write which opened it.
+func beginBulkTx(ctx context.Context, db *sql.DB, beginWrite func(ctx context.Context) (func(), error)) (*sql.Tx, func(), error) {
+	done, err := beginWrite(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	tx, err := db.BeginTx(context.WithoutCancel(ctx), nil)
+	if err != nil {
+		done()
+		return nil, nil, classifyError(err)
+	}
+	return tx, done, nil
+}
+
+func (es *eventStoreSQLite) BeginBulk(ctx context.Context, batchSize int) error {
+	if es.opts().ReadOnly {
+		return fmt.Errorf("'%s' failed to begin bulk - instance is readonly", es.String())
+	}
+	if batchSize < 1 {
+		batchSize = defaultBulkBatchSize
+	}
+	batch := &bulkBatch{size: batchSize, begin: func(ctx context.Context) (*sql.Tx, func(), error) {
+		if err := es.cfg().Quota.check(ctx, es.db); err != nil {
+			return nil, nil, fmt.Errorf("'%s' failed to create event - %w", es.String(), err)
+		}
+		return beginBulkTx(ctx, es.db, es.beginWrite)
+	}}
+	if !es.bulk.CompareAndSwap(nil, batch) {
+		return fmt.Errorf("'%s' failed to begin bulk - bulk is already active", es.String())
+	}
+	return nil
+}
+
+// Write creates evt within the active bulk, or on its own without one.
+func (es *eventStoreSQLite) Write(ctx context.Context, evt comby.Event) error {
+	return es.Create(ctx, comby.EventStoreCreateOptionWithEvent(evt))
+}
+
+// EndBulk commits the open batch and returns to single transactions.
+func (es *eventStoreSQLite) EndBulk(ctx context.Context) error {
+	batch := es.bulk.Swap(nil)
+	if batch == nil {
+		return fmt.Errorf("'%s' failed to end bulk - bulk is not active", es.String())
+	}
+	return batch.flush()
+}
+
+func (cs *commandStoreSQLite) BeginBulk(ctx context.Context, batchSize int) error {
+	if cs.opts().ReadOnly {
+		return fmt.Errorf("'%s' failed to begin bulk - instance is readonly", cs.String())
+	}
+	if batchSize < 1 {
+		batchSize = defaultBulkBatchSize
+	}
+	batch := &bulkBatch{size: batchSize, begin: func(ctx context.Context) (*sql.Tx, func(), error) {
+		if err := cs.cfg().Quota.check(ctx, cs.db); err != nil {
+			return nil, nil, fmt.Errorf("'%s' failed to create command - %w", cs.String(), err)
+		}
+		return beginBulkTx(ctx, cs.db, cs.beginWrite)
+	}}
+	if !cs.bulk.CompareAndSwap(nil, batch) {
+		return fmt.Errorf("'%s' failed to begin bulk - bulk is already active", cs.String())
+	}
+	return nil
+}
+
+// Write creates cmd within the active bulk, or on its own without one.
+func (cs *commandStoreSQLite) Write(ctx context.Context, cmd comby.Command) error {
+	return cs.Create(ctx, comby.CommandStoreCreateOptionWithCommand(cmd))
+}
+
+// EndBulk commits the open batch and returns to single transactions.
+func (cs *commandStoreSQLite) EndBulk(ctx context.Context) error {
+	batch := cs.bulk.Swap(nil)
+	if batch == nil {
+		return fmt.Errorf("'%s' failed to end bulk - bulk is not active", cs.String())
+	}
+	return batch.flush()
+}
+
+// SyncEventStore copies all events of src to dst like comby.SyncEventStore,
+// within bulk transactions if dst is a BulkWriter.
+func SyncEventStore(ctx context.Context, src, dst comby.EventStore, batchSize int) error {
+	bulk, ok := dst.(BulkWriter)
+	if !ok {
+		return comby.SyncEventStore(ctx, src, dst)
+	}
+	if err := bulk.BeginBulk(ctx, batchSize); err != nil {
+		return err
+	}
+	// events created so far are kept on failure, as without bulk
+	err := comby.SyncEventStore(ctx, src, dst)
+	if endErr := bulk.EndBulk(ctx); endErr != nil && err == nil {
+		err = endErr
+	}
+	return err
+}
+
+// SyncCommandStore copies all commands of src to dst like
+// comby.SyncCommandStore, within bulk transactions if dst is a BulkWriter.
+func SyncCommandStore(ctx context.Context, src, dst comby.CommandStore, batchSize int) error {
+	bulk, ok := dst.(BulkWriter)
+	if !ok {
+		return comby.SyncCommandStore(ctx, src, dst)
+	}
+	if err := bulk.BeginBulk(ctx, batchSize); err != nil {
+		return err
+	}
+	err := comby.SyncCommandStore(ctx, src, dst)
+	if endErr := bulk.EndBulk(ctx); endErr != nil && err == nil {
+		err = endErr
+	}
+	return err
+}
@@ -0,0 +1,107 @@
+package store_test
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	store "github.com/gradientzero/comby-store-sqlite"
+	"github.com/gradientzero/comby/v3"
+)
+
+func TestEventStoreBulk(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "bulk.db")
+	eventStore := store.NewEventStoreSQLite(path)
+	if err := eventStore.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer eventStore.Close(ctx)
+	reader := store.NewEventStoreSQLite(path)
+	if err := reader.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Close(ctx)
+
+	if err := eventStore.BeginBulk(ctx, 3); err != nil {
+		t.Fatal(err)
+	}
+	if err := eventStore.BeginBulk(ctx, 3); err == nil {
+		t.Fatal("expected second bulk to fail")
+	}
+	var evts []comby.Event
+	for i := int64(1); i <= 4; i++ {
+		evt := createTestEvent("tenant-1", "domain-1", i, i*100)
+		if err := eventStore.Write(ctx, evt); err != nil {
+			t.Fatal(err)
+		}
+		evts = append(evts, evt)
+	}
+	// a duplicate fails on its own, the batch continues
+	if err := eventStore.Create(ctx, comby.EventStoreCreateOptionWithEvent(evts[3])); err == nil {
+		t.Fatal("expected duplicate create to fail")
+	}
+
+	// the first batch of 3 is committed, the fourth event is pending
+	if total := reader.Total(ctx); total != 3 {
+		t.Fatalf("expected 3 committed events, got %d", total)
+	}
+	if err := eventStore.EndBulk(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if total := reader.Total(ctx); total != 4 {
+		t.Fatalf("expected 4 committed events, got %d", total)
+	}
+	if err := eventStore.EndBulk(ctx); err == nil {
+		t.Fatal("expected end of inactive bulk to fail")
+	}
+
+	// updates are possible again
+	evts[0].SetDomainEvtBytes([]byte("updated"))
+	if err := eventStore.Update(ctx, comby.EventStoreUpdateOptionWithEvent(evts[0])); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSyncStoresInBulk(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	srcEvents := store.NewEventStoreSQLite(filepath.Join(dir, "src.db"))
+	srcCommands := store.NewCommandStoreSQLite(filepath.Join(dir, "src.db"))
+	dstEvents := store.NewEventStoreSQLite(filepath.Join(dir, "dst.db"))
+	dstCommands := store.NewCommandStoreSQLite(filepath.Join(dir, "dst.db"))
+	for _, eventStore := range []store.EventStoreSQLite{srcEvents, dstEvents} {
+		if err := eventStore.Init(ctx); err != nil {
+			t.Fatal(err)
+		}
+		defer eventStore.Close(ctx)
+	}
+	for _, commandStore := range []store.CommandStoreSQLite{srcCommands, dstCommands} {
+		if err := commandStore.Init(ctx); err != nil {
+			t.Fatal(err)
+		}
+		defer commandStore.Close(ctx)
+	}
+
+	for i := int64(1); i <= 250; i++ {
+		if err := srcEvents.Create(ctx, comby.EventStoreCreateOptionWithEvent(createTestEvent("tenant-1", "domain-1", i, i))); err != nil {
+			t.Fatal(err)
+		}
+		if err := srcCommands.Create(ctx, comby.CommandStoreCreateOptionWithCommand(createTestCommand("tenant-1", "domain-1", i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := store.SyncEventStore(ctx, srcEvents, dstEvents, 100); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.SyncCommandStore(ctx, srcCommands, dstCommands, 100); err != nil {
+		t.Fatal(err)
+	}
+	if total := dstEvents.Total(ctx); total != 250 {
+		t.Fatalf("expected 250 synced events, got %d", total)
+	}
+	if total := dstCommands.Total(ctx); total != 250 {
+		t.Fatalf("expected 250 synced commands, got %d", total)
+	}
+}
@@ -0,0 +1,270 @@
+package store
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/gradientzero/comby-store-sqlite/internal"
+	"github.com/gradientzero/comby/v3"
+)
+
+// format version of tenant bundles
+const tenantBundleVersion = 1
+
+// identifies tenant bundles, written before the encrypted content
+var tenantBundleMagic = []byte("COMBY-SQLITE-TENANT\n")
+
+// ErrInvalidBundle is returned by ImportTenant for data which is no tenant
+// bundle or can not be decrypted with the given crypto service.
+var ErrInvalidBundle = errors.New("invalid tenant bundle")
+
+// TenantBundleReport is the result of ExportTenant and ImportTenant.
+type TenantBundleReport struct {
+	TenantUuid string
+	Events     int64
+	Commands   int64
+	Snapshots  int64
+	// events and commands with a uuid already known to the destination (import only)
+	Skipped int64
+}
+
+// bundleEntry is one line of a tenant bundle, exactly one field is set.
+type bundleEntry struct {
+	Header   *bundleHeader             `json:"header,omitempty"`
+	Event    *internal.Event           `json:"event,omitempty"`
+	Command  *exportCommand            `json:"command,omitempty"`
+	Snapshot *comby.SnapshotStoreModel `json:"snapshot,omitempty"`
+}
+
+type bundleHeader struct {
+	Version    int    `json:"version"`
+	TenantUuid string `json:"tenant_uuid"`
+	CreatedAt  int64  `json:"created_at"`
+}
+
+// ExportTenant writes all events, commands and snapshots of a tenant to w as
+// one bundle encrypted with bundleCrypto, e.g. to answer a data portability
+// request or to move the tenant to another deployment with ImportTenant.
+// Payloads are decrypted with the crypto services of the stores, so the
+// bundle only depends on bundleCrypto. The bundle is built in memory.
+// ExportOptions pseudonymize fields like in ExportEvents, snapshot data is
+// kept as it is.
+func (s *Stores) ExportTenant(ctx context.Context, tenantUuid string, w io.Writer, bundleCrypto *comby.CryptoService, opts ...ExportOption) (*TenantBundleReport, error) {
+	if len(tenantUuid) == 0 {
+		return nil, fmt.Errorf("'%s' failed to export tenant - tenant uuid is required", s.String())
+	}
+	if bundleCrypto == nil {
+		return nil, fmt.Errorf("'%s' failed to export tenant - crypto service is nil", s.String())
+	}
+	config, err := newExportConfig(opts...)
+	if err != nil {
+		return nil, fmt.Errorf("'%s' failed to export tenant - %w", s.String(), err)
+	}
+	bundleTenantUuid := tenantUuid
+	if config.Tenants {
+		bundleTenantUuid = config.pseudonym(tenantUuid)
+	}
+
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	enc := json.NewEncoder(zw)
+	report := &TenantBundleReport{TenantUuid: bundleTenantUuid}
+	header := &bundleHeader{Version: tenantBundleVersion, TenantUuid: bundleTenantUuid, CreatedAt: time.Now().UnixNano()}
+	if err := enc.Encode(bundleEntry{Header: header}); err != nil {
+		return nil, err
+	}
+
+	// one read transaction, so the parts of the bundle match up
+	err = runTx(ctx, s.db, func(tx *sql.Tx) error {
+		if es, ok := s.EventStore.(*eventStoreSQLite); ok {
+			query := fmt.Sprintf("SELECT %s FROM events WHERE tenant_uuid=? ORDER BY id ASC;", eventSelectColumns)
+			dbRecords, err := queryEventRecords(ctx, tx, query, []any{tenantUuid})
+			if err != nil {
+				return err
+			}
+			for _, dbRecord := range dbRecords {
+				if err := es.decodeDomainData(ctx, dbRecord); err != nil {
+					return err
+				}
+				if err := config.pseudonymizeEvent(dbRecord); err != nil {
+					return err
+				}
+				if err := enc.Encode(bundleEntry{Event: dbRecord}); err != nil {
+					return err
+				}
+				report.Events++
+			}
+		}
+		if cs, ok := s.CommandStore.(*commandStoreSQLite); ok {
+			query := fmt.Sprintf("SELECT %s, status, processed_at, error_text FROM commands WHERE tenant_uuid=? ORDER BY id ASC;", commandSelectColumns)
+			records, err := queryExportCommands(ctx, tx, query, tenantUuid)
+			if err != nil {
+				return err
+			}
+			for _, record := range records {
+				if err := cs.decodeDomainData(ctx, &record.Command); err != nil {
+					return err
+				}
+				if err := config.pseudonymizeCommand(&record.Command); err != nil {
+					return err
+				}
+				if err := enc.Encode(bundleEntry{Command: record}); err != nil {
+					return err
+				}
+				report.Commands++
+			}
+		}
+		if _, ok := s.SnapshotStore.(*snapshotStoreSQLite); ok {
+			query := `SELECT aggregate_uuid, COALESCE(tenant_uuid, ''), COALESCE(workspace_uuid, ''), domain, version, data, created_at
+				FROM snapshots WHERE tenant_uuid=? ORDER BY aggregate_uuid ASC;`
+			rows, err := tx.QueryContext(ctx, query, tenantUuid)
+			if err != nil {
+				return err
+			}
+			defer rows.Close()
+			for rows.Next() {
+				var model comby.SnapshotStoreModel
+				if err := rows.Scan(&model.AggregateUuid, &model.TenantUuid, &model.WorkspaceUuid, &model.Domain,
+					&model.Version, &model.Data, &model.CreatedAt); err != nil {
+					return err
+				}
+				if config.Tenants {
+					model.TenantUuid = bundleTenantUuid
+				}
+				if err := enc.Encode(bundleEntry{Snapshot: &model}); err != nil {
+					return err
+				}
+				report.Snapshots++
+			}
+			return rows.Err()
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("'%s' failed to export tenant - %w", s.String(), err)
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+
+	encrypted, err := bundleCrypto.Encrypt(buf.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("'%s' failed to export tenant - failed to encrypt bundle: %w", s.String(), err)
+	}
+	if _, err := w.Write(tenantBundleMagic); err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(encrypted); err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// ImportTenant reads a bundle written by ExportTenant and adds its records in
+// one transaction. Payloads are encrypted with the crypto services of the
+// stores. Known events and commands are skipped, snapshots are replaced.
+// Records of stores not opened are ignored.
+func (s *Stores) ImportTenant(ctx context.Context, r io.Reader, bundleCrypto *comby.CryptoService) (*TenantBundleReport, error) {
+	if bundleCrypto == nil {
+		return nil, fmt.Errorf("'%s' failed to import tenant - crypto service is nil", s.String())
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.HasPrefix(data, tenantBundleMagic) {
+		return nil, fmt.Errorf("'%s' failed to import tenant - %w", s.String(), ErrInvalidBundle)
+	}
+	plain, err := bundleCrypto.Decrypt(data[len(tenantBundleMagic):])
+	if err != nil {
+		return nil, fmt.Errorf("'%s' failed to import tenant - %w: %v", s.String(), ErrInvalidBundle, err)
+	}
+	zr, err := gzip.NewReader(bytes.NewReader(plain))
+	if err != nil {
+		return nil, fmt.Errorf("'%s' failed to import tenant - %w: %v", s.String(), ErrInvalidBundle, err)
+	}
+	dec := json.NewDecoder(bufio.NewReader(zr))
+
+	var header bundleEntry
+	if err := dec.Decode(&header); err != nil || header.Header == nil {
+		return nil, fmt.Errorf("'%s' failed to import tenant - %w: missing header", s.String(), ErrInvalidBundle)
+	}
+	if header.Header.Version != tenantBundleVersion {
+		return nil, fmt.Errorf("'%s' failed to import tenant - unsupported bundle version %d", s.String(), header.Header.Version)
+	}
+	report := &TenantBundleReport{TenantUuid: header.Header.TenantUuid}
+
+	done, err := s.beginWrite(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+	err = runTx(ctx, s.db, func(tx *sql.Tx) error {
+		es, _ := s.EventStore.(*eventStoreSQLite)
+		cs, _ := s.CommandStore.(*commandStoreSQLite)
+		ss, _ := s.SnapshotStore.(*snapshotStoreSQLite)
+		for {
+			var entry bundleEntry
+			if err := dec.Decode(&entry); err == io.EOF {
+				return nil
+			} else if err != nil {
+				return fmt.Errorf("%w: %v", ErrInvalidBundle, err)
+			}
+			switch {
+			case entry.Event != nil && es != nil:
+				var known int
+				if err := tx.QueryRowContext(ctx, "SELECT COUNT(*) FROM event_records WHERE uuid=?;", entry.Event.Uuid).Scan(&known); err != nil {
+					return err
+				}
+				if known > 0 {
+					report.Skipped++
+					continue
+				}
+				if es.cipher() != nil {
+					if err := es.encryptDomainData(ctx, entry.Event); err != nil {
+						return err
+					}
+				}
+				if err := insertEventRecord(ctx, tx, sql.NullInt64{}, entry.Event); err != nil {
+					return err
+				}
+				report.Events++
+			case entry.Command != nil && cs != nil:
+				var known int
+				if err := tx.QueryRowContext(ctx, "SELECT COUNT(*) FROM commands WHERE uuid=?;", entry.Command.Uuid).Scan(&known); err != nil {
+					return err
+				}
+				if known > 0 {
+					report.Skipped++
+					continue
+				}
+				if cs.cipher() != nil {
+					if err := cs.encryptDomainData(ctx, &entry.Command.Command); err != nil {
+						return err
+					}
+				}
+				if err := insertCommandRecord(ctx, tx, entry.Command); err != nil {
+					return err
+				}
+				report.Commands++
+			case entry.Snapshot != nil && ss != nil:
+				if err := ss.save(ctx, tx, entry.Snapshot); err != nil {
+					return err
+				}
+				report.Snapshots++
+			}
+		}
+	})
+	if err != nil {
+		return nil, fmt.Errorf("'%s' failed to import tenant - %w", s.String(), err)
+	}
+	return report, nil
+}
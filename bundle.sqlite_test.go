@@ -0,0 +1,110 @@
+package store_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	store "github.com/gradientzero/comby-store-sqlite"
+	"github.com/gradientzero/comby/v3"
+)
+
+func openTestStores(t *testing.T, path string, key string) *store.Stores {
+	t.Helper()
+	cryptoService, _ := comby.NewCryptoService([]byte(key))
+	stores, err := store.Open(path,
+		store.WithEventStore(),
+		store.WithCommandStore(),
+		store.WithSnapshotStore(),
+		store.WithCryptoService(cryptoService),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := stores.Init(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { stores.Close(context.Background()) })
+	return stores
+}
+
+func TestTenantBundle(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	src := openTestStores(t, filepath.Join(dir, "src.db"), "12345678901234567890123456789012")
+	dst := openTestStores(t, filepath.Join(dir, "dst.db"), "abcdefghijklmnopqrstuvwxyz123456")
+
+	var evts []comby.Event
+	for i, tenantUuid := range []string{"tenant-1", "tenant-2", "tenant-1"} {
+		evt := createTestEvent(tenantUuid, "domain-1", int64(i+1), int64(i+1)*100)
+		if err := src.EventStore.Create(ctx, comby.EventStoreCreateOptionWithEvent(evt)); err != nil {
+			t.Fatal(err)
+		}
+		cmd := createTestCommand(tenantUuid, "domain-1", int64(i+1)*100)
+		if err := src.CommandStore.Create(ctx, comby.CommandStoreCreateOptionWithCommand(cmd)); err != nil {
+			t.Fatal(err)
+		}
+		if err := src.SnapshotStore.Save(ctx, &comby.SnapshotStoreModel{
+			AggregateUuid: evt.GetAggregateUuid(),
+			TenantUuid:    tenantUuid,
+			Domain:        "domain-1",
+			Version:       evt.GetVersion(),
+			Data:          []byte("snapshot"),
+			CreatedAt:     evt.GetCreatedAt(),
+		}); err != nil {
+			t.Fatal(err)
+		}
+		evts = append(evts, evt)
+	}
+
+	bundleCrypto, _ := comby.NewCryptoService([]byte("bundlebundlebundlebundlebundle!!"))
+	var bundle bytes.Buffer
+	report, err := src.ExportTenant(ctx, "tenant-1", &bundle, bundleCrypto)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Events != 2 || report.Commands != 2 || report.Snapshots != 2 {
+		t.Fatalf("unexpected export report: %+v", report)
+	}
+	if bytes.Contains(bundle.Bytes(), []byte("test-data-1")) {
+		t.Fatal("expected bundle to be encrypted")
+	}
+
+	otherCrypto, _ := comby.NewCryptoService([]byte("otherotherotherotherotherother!!"))
+	if _, err := dst.ImportTenant(ctx, bytes.NewReader(bundle.Bytes()), otherCrypto); !errors.Is(err, store.ErrInvalidBundle) {
+		t.Fatalf("expected invalid bundle with wrong key, got %v", err)
+	}
+
+	report, err = dst.ImportTenant(ctx, bytes.NewReader(bundle.Bytes()), bundleCrypto)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.TenantUuid != "tenant-1" || report.Events != 2 || report.Commands != 2 || report.Snapshots != 2 || report.Skipped != 0 {
+		t.Fatalf("unexpected import report: %+v", report)
+	}
+	if total := dst.EventStore.Total(ctx); total != 2 {
+		t.Fatalf("expected 2 imported events, got %d", total)
+	}
+	// payloads are encrypted with the key of the destination
+	got, err := dst.EventStore.Get(ctx, comby.EventStoreGetOptionWithEventUuid(evts[2].GetEventUuid()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got.GetDomainEvtBytes()) != "test-data-3" {
+		t.Fatalf("unexpected imported payload: %q", got.GetDomainEvtBytes())
+	}
+	if snapshot, err := dst.SnapshotStore.GetLatest(ctx, evts[0].GetAggregateUuid()); err != nil || snapshot == nil {
+		t.Fatalf("expected imported snapshot, got %v (%v)", snapshot, err)
+	}
+
+	// importing again skips known records
+	report, err = dst.ImportTenant(ctx, bytes.NewReader(bundle.Bytes()), bundleCrypto)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Events != 0 || report.Commands != 0 || report.Skipped != 4 {
+		t.Fatalf("unexpected second import report: %+v", report)
+	}
+}
@@ -0,0 +1,178 @@
+package store
+
+import (
+	"bytes"
+	"container/list"
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/gradientzero/comby-store-sqlite/internal"
+	"github.com/gradientzero/comby/v3"
+)
+
+// cacheConfig sizes the optional result cache of the event store.
+type cacheConfig struct {
+	Size int
+	// 0 keeps entries until they are evicted or invalidated
+	TTL time.Duration
+}
+
+// EventStoreSQLiteWithCache caches the results of up to size Get and List
+// calls, e.g. for projections repeatedly fetching the same aggregates. All
+// entries are invalidated by writes through this store. Writes of other
+// processes to the same file are not noticed, use a ttl to bound staleness
+// in that case. Takes effect on Init.
+func EventStoreSQLiteWithCache(size int, ttl time.Duration) EventStoreSQLiteOption {
+	return func(c *eventStoreSQLiteConfig) { c.Cache = cacheConfig{Size: size, TTL: ttl} }
+}
+
+// CacheStats reports the effectiveness of the result cache.
+type CacheStats struct {
+	Hits    int64
+	Misses  int64
+	Entries int
+}
+
+// queryCache is a LRU cache of decoded records. Cached records are never
+// modified, each hit converts them into new events.
+type queryCache struct {
+	mu      sync.Mutex
+	size    int
+	ttl     time.Duration
+	entries map[string]*list.Element
+	order   *list.List
+	// incremented by purge, results queried before are not stored anymore
+	generation uint64
+	hits       int64
+	misses     int64
+}
+
+type cacheEntry struct {
+	key       string
+	dbRecords []*internal.Event
+	total     int64
+	expiresAt time.Time
+}
+
+func newQueryCache(config cacheConfig) *queryCache {
+	return &queryCache{
+		size:    config.Size,
+		ttl:     config.TTL,
+		entries: map[string]*list.Element{},
+		order:   list.New(),
+	}
+}
+
+// lookup returns a cached result or the generation to pass to store.
+func (c *queryCache) lookup(key string) ([]*internal.Event, int64, uint64, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, ok := c.entries[key]; ok {
+		entry := elem.Value.(*cacheEntry)
+		if c.ttl == 0 || time.Now().Before(entry.expiresAt) {
+			c.order.MoveToFront(elem)
+			c.hits++
+			return entry.dbRecords, entry.total, c.generation, true
+		}
+		c.order.Remove(elem)
+		delete(c.entries, key)
+	}
+	c.misses++
+	return nil, 0, c.generation, false
+}
+
+func (c *queryCache) store(generation uint64, key string, dbRecords []*internal.Event, total int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	// a write completed while querying, the result may be outdated
+	if generation != c.generation {
+		return
+	}
+	if elem, ok := c.entries[key]; ok {
+		c.order.Remove(elem)
+	}
+	c.entries[key] = c.order.PushFront(&cacheEntry{
+		key:       key,
+		dbRecords: dbRecords,
+		total:     total,
+		expiresAt: time.Now().Add(c.ttl),
+	})
+	for c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cacheEntry).key)
+	}
+}
+
+func (c *queryCache) purge() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.generation++
+	c.entries = map[string]*list.Element{}
+	c.order.Init()
+}
+
+func (c *queryCache) stats() CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return CacheStats{Hits: c.hits, Misses: c.misses, Entries: c.order.Len()}
+}
+
+func (es *eventStoreSQLite) CacheStats() CacheStats {
+	if c := es.cache.Load(); c != nil {
+		return c.stats()
+	}
+	return CacheStats{}
+}
+
+// invalidateCache drops all cached results, it is called after writes.
+func (es *eventStoreSQLite) invalidateCache() {
+	if c := es.cache.Load(); c != nil {
+		c.purge()
+	}
+}
+
+func (es *eventStoreSQLite) cachedGet(ctx context.Context, c *queryCache, eventUuid string) (comby.Event, error) {
+	key := "get:" + eventUuid
+	dbRecords, _, generation, ok := c.lookup(key)
+	if !ok {
+		dbRecord, err := es.getRecord(ctx, es.db, "events", eventUuid)
+		if err != nil || dbRecord == nil {
+			return nil, err
+		}
+		dbRecords = []*internal.Event{dbRecord}
+		c.store(generation, key, dbRecords, 1)
+	}
+	return cachedEvent(dbRecords[0])
+}
+
+func (es *eventStoreSQLite) cachedList(ctx context.Context, c *queryCache, listOpts comby.EventStoreListOptions, filter eventFilter) ([]comby.Event, int64, error) {
+	key := fmt.Sprintf("list:%+v:%+v", listOpts, filter)
+	dbRecords, total, generation, ok := c.lookup(key)
+	if !ok {
+		var err error
+		if dbRecords, total, err = es.listRecords(ctx, es.db, "events", listOpts, filter); err != nil {
+			return nil, 0, err
+		}
+		c.store(generation, key, dbRecords, total)
+	}
+	evts := make([]comby.Event, 0, len(dbRecords))
+	for _, dbRecord := range dbRecords {
+		evt, err := cachedEvent(dbRecord)
+		if err != nil {
+			return nil, 0, err
+		}
+		evts = append(evts, evt)
+	}
+	return evts, total, nil
+}
+
+// cachedEvent converts a cached record into an event with its own payload,
+// which callers may modify.
+func cachedEvent(dbRecord *internal.Event) (comby.Event, error) {
+	clone := *dbRecord
+	clone.DataBytes = bytes.Clone(dbRecord.DataBytes)
+	return internal.DbEventToBaseEvent(&clone)
+}
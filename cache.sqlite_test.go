@@ -0,0 +1,94 @@
+package store_test
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	store "github.com/gradientzero/comby-store-sqlite"
+	"github.com/gradientzero/comby/v3"
+)
+
+func TestEventStoreCache(t *testing.T) {
+	ctx := context.Background()
+	eventStore := store.NewEventStoreSQLite(filepath.Join(t.TempDir(), "eventStore-cache.db"))
+	eventStore.Configure(store.EventStoreSQLiteWithCache(2, 0))
+	if err := eventStore.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer eventStore.Close(ctx)
+
+	evt := createTestEvent("tenant-1", "domain-1", 1, 100)
+	if err := eventStore.Create(ctx, comby.EventStoreCreateOptionWithEvent(evt)); err != nil {
+		t.Fatal(err)
+	}
+	get := func() comby.Event {
+		t.Helper()
+		cached, err := eventStore.Get(ctx, comby.EventStoreGetOptionWithEventUuid(evt.GetEventUuid()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return cached
+	}
+
+	// hits return new events, changing them does not affect the cache
+	get().GetDomainEvtBytes()[0] = 'X'
+	get().SetDomainEvtBytes([]byte("changed"))
+	if cached := get(); string(cached.GetDomainEvtBytes()) != "test-data-1" {
+		t.Fatalf("cached event was modified: %s", cached.GetDomainEvtBytes())
+	}
+	if _, total, err := eventStore.List(ctx); err != nil || total != 1 {
+		t.Fatalf("unexpected list: %d %v", total, err)
+	}
+	if stats := eventStore.CacheStats(); stats.Hits != 2 || stats.Misses != 2 || stats.Entries != 2 {
+		t.Fatalf("unexpected stats: %+v", stats)
+	}
+
+	// writes invalidate
+	evt.SetDomainEvtBytes([]byte("updated"))
+	if err := eventStore.Update(ctx, comby.EventStoreUpdateOptionWithEvent(evt)); err != nil {
+		t.Fatal(err)
+	}
+	if cached := get(); string(cached.GetDomainEvtBytes()) != "updated" {
+		t.Fatalf("expected updated event, got %s", cached.GetDomainEvtBytes())
+	}
+	if err := eventStore.Create(ctx, comby.EventStoreCreateOptionWithEvent(createTestEvent("tenant-1", "domain-1", 2, 200))); err != nil {
+		t.Fatal(err)
+	}
+	if _, total, err := eventStore.List(ctx); err != nil || total != 2 {
+		t.Fatalf("expected invalidated list: %d %v", total, err)
+	}
+
+	// least recently used entries are evicted
+	eventStore.List(ctx, func(opt *comby.EventStoreListOptions) (*comby.EventStoreListOptions, error) {
+		opt.Limit = 1
+		return opt, nil
+	})
+	if stats := eventStore.CacheStats(); stats.Entries != 2 {
+		t.Fatalf("expected 2 entries, got %+v", stats)
+	}
+}
+
+func TestEventStoreCacheTTL(t *testing.T) {
+	ctx := context.Background()
+	eventStore := store.NewEventStoreSQLite(filepath.Join(t.TempDir(), "eventStore-cache-ttl.db"))
+	eventStore.Configure(store.EventStoreSQLiteWithCache(10, 50*time.Millisecond))
+	if err := eventStore.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer eventStore.Close(ctx)
+
+	for i := 0; i < 2; i++ {
+		if _, _, err := eventStore.List(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	time.Sleep(100 * time.Millisecond)
+	if _, _, err := eventStore.List(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if stats := eventStore.CacheStats(); stats.Hits != 1 || stats.Misses != 2 {
+		t.Fatalf("expected expired entry to miss: %+v", stats)
+	}
+}
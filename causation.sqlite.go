@@ -0,0 +1,146 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/gradientzero/comby-store-sqlite/internal"
+	"github.com/gradientzero/comby/v3"
+)
+
+// CausationEventAttribute is the attribute of the create options or of the
+// request context of a command holding the uuid of the event which triggered
+// it, e.g. set by a process manager reacting to events. Together with the
+// command uuid of events it links commands and events into causal chains.
+const CausationEventAttribute = "causationEventUuid"
+
+// CommandStoreCreateOptionCausedBy stores the command as triggered by the
+// event. It takes precedence over the attribute of the request context.
+func CommandStoreCreateOptionCausedBy(eventUuid string) comby.CommandStoreCreateOption {
+	return func(opt *comby.CommandStoreCreateOptions) (*comby.CommandStoreCreateOptions, error) {
+		if opt.Attributes == nil {
+			opt.Attributes = comby.NewAttributes()
+		}
+		opt.Attributes.Set(CausationEventAttribute, eventUuid)
+		return opt, nil
+	}
+}
+
+func (cs *commandStoreSQLite) ListCommandsByEvent(ctx context.Context, eventUuid string) ([]comby.Command, error) {
+	if len(eventUuid) < 1 {
+		return nil, fmt.Errorf("'%s' failed to list commands - event uuid '%s' is invalid", cs.String(), eventUuid)
+	}
+	whereList, args := tenantCondition(cs.cfg().Tenant, []string{"causation_event_uuid=?"}, []any{eventUuid})
+	query := fmt.Sprintf("SELECT %s FROM commands WHERE %s ORDER BY id ASC;", commandSelectColumns, strings.Join(whereList, " AND "))
+	rows, err := cs.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("'%s' failed to list commands - %w", cs.String(), classifyError(err))
+	}
+	defer rows.Close()
+
+	var dbRecords []*internal.Command
+	for rows.Next() {
+		var dbRecord internal.Command
+		if err := scanCommand(rows, &dbRecord); err != nil {
+			return nil, classifyError(err)
+		}
+		if err := cs.decodeDomainData(ctx, &dbRecord); err != nil {
+			return nil, err
+		}
+		dbRecords = append(dbRecords, &dbRecord)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, classifyError(err)
+	}
+	cmds, err := internal.DbCommandsToBaseCommands(dbRecords)
+	if err != nil {
+		return nil, err
+	}
+	for _, cmd := range cmds {
+		if err := cs.authorizeCommand(ctx, OperationGet, cmd); err != nil {
+			return nil, err
+		}
+	}
+	return cmds, nil
+}
+
+// CausalChain is a command, the events it produced and, if requested, the
+// commands those events triggered, each again with its causal chain.
+type CausalChain struct {
+	Command comby.Command
+	// in the order they were stored
+	Events []CausalEvent
+}
+
+// CausalEvent is an event of a causal chain and the commands it triggered.
+type CausalEvent struct {
+	Event     comby.Event
+	Triggered []*CausalChain
+}
+
+// CausalChainOption configures GetCausalChain.
+type CausalChainOption func(*causalChainConfig)
+
+type causalChainConfig struct {
+	// levels of triggered commands to follow, -1 follows all
+	Depth int
+}
+
+// CausalChainWithTriggeredCommands follows the commands triggered by the
+// events of the chain, see CausationEventAttribute, up to maxDepth levels.
+// A negative maxDepth follows all of them.
+func CausalChainWithTriggeredCommands(maxDepth int) CausalChainOption {
+	return func(c *causalChainConfig) { c.Depth = maxDepth }
+}
+
+// GetCausalChain returns the command and all events it produced, which
+// reconstructs a business flow for debugging. Commands triggered by these
+// events are only followed with CausalChainWithTriggeredCommands; a command
+// reached twice, e.g. through a cycle, is only expanded once. It returns nil
+// if the command does not exist and requires the event and command store.
+func (s *Stores) GetCausalChain(ctx context.Context, commandUuid string, opts ...CausalChainOption) (*CausalChain, error) {
+	if s.EventStore == nil || s.CommandStore == nil {
+		return nil, fmt.Errorf("'%s' failed to get causal chain - event and command store are required", s.String())
+	}
+	config := causalChainConfig{}
+	for _, opt := range opts {
+		opt(&config)
+	}
+	cmd, err := s.CommandStore.Get(ctx, comby.CommandStoreGetOptionWithCommandUuid(commandUuid))
+	if err != nil || cmd == nil {
+		return nil, err
+	}
+	chain := &CausalChain{Command: cmd}
+	expanded := map[string]bool{commandUuid: true}
+	return chain, s.expandCausalChain(ctx, chain, config.Depth, expanded)
+}
+
+func (s *Stores) expandCausalChain(ctx context.Context, chain *CausalChain, depth int, expanded map[string]bool) error {
+	evts, err := s.EventStore.ListEventsByCommand(ctx, chain.Command.GetCommandUuid())
+	if err != nil {
+		return err
+	}
+	for _, evt := range evts {
+		causal := CausalEvent{Event: evt}
+		if depth != 0 {
+			cmds, err := s.CommandStore.ListCommandsByEvent(ctx, evt.GetEventUuid())
+			if err != nil {
+				return err
+			}
+			for _, cmd := range cmds {
+				if expanded[cmd.GetCommandUuid()] {
+					continue
+				}
+				expanded[cmd.GetCommandUuid()] = true
+				triggered := &CausalChain{Command: cmd}
+				if err := s.expandCausalChain(ctx, triggered, depth-1, expanded); err != nil {
+					return err
+				}
+				causal.Triggered = append(causal.Triggered, triggered)
+			}
+		}
+		chain.Events = append(chain.Events, causal)
+	}
+	return nil
+}
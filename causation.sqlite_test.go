@@ -0,0 +1,88 @@
+package store_test
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	store "github.com/gradientzero/comby-store-sqlite"
+	"github.com/gradientzero/comby/v3"
+)
+
+func TestStoresGetCausalChain(t *testing.T) {
+	ctx := context.Background()
+	stores, err := store.Open(filepath.Join(t.TempDir(), "store.db"), store.WithEventStore(), store.WithCommandStore())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := stores.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer stores.Close(ctx)
+
+	createCommand := func(createdAt int64, opts ...comby.CommandStoreCreateOption) comby.Command {
+		cmd := createTestCommand("tenant-1", "orders", createdAt)
+		opts = append(opts, comby.CommandStoreCreateOptionWithCommand(cmd))
+		if err := stores.CommandStore.Create(ctx, opts...); err != nil {
+			t.Fatal(err)
+		}
+		return cmd
+	}
+	createEvent := func(cmd comby.Command, version int64) comby.Event {
+		evt := createTestEvent("tenant-1", "orders", version, version)
+		evt.SetCommandUuid(cmd.GetCommandUuid())
+		if err := stores.EventStore.Create(ctx, comby.EventStoreCreateOptionWithEvent(evt)); err != nil {
+			t.Fatal(err)
+		}
+		return evt
+	}
+
+	// place order -> order placed -> reserve stock -> stock reserved -> ship
+	//             -> payment requested -> charge (caused via request context)
+	placeOrder := createCommand(1)
+	orderPlaced := createEvent(placeOrder, 1)
+	paymentRequested := createEvent(placeOrder, 2)
+	reserveStock := createCommand(2, store.CommandStoreCreateOptionCausedBy(orderPlaced.GetEventUuid()))
+	stockReserved := createEvent(reserveStock, 3)
+	charge := createTestCommand("tenant-1", "billing", 3)
+	reqCtx := &comby.RequestContext{Attributes: comby.NewAttributes()}
+	reqCtx.Attributes.Set(store.CausationEventAttribute, paymentRequested.GetEventUuid())
+	charge.SetReqCtx(reqCtx)
+	if err := stores.CommandStore.Create(ctx, comby.CommandStoreCreateOptionWithCommand(charge)); err != nil {
+		t.Fatal(err)
+	}
+	ship := createCommand(4, store.CommandStoreCreateOptionCausedBy(stockReserved.GetEventUuid()))
+
+	chain, err := stores.GetCausalChain(ctx, placeOrder.GetCommandUuid())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if chain.Command.GetCommandUuid() != placeOrder.GetCommandUuid() || len(chain.Events) != 2 || len(chain.Events[0].Triggered) != 0 {
+		t.Fatalf("expected the command and its events only, got %+v", chain)
+	}
+
+	chain, err = stores.GetCausalChain(ctx, placeOrder.GetCommandUuid(), store.CausalChainWithTriggeredCommands(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	reserve := chain.Events[0].Triggered
+	if len(reserve) != 1 || reserve[0].Command.GetCommandUuid() != reserveStock.GetCommandUuid() || len(reserve[0].Events) != 1 || len(reserve[0].Events[0].Triggered) != 0 {
+		t.Fatalf("expected one level of triggered commands, got %+v", chain.Events[0])
+	}
+	if triggered := chain.Events[1].Triggered; len(triggered) != 1 || triggered[0].Command.GetCommandUuid() != charge.GetCommandUuid() {
+		t.Fatalf("expected the charge command, got %+v", chain.Events[1])
+	}
+
+	chain, err = stores.GetCausalChain(ctx, placeOrder.GetCommandUuid(), store.CausalChainWithTriggeredCommands(-1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	shipping := chain.Events[0].Triggered[0].Events[0].Triggered
+	if len(shipping) != 1 || shipping[0].Command.GetCommandUuid() != ship.GetCommandUuid() || len(shipping[0].Events) != 0 {
+		t.Fatalf("expected the ship command, got %+v", shipping)
+	}
+
+	if chain, err := stores.GetCausalChain(ctx, "unknown"); err != nil || chain != nil {
+		t.Fatalf("expected no chain, got %v %v", chain, err)
+	}
+}
@@ -0,0 +1,165 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/gradientzero/comby/v3"
+)
+
+// CDCSink receives the events published by a CDCBridge, e.g. a NATS, Kafka
+// or webhook producer implemented by the application. Events may be delivered
+// more than once after failures, so consumers should deduplicate by event uuid.
+type CDCSink interface {
+	Publish(ctx context.Context, seq int64, evt comby.Event) error
+}
+
+type CDCOption func(*CDCBridge)
+
+// CDCWithPollInterval sets how often Run looks for new events.
+func CDCWithPollInterval(interval time.Duration) CDCOption {
+	return func(b *CDCBridge) { b.pollInterval = interval }
+}
+
+// CDCWithCommitInterval stores the offset after every n published events
+// (and always when no more events are pending).
+func CDCWithCommitInterval(n int) CDCOption {
+	return func(b *CDCBridge) { b.commitInterval = n }
+}
+
+// CDCWithReplayOptions filters the published events, e.g. by tenant or domain.
+func CDCWithReplayOptions(opts ...ReplayOption) CDCOption {
+	return func(b *CDCBridge) { b.replayOpts = append(b.replayOpts, opts...) }
+}
+
+// CDCBridge publishes new events of an event store to a CDCSink with
+// at-least-once delivery. The sequence of the last published event is stored
+// per bridge name in the cdc_offsets table of the event store.
+type CDCBridge struct {
+	es             *eventStoreSQLite
+	name           string
+	sink           CDCSink
+	pollInterval   time.Duration
+	commitInterval int
+	replayOpts     []ReplayOption
+}
+
+func NewCDCBridge(eventStore comby.EventStore, name string, sink CDCSink, opts ...CDCOption) (*CDCBridge, error) {
+	es, ok := eventStore.(*eventStoreSQLite)
+	if !ok {
+		return nil, fmt.Errorf("cdc bridge requires a sqlite event store")
+	}
+	if len(name) == 0 {
+		return nil, fmt.Errorf("'%s' failed to create cdc bridge - name is required", es.String())
+	}
+	if sink == nil {
+		return nil, fmt.Errorf("'%s' failed to create cdc bridge - sink is nil", es.String())
+	}
+	b := &CDCBridge{
+		es:             es,
+		name:           name,
+		sink:           sink,
+		pollInterval:   time.Second,
+		commitInterval: 100,
+	}
+	for _, opt := range opts {
+		opt(b)
+	}
+	if b.pollInterval <= 0 || b.commitInterval < 1 {
+		return nil, fmt.Errorf("'%s' failed to create cdc bridge - invalid poll or commit interval", es.String())
+	}
+	return b, nil
+}
+
+var cdcTables = []strictTable{
+	{
+		name: "cdc_offsets",
+		columns: `name TEXT NOT NULL PRIMARY KEY,
+		seq INTEGER NOT NULL,
+		updated_at INTEGER NOT NULL`,
+		copyColumns: `name, seq, updated_at`,
+	},
+}
+
+// Init creates the offsets table. The event store must be initialized before.
+func (b *CDCBridge) Init(ctx context.Context) error {
+	if b.es.db == nil {
+		return fmt.Errorf("'%s' failed to init cdc bridge - event store is not initialized", b.es.String())
+	}
+	return migrateTx(ctx, b.es.db, func(tx *sql.Tx) error {
+		return migrateStrictTables(ctx, tx, loggerOrDiscard(b.es.cfg().Logger), cdcTables...)
+	})
+}
+
+// Offset returns the sequence of the last published event.
+func (b *CDCBridge) Offset(ctx context.Context) (int64, error) {
+	var seq int64
+	err := b.es.db.QueryRowContext(ctx, "SELECT seq FROM cdc_offsets WHERE name=?;", b.name).Scan(&seq)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	return seq, err
+}
+
+func (b *CDCBridge) commit(ctx context.Context, seq int64) error {
+	// the offset is bookkeeping of the bridge, it bypasses the write limits
+	b.es.writeMu.Lock()
+	defer b.es.writeMu.Unlock()
+	query := `INSERT INTO cdc_offsets (name, seq, updated_at) VALUES (?, ?, ?)
+		ON CONFLICT(name) DO UPDATE SET seq=excluded.seq, updated_at=excluded.updated_at;`
+	_, err := b.es.db.ExecContext(ctx, query, b.name, seq, time.Now().UnixNano())
+	return err
+}
+
+// Poll publishes all events after the stored offset and returns their number.
+// If the sink fails, the offset of the last published event is kept and the
+// failed event is published again by the next call.
+func (b *CDCBridge) Poll(ctx context.Context) (int64, error) {
+	offset, err := b.Offset(ctx)
+	if err != nil {
+		return 0, err
+	}
+	committed := offset
+	var published int64
+	lastSeq, err := b.es.Replay(ctx, func(ctx context.Context, seq int64, evt comby.Event) error {
+		if err := b.sink.Publish(ctx, seq, evt); err != nil {
+			return fmt.Errorf("'%s' failed to publish event '%s' - %w", b.es.String(), evt.GetEventUuid(), err)
+		}
+		published++
+		if published%int64(b.commitInterval) == 0 {
+			if err := b.commit(ctx, seq); err != nil {
+				return err
+			}
+			committed = seq
+		}
+		return nil
+	}, append(b.replayOpts, ReplayFromSequence(offset))...)
+
+	// lastSeq is the last event handled successfully
+	if lastSeq != committed {
+		if commitErr := b.commit(ctx, lastSeq); commitErr != nil && err == nil {
+			err = commitErr
+		}
+	}
+	return published, err
+}
+
+// Run polls until ctx is done. Failures are logged and retried after the
+// poll interval.
+func (b *CDCBridge) Run(ctx context.Context) error {
+	logger := loggerOrDiscard(b.es.cfg().Logger)
+	ticker := time.NewTicker(b.pollInterval)
+	defer ticker.Stop()
+	for {
+		if _, err := b.Poll(ctx); err != nil && ctx.Err() == nil {
+			logger.ErrorContext(ctx, "cdc bridge failed", "name", b.name, "error", err)
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package store_test
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	store "github.com/gradientzero/comby-store-sqlite"
+	"github.com/gradientzero/comby/v3"
+)
+
+type testSink struct {
+	mu        sync.Mutex
+	versions  []int64
+	failAfter int
+}
+
+func (s *testSink) Publish(ctx context.Context, seq int64, evt comby.Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.failAfter >= 0 && len(s.versions) >= s.failAfter {
+		return errors.New("sink unavailable")
+	}
+	s.versions = append(s.versions, evt.GetVersion())
+	return nil
+}
+
+func (s *testSink) published() []int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]int64(nil), s.versions...)
+}
+
+func TestCDCBridge(t *testing.T) {
+	ctx := context.Background()
+	eventStore := store.NewEventStoreSQLite(filepath.Join(t.TempDir(), "eventStore-cdc.db"))
+	if err := eventStore.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer eventStore.Close(ctx)
+	for i := int64(1); i <= 5; i++ {
+		evt := createTestEvent("tenant-1", "domain-1", i, i*100)
+		if err := eventStore.Create(ctx, comby.EventStoreCreateOptionWithEvent(evt)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// the sink fails after three events, the offset stays at the third
+	sink := &testSink{failAfter: 3}
+	bridge, err := store.NewCDCBridge(eventStore, "bus", sink, store.CDCWithCommitInterval(2), store.CDCWithPollInterval(10*time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := bridge.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := bridge.Poll(ctx); err == nil || n != 3 {
+		t.Fatalf("expected 3 events and a sink error, got %d, %v", n, err)
+	}
+	if offset, _ := bridge.Offset(ctx); offset != 3 {
+		t.Fatalf("expected offset 3, got %d", offset)
+	}
+
+	// the sink recovers, Run continues after the offset
+	sink.mu.Lock()
+	sink.failAfter = -1
+	sink.mu.Unlock()
+	runCtx, cancel := context.WithCancel(ctx)
+	done := make(chan error)
+	go func() { done <- bridge.Run(runCtx) }()
+	evt := createTestEvent("tenant-1", "domain-1", 6, 600)
+	if err := eventStore.Create(ctx, comby.EventStoreCreateOptionWithEvent(evt)); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for len(sink.published()) < 6 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	published := sink.published()
+	for i, version := range []int64{1, 2, 3, 4, 5, 6} {
+		if i >= len(published) || published[i] != version {
+			t.Fatalf("expected versions 1..6, got %v", published)
+		}
+	}
+	if offset, _ := bridge.Offset(ctx); offset != 6 {
+		t.Fatalf("expected offset 6, got %d", offset)
+	}
+}
@@ -0,0 +1,153 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CheckpointMode is the mode of PRAGMA wal_checkpoint.
+type CheckpointMode string
+
+const (
+	// copies as many frames as possible without waiting for readers or writers
+	CheckpointPassive CheckpointMode = "PASSIVE"
+	// waits for writers and copies all frames
+	CheckpointFull CheckpointMode = "FULL"
+	// like FULL and waits for readers, so the next writer restarts the WAL
+	CheckpointRestart CheckpointMode = "RESTART"
+	// like RESTART and truncates the WAL file to zero bytes
+	CheckpointTruncate CheckpointMode = "TRUNCATE"
+)
+
+// CheckpointReport is the result of Checkpoint.
+type CheckpointReport struct {
+	Mode CheckpointMode
+	// frames in the WAL and the number of them written back to the database
+	WalPages          int64
+	CheckpointedPages int64
+	// size of the WAL file in bytes
+	WalSizeBefore int64
+	WalSizeAfter  int64
+	// the checkpoint could not complete because of concurrent readers or writers
+	Busy     bool
+	Duration time.Duration
+}
+
+// checkpointer configures the background checkpoints of a store.
+type checkpointer struct {
+	// how often the WAL size is checked, 0 disables the checkpointer
+	Interval time.Duration
+	// WAL size in bytes from which on a checkpoint runs
+	MaxWalSize int64
+	Mode       CheckpointMode
+}
+
+// EventStoreSQLiteWithCheckpointer checks the size of the WAL every interval
+// and runs a checkpoint in mode once it exceeds maxWalSize bytes, e.g. to keep
+// the WAL small while long readers prevent SQLite's automatic checkpoints.
+func EventStoreSQLiteWithCheckpointer(interval time.Duration, maxWalSize int64, mode CheckpointMode) EventStoreSQLiteOption {
+	return func(c *eventStoreSQLiteConfig) {
+		c.Checkpointer = checkpointer{Interval: interval, MaxWalSize: maxWalSize, Mode: mode}
+	}
+}
+
+// CommandStoreSQLiteWithCheckpointer runs background checkpoints, see EventStoreSQLiteWithCheckpointer.
+func CommandStoreSQLiteWithCheckpointer(interval time.Duration, maxWalSize int64, mode CheckpointMode) CommandStoreSQLiteOption {
+	return func(c *commandStoreSQLiteConfig) {
+		c.Checkpointer = checkpointer{Interval: interval, MaxWalSize: maxWalSize, Mode: mode}
+	}
+}
+
+// walSize returns the size of the WAL file of the database at path, 0 if
+// there is none (e.g. in-memory databases).
+func walSize(path string) int64 {
+	path, _, _ = strings.Cut(strings.TrimPrefix(path, "file:"), "?")
+	info, err := os.Stat(path + "-wal")
+	if err != nil {
+		return 0
+	}
+	return info.Size()
+}
+
+// checkpoint runs a checkpoint in mode. Writes of this process wait meanwhile,
+// so FULL and stronger modes are not blocked by them.
+func checkpoint(ctx context.Context, db *sql.DB, writeMu *sync.Mutex, path string, mode CheckpointMode) (*CheckpointReport, error) {
+	switch mode {
+	case CheckpointPassive, CheckpointFull, CheckpointRestart, CheckpointTruncate:
+	default:
+		return nil, fmt.Errorf("unknown checkpoint mode '%s'", mode)
+	}
+	writeMu.Lock()
+	defer writeMu.Unlock()
+
+	start := time.Now()
+	report := &CheckpointReport{Mode: mode, WalSizeBefore: walSize(path)}
+	var busy int
+	query := fmt.Sprintf("PRAGMA wal_checkpoint(%s);", mode)
+	if err := db.QueryRowContext(ctx, query).Scan(&busy, &report.WalPages, &report.CheckpointedPages); err != nil {
+		return nil, classifyError(err)
+	}
+	report.Busy = busy != 0
+	report.WalSizeAfter = walSize(path)
+	report.Duration = time.Since(start)
+	return report, nil
+}
+
+// start (re)starts the background checkpoints of fn.
+func (c checkpointer) start(path string, logger *slog.Logger, loop *maintenanceLoop, fn func(ctx context.Context, mode CheckpointMode) (*CheckpointReport, error)) *maintenanceLoop {
+	loop.stop()
+	if c.Interval <= 0 {
+		return nil
+	}
+	mode := c.Mode
+	if len(mode) == 0 {
+		mode = CheckpointPassive
+	}
+	return startMaintenance(c.Interval, logger, "checkpoint", func(ctx context.Context) error {
+		if walSize(path) < c.MaxWalSize {
+			return nil
+		}
+		report, err := fn(ctx, mode)
+		if err != nil {
+			return err
+		}
+		logger.DebugContext(ctx, "checkpoint", "mode", report.Mode, "checkpointedPages", report.CheckpointedPages,
+			"walSizeBefore", report.WalSizeBefore, "walSizeAfter", report.WalSizeAfter, "busy", report.Busy)
+		return nil
+	})
+}
+
+// Checkpoint writes the WAL back into the database file and reports the pages
+// and the WAL size before and after.
+func (es *eventStoreSQLite) Checkpoint(ctx context.Context, mode CheckpointMode) (*CheckpointReport, error) {
+	report, err := checkpoint(ctx, es.db, es.writeMu, es.path, mode)
+	if err != nil {
+		return nil, fmt.Errorf("'%s' failed to checkpoint - %w", es.String(), err)
+	}
+	return report, nil
+}
+
+func (es *eventStoreSQLite) initCheckpointer() {
+	config := es.cfg()
+	es.checkpointer = config.Checkpointer.start(es.path, loggerOrDiscard(config.Logger), es.checkpointer, es.Checkpoint)
+}
+
+// Checkpoint writes the WAL back into the database file, see the event store.
+func (cs *commandStoreSQLite) Checkpoint(ctx context.Context, mode CheckpointMode) (*CheckpointReport, error) {
+	report, err := checkpoint(ctx, cs.db, cs.writeMu, cs.path, mode)
+	if err != nil {
+		return nil, fmt.Errorf("'%s' failed to checkpoint - %w", cs.String(), err)
+	}
+	return report, nil
+}
+
+func (cs *commandStoreSQLite) initCheckpointer() {
+	config := cs.cfg()
+	cs.checkpointer = config.Checkpointer.start(cs.path, loggerOrDiscard(config.Logger), cs.checkpointer, cs.Checkpoint)
+}
@@ -0,0 +1,69 @@
+package store_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	store "github.com/gradientzero/comby-store-sqlite"
+	"github.com/gradientzero/comby/v3"
+)
+
+func TestEventStoreCheckpoint(t *testing.T) {
+	ctx := context.Background()
+	eventStore := store.NewEventStoreSQLite(filepath.Join(t.TempDir(), "eventStore-checkpoint.db"))
+	if err := eventStore.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer eventStore.Close(ctx)
+	for i := int64(1); i <= 10; i++ {
+		if err := eventStore.Create(ctx, comby.EventStoreCreateOptionWithEvent(createTestEvent("tenant-1", "domain-1", i, i*100))); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	report, err := eventStore.Checkpoint(ctx, store.CheckpointTruncate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Busy || report.WalSizeBefore == 0 || report.WalSizeAfter != 0 {
+		t.Fatalf("expected a truncated WAL, got %+v", report)
+	}
+	if _, err := eventStore.Checkpoint(ctx, "SOMETIMES"); err == nil {
+		t.Fatal("expected an error for an unknown mode")
+	}
+}
+
+func TestCommandStoreCheckpointer(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "commandStore-checkpointer.db")
+	commandStore := store.NewCommandStoreSQLite(path)
+	commandStore.Configure(store.CommandStoreSQLiteWithCheckpointer(10*time.Millisecond, 1, store.CheckpointTruncate))
+	if err := commandStore.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer commandStore.Close(ctx)
+	for i := int64(1); i <= 10; i++ {
+		if err := commandStore.Create(ctx, comby.CommandStoreCreateOptionWithCommand(createTestCommand("tenant-1", "domain-1", i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// the WAL exceeds the threshold and is truncated in the background
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		info, err := os.Stat(path + "-wal")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Size() == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected the WAL to be truncated, has %d bytes", info.Size())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
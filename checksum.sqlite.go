@@ -0,0 +1,153 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/gradientzero/comby-store-sqlite/internal"
+)
+
+// supported payload checksum algorithms
+const (
+	ChecksumSHA256 = "sha256"
+	ChecksumCRC32  = "crc32"
+)
+
+// ErrChecksumMismatch is returned when a payload does not match its stored checksum.
+var ErrChecksumMismatch = errors.New("checksum mismatch")
+
+// ChecksumReport is the result of VerifyAll.
+type ChecksumReport struct {
+	NumChecked int64
+	// records written before checksums were introduced
+	NumMissing int64
+	// uuids of records failing decryption or checksum verification
+	Corrupted []string
+}
+
+// number of records loaded per query while verifying
+const verifyBatchSize = 1000
+
+// verifyChecksum compares the decrypted payload of a record with its stored checksum.
+// Records written before checksums were introduced pass.
+func verifyChecksum(store, kind, uuid, checksum string, dataBytes []byte) error {
+	if len(checksum) == 0 {
+		return nil
+	}
+	ok, err := internal.VerifyChecksum(checksum, dataBytes)
+	if err != nil {
+		return fmt.Errorf("'%s' failed to verify %s '%s': %w", store, kind, uuid, err)
+	}
+	if !ok {
+		return fmt.Errorf("'%s' failed to verify %s '%s': %w", store, kind, uuid, ErrChecksumMismatch)
+	}
+	return nil
+}
+
+// verifiable is a record scanned by verifyAll
+type verifiable struct {
+	id       int64
+	uuid     string
+	checksum string
+	// decrypts the payload and verifies it against the checksum
+	verify func() error
+}
+
+// verifyAll pages through the records selected by query, which is called with
+// the last seen id, and verifies each of them.
+func verifyAll(ctx context.Context, db *sql.DB, query string, scan func(row rowScanner) (verifiable, error)) (*ChecksumReport, error) {
+	report := &ChecksumReport{}
+	var lastId int64
+	for {
+		rows, err := db.QueryContext(ctx, query, lastId)
+		if err != nil {
+			return nil, err
+		}
+		var records []verifiable
+		for rows.Next() {
+			record, err := scan(rows)
+			if err != nil {
+				rows.Close()
+				return nil, err
+			}
+			records = append(records, record)
+		}
+		if err := rows.Close(); err != nil {
+			return nil, err
+		}
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+		if len(records) == 0 {
+			return report, nil
+		}
+
+		for _, record := range records {
+			lastId = record.id
+			report.NumChecked++
+			if len(record.checksum) == 0 {
+				report.NumMissing++
+				continue
+			}
+			if err := record.verify(); err != nil {
+				report.Corrupted = append(report.Corrupted, record.uuid)
+			}
+		}
+	}
+}
+
+func (es *eventStoreSQLite) verifyChecksum(dbRecord *internal.Event) error {
+	return verifyChecksum(es.String(), "event", dbRecord.Uuid, dbRecord.Checksum, dbRecord.DataBytes)
+}
+
+func (es *eventStoreSQLite) VerifyAll(ctx context.Context) (*ChecksumReport, error) {
+	query := fmt.Sprintf("SELECT %s FROM events WHERE id>? ORDER BY id ASC LIMIT %d;", eventSelectColumns, verifyBatchSize)
+	return verifyAll(ctx, es.db, query, func(row rowScanner) (verifiable, error) {
+		dbRecord := &internal.Event{}
+		if err := scanEvent(row, dbRecord); err != nil {
+			return verifiable{}, err
+		}
+		return verifiable{
+			id:       dbRecord.ID.Int64,
+			uuid:     dbRecord.Uuid,
+			checksum: dbRecord.Checksum,
+			verify: func() error {
+				if isEncrypted(dbRecord.IsEncrypted, es.cipher() != nil) {
+					if err := es.decryptDomainData(ctx, dbRecord); err != nil {
+						return err
+					}
+				}
+				return es.verifyChecksum(dbRecord)
+			},
+		}, nil
+	})
+}
+
+func (cs *commandStoreSQLite) verifyChecksum(dbRecord *internal.Command) error {
+	return verifyChecksum(cs.String(), "command", dbRecord.Uuid, dbRecord.Checksum, dbRecord.DataBytes)
+}
+
+func (cs *commandStoreSQLite) VerifyAll(ctx context.Context) (*ChecksumReport, error) {
+	query := fmt.Sprintf("SELECT %s FROM commands WHERE id>? ORDER BY id ASC LIMIT %d;", commandSelectColumns, verifyBatchSize)
+	return verifyAll(ctx, cs.db, query, func(row rowScanner) (verifiable, error) {
+		dbRecord := &internal.Command{}
+		if err := scanCommand(row, dbRecord); err != nil {
+			return verifiable{}, err
+		}
+		return verifiable{
+			id:       dbRecord.ID.Int64,
+			uuid:     dbRecord.Uuid,
+			checksum: dbRecord.Checksum,
+			verify: func() error {
+				if isEncrypted(dbRecord.IsEncrypted, cs.cipher() != nil) {
+					if err := cs.decryptDomainData(ctx, dbRecord); err != nil {
+						return err
+					}
+				}
+				return cs.verifyChecksum(dbRecord)
+			},
+		}, nil
+	})
+}
@@ -0,0 +1,190 @@
+package store_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	store "github.com/gradientzero/comby-store-sqlite"
+	"github.com/gradientzero/comby/v3"
+)
+
+func TestEventStoreChecksum(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "eventStore-checksum.db")
+
+	eventStore := store.NewEventStoreSQLite(path)
+	eventStore.Configure(
+		store.EventStoreSQLiteWithChecksumVerification(true),
+	)
+	if err := eventStore.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer eventStore.Close(ctx)
+
+	var evts []comby.Event
+	for i := int64(1); i <= 3; i++ {
+		evt := createTestEvent("tenant-1", "domain-1", i, i*100)
+		if err := eventStore.Create(ctx, comby.EventStoreCreateOptionWithEvent(evt)); err != nil {
+			t.Fatal(err)
+		}
+		evts = append(evts, evt)
+	}
+
+	// intact store
+	report, err := eventStore.VerifyAll(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.NumChecked != 3 || len(report.Corrupted) != 0 || report.NumMissing != 0 {
+		t.Fatalf("unexpected report: %+v", report)
+	}
+
+	// corrupt payload behind the store's back
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if _, err := db.ExecContext(ctx, "UPDATE events SET data_bytes=CAST('corrupted' AS BLOB) WHERE uuid=?", evts[1].GetEventUuid()); err != nil {
+		t.Fatal(err)
+	}
+
+	report, err = eventStore.VerifyAll(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Corrupted) != 1 || report.Corrupted[0] != evts[1].GetEventUuid() {
+		t.Fatalf("expected corrupted event to be reported: %+v", report)
+	}
+
+	// verification on read
+	if _, err := eventStore.Get(ctx, comby.EventStoreGetOptionWithEventUuid(evts[1].GetEventUuid())); !errors.Is(err, store.ErrChecksumMismatch) {
+		t.Fatalf("expected checksum mismatch, got %v", err)
+	}
+	if _, err := eventStore.Get(ctx, comby.EventStoreGetOptionWithEventUuid(evts[0].GetEventUuid())); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestEventStoreChecksumWithEncryption(t *testing.T) {
+	ctx := context.Background()
+
+	// create crypto service
+	key := []byte("12345678901234567890123456789012")
+	cryptoService, _ := comby.NewCryptoService(key)
+
+	eventStore := store.NewEventStoreSQLite(filepath.Join(t.TempDir(), "eventStore-checksum.db"))
+	eventStore.Configure(
+		store.EventStoreSQLiteWithChecksumAlgorithm(store.ChecksumCRC32),
+		store.EventStoreSQLiteWithChecksumVerification(true),
+	)
+	if err := eventStore.Init(ctx,
+		comby.EventStoreOptionWithCryptoService(cryptoService),
+	); err != nil {
+		t.Fatal(err)
+	}
+	defer eventStore.Close(ctx)
+
+	evt := createTestEvent("tenant-1", "domain-1", 1, 100)
+	if err := eventStore.Create(ctx, comby.EventStoreCreateOptionWithEvent(evt)); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := eventStore.List(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := eventStore.VerifyAll(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.NumChecked != 1 || len(report.Corrupted) != 0 {
+		t.Fatalf("unexpected report: %+v", report)
+	}
+}
+
+func TestCommandStoreChecksum(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "commandStore-checksum.db")
+
+	commandStore := store.NewCommandStoreSQLite(path)
+	commandStore.Configure(
+		store.CommandStoreSQLiteWithChecksumVerification(true),
+	)
+	if err := commandStore.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer commandStore.Close(ctx)
+
+	cmd := createTestCommand("tenant-1", "domain-1", 1000)
+	if err := commandStore.Create(ctx, comby.CommandStoreCreateOptionWithCommand(cmd)); err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if _, err := db.ExecContext(ctx, "UPDATE commands SET data_bytes=CAST('corrupted' AS BLOB) WHERE uuid=?", cmd.GetCommandUuid()); err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := commandStore.VerifyAll(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.NumChecked != 1 || len(report.Corrupted) != 1 {
+		t.Fatalf("unexpected report: %+v", report)
+	}
+	if _, err := commandStore.Get(ctx, comby.CommandStoreGetOptionWithCommandUuid(cmd.GetCommandUuid())); !errors.Is(err, store.ErrChecksumMismatch) {
+		t.Fatalf("expected checksum mismatch, got %v", err)
+	}
+}
+
+func TestEventStoreReadOnlyNeedsMigration(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "eventStore-readonly.db")
+
+	// database created before checksums were introduced
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if _, err := db.ExecContext(ctx, `
+	CREATE TABLE events (id INTEGER, instance_id INTEGER, uuid TEXT, tenant_uuid TEXT, command_uuid TEXT,
+		domain TEXT, aggregate_uuid TEXT, version INTEGER, created_at INTEGER, data_type TEXT, data_bytes TEXT,
+		PRIMARY KEY (id));
+	`); err != nil {
+		t.Fatal(err)
+	}
+
+	readOnly := func(opt *comby.EventStoreOptions) (*comby.EventStoreOptions, error) {
+		opt.ReadOnly = true
+		return opt, nil
+	}
+	eventStore := store.NewEventStoreSQLite(path)
+	if err := eventStore.Init(ctx, readOnly); err == nil || !strings.Contains(err.Error(), "needs migration") {
+		t.Fatalf("expected needs migration error, got %v", err)
+	}
+	eventStore.Close(ctx)
+
+	// once migrated, read-only stores can read
+	eventStore = store.NewEventStoreSQLite(path)
+	if err := eventStore.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	eventStore.Close(ctx)
+	eventStore = store.NewEventStoreSQLite(path)
+	if err := eventStore.Init(ctx, readOnly); err != nil {
+		t.Fatal(err)
+	}
+	defer eventStore.Close(ctx)
+	if _, _, err := eventStore.List(ctx); err != nil {
+		t.Fatal(err)
+	}
+}
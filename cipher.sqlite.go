@@ -0,0 +1,63 @@
+package store
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"fmt"
+
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+// supported payload encryption algorithms of key provider stores
+const (
+	// AES-GCM with 16, 24 or 32 byte keys, FIPS 140 approved (default)
+	EncryptionAESGCM = "aes-gcm"
+	// ChaCha20-Poly1305 with 32 byte keys, fast without AES hardware support
+	EncryptionChaCha20Poly1305 = "chacha20-poly1305"
+)
+
+// EventStoreSQLiteWithEncryptionAlgorithm sets the algorithm new payloads are
+// encrypted with (EncryptionAESGCM or EncryptionChaCha20Poly1305). It requires
+// a key provider, payloads of all supported algorithms stay readable.
+func EventStoreSQLiteWithEncryptionAlgorithm(algorithm string) EventStoreSQLiteOption {
+	return func(c *eventStoreSQLiteConfig) { c.EncryptionAlgorithm = algorithm }
+}
+
+// CommandStoreSQLiteWithEncryptionAlgorithm sets the algorithm new payloads are
+// encrypted with, see EventStoreSQLiteWithEncryptionAlgorithm.
+func CommandStoreSQLiteWithEncryptionAlgorithm(algorithm string) CommandStoreSQLiteOption {
+	return func(c *commandStoreSQLiteConfig) { c.EncryptionAlgorithm = algorithm }
+}
+
+// algorithm ids stored in the payload envelope, never reuse a value
+const (
+	algorithmIdAESGCM           byte = 1
+	algorithmIdChaCha20Poly1305 byte = 2
+)
+
+func algorithmId(algorithm string) (byte, error) {
+	switch algorithm {
+	case EncryptionAESGCM, "":
+		return algorithmIdAESGCM, nil
+	case EncryptionChaCha20Poly1305:
+		return algorithmIdChaCha20Poly1305, nil
+	default:
+		return 0, fmt.Errorf("unknown encryption algorithm '%s'", algorithm)
+	}
+}
+
+// newAEAD returns the cipher of the algorithm with the given id.
+func newAEAD(id byte, key []byte) (cipher.AEAD, error) {
+	switch id {
+	case algorithmIdAESGCM:
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, err
+		}
+		return cipher.NewGCM(block)
+	case algorithmIdChaCha20Poly1305:
+		return chacha20poly1305.New(key)
+	default:
+		return nil, fmt.Errorf("unknown encryption algorithm id %d", id)
+	}
+}
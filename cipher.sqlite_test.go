@@ -0,0 +1,93 @@
+package store_test
+
+import (
+	"context"
+	"database/sql"
+	"encoding/hex"
+	"path/filepath"
+	"testing"
+
+	store "github.com/gradientzero/comby-store-sqlite"
+	"github.com/gradientzero/comby/v3"
+)
+
+func TestEventStoreEncryptionAlgorithmMigration(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "eventStore-algorithm.db")
+	provider := store.NewStaticKeyProvider("key-1", []byte("12345678901234567890123456789012"))
+
+	// events written with the default algorithm
+	aesStore := store.NewEventStoreSQLite(path)
+	aesStore.Configure(store.EventStoreSQLiteWithKeyProvider(provider))
+	if err := aesStore.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	evt1 := createTestEvent("tenant-1", "domain-1", 1, 100)
+	if err := aesStore.Create(ctx, comby.EventStoreCreateOptionWithEvent(evt1)); err != nil {
+		t.Fatal(err)
+	}
+	aesStore.Close(ctx)
+
+	eventStore := store.NewEventStoreSQLite(path)
+	eventStore.Configure(
+		store.EventStoreSQLiteWithKeyProvider(provider),
+		store.EventStoreSQLiteWithEncryptionAlgorithm(store.EncryptionChaCha20Poly1305),
+	)
+	if err := eventStore.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer eventStore.Close(ctx)
+	evt2 := createTestEvent("tenant-1", "domain-1", 2, 200)
+	if err := eventStore.Create(ctx, comby.EventStoreCreateOptionWithEvent(evt2)); err != nil {
+		t.Fatal(err)
+	}
+
+	// the algorithm is recorded with each payload
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	for _, tc := range []struct {
+		uuid string
+		id   byte
+	}{{evt1.GetEventUuid(), 1}, {evt2.GetEventUuid(), 2}} {
+		var hexBytes string
+		if err := db.QueryRow("SELECT data_bytes FROM events WHERE uuid=?", tc.uuid).Scan(&hexBytes); err != nil {
+			t.Fatal(err)
+		}
+		// encrypted payloads are stored hex encoded
+		dataBytes, err := hex.DecodeString(hexBytes)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(dataBytes) < 2 || dataBytes[1] != tc.id {
+			t.Fatalf("expected algorithm id %d, got %v", tc.id, dataBytes[:min(len(dataBytes), 2)])
+		}
+	}
+
+	// payloads of both algorithms are readable
+	evts, _, err := eventStore.List(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(evts) != 2 || string(evts[0].GetDomainEvtBytes()) != "test-data-1" || string(evts[1].GetDomainEvtBytes()) != "test-data-2" {
+		t.Fatalf("unexpected events %v", evts)
+	}
+}
+
+func TestCommandStoreUnknownEncryptionAlgorithm(t *testing.T) {
+	ctx := context.Background()
+	commandStore := store.NewCommandStoreSQLite(filepath.Join(t.TempDir(), "commandStore-algorithm.db"))
+	commandStore.Configure(
+		store.CommandStoreSQLiteWithKeyProvider(store.NewStaticKeyProvider("key-1", []byte("12345678901234567890123456789012"))),
+		store.CommandStoreSQLiteWithEncryptionAlgorithm("rot13"),
+	)
+	if err := commandStore.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer commandStore.Close(ctx)
+	if err := commandStore.Create(ctx, comby.CommandStoreCreateOptionWithCommand(createTestCommand("tenant-1", "domain-1", 1))); err == nil {
+		t.Fatal("expected an error for an unknown algorithm")
+	}
+}
@@ -0,0 +1,68 @@
+package store
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/gradientzero/comby/v3"
+)
+
+// CommandStoreInfo extends comby.CommandStoreInfoModel by what operators need
+// to size retention policies.
+type CommandStoreInfo struct {
+	comby.CommandStoreInfoModel
+	// unix nano, 0 without commands
+	OldestItemCreatedAt int64
+	// total size of the stored payloads in bytes, encrypted ones as stored
+	DataBytes int64
+	// number of commands per processing state, every CommandStatus* is present
+	CountByStatus map[string]int64
+}
+
+// DetailedInfo returns Info with the oldest command, the payload bytes and
+// the number of commands per processing state.
+func (cs *commandStoreSQLite) DetailedInfo(ctx context.Context) (*CommandStoreInfo, error) {
+	if cs.db == nil {
+		return nil, fmt.Errorf("'%s' failed to get info - instance is not initialized", cs.String())
+	}
+	ctx, cancel := cs.cfg().Timeouts.read(ctx)
+	defer cancel()
+	info := &CommandStoreInfo{
+		CommandStoreInfoModel: comby.CommandStoreInfoModel{
+			StoreType:      "sqlite",
+			ConnectionInfo: cs.path,
+		},
+		CountByStatus: map[string]int64{
+			CommandStatusPending:   0,
+			CommandStatusProcessed: 0,
+			CommandStatusFailed:    0,
+		},
+	}
+	// a store bound to a tenant only describes its commands
+	whereList, args := tenantCondition(cs.cfg().Tenant, nil, nil)
+	query := fmt.Sprintf(`SELECT COUNT(*), COALESCE(MAX(created_at), 0), COALESCE(MIN(created_at), 0),
+		COALESCE(SUM(LENGTH(data_bytes)), 0) FROM commands%s;`, whereSQL(whereList))
+	if err := cs.db.QueryRowContext(ctx, query, args...).Scan(&info.NumItems, &info.LastItemCreatedAt,
+		&info.OldestItemCreatedAt, &info.DataBytes); err != nil {
+		return nil, fmt.Errorf("'%s' failed to get info - %w", cs.String(), classifyError(err))
+	}
+
+	query = fmt.Sprintf(`SELECT COALESCE(status, 'pending'), COUNT(*) FROM commands%s GROUP BY 1;`, whereSQL(whereList))
+	rows, err := cs.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("'%s' failed to get info - %w", cs.String(), classifyError(err))
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var status string
+		var count int64
+		if err := rows.Scan(&status, &count); err != nil {
+			return nil, fmt.Errorf("'%s' failed to get info - %w", cs.String(), classifyError(err))
+		}
+		info.CountByStatus[status] = count
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("'%s' failed to get info - %w", cs.String(), classifyError(err))
+	}
+	return info, nil
+}
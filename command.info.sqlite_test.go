@@ -0,0 +1,68 @@
+package store_test
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	store "github.com/gradientzero/comby-store-sqlite"
+	"github.com/gradientzero/comby/v3"
+)
+
+func TestCommandStoreDetailedInfo(t *testing.T) {
+	ctx := context.Background()
+	commandStore := store.NewCommandStoreSQLite(filepath.Join(t.TempDir(), "commandStore-info.db"))
+	if err := commandStore.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer commandStore.Close(ctx)
+
+	info, err := commandStore.DetailedInfo(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.NumItems != 0 || info.OldestItemCreatedAt != 0 || info.DataBytes != 0 || info.CountByStatus[store.CommandStatusPending] != 0 || len(info.CountByStatus) != 3 {
+		t.Fatalf("unexpected info of empty store: %+v", info)
+	}
+
+	var cmds []comby.Command
+	var dataBytes int
+	for i := int64(1); i <= 4; i++ {
+		cmd := createTestCommand("tenant-1", "domain-1", i*100)
+		if err := commandStore.Create(ctx, comby.CommandStoreCreateOptionWithCommand(cmd)); err != nil {
+			t.Fatal(err)
+		}
+		cmds = append(cmds, cmd)
+		dataBytes += len(cmd.GetDomainCmdBytes())
+	}
+	if err := commandStore.MarkProcessed(ctx, cmds[0].GetCommandUuid()); err != nil {
+		t.Fatal(err)
+	}
+	if err := commandStore.MarkProcessed(ctx, cmds[1].GetCommandUuid()); err != nil {
+		t.Fatal(err)
+	}
+	if err := commandStore.MarkFailed(ctx, cmds[2].GetCommandUuid(), "failed"); err != nil {
+		t.Fatal(err)
+	}
+
+	info, err = commandStore.DetailedInfo(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.NumItems != 4 || info.OldestItemCreatedAt != 100 || info.LastItemCreatedAt != 400 || info.StoreType != "sqlite" {
+		t.Fatalf("unexpected info: %+v", info)
+	}
+	if info.DataBytes != int64(dataBytes) || dataBytes == 0 {
+		t.Fatalf("expected %d payload bytes, got %d", dataBytes, info.DataBytes)
+	}
+	want := map[string]int64{
+		store.CommandStatusPending:   1,
+		store.CommandStatusProcessed: 2,
+		store.CommandStatusFailed:    1,
+	}
+	for status, count := range want {
+		if info.CountByStatus[status] != count {
+			t.Fatalf("expected %d %s commands, got %v", count, status, info.CountByStatus)
+		}
+	}
+}
@@ -0,0 +1,165 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gradientzero/comby-store-sqlite/internal"
+	"github.com/gradientzero/comby/v3"
+)
+
+// CommandDispatcher receives replayed commands, e.g. to pass them to a fixed
+// command handler again.
+type CommandDispatcher func(cmd comby.Command) error
+
+// CommandReplayFilter selects the commands of ReplayCommands. Empty fields
+// do not filter.
+type CommandReplayFilter struct {
+	// name of the checkpoint, replays with a name resume after the last
+	// dispatched command of the previous run
+	Name       string
+	TenantUuid string
+	Domains    []string
+	DataTypes  []string
+	// processing state, e.g. CommandStatusFailed
+	Status string
+	// only commands with after < created_at < before (0 disables a bound)
+	After  int64
+	Before int64
+	// commands loaded per query, defaults to 1000
+	BatchSize int
+}
+
+// the checkpoints of named command replays
+var commandReplayTables = []strictTable{
+	{
+		name: "command_replay_checkpoints",
+		columns: `name TEXT NOT NULL PRIMARY KEY,
+		seq INTEGER NOT NULL,
+		updated_at INTEGER NOT NULL`,
+		copyColumns: `name, seq, updated_at`,
+	},
+}
+
+// ReplayCommands streams the stored commands matching filter in store order
+// to dispatcher and returns the number of dispatched commands. It stops at the
+// first dispatcher error. Named replays store the position of the last
+// dispatched command, so calling it again after fixing the error continues
+// with the failed command.
+func (cs *commandStoreSQLite) ReplayCommands(ctx context.Context, filter CommandReplayFilter, dispatcher CommandDispatcher) (int64, error) {
+	if dispatcher == nil {
+		return 0, fmt.Errorf("'%s' failed to replay commands - dispatcher is nil", cs.String())
+	}
+	if filter.BatchSize == 0 {
+		filter.BatchSize = 1000
+	}
+	if filter.BatchSize < 0 {
+		return 0, fmt.Errorf("'%s' failed to replay commands - invalid batch size %d", cs.String(), filter.BatchSize)
+	}
+	if len(filter.Name) > 0 && cs.opts().ReadOnly {
+		return 0, fmt.Errorf("'%s' failed to replay commands - checkpoints require a writable instance", cs.String())
+	}
+	if err := cs.authorize(ctx, AccessRequest{Operation: OperationList, TenantUuid: filter.TenantUuid, Domains: filter.Domains}); err != nil {
+		return 0, err
+	}
+
+	whereList := []string{"id>?"}
+	var args []any
+	if len(filter.TenantUuid) > 0 {
+		whereList = append(whereList, "tenant_uuid=?")
+		args = append(args, filter.TenantUuid)
+	}
+	whereList, args = inCondition("domain", "IN", filter.Domains, whereList, args)
+	whereList, args = inCondition("data_type", "IN", filter.DataTypes, whereList, args)
+	if len(filter.Status) > 0 {
+		whereList = append(whereList, "status=?")
+		args = append(args, filter.Status)
+	}
+	if filter.After > 0 {
+		whereList = append(whereList, "created_at>?")
+		args = append(args, filter.After)
+	}
+	if filter.Before > 0 {
+		whereList = append(whereList, "created_at<?")
+		args = append(args, filter.Before)
+	}
+	whereList, args = tenantCondition(cs.cfg().Tenant, whereList, args)
+	query := fmt.Sprintf("SELECT %s FROM commands WHERE %s ORDER BY id ASC LIMIT %d;", commandSelectColumns, strings.Join(whereList, " AND "), filter.BatchSize)
+
+	seq, err := cs.replayCheckpoint(ctx, filter.Name)
+	if err != nil {
+		return 0, fmt.Errorf("'%s' failed to replay commands - %w", cs.String(), classifyError(err))
+	}
+	var dispatched int64
+	for {
+		dbRecords, err := queryCommandRecords(ctx, cs.db, query, append([]any{seq}, args...)...)
+		if err != nil {
+			return dispatched, fmt.Errorf("'%s' failed to replay commands - %w", cs.String(), classifyError(err))
+		}
+		if len(dbRecords) == 0 {
+			return dispatched, nil
+		}
+		last := seq
+		dispatchErr := cs.dispatchBatch(ctx, dbRecords, dispatcher, &last, &dispatched)
+		if last > seq {
+			seq = last
+			if err := cs.saveReplayCheckpoint(ctx, filter.Name, seq); err != nil {
+				return dispatched, fmt.Errorf("'%s' failed to save replay checkpoint - %w", cs.String(), classifyError(err))
+			}
+		}
+		if dispatchErr != nil {
+			return dispatched, dispatchErr
+		}
+	}
+}
+
+// dispatchBatch dispatches dbRecords and advances last to the sequence of
+// each dispatched command.
+func (cs *commandStoreSQLite) dispatchBatch(ctx context.Context, dbRecords []*internal.Command, dispatcher CommandDispatcher, last, dispatched *int64) error {
+	for _, dbRecord := range dbRecords {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := cs.decodeDomainData(ctx, dbRecord); err != nil {
+			return err
+		}
+		cmd, err := internal.DbCommandToBaseCommand(dbRecord)
+		if err != nil {
+			return err
+		}
+		if err := dispatcher(cmd); err != nil {
+			return fmt.Errorf("'%s' failed to dispatch command '%s' - %w", cs.String(), dbRecord.Uuid, err)
+		}
+		*last = dbRecord.ID.Int64
+		*dispatched++
+	}
+	return nil
+}
+
+func (cs *commandStoreSQLite) replayCheckpoint(ctx context.Context, name string) (int64, error) {
+	if len(name) == 0 {
+		return 0, nil
+	}
+	var seq int64
+	err := cs.db.QueryRowContext(ctx, "SELECT seq FROM command_replay_checkpoints WHERE name=?;", name).Scan(&seq)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	return seq, err
+}
+
+func (cs *commandStoreSQLite) saveReplayCheckpoint(ctx context.Context, name string, seq int64) error {
+	if len(name) == 0 {
+		return nil
+	}
+	// checkpoints are bookkeeping of the replay, they bypass the write limits
+	cs.writeMu.Lock()
+	defer cs.writeMu.Unlock()
+	query := `INSERT INTO command_replay_checkpoints (name, seq, updated_at) VALUES (?, ?, ?)
+		ON CONFLICT(name) DO UPDATE SET seq=excluded.seq, updated_at=excluded.updated_at;`
+	_, err := cs.db.ExecContext(ctx, query, name, seq, time.Now().UnixNano())
+	return err
+}
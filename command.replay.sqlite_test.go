@@ -0,0 +1,63 @@
+package store_test
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	store "github.com/gradientzero/comby-store-sqlite"
+	"github.com/gradientzero/comby/v3"
+)
+
+func TestCommandStoreReplayCommands(t *testing.T) {
+	ctx := context.Background()
+	commandStore := store.NewCommandStoreSQLite(filepath.Join(t.TempDir(), "commandStore-replay.db"))
+	if err := commandStore.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer commandStore.Close(ctx)
+	for i := int64(1); i <= 6; i++ {
+		domain := "domain-1"
+		if i%2 == 0 {
+			domain = "domain-2"
+		}
+		if err := commandStore.Create(ctx, comby.CommandStoreCreateOptionWithCommand(createTestCommand("tenant-1", domain, i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// the handler fails on the second command of domain-1
+	filter := store.CommandReplayFilter{Name: "fix-1", Domains: []string{"domain-1"}, BatchSize: 2}
+	var seen []string
+	failOn := "test-data-3"
+	dispatcher := func(cmd comby.Command) error {
+		if string(cmd.GetDomainCmdBytes()) == failOn {
+			return errors.New("handler bug")
+		}
+		seen = append(seen, string(cmd.GetDomainCmdBytes()))
+		return nil
+	}
+	dispatched, err := commandStore.ReplayCommands(ctx, filter, dispatcher)
+	if err == nil || dispatched != 1 {
+		t.Fatalf("expected 1 dispatched command and an error, got %d and %v", dispatched, err)
+	}
+
+	// after the fix the replay continues with the failed command
+	failOn = ""
+	dispatched, err = commandStore.ReplayCommands(ctx, filter, dispatcher)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dispatched != 2 || len(seen) != 3 || seen[0] != "test-data-1" || seen[1] != "test-data-3" || seen[2] != "test-data-5" {
+		t.Fatalf("unexpected replay: %d %v", dispatched, seen)
+	}
+
+	// nothing is left for the checkpoint, unnamed replays start from the beginning
+	if dispatched, err := commandStore.ReplayCommands(ctx, filter, dispatcher); err != nil || dispatched != 0 {
+		t.Fatalf("expected nothing to replay, got %d and %v", dispatched, err)
+	}
+	if dispatched, err := commandStore.ReplayCommands(ctx, store.CommandReplayFilter{}, dispatcher); err != nil || dispatched != 6 {
+		t.Fatalf("expected 6 replayed commands, got %d and %v", dispatched, err)
+	}
+}
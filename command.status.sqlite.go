@@ -0,0 +1,98 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/gradientzero/comby/v3"
+)
+
+// Processing states of commands. Created commands are pending.
+const (
+	CommandStatusPending   = "pending"
+	CommandStatusProcessed = "processed"
+	CommandStatusFailed    = "failed"
+)
+
+// CommandStatus is the processing state of a command.
+type CommandStatus struct {
+	Status string
+	// unix nano, 0 while pending
+	ProcessedAt int64
+	// error passed to MarkFailed
+	ErrorText string
+}
+
+func (cs *commandStoreSQLite) MarkProcessed(ctx context.Context, commandUuid string) error {
+	return cs.markStatus(ctx, commandUuid, CommandStatusProcessed, "")
+}
+
+func (cs *commandStoreSQLite) MarkFailed(ctx context.Context, commandUuid string, errorText string) error {
+	return cs.markStatus(ctx, commandUuid, CommandStatusFailed, errorText)
+}
+
+func (cs *commandStoreSQLite) markStatus(ctx context.Context, commandUuid, status, errorText string) error {
+	if cs.opts().ReadOnly {
+		return fmt.Errorf("'%s' failed to mark command - instance is readonly", cs.String())
+	}
+	if len(commandUuid) == 0 {
+		return fmt.Errorf("'%s' failed to mark command - command uuid is required", cs.String())
+	}
+	done, err := cs.beginWrite(ctx)
+	if err != nil {
+		return err
+	}
+	defer done()
+	if err := cs.authorizeStored(ctx, cs.db, OperationUpdate, commandUuid); err != nil {
+		return err
+	}
+
+	// commands of other tenants than the bound one are not found
+	whereList, args := tenantCondition(cs.cfg().Tenant, []string{"uuid=?"}, []any{commandUuid})
+	query := fmt.Sprintf("UPDATE commands SET status=?, processed_at=?, error_text=?%s;", whereSQL(whereList))
+	res, err := cs.db.ExecContext(ctx, query, append([]any{status, time.Now().UnixNano(), errorText}, args...)...)
+	if err != nil {
+		return classifyError(err)
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return fmt.Errorf("'%s' failed to mark command - command '%s' %w", cs.String(), commandUuid, ErrNotFound)
+	}
+	return nil
+}
+
+func (cs *commandStoreSQLite) GetStatus(ctx context.Context, commandUuid string) (*CommandStatus, error) {
+	if err := cs.authorizeStored(ctx, cs.db, OperationGet, commandUuid); err != nil {
+		return nil, err
+	}
+	var status CommandStatus
+	whereList, args := tenantCondition(cs.cfg().Tenant, []string{"uuid=?"}, []any{commandUuid})
+	query := fmt.Sprintf("SELECT status, processed_at, error_text FROM commands%s LIMIT 1;", whereSQL(whereList))
+	err := cs.db.QueryRowContext(ctx, query, args...).Scan(&status.Status, &status.ProcessedAt, &status.ErrorText)
+	switch {
+	case err == sql.ErrNoRows:
+		return nil, nil
+	case err != nil:
+		return nil, classifyError(err)
+	}
+	return &status, nil
+}
+
+func (cs *commandStoreSQLite) ListByStatus(ctx context.Context, status string, opts ...comby.CommandStoreListOption) ([]comby.Command, int64, error) {
+	listOpts, filter, err := cs.listOptions(opts)
+	if err != nil {
+		return nil, 0, err
+	}
+	if len(status) == 0 {
+		return nil, 0, fmt.Errorf("'%s' failed to list commands - status is required", cs.String())
+	}
+	filter.Status = status
+	if err := cs.authorizeList(ctx, listOpts, filter); err != nil {
+		return nil, 0, err
+	}
+	cmds, total, err := cs.list(ctx, cs.db, listOpts, filter)
+	return cmds, total, classifyError(err)
+}
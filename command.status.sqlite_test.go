@@ -0,0 +1,93 @@
+package store_test
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"testing"
+
+	store "github.com/gradientzero/comby-store-sqlite"
+	"github.com/gradientzero/comby/v3"
+)
+
+func TestCommandStoreStatus(t *testing.T) {
+	ctx := context.Background()
+	commandStore := store.NewCommandStoreSQLite(filepath.Join(t.TempDir(), "commandStore-status.db"))
+	if err := commandStore.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer commandStore.Close(ctx)
+
+	var cmds []comby.Command
+	for i := int64(1); i <= 3; i++ {
+		cmd := createTestCommand("tenant-1", "domain-1", i*100)
+		if err := commandStore.Create(ctx, comby.CommandStoreCreateOptionWithCommand(cmd)); err != nil {
+			t.Fatal(err)
+		}
+		cmds = append(cmds, cmd)
+	}
+	if err := commandStore.MarkProcessed(ctx, cmds[0].GetCommandUuid()); err != nil {
+		t.Fatal(err)
+	}
+	if err := commandStore.MarkFailed(ctx, cmds[1].GetCommandUuid(), "aggregate not found"); err != nil {
+		t.Fatal(err)
+	}
+	if err := commandStore.MarkProcessed(ctx, "missing"); err == nil {
+		t.Fatal("expected error for unknown command")
+	}
+
+	status, err := commandStore.GetStatus(ctx, cmds[1].GetCommandUuid())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.Status != store.CommandStatusFailed || status.ErrorText != "aggregate not found" || status.ProcessedAt == 0 {
+		t.Fatalf("unexpected status: %+v", status)
+	}
+	for status, uuid := range map[string]string{
+		store.CommandStatusProcessed: cmds[0].GetCommandUuid(),
+		store.CommandStatusFailed:    cmds[1].GetCommandUuid(),
+		store.CommandStatusPending:   cmds[2].GetCommandUuid(),
+	} {
+		list, total, err := commandStore.ListByStatus(ctx, status)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if total != 1 || len(list) != 1 || list[0].GetCommandUuid() != uuid {
+			t.Fatalf("unexpected %s commands: %d", status, total)
+		}
+	}
+}
+
+func TestCommandStoreStatusMigration(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "commandStore-status-migration.db")
+
+	// strict table created before the status columns were added
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if _, err := db.ExecContext(ctx, `
+	CREATE TABLE commands (id INTEGER, instance_id INTEGER NOT NULL, uuid TEXT NOT NULL, tenant_uuid TEXT NOT NULL,
+		workspace_uuid TEXT, domain TEXT NOT NULL, created_at INTEGER NOT NULL, data_type TEXT NOT NULL,
+		data_bytes BLOB NOT NULL, req_ctx TEXT, checksum TEXT, PRIMARY KEY (id)) STRICT;
+	INSERT INTO commands (instance_id, uuid, tenant_uuid, domain, created_at, data_type, data_bytes, req_ctx)
+	VALUES (1, 'legacy-1', 'tenant-1', 'domain-1', 100, 'TestCommand', X'00', '');
+	`); err != nil {
+		t.Fatal(err)
+	}
+
+	commandStore := store.NewCommandStoreSQLite(path)
+	if err := commandStore.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer commandStore.Close(ctx)
+	status, err := commandStore.GetStatus(ctx, "legacy-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status == nil || status.Status != store.CommandStatusPending {
+		t.Fatalf("unexpected status of migrated command: %+v", status)
+	}
+}
@@ -4,30 +4,254 @@ import (
 	"context"
 	"database/sql"
 	"encoding/hex"
+	"errors"
 	"fmt"
+	"io/fs"
+	"log/slog"
 	"os"
 	"path/filepath"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/gradientzero/comby-store-sqlite/internal"
 	"github.com/gradientzero/comby/v3"
-	_ "modernc.org/sqlite"
 )
 
+// CommandStoreSQLite is the comby.CommandStore backed by SQLite including its sqlite specific extensions.
+type CommandStoreSQLite interface {
+	comby.CommandStore
+
+	// Configure applies sqlite specific options. Options like the logger or
+	// checksum verification may also be changed after Init.
+	Configure(opts ...CommandStoreSQLiteOption)
+	// ApplyOptions updates options of an initialized store, e.g. to switch
+	// into read-only mode during maintenance without reconnecting. It waits
+	// for writes in progress and must not be called within WithTx.
+	ApplyOptions(opts ...comby.CommandStoreOption) error
+	// CreateIdempotent creates a command or returns the one stored before with
+	// the same idempotency key, see IdempotencyKeyAttribute.
+	CreateIdempotent(ctx context.Context, opts ...comby.CommandStoreCreateOption) (comby.Command, bool, error)
+	// GetByIdempotencyKey returns the command of a tenant stored with an idempotency key.
+	GetByIdempotencyKey(ctx context.Context, tenantUuid, key string) (comby.Command, error)
+	// WithTx runs fn in one transaction, see CommandStoreTx.
+	WithTx(ctx context.Context, fn func(tx CommandStoreTx) error) error
+	// BeginBulk and EndBulk collect creates in large transactions, see BulkWriter.
+	BulkWriter
+	// Write creates a command, within the active bulk if any.
+	Write(ctx context.Context, cmd comby.Command) error
+	// WriteStats reports pending, throttled and rejected writes.
+	WriteStats() WriteStats
+	// MaintainStorage runs incremental_vacuum and truncates the WAL.
+	MaintainStorage(ctx context.Context) (*StorageReport, error)
+	// VerifyAll scans the whole store and verifies all payload checksums.
+	VerifyAll(ctx context.Context) (*ChecksumReport, error)
+	// ListCommandsWithoutEvents returns commands no event refers to. Requires
+	// the event store to share the database file.
+	ListCommandsWithoutEvents(ctx context.Context) ([]comby.Command, error)
+	// FindOrphans reports events and commands which do not match up. Requires
+	// the event store to share the database file.
+	FindOrphans(ctx context.Context) (*OrphanReport, error)
+	// ListCommandsByEvent returns the commands triggered by the given event.
+	ListCommandsByEvent(ctx context.Context, eventUuid string) ([]comby.Command, error)
+	// MarkProcessed and MarkFailed record the outcome of handling a command.
+	MarkProcessed(ctx context.Context, commandUuid string) error
+	MarkFailed(ctx context.Context, commandUuid string, errorText string) error
+	// GetStatus returns the processing state of a command, nil if it does not exist.
+	GetStatus(ctx context.Context, commandUuid string) (*CommandStatus, error)
+	// ListByStatus lists commands in the given processing state, e.g. pending ones.
+	ListByStatus(ctx context.Context, status string, opts ...comby.CommandStoreListOption) ([]comby.Command, int64, error)
+	// DetailedInfo extends Info by the oldest command, payload bytes and counts per status.
+	DetailedInfo(ctx context.Context) (*CommandStoreInfo, error)
+	// ListAdminAudit returns the recorded administrative operations, e.g. resets.
+	ListAdminAudit(ctx context.Context) ([]AdminAuditEntry, error)
+	// ReadSnapshot pins one read transaction for several Get/List/Total calls.
+	ReadSnapshot(ctx context.Context) (CommandStoreReadSnapshot, error)
+	// ListPage lists commands like List without the count query and reports whether more follow.
+	ListPage(ctx context.Context, opts ...comby.CommandStoreListOption) ([]comby.Command, bool, error)
+	// ListBatches passes all commands in store order to fn in batches.
+	ListBatches(ctx context.Context, batchSize int, fn func([]comby.Command) error) error
+	// ListEach passes the commands of a list to fn one by one while they are read.
+	ListEach(ctx context.Context, fn func(cmd comby.Command) error, opts ...comby.CommandStoreListOption) (int64, error)
+	// SubscribeChanges returns a channel signalled after commands were written.
+	SubscribeChanges() (<-chan struct{}, func())
+	// ListAfterUuid lists commands ordered by uuid after a cursor (keyset pagination).
+	ListAfterUuid(ctx context.Context, afterUuid string, opts ...comby.CommandStoreListOption) ([]comby.Command, int64, error)
+	// ListDataTypes returns the command data types per domain with counts and first/last seen.
+	ListDataTypes(ctx context.Context) ([]DataTypeInfo, error)
+	// EncryptExisting encrypts the plaintext payloads of a store which got a crypto service later.
+	EncryptExisting(ctx context.Context) (int64, error)
+	// Preflight checks integrity, indexes and tables and warms the page cache.
+	Preflight(ctx context.Context, warmPages int) (*PreflightReport, error)
+	// Checkpoint writes the WAL back into the database file.
+	Checkpoint(ctx context.Context, mode CheckpointMode) (*CheckpointReport, error)
+	// SchemaInfo describes the DDL and pragmas of the database to compare deployments.
+	SchemaInfo(ctx context.Context) (*SchemaInfo, error)
+	// ReplayCommands streams stored commands to a dispatcher, resuming named replays.
+	ReplayCommands(ctx context.Context, filter CommandReplayFilter, dispatcher CommandDispatcher) (int64, error)
+	// SlowQueries returns the operations recorded by the query watchdog.
+	SlowQueries() []SlowQuery
+	// Metrics returns the reads and writes per minute of the metrics window.
+	Metrics(ctx context.Context) ([]MetricsMinute, error)
+	// ResetAndReinit replaces the database with a freshly migrated one.
+	ResetAndReinit(ctx context.Context) error
+	// Snapshot and SnapshotFile capture the current database as a consistent copy.
+	Snapshot(ctx context.Context) ([]byte, error)
+	SnapshotFile(ctx context.Context, path string) error
+}
+
+// CommandStoreSQLiteOption configures sqlite specific behaviour of the command store.
+type CommandStoreSQLiteOption func(*commandStoreSQLiteConfig)
+
+type commandStoreSQLiteConfig struct {
+	// json paths per data type encrypted individually instead of the whole payload
+	FieldEncryption map[string][]string
+	// algorithm used for payload checksums, defaults to ChecksumSHA256
+	ChecksumAlgorithm string
+	// verify payload checksums on read
+	VerifyChecksum bool
+	Logger         *slog.Logger
+	// throttles writes at the store boundary
+	WriteLimit writeLimit
+	// vacuum mode and schedule of MaintainStorage
+	Maintenance storageMaintenance
+	// maximum size of the database checked before writes
+	Quota sizeQuota
+	// busy timeout and deadlines of reads and writes
+	Timeouts opTimeouts
+	// assigns uuids to records created without one
+	UuidGenerator UuidGenerator
+	// decides on access before operations
+	Authorizer Authorizer
+	// only records of this tenant are visible and writable
+	Tenant string
+	// supplies encryption keys instead of the crypto service
+	KeyProvider KeyProvider
+	// algorithm payloads are encrypted with using keys of the key provider
+	EncryptionAlgorithm string
+	// checks and cache warm-up on Init
+	Preflight preflightConfig
+	// background checkpoints by WAL size
+	Checkpointer checkpointer
+	// connection pragmas for read heavy workloads
+	ReadProfile readProfile
+	// lists without limit fail if more records match, 0 disables the cap
+	MaxListLimit int64
+	// interval of checking for inserts of other processes, 0 disables it
+	ChangePolling time.Duration
+	// database/sql driver the store connects with, the default driver if empty
+	DriverName string
+	// interval of validating pooled connections, 0 disables it
+	Keepalive time.Duration
+	// cancels runaway operations and keeps the slow query log
+	Watchdog *queryWatchdog
+	// reads and writes per minute, written to the metrics table
+	Metrics *opsMetrics
+	// file system the database is read from, path is a name within it
+	FS fs.FS
+}
+
+// CommandStoreSQLiteWithFieldEncryption encrypts only the given dot separated JSON paths
+// of payloads with the given data type (requires a crypto service). The remaining
+// payload stays in plaintext and can be queried with the JSON1 functions.
+func CommandStoreSQLiteWithFieldEncryption(dataType string, paths ...string) CommandStoreSQLiteOption {
+	return func(c *commandStoreSQLiteConfig) {
+		if c.FieldEncryption == nil {
+			c.FieldEncryption = map[string][]string{}
+		}
+		c.FieldEncryption[dataType] = paths
+	}
+}
+
+// CommandStoreSQLiteWithChecksumAlgorithm sets the algorithm used for payload checksums (ChecksumSHA256 or ChecksumCRC32).
+func CommandStoreSQLiteWithChecksumAlgorithm(algorithm string) CommandStoreSQLiteOption {
+	return func(c *commandStoreSQLiteConfig) { c.ChecksumAlgorithm = algorithm }
+}
+
+// CommandStoreSQLiteWithChecksumVerification verifies payload checksums on Get and List.
+func CommandStoreSQLiteWithChecksumVerification(verify bool) CommandStoreSQLiteOption {
+	return func(c *commandStoreSQLiteConfig) { c.VerifyChecksum = verify }
+}
+
+// CommandStoreSQLiteWithLogger sets the logger used for migrations and maintenance.
+func CommandStoreSQLiteWithLogger(logger *slog.Logger) CommandStoreSQLiteOption {
+	return func(c *commandStoreSQLiteConfig) { c.Logger = logger }
+}
+
+// CommandStoreSQLiteWithWriteRateLimit limits writes to perSecond with bursts of up to burst writes.
+func CommandStoreSQLiteWithWriteRateLimit(perSecond float64, burst int) CommandStoreSQLiteOption {
+	return func(c *commandStoreSQLiteConfig) {
+		c.WriteLimit.PerSecond = perSecond
+		c.WriteLimit.Burst = burst
+	}
+}
+
+// CommandStoreSQLiteWithMaxPendingWrites rejects writes with ErrWriteBackpressure while n writes are pending.
+func CommandStoreSQLiteWithMaxPendingWrites(n int) CommandStoreSQLiteOption {
+	return func(c *commandStoreSQLiteConfig) { c.WriteLimit.MaxPending = n }
+}
+
+// CommandStoreSQLiteWithIncrementalVacuum switches the database to auto_vacuum=INCREMENTAL
+// on Init (running a one-time VACUUM on existing databases), so MaintainStorage
+// can reclaim space after large deletions without a full VACUUM.
+func CommandStoreSQLiteWithIncrementalVacuum() CommandStoreSQLiteOption {
+	return func(c *commandStoreSQLiteConfig) { c.Maintenance.IncrementalVacuum = true }
+}
+
+// CommandStoreSQLiteWithMaintenanceInterval runs MaintainStorage every interval until Close.
+func CommandStoreSQLiteWithMaintenanceInterval(interval time.Duration) CommandStoreSQLiteOption {
+	return func(c *commandStoreSQLiteConfig) { c.Maintenance.Interval = interval }
+}
+
+// CommandStoreSQLiteWithMaxSize rejects Create and WithTx with a QuotaError while the
+// database uses more than maxBytes. If onExceeded is not nil, it is called
+// first to free space, e.g. by pruning archived events.
+func CommandStoreSQLiteWithMaxSize(maxBytes int64, onExceeded func(ctx context.Context) error) CommandStoreSQLiteOption {
+	return func(c *commandStoreSQLiteConfig) {
+		c.Quota = sizeQuota{MaxSize: maxBytes, OnExceeded: onExceeded}
+	}
+}
+
 // Make sure it implements interfaces
-var _ comby.CommandStore = (*commandStoreSQLite)(nil)
+var _ CommandStoreSQLite = (*commandStoreSQLite)(nil)
 
 type commandStoreSQLite struct {
+	// guards options and config, which may be updated after Init
+	mu      sync.RWMutex
 	options comby.CommandStoreOptions
+	config  commandStoreSQLiteConfig
 	db      *sql.DB
 
 	// sqlite specific options
 	path string
+
+	sharedDB
+	// audit entry of a Reset, written by the next migration
+	pendingAudit atomic.Pointer[AdminAuditEntry]
+	// throttles writes before they wait for writeMu
+	gate writeGate
+	// periodic MaintainStorage, if configured
+	maintenance *maintenanceLoop
+	// periodic Checkpoint, if configured
+	checkpointer *maintenanceLoop
+	// signals subscribers after writes
+	changes changeNotifier
+	// periodic check for inserts of other processes, if configured
+	changePoller *maintenanceLoop
+	// periodic validation of pooled connections, if configured
+	keepalive *maintenanceLoop
+	// periodic flush of the metrics, if configured
+	metricsFlusher *maintenanceLoop
+	// temporary copy of a database read from an fs.FS
+	fsCopy string
+	// open batch between BeginBulk and EndBulk
+	bulk atomic.Pointer[bulkBatch]
 }
 
-func NewCommandStoreSQLite(path string, opts ...comby.CommandStoreOption) comby.CommandStore {
+func NewCommandStoreSQLite(path string, opts ...comby.CommandStoreOption) CommandStoreSQLite {
 	cs := &commandStoreSQLite{
-		path: path,
+		path:     path,
+		sharedDB: sharedDB{writeMu: &sync.Mutex{}},
 	}
 	for _, opt := range opts {
 		if _, err := opt(&cs.options); err != nil {
@@ -37,38 +261,91 @@ func NewCommandStoreSQLite(path string, opts ...comby.CommandStoreOption) comby.
 	return cs
 }
 
-func (cs *commandStoreSQLite) connect(ctx context.Context) (*sql.DB, error) {
-	db, err := sql.Open("sqlite", cs.path)
-	if err != nil {
-		return nil, err
-	}
-	// WAL mode allows concurrent readers while a single writer holds the lock.
-	maxOpenConns := 10
-	if cs.options.MaxOpenConns > 0 {
-		maxOpenConns = cs.options.MaxOpenConns
+// columns of a command record as read by scanCommand
+const commandSelectColumns = `id, instance_id, uuid, tenant_uuid, COALESCE(workspace_uuid, ''), domain, created_at,
+	data_type, data_bytes, req_ctx, COALESCE(checksum, ''), is_encrypted`
+
+func scanCommand(row rowScanner, dbRecord *internal.Command) error {
+	return row.Scan(
+		&dbRecord.ID,
+		&dbRecord.InstanceId,
+		&dbRecord.Uuid,
+		&dbRecord.TenantUuid,
+		&dbRecord.WorkspaceUuid,
+		&dbRecord.Domain,
+		&dbRecord.CreatedAt,
+		&dbRecord.DataType,
+		&dbRecord.DataBytes,
+		&dbRecord.ReqCtx,
+		&dbRecord.Checksum,
+		&dbRecord.IsEncrypted,
+	)
+}
+
+func (cs *commandStoreSQLite) Configure(opts ...CommandStoreSQLiteOption) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	for _, opt := range opts {
+		opt(&cs.config)
 	}
-	db.SetMaxOpenConns(maxOpenConns)
+}
+
+// opts returns a copy of the options, safe for concurrent use with ApplyOptions.
+func (cs *commandStoreSQLite) opts() comby.CommandStoreOptions {
+	cs.mu.RLock()
+	defer cs.mu.RUnlock()
+	return cs.options
+}
 
-	if cs.options.MaxIdleConns > 0 {
-		db.SetMaxIdleConns(cs.options.MaxIdleConns)
+// cfg returns a copy of the sqlite specific config, safe for concurrent use with Configure.
+func (cs *commandStoreSQLite) cfg() commandStoreSQLiteConfig {
+	cs.mu.RLock()
+	defer cs.mu.RUnlock()
+	return cs.config
+}
+
+func (cs *commandStoreSQLite) ApplyOptions(opts ...comby.CommandStoreOption) error {
+	// wait for writes in progress, so none of them commits with the old options
+	// after returning (e.g. when switching into read-only mode)
+	cs.writeMu.Lock()
+	defer cs.writeMu.Unlock()
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	// apply on a copy, so a failing option leaves the store untouched
+	options := cs.options
+	for _, opt := range opts {
+		if _, err := opt(&options); err != nil {
+			return err
+		}
 	}
+	cs.options = options
 
-	if cs.options.ConnMaxIdleTime > 0 {
-		db.SetConnMaxIdleTime(cs.options.ConnMaxIdleTime)
-	} else {
-		db.SetConnMaxIdleTime(5 * time.Minute)
+	// connection pool limits take effect immediately
+	if cs.db != nil && !cs.shared {
+		configurePool(cs.db, options.MaxOpenConns, options.MaxIdleConns, options.ConnMaxIdleTime, options.ConnMaxLifetime)
 	}
+	return nil
+}
 
-	if cs.options.ConnMaxLifetime > 0 {
-		db.SetConnMaxLifetime(cs.options.ConnMaxLifetime)
+func (cs *commandStoreSQLite) connect(ctx context.Context) (*sql.DB, error) {
+	return cs.connectTo(ctx, dbPath(cs.path, cs.fsCopy))
+}
+
+func (cs *commandStoreSQLite) connectTo(ctx context.Context, path string) (*sql.DB, error) {
+	db, err := sql.Open(driverName(cs.cfg().DriverName), cs.cfg().Timeouts.dsn(path, cs.cfg().ReadProfile.pragmas()...))
+	if err != nil {
+		return nil, err
 	}
+	// WAL mode allows concurrent readers while a single writer holds the lock.
+	options := cs.opts()
+	configurePool(db, options.MaxOpenConns, options.MaxIdleConns, options.ConnMaxIdleTime, options.ConnMaxLifetime)
 
 	// set sqlite specific pragmas
 	query := `
 		PRAGMA journal_mode=WAL;
 		PRAGMA synchronous=NORMAL;
 		PRAGMA foreign_keys=1;
-		PRAGMA busy_timeout=5000;
 		`
 	if _, err := db.ExecContext(context.Background(), query); err != nil {
 		return nil, err
@@ -77,20 +354,92 @@ func (cs *commandStoreSQLite) connect(ctx context.Context) (*sql.DB, error) {
 	return db, nil
 }
 
-func (cs *commandStoreSQLite) migrate(ctx context.Context) error {
-	query := `
-	CREATE TABLE IF NOT EXISTS commands (id INTEGER,
-		instance_id INTEGER,
-		uuid TEXT,
-		tenant_uuid TEXT,
+var commandTables = []strictTable{
+	{
+		name: "commands",
+		columns: `id INTEGER,
+		instance_id INTEGER NOT NULL,
+		uuid TEXT NOT NULL,
+		tenant_uuid TEXT NOT NULL,
 		workspace_uuid TEXT,
-		domain TEXT,
-		created_at INTEGER,
-		data_type TEXT,
-		data_bytes TEXT,
+		domain TEXT NOT NULL,
+		created_at INTEGER NOT NULL,
+		data_type TEXT NOT NULL,
+		data_bytes BLOB NOT NULL,
 		req_ctx TEXT,
-		PRIMARY KEY (id)
-	);
+		checksum TEXT,
+		status TEXT NOT NULL DEFAULT 'pending',
+		processed_at INTEGER NOT NULL DEFAULT 0,
+		error_text TEXT NOT NULL DEFAULT '',
+		is_encrypted INTEGER,
+		idempotency_key TEXT,
+		causation_event_uuid TEXT,
+		PRIMARY KEY (id)`,
+		copyColumns: `id, COALESCE(instance_id, 0), uuid, COALESCE(tenant_uuid, ''), workspace_uuid, COALESCE(domain, ''),
+		COALESCE(created_at, 0), COALESCE(data_type, ''), CAST(COALESCE(data_bytes, '') AS BLOB), req_ctx, checksum,
+		COALESCE(status, 'pending'), COALESCE(processed_at, 0), COALESCE(error_text, ''), is_encrypted, idempotency_key, causation_event_uuid`,
+	},
+}
+
+func (cs *commandStoreSQLite) migrate(ctx context.Context) error {
+	return cs.migrateDB(ctx, cs.db)
+}
+
+func (cs *commandStoreSQLite) migrateDB(ctx context.Context, db *sql.DB) error {
+	return migrateTx(ctx, db, func(tx *sql.Tx) error {
+		if err := stampFormatVersion(ctx, tx); err != nil {
+			return err
+		}
+		// migrate existing databases: add columns introduced after the first
+		// release, so legacy tables can be rebuilt as STRICT as a whole
+		var exists int
+		if err := tx.QueryRowContext(ctx, `SELECT COUNT(*) FROM sqlite_master WHERE type='table' AND name='commands'`).Scan(&exists); err != nil {
+			return err
+		}
+		for _, column := range [][2]string{
+			{"workspace_uuid", "TEXT"},
+			{"checksum", "TEXT"},
+			{"status", "TEXT NOT NULL DEFAULT 'pending'"},
+			{"processed_at", "INTEGER NOT NULL DEFAULT 0"},
+			{"error_text", "TEXT NOT NULL DEFAULT ''"},
+			// existing records keep an unknown encryption state (NULL)
+			{"is_encrypted", "INTEGER"},
+			{"idempotency_key", "TEXT"},
+			{"causation_event_uuid", "TEXT"},
+		} {
+			if exists == 0 {
+				break
+			}
+			var count int
+			if err := tx.QueryRowContext(ctx, `SELECT COUNT(*) FROM pragma_table_info('commands') WHERE name=?`, column[0]).Scan(&count); err != nil {
+				return err
+			}
+			if count == 0 {
+				if _, err := tx.ExecContext(ctx, fmt.Sprintf(`ALTER TABLE commands ADD COLUMN %s %s`, column[0], column[1])); err != nil {
+					return err
+				}
+			}
+		}
+
+		tables := append(append(commandTables, auditTables...), commandReplayTables...)
+		if cs.cfg().Metrics != nil {
+			tables = append(tables, metricsTables...)
+		}
+		if err := migrateStrictTables(ctx, tx, loggerOrDiscard(cs.cfg().Logger), tables...); err != nil {
+			return err
+		}
+		// a reset is recorded in the recreated database
+		if pending := cs.pendingAudit.Swap(nil); pending != nil {
+			if err := insertAuditEntry(ctx, tx, pending); err != nil {
+				return err
+			}
+		}
+		_, err := tx.ExecContext(ctx, commandIndexSchema)
+		return err
+	})
+}
+
+const commandIndexSchema = `
 	CREATE INDEX IF NOT EXISTS "tenant_index" ON "commands" (
 		"tenant_uuid" ASC
 	);
@@ -103,48 +452,107 @@ func (cs *commandStoreSQLite) migrate(ctx context.Context) error {
 	CREATE INDEX IF NOT EXISTS "created_at_index" ON "commands" (
 		"created_at" ASC
 	);
-	`
-	if _, err := cs.db.ExecContext(ctx, query); err != nil {
-		return err
-	}
-	// migrate existing databases: add workspace_uuid column if it doesn't exist
-	var count int
-	if err := cs.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM pragma_table_info('commands') WHERE name='workspace_uuid'`).Scan(&count); err != nil {
-		return err
-	}
-	if count == 0 {
-		if _, err := cs.db.ExecContext(ctx, `ALTER TABLE commands ADD COLUMN workspace_uuid TEXT`); err != nil {
-			return err
-		}
-	}
-	return nil
-}
+	CREATE INDEX IF NOT EXISTS "status_index" ON "commands" (
+		"status" ASC
+	);
+	CREATE UNIQUE INDEX IF NOT EXISTS "idempotency_key_index" ON "commands" (
+		"tenant_uuid" ASC, "idempotency_key" ASC
+	) WHERE "idempotency_key" IS NOT NULL;
+	CREATE INDEX IF NOT EXISTS "causation_event_index" ON "commands" (
+		"causation_event_uuid" ASC
+	) WHERE "causation_event_uuid" IS NOT NULL;
+	CREATE INDEX IF NOT EXISTS "domain_index" ON "commands" (
+		"domain" ASC
+	);
+	CREATE INDEX IF NOT EXISTS "data_type_index" ON "commands" (
+		"data_type" ASC
+	);
+	CREATE INDEX IF NOT EXISTS "domain_nocase_index" ON "commands" (
+		"domain" COLLATE NOCASE ASC
+	);
+	CREATE INDEX IF NOT EXISTS "data_type_nocase_index" ON "commands" (
+		"data_type" COLLATE NOCASE ASC
+	);
+`
 
 // fullfilling CommandStore interface
 func (cs *commandStoreSQLite) Init(ctx context.Context, opts ...comby.CommandStoreOption) error {
+	cs.mu.Lock()
 	for _, opt := range opts {
 		if _, err := opt(&cs.options); err != nil {
+			cs.mu.Unlock()
 			return err
 		}
 	}
+	cs.mu.Unlock()
 
 	// connect to db (or create new one)
-	if db, err := cs.connect(ctx); err != nil {
-		return err
-	} else {
-		cs.db = db
+	if !cs.shared {
+		if err := cs.initFS(); err != nil {
+			return err
+		}
+		if db, err := cs.connect(ctx); err != nil {
+			return classifyError(err)
+		} else {
+			cs.db = db
+		}
+	}
+
+	// databases of newer releases are neither migrated nor read
+	if err := checkFormatVersion(ctx, cs.db); err != nil {
+		return fmt.Errorf("'%s' failed to init - %w", cs.String(), classifyError(err))
 	}
 
 	// auto-migrate table
-	if !cs.options.ReadOnly {
+	if !cs.opts().ReadOnly {
 		if err := cs.migrate(ctx); err != nil {
+			return classifyError(err)
+		}
+		if err := cs.startLoops(ctx); err != nil {
 			return err
 		}
+		return cs.initPreflight(ctx)
 	}
-	return nil
+
+	// read-only stores can not migrate, but reads select all current columns
+	if ok, err := hasColumn(ctx, cs.db, "commands", "status"); err != nil {
+		return err
+	} else if !ok {
+		return fmt.Errorf("'%s' failed to init - database needs migration, init once without read-only", cs.String())
+	}
+	cs.initChangePolling()
+	cs.initKeepalive()
+	return cs.initPreflight(ctx)
+}
+
+func (cs *commandStoreSQLite) Create(ctx context.Context, opts ...comby.CommandStoreCreateOption) (err error) {
+	defer wrapOpError(&err, "create", "commands", time.Now(), func() (string, []string) {
+		return commandUuidOf(optionsOf(opts).Command), nil
+	})
+	ctx, cancel := cs.cfg().Timeouts.write(ctx)
+	defer cancel()
+	ctx, finish := cs.watch(ctx, "create")
+	defer finish(&err)
+	if batch := cs.bulk.Load(); batch != nil {
+		return batch.write(ctx, func(tx *sql.Tx) error {
+			return cs.create(ctx, tx, opts...)
+		})
+	}
+	if err := cs.cfg().Quota.check(ctx, cs.db); err != nil {
+		return fmt.Errorf("'%s' failed to create command - %w", cs.String(), err)
+	}
+	done, err := cs.beginWrite(ctx)
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	return runTx(ctx, cs.db, func(tx *sql.Tx) error {
+		return cs.create(ctx, tx, opts...)
+	})
 }
 
-func (cs *commandStoreSQLite) Create(ctx context.Context, opts ...comby.CommandStoreCreateOption) error {
+func (cs *commandStoreSQLite) create(ctx context.Context, q queryer, opts ...comby.CommandStoreCreateOption) error {
 	createOpts := comby.CommandStoreCreateOptions{
 		Command: nil,
 	}
@@ -153,16 +561,35 @@ func (cs *commandStoreSQLite) Create(ctx context.Context, opts ...comby.CommandS
 			return err
 		}
 	}
-	if cs.options.ReadOnly {
+	if cs.opts().ReadOnly {
 		return fmt.Errorf("'%s' failed to create command - instance is readonly", cs.String())
 	}
+
 	var cmd comby.Command = createOpts.Command
 	if cmd == nil {
 		return fmt.Errorf("'%s' failed to create command - command is nil", cs.String())
 	}
+	if err := checkTenant(cs.cfg().Tenant, cmd.GetTenantUuid()); err != nil {
+		return fmt.Errorf("'%s' failed to create command - %w", cs.String(), err)
+	}
+	if err := cs.authorizeCommand(ctx, OperationCreate, cmd); err != nil {
+		return err
+	}
+	if gen := cs.cfg().UuidGenerator; gen != nil && len(cmd.GetCommandUuid()) < 1 {
+		cmd.SetCommandUuid(gen())
+	}
 	if len(cmd.GetCommandUuid()) < 1 {
 		return fmt.Errorf("'%s' failed to create command - command uuid is invalid", cs.String())
 	}
+	key := sql.NullString{String: idempotencyKey(createOpts, cmd)}
+	if key.Valid = len(key.String) > 0; key.Valid {
+		if err := idempotencyConflict(ctx, q, cmd.GetTenantUuid(), key.String); err != nil {
+			return err
+		}
+	}
+
+	causation := sql.NullString{String: createAttribute(createOpts, cmd, CausationEventAttribute)}
+	causation.Valid = len(causation.String) > 0
 
 	// sql statement
 	dbRecord, err := internal.BaseCommandToDbCommand(cmd)
@@ -170,24 +597,18 @@ func (cs *commandStoreSQLite) Create(ctx context.Context, opts ...comby.CommandS
 		return err
 	}
 
+	// checksum of plain domain data
+	if dbRecord.Checksum, err = internal.Checksum(cs.cfg().ChecksumAlgorithm, dbRecord.DataBytes); err != nil {
+		return err
+	}
+
 	// encrypt domain data if crypto service is provided
-	if cs.options.CryptoService != nil {
-		if err := cs.encryptDomainData(dbRecord); err != nil {
+	if cs.cipher() != nil {
+		if err := cs.encryptDomainData(ctx, dbRecord); err != nil {
 			return err
 		}
 	}
 
-	// sql begin transaction
-	tx, err := cs.db.Begin()
-	if err != nil {
-		return err
-	}
-	defer func() {
-		if err != nil {
-			tx.Rollback()
-		}
-	}()
-
 	query := `INSERT INTO commands (
 		instance_id,
 		uuid,
@@ -197,10 +618,14 @@ func (cs *commandStoreSQLite) Create(ctx context.Context, opts ...comby.CommandS
 		created_at,
 		data_type,
 		data_bytes,
-		req_ctx
-	) VALUES (?,?,?,?,?,?,?,?,?);`
+		req_ctx,
+		checksum,
+		is_encrypted,
+		idempotency_key,
+		causation_event_uuid
+	) VALUES (?,?,?,?,?,?,?,?,?,?,?,?,?);`
 
-	_, err = tx.ExecContext(
+	_, err = q.ExecContext(
 		ctx,
 		query,
 		dbRecord.InstanceId,
@@ -212,15 +637,28 @@ func (cs *commandStoreSQLite) Create(ctx context.Context, opts ...comby.CommandS
 		dbRecord.DataType,
 		dbRecord.DataBytes,
 		dbRecord.ReqCtx,
+		dbRecord.Checksum,
+		dbRecord.IsEncrypted,
+		key,
+		causation,
 	)
-	if err != nil {
-		return err
+	if err != nil && key.Valid {
+		// the key may have been used by another process meanwhile
+		if conflict := idempotencyConflict(ctx, q, cmd.GetTenantUuid(), key.String); conflict != nil {
+			return conflict
+		}
 	}
-
-	return tx.Commit()
+	return err
 }
 
-func (cs *commandStoreSQLite) Get(ctx context.Context, opts ...comby.CommandStoreGetOption) (comby.Command, error) {
+func (cs *commandStoreSQLite) Get(ctx context.Context, opts ...comby.CommandStoreGetOption) (_ comby.Command, err error) {
+	defer wrapOpError(&err, "get", "commands", time.Now(), func() (string, []string) {
+		return optionsOf(opts).CommandUuid, nil
+	})
+	ctx, cancel := cs.cfg().Timeouts.read(ctx)
+	defer cancel()
+	ctx, finish := cs.watch(ctx, "get")
+	defer finish(&err)
 	getOpts := comby.CommandStoreGetOptions{}
 	for _, opt := range opts {
 		if _, err := opt(&getOpts); err != nil {
@@ -228,32 +666,29 @@ func (cs *commandStoreSQLite) Get(ctx context.Context, opts ...comby.CommandStor
 		}
 	}
 
-	if len(getOpts.CommandUuid) == 0 {
-		return nil, fmt.Errorf("'%s' failed to get command - command uuid is required", cs.String())
+	if err := requireUuid("command", getOpts.CommandUuid); err != nil {
+		return nil, fmt.Errorf("'%s' failed to get command - %w", cs.String(), err)
+	}
+	cmd, err := cs.get(ctx, cs.db, getOpts.CommandUuid)
+	if err != nil {
+		return nil, classifyError(err)
+	}
+	if err := cs.authorizeGet(ctx, getOpts.CommandUuid, cmd); err != nil {
+		return nil, err
 	}
+	return cmd, nil
+}
 
-	query := `SELECT id, instance_id, uuid, tenant_uuid, COALESCE(workspace_uuid, ''), domain, created_at,
-		data_type, data_bytes, req_ctx
-		FROM commands WHERE uuid=? LIMIT 1;`
-	row := cs.db.QueryRowContext(ctx, query, getOpts.CommandUuid)
+func (cs *commandStoreSQLite) get(ctx context.Context, q queryer, commandUuid string) (comby.Command, error) {
+	query := fmt.Sprintf("SELECT %s FROM commands WHERE uuid=? LIMIT 1;", commandSelectColumns)
+	row := q.QueryRowContext(ctx, query, commandUuid)
 	if row.Err() != nil {
 		return nil, row.Err()
 	}
 
 	// extract record
 	var dbRecord internal.Command
-	if err := row.Scan(
-		&dbRecord.ID,
-		&dbRecord.InstanceId,
-		&dbRecord.Uuid,
-		&dbRecord.TenantUuid,
-		&dbRecord.WorkspaceUuid,
-		&dbRecord.Domain,
-		&dbRecord.CreatedAt,
-		&dbRecord.DataType,
-		&dbRecord.DataBytes,
-		&dbRecord.ReqCtx,
-	); err != nil {
+	if err := scanCommand(row, &dbRecord); err != nil {
 		// Catch errors
 		switch {
 		case err == sql.ErrNoRows:
@@ -263,11 +698,14 @@ func (cs *commandStoreSQLite) Get(ctx context.Context, opts ...comby.CommandStor
 		}
 	}
 
-	// decrypt domain data if crypto service is provided
-	if cs.options.CryptoService != nil {
-		if err := cs.decryptDomainData(&dbRecord); err != nil {
-			return nil, err
-		}
+	// records of other tenants do not exist for a bound store
+	if checkTenant(cs.cfg().Tenant, dbRecord.TenantUuid) != nil {
+		return nil, nil
+	}
+
+	// decrypt and verify domain data
+	if err := cs.decodeDomainData(ctx, &dbRecord); err != nil {
+		return nil, err
 	}
 
 	// db record to command
@@ -278,154 +716,138 @@ func (cs *commandStoreSQLite) Get(ctx context.Context, opts ...comby.CommandStor
 	return cmd, err
 }
 
-func (cs *commandStoreSQLite) List(ctx context.Context, opts ...comby.CommandStoreListOption) ([]comby.Command, int64, error) {
-	listOpts := comby.CommandStoreListOptions{
-		Before:    -1,
-		After:     -1,
-		Offset:    0,
-		Limit:     100,
-		OrderBy:   "created_at",
-		Ascending: true,
+func (cs *commandStoreSQLite) List(ctx context.Context, opts ...comby.CommandStoreListOption) (_ []comby.Command, _ int64, err error) {
+	defer wrapOpError(&err, "list", "commands", time.Now(), func() (string, []string) {
+		return "", cs.describeCommandList(opts)
+	})
+	ctx, cancel := cs.cfg().Timeouts.read(ctx)
+	defer cancel()
+	ctx, finish := cs.watch(ctx, "list")
+	defer finish(&err)
+	listOpts, filter, err := cs.listOptions(opts)
+	if err != nil {
+		return nil, 0, err
 	}
-	for _, opt := range opts {
-		if _, err := opt(&listOpts); err != nil {
-			return nil, 0, err
-		}
+	if err := cs.authorizeList(ctx, listOpts, filter); err != nil {
+		return nil, 0, err
 	}
+	cmds, total, err := cs.list(ctx, cs.db, listOpts, filter)
+	return cmds, total, classifyError(err)
+}
 
-	var whereSQL string = ""
-	var whereList []string = []string{}
-	var args []any
-	if len(listOpts.TenantUuid) > 0 {
-		whereList = append(whereList, "tenant_uuid=?")
-		args = append(args, listOpts.TenantUuid)
-	}
-	if len(listOpts.Domain) > 0 {
-		whereList = append(whereList, "domain=?")
-		args = append(args, listOpts.Domain)
-	}
-	if len(listOpts.DataType) > 0 {
-		whereList = append(whereList, "data_type=?")
-		args = append(args, listOpts.DataType)
+// commandFilter holds sqlite specific filters of commands, which are not part
+// of comby.CommandStoreListOptions.
+type commandFilter struct {
+	listFilter
+	Status string
+	// keyset pagination: uuids after (or before, if descending) this one
+	AfterUuid string
+	// skip the count query, the total is -1
+	SkipTotal bool
+}
+
+func (cs *commandStoreSQLite) list(ctx context.Context, q queryer, listOpts comby.CommandStoreListOptions, filter commandFilter) ([]comby.Command, int64, error) {
+	maxLimit := cs.cfg().MaxListLimit
+	capped := maxLimit > 0 && listOpts.Limit < 0
+	if capped {
+		listOpts.Limit = maxLimit + 1
 	}
-	if listOpts.Before >= 0 {
-		whereList = append(whereList, "created_at<?")
-		args = append(args, listOpts.Before)
+	var dbRecords []*internal.Command
+	total, err := cs.eachRecord(ctx, q, listOpts, filter, func(dbRecord *internal.Command) error {
+		dbRecords = append(dbRecords, dbRecord)
+		return nil
+	})
+	if err != nil {
+		return nil, 0, err
 	}
-	if listOpts.After >= 0 {
-		whereList = append(whereList, "created_at>?")
-		args = append(args, listOpts.After)
+	if capped && int64(len(dbRecords)) > maxLimit {
+		return nil, 0, fmt.Errorf("%w - more than %d commands match", ErrListLimitExceeded, maxLimit)
 	}
 
-	// note the first empty character(s) below
-	for index, where := range whereList {
-		if index == 0 {
-			whereSQL = fmt.Sprintf(" WHERE %s", where)
-		} else {
-			whereSQL = fmt.Sprintf("%s AND %s", whereSQL, where)
+	// decrypt and verify domain data
+	for _, dbRecord := range dbRecords {
+		if err := cs.decodeDomainData(ctx, dbRecord); err != nil {
+			return nil, 0, err
 		}
 	}
 
-	// count the total number of records for this query
-	var queryTotal int64
-	var queryTotalQuery string = fmt.Sprintf("SELECT COUNT(id) FROM commands%s;", whereSQL)
-	var row *sql.Row
-	if len(args) > 0 {
-		row = cs.db.QueryRowContext(ctx, queryTotalQuery, args...)
-	} else {
-		row = cs.db.QueryRowContext(ctx, queryTotalQuery)
-	}
-	if err := row.Err(); err != nil {
-		return nil, 0, err
-	}
-	// extract record
-	if err := row.Scan(&queryTotal); err != nil {
+	// convert
+	cmds, err := internal.DbCommandsToBaseCommands(dbRecords)
+	if err != nil {
 		return nil, 0, err
 	}
+	return cmds, total, err
+}
 
-	// prepare orderby
-	var orderBySQL string = ""
-	if len(listOpts.OrderBy) > 0 {
-		if listOpts.Ascending {
-			orderBySQL = fmt.Sprintf(" ORDER BY %s ASC", listOpts.OrderBy)
-		} else {
-			orderBySQL = fmt.Sprintf(" ORDER BY %s DESC", listOpts.OrderBy)
-		}
-	}
+// eachRecord calls fn with each record of a page as stored while the rows
+// are read and returns the total number of matching commands.
+func (cs *commandStoreSQLite) eachRecord(ctx context.Context, q queryer, listOpts comby.CommandStoreListOptions, filter commandFilter, fn func(dbRecord *internal.Command) error) (int64, error) {
+	query := commandListQuery(listOpts, filter, cs.cfg().Tenant)
 
-	// prepare limit/offset
-	var limitSQL string = ""
-	var offsetSQL string = ""
-	if listOpts.Limit >= 0 {
-		limitSQL = fmt.Sprintf(" LIMIT %d", listOpts.Limit)
-	}
-	if listOpts.Offset >= 0 {
-		offsetSQL = fmt.Sprintf(" OFFSET %d", listOpts.Offset)
+	// count the total number of records for this query
+	var queryTotal int64 = -1
+	if !filter.SkipTotal {
+		row := q.QueryRowContext(ctx, query.countSQL, query.args...)
+		if err := row.Err(); err != nil {
+			return 0, err
+		}
+		// extract record
+		if err := row.Scan(&queryTotal); err != nil {
+			return 0, err
+		}
 	}
 
-	var query string = fmt.Sprintf("SELECT id, instance_id, uuid, tenant_uuid, COALESCE(workspace_uuid, ''), domain, created_at, data_type, data_bytes, req_ctx FROM commands%s%s%s%s;", whereSQL, orderBySQL, limitSQL, offsetSQL)
-	var rows *sql.Rows
-	var err error
-	if len(args) > 0 {
-		rows, err = cs.db.QueryContext(ctx, query, args...)
-	} else {
-		rows, err = cs.db.QueryContext(ctx, query)
-	}
+	rows, err := q.QueryContext(ctx, query.selectSQL, query.args...)
 	switch {
 	case err == sql.ErrNoRows:
-		return nil, queryTotal, nil
+		return queryTotal, nil
 	case err != nil:
-		return nil, 0, err
+		return 0, err
 	}
 	if rows != nil {
 		defer rows.Close()
 	}
 
 	// extract results
-	var dbRecords []*internal.Command
+	scanner := newPayloadScanner(rows)
 	for rows.Next() {
 		var dbRecord internal.Command
-		if err := rows.Scan(
-			&dbRecord.ID,
-			&dbRecord.InstanceId,
-			&dbRecord.Uuid,
-			&dbRecord.TenantUuid,
-			&dbRecord.WorkspaceUuid,
-			&dbRecord.Domain,
-			&dbRecord.CreatedAt,
-			&dbRecord.DataType,
-			&dbRecord.DataBytes,
-			&dbRecord.ReqCtx,
-		); err != nil {
-			return nil, 0, err
+		if err := scanCommand(scanner, &dbRecord); err != nil {
+			return 0, err
+		}
+		if err := fn(&dbRecord); err != nil {
+			return 0, err
 		}
-		dbRecords = append(dbRecords, &dbRecord)
 	}
 	if err := rows.Close(); err != nil {
-		return nil, 0, err
+		return 0, err
 	}
 	if err := rows.Err(); err != nil {
-		return nil, 0, err
-	}
-
-	// decrypt domain data if crypto service is provided
-	if cs.options.CryptoService != nil {
-		for _, dbRecord := range dbRecords {
-			if err := cs.decryptDomainData(dbRecord); err != nil {
-				return nil, 0, err
-			}
-		}
+		return 0, err
 	}
+	return queryTotal, nil
+}
 
-	// convert
-	cmds, err := internal.DbCommandsToBaseCommands(dbRecords)
+func (cs *commandStoreSQLite) Update(ctx context.Context, opts ...comby.CommandStoreUpdateOption) (err error) {
+	defer wrapOpError(&err, "update", "commands", time.Now(), func() (string, []string) {
+		return commandUuidOf(optionsOf(opts).Command), nil
+	})
+	ctx, cancel := cs.cfg().Timeouts.write(ctx)
+	defer cancel()
+	ctx, finish := cs.watch(ctx, "update")
+	defer finish(&err)
+	done, err := cs.beginWrite(ctx)
 	if err != nil {
-		return nil, 0, err
+		return err
 	}
-	return cmds, queryTotal, err
+	defer done()
+
+	return runTx(ctx, cs.db, func(tx *sql.Tx) error {
+		return cs.update(ctx, tx, opts...)
+	})
 }
 
-func (cs *commandStoreSQLite) Update(ctx context.Context, opts ...comby.CommandStoreUpdateOption) error {
+func (cs *commandStoreSQLite) update(ctx context.Context, q queryer, opts ...comby.CommandStoreUpdateOption) error {
 	updateOpts := comby.CommandStoreUpdateOptions{
 		Command: nil,
 	}
@@ -434,9 +856,10 @@ func (cs *commandStoreSQLite) Update(ctx context.Context, opts ...comby.CommandS
 			return err
 		}
 	}
-	if cs.options.ReadOnly {
+	if cs.opts().ReadOnly {
 		return fmt.Errorf("'%s' failed to update command - instance is readonly", cs.String())
 	}
+
 	var cmd comby.Command = updateOpts.Command
 	if cmd == nil {
 		return fmt.Errorf("'%s' failed to update command - command is nil", cs.String())
@@ -444,6 +867,15 @@ func (cs *commandStoreSQLite) Update(ctx context.Context, opts ...comby.CommandS
 	if len(cmd.GetCommandUuid()) < 1 {
 		return fmt.Errorf("'%s' failed to update command - command uuid is invalid", cs.String())
 	}
+	if err := checkTenant(cs.cfg().Tenant, cmd.GetTenantUuid()); err != nil {
+		return fmt.Errorf("'%s' failed to update command - %w", cs.String(), err)
+	}
+	if err := checkStoredTenant(ctx, q, cs.cfg().Tenant, "commands", cmd.GetCommandUuid()); err != nil {
+		return fmt.Errorf("'%s' failed to update command - %w", cs.String(), err)
+	}
+	if err := cs.authorizeStored(ctx, q, OperationUpdate, cmd.GetCommandUuid()); err != nil {
+		return err
+	}
 
 	// convert to db format
 	dbRecord, err := internal.BaseCommandToDbCommand(cmd)
@@ -451,24 +883,18 @@ func (cs *commandStoreSQLite) Update(ctx context.Context, opts ...comby.CommandS
 		return err
 	}
 
+	// checksum of plain domain data
+	if dbRecord.Checksum, err = internal.Checksum(cs.cfg().ChecksumAlgorithm, dbRecord.DataBytes); err != nil {
+		return err
+	}
+
 	// encrypt domain data if crypto service is provided
-	if cs.options.CryptoService != nil {
-		if err := cs.encryptDomainData(dbRecord); err != nil {
+	if cs.cipher() != nil {
+		if err := cs.encryptDomainData(ctx, dbRecord); err != nil {
 			return err
 		}
 	}
 
-	// sql begin transaction
-	tx, err := cs.db.Begin()
-	if err != nil {
-		return err
-	}
-	defer func() {
-		if err != nil {
-			tx.Rollback()
-		}
-	}()
-
 	query := `UPDATE commands SET
 		instance_id=?,
 		tenant_uuid=?,
@@ -477,10 +903,12 @@ func (cs *commandStoreSQLite) Update(ctx context.Context, opts ...comby.CommandS
 		created_at=?,
 		data_type=?,
 		data_bytes=?,
-		req_ctx=?
+		req_ctx=?,
+		checksum=?,
+		is_encrypted=?
 	 WHERE uuid=?;`
 
-	_, err = tx.ExecContext(ctx,
+	_, err = q.ExecContext(ctx,
 		query,
 		dbRecord.InstanceId,
 		dbRecord.TenantUuid,
@@ -490,52 +918,80 @@ func (cs *commandStoreSQLite) Update(ctx context.Context, opts ...comby.CommandS
 		dbRecord.DataType,
 		dbRecord.DataBytes,
 		dbRecord.ReqCtx,
+		dbRecord.Checksum,
+		dbRecord.IsEncrypted,
 		dbRecord.Uuid)
+	return err
+}
+
+func (cs *commandStoreSQLite) Delete(ctx context.Context, opts ...comby.CommandStoreDeleteOption) (err error) {
+	defer wrapOpError(&err, "delete", "commands", time.Now(), func() (string, []string) {
+		return optionsOf(opts).CommandUuid, nil
+	})
+	ctx, cancel := cs.cfg().Timeouts.write(ctx)
+	defer cancel()
+	ctx, finish := cs.watch(ctx, "delete")
+	defer finish(&err)
+	done, err := cs.beginWrite(ctx)
 	if err != nil {
 		return err
 	}
-
-	return tx.Commit()
+	defer done()
+	return classifyError(cs.delete(ctx, cs.db, opts...))
 }
 
-func (cs *commandStoreSQLite) Delete(ctx context.Context, opts ...comby.CommandStoreDeleteOption) error {
+func (cs *commandStoreSQLite) delete(ctx context.Context, q queryer, opts ...comby.CommandStoreDeleteOption) error {
 	deleteOpts := comby.CommandStoreDeleteOptions{}
 	for _, opt := range opts {
 		if _, err := opt(&deleteOpts); err != nil {
 			return err
 		}
 	}
-	if cs.options.ReadOnly {
+	if cs.opts().ReadOnly {
 		return fmt.Errorf("'%s' failed to delete command - instance is readonly", cs.String())
 	}
+
 	var commandUuid string = deleteOpts.CommandUuid
-	if len(commandUuid) < 1 {
-		return fmt.Errorf("'%s' failed to delete command - command uuid '%s' is invalid", cs.String(), commandUuid)
+	if err := requireUuid("command", commandUuid); err != nil {
+		return fmt.Errorf("'%s' failed to delete command - %w", cs.String(), err)
+	}
+	if err := checkStoredTenant(ctx, q, cs.cfg().Tenant, "commands", commandUuid); err != nil {
+		return fmt.Errorf("'%s' failed to delete command - %w", cs.String(), err)
+	}
+	if err := cs.authorizeStored(ctx, q, OperationDelete, commandUuid); err != nil {
+		return err
 	}
 
-	_, err := cs.db.ExecContext(ctx, "DELETE FROM commands WHERE uuid=?;", commandUuid)
+	_, err := q.ExecContext(ctx, "DELETE FROM commands WHERE uuid=?;", commandUuid)
 	return err
 }
 
 func (cs *commandStoreSQLite) Total(ctx context.Context) int64 {
-	// run query (no args to not using prepared statement)
-	row := cs.db.QueryRowContext(ctx, `SELECT COUNT(id) FROM commands;`)
-	if err := row.Err(); err != nil {
-		return 0
-	}
-	// extract record
-	var dbTotal int64
-	if err := row.Scan(&dbTotal); err != nil {
-		return 0
-	}
-	return dbTotal
+	return countRows(ctx, cs.db, "commands", cs.cfg().Tenant)
 }
 
 func (cs *commandStoreSQLite) Close(ctx context.Context) error {
-	return cs.db.Close()
+	if batch := cs.bulk.Swap(nil); batch != nil {
+		if err := batch.flush(); err != nil {
+			return err
+		}
+	}
+	cs.stopLoops()
+	if !cs.opts().ReadOnly {
+		if err := cs.cfg().Metrics.flush(ctx, cs.db, cs.writeMu, "commands"); err != nil {
+			loggerOrDiscard(cs.cfg().Logger).ErrorContext(ctx, "metrics flush failed", "error", err)
+		}
+	}
+	if cs.shared {
+		return nil
+	}
+	if err := cs.db.Close(); err != nil {
+		return err
+	}
+	return removeFSCopy(&cs.fsCopy)
 }
 func (cs *commandStoreSQLite) Options() comby.CommandStoreOptions {
-	return cs.options
+	return cs.opts()
 }
 
 func (cs *commandStoreSQLite) String() string {
@@ -543,8 +999,10 @@ func (cs *commandStoreSQLite) String() string {
 }
 
 func (cs *commandStoreSQLite) Info(ctx context.Context) (*comby.CommandStoreInfoModel, error) {
+	// a store bound to a tenant only describes its commands
+	whereList, args := tenantCondition(cs.cfg().Tenant, nil, nil)
 
-	row := cs.db.QueryRowContext(ctx, "SELECT COUNT(uuid) FROM commands;")
+	row := cs.db.QueryRowContext(ctx, fmt.Sprintf("SELECT COUNT(uuid) FROM commands%s;", whereSQL(whereList)), args...)
 	if err := row.Err(); err != nil {
 		return nil, err
 	}
@@ -554,7 +1012,7 @@ func (cs *commandStoreSQLite) Info(ctx context.Context) (*comby.CommandStoreInfo
 		return nil, err
 	}
 
-	row = cs.db.QueryRowContext(ctx, "SELECT COALESCE(MAX(created_at), 0) FROM commands;")
+	row = cs.db.QueryRowContext(ctx, fmt.Sprintf("SELECT COALESCE(MAX(created_at), 0) FROM commands%s;", whereSQL(whereList)), args...)
 	if err := row.Err(); err != nil {
 		return nil, err
 	}
@@ -573,9 +1031,14 @@ func (cs *commandStoreSQLite) Info(ctx context.Context) (*comby.CommandStoreInfo
 }
 
 func (cs *commandStoreSQLite) Reset(ctx context.Context) error {
-	if cs.options.ReadOnly {
+	if cs.opts().ReadOnly {
 		return fmt.Errorf("'%s' failed to reset - instance is readonly", cs.String())
 	}
+	var numRecords int64
+	if cs.db != nil {
+		numRecords = countRows(ctx, cs.db, "commands", "")
+	}
+	cs.pendingAudit.Store(newAuditEntry(ctx, "commands", AdminOperationReset, "", numRecords))
 
 	//try to delete all files
 	files, err := filepath.Glob(cs.path + "*")
@@ -591,37 +1054,85 @@ func (cs *commandStoreSQLite) Reset(ctx context.Context) error {
 	return nil
 }
 
-func (cs *commandStoreSQLite) encryptDomainData(dbRecord *internal.Command) error {
-	if cs.options.CryptoService == nil {
+// decodeDomainData decrypts domain data if the record is encrypted and
+// verifies its checksum if enabled.
+func (cs *commandStoreSQLite) decodeDomainData(ctx context.Context, dbRecord *internal.Command) error {
+	if isEncrypted(dbRecord.IsEncrypted, cs.cipher() != nil) {
+		if err := cs.decryptDomainData(ctx, dbRecord); err != nil {
+			return err
+		}
+	}
+	if cs.cfg().VerifyChecksum {
+		if err := cs.verifyChecksum(dbRecord); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// cipher returns the cipher payloads are encrypted with, nil if the store does not encrypt.
+func (cs *commandStoreSQLite) cipher() payloadCipher {
+	return newPayloadCipher(cs.cfg().KeyProvider, cs.cfg().EncryptionAlgorithm, cs.opts().CryptoService)
+}
+
+func (cs *commandStoreSQLite) encryptDomainData(ctx context.Context, dbRecord *internal.Command) error {
+	c := cs.cipher()
+	if c == nil {
 		return fmt.Errorf("'%s' failed - crypto service is nil", cs.String())
 	}
-	domainData := []byte(dbRecord.DataBytes)
+	encrypt := func(data []byte) ([]byte, error) { return c.Encrypt(ctx, data) }
+	domainData := dbRecord.DataBytes
 	if len(domainData) < 1 {
 		return fmt.Errorf("'%s' failed - domain data is empty", cs.String())
 	}
-	if encryptedData, err := cs.options.CryptoService.Encrypt(domainData); err != nil {
+	if paths := cs.cfg().FieldEncryption[dbRecord.DataType]; len(paths) > 0 {
+		encryptedData, err := internal.EncryptFields(domainData, paths, encrypt)
+		if err != nil {
+			return fmt.Errorf("'%s' failed - failed to encrypt domain data fields: %w", cs.String(), err)
+		}
+		dbRecord.DataBytes = encryptedData
+		dbRecord.IsEncrypted = internal.NullBool{Bool: true, Valid: true}
+		return nil
+	}
+	if encryptedData, err := encrypt(domainData); err != nil {
 		return fmt.Errorf("'%s' failed - failed to encrypt domain data: %w", cs.String(), err)
 	} else {
-		dbRecord.DataBytes = hex.EncodeToString(encryptedData)
+		dbRecord.DataBytes = hex.AppendEncode(nil, encryptedData)
+		dbRecord.IsEncrypted = internal.NullBool{Bool: true, Valid: true}
 	}
 	return nil
 }
 
-func (cs *commandStoreSQLite) decryptDomainData(dbRecord *internal.Command) error {
-	if cs.options.CryptoService == nil {
-		return fmt.Errorf("'%s' failed - crypto service is nil", cs.String())
+func (cs *commandStoreSQLite) decryptDomainData(ctx context.Context, dbRecord *internal.Command) error {
+	c := cs.cipher()
+	if c == nil {
+		return fmt.Errorf("'%s' failed to decrypt command '%s' - %w", cs.String(), dbRecord.Uuid, ErrNoCryptoService)
+	}
+	decrypt := func(data []byte) ([]byte, error) { return c.Decrypt(ctx, data) }
+	if paths := cs.cfg().FieldEncryption[dbRecord.DataType]; len(paths) > 0 {
+		decryptedData, err := internal.DecryptFields(dbRecord.DataBytes, paths, decrypt)
+		switch {
+		case err == nil:
+			dbRecord.DataBytes = decryptedData
+			dbRecord.IsEncrypted = internal.NullBool{Valid: true}
+			return nil
+		case !errors.Is(err, internal.ErrNotJSONObject):
+			return fmt.Errorf("'%s' failed - failed to decrypt domain data fields: %w", cs.String(), err)
+		}
+		// written as a whole before field encryption was enabled
 	}
-	encryptedData, err := hex.DecodeString(dbRecord.DataBytes)
+	encryptedData, err := hex.AppendDecode(nil, dbRecord.DataBytes)
 	if err != nil {
 		return fmt.Errorf("'%s' failed - failed to decode hex domain data: %w", cs.String(), err)
 	}
 	if len(encryptedData) < 1 {
 		return fmt.Errorf("'%s' failed - encrypted domain data is empty", cs.String())
 	}
-	if decryptedData, err := cs.options.CryptoService.Decrypt(encryptedData); err != nil {
+	if decryptedData, err := decrypt(encryptedData); err != nil {
 		return fmt.Errorf("'%s' failed - failed to decrypt domain data: %w", cs.String(), err)
 	} else {
-		dbRecord.DataBytes = string(decryptedData)
+		dbRecord.DataBytes = decryptedData
+		dbRecord.IsEncrypted = internal.NullBool{Valid: true}
 	}
 	return nil
 }
@@ -2,8 +2,11 @@ package store_test
 
 import (
 	"context"
+	"database/sql"
 	"fmt"
 	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -670,3 +673,164 @@ func TestSyncCommandStore_ValidateCompleteCommandData(t *testing.T) {
 		}
 	}
 }
+
+func TestCommandStoreWithFieldEncryption(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "commandStore-fields.db")
+
+	// create crypto service
+	key := []byte("12345678901234567890123456789012")
+	cryptoService, _ := comby.NewCryptoService(key)
+
+	// setup and init store
+	commandStore := store.NewCommandStoreSQLite(path)
+	commandStore.Configure(
+		store.CommandStoreSQLiteWithFieldEncryption("CreateUser", "password"),
+	)
+	if err := commandStore.Init(ctx,
+		comby.CommandStoreOptionWithCryptoService(cryptoService),
+	); err != nil {
+		t.Fatal(err)
+	}
+	defer commandStore.Close(ctx)
+
+	cmd := createTestCommand("tenant-1", "user-domain", 1000)
+	cmd.SetDomainCmdName("CreateUser")
+	cmd.SetDomainCmdBytes([]byte(`{"name":"John","password":"secret"}`))
+	if err := commandStore.Create(ctx, comby.CommandStoreCreateOptionWithCommand(cmd)); err != nil {
+		t.Fatal(err)
+	}
+
+	// raw payload keeps unencrypted fields queryable
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	var name, password string
+	if err := db.QueryRowContext(ctx,
+		`SELECT json_extract(data_bytes, '$.name'), json_extract(data_bytes, '$.password') FROM commands WHERE uuid=?`,
+		cmd.GetCommandUuid()).Scan(&name, &password); err != nil {
+		t.Fatal(err)
+	}
+	if name != "John" {
+		t.Fatalf("plaintext field should be queryable: %q", name)
+	}
+	if password == "secret" {
+		t.Fatalf("password should be encrypted")
+	}
+
+	// payload is decrypted on read
+	_cmd, err := commandStore.Get(ctx, comby.CommandStoreGetOptionWithCommandUuid(cmd.GetCommandUuid()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(_cmd.GetDomainCmdBytes()), `"password":"secret"`) {
+		t.Fatalf("wrong payload: %s", _cmd.GetDomainCmdBytes())
+	}
+}
+
+func TestCommandStoreStrictMigration(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "commandStore-strict.db")
+
+	// database created before tables were STRICT
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if _, err := db.ExecContext(ctx, `
+	CREATE TABLE commands (id INTEGER, instance_id INTEGER, uuid TEXT, tenant_uuid TEXT, domain TEXT,
+		created_at INTEGER, data_type TEXT, data_bytes TEXT, req_ctx TEXT, PRIMARY KEY (id));
+	INSERT INTO commands (instance_id, uuid, tenant_uuid, domain, created_at, data_type, data_bytes, req_ctx)
+	VALUES (1, 'legacy-1', 'tenant-1', 'domain-1', 100, 'TestCommand', 'test-data', '');
+	`); err != nil {
+		t.Fatal(err)
+	}
+
+	commandStore := store.NewCommandStoreSQLite(path)
+	if err := commandStore.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer commandStore.Close(ctx)
+
+	var strict int
+	if err := db.QueryRowContext(ctx, "SELECT strict FROM pragma_table_list WHERE name='commands'").Scan(&strict); err != nil {
+		t.Fatal(err)
+	}
+	if strict != 1 {
+		t.Fatal("commands table was not rebuilt as strict")
+	}
+	cmd, err := commandStore.Get(ctx, comby.CommandStoreGetOptionWithCommandUuid("legacy-1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cmd.GetTenantUuid() != "tenant-1" || string(cmd.GetDomainCmdBytes()) != "test-data" {
+		t.Fatalf("unexpected migrated command: %+v", cmd)
+	}
+	if err := commandStore.Create(ctx, comby.CommandStoreCreateOptionWithCommand(createTestCommand("tenant-1", "domain-1", 200))); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCommandStoreApplyOptions(t *testing.T) {
+	ctx := context.Background()
+	commandStore := store.NewCommandStoreSQLite(filepath.Join(t.TempDir(), "commandStore-options.db"))
+	if err := commandStore.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer commandStore.Close(ctx)
+
+	// crypto service can be enabled at runtime
+	key := []byte("12345678901234567890123456789012")
+	cryptoService, _ := comby.NewCryptoService(key)
+	if err := commandStore.ApplyOptions(comby.CommandStoreOptionWithCryptoService(cryptoService)); err != nil {
+		t.Fatal(err)
+	}
+	if commandStore.Options().CryptoService == nil {
+		t.Fatal("expected crypto service")
+	}
+	cmd := createTestCommand("tenant-1", "domain-1", 100)
+	if err := commandStore.Create(ctx, comby.CommandStoreCreateOptionWithCommand(cmd)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := commandStore.Get(ctx, comby.CommandStoreGetOptionWithCommandUuid(cmd.GetCommandUuid())); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCommandStoreConcurrentWriters(t *testing.T) {
+	ctx := context.Background()
+	commandStore := store.NewCommandStoreSQLite(filepath.Join(t.TempDir(), "commandStore-concurrent.db"))
+	if err := commandStore.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer commandStore.Close(ctx)
+
+	const numWriters = 16
+	const numCommands = 25
+	var wg sync.WaitGroup
+	errs := make(chan error, numWriters)
+	for w := 0; w < numWriters; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := int64(1); i <= numCommands; i++ {
+				cmd := createTestCommand("tenant-1", "domain-1", i*100)
+				if err := commandStore.Create(ctx, comby.CommandStoreCreateOptionWithCommand(cmd)); err != nil {
+					errs <- err
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatal(err)
+	}
+	if total := commandStore.Total(ctx); total != numWriters*numCommands {
+		t.Fatalf("unexpected total: %d", total)
+	}
+}
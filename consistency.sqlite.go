@@ -0,0 +1,92 @@
+package store
+
+import (
+	"context"
+	"fmt"
+)
+
+// kinds of anomalies found by VerifyAggregateConsistency
+const (
+	// versions are missing before the event
+	AnomalyVersionGap = "version_gap"
+	// another event has the same version
+	AnomalyDuplicateVersion = "duplicate_version"
+	// the event was created before its predecessor
+	AnomalyTimeReversal = "time_reversal"
+)
+
+// AggregateAnomaly is an event breaking the version or time order of its aggregate.
+type AggregateAnomaly struct {
+	Kind      string
+	EventUuid string
+	Version   int64
+	CreatedAt int64
+	// version and created_at of the preceding event, 0 for the first event
+	PrevVersion   int64
+	PrevCreatedAt int64
+}
+
+// AggregateConsistencyReport is the result of VerifyAggregateConsistency.
+type AggregateConsistencyReport struct {
+	AggregateUuid string
+	NumEvents     int64
+	// highest version found
+	Version   int64
+	Anomalies []AggregateAnomaly
+}
+
+func (r *AggregateConsistencyReport) Consistent() bool {
+	return len(r.Anomalies) == 0
+}
+
+// VerifyAggregateConsistency checks that the versions of an aggregate start at
+// 1 and increase by one without duplicates, and that created_at never
+// decreases in version order. Anomalies are reported, not repaired. An
+// aggregate without events is consistent.
+func (es *eventStoreSQLite) VerifyAggregateConsistency(ctx context.Context, aggregateUuid string) (*AggregateConsistencyReport, error) {
+	if len(aggregateUuid) == 0 {
+		return nil, fmt.Errorf("'%s' failed to verify aggregate - aggregate uuid is required", es.String())
+	}
+	query := `SELECT uuid, version, created_at FROM events WHERE aggregate_uuid=? ORDER BY version ASC, id ASC;`
+	rows, err := es.db.QueryContext(ctx, query, aggregateUuid)
+	if err != nil {
+		return nil, classifyError(err)
+	}
+	defer rows.Close()
+
+	report := &AggregateConsistencyReport{AggregateUuid: aggregateUuid}
+	var prevVersion, prevCreatedAt int64
+	for rows.Next() {
+		var eventUuid string
+		var version, createdAt int64
+		if err := rows.Scan(&eventUuid, &version, &createdAt); err != nil {
+			return nil, classifyError(err)
+		}
+		anomaly := AggregateAnomaly{
+			EventUuid:     eventUuid,
+			Version:       version,
+			CreatedAt:     createdAt,
+			PrevVersion:   prevVersion,
+			PrevCreatedAt: prevCreatedAt,
+		}
+		switch {
+		case report.NumEvents > 0 && version == prevVersion:
+			anomaly.Kind = AnomalyDuplicateVersion
+			report.Anomalies = append(report.Anomalies, anomaly)
+		case version != prevVersion+1:
+			anomaly.Kind = AnomalyVersionGap
+			report.Anomalies = append(report.Anomalies, anomaly)
+		}
+		if report.NumEvents > 0 && createdAt < prevCreatedAt {
+			anomaly.Kind = AnomalyTimeReversal
+			report.Anomalies = append(report.Anomalies, anomaly)
+		}
+		report.NumEvents++
+		report.Version = version
+		prevVersion, prevCreatedAt = version, createdAt
+	}
+	if err := rows.Err(); err != nil {
+		return nil, classifyError(err)
+	}
+	return report, nil
+}
@@ -0,0 +1,70 @@
+package store_test
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	store "github.com/gradientzero/comby-store-sqlite"
+	"github.com/gradientzero/comby/v3"
+)
+
+func TestVerifyAggregateConsistency(t *testing.T) {
+	ctx := context.Background()
+	eventStore := store.NewEventStoreSQLite(filepath.Join(t.TempDir(), "consistency.db"))
+	if err := eventStore.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer eventStore.Close(ctx)
+
+	create := func(aggregateUuid string, version, createdAt int64) comby.Event {
+		evt := createTestEvent("tenant-1", "domain-1", version, createdAt)
+		evt.SetAggregateUuid(aggregateUuid)
+		if err := eventStore.Create(ctx, comby.EventStoreCreateOptionWithEvent(evt)); err != nil {
+			t.Fatal(err)
+		}
+		return evt
+	}
+	for v := int64(1); v <= 3; v++ {
+		create("healthy", v, v*100)
+	}
+	create("broken", 1, 100)
+	create("broken", 2, 200)
+	gap := create("broken", 4, 400)
+	duplicate := create("broken", 4, 410)
+	reversed := create("broken", 5, 300)
+
+	report, err := eventStore.VerifyAggregateConsistency(ctx, "healthy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !report.Consistent() || report.NumEvents != 3 || report.Version != 3 {
+		t.Fatalf("unexpected report of healthy aggregate: %+v", report)
+	}
+
+	report, err = eventStore.VerifyAggregateConsistency(ctx, "broken")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []store.AggregateAnomaly{
+		{Kind: store.AnomalyVersionGap, EventUuid: gap.GetEventUuid(), Version: 4, CreatedAt: 400, PrevVersion: 2, PrevCreatedAt: 200},
+		{Kind: store.AnomalyDuplicateVersion, EventUuid: duplicate.GetEventUuid(), Version: 4, CreatedAt: 410, PrevVersion: 4, PrevCreatedAt: 400},
+		{Kind: store.AnomalyTimeReversal, EventUuid: reversed.GetEventUuid(), Version: 5, CreatedAt: 300, PrevVersion: 4, PrevCreatedAt: 410},
+	}
+	if report.Consistent() || report.NumEvents != 5 || len(report.Anomalies) != len(want) {
+		t.Fatalf("unexpected report of broken aggregate: %+v", report)
+	}
+	for i := range want {
+		if report.Anomalies[i] != want[i] {
+			t.Fatalf("unexpected anomaly %d: %+v", i, report.Anomalies[i])
+		}
+	}
+
+	// an aggregate starting after version 1 has a gap
+	create("truncated", 3, 100)
+	if report, err := eventStore.VerifyAggregateConsistency(ctx, "truncated"); err != nil {
+		t.Fatal(err)
+	} else if len(report.Anomalies) != 1 || report.Anomalies[0].Kind != store.AnomalyVersionGap {
+		t.Fatalf("unexpected report of truncated aggregate: %+v", report)
+	}
+}
@@ -0,0 +1,112 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+)
+
+// DomainCounter is the number of events of a domain and tenant.
+type DomainCounter struct {
+	Domain     string
+	TenantUuid string
+	Count      int64
+	// created_at of the newest event, unix nano. Deletes do not lower it.
+	LastCreatedAt int64
+}
+
+// event_counters is maintained by triggers on event_records, so every write
+// updates it within its own transaction
+var eventCounterTables = []strictTable{
+	{
+		name: "event_counters",
+		columns: `domain_id INTEGER NOT NULL,
+		tenant_id INTEGER NOT NULL,
+		count INTEGER NOT NULL,
+		last_created_at INTEGER NOT NULL,
+		PRIMARY KEY (domain_id, tenant_id)`,
+		copyColumns: `domain_id, tenant_id, count, last_created_at`,
+	},
+}
+
+const eventCounterTriggerSchema = `
+	CREATE TRIGGER IF NOT EXISTS event_counters_insert AFTER INSERT ON event_records
+	BEGIN
+		INSERT INTO event_counters (domain_id, tenant_id, count, last_created_at)
+		VALUES (NEW.domain_id, NEW.tenant_id, 1, NEW.created_at)
+		ON CONFLICT(domain_id, tenant_id) DO UPDATE SET
+			count=count+1,
+			last_created_at=MAX(last_created_at, excluded.last_created_at);
+	END;
+
+	CREATE TRIGGER IF NOT EXISTS event_counters_delete AFTER DELETE ON event_records
+	BEGIN
+		UPDATE event_counters SET count=count-1 WHERE domain_id=OLD.domain_id AND tenant_id=OLD.tenant_id;
+		DELETE FROM event_counters WHERE domain_id=OLD.domain_id AND tenant_id=OLD.tenant_id AND count<=0;
+	END;
+
+	CREATE TRIGGER IF NOT EXISTS event_counters_update AFTER UPDATE OF domain_id, tenant_id, created_at ON event_records
+	WHEN OLD.domain_id<>NEW.domain_id OR OLD.tenant_id<>NEW.tenant_id OR OLD.created_at<>NEW.created_at
+	BEGIN
+		UPDATE event_counters SET count=count-1 WHERE domain_id=OLD.domain_id AND tenant_id=OLD.tenant_id;
+		DELETE FROM event_counters WHERE domain_id=OLD.domain_id AND tenant_id=OLD.tenant_id AND count<=0;
+		INSERT INTO event_counters (domain_id, tenant_id, count, last_created_at)
+		VALUES (NEW.domain_id, NEW.tenant_id, 1, NEW.created_at)
+		ON CONFLICT(domain_id, tenant_id) DO UPDATE SET
+			count=count+1,
+			last_created_at=MAX(last_created_at, excluded.last_created_at);
+	END;
+`
+
+// migrateEventCounters creates the counter triggers. Databases without them,
+// new ones or those whose event_records were rebuilt, are counted once.
+func migrateEventCounters(ctx context.Context, tx *sql.Tx) error {
+	var triggers int
+	if err := tx.QueryRowContext(ctx, `SELECT COUNT(*) FROM sqlite_master WHERE type='trigger' AND name='event_counters_insert'`).Scan(&triggers); err != nil {
+		return err
+	}
+	if triggers > 0 {
+		return nil
+	}
+	query := `
+	DELETE FROM event_counters;
+	INSERT INTO event_counters (domain_id, tenant_id, count, last_created_at)
+	SELECT domain_id, tenant_id, COUNT(*), MAX(created_at) FROM event_records GROUP BY domain_id, tenant_id;
+	`
+	if _, err := tx.ExecContext(ctx, query); err != nil {
+		return err
+	}
+	_, err := tx.ExecContext(ctx, eventCounterTriggerSchema)
+	return err
+}
+
+func (es *eventStoreSQLite) DomainCounters(ctx context.Context, domains ...string) ([]DomainCounter, error) {
+	whereList, args := inCondition("d.name", "IN", domains, nil, nil)
+	if tenant := es.cfg().Tenant; len(tenant) > 0 {
+		whereList = append(whereList, "t.uuid=?")
+		args = append(args, tenant)
+	}
+	query := `SELECT d.name, t.uuid, c.count, c.last_created_at FROM event_counters c
+		JOIN event_domains d ON d.id=c.domain_id
+		JOIN event_tenants t ON t.id=c.tenant_id`
+	if len(whereList) > 0 {
+		query += " WHERE " + strings.Join(whereList, " AND ")
+	}
+	query += " ORDER BY d.name ASC, t.uuid ASC;"
+
+	rows, err := es.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, classifyError(err)
+	}
+	defer rows.Close()
+
+	var counters []DomainCounter
+	for rows.Next() {
+		var counter DomainCounter
+		if err := rows.Scan(&counter.Domain, &counter.TenantUuid, &counter.Count, &counter.LastCreatedAt); err != nil {
+			return nil, classifyError(err)
+		}
+		counters = append(counters, counter)
+	}
+	return counters, classifyError(rows.Err())
+}
@@ -0,0 +1,81 @@
+package store_test
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	store "github.com/gradientzero/comby-store-sqlite"
+	"github.com/gradientzero/comby/v3"
+)
+
+func TestDomainCounters(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "eventStore-counters.db")
+	eventStore := store.NewEventStoreSQLite(path)
+	if err := eventStore.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer eventStore.Close(ctx)
+
+	var evts []comby.Event
+	for i, target := range [][2]string{
+		{"tenant-1", "domain-1"},
+		{"tenant-1", "domain-1"},
+		{"tenant-2", "domain-1"},
+		{"tenant-1", "domain-2"},
+	} {
+		evt := createTestEvent(target[0], target[1], int64(i+1), int64(i+1)*100)
+		if err := eventStore.Create(ctx, comby.EventStoreCreateOptionWithEvent(evt)); err != nil {
+			t.Fatal(err)
+		}
+		evts = append(evts, evt)
+	}
+	counters, err := eventStore.DomainCounters(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []store.DomainCounter{
+		{Domain: "domain-1", TenantUuid: "tenant-1", Count: 2, LastCreatedAt: 200},
+		{Domain: "domain-1", TenantUuid: "tenant-2", Count: 1, LastCreatedAt: 300},
+		{Domain: "domain-2", TenantUuid: "tenant-1", Count: 1, LastCreatedAt: 400},
+	}
+	if !reflect.DeepEqual(counters, expected) {
+		t.Fatalf("unexpected counters: %+v", counters)
+	}
+
+	// deletes and moves between domains are counted as well
+	if err := eventStore.Delete(ctx, comby.EventStoreDeleteOptionWithEventUuid(evts[2].GetEventUuid())); err != nil {
+		t.Fatal(err)
+	}
+	evts[0].SetDomain("domain-2")
+	if err := eventStore.Update(ctx, comby.EventStoreUpdateOptionWithEvent(evts[0])); err != nil {
+		t.Fatal(err)
+	}
+	counters, err = eventStore.DomainCounters(ctx, "domain-2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected = []store.DomainCounter{
+		{Domain: "domain-2", TenantUuid: "tenant-1", Count: 2, LastCreatedAt: 400},
+	}
+	if !reflect.DeepEqual(counters, expected) {
+		t.Fatalf("unexpected counters: %+v", counters)
+	}
+	counters, _ = eventStore.DomainCounters(ctx)
+	if len(counters) != 2 || counters[0].Count != 1 {
+		t.Fatalf("unexpected counters: %+v", counters)
+	}
+
+	// counters are kept across restarts
+	eventStore.Close(ctx)
+	reopened := store.NewEventStoreSQLite(path)
+	if err := reopened.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer reopened.Close(ctx)
+	if reopenedCounters, _ := reopened.DomainCounters(ctx); !reflect.DeepEqual(reopenedCounters, counters) {
+		t.Fatalf("unexpected counters after reopen: %+v", reopenedCounters)
+	}
+}
@@ -0,0 +1,55 @@
+package store
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/gradientzero/comby/v3"
+)
+
+// DataTypeInfo describes one data type found in a store.
+type DataTypeInfo struct {
+	Domain   string
+	DataType string
+	Count    int64
+	// created_at of the oldest and newest record, unix nano
+	FirstSeen int64
+	LastSeen  int64
+}
+
+func (es *eventStoreSQLite) ListDataTypes(ctx context.Context) ([]DataTypeInfo, error) {
+	if err := es.authorizeList(ctx, comby.EventStoreListOptions{}, eventFilter{}); err != nil {
+		return nil, err
+	}
+	return listDataTypes(ctx, es.db, "events", es.cfg().Tenant)
+}
+
+func (cs *commandStoreSQLite) ListDataTypes(ctx context.Context) ([]DataTypeInfo, error) {
+	if err := cs.authorizeList(ctx, comby.CommandStoreListOptions{}, commandFilter{}); err != nil {
+		return nil, err
+	}
+	return listDataTypes(ctx, cs.db, "commands", cs.cfg().Tenant)
+}
+
+// listDataTypes groups the records of source, those of the bound tenant if
+// any, by domain and data type.
+func listDataTypes(ctx context.Context, q queryer, source, boundTenant string) ([]DataTypeInfo, error) {
+	whereList, args := tenantCondition(boundTenant, nil, nil)
+	query := fmt.Sprintf(`SELECT domain, data_type, COUNT(*), MIN(created_at), MAX(created_at)
+		FROM %s%s GROUP BY domain, data_type ORDER BY domain ASC, data_type ASC;`, source, whereSQL(whereList))
+	rows, err := q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, classifyError(err)
+	}
+	defer rows.Close()
+
+	var infos []DataTypeInfo
+	for rows.Next() {
+		var info DataTypeInfo
+		if err := rows.Scan(&info.Domain, &info.DataType, &info.Count, &info.FirstSeen, &info.LastSeen); err != nil {
+			return nil, classifyError(err)
+		}
+		infos = append(infos, info)
+	}
+	return infos, classifyError(rows.Err())
+}
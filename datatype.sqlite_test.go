@@ -0,0 +1,59 @@
+package store_test
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	store "github.com/gradientzero/comby-store-sqlite"
+	"github.com/gradientzero/comby/v3"
+)
+
+func TestListDataTypes(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "datatypes.db")
+	eventStore := store.NewEventStoreSQLite(path)
+	if err := eventStore.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer eventStore.Close(ctx)
+	commandStore := store.NewCommandStoreSQLite(path)
+	if err := commandStore.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer commandStore.Close(ctx)
+
+	for i := int64(1); i <= 3; i++ {
+		evt := createTestEvent("tenant-1", "domain-1", i, i*100)
+		if i > 1 {
+			evt.SetDomainEvtName("Renamed")
+		}
+		if err := eventStore.Create(ctx, comby.EventStoreCreateOptionWithEvent(evt)); err != nil {
+			t.Fatal(err)
+		}
+		cmd := createTestCommand("tenant-1", "domain-1", 100)
+		if err := commandStore.Create(ctx, comby.CommandStoreCreateOptionWithCommand(cmd)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	eventTypes, err := eventStore.ListDataTypes(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []store.DataTypeInfo{
+		{Domain: "domain-1", DataType: "Renamed", Count: 2, FirstSeen: 200, LastSeen: 300},
+		{Domain: "domain-1", DataType: "TestEvent_1", Count: 1, FirstSeen: 100, LastSeen: 100},
+	}
+	if len(eventTypes) != len(want) || eventTypes[0] != want[0] || eventTypes[1] != want[1] {
+		t.Fatalf("unexpected event data types: %+v", eventTypes)
+	}
+
+	commandTypes, err := commandStore.ListDataTypes(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(commandTypes) != 1 || commandTypes[0].DataType != "TestCommand_100" || commandTypes[0].Count != 3 {
+		t.Fatalf("unexpected command data types: %+v", commandTypes)
+	}
+}
@@ -0,0 +1,51 @@
+package store
+
+// DriverModernc is the database/sql name of modernc.org/sqlite, a pure Go
+// SQLite driver and the default of builds without the sqlite_mattn tag.
+const DriverModernc = "sqlite"
+
+// driver the stores connect with if no driver name is configured
+var defaultDriverName = DriverModernc
+
+// driverErrorCodes return the SQLite result code of errors of other drivers
+// than modernc.org/sqlite, so classifyError handles them alike.
+var driverErrorCodes []func(err error) (int, bool)
+
+// EventStoreSQLiteWithDriverName connects through the database/sql driver
+// registered as name, e.g. DriverMattn or a driver wrapping the SQLite driver
+// to inject failures in tests, see storetest.FaultDriver.
+func EventStoreSQLiteWithDriverName(name string) EventStoreSQLiteOption {
+	return func(c *eventStoreSQLiteConfig) { c.DriverName = name }
+}
+
+// CommandStoreSQLiteWithDriverName connects through the database/sql driver
+// registered as name, see EventStoreSQLiteWithDriverName.
+func CommandStoreSQLiteWithDriverName(name string) CommandStoreSQLiteOption {
+	return func(c *commandStoreSQLiteConfig) { c.DriverName = name }
+}
+
+// SnapshotStoreSQLiteWithDriverName connects through the database/sql driver
+// registered as name, see EventStoreSQLiteWithDriverName.
+func SnapshotStoreSQLiteWithDriverName(name string) SnapshotStoreSQLiteOption {
+	return func(c *snapshotStoreSQLiteConfig) { c.DriverName = name }
+}
+
+// WithDriverName connects the shared pool through the database/sql driver
+// registered as name, see EventStoreSQLiteWithDriverName.
+func WithDriverName(name string) OpenOption {
+	return func(c *openConfig) { c.DriverName = name }
+}
+
+// DefaultDriverName returns the name of the driver stores connect with by
+// default. Connections to a database opened besides the stores should use it:
+// two SQLite drivers in one process do not see each other's file locks.
+func DefaultDriverName() string {
+	return defaultDriverName
+}
+
+func driverName(name string) string {
+	if len(name) == 0 {
+		return defaultDriverName
+	}
+	return name
+}
@@ -0,0 +1,83 @@
+//go:build sqlite_mattn
+
+package store
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// DriverMattn is the database/sql name of github.com/mattn/go-sqlite3 with the
+// DSN handling of the stores. It requires cgo and is the default of builds
+// with the sqlite_mattn tag, e.g. to use SQLCipher.
+const DriverMattn = "sqlite3-store"
+
+func init() {
+	sql.Register(DriverMattn, &mattnDriver{})
+	defaultDriverName = DriverMattn
+	driverErrorCodes = append(driverErrorCodes, func(err error) (int, bool) {
+		var sqliteErr sqlite3.Error
+		if !errors.As(err, &sqliteErr) {
+			return 0, false
+		}
+		return int(sqliteErr.ExtendedCode), true
+	})
+}
+
+// mattnDriver accepts the DSNs of modernc.org/sqlite. go-sqlite3 only knows
+// a fixed set of pragma parameters, so the "_pragma" parameters are removed
+// from the DSN and run on every new connection instead.
+type mattnDriver struct {
+	sqlite3.SQLiteDriver
+}
+
+func (d *mattnDriver) Open(dsn string) (driver.Conn, error) {
+	dsn, pragmas, err := splitPragmas(dsn)
+	if err != nil {
+		return nil, err
+	}
+	c, err := d.SQLiteDriver.Open(dsn)
+	if err != nil {
+		return nil, err
+	}
+	for _, pragma := range pragmas {
+		if _, err := c.(*sqlite3.SQLiteConn).Exec("PRAGMA "+pragma+";", nil); err != nil {
+			c.Close()
+			return nil, fmt.Errorf("failed to set pragma '%s' - %w", pragma, err)
+		}
+	}
+	return c, nil
+}
+
+// splitPragmas removes the "_pragma" parameters of dsn and returns them.
+func splitPragmas(dsn string) (string, []string, error) {
+	path, query, ok := strings.Cut(dsn, "?")
+	if !ok {
+		return dsn, nil, nil
+	}
+	var params, pragmas []string
+	for _, param := range strings.Split(query, "&") {
+		value, ok := strings.CutPrefix(param, "_pragma=")
+		if !ok {
+			if len(param) > 0 {
+				params = append(params, param)
+			}
+			continue
+		}
+		pragma, err := url.QueryUnescape(value)
+		if err != nil {
+			return "", nil, fmt.Errorf("invalid pragma '%s' - %w", value, err)
+		}
+		pragmas = append(pragmas, pragma)
+	}
+	if len(params) > 0 {
+		path += "?" + strings.Join(params, "&")
+	}
+	return path, pragmas, nil
+}
@@ -0,0 +1,52 @@
+//go:build sqlite_mattn
+
+package store_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	store "github.com/gradientzero/comby-store-sqlite"
+	"github.com/gradientzero/comby-store-sqlite/storetest"
+	"github.com/gradientzero/comby/v3"
+)
+
+func TestMattnDriverConformance(t *testing.T) {
+	storetest.RunEventStoreConformance(t, func(t *testing.T) comby.EventStore {
+		// pragmas of the DSN are run by the driver
+		return storetest.NewTempEventStore(t, store.EventStoreSQLiteWithReadOptimizedProfile())
+	})
+	storetest.RunCommandStoreConformance(t, func(t *testing.T) comby.CommandStore {
+		return storetest.NewMemoryCommandStore(t)
+	})
+}
+
+func TestMattnDriverErrors(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "corrupt.db")
+	garbage := make([]byte, 4096)
+	for i := range garbage {
+		garbage[i] = byte(i % 251)
+	}
+	if err := os.WriteFile(path, garbage, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.NewEventStoreSQLite(path).Init(ctx); !errors.Is(err, store.ErrCorrupt) {
+		t.Fatalf("expected corrupt error, got %v", err)
+	}
+
+	// the pure Go driver stays available
+	eventStore := storetest.NewTempEventStore(t, store.EventStoreSQLiteWithDriverName(store.DriverModernc))
+	storetest.CreateEvents(t, eventStore, storetest.NewEvent())
+	stores, err := store.Open(filepath.Join(t.TempDir(), "store.db"), store.WithDriverName(store.DriverMattn), store.WithSnapshotStore())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stores.Close(ctx)
+	if err := stores.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+}
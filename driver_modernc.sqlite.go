@@ -0,0 +1,9 @@
+//go:build !sqlite_mattn
+
+package store
+
+import (
+	// registers DriverModernc, the default driver of builds without the
+	// sqlite_mattn tag
+	_ "modernc.org/sqlite"
+)
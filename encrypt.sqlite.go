@@ -0,0 +1,156 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/gradientzero/comby-store-sqlite/internal"
+)
+
+// ErrNoCryptoService is returned when an encrypted payload is read by a store
+// without crypto service, e.g. after it was removed.
+var ErrNoCryptoService = errors.New("payload is encrypted but no crypto service is configured")
+
+// isEncrypted decides per record whether its payload has to be decrypted, so
+// a store can hold encrypted and plaintext records side by side. Records
+// written before the state was tracked are as encrypted as the store.
+func isEncrypted(state internal.NullBool, storeEncrypts bool) bool {
+	if state.Valid {
+		return state.Bool
+	}
+	return storeEncrypts
+}
+
+// encryptBatchSize is the number of records encrypted per transaction by EncryptExisting
+const encryptBatchSize = 500
+
+// EncryptExisting encrypts all plaintext payloads with the crypto service of
+// the store, e.g. after encryption was enabled for an existing database. It
+// runs in batches, so other writes can proceed in between and an interrupted
+// run continues where it stopped. Records written before the encryption state
+// was tracked are checked by trying to decrypt them. It returns the number of
+// encrypted records and is recorded in the admin audit log.
+func (es *eventStoreSQLite) EncryptExisting(ctx context.Context) (int64, error) {
+	if es.cipher() == nil {
+		return 0, fmt.Errorf("'%s' failed to encrypt events - no crypto service or key provider configured", es.String())
+	}
+	if es.opts().ReadOnly {
+		return 0, fmt.Errorf("'%s' failed to encrypt events - instance is readonly", es.String())
+	}
+	defer es.invalidateCache()
+
+	query := fmt.Sprintf(`SELECT %s FROM events WHERE id>? AND (is_encrypted IS NULL OR is_encrypted=0) ORDER BY id ASC LIMIT %d;`, eventSelectColumns, encryptBatchSize)
+	var lastId, numEncrypted int64
+	for {
+		var numRecords int
+		err := es.encryptBatch(ctx, func(tx *sql.Tx) error {
+			dbRecords, err := queryEventRecords(ctx, tx, query, []any{lastId})
+			if err != nil {
+				return err
+			}
+			numRecords = len(dbRecords)
+			for _, dbRecord := range dbRecords {
+				lastId = dbRecord.ID.Int64
+				if !dbRecord.IsEncrypted.Valid {
+					probe := *dbRecord
+					if es.decryptDomainData(ctx, &probe) == nil {
+						if _, err := tx.ExecContext(ctx, "UPDATE event_records SET is_encrypted=1 WHERE id=?;", lastId); err != nil {
+							return err
+						}
+						continue
+					}
+				}
+				if err := es.encryptDomainData(ctx, dbRecord); err != nil {
+					return err
+				}
+				query := `UPDATE event_records SET data_bytes=?, data_size=?, is_encrypted=1 WHERE id=?;`
+				if _, err := tx.ExecContext(ctx, query, dbRecord.DataBytes, len(dbRecord.DataBytes), lastId); err != nil {
+					return err
+				}
+				numEncrypted++
+			}
+			return nil
+		})
+		if err != nil {
+			return numEncrypted, fmt.Errorf("'%s' failed to encrypt events - %w", es.String(), err)
+		}
+		if numRecords < encryptBatchSize {
+			break
+		}
+	}
+	return numEncrypted, es.encryptBatch(ctx, func(tx *sql.Tx) error {
+		return insertAuditEntry(ctx, tx, newAuditEntry(ctx, "events", AdminOperationEncrypt, "", numEncrypted))
+	})
+}
+
+func (es *eventStoreSQLite) encryptBatch(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	done, err := es.beginWrite(ctx)
+	if err != nil {
+		return err
+	}
+	defer done()
+	return runTx(ctx, es.db, fn)
+}
+
+// EncryptExisting encrypts all plaintext payloads, see the event store.
+func (cs *commandStoreSQLite) EncryptExisting(ctx context.Context) (int64, error) {
+	if cs.cipher() == nil {
+		return 0, fmt.Errorf("'%s' failed to encrypt commands - no crypto service or key provider configured", cs.String())
+	}
+	if cs.opts().ReadOnly {
+		return 0, fmt.Errorf("'%s' failed to encrypt commands - instance is readonly", cs.String())
+	}
+
+	query := fmt.Sprintf(`SELECT %s FROM commands WHERE id>? AND (is_encrypted IS NULL OR is_encrypted=0) ORDER BY id ASC LIMIT %d;`, commandSelectColumns, encryptBatchSize)
+	var lastId, numEncrypted int64
+	for {
+		var numRecords int
+		err := cs.encryptBatch(ctx, func(tx *sql.Tx) error {
+			dbRecords, err := queryCommandRecords(ctx, tx, query, lastId)
+			if err != nil {
+				return err
+			}
+			numRecords = len(dbRecords)
+			for _, dbRecord := range dbRecords {
+				lastId = dbRecord.ID.Int64
+				if !dbRecord.IsEncrypted.Valid {
+					probe := *dbRecord
+					if cs.decryptDomainData(ctx, &probe) == nil {
+						if _, err := tx.ExecContext(ctx, "UPDATE commands SET is_encrypted=1 WHERE id=?;", lastId); err != nil {
+							return err
+						}
+						continue
+					}
+				}
+				if err := cs.encryptDomainData(ctx, dbRecord); err != nil {
+					return err
+				}
+				if _, err := tx.ExecContext(ctx, "UPDATE commands SET data_bytes=?, is_encrypted=1 WHERE id=?;", dbRecord.DataBytes, lastId); err != nil {
+					return err
+				}
+				numEncrypted++
+			}
+			return nil
+		})
+		if err != nil {
+			return numEncrypted, fmt.Errorf("'%s' failed to encrypt commands - %w", cs.String(), err)
+		}
+		if numRecords < encryptBatchSize {
+			break
+		}
+	}
+	return numEncrypted, cs.encryptBatch(ctx, func(tx *sql.Tx) error {
+		return insertAuditEntry(ctx, tx, newAuditEntry(ctx, "commands", AdminOperationEncrypt, "", numEncrypted))
+	})
+}
+
+func (cs *commandStoreSQLite) encryptBatch(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	done, err := cs.beginWrite(ctx)
+	if err != nil {
+		return err
+	}
+	defer done()
+	return runTx(ctx, cs.db, fn)
+}
@@ -0,0 +1,177 @@
+package store_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	store "github.com/gradientzero/comby-store-sqlite"
+	"github.com/gradientzero/comby/v3"
+)
+
+func TestEventStoreEncryptExisting(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "eventStore-encrypt.db")
+
+	// events written before encryption was enabled
+	plainStore := store.NewEventStoreSQLite(path)
+	if err := plainStore.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	for i := int64(1); i <= 3; i++ {
+		if err := plainStore.Create(ctx, comby.EventStoreCreateOptionWithEvent(createTestEvent("tenant-1", "domain-1", i, i*100))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	plainStore.Close(ctx)
+
+	cryptoService, _ := comby.NewCryptoService([]byte("12345678901234567890123456789012"))
+	eventStore := store.NewEventStoreSQLite(path)
+	if err := eventStore.Init(ctx, comby.EventStoreOptionWithCryptoService(cryptoService)); err != nil {
+		t.Fatal(err)
+	}
+	defer eventStore.Close(ctx)
+	if err := eventStore.Create(ctx, comby.EventStoreCreateOptionWithEvent(createTestEvent("tenant-1", "domain-1", 4, 400))); err != nil {
+		t.Fatal(err)
+	}
+
+	// records of older versions have an unknown encryption state
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if _, err := db.Exec("UPDATE event_records SET is_encrypted=NULL WHERE id IN (1, 4)"); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx = store.WithAuditActor(ctx, "ops")
+	numEncrypted, err := eventStore.EncryptExisting(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if numEncrypted != 3 {
+		t.Fatalf("expected 3 encrypted events, got %d", numEncrypted)
+	}
+	var numPlain int
+	if err := db.QueryRow("SELECT COUNT(*) FROM event_records WHERE is_encrypted IS NOT 1 OR data_bytes LIKE 'test-data-%'").Scan(&numPlain); err != nil {
+		t.Fatal(err)
+	}
+	if numPlain != 0 {
+		t.Fatalf("expected no plaintext events, got %d", numPlain)
+	}
+	evts, _, err := eventStore.List(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, evt := range evts {
+		if expected := fmt.Sprintf("test-data-%d", evt.GetVersion()); string(evt.GetDomainEvtBytes()) != expected {
+			t.Fatalf("expected %s, got %s", expected, evt.GetDomainEvtBytes())
+		}
+	}
+
+	// a second run has nothing to do
+	if numEncrypted, err := eventStore.EncryptExisting(ctx); err != nil || numEncrypted != 0 {
+		t.Fatalf("expected nothing to encrypt, got %d, %v", numEncrypted, err)
+	}
+	entries, err := eventStore.ListAdminAudit(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 || entries[0].Operation != store.AdminOperationEncrypt || entries[0].Rows != 3 || entries[0].Actor != "ops" {
+		t.Fatalf("unexpected audit entries: %+v", entries)
+	}
+}
+
+func TestCommandStoreEncryptExisting(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "commandStore-encrypt.db")
+
+	plainStore := store.NewCommandStoreSQLite(path)
+	if err := plainStore.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := plainStore.Create(ctx, comby.CommandStoreCreateOptionWithCommand(createTestCommand("tenant-1", "domain-1", 100))); err != nil {
+		t.Fatal(err)
+	}
+	plainStore.Close(ctx)
+
+	cryptoService, _ := comby.NewCryptoService([]byte("12345678901234567890123456789012"))
+	commandStore := store.NewCommandStoreSQLite(path)
+	if err := commandStore.Init(ctx, comby.CommandStoreOptionWithCryptoService(cryptoService)); err != nil {
+		t.Fatal(err)
+	}
+	defer commandStore.Close(ctx)
+	if numEncrypted, err := commandStore.EncryptExisting(ctx); err != nil || numEncrypted != 1 {
+		t.Fatalf("expected 1 encrypted command, got %d, %v", numEncrypted, err)
+	}
+	cmds, _, err := commandStore.List(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cmds) != 1 || string(cmds[0].GetDomainCmdBytes()) != "test-data-100" {
+		t.Fatalf("unexpected commands: %v", cmds)
+	}
+}
+
+func TestEncryptExistingRequiresCryptoService(t *testing.T) {
+	ctx := context.Background()
+	eventStore := store.NewEventStoreSQLite(filepath.Join(t.TempDir(), "eventStore-encrypt.db"))
+	if err := eventStore.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer eventStore.Close(ctx)
+	if _, err := eventStore.EncryptExisting(ctx); err == nil {
+		t.Fatal("expected error without crypto service")
+	}
+}
+
+func TestEventStoreMixedEncryption(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "eventStore-mixed.db")
+	cryptoService, _ := comby.NewCryptoService([]byte("12345678901234567890123456789012"))
+
+	open := func(opts ...comby.EventStoreOption) store.EventStoreSQLite {
+		eventStore := store.NewEventStoreSQLite(path)
+		if err := eventStore.Init(ctx, opts...); err != nil {
+			t.Fatal(err)
+		}
+		return eventStore
+	}
+	create := func(eventStore store.EventStoreSQLite, version int64) comby.Event {
+		evt := createTestEvent("tenant-1", "domain-1", version, version*100)
+		if err := eventStore.Create(ctx, comby.EventStoreCreateOptionWithEvent(evt)); err != nil {
+			t.Fatal(err)
+		}
+		return evt
+	}
+
+	// plaintext, then encrypted after a crypto service was added
+	eventStore := open()
+	plain := create(eventStore, 1)
+	eventStore.Close(ctx)
+	eventStore = open(comby.EventStoreOptionWithCryptoService(cryptoService))
+	encrypted := create(eventStore, 2)
+	evts, _, err := eventStore.List(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(evts) != 2 || string(evts[0].GetDomainEvtBytes()) != "test-data-1" || string(evts[1].GetDomainEvtBytes()) != "test-data-2" {
+		t.Fatalf("unexpected events: %v", evts)
+	}
+	eventStore.Close(ctx)
+
+	// without crypto service plaintext events stay readable
+	eventStore = open()
+	defer eventStore.Close(ctx)
+	create(eventStore, 3)
+	if evt, err := eventStore.Get(ctx, comby.EventStoreGetOptionWithEventUuid(plain.GetEventUuid())); err != nil || string(evt.GetDomainEvtBytes()) != "test-data-1" {
+		t.Fatalf("expected plaintext event, got %v, %v", evt, err)
+	}
+	if _, err := eventStore.Get(ctx, comby.EventStoreGetOptionWithEventUuid(encrypted.GetEventUuid())); !errors.Is(err, store.ErrNoCryptoService) {
+		t.Fatalf("expected ErrNoCryptoService, got %v", err)
+	}
+}
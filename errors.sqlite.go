@@ -0,0 +1,173 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gradientzero/comby/v3"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+// Classified SQLite errors. Errors returned by the stores match one of them
+// with errors.Is if the underlying driver error is of that kind, while the
+// driver error itself stays reachable with errors.As.
+var (
+	// the database file is malformed or not a database
+	ErrCorrupt = errors.New("database is corrupt")
+	// the disk or the database size limit is full
+	ErrDiskFull = errors.New("database or disk is full")
+	// the database is locked by another connection or process
+	ErrLocked = errors.New("database is locked")
+	// the database can not be written, e.g. on a read-only file system
+	ErrReadOnlyFS = errors.New("database is read-only")
+)
+
+// ErrNotFound is returned by operations on a single record, e.g. MarkProcessed,
+// if it does not exist. Get returns nil instead.
+var ErrNotFound = errors.New("not found")
+
+// classifiedError wraps a driver error with its class.
+type classifiedError struct {
+	class error
+	err   error
+}
+
+func (e *classifiedError) Error() string {
+	return e.err.Error()
+}
+
+func (e *classifiedError) Unwrap() []error {
+	return []error{e.class, e.err}
+}
+
+// sqliteError is implemented by *sqlite.Error and by errors of drivers
+// wrapping it, Code returns the (extended) SQLite result code.
+type sqliteError interface {
+	error
+	Code() int
+}
+
+var _ sqliteError = (*sqlite.Error)(nil)
+
+// classifyError wraps SQLite driver errors into the error taxonomy above,
+// other errors are returned unchanged.
+func classifyError(err error) error {
+	if err == nil {
+		return nil
+	}
+	code, ok := sqliteErrorCode(err)
+	if !ok {
+		return err
+	}
+	var class error
+	// extended result codes carry the primary code in the lower byte
+	switch code & 0xff {
+	case sqlite3.SQLITE_CORRUPT, sqlite3.SQLITE_NOTADB:
+		class = ErrCorrupt
+	case sqlite3.SQLITE_FULL:
+		class = ErrDiskFull
+	case sqlite3.SQLITE_BUSY, sqlite3.SQLITE_LOCKED:
+		class = ErrLocked
+	case sqlite3.SQLITE_READONLY:
+		class = ErrReadOnlyFS
+	default:
+		return err
+	}
+	return &classifiedError{class: class, err: err}
+}
+
+// sqliteErrorCode returns the result code of a SQLite error of any driver.
+func sqliteErrorCode(err error) (int, bool) {
+	var sqliteErr sqliteError
+	if errors.As(err, &sqliteErr) {
+		return sqliteErr.Code(), true
+	}
+	for _, errorCode := range driverErrorCodes {
+		if code, ok := errorCode(err); ok {
+			return code, true
+		}
+	}
+	return 0, false
+}
+
+// OpError is returned by the operations of the stores, e.g. Create or List,
+// and records what failed, so an error can be debugged without the logs of
+// the service. The wrapped error stays matchable with errors.Is and
+// errors.As, e.g. for ErrLocked or *OptionsError.
+type OpError struct {
+	// operation and table, e.g. "create" and "events"
+	Op    string
+	Table string
+	// uuid of the record, empty for lists
+	Uuid string
+	// list options which were set, as key=value
+	Filters []string
+	// time from the start of the operation until it failed
+	Elapsed time.Duration
+	Err     error
+}
+
+func (e *OpError) Error() string {
+	details := []string{"op=" + e.Op, "table=" + e.Table}
+	if len(e.Uuid) > 0 {
+		details = append(details, "uuid="+e.Uuid)
+	}
+	details = append(details, e.Filters...)
+	details = append(details, "elapsed="+e.Elapsed.String())
+	return fmt.Sprintf("%s (%s)", e.Err, strings.Join(details, " "))
+}
+
+func (e *OpError) Unwrap() error {
+	return e.Err
+}
+
+// wrapOpError wraps *err into a classified OpError when an operation returns.
+// describe returns the uuid and filters of the operation and is only called
+// on failure. Errors of nested operations are wrapped once.
+func wrapOpError(err *error, op, table string, start time.Time, describe func() (string, []string)) {
+	if *err == nil {
+		return
+	}
+	var opErr *OpError
+	if errors.As(*err, &opErr) {
+		return
+	}
+	uuid, filters := describe()
+	*err = &OpError{
+		Op:      op,
+		Table:   table,
+		Uuid:    uuid,
+		Filters: filters,
+		Elapsed: time.Since(start),
+		Err:     classifyError(*err),
+	}
+}
+
+// eventUuidOf returns the uuid of evt, empty if it is nil.
+func eventUuidOf(evt comby.Event) string {
+	if evt == nil {
+		return ""
+	}
+	return evt.GetEventUuid()
+}
+
+// commandUuidOf returns the uuid of cmd, empty if it is nil.
+func commandUuidOf(cmd comby.Command) string {
+	if cmd == nil {
+		return ""
+	}
+	return cmd.GetCommandUuid()
+}
+
+// optionsOf applies the comby options opts to zero options, e.g. to describe
+// a failed call. Failing options are skipped.
+func optionsOf[T any, O ~func(*T) (*T, error)](opts []O) T {
+	var options T
+	for _, opt := range opts {
+		opt(&options)
+	}
+	return options
+}
@@ -0,0 +1,115 @@
+package store_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	store "github.com/gradientzero/comby-store-sqlite"
+	"github.com/gradientzero/comby-store-sqlite/storetest"
+	"github.com/gradientzero/comby/v3"
+)
+
+func TestEventStoreErrCorrupt(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "eventStore-corrupt.db")
+	garbage := make([]byte, 4096)
+	for i := range garbage {
+		garbage[i] = byte(i % 251)
+	}
+	if err := os.WriteFile(path, garbage, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	eventStore := store.NewEventStoreSQLite(path)
+	err := eventStore.Init(ctx)
+	if !errors.Is(err, store.ErrCorrupt) {
+		t.Fatalf("expected corrupt error, got %v", err)
+	}
+	if errors.Is(err, store.ErrDiskFull) || errors.Is(err, store.ErrLocked) {
+		t.Fatalf("unexpected classification: %v", err)
+	}
+}
+
+func TestCommandStoreErrCorrupt(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "commandStore-corrupt.db")
+	if err := os.WriteFile(path, []byte("this is not a sqlite database, but long enough to look like one......................................................"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	commandStore := store.NewCommandStoreSQLite(path)
+	if err := commandStore.Init(ctx); !errors.Is(err, store.ErrCorrupt) {
+		t.Fatalf("expected corrupt error, got %v", err)
+	}
+}
+
+func TestOpErrorContext(t *testing.T) {
+	ctx := context.Background()
+	faults := storetest.NewFaultDriver()
+	eventStore := store.NewEventStoreSQLite(filepath.Join(t.TempDir(), "events.db"))
+	eventStore.Configure(store.EventStoreSQLiteWithDriverName(faults.Name()))
+	if err := eventStore.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer eventStore.Close(ctx)
+
+	evt := createTestEvent("tenant-1", "domain-1", 1, 100)
+	faults.Fail("INSERT INTO events", storetest.ErrBusy, 1)
+	err := eventStore.Create(ctx, comby.EventStoreCreateOptionWithEvent(evt))
+	var opErr *store.OpError
+	if !errors.As(err, &opErr) {
+		t.Fatalf("expected op error, got %v", err)
+	}
+	if opErr.Op != "create" || opErr.Table != "events" || opErr.Uuid != evt.GetEventUuid() || opErr.Elapsed <= 0 {
+		t.Fatalf("unexpected op error %+v", opErr)
+	}
+	if !errors.Is(err, store.ErrLocked) {
+		t.Fatalf("expected locked error, got %v", err)
+	}
+	var faultErr *storetest.FaultError
+	if !errors.As(err, &faultErr) {
+		t.Fatalf("expected the driver error to stay reachable, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "uuid="+evt.GetEventUuid()) {
+		t.Fatalf("expected the uuid in %q", err.Error())
+	}
+
+	// list errors carry the options which were set
+	_, _, err = eventStore.List(ctx,
+		comby.EventStoreListOptionOrderBy("data_bytes"),
+		store.EventStoreListOptionExcludeDomains("internal"),
+	)
+	if !errors.As(err, &opErr) || !errors.Is(err, store.ErrInvalidOptions) {
+		t.Fatalf("expected op error with invalid options, got %v", err)
+	}
+	if opErr.Op != "list" || !slices.Contains(opErr.Filters, "orderBy=data_bytes") || !slices.Contains(opErr.Filters, "excludeDomains=internal") {
+		t.Fatalf("unexpected filters %q", opErr.Filters)
+	}
+}
+
+func TestOpErrorWrappedOnce(t *testing.T) {
+	ctx := context.Background()
+	commandStore := store.NewCommandStoreSQLite(filepath.Join(t.TempDir(), "commands.db"))
+	if err := commandStore.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer commandStore.Close(ctx)
+
+	cmd := createTestCommand("tenant-1", "domain-1", 100)
+	if err := commandStore.Create(ctx, comby.CommandStoreCreateOptionWithCommand(cmd)); err != nil {
+		t.Fatal(err)
+	}
+	err := commandStore.Create(ctx, comby.CommandStoreCreateOptionWithCommand(cmd))
+	var opErr *store.OpError
+	if !errors.As(err, &opErr) || opErr.Uuid != cmd.GetCommandUuid() {
+		t.Fatalf("expected op error of the duplicate command, got %v", err)
+	}
+	if errors.As(opErr.Err, new(*store.OpError)) {
+		t.Fatalf("expected one op error, got %v", err)
+	}
+}
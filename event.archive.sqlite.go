@@ -0,0 +1,759 @@
+package store
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gradientzero/comby-store-sqlite/internal"
+	"github.com/gradientzero/comby/v3"
+)
+
+// ArchiveUploader moves archived segments to and from an object store (S3, GCS, ...).
+type ArchiveUploader interface {
+	Upload(ctx context.Context, key string, r io.Reader) error
+	Download(ctx context.Context, key string) (io.ReadCloser, error)
+}
+
+// ArchiveSegment describes an uploaded range of events tracked in the manifest table.
+type ArchiveSegment struct {
+	Key           string
+	FromCreatedAt int64
+	ToCreatedAt   int64
+	NumItems      int64
+	UploadedAt    int64
+	PrunedAt      int64
+}
+
+// EventArchiver exports ranges of events as NDJSON segments to an ArchiveUploader
+// and keeps track of them in the archive_manifest table of the event store.
+type EventArchiver struct {
+	es       *eventStoreSQLite
+	uploader ArchiveUploader
+}
+
+func NewEventArchiver(eventStore comby.EventStore, uploader ArchiveUploader) (*EventArchiver, error) {
+	es, ok := eventStore.(*eventStoreSQLite)
+	if !ok {
+		return nil, fmt.Errorf("archiver requires a sqlite event store")
+	}
+	if uploader == nil {
+		return nil, fmt.Errorf("'%s' failed to create archiver - uploader is nil", es.String())
+	}
+	return &EventArchiver{
+		es:       es,
+		uploader: uploader,
+	}, nil
+}
+
+// Init creates the manifest table. The event store must be initialized before.
+func (a *EventArchiver) Init(ctx context.Context) error {
+	if a.es.db == nil {
+		return fmt.Errorf("'%s' failed to init archiver - event store is not initialized", a.es.String())
+	}
+	return migrateTx(ctx, a.es.db, func(tx *sql.Tx) error {
+		// pruned events keep their aggregate versions from being handed out again
+		tables := append(archiveTables, prunedVersionTables...)
+		if err := migrateStrictTables(ctx, tx, loggerOrDiscard(a.es.cfg().Logger), tables...); err != nil {
+			return err
+		}
+		query := `
+		CREATE INDEX IF NOT EXISTS "archive_manifest_range_index" ON "archive_manifest" (
+			"from_created_at" ASC,
+			"to_created_at" ASC
+		);
+		CREATE INDEX IF NOT EXISTS "archive_pruned_uuids_key_index" ON "archive_pruned_uuids" (
+			"key" ASC
+		);
+		`
+		_, err := tx.ExecContext(ctx, query)
+		return err
+	})
+}
+
+var archiveTables = []strictTable{
+	{
+		name: "archive_manifest",
+		columns: `key TEXT NOT NULL PRIMARY KEY,
+		from_created_at INTEGER NOT NULL,
+		to_created_at INTEGER NOT NULL,
+		num_items INTEGER NOT NULL,
+		uploaded_at INTEGER NOT NULL,
+		pruned_at INTEGER NOT NULL DEFAULT 0`,
+		copyColumns: `key, from_created_at, to_created_at, num_items, uploaded_at, pruned_at`,
+	},
+	// segments of pruned events, so the read-through only downloads the
+	// segment holding a requested event
+	{
+		name: "archive_pruned_uuids",
+		columns: `uuid TEXT NOT NULL,
+		key TEXT NOT NULL,
+		PRIMARY KEY (uuid, key)`,
+		copyColumns: `uuid, key`,
+	},
+}
+
+// Archive uploads all events with from <= created_at < to as one segment and
+// records it in the manifest. Payloads are exported as stored (still encrypted
+// if a crypto service is used). A bound store only archives events of its
+// tenant.
+//
+// The segment is read from one read snapshot and streamed to the uploader, so
+// writers only wait for the manifest update. Events written into the range
+// meanwhile are not part of the segment and are kept by Prune.
+func (a *EventArchiver) Archive(ctx context.Context, from, to int64) (*ArchiveSegment, error) {
+	if from >= to {
+		return nil, fmt.Errorf("'%s' failed to archive - invalid range %d..%d", a.es.String(), from, to)
+	}
+	tx, err := beginReadSnapshot(ctx, a.es.db)
+	if err != nil {
+		return nil, fmt.Errorf("'%s' failed to archive - %w", a.es.String(), err)
+	}
+	defer tx.Rollback()
+
+	segment := &ArchiveSegment{
+		Key:           fmt.Sprintf("events/%020d-%020d.ndjson", from, to),
+		FromCreatedAt: from,
+		ToCreatedAt:   to,
+	}
+	if tenant := a.es.cfg().Tenant; len(tenant) > 0 {
+		segment.Key = fmt.Sprintf("events/%s/%020d-%020d.ndjson", tenant, from, to)
+	}
+
+	// write segment as newline delimited json while it is uploaded
+	pr, pw := io.Pipe()
+	written := make(chan error, 1)
+	go func() {
+		err := a.writeSegment(ctx, tx, from, to, pw, &segment.NumItems)
+		pw.CloseWithError(err)
+		written <- err
+	}()
+	err = a.uploader.Upload(ctx, segment.Key, pr)
+	// unblocks the writer if the uploader stopped reading
+	pr.CloseWithError(io.ErrClosedPipe)
+	writeErr := <-written
+	if err != nil {
+		return nil, fmt.Errorf("'%s' failed to upload segment '%s': %w", a.es.String(), segment.Key, err)
+	}
+	if writeErr != nil {
+		return nil, fmt.Errorf("'%s' failed to archive - %w", a.es.String(), writeErr)
+	}
+	segment.UploadedAt = time.Now().UnixNano()
+
+	done, err := a.es.beginWrite(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+	query := `INSERT INTO archive_manifest (key, from_created_at, to_created_at, num_items, uploaded_at, pruned_at)
+		VALUES (?, ?, ?, ?, ?, 0)
+		ON CONFLICT(key) DO UPDATE SET
+			num_items=excluded.num_items,
+			uploaded_at=excluded.uploaded_at,
+			pruned_at=0;`
+	if _, err := a.es.db.ExecContext(ctx, query,
+		segment.Key,
+		segment.FromCreatedAt,
+		segment.ToCreatedAt,
+		segment.NumItems,
+		segment.UploadedAt,
+	); err != nil {
+		return nil, err
+	}
+	return segment, nil
+}
+
+// writeSegment encodes the events with from <= created_at < to as NDJSON into w.
+func (a *EventArchiver) writeSegment(ctx context.Context, q queryer, from, to int64, w io.Writer, numItems *int64) error {
+	whereList, args := tenantCondition(a.es.cfg().Tenant, []string{"created_at>=?", "created_at<?"}, []any{from, to})
+	query := fmt.Sprintf("SELECT %s FROM events WHERE %s ORDER BY created_at ASC, id ASC;", eventSelectColumns, strings.Join(whereList, " AND "))
+	rows, err := q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	enc := json.NewEncoder(w)
+	for rows.Next() {
+		var dbRecord internal.Event
+		if err := scanEvent(rows, &dbRecord); err != nil {
+			return err
+		}
+		if err := enc.Encode(&archiveRecord{ID: dbRecord.ID.Int64, Event: &dbRecord}); err != nil {
+			return err
+		}
+		*numItems++
+	}
+	if err := rows.Close(); err != nil {
+		return err
+	}
+	return rows.Err()
+}
+
+// Segments returns all archived segments ordered by range.
+func (a *EventArchiver) Segments(ctx context.Context) ([]*ArchiveSegment, error) {
+	query := `SELECT key, from_created_at, to_created_at, num_items, uploaded_at, pruned_at
+		FROM archive_manifest ORDER BY from_created_at ASC;`
+	rows, err := a.es.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var segments []*ArchiveSegment
+	for rows.Next() {
+		var segment ArchiveSegment
+		if err := rows.Scan(
+			&segment.Key,
+			&segment.FromCreatedAt,
+			&segment.ToCreatedAt,
+			&segment.NumItems,
+			&segment.UploadedAt,
+			&segment.PrunedAt,
+		); err != nil {
+			return nil, err
+		}
+		segments = append(segments, &segment)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return segments, nil
+}
+
+// Prune removes the local events listed in an uploaded segment. Events written
+// into the range of the segment after it was archived are kept.
+func (a *EventArchiver) Prune(ctx context.Context, key string) (int64, error) {
+	if a.es.opts().ReadOnly {
+		return 0, fmt.Errorf("'%s' failed to prune - instance is readonly", a.es.String())
+	}
+	if _, err := a.segment(ctx, key); err != nil {
+		return 0, err
+	}
+
+	// only delete what is known to be in the archive
+	var uuids []string
+	if err := a.readSegment(ctx, key, func(dbRecord *archiveRecord) error {
+		uuids = append(uuids, dbRecord.Uuid)
+		return nil
+	}); err != nil {
+		return 0, err
+	}
+
+	done, err := a.es.beginWrite(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer done()
+	tx, err := a.es.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var numDeleted int64
+	for _, uuid := range uuids {
+		if err := markPrunedVersion(ctx, tx, uuid); err != nil {
+			return 0, err
+		}
+		res, err := tx.ExecContext(ctx, "DELETE FROM event_records WHERE uuid=?;", uuid)
+		if err != nil {
+			return 0, err
+		}
+		if _, err := tx.ExecContext(ctx, "INSERT OR IGNORE INTO archive_pruned_uuids (uuid, key) VALUES (?, ?);", uuid, key); err != nil {
+			return 0, err
+		}
+		n, err := res.RowsAffected()
+		if err != nil {
+			return 0, err
+		}
+		numDeleted += n
+	}
+	if _, err := tx.ExecContext(ctx, "UPDATE archive_manifest SET pruned_at=? WHERE key=?;", time.Now().UnixNano(), key); err != nil {
+		return 0, err
+	}
+	if err := insertAuditEntry(ctx, tx, newAuditEntry(ctx, "events", AdminOperationPrune, key, numDeleted)); err != nil {
+		return 0, err
+	}
+	return numDeleted, tx.Commit()
+}
+
+// Restore downloads a segment and inserts its events back into the event store.
+// Events which already exist locally are skipped. Writers only wait for the
+// insert, the segment is downloaded into a temporary file before.
+func (a *EventArchiver) Restore(ctx context.Context, key string) (int64, error) {
+	if a.es.opts().ReadOnly {
+		return 0, fmt.Errorf("'%s' failed to restore - instance is readonly", a.es.String())
+	}
+	if _, err := a.segment(ctx, key); err != nil {
+		return 0, err
+	}
+	f, err := a.downloadSegment(ctx, key, "")
+	if err != nil {
+		return 0, err
+	}
+	defer removeTempFile(f)
+
+	done, err := a.es.beginWrite(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer done()
+	numRestored, err := a.restoreInto(ctx, a.es.db, key, f)
+	if err != nil {
+		return 0, err
+	}
+	if _, err := a.es.db.ExecContext(ctx, "UPDATE archive_manifest SET pruned_at=0 WHERE key=?;", key); err != nil {
+		return 0, err
+	}
+	if _, err := a.es.db.ExecContext(ctx, "DELETE FROM archive_pruned_uuids WHERE key=?;", key); err != nil {
+		return 0, err
+	}
+	return numRestored, nil
+}
+
+// archiveRecord is one line of a segment. The id is exported as well, so
+// restored events keep their position in the store order used by Replay.
+type archiveRecord struct {
+	ID int64 `json:"id"`
+	*internal.Event
+}
+
+// readSegment downloads a segment and calls fn for each of its events.
+func (a *EventArchiver) readSegment(ctx context.Context, key string, fn func(dbRecord *archiveRecord) error) error {
+	r, err := a.uploader.Download(ctx, key)
+	if err != nil {
+		return fmt.Errorf("'%s' failed to download segment '%s': %w", a.es.String(), key, err)
+	}
+	defer r.Close()
+	return a.decodeSegment(key, r, fn)
+}
+
+// downloadSegment downloads a segment into a temporary file in dir (or the
+// default temp dir if empty), so it can be inserted without waiting for the
+// uploader. The caller removes it with removeTempFile.
+func (a *EventArchiver) downloadSegment(ctx context.Context, key, dir string) (*os.File, error) {
+	r, err := a.uploader.Download(ctx, key)
+	if err != nil {
+		return nil, fmt.Errorf("'%s' failed to download segment '%s': %w", a.es.String(), key, err)
+	}
+	defer r.Close()
+
+	f, err := os.CreateTemp(dir, "comby-segment-")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		removeTempFile(f)
+		return nil, fmt.Errorf("'%s' failed to download segment '%s': %w", a.es.String(), key, err)
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		removeTempFile(f)
+		return nil, err
+	}
+	return f, nil
+}
+
+func removeTempFile(f *os.File) {
+	f.Close()
+	os.Remove(f.Name())
+}
+
+// decodeSegment calls fn for each event of the segment read from r.
+func (a *EventArchiver) decodeSegment(key string, r io.Reader, fn func(dbRecord *archiveRecord) error) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+		dbRecord := &archiveRecord{Event: &internal.Event{}}
+		if err := json.Unmarshal(line, dbRecord); err != nil {
+			return fmt.Errorf("'%s' failed to decode segment '%s': %w", a.es.String(), key, err)
+		}
+		if err := fn(dbRecord); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+// restoreInto inserts the events of the segment read from r into db.
+func (a *EventArchiver) restoreInto(ctx context.Context, db *sql.DB, key string, r io.Reader) (int64, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	// rows inserted through the events view are not reported as affected,
+	// so restored events are counted on the underlying table instead
+	var numBefore int64
+	if err := tx.QueryRowContext(ctx, "SELECT COUNT(*) FROM event_records;").Scan(&numBefore); err != nil {
+		return 0, err
+	}
+
+	if err := a.decodeSegment(key, r, func(dbRecord *archiveRecord) error {
+		// keep the original id unless it was taken by another event in the meantime
+		// (or the segment was written by a version not exporting ids)
+		id := sql.NullInt64{Int64: dbRecord.ID, Valid: dbRecord.ID > 0}
+		if id.Valid {
+			var uuid string
+			err := tx.QueryRowContext(ctx, "SELECT uuid FROM event_records WHERE id=?;", id.Int64).Scan(&uuid)
+			switch {
+			case err == sql.ErrNoRows:
+			case err != nil:
+				return err
+			case uuid != dbRecord.Uuid:
+				loggerOrDiscard(a.es.cfg().Logger).WarnContext(ctx, "restored event gets a new id", "uuid", dbRecord.Uuid, "id", id.Int64)
+				id.Valid = false
+			}
+		}
+		return insertEventRecord(ctx, tx, id, dbRecord.Event)
+	}); err != nil {
+		return 0, err
+	}
+
+	var numAfter int64
+	if err := tx.QueryRowContext(ctx, "SELECT COUNT(*) FROM event_records;").Scan(&numAfter); err != nil {
+		return 0, err
+	}
+	return numAfter - numBefore, tx.Commit()
+}
+
+// insertEventRecord inserts a record as stored (payload still encrypted, if
+// so), events with a known uuid are ignored. A NULL id appends the event.
+func insertEventRecord(ctx context.Context, q queryer, id sql.NullInt64, dbRecord *internal.Event) error {
+	query := `INSERT OR IGNORE INTO events (
+	id,
+	instance_id,
+	uuid,
+	tenant_uuid,
+	workspace_uuid,
+	command_uuid,
+	domain,
+	aggregate_uuid,
+	version,
+	created_at,
+	data_type,
+	data_bytes,
+	req_ctx,
+	checksum,
+	is_encrypted
+) VALUES (?,?,?,?,?,?,?,?,?,?,?,?,?,?,?);`
+	_, err := q.ExecContext(ctx, query,
+		id,
+		dbRecord.InstanceId,
+		dbRecord.Uuid,
+		dbRecord.TenantUuid,
+		dbRecord.WorkspaceUuid,
+		dbRecord.CommandUuid,
+		dbRecord.Domain,
+		dbRecord.AggregateUuid,
+		dbRecord.Version,
+		dbRecord.CreatedAt,
+		dbRecord.DataType,
+		dbRecord.DataBytes,
+		dbRecord.ReqCtx,
+		dbRecord.Checksum,
+		dbRecord.IsEncrypted,
+	)
+	return err
+}
+
+func (a *EventArchiver) segment(ctx context.Context, key string) (*ArchiveSegment, error) {
+	query := `SELECT key, from_created_at, to_created_at, num_items, uploaded_at, pruned_at
+		FROM archive_manifest WHERE key=? LIMIT 1;`
+	var segment ArchiveSegment
+	if err := a.es.db.QueryRowContext(ctx, query, key).Scan(
+		&segment.Key,
+		&segment.FromCreatedAt,
+		&segment.ToCreatedAt,
+		&segment.NumItems,
+		&segment.UploadedAt,
+		&segment.PrunedAt,
+	); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("'%s' archive segment '%s' %w", a.es.String(), key, ErrNotFound)
+		}
+		return nil, err
+	}
+	return &segment, nil
+}
+
+// EnableReadThrough makes Get and List of the event store consult the archive
+// manifest: pruned segments overlapping a request are lazily downloaded into a
+// temporary database (created in dir, or the default temp dir if empty), which
+// is attached for the duration of the query. Enable before serving requests.
+//
+// Prune records the uuids of the pruned events, so a Get for an event which is
+// not stored locally only downloads the segment holding it, and none for an
+// unknown uuid. Segments pruned by versions not recording uuids are all
+// downloaded on such a miss, misses are cached until another segment is
+// hydrated.
+func (a *EventArchiver) EnableReadThrough(ctx context.Context, dir string) error {
+	if a.es.readThrough.Load() != nil {
+		return nil
+	}
+	tmpDir, err := os.MkdirTemp(dir, "comby-archive-")
+	if err != nil {
+		return err
+	}
+
+	// hydrated events are stored as is, decryption happens in the event store
+	hydrated := &eventStoreSQLite{
+		path:     filepath.Join(tmpDir, "hydrated.db"),
+		sharedDB: sharedDB{writeMu: &sync.Mutex{}},
+	}
+	if err := hydrated.Init(ctx); err != nil {
+		os.RemoveAll(tmpDir)
+		return err
+	}
+	rt := &archiveReadThrough{
+		archiver: a,
+		dir:      tmpDir,
+		hydrated: hydrated,
+		segments: map[string]bool{},
+		loading:  map[string]*segmentLoad{},
+		missing:  map[string]bool{},
+	}
+	if !a.es.readThrough.CompareAndSwap(nil, rt) {
+		// enabled concurrently
+		return rt.close(ctx)
+	}
+	return nil
+}
+
+// DisableReadThrough stops consulting the archive and removes the temporary database.
+func (a *EventArchiver) DisableReadThrough(ctx context.Context) error {
+	if rt := a.es.readThrough.Swap(nil); rt != nil {
+		return rt.close(ctx)
+	}
+	return nil
+}
+
+// maxMissingUuids bounds the cache of uuids not found in the archive
+const maxMissingUuids = 10000
+
+type archiveReadThrough struct {
+	archiver *EventArchiver
+	dir      string
+	hydrated *eventStoreSQLite
+
+	mu       sync.Mutex
+	segments map[string]bool
+	// downloads in progress by segment key
+	loading map[string]*segmentLoad
+	// uuids neither stored locally nor in any hydrated segment
+	missing map[string]bool
+
+	// held by queries, so the hydrated database is not closed while in use
+	closeMu sync.RWMutex
+	closed  bool
+}
+
+// hydrate downloads all pruned segments overlapping after < created_at < before
+// (-1 means unbounded) and reports whether any archived range is involved.
+func (rt *archiveReadThrough) hydrate(ctx context.Context, after, before int64) (bool, error) {
+	query := `SELECT key FROM archive_manifest
+		WHERE pruned_at>0 AND (?<0 OR to_created_at-1>?) AND (?<0 OR from_created_at<?)
+		ORDER BY from_created_at ASC;`
+	rows, err := rt.archiver.es.db.QueryContext(ctx, query, after, after, before, before)
+	if err != nil {
+		return false, err
+	}
+	defer rows.Close()
+
+	var keys []string
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			return false, err
+		}
+		keys = append(keys, key)
+	}
+	if err := rows.Close(); err != nil {
+		return false, err
+	}
+	if err := rows.Err(); err != nil {
+		return false, err
+	}
+
+	for _, key := range keys {
+		if err := rt.hydrateSegment(ctx, key); err != nil {
+			return false, err
+		}
+	}
+	return len(keys) > 0, nil
+}
+
+// segmentLoad is a download of a segment other queries may wait for.
+type segmentLoad struct {
+	done chan struct{}
+	err  error
+}
+
+// hydrateSegment downloads a segment into the hydrated database unless it is
+// there already. Concurrent queries needing the same segment wait for one
+// download; rt.mu is not held meanwhile, so queries of hydrated segments
+// continue.
+func (rt *archiveReadThrough) hydrateSegment(ctx context.Context, key string) error {
+	rt.mu.Lock()
+	if rt.segments[key] {
+		rt.mu.Unlock()
+		return nil
+	}
+	if load, ok := rt.loading[key]; ok {
+		rt.mu.Unlock()
+		select {
+		case <-load.done:
+			return load.err
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	load := &segmentLoad{done: make(chan struct{})}
+	rt.loading[key] = load
+	rt.mu.Unlock()
+
+	load.err = rt.loadSegment(ctx, key)
+
+	rt.mu.Lock()
+	delete(rt.loading, key)
+	if load.err == nil {
+		rt.segments[key] = true
+		// a new segment may contain previously missing events
+		clear(rt.missing)
+	}
+	rt.mu.Unlock()
+	close(load.done)
+	return load.err
+}
+
+func (rt *archiveReadThrough) loadSegment(ctx context.Context, key string) error {
+	f, err := rt.archiver.downloadSegment(ctx, key, rt.dir)
+	if err != nil {
+		return err
+	}
+	defer removeTempFile(f)
+
+	// segments are inserted one at a time
+	rt.hydrated.writeMu.Lock()
+	defer rt.hydrated.writeMu.Unlock()
+	_, err = rt.archiver.restoreInto(ctx, rt.hydrated.db, key, f)
+	return err
+}
+
+// segmentsOf returns the pruned segments which may hold the event: the ones
+// it was pruned from, and those pruned without recording their uuids.
+func (rt *archiveReadThrough) segmentsOf(ctx context.Context, eventUuid string) ([]string, error) {
+	query := `SELECT m.key FROM archive_manifest m
+		WHERE m.pruned_at>0 AND (
+			EXISTS (SELECT 1 FROM archive_pruned_uuids u WHERE u.key=m.key AND u.uuid=?)
+			OR NOT EXISTS (SELECT 1 FROM archive_pruned_uuids u WHERE u.key=m.key)
+		)
+		ORDER BY m.from_created_at ASC;`
+	rows, err := rt.archiver.es.db.QueryContext(ctx, query, eventUuid)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var keys []string
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, rows.Err()
+}
+
+func (rt *archiveReadThrough) get(ctx context.Context, eventUuid string) (comby.Event, error) {
+	rt.closeMu.RLock()
+	defer rt.closeMu.RUnlock()
+	if rt.closed {
+		return nil, nil
+	}
+	rt.mu.Lock()
+	missing := rt.missing[eventUuid]
+	rt.mu.Unlock()
+	if missing {
+		return nil, nil
+	}
+	keys, err := rt.segmentsOf(ctx, eventUuid)
+	if err != nil || len(keys) == 0 {
+		return nil, err
+	}
+	for _, key := range keys {
+		if err := rt.hydrateSegment(ctx, key); err != nil {
+			return nil, err
+		}
+	}
+
+	evt, err := rt.archiver.es.get(ctx, rt.hydrated.db, "events", eventUuid)
+	if err == nil && evt == nil {
+		rt.mu.Lock()
+		if len(rt.missing) >= maxMissingUuids {
+			clear(rt.missing)
+		}
+		rt.missing[eventUuid] = true
+		rt.mu.Unlock()
+	}
+	return evt, err
+}
+
+func (rt *archiveReadThrough) list(ctx context.Context, listOpts comby.EventStoreListOptions, filter eventFilter) ([]comby.Event, int64, error) {
+	es := rt.archiver.es
+	rt.closeMu.RLock()
+	defer rt.closeMu.RUnlock()
+	if rt.closed {
+		return es.list(ctx, es.db, "events", listOpts, filter)
+	}
+	ok, err := rt.hydrate(ctx, listOpts.After, listOpts.Before)
+	if err != nil {
+		return nil, 0, err
+	}
+	if !ok {
+		return es.list(ctx, es.db, "events", listOpts, filter)
+	}
+
+	// attach hydrated database on a dedicated connection and query the union of both
+	conn, err := es.db.Conn(ctx)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer conn.Close()
+	if _, err := conn.ExecContext(ctx, "ATTACH DATABASE ? AS archive;", rt.hydrated.path); err != nil {
+		return nil, 0, err
+	}
+	defer conn.ExecContext(context.Background(), "DETACH DATABASE archive;")
+
+	source := fmt.Sprintf(`(SELECT %s FROM main.events
+		UNION ALL
+		SELECT %s FROM archive.events WHERE uuid NOT IN (SELECT uuid FROM main.events)) AS events`, eventViewColumns, eventViewColumns)
+	return es.list(ctx, conn, source, listOpts, filter)
+}
+
+func (rt *archiveReadThrough) close(ctx context.Context) error {
+	rt.closeMu.Lock()
+	defer rt.closeMu.Unlock()
+	rt.closed = true
+	if err := rt.hydrated.Close(ctx); err != nil {
+		return err
+	}
+	return os.RemoveAll(rt.dir)
+}
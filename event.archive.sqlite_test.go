@@ -0,0 +1,691 @@
+package store_test
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	store "github.com/gradientzero/comby-store-sqlite"
+	"github.com/gradientzero/comby/v3"
+)
+
+// memoryUploader keeps uploaded segments in memory
+type memoryUploader struct {
+	mu        sync.Mutex
+	objects   map[string][]byte
+	downloads int
+}
+
+func newMemoryUploader() *memoryUploader {
+	return &memoryUploader{objects: map[string][]byte{}}
+}
+
+func (u *memoryUploader) Upload(ctx context.Context, key string, r io.Reader) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.objects[key] = data
+	return nil
+}
+
+func (u *memoryUploader) Download(ctx context.Context, key string) (io.ReadCloser, error) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.downloads++
+	data, ok := u.objects[key]
+	if !ok {
+		return nil, fmt.Errorf("object %s not found", key)
+	}
+	return io.NopCloser(bytes.NewReader(data)), nil
+}
+
+func TestEventArchiver_ArchivePruneRestore(t *testing.T) {
+	ctx := context.Background()
+	eventStore := store.NewEventStoreSQLite(filepath.Join(t.TempDir(), "events.db"))
+	if err := eventStore.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer eventStore.Close(ctx)
+
+	for i := int64(1); i <= 10; i++ {
+		if err := eventStore.Create(ctx,
+			comby.EventStoreCreateOptionWithEvent(createTestEvent("tenant-1", "domain-1", i, i*100)),
+		); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	uploader := newMemoryUploader()
+	archiver, err := store.NewEventArchiver(eventStore, uploader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := archiver.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// archive the first five events
+	segment, err := archiver.Archive(ctx, 0, 600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if segment.NumItems != 5 {
+		t.Fatalf("wrong number of archived items: %d", segment.NumItems)
+	}
+	if _, ok := uploader.objects[segment.Key]; !ok {
+		t.Fatalf("segment %s was not uploaded", segment.Key)
+	}
+
+	// prune local copy
+	if n, err := archiver.Prune(ctx, segment.Key); err != nil {
+		t.Fatal(err)
+	} else if n != 5 {
+		t.Fatalf("wrong number of pruned items: %d", n)
+	}
+	if eventStore.Total(ctx) != 5 {
+		t.Fatalf("wrong total %d", eventStore.Total(ctx))
+	}
+	segments, err := archiver.Segments(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(segments) != 1 || segments[0].PrunedAt == 0 {
+		t.Fatalf("segment should be marked as pruned: %+v", segments)
+	}
+	entries, err := eventStore.ListAdminAudit(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Operation != store.AdminOperationPrune || entries[0].Target != segment.Key || entries[0].Rows != 5 {
+		t.Fatalf("expected audited prune, got %+v", entries)
+	}
+
+	// restore from archive
+	if n, err := archiver.Restore(ctx, segment.Key); err != nil {
+		t.Fatal(err)
+	} else if n != 5 {
+		t.Fatalf("wrong number of restored items: %d", n)
+	}
+	if eventStore.Total(ctx) != 10 {
+		t.Fatalf("wrong total %d", eventStore.Total(ctx))
+	}
+
+	// restored events are complete
+	evts, _, err := eventStore.List(ctx, comby.EventStoreListOptionOrderBy("created_at"), comby.EventStoreListOptionAscending(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(evts[0].GetDomainEvtBytes()) != "test-data-1" {
+		t.Fatalf("wrong data bytes: %q", evts[0].GetDomainEvtBytes())
+	}
+
+	// restoring twice does not duplicate events
+	if n, err := archiver.Restore(ctx, segment.Key); err != nil {
+		t.Fatal(err)
+	} else if n != 0 {
+		t.Fatalf("expected no restored items, got %d", n)
+	}
+}
+
+func TestEventArchiver_RestoreKeepsEncryptionState(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "events.db")
+
+	// plaintext events written before encryption was enabled
+	plainStore := store.NewEventStoreSQLite(path)
+	if err := plainStore.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	for i := int64(1); i <= 2; i++ {
+		if err := plainStore.Create(ctx, comby.EventStoreCreateOptionWithEvent(createTestEvent("tenant-1", "domain-1", i, i*100))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	plainStore.Close(ctx)
+
+	cryptoService, _ := comby.NewCryptoService([]byte("12345678901234567890123456789012"))
+	eventStore := store.NewEventStoreSQLite(path)
+	if err := eventStore.Init(ctx, comby.EventStoreOptionWithCryptoService(cryptoService)); err != nil {
+		t.Fatal(err)
+	}
+	defer eventStore.Close(ctx)
+	encrypted := createTestEvent("tenant-1", "domain-1", 3, 300)
+	if err := eventStore.Create(ctx, comby.EventStoreCreateOptionWithEvent(encrypted)); err != nil {
+		t.Fatal(err)
+	}
+
+	archiver, err := store.NewEventArchiver(eventStore, newMemoryUploader())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := archiver.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	segment, err := archiver.Archive(ctx, 0, 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n, err := archiver.Prune(ctx, segment.Key); err != nil || n != 3 {
+		t.Fatalf("expected 3 pruned events, got %d, %v", n, err)
+	}
+	if n, err := archiver.Restore(ctx, segment.Key); err != nil || n != 3 {
+		t.Fatalf("expected 3 restored events, got %d, %v", n, err)
+	}
+
+	// restored rows keep their state, plaintext rows are not decrypted
+	db, err := sql.Open(store.DefaultDriverName(), path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	var numEncrypted, numPlain int
+	if err := db.QueryRow("SELECT COUNT(*) FILTER (WHERE is_encrypted=1), COUNT(*) FILTER (WHERE is_encrypted=0) FROM event_records;").Scan(&numEncrypted, &numPlain); err != nil {
+		t.Fatal(err)
+	}
+	if numEncrypted != 1 || numPlain != 2 {
+		t.Fatalf("expected 1 encrypted and 2 plaintext rows, got %d and %d", numEncrypted, numPlain)
+	}
+	evts, _, err := eventStore.List(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, evt := range evts {
+		if expected := fmt.Sprintf("test-data-%d", evt.GetVersion()); string(evt.GetDomainEvtBytes()) != expected {
+			t.Fatalf("expected %s, got %s", expected, evt.GetDomainEvtBytes())
+		}
+	}
+
+	// a store without crypto service does not return the ciphertext
+	eventStore.Close(ctx)
+	readStore := store.NewEventStoreSQLite(path)
+	if err := readStore.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer readStore.Close(ctx)
+	if _, err := readStore.Get(ctx, comby.EventStoreGetOptionWithEventUuid(encrypted.GetEventUuid())); !errors.Is(err, store.ErrNoCryptoService) {
+		t.Fatalf("expected missing crypto service, got %v", err)
+	}
+}
+
+func TestEventArchiver_PruneKeepsLateEvents(t *testing.T) {
+	ctx := context.Background()
+	eventStore := store.NewEventStoreSQLite(filepath.Join(t.TempDir(), "events.db"))
+	if err := eventStore.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer eventStore.Close(ctx)
+
+	for i := int64(1); i <= 3; i++ {
+		if err := eventStore.Create(ctx,
+			comby.EventStoreCreateOptionWithEvent(createTestEvent("tenant-1", "domain-1", i, i*100)),
+		); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	uploader := newMemoryUploader()
+	archiver, err := store.NewEventArchiver(eventStore, uploader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := archiver.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	segment, err := archiver.Archive(ctx, 0, 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// written into the archived range after the upload
+	late := createTestEvent("tenant-1", "domain-1", 4, 400)
+	if err := eventStore.Create(ctx, comby.EventStoreCreateOptionWithEvent(late)); err != nil {
+		t.Fatal(err)
+	}
+	if err := eventStore.Create(ctx,
+		comby.EventStoreCreateOptionWithEvent(createTestEvent("tenant-1", "domain-1", 5, 2000)),
+	); err != nil {
+		t.Fatal(err)
+	}
+
+	if n, err := archiver.Prune(ctx, segment.Key); err != nil {
+		t.Fatal(err)
+	} else if n != 3 {
+		t.Fatalf("expected 3 pruned events, got %d", n)
+	}
+	if evt, err := eventStore.Get(ctx, comby.EventStoreGetOptionWithEventUuid(late.GetEventUuid())); err != nil {
+		t.Fatal(err)
+	} else if evt == nil {
+		t.Fatal("event written after archiving must not be pruned")
+	}
+
+	// restored events keep their position in store order
+	if _, err := archiver.Restore(ctx, segment.Key); err != nil {
+		t.Fatal(err)
+	}
+	var versions []int64
+	if _, err := eventStore.Replay(ctx, func(ctx context.Context, seq int64, evt comby.Event) error {
+		versions = append(versions, evt.GetVersion())
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(versions) != "[1 2 3 4 5]" {
+		t.Fatalf("wrong replay order after restore: %v", versions)
+	}
+}
+
+func TestEventArchiver_UnknownSegment(t *testing.T) {
+	ctx := context.Background()
+	eventStore := store.NewEventStoreSQLite(filepath.Join(t.TempDir(), "events.db"))
+	if err := eventStore.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer eventStore.Close(ctx)
+
+	archiver, err := store.NewEventArchiver(eventStore, newMemoryUploader())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := archiver.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := archiver.Restore(ctx, "events/unknown.ndjson"); err == nil {
+		t.Fatal("expected error for unknown segment")
+	}
+}
+
+func TestEventArchiver_ReadThrough(t *testing.T) {
+	ctx := context.Background()
+	eventStore := store.NewEventStoreSQLite(filepath.Join(t.TempDir(), "events.db"))
+	if err := eventStore.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer eventStore.Close(ctx)
+
+	var evts []comby.Event
+	for i := int64(1); i <= 10; i++ {
+		evt := createTestEvent("tenant-1", "domain-1", i, i*100)
+		if err := eventStore.Create(ctx,
+			comby.EventStoreCreateOptionWithEvent(evt),
+		); err != nil {
+			t.Fatal(err)
+		}
+		evts = append(evts, evt)
+	}
+
+	archiver, err := store.NewEventArchiver(eventStore, newMemoryUploader())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := archiver.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	segment, err := archiver.Archive(ctx, 0, 600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := archiver.Prune(ctx, segment.Key); err != nil {
+		t.Fatal(err)
+	}
+
+	// without read-through pruned events are gone
+	if evt, err := eventStore.Get(ctx, comby.EventStoreGetOptionWithEventUuid(evts[0].GetEventUuid())); err != nil {
+		t.Fatal(err)
+	} else if evt != nil {
+		t.Fatalf("expected pruned event to be missing")
+	}
+
+	if err := archiver.EnableReadThrough(ctx, t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+
+	// pruned event is hydrated from archive
+	if evt, err := eventStore.Get(ctx, comby.EventStoreGetOptionWithEventUuid(evts[0].GetEventUuid())); err != nil {
+		t.Fatal(err)
+	} else if evt == nil {
+		t.Fatalf("expected pruned event to be restored from archive")
+	} else if string(evt.GetDomainEvtBytes()) != "test-data-1" {
+		t.Fatalf("wrong data bytes: %q", evt.GetDomainEvtBytes())
+	}
+
+	// list covers local and archived events
+	list, total, err := eventStore.List(ctx, comby.EventStoreListOptionOrderBy("created_at"), comby.EventStoreListOptionAscending(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 10 || len(list) != 10 {
+		t.Fatalf("expected 10 events, got %d (total %d)", len(list), total)
+	}
+	for i, evt := range list {
+		if evt.GetEventUuid() != evts[i].GetEventUuid() {
+			t.Fatalf("event %d: wrong order", i)
+		}
+	}
+
+	// local store is untouched
+	if eventStore.Total(ctx) != 5 {
+		t.Fatalf("wrong total %d", eventStore.Total(ctx))
+	}
+
+	if err := archiver.DisableReadThrough(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, total, err := eventStore.List(ctx); err != nil {
+		t.Fatal(err)
+	} else if total != 5 {
+		t.Fatalf("expected 5 local events, got %d", total)
+	}
+}
+
+func TestEventArchiver_ReadThroughMissingEvents(t *testing.T) {
+	ctx := context.Background()
+	eventStore := store.NewEventStoreSQLite(filepath.Join(t.TempDir(), "events.db"))
+	if err := eventStore.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer eventStore.Close(ctx)
+
+	var evts []comby.Event
+	for i := int64(1); i <= 4; i++ {
+		evt := createTestEvent("tenant-1", "domain-1", i, i*100)
+		if err := eventStore.Create(ctx, comby.EventStoreCreateOptionWithEvent(evt)); err != nil {
+			t.Fatal(err)
+		}
+		evts = append(evts, evt)
+	}
+	uploader := newMemoryUploader()
+	archiver, err := store.NewEventArchiver(eventStore, uploader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := archiver.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range [][2]int64{{0, 250}, {250, 1000}} {
+		segment, err := archiver.Archive(ctx, r[0], r[1])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := archiver.Prune(ctx, segment.Key); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := archiver.EnableReadThrough(ctx, t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+
+	// concurrent readers of the same unknown uuid
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 10; j++ {
+				if _, err := eventStore.Get(ctx, comby.EventStoreGetOptionWithEventUuid("unknown")); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	// unknown uuids download no segment, a pruned event only its own
+	downloads := func() int {
+		uploader.mu.Lock()
+		defer uploader.mu.Unlock()
+		return uploader.downloads
+	}
+	if n := downloads(); n != 2 {
+		t.Fatalf("expected 2 downloads for pruning, got %d", n)
+	}
+	if evt, err := eventStore.Get(ctx, comby.EventStoreGetOptionWithEventUuid(evts[3].GetEventUuid())); err != nil || evt == nil {
+		t.Fatalf("expected pruned event from the archive, got %v, %v", evt, err)
+	}
+	if n := downloads(); n != 3 {
+		t.Fatalf("expected 1 download for hydration, got %d", n-2)
+	}
+
+	// readers race with disabling the read-through
+	var disable sync.WaitGroup
+	disable.Add(1)
+	go func() {
+		defer disable.Done()
+		archiver.DisableReadThrough(ctx)
+	}()
+	for j := 0; j < 10; j++ {
+		if _, _, err := eventStore.List(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	disable.Wait()
+}
+
+// blockingUploader holds uploads until released
+type blockingUploader struct {
+	*memoryUploader
+	started chan struct{}
+	release chan struct{}
+}
+
+func (u *blockingUploader) Upload(ctx context.Context, key string, r io.Reader) error {
+	close(u.started)
+	<-u.release
+	return u.memoryUploader.Upload(ctx, key, r)
+}
+
+func TestEventArchiver_WritesDuringUpload(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "events.db")
+	eventStore := store.NewEventStoreSQLite(path)
+	if err := eventStore.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	for i := int64(1); i <= 4; i++ {
+		tenantUuid := fmt.Sprintf("tenant-%d", i%2+1)
+		if err := eventStore.Create(ctx, comby.EventStoreCreateOptionWithEvent(createTestEvent(tenantUuid, "domain-1", i, i*100))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	eventStore.Close(ctx)
+
+	// archives of a bound store only contain events of its tenant
+	eventStore = store.NewEventStoreSQLite(path)
+	eventStore.Configure(store.EventStoreSQLiteWithTenant("tenant-1"))
+	if err := eventStore.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer eventStore.Close(ctx)
+	uploader := &blockingUploader{memoryUploader: newMemoryUploader(), started: make(chan struct{}), release: make(chan struct{})}
+	archiver, err := store.NewEventArchiver(eventStore, uploader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := archiver.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	type result struct {
+		segment *store.ArchiveSegment
+		err     error
+	}
+	archived := make(chan result, 1)
+	go func() {
+		segment, err := archiver.Archive(ctx, 0, 1000)
+		archived <- result{segment, err}
+	}()
+
+	// writers do not wait for the upload
+	<-uploader.started
+	late := createTestEvent("tenant-1", "domain-1", 5, 250)
+	if err := eventStore.Create(ctx, comby.EventStoreCreateOptionWithEvent(late)); err != nil {
+		t.Fatal(err)
+	}
+	close(uploader.release)
+	res := <-archived
+	if res.err != nil {
+		t.Fatal(res.err)
+	}
+	if res.segment.NumItems != 2 {
+		t.Fatalf("expected 2 archived events of tenant-1, got %d", res.segment.NumItems)
+	}
+
+	// the event written meanwhile is not part of the segment and kept
+	if n, err := archiver.Prune(ctx, res.segment.Key); err != nil || n != 2 {
+		t.Fatalf("expected 2 pruned events, got %d, %v", n, err)
+	}
+	if evt, err := eventStore.Get(ctx, comby.EventStoreGetOptionWithEventUuid(late.GetEventUuid())); err != nil || evt == nil {
+		t.Fatalf("expected late event to be kept, got %v, %v", evt, err)
+	}
+}
+
+func TestEventArchiver_ReadThroughOrderByDataSize(t *testing.T) {
+	ctx := context.Background()
+	eventStore := store.NewEventStoreSQLite(filepath.Join(t.TempDir(), "events.db"))
+	if err := eventStore.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer eventStore.Close(ctx)
+
+	var evts []comby.Event
+	for i := int64(1); i <= 6; i++ {
+		evt := createTestEvent("tenant-1", "domain-1", i, i*100)
+		evt.SetDomainEvtBytes(bytes.Repeat([]byte("x"), int(i)))
+		if err := eventStore.Create(ctx, comby.EventStoreCreateOptionWithEvent(evt)); err != nil {
+			t.Fatal(err)
+		}
+		evts = append(evts, evt)
+	}
+	archiver, err := store.NewEventArchiver(eventStore, newMemoryUploader())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := archiver.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	segment, err := archiver.Archive(ctx, 0, 400)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := archiver.Prune(ctx, segment.Key); err != nil {
+		t.Fatal(err)
+	}
+	if err := archiver.EnableReadThrough(ctx, t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+
+	// archived events are ordered by their size as well
+	list, total, err := eventStore.List(ctx, comby.EventStoreListOptionOrderBy("data_size"), comby.EventStoreListOptionAscending(false))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 6 || len(list) != 6 {
+		t.Fatalf("expected 6 events, got %d (total %d)", len(list), total)
+	}
+	for i, evt := range list {
+		if evt.GetEventUuid() != evts[len(evts)-1-i].GetEventUuid() {
+			t.Fatalf("event %d: wrong order", i)
+		}
+	}
+}
+
+// blockingDownloader holds downloads of one key until released
+type blockingDownloader struct {
+	*memoryUploader
+	key     string
+	started chan struct{}
+	release chan struct{}
+}
+
+func (u *blockingDownloader) Download(ctx context.Context, key string) (io.ReadCloser, error) {
+	if key == u.key {
+		close(u.started)
+		<-u.release
+	}
+	return u.memoryUploader.Download(ctx, key)
+}
+
+func TestEventArchiver_ReadThroughDuringDownload(t *testing.T) {
+	ctx := context.Background()
+	eventStore := store.NewEventStoreSQLite(filepath.Join(t.TempDir(), "events.db"))
+	if err := eventStore.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer eventStore.Close(ctx)
+	var last comby.Event
+	for i := int64(1); i <= 4; i++ {
+		last = createTestEvent("tenant-1", "domain-1", i, i*100)
+		if err := eventStore.Create(ctx, comby.EventStoreCreateOptionWithEvent(last)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	uploader := &blockingDownloader{memoryUploader: newMemoryUploader(), started: make(chan struct{}), release: make(chan struct{})}
+	archiver, err := store.NewEventArchiver(eventStore, uploader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := archiver.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range [][2]int64{{0, 250}, {250, 1000}} {
+		segment, err := archiver.Archive(ctx, r[0], r[1])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := archiver.Prune(ctx, segment.Key); err != nil {
+			t.Fatal(err)
+		}
+		uploader.key = segment.Key
+	}
+	if err := archiver.EnableReadThrough(ctx, t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	listFirst := func() error {
+		_, _, err := eventStore.List(ctx, func(opts *comby.EventStoreListOptions) (*comby.EventStoreListOptions, error) {
+			opts.Before = 250
+			return opts, nil
+		})
+		return err
+	}
+	if err := listFirst(); err != nil {
+		t.Fatal(err)
+	}
+
+	// a pruned event of the second segment waits for its download
+	got := make(chan error, 1)
+	go func() {
+		evt, err := eventStore.Get(ctx, comby.EventStoreGetOptionWithEventUuid(last.GetEventUuid()))
+		if err == nil && evt == nil {
+			err = errors.New("pruned event not found")
+		}
+		got <- err
+	}()
+	<-uploader.started
+
+	// queries of the hydrated first segment do not wait for it
+	listed := make(chan error, 1)
+	go func() { listed <- listFirst() }()
+	select {
+	case err := <-listed:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("list waited for the download of another segment")
+	}
+	close(uploader.release)
+	if err := <-got; err != nil {
+		t.Fatal(err)
+	}
+}
@@ -4,31 +4,286 @@ import (
 	"context"
 	"database/sql"
 	"encoding/hex"
+	"errors"
 	"fmt"
+	"io/fs"
+	"log/slog"
 	"os"
 	"path/filepath"
-	"strings"
+	"slices"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/gradientzero/comby-store-sqlite/internal"
 	"github.com/gradientzero/comby/v3"
-	_ "modernc.org/sqlite"
 )
 
+// EventStoreSQLite is the comby.EventStore backed by SQLite including its sqlite specific extensions.
+type EventStoreSQLite interface {
+	comby.EventStore
+
+	// Configure applies sqlite specific options. Options like the logger or
+	// checksum verification may also be changed after Init.
+	Configure(opts ...EventStoreSQLiteOption)
+	// ApplyOptions updates options of an initialized store, e.g. to switch
+	// into read-only mode during maintenance without reconnecting. It waits
+	// for writes in progress and must not be called within WithTx.
+	ApplyOptions(opts ...comby.EventStoreOption) error
+	// WithTx runs fn in one transaction, see EventStoreTx.
+	WithTx(ctx context.Context, fn func(tx EventStoreTx) error) error
+	// BeginBulk and EndBulk collect creates in large transactions, see BulkWriter.
+	BulkWriter
+	// Write creates an event, within the active bulk if any.
+	Write(ctx context.Context, evt comby.Event) error
+	// WriteStats reports pending, throttled and rejected writes.
+	WriteStats() WriteStats
+	// MaintainStorage runs incremental_vacuum and truncates the WAL.
+	MaintainStorage(ctx context.Context) (*StorageReport, error)
+	// RefreshReplica copies the primary of a replica store, see EventStoreSQLiteWithReplicaOf.
+	RefreshReplica(ctx context.Context) error
+	// VerifyAll scans the whole store and verifies all payload checksums.
+	VerifyAll(ctx context.Context) (*ChecksumReport, error)
+	// Replay streams matching events in store order to handler.
+	Replay(ctx context.Context, handler ReplayHandler, opts ...ReplayOption) (int64, error)
+	// ListEventsByCommand returns all events caused by the given command.
+	ListEventsByCommand(ctx context.Context, commandUuid string) ([]comby.Event, error)
+	// ListAggregates browses the aggregates of a domain, see AggregateInfo.
+	ListAggregates(ctx context.Context, domain string, opts ...AggregateListOption) ([]AggregateInfo, int64, error)
+	// ListDataTypes returns the event data types per domain with counts and first/last seen.
+	ListDataTypes(ctx context.Context) ([]DataTypeInfo, error)
+	// GetAsOf returns an event only if it existed at the given time, see also EventStoreListOptionAsOf.
+	GetAsOf(ctx context.Context, eventUuid string, timestamp int64) (comby.Event, error)
+	// AggregateAsOf folds the events of an aggregate up to the given time.
+	AggregateAsOf(ctx context.Context, aggregateUuid string, timestamp int64, apply func(evt comby.Event) error) (int64, error)
+	// Inspect dumps the stored record and decoded payload of an event for debugging.
+	Inspect(ctx context.Context, eventUuid string) (*EventInspection, error)
+	// CacheStats reports hits and misses of the result cache, see EventStoreSQLiteWithCache.
+	CacheStats() CacheStats
+	// TopBySize, SizeByDataType and SizeHistogram report payload sizes.
+	TopBySize(ctx context.Context, n int) ([]EventSize, error)
+	SizeByDataType(ctx context.Context) ([]DataTypeSize, error)
+	SizeHistogram(ctx context.Context) ([]SizeBucket, error)
+	// VerifyAggregateConsistency reports version gaps, duplicate versions and
+	// decreasing timestamps of an aggregate.
+	VerifyAggregateConsistency(ctx context.Context, aggregateUuid string) (*AggregateConsistencyReport, error)
+	// RenumberAggregate rewrites the versions of an aggregate contiguously,
+	// keeping the original versions in the event_renumberings table.
+	RenumberAggregate(ctx context.Context, aggregateUuid string) (*RenumberReport, error)
+	// ListAdminAudit returns the recorded administrative operations, e.g. resets.
+	ListAdminAudit(ctx context.Context) ([]AdminAuditEntry, error)
+	// ReadSnapshot pins one read transaction for several Get/List/Total calls.
+	ReadSnapshot(ctx context.Context) (EventStoreReadSnapshot, error)
+	// ListPage lists events like List without the count query and reports whether more follow.
+	ListPage(ctx context.Context, opts ...comby.EventStoreListOption) ([]comby.Event, bool, error)
+	// ListBatches passes all events in store order to fn in batches.
+	ListBatches(ctx context.Context, batchSize int, fn func([]comby.Event) error) error
+	// ListEach passes the events of a list to fn one by one while they are read.
+	ListEach(ctx context.Context, fn func(evt comby.Event) error, opts ...comby.EventStoreListOption) (int64, error)
+	// SubscribeChanges returns a channel signalled after events were written.
+	SubscribeChanges() (<-chan struct{}, func())
+	// ListAfterUuid lists events ordered by uuid after a cursor (keyset pagination).
+	ListAfterUuid(ctx context.Context, afterUuid string, opts ...comby.EventStoreListOption) ([]comby.Event, int64, error)
+	// ListMetadata lists events like List but without their payloads.
+	ListMetadata(ctx context.Context, opts ...comby.EventStoreListOption) ([]comby.Event, int64, error)
+	// UniqueListFields lists distinct combinations of several fields with their counts.
+	UniqueListFields(ctx context.Context, fields []string, opts ...comby.EventStoreUniqueListOption) ([]UniqueRow, int64, error)
+	// UniqueListCounts lists distinct values of a field with their counts.
+	UniqueListCounts(ctx context.Context, opts ...comby.EventStoreUniqueListOption) ([]UniqueValue, int64, error)
+	// DomainCounters returns the maintained event counts per domain and tenant without scanning events.
+	DomainCounters(ctx context.Context, domains ...string) ([]DomainCounter, error)
+	// EncryptExisting encrypts the plaintext payloads of a store which got a crypto service later.
+	EncryptExisting(ctx context.Context) (int64, error)
+	// Preflight checks integrity, indexes and tables and warms the page cache.
+	Preflight(ctx context.Context, warmPages int) (*PreflightReport, error)
+	// Checkpoint writes the WAL back into the database file.
+	Checkpoint(ctx context.Context, mode CheckpointMode) (*CheckpointReport, error)
+	// SchemaInfo describes the DDL and pragmas of the database to compare deployments.
+	SchemaInfo(ctx context.Context) (*SchemaInfo, error)
+	// SlowQueries returns the operations recorded by the query watchdog.
+	SlowQueries() []SlowQuery
+	// Metrics returns the reads and writes per minute of the metrics window.
+	Metrics(ctx context.Context) ([]MetricsMinute, error)
+	// ResetAndReinit replaces the database with a freshly migrated one.
+	ResetAndReinit(ctx context.Context) error
+	// Snapshot and SnapshotFile capture the current database as a consistent copy.
+	Snapshot(ctx context.Context) ([]byte, error)
+	SnapshotFile(ctx context.Context, path string) error
+}
+
+// EventStoreSQLiteOption configures sqlite specific behaviour of the event store.
+type EventStoreSQLiteOption func(*eventStoreSQLiteConfig)
+
+type eventStoreSQLiteConfig struct {
+	// json paths per data type encrypted individually instead of the whole payload
+	FieldEncryption map[string][]string
+	// algorithm used for payload checksums, defaults to ChecksumSHA256
+	ChecksumAlgorithm string
+	// verify payload checksums on read
+	VerifyChecksum bool
+	Logger         *slog.Logger
+	// throttles writes at the store boundary
+	WriteLimit writeLimit
+	// vacuum mode and schedule of MaintainStorage
+	Maintenance storageMaintenance
+	// maximum size of the database checked before writes
+	Quota sizeQuota
+	// primary database followed by a read replica
+	Replica replicaConfig
+	// LRU cache of Get and List results
+	Cache cacheConfig
+	// busy timeout and deadlines of reads and writes
+	Timeouts opTimeouts
+	// assigns uuids to records created without one
+	UuidGenerator UuidGenerator
+	// decides on access before operations
+	Authorizer Authorizer
+	// only records of this tenant are visible and writable
+	Tenant string
+	// supplies encryption keys instead of the crypto service
+	KeyProvider KeyProvider
+	// algorithm payloads are encrypted with using keys of the key provider
+	EncryptionAlgorithm string
+	// checks and cache warm-up on Init
+	Preflight preflightConfig
+	// background checkpoints by WAL size
+	Checkpointer checkpointer
+	// connection pragmas for read heavy workloads
+	ReadProfile readProfile
+	// lists without limit fail if more records match, 0 disables the cap
+	MaxListLimit int64
+	// interval of checking for inserts of other processes, 0 disables it
+	ChangePolling time.Duration
+	// database/sql driver the store connects with, the default driver if empty
+	DriverName string
+	// interval of validating pooled connections, 0 disables it
+	Keepalive time.Duration
+	// cancels runaway operations and keeps the slow query log
+	Watchdog *queryWatchdog
+	// validate payloads on Create against the schema registry
+	SchemaValidation bool
+	// invoked on Create per data type
+	PayloadValidators map[string][]PayloadValidator
+	// reads and writes per minute, written to the metrics table
+	Metrics *opsMetrics
+	// file system the database is read from, path is a name within it
+	FS fs.FS
+	// reject events on Create which would break their stream
+	StrictValidation bool
+	// number events created without version per aggregate
+	AssignVersions bool
+}
+
+// EventStoreSQLiteWithFieldEncryption encrypts only the given dot separated JSON paths
+// of payloads with the given data type (requires a crypto service). The remaining
+// payload stays in plaintext and can be queried with the JSON1 functions.
+func EventStoreSQLiteWithFieldEncryption(dataType string, paths ...string) EventStoreSQLiteOption {
+	return func(c *eventStoreSQLiteConfig) {
+		if c.FieldEncryption == nil {
+			c.FieldEncryption = map[string][]string{}
+		}
+		c.FieldEncryption[dataType] = paths
+	}
+}
+
+// EventStoreSQLiteWithChecksumAlgorithm sets the algorithm used for payload checksums (ChecksumSHA256 or ChecksumCRC32).
+func EventStoreSQLiteWithChecksumAlgorithm(algorithm string) EventStoreSQLiteOption {
+	return func(c *eventStoreSQLiteConfig) { c.ChecksumAlgorithm = algorithm }
+}
+
+// EventStoreSQLiteWithChecksumVerification verifies payload checksums on Get and List.
+func EventStoreSQLiteWithChecksumVerification(verify bool) EventStoreSQLiteOption {
+	return func(c *eventStoreSQLiteConfig) { c.VerifyChecksum = verify }
+}
+
+// EventStoreSQLiteWithLogger sets the logger used for migrations and maintenance.
+func EventStoreSQLiteWithLogger(logger *slog.Logger) EventStoreSQLiteOption {
+	return func(c *eventStoreSQLiteConfig) { c.Logger = logger }
+}
+
+// EventStoreSQLiteWithWriteRateLimit limits writes to perSecond with bursts of up to burst writes.
+func EventStoreSQLiteWithWriteRateLimit(perSecond float64, burst int) EventStoreSQLiteOption {
+	return func(c *eventStoreSQLiteConfig) {
+		c.WriteLimit.PerSecond = perSecond
+		c.WriteLimit.Burst = burst
+	}
+}
+
+// EventStoreSQLiteWithMaxPendingWrites rejects writes with ErrWriteBackpressure while n writes are pending.
+func EventStoreSQLiteWithMaxPendingWrites(n int) EventStoreSQLiteOption {
+	return func(c *eventStoreSQLiteConfig) { c.WriteLimit.MaxPending = n }
+}
+
+// EventStoreSQLiteWithIncrementalVacuum switches the database to auto_vacuum=INCREMENTAL
+// on Init (running a one-time VACUUM on existing databases), so MaintainStorage
+// can reclaim space after large deletions without a full VACUUM.
+func EventStoreSQLiteWithIncrementalVacuum() EventStoreSQLiteOption {
+	return func(c *eventStoreSQLiteConfig) { c.Maintenance.IncrementalVacuum = true }
+}
+
+// EventStoreSQLiteWithMaintenanceInterval runs MaintainStorage every interval until Close.
+func EventStoreSQLiteWithMaintenanceInterval(interval time.Duration) EventStoreSQLiteOption {
+	return func(c *eventStoreSQLiteConfig) { c.Maintenance.Interval = interval }
+}
+
+// EventStoreSQLiteWithMaxSize rejects Create and WithTx with a QuotaError while the
+// database uses more than maxBytes. If onExceeded is not nil, it is called
+// first to free space, e.g. by pruning archived events.
+func EventStoreSQLiteWithMaxSize(maxBytes int64, onExceeded func(ctx context.Context) error) EventStoreSQLiteOption {
+	return func(c *eventStoreSQLiteConfig) {
+		c.Quota = sizeQuota{MaxSize: maxBytes, OnExceeded: onExceeded}
+	}
+}
+
 // Make sure it implements interfaces
-var _ comby.EventStore = (*eventStoreSQLite)(nil)
+var _ EventStoreSQLite = (*eventStoreSQLite)(nil)
 
 type eventStoreSQLite struct {
+	// guards options and config, which may be updated after Init
+	mu      sync.RWMutex
 	options comby.EventStoreOptions
+	config  eventStoreSQLiteConfig
 	db      *sql.DB
 
 	// sqlite specific options
 	path string
+
+	sharedDB
+	// audit entry of a Reset, written by the next migration
+	pendingAudit atomic.Pointer[AdminAuditEntry]
+	// throttles writes before they wait for writeMu
+	gate writeGate
+	// periodic MaintainStorage, if configured
+	maintenance *maintenanceLoop
+	// periodic Checkpoint, if configured
+	checkpointer *maintenanceLoop
+	// signals subscribers after writes
+	changes changeNotifier
+	// periodic check for inserts of other processes, if configured
+	changePoller *maintenanceLoop
+	// periodic validation of pooled connections, if configured
+	keepalive *maintenanceLoop
+	// periodic RefreshReplica, if configured
+	replica *maintenanceLoop
+	// periodic flush of the metrics, if configured
+	metricsFlusher *maintenanceLoop
+	// temporary copy of a database read from an fs.FS
+	fsCopy string
+
+	// optional archive consulted for pruned events
+	readThrough atomic.Pointer[archiveReadThrough]
+	// optional result cache, see EventStoreSQLiteWithCache
+	cache atomic.Pointer[queryCache]
+	// open batch between BeginBulk and EndBulk
+	bulk atomic.Pointer[bulkBatch]
+	// compiled payload schemas, see EventStoreSQLiteWithSchemaValidation
+	payloadSchemas payloadSchemaCache
 }
 
-func NewEventStoreSQLite(path string, opts ...comby.EventStoreOption) comby.EventStore {
+func NewEventStoreSQLite(path string, opts ...comby.EventStoreOption) EventStoreSQLite {
 	es := &eventStoreSQLite{
-		path: path,
+		path:     path,
+		sharedDB: sharedDB{writeMu: &sync.Mutex{}},
 	}
 	for _, opt := range opts {
 		if _, err := opt(&es.options); err != nil {
@@ -38,8 +293,93 @@ func NewEventStoreSQLite(path string, opts ...comby.EventStoreOption) comby.Even
 	return es
 }
 
+// columns of an event record as read by scanEvent
+const eventSelectColumns = `id, instance_id, uuid, tenant_uuid, COALESCE(workspace_uuid, ''), command_uuid, domain,
+	aggregate_uuid, version, created_at, data_type, data_bytes, COALESCE(req_ctx, ''), COALESCE(checksum, ''), is_encrypted`
+
+// columns of the events view, e.g. to select the union of several databases
+const eventViewColumns = `id, instance_id, uuid, tenant_uuid, workspace_uuid, command_uuid, domain,
+	aggregate_uuid, version, created_at, data_type, data_bytes, req_ctx, checksum, data_size, is_encrypted`
+
+// eventSelectColumns without the payload, data_bytes is scanned as nil
+const eventMetadataColumns = `id, instance_id, uuid, tenant_uuid, COALESCE(workspace_uuid, ''), command_uuid, domain,
+	aggregate_uuid, version, created_at, data_type, NULL, COALESCE(req_ctx, ''), COALESCE(checksum, ''), is_encrypted`
+
+func scanEvent(row rowScanner, dbRecord *internal.Event) error {
+	return row.Scan(
+		&dbRecord.ID,
+		&dbRecord.InstanceId,
+		&dbRecord.Uuid,
+		&dbRecord.TenantUuid,
+		&dbRecord.WorkspaceUuid,
+		&dbRecord.CommandUuid,
+		&dbRecord.Domain,
+		&dbRecord.AggregateUuid,
+		&dbRecord.Version,
+		&dbRecord.CreatedAt,
+		&dbRecord.DataType,
+		&dbRecord.DataBytes,
+		&dbRecord.ReqCtx,
+		&dbRecord.Checksum,
+		&dbRecord.IsEncrypted,
+	)
+}
+
+func (es *eventStoreSQLite) Configure(opts ...EventStoreSQLiteOption) {
+	es.mu.Lock()
+	defer es.mu.Unlock()
+	for _, opt := range opts {
+		opt(&es.config)
+	}
+	// cached payloads were decoded with the previous settings
+	es.invalidateCache()
+}
+
+// opts returns a copy of the options, safe for concurrent use with ApplyOptions.
+func (es *eventStoreSQLite) opts() comby.EventStoreOptions {
+	es.mu.RLock()
+	defer es.mu.RUnlock()
+	return es.options
+}
+
+// cfg returns a copy of the sqlite specific config, safe for concurrent use with Configure.
+func (es *eventStoreSQLite) cfg() eventStoreSQLiteConfig {
+	es.mu.RLock()
+	defer es.mu.RUnlock()
+	return es.config
+}
+
+func (es *eventStoreSQLite) ApplyOptions(opts ...comby.EventStoreOption) error {
+	// wait for writes in progress, so none of them commits with the old options
+	// after returning (e.g. when switching into read-only mode)
+	es.writeMu.Lock()
+	defer es.writeMu.Unlock()
+	es.mu.Lock()
+	defer es.mu.Unlock()
+
+	// apply on a copy, so a failing option leaves the store untouched
+	options := es.options
+	for _, opt := range opts {
+		if _, err := opt(&options); err != nil {
+			return err
+		}
+	}
+	es.options = options
+	es.invalidateCache()
+
+	// connection pool limits take effect immediately
+	if es.db != nil && !es.shared {
+		configurePool(es.db, options.MaxOpenConns, options.MaxIdleConns, options.ConnMaxIdleTime, options.ConnMaxLifetime)
+	}
+	return nil
+}
+
 func (es *eventStoreSQLite) connect(ctx context.Context) (*sql.DB, error) {
-	db, err := sql.Open("sqlite", es.path)
+	return es.connectTo(ctx, dbPath(es.path, es.fsCopy))
+}
+
+func (es *eventStoreSQLite) connectTo(ctx context.Context, path string) (*sql.DB, error) {
+	db, err := sql.Open(driverName(es.cfg().DriverName), es.cfg().Timeouts.dsn(path, es.cfg().ReadProfile.pragmas()...))
 	if err != nil {
 		return nil, err
 	}
@@ -48,32 +388,14 @@ func (es *eventStoreSQLite) connect(ctx context.Context) (*sql.DB, error) {
 	// This is critical for readmodel restore where multiple goroutines read
 	// from the event store in parallel. We allow multiple connections so
 	// database/sql can serve concurrent reads without queuing.
-	maxOpenConns := 10
-	if es.options.MaxOpenConns > 0 {
-		maxOpenConns = es.options.MaxOpenConns
-	}
-	db.SetMaxOpenConns(maxOpenConns)
-
-	if es.options.MaxIdleConns > 0 {
-		db.SetMaxIdleConns(es.options.MaxIdleConns)
-	}
-
-	if es.options.ConnMaxIdleTime > 0 {
-		db.SetConnMaxIdleTime(es.options.ConnMaxIdleTime)
-	} else {
-		db.SetConnMaxIdleTime(5 * time.Minute)
-	}
-
-	if es.options.ConnMaxLifetime > 0 {
-		db.SetConnMaxLifetime(es.options.ConnMaxLifetime)
-	}
+	options := es.opts()
+	configurePool(db, options.MaxOpenConns, options.MaxIdleConns, options.ConnMaxIdleTime, options.ConnMaxLifetime)
 
 	// set sqlite specific pragmas
 	query := `
 	PRAGMA journal_mode=WAL;
 	PRAGMA synchronous=NORMAL;
 	PRAGMA foreign_keys=1;
-	PRAGMA busy_timeout=5000;
 	`
 	if _, err := db.ExecContext(ctx, query); err != nil {
 		return nil, err
@@ -81,94 +403,366 @@ func (es *eventStoreSQLite) connect(ctx context.Context) (*sql.DB, error) {
 	return db, nil
 }
 
-func (es *eventStoreSQLite) migrate(ctx context.Context) error {
-	query := `
-	CREATE TABLE IF NOT EXISTS events (id INTEGER,
-		instance_id INTEGER,
-		uuid TEXT,
-		tenant_uuid TEXT,
+// Events are stored in event_records with tenant_uuid and domain normalized
+// into the event_tenants and event_domains dimension tables. All tables are
+// STRICT, so type mismatches fail on insert. The events view
+// joins them back together, so reads and writes keep using the original
+// column names. Writes on the view are redirected by INSTEAD OF triggers.
+var eventTables = []strictTable{
+	{
+		name: "event_tenants",
+		columns: `id INTEGER PRIMARY KEY,
+		uuid TEXT NOT NULL UNIQUE`,
+		copyColumns: `id, uuid`,
+	},
+	{
+		name: "event_domains",
+		columns: `id INTEGER PRIMARY KEY,
+		name TEXT NOT NULL UNIQUE`,
+		copyColumns: `id, name`,
+	},
+	{
+		name: "event_records",
+		columns: `id INTEGER,
+		instance_id INTEGER NOT NULL,
+		uuid TEXT NOT NULL,
+		tenant_id INTEGER NOT NULL REFERENCES event_tenants(id),
 		workspace_uuid TEXT,
 		command_uuid TEXT,
-		domain TEXT,
-		aggregate_uuid TEXT,
-		version INTEGER,
-		created_at INTEGER,
-		data_type TEXT,
-		data_bytes TEXT,
+		domain_id INTEGER NOT NULL REFERENCES event_domains(id),
+		aggregate_uuid TEXT NOT NULL,
+		version INTEGER NOT NULL CHECK (version >= 0),
+		created_at INTEGER NOT NULL,
+		data_type TEXT NOT NULL,
+		data_bytes BLOB NOT NULL,
 		req_ctx TEXT,
-		PRIMARY KEY (id)
+		checksum TEXT,
+		data_size INTEGER NOT NULL DEFAULT 0,
+		is_encrypted INTEGER,
+		PRIMARY KEY (id)`,
+		copyColumns: `id, COALESCE(instance_id, 0), uuid, tenant_id, workspace_uuid, command_uuid, domain_id,
+		COALESCE(aggregate_uuid, ''), COALESCE(version, 0), COALESCE(created_at, 0), COALESCE(data_type, ''),
+		CAST(COALESCE(data_bytes, '') AS BLOB), req_ctx, checksum, length(CAST(COALESCE(data_bytes, '') AS BLOB)), is_encrypted`,
+	},
+}
+
+const eventIndexSchema = `
+	CREATE INDEX IF NOT EXISTS "event_records_tenant_index" ON "event_records" (
+		"tenant_id" ASC
 	);
-	CREATE INDEX IF NOT EXISTS "tenant_index" ON "events" (
-		"tenant_uuid" ASC
+	CREATE INDEX IF NOT EXISTS "event_records_domain_index" ON "event_records" (
+		"domain_id" ASC
 	);
-	CREATE INDEX IF NOT EXISTS "workspace_index" ON "events" (
+	CREATE INDEX IF NOT EXISTS "event_records_workspace_index" ON "event_records" (
 		"workspace_uuid" ASC
 	);
-	CREATE INDEX IF NOT EXISTS "aggregate_uuid_index" ON "events" (
+	CREATE INDEX IF NOT EXISTS "event_records_command_uuid_index" ON "event_records" (
+		"command_uuid" ASC
+	);
+	CREATE INDEX IF NOT EXISTS "event_records_aggregate_uuid_index" ON "event_records" (
 		"aggregate_uuid" ASC
 	);
-	CREATE INDEX IF NOT EXISTS "created_at_index" ON "events" (
+	CREATE INDEX IF NOT EXISTS "event_records_created_at_index" ON "event_records" (
 		"created_at" ASC
 	);
-	CREATE UNIQUE INDEX IF NOT EXISTS "uuid_index" ON "events" (
+	CREATE UNIQUE INDEX IF NOT EXISTS "event_records_uuid_index" ON "event_records" (
 		"uuid" ASC
 	);
-	`
-	if _, err := es.db.ExecContext(ctx, query); err != nil {
-		return err
-	}
+	CREATE INDEX IF NOT EXISTS "event_records_data_size_index" ON "event_records" (
+		"data_size" DESC
+	);
+	CREATE INDEX IF NOT EXISTS "event_records_data_type_index" ON "event_records" (
+		"data_type" ASC
+	);
+	CREATE INDEX IF NOT EXISTS "event_records_data_type_nocase_index" ON "event_records" (
+		"data_type" COLLATE NOCASE ASC
+	);
+	CREATE INDEX IF NOT EXISTS "event_domains_name_nocase_index" ON "event_domains" (
+		"name" COLLATE NOCASE ASC
+	);
+`
+
+const eventViewSchema = `
+	CREATE VIEW IF NOT EXISTS events AS
+	SELECT
+		e.id AS id,
+		e.instance_id AS instance_id,
+		e.uuid AS uuid,
+		t.uuid AS tenant_uuid,
+		e.workspace_uuid AS workspace_uuid,
+		e.command_uuid AS command_uuid,
+		d.name AS domain,
+		e.aggregate_uuid AS aggregate_uuid,
+		e.version AS version,
+		e.created_at AS created_at,
+		e.data_type AS data_type,
+		e.data_bytes AS data_bytes,
+		e.req_ctx AS req_ctx,
+		e.checksum AS checksum,
+		e.data_size AS data_size,
+		e.is_encrypted AS is_encrypted
+	FROM event_records e
+	LEFT JOIN event_tenants t ON t.id=e.tenant_id
+	LEFT JOIN event_domains d ON d.id=e.domain_id;
+
+	CREATE TRIGGER IF NOT EXISTS events_insert INSTEAD OF INSERT ON events
+	BEGIN
+		INSERT OR IGNORE INTO event_tenants (uuid) VALUES (NEW.tenant_uuid);
+		INSERT OR IGNORE INTO event_domains (name) VALUES (NEW.domain);
+		INSERT INTO event_records (id, instance_id, uuid, tenant_id, workspace_uuid, command_uuid, domain_id,
+			aggregate_uuid, version, created_at, data_type, data_bytes, req_ctx, checksum, data_size, is_encrypted)
+		VALUES (NEW.id, NEW.instance_id, NEW.uuid,
+			(SELECT id FROM event_tenants WHERE uuid=NEW.tenant_uuid),
+			NEW.workspace_uuid, NEW.command_uuid,
+			(SELECT id FROM event_domains WHERE name=NEW.domain),
+			NEW.aggregate_uuid, NEW.version, NEW.created_at, NEW.data_type, NEW.data_bytes, NEW.req_ctx, NEW.checksum,
+			length(CAST(NEW.data_bytes AS BLOB)), NEW.is_encrypted);
+	END;
+
+	CREATE TRIGGER IF NOT EXISTS events_update INSTEAD OF UPDATE ON events
+	BEGIN
+		INSERT OR IGNORE INTO event_tenants (uuid) VALUES (NEW.tenant_uuid);
+		INSERT OR IGNORE INTO event_domains (name) VALUES (NEW.domain);
+		UPDATE event_records SET
+			instance_id=NEW.instance_id,
+			uuid=NEW.uuid,
+			tenant_id=(SELECT id FROM event_tenants WHERE uuid=NEW.tenant_uuid),
+			workspace_uuid=NEW.workspace_uuid,
+			command_uuid=NEW.command_uuid,
+			domain_id=(SELECT id FROM event_domains WHERE name=NEW.domain),
+			aggregate_uuid=NEW.aggregate_uuid,
+			version=NEW.version,
+			created_at=NEW.created_at,
+			data_type=NEW.data_type,
+			data_bytes=NEW.data_bytes,
+			req_ctx=NEW.req_ctx,
+			checksum=NEW.checksum,
+			data_size=length(CAST(NEW.data_bytes AS BLOB)),
+			is_encrypted=NEW.is_encrypted
+		WHERE id=OLD.id;
+	END;
+
+	CREATE TRIGGER IF NOT EXISTS events_delete INSTEAD OF DELETE ON events
+	BEGIN
+		DELETE FROM event_records WHERE id=OLD.id;
+	END;
+`
 
-	// migrate existing databases: add req_ctx column if it doesn't exist
-	// SQLite silently ignores ALTER TABLE ADD COLUMN if column already exists via "IF NOT EXISTS" workaround:
-	// We check PRAGMA table_info and add the column only if missing.
-	var count int
-	if err := es.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM pragma_table_info('events') WHERE name='req_ctx'`).Scan(&count); err != nil {
-		return err
-	}
-	if count == 0 {
-		if _, err := es.db.ExecContext(ctx, `ALTER TABLE events ADD COLUMN req_ctx TEXT`); err != nil {
+func (es *eventStoreSQLite) migrate(ctx context.Context) error {
+	return es.migrateDB(ctx, es.db)
+}
+
+func (es *eventStoreSQLite) migrateDB(ctx context.Context, db *sql.DB) error {
+	return migrateTx(ctx, db, func(tx *sql.Tx) error {
+		if err := stampFormatVersion(ctx, tx); err != nil {
+			return err
+		}
+		// view and triggers reference the tables which may be rebuilt below,
+		// they are recreated afterwards
+		var view int
+		if err := tx.QueryRowContext(ctx, `SELECT COUNT(*) FROM sqlite_master WHERE type='view' AND name='events'`).Scan(&view); err != nil {
+			return err
+		}
+		if view > 0 {
+			if _, err := tx.ExecContext(ctx, `DROP VIEW events;`); err != nil {
+				return err
+			}
+		}
+		// payload sizes were introduced after event_records, backfill them once
+		var records int
+		if err := tx.QueryRowContext(ctx, `SELECT COUNT(*) FROM sqlite_master WHERE type='table' AND name='event_records'`).Scan(&records); err != nil {
+			return err
+		}
+		if records > 0 {
+			if ok, err := hasColumn(ctx, tx, "event_records", "data_size"); err != nil {
+				return err
+			} else if !ok {
+				query := `
+				ALTER TABLE event_records ADD COLUMN data_size INTEGER NOT NULL DEFAULT 0;
+				UPDATE event_records SET data_size=length(CAST(data_bytes AS BLOB));
+				`
+				if _, err := tx.ExecContext(ctx, query); err != nil {
+					return err
+				}
+			}
+			// existing records keep an unknown encryption state (NULL)
+			if ok, err := hasColumn(ctx, tx, "event_records", "is_encrypted"); err != nil {
+				return err
+			} else if !ok {
+				if _, err := tx.ExecContext(ctx, `ALTER TABLE event_records ADD COLUMN is_encrypted INTEGER;`); err != nil {
+					return err
+				}
+			}
+		}
+		tables := append(append(eventTables, auditTables...), eventCounterTables...)
+		if es.cfg().SchemaValidation {
+			tables = append(tables, payloadSchemaTables...)
+		}
+		if es.cfg().Metrics != nil {
+			tables = append(tables, metricsTables...)
+		}
+		if es.cfg().AssignVersions {
+			tables = append(tables, prunedVersionTables...)
+		}
+		if err := migrateStrictTables(ctx, tx, loggerOrDiscard(es.cfg().Logger), tables...); err != nil {
+			return err
+		}
+		// a reset is recorded in the recreated database
+		if pending := es.pendingAudit.Swap(nil); pending != nil {
+			if err := insertAuditEntry(ctx, tx, pending); err != nil {
+				return err
+			}
+		}
+		if _, err := tx.ExecContext(ctx, eventIndexSchema); err != nil {
 			return err
 		}
-	}
 
-	// migrate existing databases: add workspace_uuid column if it doesn't exist
-	if err := es.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM pragma_table_info('events') WHERE name='workspace_uuid'`).Scan(&count); err != nil {
+		// migrate existing databases: events used to be a plain table holding
+		// tenant_uuid and domain inline, move its rows into the normalized tables
+		var legacy int
+		if err := tx.QueryRowContext(ctx, `SELECT COUNT(*) FROM sqlite_master WHERE type='table' AND name='events'`).Scan(&legacy); err != nil {
+			return err
+		}
+		if legacy > 0 {
+			if err := es.migrateLegacyColumns(ctx, tx); err != nil {
+				return err
+			}
+			query := `
+			INSERT OR IGNORE INTO event_tenants (uuid) SELECT DISTINCT COALESCE(tenant_uuid, '') FROM events;
+			INSERT OR IGNORE INTO event_domains (name) SELECT DISTINCT COALESCE(domain, '') FROM events;
+			INSERT INTO event_records (id, instance_id, uuid, tenant_id, workspace_uuid, command_uuid, domain_id,
+				aggregate_uuid, version, created_at, data_type, data_bytes, req_ctx, checksum, data_size)
+			SELECT e.id, COALESCE(e.instance_id, 0), e.uuid, t.id, e.workspace_uuid, e.command_uuid, d.id,
+				COALESCE(e.aggregate_uuid, ''), COALESCE(e.version, 0), COALESCE(e.created_at, 0), COALESCE(e.data_type, ''),
+				CAST(COALESCE(e.data_bytes, '') AS BLOB), e.req_ctx, e.checksum, length(CAST(COALESCE(e.data_bytes, '') AS BLOB))
+			FROM events e
+			JOIN event_tenants t ON t.uuid=COALESCE(e.tenant_uuid, '')
+			JOIN event_domains d ON d.name=COALESCE(e.domain, '');
+			DROP TABLE events;
+			`
+			if _, err := tx.ExecContext(ctx, query); err != nil {
+				return err
+			}
+		}
+
+		if err := migrateEventCounters(ctx, tx); err != nil {
+			return err
+		}
+		_, err := tx.ExecContext(ctx, eventViewSchema)
 		return err
-	}
-	if count == 0 {
-		if _, err := es.db.ExecContext(ctx, `ALTER TABLE events ADD COLUMN workspace_uuid TEXT`); err != nil {
+	})
+}
+
+// migrateLegacyColumns adds columns introduced after the first release to a
+// legacy events table, so it can be copied into event_records as a whole.
+func (es *eventStoreSQLite) migrateLegacyColumns(ctx context.Context, tx *sql.Tx) error {
+	// SQLite has no ALTER TABLE ADD COLUMN IF NOT EXISTS:
+	// We check PRAGMA table_info and add the column only if missing.
+	for _, column := range []string{"req_ctx", "workspace_uuid", "checksum"} {
+		var count int
+		if err := tx.QueryRowContext(ctx, `SELECT COUNT(*) FROM pragma_table_info('events') WHERE name=?`, column).Scan(&count); err != nil {
 			return err
 		}
+		if count == 0 {
+			if _, err := tx.ExecContext(ctx, fmt.Sprintf(`ALTER TABLE events ADD COLUMN %s TEXT`, column)); err != nil {
+				return err
+			}
+		}
 	}
-
 	return nil
 }
 
 // fullfilling EventStore interface
 func (es *eventStoreSQLite) Init(ctx context.Context, opts ...comby.EventStoreOption) error {
+	es.mu.Lock()
 	for _, opt := range opts {
 		if _, err := opt(&es.options); err != nil {
+			es.mu.Unlock()
 			return err
 		}
 	}
+	es.mu.Unlock()
+
+	if config := es.cfg().Cache; config.Size > 0 {
+		es.cache.Store(newQueryCache(config))
+	}
 
 	// connect to db (or create new one)
-	if db, err := es.connect(ctx); err != nil {
-		return err
-	} else {
-		es.db = db
+	if !es.shared {
+		if err := es.initFS(); err != nil {
+			return err
+		}
+		if db, err := es.connect(ctx); err != nil {
+			return classifyError(err)
+		} else {
+			es.db = db
+		}
+	}
+
+	// databases of newer releases are neither migrated nor read
+	if err := checkFormatVersion(ctx, es.db); err != nil {
+		return fmt.Errorf("'%s' failed to init - %w", es.String(), classifyError(err))
+	}
+
+	if len(es.cfg().Replica.PrimaryPath) > 0 {
+		return es.initReplica(ctx)
 	}
 
 	// auto-migrate table
-	if !es.options.ReadOnly {
+	if !es.opts().ReadOnly {
 		if err := es.migrate(ctx); err != nil {
+			return classifyError(err)
+		}
+		if err := es.startLoops(ctx); err != nil {
 			return err
 		}
+		return es.initPreflight(ctx)
 	}
-	return nil
+
+	// read-only stores can not migrate, but reads select all current columns
+	if ok, err := hasColumn(ctx, es.db, "events", "data_size"); err != nil {
+		return err
+	} else if !ok {
+		return fmt.Errorf("'%s' failed to init - database needs migration, init once without read-only", es.String())
+	}
+	es.initChangePolling()
+	es.initKeepalive()
+	return es.initPreflight(ctx)
+}
+
+func (es *eventStoreSQLite) Create(ctx context.Context, opts ...comby.EventStoreCreateOption) (err error) {
+	defer wrapOpError(&err, "create", "events", time.Now(), func() (string, []string) {
+		return eventUuidOf(optionsOf(opts).Event), nil
+	})
+	ctx, cancel := es.cfg().Timeouts.write(ctx)
+	defer cancel()
+	ctx, finish := es.watch(ctx, "create")
+	defer finish(&err)
+	if batch := es.bulk.Load(); batch != nil {
+		return batch.write(ctx, func(tx *sql.Tx) error {
+			return es.create(ctx, tx, &batch.versions, opts...)
+		})
+	}
+	if err := es.cfg().Quota.check(ctx, es.db); err != nil {
+		return fmt.Errorf("'%s' failed to create event - %w", es.String(), err)
+	}
+	done, err := es.beginWrite(ctx)
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	var assigned versionAssignments
+	err = runTx(ctx, es.db, func(tx *sql.Tx) error {
+		return es.create(ctx, tx, &assigned, opts...)
+	})
+	assigned.apply(err == nil)
+	return err
 }
 
-func (es *eventStoreSQLite) Create(ctx context.Context, opts ...comby.EventStoreCreateOption) error {
+// create inserts an event. Versions assigned by the store are collected in
+// assigned and set on the event once the transaction is committed.
+func (es *eventStoreSQLite) create(ctx context.Context, q queryer, assigned *versionAssignments, opts ...comby.EventStoreCreateOption) error {
 	createOpts := comby.EventStoreCreateOptions{
 		Event: nil,
 	}
@@ -178,7 +772,7 @@ func (es *eventStoreSQLite) Create(ctx context.Context, opts ...comby.EventStore
 		}
 	}
 
-	if es.options.ReadOnly {
+	if es.opts().ReadOnly {
 		return fmt.Errorf("'%s' failed to create event - instance is readonly", es.String())
 	}
 
@@ -186,33 +780,60 @@ func (es *eventStoreSQLite) Create(ctx context.Context, opts ...comby.EventStore
 	if evt == nil {
 		return fmt.Errorf("'%s' failed to create event - event is nil", es.String())
 	}
+	if err := checkTenant(es.cfg().Tenant, evt.GetTenantUuid()); err != nil {
+		return fmt.Errorf("'%s' failed to create event - %w", es.String(), err)
+	}
+	if err := es.authorizeEvent(ctx, OperationCreate, evt); err != nil {
+		return err
+	}
+	if gen := es.cfg().UuidGenerator; gen != nil && len(evt.GetEventUuid()) < 1 {
+		evt.SetEventUuid(gen())
+	}
 	if len(evt.GetEventUuid()) < 1 {
 		return fmt.Errorf("'%s' failed to create event - event uuid is invalid", es.String())
 	}
+	version := evt.GetVersion()
+	if es.cfg().AssignVersions {
+		var err error
+		if version, err = nextVersion(ctx, q, evt); err != nil {
+			return fmt.Errorf("'%s' failed to create event - %w", es.String(), err)
+		}
+	}
+	if es.cfg().StrictValidation {
+		if err := checkStrict(evt, version); err != nil {
+			return fmt.Errorf("'%s' failed to create event - %w", es.String(), err)
+		}
+	}
 
 	// sql statement
 	dbRecord, err := internal.BaseEventToDbEvent(evt)
 	if err != nil {
 		return err
 	}
+	dbRecord.Version = version
 
-	// encrypt domain data if crypto service is provided
-	if es.options.CryptoService != nil {
-		if err := es.encryptDomainData(dbRecord); err != nil {
-			return err
+	if es.cfg().SchemaValidation {
+		if err := es.validatePayload(ctx, q, dbRecord.DataType, dbRecord.DataBytes); err != nil {
+			return fmt.Errorf("'%s' failed to create event - %w", es.String(), err)
+		}
+	}
+	if validators := es.cfg().PayloadValidators[dbRecord.DataType]; len(validators) > 0 {
+		if err := runPayloadValidators(ctx, validators, evt, dbRecord.DataType, dbRecord.Uuid, dbRecord.DataBytes); err != nil {
+			return fmt.Errorf("'%s' failed to create event - %w", es.String(), err)
 		}
 	}
 
-	// sql begin transaction
-	tx, err := es.db.Begin()
-	if err != nil {
+	// checksum of plain domain data
+	if dbRecord.Checksum, err = internal.Checksum(es.cfg().ChecksumAlgorithm, dbRecord.DataBytes); err != nil {
 		return err
 	}
-	defer func() {
-		if err != nil {
-			tx.Rollback()
+
+	// encrypt domain data if crypto service is provided
+	if es.cipher() != nil {
+		if err := es.encryptDomainData(ctx, dbRecord); err != nil {
+			return err
 		}
-	}()
+	}
 
 	query := `INSERT INTO events (
 	instance_id,
@@ -226,10 +847,12 @@ func (es *eventStoreSQLite) Create(ctx context.Context, opts ...comby.EventStore
 	created_at,
 	data_type,
 	data_bytes,
-	req_ctx
-) VALUES (?,?,?,?,?,?,?,?,?,?,?,?);`
+	req_ctx,
+	checksum,
+	is_encrypted
+) VALUES (?,?,?,?,?,?,?,?,?,?,?,?,?,?);`
 
-	_, err = tx.ExecContext(
+	_, err = q.ExecContext(
 		ctx,
 		query,
 		dbRecord.InstanceId,
@@ -244,15 +867,23 @@ func (es *eventStoreSQLite) Create(ctx context.Context, opts ...comby.EventStore
 		dbRecord.DataType,
 		dbRecord.DataBytes,
 		dbRecord.ReqCtx,
+		dbRecord.Checksum,
+		dbRecord.IsEncrypted,
 	)
-	if err != nil {
-		return err
+	if err == nil && version != evt.GetVersion() {
+		assigned.add(evt, version)
 	}
-
-	return tx.Commit()
+	return err
 }
 
-func (es *eventStoreSQLite) Get(ctx context.Context, opts ...comby.EventStoreGetOption) (comby.Event, error) {
+func (es *eventStoreSQLite) Get(ctx context.Context, opts ...comby.EventStoreGetOption) (_ comby.Event, err error) {
+	defer wrapOpError(&err, "get", "events", time.Now(), func() (string, []string) {
+		return optionsOf(opts).EventUuid, nil
+	})
+	ctx, cancel := es.cfg().Timeouts.read(ctx)
+	defer cancel()
+	ctx, finish := es.watch(ctx, "get")
+	defer finish(&err)
 	getOpts := comby.EventStoreGetOptions{}
 	for _, opt := range opts {
 		if _, err := opt(&getOpts); err != nil {
@@ -260,35 +891,48 @@ func (es *eventStoreSQLite) Get(ctx context.Context, opts ...comby.EventStoreGet
 		}
 	}
 
-	if len(getOpts.EventUuid) == 0 {
-		return nil, fmt.Errorf("'%s' failed to get event - event uuid is required", es.String())
+	if err := requireUuid("event", getOpts.EventUuid); err != nil {
+		return nil, fmt.Errorf("'%s' failed to get event - %w", es.String(), err)
+	}
+
+	var evt comby.Event
+	if c := es.cache.Load(); c != nil {
+		evt, err = es.cachedGet(ctx, c, getOpts.EventUuid)
+	} else {
+		evt, err = es.get(ctx, es.db, "events", getOpts.EventUuid)
+	}
+	if rt := es.readThrough.Load(); err == nil && evt == nil && rt != nil {
+		// event might have been pruned: consult archive
+		evt, err = rt.get(ctx, getOpts.EventUuid)
+	}
+	if err != nil {
+		return nil, classifyError(err)
+	}
+	if err := es.authorizeGet(ctx, getOpts.EventUuid, evt); err != nil {
+		return nil, err
+	}
+	return evt, nil
+}
+
+func (es *eventStoreSQLite) get(ctx context.Context, q queryer, source, eventUuid string) (comby.Event, error) {
+	dbRecord, err := es.getRecord(ctx, q, source, eventUuid)
+	if err != nil || dbRecord == nil {
+		return nil, err
 	}
+	return internal.DbEventToBaseEvent(dbRecord)
+}
 
-	query := `SELECT id, instance_id, uuid, tenant_uuid, COALESCE(workspace_uuid, ''), command_uuid, domain,
-		aggregate_uuid, version, created_at, data_type, data_bytes, COALESCE(req_ctx, '')
-		FROM events WHERE uuid=? LIMIT 1;`
-	row := es.db.QueryRowContext(ctx, query, getOpts.EventUuid)
+// getRecord returns the decoded record of an event, nil if it does not exist.
+func (es *eventStoreSQLite) getRecord(ctx context.Context, q queryer, source, eventUuid string) (*internal.Event, error) {
+	query := fmt.Sprintf("SELECT %s FROM %s WHERE uuid=? LIMIT 1;", eventSelectColumns, source)
+	row := q.QueryRowContext(ctx, query, eventUuid)
 	if row.Err() != nil {
 		return nil, row.Err()
 	}
 
 	// extract record
 	var dbRecord internal.Event
-	if err := row.Scan(
-		&dbRecord.ID,
-		&dbRecord.InstanceId,
-		&dbRecord.Uuid,
-		&dbRecord.TenantUuid,
-		&dbRecord.WorkspaceUuid,
-		&dbRecord.CommandUuid,
-		&dbRecord.Domain,
-		&dbRecord.AggregateUuid,
-		&dbRecord.Version,
-		&dbRecord.CreatedAt,
-		&dbRecord.DataType,
-		&dbRecord.DataBytes,
-		&dbRecord.ReqCtx,
-	); err != nil {
+	if err := scanEvent(row, &dbRecord); err != nil {
 		// Catch errors
 		switch {
 		case err == sql.ErrNoRows:
@@ -298,187 +942,174 @@ func (es *eventStoreSQLite) Get(ctx context.Context, opts ...comby.EventStoreGet
 		}
 	}
 
-	// decrypt domain data if crypto service is provided
-	if es.options.CryptoService != nil {
-		if err := es.decryptDomainData(&dbRecord); err != nil {
-			return nil, err
-		}
+	// records of other tenants do not exist for a bound store
+	if checkTenant(es.cfg().Tenant, dbRecord.TenantUuid) != nil {
+		return nil, nil
 	}
 
-	// db record to event
-	evt, err := internal.DbEventToBaseEvent(&dbRecord)
-	if err != nil {
+	// decrypt and verify domain data
+	if err := es.decodeDomainData(ctx, &dbRecord); err != nil {
 		return nil, err
 	}
-
-	return evt, err
+	return &dbRecord, nil
 }
 
-func (es *eventStoreSQLite) List(ctx context.Context, opts ...comby.EventStoreListOption) ([]comby.Event, int64, error) {
-	listOpts := comby.EventStoreListOptions{
-		Before:    -1,
-		After:     -1,
-		Offset:    0,
-		Limit:     100,
-		OrderBy:   "created_at",
-		Ascending: true,
+func (es *eventStoreSQLite) List(ctx context.Context, opts ...comby.EventStoreListOption) (_ []comby.Event, _ int64, err error) {
+	defer wrapOpError(&err, "list", "events", time.Now(), func() (string, []string) {
+		return "", es.describeEventList(opts)
+	})
+	ctx, cancel := es.cfg().Timeouts.read(ctx)
+	defer cancel()
+	ctx, finish := es.watch(ctx, "list")
+	defer finish(&err)
+	listOpts, filter, err := es.listOptions(opts)
+	if err != nil {
+		return nil, 0, err
 	}
-	for _, opt := range opts {
-		if _, err := opt(&listOpts); err != nil {
-			return nil, 0, err
-		}
+	if err := es.authorizeList(ctx, listOpts, filter); err != nil {
+		return nil, 0, err
 	}
-	// prepare statement: (do NOT used them for Query/QueryContext)
-	// 1. see different syntax for postgres:
-	// http://go-database-sql.org/prepared.html#parameter-placeholder-syntax
-	// 2. db.Query and db.QueryContext for some reason it does not work as expected
-	// (seems to be something internally in database/sql because for SQLite and Postgres
-	// simply does not return the expected result after sending new values to prepared statement)
-	var whereSQL string = ""
-	var whereList []string = []string{}
-	var args []any
-	if len(listOpts.TenantUuid) > 0 {
-		whereList = append(whereList, "tenant_uuid=?")
-		args = append(args, listOpts.TenantUuid)
-	}
-	if len(listOpts.AggregateUuid) > 0 {
-		whereList = append(whereList, "aggregate_uuid=?")
-		args = append(args, listOpts.AggregateUuid)
-	}
-	if len(listOpts.DataType) > 0 {
-		whereList = append(whereList, "data_type=?")
-		args = append(args, listOpts.DataType)
-	}
-	if len(listOpts.Domains) > 0 {
-		placeholders := make([]string, len(listOpts.Domains))
-		for i, d := range listOpts.Domains {
-			placeholders[i] = "?"
-			args = append(args, d)
-		}
-		whereList = append(whereList, fmt.Sprintf("domain IN (%s)", strings.Join(placeholders, ",")))
-	}
-	if listOpts.Before >= 0 {
-		whereList = append(whereList, "created_at<?")
-		args = append(args, listOpts.Before)
-	}
-	if listOpts.After >= 0 {
-		whereList = append(whereList, "created_at>?")
-		args = append(args, listOpts.After)
-	}
-
-	// note the first empty character(s) below
-	for index, where := range whereList {
-		if index == 0 {
-			whereSQL = fmt.Sprintf(" WHERE %s", where)
-		} else {
-			whereSQL = fmt.Sprintf("%s AND %s", whereSQL, where)
-		}
+	var evts []comby.Event
+	var total int64
+	if rt := es.readThrough.Load(); rt != nil {
+		evts, total, err = rt.list(ctx, listOpts, filter)
+	} else if c := es.cache.Load(); c != nil {
+		evts, total, err = es.cachedList(ctx, c, listOpts, filter)
+	} else {
+		evts, total, err = es.list(ctx, es.db, "events", listOpts, filter)
 	}
+	return evts, total, classifyError(err)
+}
 
-	// count the total number of records for this query
-	var queryTotal int64
-	var queryTotalQuery string = fmt.Sprintf("SELECT COUNT(id) FROM events%s;", whereSQL)
-	var row *sql.Row
-	if len(args) > 0 {
-		row = es.db.QueryRowContext(ctx, queryTotalQuery, args...)
-	} else {
-		row = es.db.QueryRowContext(ctx, queryTotalQuery)
+func (es *eventStoreSQLite) list(ctx context.Context, q queryer, source string, listOpts comby.EventStoreListOptions, filter eventFilter) ([]comby.Event, int64, error) {
+	dbRecords, total, err := es.listRecords(ctx, q, source, listOpts, filter)
+	if err != nil {
+		return nil, 0, err
 	}
-	if err := row.Err(); err != nil {
+	evts, err := internal.DbEventsToBaseEvents(dbRecords)
+	if err != nil {
 		return nil, 0, err
 	}
-	// extract record
-	if err := row.Scan(&queryTotal); err != nil {
+	return evts, total, nil
+}
+
+// eventFilter holds sqlite specific filters of events, which are not part
+// of comby.EventStoreListOptions.
+type eventFilter struct {
+	listFilter
+	// keyset pagination: uuids after (or before, if descending) this one
+	AfterUuid string
+	// skip the count query, the total is -1
+	SkipTotal bool
+}
+
+// listRecords returns the decoded records of a page and the total number of matching events.
+func (es *eventStoreSQLite) listRecords(ctx context.Context, q queryer, source string, listOpts comby.EventStoreListOptions, filter eventFilter) ([]*internal.Event, int64, error) {
+	dbRecords, total, err := es.queryRecords(ctx, q, source, eventSelectColumns, listOpts, filter)
+	if err != nil {
 		return nil, 0, err
 	}
 
-	// prepare orderby
-	var orderBySQL string = ""
-	if len(listOpts.OrderBy) > 0 {
-		if listOpts.Ascending {
-			orderBySQL = fmt.Sprintf(" ORDER BY %s ASC", listOpts.OrderBy)
-		} else {
-			orderBySQL = fmt.Sprintf(" ORDER BY %s DESC", listOpts.OrderBy)
+	// decrypt and verify domain data
+	for _, dbRecord := range dbRecords {
+		if err := es.decodeDomainData(ctx, dbRecord); err != nil {
+			return nil, 0, err
 		}
 	}
+	return dbRecords, total, nil
+}
 
-	// prepare limit/offset statements
-	var limitSQL string = ""
-	var offsetSQL string = ""
-	if listOpts.Limit >= 0 {
-		limitSQL = fmt.Sprintf(" LIMIT %d", listOpts.Limit)
+// queryRecords returns the records of a page as stored, columns must be
+// scannable by scanEvent. Lists without limit are capped by MaxListLimit.
+func (es *eventStoreSQLite) queryRecords(ctx context.Context, q queryer, source, columns string, listOpts comby.EventStoreListOptions, filter eventFilter) ([]*internal.Event, int64, error) {
+	maxLimit := es.cfg().MaxListLimit
+	capped := maxLimit > 0 && listOpts.Limit < 0
+	if capped {
+		listOpts.Limit = maxLimit + 1
+	}
+	var dbRecords []*internal.Event
+	total, err := es.eachRecord(ctx, q, source, columns, listOpts, filter, func(dbRecord *internal.Event) error {
+		dbRecords = append(dbRecords, dbRecord)
+		return nil
+	})
+	if err != nil {
+		return nil, 0, err
 	}
-	if listOpts.Offset >= 0 {
-		offsetSQL = fmt.Sprintf(" OFFSET %d", listOpts.Offset)
+	if capped && int64(len(dbRecords)) > maxLimit {
+		return nil, 0, fmt.Errorf("%w - more than %d events match", ErrListLimitExceeded, maxLimit)
 	}
+	return dbRecords, total, nil
+}
 
-	// run query with parameterized values
-	var query string = fmt.Sprintf("SELECT id, instance_id, uuid, tenant_uuid, COALESCE(workspace_uuid, ''), command_uuid, domain, aggregate_uuid, version, created_at, data_type, data_bytes, COALESCE(req_ctx, '') FROM events%s%s%s%s;", whereSQL, orderBySQL, limitSQL, offsetSQL)
-	var rows *sql.Rows
-	var err error
-	if len(args) > 0 {
-		rows, err = es.db.QueryContext(ctx, query, args...)
-	} else {
-		rows, err = es.db.QueryContext(ctx, query)
+// eachRecord calls fn with each record of a page as stored while the rows
+// are read and returns the total number of matching events.
+func (es *eventStoreSQLite) eachRecord(ctx context.Context, q queryer, source, columns string, listOpts comby.EventStoreListOptions, filter eventFilter, fn func(dbRecord *internal.Event) error) (int64, error) {
+	query := eventListQuery(source, columns, listOpts, filter, es.cfg().Tenant)
+
+	// count the total number of records for this query
+	var queryTotal int64 = -1
+	if !filter.SkipTotal {
+		row := q.QueryRowContext(ctx, query.countSQL, query.args...)
+		if err := row.Err(); err != nil {
+			return 0, err
+		}
+		// extract record
+		if err := row.Scan(&queryTotal); err != nil {
+			return 0, err
+		}
 	}
+
+	rows, err := q.QueryContext(ctx, query.selectSQL, query.args...)
 	switch {
 	case err == sql.ErrNoRows:
-		return nil, queryTotal, nil
+		return queryTotal, nil
 	case err != nil:
-		return nil, 0, err
+		return 0, err
 	}
 	if rows != nil {
 		defer rows.Close()
 	}
 
 	// extract results
-	var dbRecords []*internal.Event
+	scanner := newPayloadScanner(rows)
 	for rows.Next() {
 		var dbRecord internal.Event
-		if err := rows.Scan(
-			&dbRecord.ID,
-			&dbRecord.InstanceId,
-			&dbRecord.Uuid,
-			&dbRecord.TenantUuid,
-			&dbRecord.WorkspaceUuid,
-			&dbRecord.CommandUuid,
-			&dbRecord.Domain,
-			&dbRecord.AggregateUuid,
-			&dbRecord.Version,
-			&dbRecord.CreatedAt,
-			&dbRecord.DataType,
-			&dbRecord.DataBytes,
-			&dbRecord.ReqCtx,
-		); err != nil {
-			return nil, 0, err
+		if err := scanEvent(scanner, &dbRecord); err != nil {
+			return 0, err
+		}
+		if err := fn(&dbRecord); err != nil {
+			return 0, err
 		}
-		dbRecords = append(dbRecords, &dbRecord)
 	}
 	if err := rows.Close(); err != nil {
-		return nil, 0, err
+		return 0, err
 	}
 	if err := rows.Err(); err != nil {
-		return nil, 0, err
-	}
-
-	// decrypt domain data if crypto service is provided
-	if es.options.CryptoService != nil {
-		for _, dbRecord := range dbRecords {
-			if err := es.decryptDomainData(dbRecord); err != nil {
-				return nil, 0, err
-			}
-		}
+		return 0, err
 	}
+	return queryTotal, nil
+}
 
-	// convert
-	evts, err := internal.DbEventsToBaseEvents(dbRecords)
+func (es *eventStoreSQLite) Update(ctx context.Context, opts ...comby.EventStoreUpdateOption) (err error) {
+	defer wrapOpError(&err, "update", "events", time.Now(), func() (string, []string) {
+		return eventUuidOf(optionsOf(opts).Event), nil
+	})
+	ctx, cancel := es.cfg().Timeouts.write(ctx)
+	defer cancel()
+	ctx, finish := es.watch(ctx, "update")
+	defer finish(&err)
+	done, err := es.beginWrite(ctx)
 	if err != nil {
-		return nil, 0, err
+		return err
 	}
-	return evts, queryTotal, err
+	defer done()
+
+	return runTx(ctx, es.db, func(tx *sql.Tx) error {
+		return es.update(ctx, tx, opts...)
+	})
 }
 
-func (es *eventStoreSQLite) Update(ctx context.Context, opts ...comby.EventStoreUpdateOption) error {
+func (es *eventStoreSQLite) update(ctx context.Context, q queryer, opts ...comby.EventStoreUpdateOption) error {
 	updateOpts := comby.EventStoreUpdateOptions{
 		Event: nil,
 	}
@@ -487,7 +1118,7 @@ func (es *eventStoreSQLite) Update(ctx context.Context, opts ...comby.EventStore
 			return err
 		}
 	}
-	if es.options.ReadOnly {
+	if es.opts().ReadOnly {
 		return fmt.Errorf("'%s' failed to update event - instance is readonly", es.String())
 	}
 
@@ -498,6 +1129,15 @@ func (es *eventStoreSQLite) Update(ctx context.Context, opts ...comby.EventStore
 	if len(evt.GetEventUuid()) < 1 {
 		return fmt.Errorf("'%s' failed to update event - event uuid is invalid", es.String())
 	}
+	if err := checkTenant(es.cfg().Tenant, evt.GetTenantUuid()); err != nil {
+		return fmt.Errorf("'%s' failed to update event - %w", es.String(), err)
+	}
+	if err := checkStoredTenant(ctx, q, es.cfg().Tenant, "events", evt.GetEventUuid()); err != nil {
+		return fmt.Errorf("'%s' failed to update event - %w", es.String(), err)
+	}
+	if err := es.authorizeStored(ctx, q, OperationUpdate, evt.GetEventUuid()); err != nil {
+		return err
+	}
 
 	// convert to db format
 	dbRecord, err := internal.BaseEventToDbEvent(evt)
@@ -505,24 +1145,18 @@ func (es *eventStoreSQLite) Update(ctx context.Context, opts ...comby.EventStore
 		return err
 	}
 
+	// checksum of plain domain data
+	if dbRecord.Checksum, err = internal.Checksum(es.cfg().ChecksumAlgorithm, dbRecord.DataBytes); err != nil {
+		return err
+	}
+
 	// encrypt domain data if crypto service is provided
-	if es.options.CryptoService != nil {
-		if err := es.encryptDomainData(dbRecord); err != nil {
+	if es.cipher() != nil {
+		if err := es.encryptDomainData(ctx, dbRecord); err != nil {
 			return err
 		}
 	}
 
-	// sql begin transaction
-	tx, err := es.db.Begin()
-	if err != nil {
-		return err
-	}
-	defer func() {
-		if err != nil {
-			tx.Rollback()
-		}
-	}()
-
 	query := `UPDATE events SET
 		instance_id=?,
 		tenant_uuid=?,
@@ -534,10 +1168,12 @@ func (es *eventStoreSQLite) Update(ctx context.Context, opts ...comby.EventStore
 		created_at=?,
 		data_type=?,
 		data_bytes=?,
-		req_ctx=?
+		req_ctx=?,
+		checksum=?,
+		is_encrypted=?
 	 WHERE uuid=?;`
 
-	_, err = tx.ExecContext(ctx,
+	_, err = q.ExecContext(ctx,
 		query,
 		dbRecord.InstanceId,
 		dbRecord.TenantUuid,
@@ -550,84 +1186,76 @@ func (es *eventStoreSQLite) Update(ctx context.Context, opts ...comby.EventStore
 		dbRecord.DataType,
 		dbRecord.DataBytes,
 		dbRecord.ReqCtx,
+		dbRecord.Checksum,
+		dbRecord.IsEncrypted,
 		dbRecord.Uuid)
+	return err
+}
+
+func (es *eventStoreSQLite) Delete(ctx context.Context, opts ...comby.EventStoreDeleteOption) (err error) {
+	defer wrapOpError(&err, "delete", "events", time.Now(), func() (string, []string) {
+		return optionsOf(opts).EventUuid, nil
+	})
+	ctx, cancel := es.cfg().Timeouts.write(ctx)
+	defer cancel()
+	ctx, finish := es.watch(ctx, "delete")
+	defer finish(&err)
+	done, err := es.beginWrite(ctx)
 	if err != nil {
 		return err
 	}
-
-	return tx.Commit()
+	defer done()
+	return classifyError(es.delete(ctx, es.db, opts...))
 }
 
-func (es *eventStoreSQLite) Delete(ctx context.Context, opts ...comby.EventStoreDeleteOption) error {
+func (es *eventStoreSQLite) delete(ctx context.Context, q queryer, opts ...comby.EventStoreDeleteOption) error {
 	deleteOpts := comby.EventStoreDeleteOptions{}
 	for _, opt := range opts {
 		if _, err := opt(&deleteOpts); err != nil {
 			return err
 		}
 	}
-	if es.options.ReadOnly {
+	if es.opts().ReadOnly {
 		return fmt.Errorf("'%s' failed to delete event - instance is readonly", es.String())
 	}
 
 	var eventUuid string = deleteOpts.EventUuid
-	if len(eventUuid) < 1 {
-		return fmt.Errorf("'%s' failed to delete event - event uuid '%s' is invalid", es.String(), eventUuid)
+	if err := requireUuid("event", eventUuid); err != nil {
+		return fmt.Errorf("'%s' failed to delete event - %w", es.String(), err)
+	}
+	if err := checkStoredTenant(ctx, q, es.cfg().Tenant, "events", eventUuid); err != nil {
+		return fmt.Errorf("'%s' failed to delete event - %w", es.String(), err)
+	}
+	if err := es.authorizeStored(ctx, q, OperationDelete, eventUuid); err != nil {
+		return err
 	}
 
 	// run query with parameterized values
 	query := "DELETE FROM events WHERE uuid=?;"
-	_, err := es.db.ExecContext(ctx, query, eventUuid)
+	_, err := q.ExecContext(ctx, query, eventUuid)
 	return err
 }
 
 func (es *eventStoreSQLite) Total(ctx context.Context) int64 {
-	// run query (no args to not using prepared statement)
-	row := es.db.QueryRowContext(ctx, `SELECT COUNT(id) FROM events;`)
-	if err := row.Err(); err != nil {
-		return 0
-	}
-	// extract record
-	var dbTotal int64
-	if err := row.Scan(&dbTotal); err != nil {
-		return 0
-	}
-	return dbTotal
+	return countRows(ctx, es.db, "events", es.cfg().Tenant)
 }
 
-func (es *eventStoreSQLite) UniqueList(ctx context.Context, opts ...comby.EventStoreUniqueListOption) ([]string, int64, error) {
-	listOpts := comby.EventStoreUniqueListOptions{
-		DbField:   "tenant_uuid",
-		Offset:    0,
-		Limit:     100,
-		Ascending: true,
-	}
-	for _, opt := range opts {
-		if _, err := opt(&listOpts); err != nil {
-			return nil, 0, err
-		}
-	}
-
-	// prepare where
-	var whereSQL string = ""
-	var whereList []string = []string{}
-	var args []any
-	if len(listOpts.TenantUuid) > 0 {
-		whereList = append(whereList, "tenant_uuid=?")
-		args = append(args, listOpts.TenantUuid)
-	}
-	if len(listOpts.Domain) > 0 {
-		whereList = append(whereList, "domain=?")
-		args = append(args, listOpts.Domain)
+func (es *eventStoreSQLite) UniqueList(ctx context.Context, opts ...comby.EventStoreUniqueListOption) (_ []string, _ int64, err error) {
+	defer wrapOpError(&err, "unique list", "events", time.Now(), func() (string, []string) {
+		return "", []string{"field=" + optionsOf(opts).DbField}
+	})
+	ctx, cancel := es.cfg().Timeouts.read(ctx)
+	defer cancel()
+	ctx, finish := es.watch(ctx, "unique list")
+	defer finish(&err)
+	listOpts, filter, err := es.prepareUniqueList(ctx, opts)
+	if err != nil {
+		return nil, 0, err
 	}
 
-	// note the first empty character(s) below
-	for index, where := range whereList {
-		if index == 0 {
-			whereSQL = fmt.Sprintf(" WHERE %s", where)
-		} else {
-			whereSQL = fmt.Sprintf("%s AND %s", whereSQL, where)
-		}
-	}
+	// prepare where, the cursor only applies to the page
+	whereList, args := uniqueListWhere(listOpts, filter, es.cfg().Tenant)
+	pageWhere, pageArgs := filter.afterCondition(listOpts.DbField, listOpts.Ascending, slices.Clone(whereList), slices.Clone(args))
 
 	// prepare orderby
 	var orderBySQL string = ""
@@ -639,22 +1267,11 @@ func (es *eventStoreSQLite) UniqueList(ctx context.Context, opts ...comby.EventS
 		}
 	}
 
-	// prepare limit/offset statements
-	var limitSQL string = ""
-	var offsetSQL string = ""
-	if listOpts.Limit >= 0 {
-		limitSQL = fmt.Sprintf(" LIMIT %d", listOpts.Limit)
-	}
-	if listOpts.Offset >= 0 {
-		offsetSQL = fmt.Sprintf(" OFFSET %d", listOpts.Offset)
-	}
-
 	// run query with parameterized values
-	var query string = fmt.Sprintf("SELECT DISTINCT %s FROM events%s%s%s%s;", listOpts.DbField, whereSQL, orderBySQL, limitSQL, offsetSQL)
+	var query string = fmt.Sprintf("SELECT DISTINCT %s FROM events%s%s%s;", listOpts.DbField, whereSQL(pageWhere), orderBySQL, pageSQL(listOpts.Limit, listOpts.Offset))
 	var rows *sql.Rows
-	var err error
-	if len(args) > 0 {
-		rows, err = es.db.QueryContext(ctx, query, args...)
+	if len(pageArgs) > 0 {
+		rows, err = es.db.QueryContext(ctx, query, pageArgs...)
 	} else {
 		rows, err = es.db.QueryContext(ctx, query)
 	}
@@ -685,7 +1302,7 @@ func (es *eventStoreSQLite) UniqueList(ctx context.Context, opts ...comby.EventS
 	}
 
 	// run extra total query with parameterized values
-	var totalQuery string = fmt.Sprintf("SELECT COUNT(DISTINCT %s) FROM events%s;", listOpts.DbField, whereSQL)
+	var totalQuery string = fmt.Sprintf("SELECT COUNT(DISTINCT %s) FROM events%s;", listOpts.DbField, whereSQL(whereList))
 	var row *sql.Row
 	if len(args) > 0 {
 		row = es.db.QueryRowContext(ctx, totalQuery, args...)
@@ -705,11 +1322,33 @@ func (es *eventStoreSQLite) UniqueList(ctx context.Context, opts ...comby.EventS
 }
 
 func (es *eventStoreSQLite) Close(ctx context.Context) error {
-	return es.db.Close()
+	if batch := es.bulk.Swap(nil); batch != nil {
+		if err := batch.flush(); err != nil {
+			return err
+		}
+	}
+	es.stopLoops()
+	if !es.opts().ReadOnly {
+		if err := es.cfg().Metrics.flush(ctx, es.db, es.writeMu, "events"); err != nil {
+			loggerOrDiscard(es.cfg().Logger).ErrorContext(ctx, "metrics flush failed", "error", err)
+		}
+	}
+	if rt := es.readThrough.Swap(nil); rt != nil {
+		if err := rt.close(ctx); err != nil {
+			return err
+		}
+	}
+	if es.shared {
+		return nil
+	}
+	if err := es.db.Close(); err != nil {
+		return err
+	}
+	return removeFSCopy(&es.fsCopy)
 }
 
 func (es *eventStoreSQLite) Options() comby.EventStoreOptions {
-	return es.options
+	return es.opts()
 }
 
 func (es *eventStoreSQLite) String() string {
@@ -717,9 +1356,11 @@ func (es *eventStoreSQLite) String() string {
 }
 
 func (es *eventStoreSQLite) Info(ctx context.Context) (*comby.EventStoreInfoModel, error) {
+	// a store bound to a tenant only describes its events
+	whereList, args := tenantCondition(es.cfg().Tenant, nil, nil)
 
-	// run extra total query (no args to not using prepared statement)
-	row := es.db.QueryRowContext(ctx, "SELECT COUNT(uuid) FROM events;")
+	// run extra total query
+	row := es.db.QueryRowContext(ctx, fmt.Sprintf("SELECT COUNT(uuid) FROM events%s;", whereSQL(whereList)), args...)
 	if err := row.Err(); err != nil {
 		return nil, err
 	}
@@ -729,8 +1370,8 @@ func (es *eventStoreSQLite) Info(ctx context.Context) (*comby.EventStoreInfoMode
 		return nil, err
 	}
 
-	// run extra total query (no args to not using prepared statement)
-	row = es.db.QueryRowContext(ctx, "SELECT COALESCE(MAX(created_at), 0) FROM events;")
+	// run extra total query
+	row = es.db.QueryRowContext(ctx, fmt.Sprintf("SELECT COALESCE(MAX(created_at), 0) FROM events%s;", whereSQL(whereList)), args...)
 	if err := row.Err(); err != nil {
 		return nil, err
 	}
@@ -749,9 +1390,15 @@ func (es *eventStoreSQLite) Info(ctx context.Context) (*comby.EventStoreInfoMode
 }
 
 func (es *eventStoreSQLite) Reset(ctx context.Context) error {
-	if es.options.ReadOnly {
+	if es.opts().ReadOnly {
 		return fmt.Errorf("'%s' failed to reset - instance is readonly", es.String())
 	}
+	var numRecords int64
+	if es.db != nil {
+		numRecords = countRows(ctx, es.db, "events", "")
+	}
+	es.pendingAudit.Store(newAuditEntry(ctx, "events", AdminOperationReset, "", numRecords))
+	defer es.invalidateCache()
 
 	//try to delete all files
 	files, err := filepath.Glob(es.path + "*")
@@ -767,37 +1414,85 @@ func (es *eventStoreSQLite) Reset(ctx context.Context) error {
 	return nil
 }
 
-func (es *eventStoreSQLite) encryptDomainData(dbRecord *internal.Event) error {
-	if es.options.CryptoService == nil {
+// decodeDomainData decrypts domain data if the record is encrypted and
+// verifies its checksum if enabled.
+func (es *eventStoreSQLite) decodeDomainData(ctx context.Context, dbRecord *internal.Event) error {
+	if isEncrypted(dbRecord.IsEncrypted, es.cipher() != nil) {
+		if err := es.decryptDomainData(ctx, dbRecord); err != nil {
+			return err
+		}
+	}
+	if es.cfg().VerifyChecksum {
+		if err := es.verifyChecksum(dbRecord); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// cipher returns the cipher payloads are encrypted with, nil if the store does not encrypt.
+func (es *eventStoreSQLite) cipher() payloadCipher {
+	return newPayloadCipher(es.cfg().KeyProvider, es.cfg().EncryptionAlgorithm, es.opts().CryptoService)
+}
+
+func (es *eventStoreSQLite) encryptDomainData(ctx context.Context, dbRecord *internal.Event) error {
+	c := es.cipher()
+	if c == nil {
 		return fmt.Errorf("'%s' failed - crypto service is nil", es.String())
 	}
-	domainData := []byte(dbRecord.DataBytes)
+	encrypt := func(data []byte) ([]byte, error) { return c.Encrypt(ctx, data) }
+	domainData := dbRecord.DataBytes
 	if len(domainData) < 1 {
 		return fmt.Errorf("'%s' failed - domain data is empty", es.String())
 	}
-	if encryptedData, err := es.options.CryptoService.Encrypt(domainData); err != nil {
+	if paths := es.cfg().FieldEncryption[dbRecord.DataType]; len(paths) > 0 {
+		encryptedData, err := internal.EncryptFields(domainData, paths, encrypt)
+		if err != nil {
+			return fmt.Errorf("'%s' failed - failed to encrypt domain data fields: %w", es.String(), err)
+		}
+		dbRecord.DataBytes = encryptedData
+		dbRecord.IsEncrypted = internal.NullBool{Bool: true, Valid: true}
+		return nil
+	}
+	if encryptedData, err := encrypt(domainData); err != nil {
 		return fmt.Errorf("'%s' failed - failed to encrypt domain data: %w", es.String(), err)
 	} else {
-		dbRecord.DataBytes = hex.EncodeToString(encryptedData)
+		dbRecord.DataBytes = hex.AppendEncode(nil, encryptedData)
+		dbRecord.IsEncrypted = internal.NullBool{Bool: true, Valid: true}
 	}
 	return nil
 }
 
-func (es *eventStoreSQLite) decryptDomainData(dbRecord *internal.Event) error {
-	if es.options.CryptoService == nil {
-		return fmt.Errorf("'%s' failed - crypto service is nil", es.String())
+func (es *eventStoreSQLite) decryptDomainData(ctx context.Context, dbRecord *internal.Event) error {
+	c := es.cipher()
+	if c == nil {
+		return fmt.Errorf("'%s' failed to decrypt event '%s' - %w", es.String(), dbRecord.Uuid, ErrNoCryptoService)
+	}
+	decrypt := func(data []byte) ([]byte, error) { return c.Decrypt(ctx, data) }
+	if paths := es.cfg().FieldEncryption[dbRecord.DataType]; len(paths) > 0 {
+		decryptedData, err := internal.DecryptFields(dbRecord.DataBytes, paths, decrypt)
+		switch {
+		case err == nil:
+			dbRecord.DataBytes = decryptedData
+			dbRecord.IsEncrypted = internal.NullBool{Valid: true}
+			return nil
+		case !errors.Is(err, internal.ErrNotJSONObject):
+			return fmt.Errorf("'%s' failed - failed to decrypt domain data fields: %w", es.String(), err)
+		}
+		// written as a whole before field encryption was enabled
 	}
-	encryptedData, err := hex.DecodeString(dbRecord.DataBytes)
+	encryptedData, err := hex.AppendDecode(nil, dbRecord.DataBytes)
 	if err != nil {
 		return fmt.Errorf("'%s' failed - failed to decode hex domain data: %w", es.String(), err)
 	}
 	if len(encryptedData) < 1 {
 		return fmt.Errorf("'%s' failed - encrypted domain data is empty", es.String())
 	}
-	if decryptedData, err := es.options.CryptoService.Decrypt(encryptedData); err != nil {
+	if decryptedData, err := decrypt(encryptedData); err != nil {
 		return fmt.Errorf("'%s' failed - failed to decrypt domain data: %w", es.String(), err)
 	} else {
-		dbRecord.DataBytes = string(decryptedData)
+		dbRecord.DataBytes = decryptedData
+		dbRecord.IsEncrypted = internal.NullBool{Valid: true}
 	}
 	return nil
 }
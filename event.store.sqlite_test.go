@@ -1,9 +1,13 @@
 package store_test
 
 import (
+	"bytes"
 	"context"
+	"database/sql"
 	"fmt"
 	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -687,3 +691,406 @@ func min(a, b int) int {
 	}
 	return b
 }
+
+func TestEventStoreWithFieldEncryption(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "eventStore-fields.db")
+
+	// create crypto service
+	key := []byte("12345678901234567890123456789012")
+	cryptoService, _ := comby.NewCryptoService(key)
+
+	// setup and init store
+	eventStore := store.NewEventStoreSQLite(path)
+	eventStore.Configure(
+		store.EventStoreSQLiteWithFieldEncryption("UserCreated", "email", "address.street"),
+	)
+	if err := eventStore.Init(ctx,
+		comby.EventStoreOptionWithCryptoService(cryptoService),
+	); err != nil {
+		t.Fatal(err)
+	}
+	defer eventStore.Close(ctx)
+
+	evt := createTestEvent("tenant-1", "user-domain", 1, 1000)
+	evt.SetDomainEvtName("UserCreated")
+	evt.SetDomainEvtBytes([]byte(`{"name":"John","email":"john@example.com","address":{"city":"Berlin","street":"Main St"}}`))
+	if err := eventStore.Create(ctx, comby.EventStoreCreateOptionWithEvent(evt)); err != nil {
+		t.Fatal(err)
+	}
+
+	// raw payload keeps unencrypted fields queryable
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	var name, email, city, street string
+	if err := db.QueryRowContext(ctx,
+		`SELECT json_extract(data_bytes, '$.name'), json_extract(data_bytes, '$.email'),
+		json_extract(data_bytes, '$.address.city'), json_extract(data_bytes, '$.address.street')
+		FROM events WHERE uuid=?`, evt.GetEventUuid()).Scan(&name, &email, &city, &street); err != nil {
+		t.Fatal(err)
+	}
+	if name != "John" || city != "Berlin" {
+		t.Fatalf("plaintext fields should be queryable: %q %q", name, city)
+	}
+	if !strings.HasPrefix(email, "enc:") || !strings.HasPrefix(street, "enc:") {
+		t.Fatalf("configured fields should be encrypted: %q %q", email, street)
+	}
+
+	// payload is decrypted on read
+	_evt, err := eventStore.Get(ctx, comby.EventStoreGetOptionWithEventUuid(evt.GetEventUuid()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	type Address struct {
+		City   string `json:"city"`
+		Street string `json:"street"`
+	}
+	type UserCreated struct {
+		Name    string  `json:"name"`
+		Email   string  `json:"email"`
+		Address Address `json:"address"`
+	}
+	domainData, err := comby.Deserialize(_evt.GetDomainEvtBytes(), &UserCreated{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if domainData.Email != "john@example.com" {
+		t.Fatalf("wrong email: %q", domainData.Email)
+	}
+	if domainData.Address.Street != "Main St" {
+		t.Fatalf("wrong street: %q", domainData.Address.Street)
+	}
+}
+
+func TestEventStoreFieldEncryptionChecksum(t *testing.T) {
+	ctx := context.Background()
+	key := []byte("12345678901234567890123456789012")
+	cryptoService, _ := comby.NewCryptoService(key)
+
+	eventStore := store.NewEventStoreSQLite(filepath.Join(t.TempDir(), "eventStore-fields-checksum.db"))
+	if err := eventStore.Init(ctx,
+		comby.EventStoreOptionWithCryptoService(cryptoService),
+	); err != nil {
+		t.Fatal(err)
+	}
+	defer eventStore.Close(ctx)
+
+	// written before field encryption was enabled: encrypted as a whole
+	legacy := createTestEvent("tenant-1", "user-domain", 1, 1000)
+	legacy.SetDomainEvtName("UserCreated")
+	legacy.SetDomainEvtBytes([]byte(`{"email":"jane@example.com"}`))
+	if err := eventStore.Create(ctx, comby.EventStoreCreateOptionWithEvent(legacy)); err != nil {
+		t.Fatal(err)
+	}
+
+	eventStore.Configure(
+		store.EventStoreSQLiteWithFieldEncryption("UserCreated", "email"),
+		store.EventStoreSQLiteWithChecksumVerification(true),
+	)
+
+	// key order, whitespace and escaping are kept, so the checksum still matches
+	payload := `{"z": 1, "email":"john@example.com", "note":"enc:<b>plain</b>"}`
+	evt := createTestEvent("tenant-1", "user-domain", 2, 2000)
+	evt.SetDomainEvtName("UserCreated")
+	evt.SetDomainEvtBytes([]byte(payload))
+	if err := eventStore.Create(ctx, comby.EventStoreCreateOptionWithEvent(evt)); err != nil {
+		t.Fatal(err)
+	}
+
+	// a later added path must not mistake plaintext for ciphertext
+	eventStore.Configure(store.EventStoreSQLiteWithFieldEncryption("UserCreated", "email", "note"))
+	if _evt, err := eventStore.Get(ctx, comby.EventStoreGetOptionWithEventUuid(evt.GetEventUuid())); err != nil {
+		t.Fatal(err)
+	} else if string(_evt.GetDomainEvtBytes()) != payload {
+		t.Fatalf("payload changed: %s", _evt.GetDomainEvtBytes())
+	}
+	if _evt, err := eventStore.Get(ctx, comby.EventStoreGetOptionWithEventUuid(legacy.GetEventUuid())); err != nil {
+		t.Fatal(err)
+	} else if string(_evt.GetDomainEvtBytes()) != `{"email":"jane@example.com"}` {
+		t.Fatalf("wrong legacy payload: %s", _evt.GetDomainEvtBytes())
+	}
+}
+
+func TestEventStoreDimensionTables(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "eventStore-dimensions.db")
+
+	// database created before tenant/domain were normalized
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if _, err := db.ExecContext(ctx, `
+	CREATE TABLE events (id INTEGER, instance_id INTEGER, uuid TEXT, tenant_uuid TEXT, command_uuid TEXT,
+		domain TEXT, aggregate_uuid TEXT, version INTEGER, created_at INTEGER, data_type TEXT, data_bytes TEXT,
+		PRIMARY KEY (id));
+	CREATE UNIQUE INDEX "uuid_index" ON "events" ("uuid" ASC);
+	INSERT INTO events (instance_id, uuid, tenant_uuid, command_uuid, domain, aggregate_uuid, version, created_at, data_type, data_bytes)
+	VALUES (1, 'legacy-1', 'tenant-1', 'command-1', 'domain-1', 'aggregate-1', 1, 100, 'TestEvent_1', 'test-data-1'),
+		(1, 'legacy-2', 'tenant-1', 'command-2', 'domain-2', 'aggregate-1', 2, 200, 'TestEvent_2', 'test-data-2');
+	`); err != nil {
+		t.Fatal(err)
+	}
+
+	eventStore := store.NewEventStoreSQLite(path)
+	if err := eventStore.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer eventStore.Close(ctx)
+
+	// legacy rows survive the migration
+	evt, err := eventStore.Get(ctx, comby.EventStoreGetOptionWithEventUuid("legacy-2"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if evt.GetTenantUuid() != "tenant-1" || evt.GetDomain() != "domain-2" || string(evt.GetDomainEvtBytes()) != "test-data-2" {
+		t.Fatalf("unexpected migrated event: %+v", evt)
+	}
+
+	// new events reuse existing dimension keys
+	if err := eventStore.Create(ctx, comby.EventStoreCreateOptionWithEvent(createTestEvent("tenant-1", "domain-1", 3, 300))); err != nil {
+		t.Fatal(err)
+	}
+	var total int
+	if _, err := eventStore.Replay(ctx, func(ctx context.Context, seq int64, evt comby.Event) error {
+		total++
+		return nil
+	}, store.ReplayWithTenantUuid("tenant-1")); err != nil {
+		t.Fatal(err)
+	}
+	if total != 3 {
+		t.Fatalf("expected 3 events, got %d", total)
+	}
+	var numTenants, numDomains int
+	if err := db.QueryRowContext(ctx, "SELECT (SELECT COUNT(*) FROM event_tenants), (SELECT COUNT(*) FROM event_domains)").Scan(&numTenants, &numDomains); err != nil {
+		t.Fatal(err)
+	}
+	if numTenants != 1 || numDomains != 2 {
+		t.Fatalf("unexpected dimension sizes: tenants=%d domains=%d", numTenants, numDomains)
+	}
+
+	// updates and deletes go through the view
+	evt.SetDomain("domain-3")
+	if err := eventStore.Update(ctx, comby.EventStoreUpdateOptionWithEvent(evt)); err != nil {
+		t.Fatal(err)
+	}
+	if evt, err := eventStore.Get(ctx, comby.EventStoreGetOptionWithEventUuid("legacy-2")); err != nil || evt.GetDomain() != "domain-3" {
+		t.Fatalf("update failed: %v", err)
+	}
+	if err := eventStore.Delete(ctx, comby.EventStoreDeleteOptionWithEventUuid("legacy-1")); err != nil {
+		t.Fatal(err)
+	}
+	if total := eventStore.Total(ctx); total != 2 {
+		t.Fatalf("expected 2 events after delete, got %d", total)
+	}
+}
+
+func TestEventStoreStrictTables(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "eventStore-strict.db")
+
+	eventStore := store.NewEventStoreSQLite(path)
+	if err := eventStore.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer eventStore.Close(ctx)
+
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	for _, table := range []string{"event_records", "event_tenants", "event_domains"} {
+		var strict int
+		if err := db.QueryRowContext(ctx, "SELECT strict FROM pragma_table_list WHERE name=?", table).Scan(&strict); err != nil {
+			t.Fatal(err)
+		}
+		if strict != 1 {
+			t.Fatalf("table %s is not strict", table)
+		}
+	}
+
+	// type and range violations are rejected on insert
+	for _, query := range []string{
+		`INSERT INTO events (instance_id, uuid, tenant_uuid, domain, aggregate_uuid, version, created_at, data_type, data_bytes)
+		VALUES (1, 'evt-1', 'tenant-1', 'domain-1', 'aggregate-1', -1, 100, 'TestEvent', X'00')`,
+		`INSERT INTO events (instance_id, uuid, tenant_uuid, domain, aggregate_uuid, version, created_at, data_type, data_bytes)
+		VALUES (1, 'evt-2', 'tenant-1', 'domain-1', 'aggregate-1', 1, 'yesterday', 'TestEvent', X'00')`,
+		`INSERT INTO events (instance_id, uuid, tenant_uuid, domain, aggregate_uuid, version, created_at, data_type, data_bytes)
+		VALUES (1, 'evt-3', 'tenant-1', 'domain-1', 'aggregate-1', 1, 100, 'TestEvent', NULL)`,
+	} {
+		if _, err := db.ExecContext(ctx, query); err == nil {
+			t.Fatalf("expected insert to fail: %s", query)
+		}
+	}
+}
+
+func TestEventStoreApplyOptions(t *testing.T) {
+	ctx := context.Background()
+	eventStore := store.NewEventStoreSQLite(filepath.Join(t.TempDir(), "eventStore-options.db"))
+	if err := eventStore.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer eventStore.Close(ctx)
+
+	readOnly := func(readOnly bool) comby.EventStoreOption {
+		return func(opt *comby.EventStoreOptions) (*comby.EventStoreOptions, error) {
+			opt.ReadOnly = readOnly
+			return opt, nil
+		}
+	}
+
+	// switch into read-only mode without reconnecting
+	if err := eventStore.ApplyOptions(readOnly(true)); err != nil {
+		t.Fatal(err)
+	}
+	if !eventStore.Options().ReadOnly {
+		t.Fatal("expected read-only option")
+	}
+	if err := eventStore.Create(ctx, comby.EventStoreCreateOptionWithEvent(createTestEvent("tenant-1", "domain-1", 1, 100))); err == nil {
+		t.Fatal("expected create to fail in read-only mode")
+	}
+
+	// a failing option leaves the store untouched
+	failing := func(opt *comby.EventStoreOptions) (*comby.EventStoreOptions, error) {
+		return nil, fmt.Errorf("invalid option")
+	}
+	if err := eventStore.ApplyOptions(readOnly(false), failing); err == nil {
+		t.Fatal("expected error")
+	}
+	if !eventStore.Options().ReadOnly {
+		t.Fatal("options changed by failing update")
+	}
+
+	if err := eventStore.ApplyOptions(readOnly(false)); err != nil {
+		t.Fatal(err)
+	}
+	if err := eventStore.Create(ctx, comby.EventStoreCreateOptionWithEvent(createTestEvent("tenant-1", "domain-1", 1, 100))); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestEventStoreApplyOptionsWaitsForWrites(t *testing.T) {
+	ctx := context.Background()
+	eventStore := store.NewEventStoreSQLite(filepath.Join(t.TempDir(), "eventStore-options-writes.db"))
+	if err := eventStore.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer eventStore.Close(ctx)
+
+	// writers keep writing until the store turns read-only
+	const numWriters = 8
+	var wg sync.WaitGroup
+	for w := 0; w < numWriters; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := int64(1); ; i++ {
+				evt := createTestEvent("tenant-1", "domain-1", i, int64(w)*1000+i)
+				if err := eventStore.Create(ctx, comby.EventStoreCreateOptionWithEvent(evt)); err != nil {
+					return
+				}
+			}
+		}(w)
+	}
+	time.Sleep(50 * time.Millisecond)
+
+	readOnly := func(opt *comby.EventStoreOptions) (*comby.EventStoreOptions, error) {
+		opt.ReadOnly = true
+		return opt, nil
+	}
+	if err := eventStore.ApplyOptions(readOnly); err != nil {
+		t.Fatal(err)
+	}
+	total := eventStore.Total(ctx)
+	wg.Wait()
+	if after := eventStore.Total(ctx); after != total {
+		t.Fatalf("write landed after switching to read-only: %d != %d", after, total)
+	}
+}
+
+func TestEventStoreConcurrentWriters(t *testing.T) {
+	ctx := context.Background()
+	eventStore := store.NewEventStoreSQLite(filepath.Join(t.TempDir(), "eventStore-concurrent.db"))
+	if err := eventStore.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer eventStore.Close(ctx)
+
+	const numWriters = 16
+	const numEvents = 25
+	var wg sync.WaitGroup
+	errs := make(chan error, numWriters)
+	for w := 0; w < numWriters; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := int64(1); i <= numEvents; i++ {
+				evt := createTestEvent(fmt.Sprintf("tenant-%d", w), "domain-1", i, i*100)
+				if err := eventStore.Create(ctx, comby.EventStoreCreateOptionWithEvent(evt)); err != nil {
+					errs <- err
+					return
+				}
+				evt.SetDomain("domain-2")
+				if err := eventStore.Update(ctx, comby.EventStoreUpdateOptionWithEvent(evt)); err != nil {
+					errs <- err
+					return
+				}
+				if i%5 == 0 {
+					if err := eventStore.Delete(ctx, comby.EventStoreDeleteOptionWithEventUuid(evt.GetEventUuid())); err != nil {
+						errs <- err
+						return
+					}
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatal(err)
+	}
+	if total := eventStore.Total(ctx); total != numWriters*(numEvents-numEvents/5) {
+		t.Fatalf("unexpected total: %d", total)
+	}
+}
+
+func TestEventStoreListPayloads(t *testing.T) {
+	ctx := context.Background()
+	eventStore := store.NewEventStoreSQLite(filepath.Join(t.TempDir(), "eventStore-payloads.db"))
+	if err := eventStore.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer eventStore.Close(ctx)
+
+	// small payloads share blocks, large ones are allocated individually
+	var payloads [][]byte
+	for i := int64(1); i <= 50; i++ {
+		payload := bytes.Repeat([]byte{byte('a' + i%26)}, int(i*i*10))
+		evt := createTestEvent("tenant-1", "domain-1", i, i*100)
+		evt.SetDomainEvtBytes(payload)
+		if err := eventStore.Create(ctx, comby.EventStoreCreateOptionWithEvent(evt)); err != nil {
+			t.Fatal(err)
+		}
+		payloads = append(payloads, payload)
+	}
+	evts, _, err := eventStore.List(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(evts) != len(payloads) {
+		t.Fatalf("expected %d events, got %d", len(payloads), len(evts))
+	}
+	// appending to a payload must not overwrite the next one
+	_ = append(evts[0].GetDomainEvtBytes(), 'X')
+	for i, evt := range evts {
+		if !bytes.Equal(evt.GetDomainEvtBytes(), payloads[i]) {
+			t.Fatalf("unexpected payload of event %d", i)
+		}
+	}
+}
@@ -0,0 +1,243 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/gradientzero/comby-store-sqlite/internal"
+	"github.com/gradientzero/comby/v3"
+)
+
+// ExportReport is the result of ExportEvents and ExportCommands.
+type ExportReport struct {
+	Exported int64
+	// records with a uuid already known to the destination
+	Skipped int64
+}
+
+// number of records copied per transaction while exporting
+const exportBatchSize = 1000
+
+// ExportEvents copies all events of src to dst in one streaming pass. Each
+// payload is decrypted with the crypto service of src and encrypted with the
+// one of dst (including their field encryption settings), so encrypted stores
+// can be moved between environments with different keys. Stores without a
+// crypto service read or write plain payloads, which also allows to encrypt or
+// decrypt a store as a whole. Known events are skipped, an interrupted export
+// can simply be run again. Fields can be pseudonymized with ExportOptions,
+// e.g. to copy production data into staging.
+func ExportEvents(ctx context.Context, src, dst comby.EventStore, opts ...ExportOption) (*ExportReport, error) {
+	ses, ok := src.(*eventStoreSQLite)
+	if !ok {
+		return nil, fmt.Errorf("export requires sqlite event stores")
+	}
+	des, ok := dst.(*eventStoreSQLite)
+	if !ok {
+		return nil, fmt.Errorf("'%s' failed to export - destination is not a sqlite event store", ses.String())
+	}
+	if ses.writeMu == des.writeMu {
+		return nil, fmt.Errorf("'%s' failed to export - stores share one database", ses.String())
+	}
+	if des.opts().ReadOnly {
+		return nil, fmt.Errorf("'%s' failed to export - instance is readonly", des.String())
+	}
+	config, err := newExportConfig(opts...)
+	if err != nil {
+		return nil, fmt.Errorf("'%s' failed to export - %w", ses.String(), err)
+	}
+	done, err := des.beginWrite(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	report := &ExportReport{}
+	query := fmt.Sprintf("SELECT %s FROM events WHERE id>? ORDER BY id ASC LIMIT %d;", eventSelectColumns, exportBatchSize)
+	var lastId int64
+	for {
+		dbRecords, err := ses.queryEvents(ctx, query, []any{lastId})
+		if err != nil {
+			return report, classifyError(err)
+		}
+		if len(dbRecords) == 0 {
+			return report, nil
+		}
+		err = runTx(ctx, des.db, func(tx *sql.Tx) error {
+			for _, dbRecord := range dbRecords {
+				var known int
+				if err := tx.QueryRowContext(ctx, "SELECT COUNT(*) FROM event_records WHERE uuid=?;", dbRecord.Uuid).Scan(&known); err != nil {
+					return err
+				}
+				if known > 0 {
+					report.Skipped++
+					continue
+				}
+				if err := ses.decodeDomainData(ctx, dbRecord); err != nil {
+					return err
+				}
+				if err := config.pseudonymizeEvent(dbRecord); err != nil {
+					return err
+				}
+				if des.cipher() != nil {
+					if err := des.encryptDomainData(ctx, dbRecord); err != nil {
+						return err
+					}
+				}
+				if err := insertEventRecord(ctx, tx, sql.NullInt64{}, dbRecord); err != nil {
+					return err
+				}
+				report.Exported++
+			}
+			return nil
+		})
+		if err != nil {
+			return report, fmt.Errorf("'%s' failed to export - %w", ses.String(), err)
+		}
+		lastId = dbRecords[len(dbRecords)-1].ID.Int64
+	}
+}
+
+// ExportCommands is ExportEvents for command stores. The processing status
+// of commands is kept.
+func ExportCommands(ctx context.Context, src, dst comby.CommandStore, opts ...ExportOption) (*ExportReport, error) {
+	scs, ok := src.(*commandStoreSQLite)
+	if !ok {
+		return nil, fmt.Errorf("export requires sqlite command stores")
+	}
+	dcs, ok := dst.(*commandStoreSQLite)
+	if !ok {
+		return nil, fmt.Errorf("'%s' failed to export - destination is not a sqlite command store", scs.String())
+	}
+	if scs.writeMu == dcs.writeMu {
+		return nil, fmt.Errorf("'%s' failed to export - stores share one database", scs.String())
+	}
+	if dcs.opts().ReadOnly {
+		return nil, fmt.Errorf("'%s' failed to export - instance is readonly", dcs.String())
+	}
+	config, err := newExportConfig(opts...)
+	if err != nil {
+		return nil, fmt.Errorf("'%s' failed to export - %w", scs.String(), err)
+	}
+	done, err := dcs.beginWrite(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	report := &ExportReport{}
+	query := fmt.Sprintf("SELECT %s, status, processed_at, error_text FROM commands WHERE id>? ORDER BY id ASC LIMIT %d;", commandSelectColumns, exportBatchSize)
+	var lastId int64
+	for {
+		records, err := queryExportCommands(ctx, scs.db, query, lastId)
+		if err != nil {
+			return report, classifyError(err)
+		}
+		if len(records) == 0 {
+			return report, nil
+		}
+		err = runTx(ctx, dcs.db, func(tx *sql.Tx) error {
+			for _, record := range records {
+				var known int
+				if err := tx.QueryRowContext(ctx, "SELECT COUNT(*) FROM commands WHERE uuid=?;", record.Uuid).Scan(&known); err != nil {
+					return err
+				}
+				if known > 0 {
+					report.Skipped++
+					continue
+				}
+				if err := scs.decodeDomainData(ctx, &record.Command); err != nil {
+					return err
+				}
+				if err := config.pseudonymizeCommand(&record.Command); err != nil {
+					return err
+				}
+				if dcs.cipher() != nil {
+					if err := dcs.encryptDomainData(ctx, &record.Command); err != nil {
+						return err
+					}
+				}
+				if err := insertCommandRecord(ctx, tx, record); err != nil {
+					return err
+				}
+				report.Exported++
+			}
+			return nil
+		})
+		if err != nil {
+			return report, fmt.Errorf("'%s' failed to export - %w", scs.String(), err)
+		}
+		lastId = records[len(records)-1].ID.Int64
+	}
+}
+
+// exportCommand is a command record including its processing status.
+type exportCommand struct {
+	internal.Command
+	CommandStatus
+}
+
+func queryExportCommands(ctx context.Context, q queryer, query string, args ...any) ([]*exportCommand, error) {
+	rows, err := q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var records []*exportCommand
+	for rows.Next() {
+		var record exportCommand
+		row := extendedScanner{row: rows, extra: []any{&record.Status, &record.ProcessedAt, &record.ErrorText}}
+		if err := scanCommand(row, &record.Command); err != nil {
+			return nil, err
+		}
+		records = append(records, &record)
+	}
+	return records, rows.Err()
+}
+
+// extendedScanner scans additional columns selected after the regular ones.
+type extendedScanner struct {
+	row   rowScanner
+	extra []any
+}
+
+func (s extendedScanner) Scan(dest ...any) error {
+	return s.row.Scan(append(dest, s.extra...)...)
+}
+
+func insertCommandRecord(ctx context.Context, q queryer, record *exportCommand) error {
+	query := `INSERT INTO commands (
+	instance_id,
+	uuid,
+	tenant_uuid,
+	workspace_uuid,
+	domain,
+	created_at,
+	data_type,
+	data_bytes,
+	req_ctx,
+	checksum,
+	status,
+	processed_at,
+	error_text,
+	is_encrypted
+) VALUES (?,?,?,?,?,?,?,?,?,?,?,?,?,?);`
+	_, err := q.ExecContext(ctx, query,
+		record.InstanceId,
+		record.Uuid,
+		record.TenantUuid,
+		record.WorkspaceUuid,
+		record.Domain,
+		record.CreatedAt,
+		record.DataType,
+		record.DataBytes,
+		record.ReqCtx,
+		record.Checksum,
+		record.Status,
+		record.ProcessedAt,
+		record.ErrorText,
+		record.IsEncrypted,
+	)
+	return err
+}
@@ -0,0 +1,102 @@
+package store_test
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	store "github.com/gradientzero/comby-store-sqlite"
+	"github.com/gradientzero/comby/v3"
+)
+
+func TestExportWithReKeying(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	srcCrypto, _ := comby.NewCryptoService([]byte("12345678901234567890123456789012"))
+	dstCrypto, _ := comby.NewCryptoService([]byte("abcdefghijklmnopqrstuvwxyz012345"))
+
+	srcEvents := store.NewEventStoreSQLite(filepath.Join(dir, "src.db"))
+	if err := srcEvents.Init(ctx, comby.EventStoreOptionWithCryptoService(srcCrypto)); err != nil {
+		t.Fatal(err)
+	}
+	defer srcEvents.Close(ctx)
+	srcCommands := store.NewCommandStoreSQLite(filepath.Join(dir, "src.db"))
+	if err := srcCommands.Init(ctx, comby.CommandStoreOptionWithCryptoService(srcCrypto)); err != nil {
+		t.Fatal(err)
+	}
+	defer srcCommands.Close(ctx)
+	dstEvents := store.NewEventStoreSQLite(filepath.Join(dir, "dst.db"))
+	dstEvents.Configure(store.EventStoreSQLiteWithChecksumVerification(true))
+	if err := dstEvents.Init(ctx, comby.EventStoreOptionWithCryptoService(dstCrypto)); err != nil {
+		t.Fatal(err)
+	}
+	defer dstEvents.Close(ctx)
+	dstCommands := store.NewCommandStoreSQLite(filepath.Join(dir, "dst.db"))
+	if err := dstCommands.Init(ctx, comby.CommandStoreOptionWithCryptoService(dstCrypto)); err != nil {
+		t.Fatal(err)
+	}
+	defer dstCommands.Close(ctx)
+
+	var evts []comby.Event
+	for i := int64(1); i <= 3; i++ {
+		evt := createTestEvent("tenant-1", "domain-1", i, i*100)
+		if err := srcEvents.Create(ctx, comby.EventStoreCreateOptionWithEvent(evt)); err != nil {
+			t.Fatal(err)
+		}
+		evts = append(evts, evt)
+	}
+	cmd := createTestCommand("tenant-1", "domain-1", 100)
+	if err := srcCommands.Create(ctx, comby.CommandStoreCreateOptionWithCommand(cmd)); err != nil {
+		t.Fatal(err)
+	}
+	if err := srcCommands.MarkProcessed(ctx, cmd.GetCommandUuid()); err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := store.ExportEvents(ctx, srcEvents, dstEvents)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Exported != 3 || report.Skipped != 0 {
+		t.Fatalf("unexpected report: %+v", report)
+	}
+	if report, err = store.ExportCommands(ctx, srcCommands, dstCommands); err != nil || report.Exported != 1 {
+		t.Fatalf("unexpected command export: %+v %v", report, err)
+	}
+
+	// payloads are readable with the destination key
+	for _, evt := range evts {
+		exported, err := dstEvents.Get(ctx, comby.EventStoreGetOptionWithEventUuid(evt.GetEventUuid()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(exported.GetDomainEvtBytes()) != string(evt.GetDomainEvtBytes()) {
+			t.Fatalf("unexpected payload: %s", exported.GetDomainEvtBytes())
+		}
+	}
+	exported, err := dstCommands.Get(ctx, comby.CommandStoreGetOptionWithCommandUuid(cmd.GetCommandUuid()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(exported.GetDomainCmdBytes()) != string(cmd.GetDomainCmdBytes()) {
+		t.Fatalf("unexpected payload: %s", exported.GetDomainCmdBytes())
+	}
+	if status, err := dstCommands.GetStatus(ctx, cmd.GetCommandUuid()); err != nil || status.Status != store.CommandStatusProcessed {
+		t.Fatalf("unexpected status: %+v %v", status, err)
+	}
+
+	// the source key does not decrypt the destination
+	reader := store.NewEventStoreSQLite(filepath.Join(dir, "dst.db"))
+	if err := reader.Init(ctx, comby.EventStoreOptionWithCryptoService(srcCrypto)); err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Close(ctx)
+	if _, err := reader.Get(ctx, comby.EventStoreGetOptionWithEventUuid(evts[0].GetEventUuid())); err == nil {
+		t.Fatal("expected decryption with the source key to fail")
+	}
+
+	// a second run skips known events
+	if report, err = store.ExportEvents(ctx, srcEvents, dstEvents); err != nil || report.Exported != 0 || report.Skipped != 3 {
+		t.Fatalf("unexpected second export: %+v %v", report, err)
+	}
+}
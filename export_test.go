@@ -0,0 +1,27 @@
+package store
+
+import "github.com/gradientzero/comby/v3"
+
+// EventListQuery returns the statements and args List of eventStore runs for
+// opts, so tests can check the query building directly.
+func EventListQuery(eventStore EventStoreSQLite, opts ...comby.EventStoreListOption) (string, string, []any, error) {
+	es := eventStore.(*eventStoreSQLite)
+	listOpts, filter, err := es.listOptions(opts)
+	if err != nil {
+		return "", "", nil, err
+	}
+	query := eventListQuery("events", eventSelectColumns, listOpts, filter, es.cfg().Tenant)
+	return query.selectSQL, query.countSQL, query.args, nil
+}
+
+// CommandListQuery returns the statements and args List of commandStore runs
+// for opts.
+func CommandListQuery(commandStore CommandStoreSQLite, opts ...comby.CommandStoreListOption) (string, string, []any, error) {
+	cs := commandStore.(*commandStoreSQLite)
+	listOpts, filter, err := cs.listOptions(opts)
+	if err != nil {
+		return "", "", nil, err
+	}
+	query := commandListQuery(listOpts, filter, cs.cfg().Tenant)
+	return query.selectSQL, query.countSQL, query.args, nil
+}
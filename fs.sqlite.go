@@ -0,0 +1,108 @@
+package store
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+)
+
+// EventStoreSQLiteWithFS reads the database from fsys, e.g. an embed.FS
+// shipping seed or demo data inside the binary, os.DirFS or a custom file
+// system. The path of the store is the name of the file within fsys. Init
+// copies the file into a temporary file, which Close removes again, and
+// switches the store to read-only. The database must be migrated by this
+// release and checkpointed, a WAL file next to it is not read.
+func EventStoreSQLiteWithFS(fsys fs.FS) EventStoreSQLiteOption {
+	return func(c *eventStoreSQLiteConfig) { c.FS = fsys }
+}
+
+// CommandStoreSQLiteWithFS reads the database of the command store from fsys,
+// see EventStoreSQLiteWithFS.
+func CommandStoreSQLiteWithFS(fsys fs.FS) CommandStoreSQLiteOption {
+	return func(c *commandStoreSQLiteConfig) { c.FS = fsys }
+}
+
+// dbPath returns the file a store connects to.
+func dbPath(path, fsCopy string) string {
+	if len(fsCopy) > 0 {
+		return fsCopy
+	}
+	return path
+}
+
+// copyFromFS copies the file name of fsys into a temporary file and returns
+// its path.
+func copyFromFS(fsys fs.FS, name string) (string, error) {
+	src, err := fsys.Open(name)
+	if err != nil {
+		return "", err
+	}
+	defer src.Close()
+	dst, err := os.CreateTemp("", "comby-store-*.db")
+	if err != nil {
+		return "", err
+	}
+	if _, err := io.Copy(dst, src); err != nil {
+		dst.Close()
+		os.Remove(dst.Name())
+		return "", err
+	}
+	if err := dst.Close(); err != nil {
+		os.Remove(dst.Name())
+		return "", err
+	}
+	return dst.Name(), nil
+}
+
+// removeFSCopy removes the temporary copy at *path including the files
+// sqlite creates next to it.
+func removeFSCopy(path *string) error {
+	if len(*path) == 0 {
+		return nil
+	}
+	err := removeDatabaseFiles(*path)
+	*path = ""
+	return err
+}
+
+// initFS copies the database of a store reading from an fs.FS and switches
+// the store to read-only.
+func (es *eventStoreSQLite) initFS() error {
+	fsys := es.cfg().FS
+	if fsys == nil {
+		return nil
+	}
+	// Init may be called again
+	if err := removeFSCopy(&es.fsCopy); err != nil {
+		return fmt.Errorf("'%s' failed to init - %w", es.String(), err)
+	}
+	fsCopy, err := copyFromFS(fsys, es.path)
+	if err != nil {
+		return fmt.Errorf("'%s' failed to init - %w", es.String(), err)
+	}
+	es.mu.Lock()
+	es.fsCopy = fsCopy
+	es.options.ReadOnly = true
+	es.mu.Unlock()
+	return nil
+}
+
+func (cs *commandStoreSQLite) initFS() error {
+	fsys := cs.cfg().FS
+	if fsys == nil {
+		return nil
+	}
+	if err := removeFSCopy(&cs.fsCopy); err != nil {
+		return fmt.Errorf("'%s' failed to init - %w", cs.String(), err)
+	}
+	fsCopy, err := copyFromFS(fsys, cs.path)
+	if err != nil {
+		return fmt.Errorf("'%s' failed to init - %w", cs.String(), err)
+	}
+	cs.mu.Lock()
+	cs.fsCopy = fsCopy
+	cs.options.ReadOnly = true
+	cs.mu.Unlock()
+	return nil
+}
@@ -0,0 +1,95 @@
+package store_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	store "github.com/gradientzero/comby-store-sqlite"
+	"github.com/gradientzero/comby/v3"
+)
+
+func TestEventStoreFS(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	// seed database as it would be embedded
+	seed := store.NewEventStoreSQLite(filepath.Join(dir, "seed.db"))
+	if err := seed.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	evt := createTestEvent("tenant-1", "domain-1", 1, 100)
+	if err := seed.Create(ctx, comby.EventStoreCreateOptionWithEvent(evt)); err != nil {
+		t.Fatal(err)
+	}
+	if err := seed.Close(ctx); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "seed.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	fsys := fstest.MapFS{"data/seed.db": &fstest.MapFile{Data: data}}
+
+	eventStore := store.NewEventStoreSQLite("data/seed.db")
+	eventStore.Configure(store.EventStoreSQLiteWithFS(fsys))
+	if err := eventStore.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if !eventStore.Options().ReadOnly {
+		t.Fatal("expected a read-only store")
+	}
+	got, err := eventStore.Get(ctx, comby.EventStoreGetOptionWithEventUuid(evt.GetEventUuid()))
+	if err != nil || got == nil {
+		t.Fatalf("expected seeded event: %v", err)
+	}
+	if err := eventStore.Create(ctx, comby.EventStoreCreateOptionWithEvent(createTestEvent("tenant-1", "domain-1", 2, 200))); err == nil {
+		t.Fatal("expected create to fail")
+	}
+	if err := eventStore.Close(ctx); err != nil {
+		t.Fatal(err)
+	}
+	// the embedded file is untouched
+	if string(fsys["data/seed.db"].Data) != string(data) {
+		t.Fatal("expected the file of the fs to be unchanged")
+	}
+
+	missing := store.NewEventStoreSQLite("missing.db")
+	missing.Configure(store.EventStoreSQLiteWithFS(fsys))
+	if err := missing.Init(ctx); err == nil {
+		t.Fatal("expected init of a missing file to fail")
+	}
+}
+
+func TestCommandStoreFS(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	seed := store.NewCommandStoreSQLite(filepath.Join(dir, "commands.db"))
+	if err := seed.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	cmd := createTestCommand("tenant-1", "domain-1", 100)
+	if err := seed.Create(ctx, comby.CommandStoreCreateOptionWithCommand(cmd)); err != nil {
+		t.Fatal(err)
+	}
+	if err := seed.Close(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	commandStore := store.NewCommandStoreSQLite("commands.db")
+	commandStore.Configure(store.CommandStoreSQLiteWithFS(os.DirFS(dir)))
+	if err := commandStore.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer commandStore.Close(ctx)
+	got, err := commandStore.Get(ctx, comby.CommandStoreGetOptionWithCommandUuid(cmd.GetCommandUuid()))
+	if err != nil || got == nil {
+		t.Fatalf("expected seeded command: %v", err)
+	}
+	if !commandStore.Options().ReadOnly {
+		t.Fatal("expected a read-only store")
+	}
+}
@@ -4,6 +4,8 @@ go 1.22.0
 
 require (
 	github.com/gradientzero/comby/v3 v3.0.0
+	github.com/mattn/go-sqlite3 v1.14.22
+	golang.org/x/crypto v0.21.0
 	modernc.org/sqlite v1.28.0
 )
 
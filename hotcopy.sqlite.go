@@ -0,0 +1,439 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/gradientzero/comby-store-sqlite/internal"
+	"github.com/gradientzero/comby/v3"
+)
+
+// HotCopyMismatch is a record whose copy in the destination differs from the
+// source, found in dual-write verification mode.
+type HotCopyMismatch struct {
+	// "event" or "command"
+	Kind string
+	Uuid string
+	// first differing attribute, e.g. "version"
+	Field string
+}
+
+// HotCopyReport is the result of copying records with a HotCopy.
+type HotCopyReport struct {
+	Events   int64
+	Commands int64
+	// records with a uuid already known to the destination
+	Skipped int64
+	// known records compared in dual-write verification mode
+	Verified   int64
+	Mismatches []HotCopyMismatch
+}
+
+// HotCopyLag is the number of source records not copied yet.
+type HotCopyLag struct {
+	Events   int64
+	Commands int64
+}
+
+type HotCopyOption func(*HotCopy)
+
+// HotCopyWithCommands also copies the commands of src to dst.
+func HotCopyWithCommands(src, dst comby.CommandStore) HotCopyOption {
+	return func(h *HotCopy) { h.srcCommands, h.dstCommands = src, dst }
+}
+
+// HotCopyWithBatchSize sets the number of records copied before the offset is
+// stored, 1000 by default.
+func HotCopyWithBatchSize(n int) HotCopyOption {
+	return func(h *HotCopy) { h.batchSize = n }
+}
+
+// HotCopyWithPollInterval sets how often Run looks for new records.
+func HotCopyWithPollInterval(interval time.Duration) HotCopyOption {
+	return func(h *HotCopy) { h.pollInterval = interval }
+}
+
+// HotCopyWithDualWriteVerification compares records already known to the
+// destination with the source instead of skipping them. Use it while the
+// application writes to both stores, differences are reported as mismatches.
+func HotCopyWithDualWriteVerification() HotCopyOption {
+	return func(h *HotCopy) { h.verify = true }
+}
+
+// HotCopy copies the events, and optionally the commands, of sqlite stores
+// into any other comby store implementation, e.g. to migrate to Postgres
+// without downtime: Run copies new records while the application keeps
+// writing to the sqlite stores, Cutover copies the rest after switching them
+// to read-only. Payloads are copied decrypted, the destination encrypts them
+// with its own settings. The sequence of the last copied record is stored per
+// name in the hot_copy_offsets table of each source, so an interrupted copy
+// resumes after its last batch. Records already known to the destination are
+// skipped, which requires its Get to return nil for unknown uuids.
+type HotCopy struct {
+	name         string
+	es           *eventStoreSQLite
+	dst          comby.EventStore
+	srcCommands  comby.CommandStore
+	dstCommands  comby.CommandStore
+	cs           *commandStoreSQLite
+	batchSize    int
+	pollInterval time.Duration
+	verify       bool
+}
+
+func NewHotCopy(name string, src, dst comby.EventStore, opts ...HotCopyOption) (*HotCopy, error) {
+	es, ok := src.(*eventStoreSQLite)
+	if !ok {
+		return nil, fmt.Errorf("hot copy requires a sqlite event store")
+	}
+	if len(name) == 0 {
+		return nil, fmt.Errorf("'%s' failed to create hot copy - name is required", es.String())
+	}
+	if dst == nil {
+		return nil, fmt.Errorf("'%s' failed to create hot copy - destination is nil", es.String())
+	}
+	h := &HotCopy{
+		name:         name,
+		es:           es,
+		dst:          dst,
+		batchSize:    exportBatchSize,
+		pollInterval: time.Second,
+	}
+	for _, opt := range opts {
+		opt(h)
+	}
+	if h.srcCommands != nil {
+		cs, ok := h.srcCommands.(*commandStoreSQLite)
+		if !ok {
+			return nil, fmt.Errorf("'%s' failed to create hot copy - commands require a sqlite command store", es.String())
+		}
+		if h.dstCommands == nil {
+			return nil, fmt.Errorf("'%s' failed to create hot copy - command destination is nil", es.String())
+		}
+		h.cs = cs
+	}
+	if h.batchSize < 1 || h.pollInterval <= 0 {
+		return nil, fmt.Errorf("'%s' failed to create hot copy - invalid batch size or poll interval", es.String())
+	}
+	return h, nil
+}
+
+var hotCopyTables = []strictTable{
+	{
+		name: "hot_copy_offsets",
+		columns: `name TEXT NOT NULL,
+		store TEXT NOT NULL,
+		seq INTEGER NOT NULL,
+		updated_at INTEGER NOT NULL,
+		PRIMARY KEY (name, store)`,
+		copyColumns: `name, store, seq, updated_at`,
+	},
+}
+
+// Init creates the offsets table in the sources, which must be initialized
+// before.
+func (h *HotCopy) Init(ctx context.Context) error {
+	if h.es.db == nil || (h.cs != nil && h.cs.db == nil) {
+		return fmt.Errorf("'%s' failed to init hot copy - source is not initialized", h.es.String())
+	}
+	dbs := []*sql.DB{h.es.db}
+	if h.cs != nil && h.cs.db != h.es.db {
+		dbs = append(dbs, h.cs.db)
+	}
+	for _, db := range dbs {
+		err := migrateTx(ctx, db, func(tx *sql.Tx) error {
+			return migrateStrictTables(ctx, tx, loggerOrDiscard(h.es.cfg().Logger), hotCopyTables...)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (h *HotCopy) offset(ctx context.Context, db *sql.DB, store string) (int64, error) {
+	var seq int64
+	err := db.QueryRowContext(ctx, "SELECT seq FROM hot_copy_offsets WHERE name=? AND store=?;", h.name, store).Scan(&seq)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	return seq, err
+}
+
+func (h *HotCopy) commit(ctx context.Context, db *sql.DB, writeMu *sync.Mutex, store string, seq int64) error {
+	// the offset is bookkeeping of the copy, it is also written to read-only sources
+	writeMu.Lock()
+	defer writeMu.Unlock()
+	query := `INSERT INTO hot_copy_offsets (name, store, seq, updated_at) VALUES (?, ?, ?, ?)
+		ON CONFLICT(name, store) DO UPDATE SET seq=excluded.seq, updated_at=excluded.updated_at;`
+	_, err := db.ExecContext(ctx, query, h.name, store, seq, time.Now().UnixNano())
+	return err
+}
+
+// Lag returns the number of source records after the stored offsets.
+func (h *HotCopy) Lag(ctx context.Context) (*HotCopyLag, error) {
+	lag := &HotCopyLag{}
+	seq, err := h.offset(ctx, h.es.db, "events")
+	if err == nil {
+		err = h.es.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM events WHERE id>?;", seq).Scan(&lag.Events)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("'%s' failed to read hot copy lag - %w", h.es.String(), classifyError(err))
+	}
+	if h.cs != nil {
+		seq, err := h.offset(ctx, h.cs.db, "commands")
+		if err == nil {
+			err = h.cs.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM commands WHERE id>?;", seq).Scan(&lag.Commands)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("'%s' failed to read hot copy lag - %w", h.cs.String(), classifyError(err))
+		}
+	}
+	return lag, nil
+}
+
+// Poll copies all records after the stored offsets. The offset is stored
+// after each batch; if copying fails, the next call starts again with the
+// first record of the failed batch.
+func (h *HotCopy) Poll(ctx context.Context) (*HotCopyReport, error) {
+	report := &HotCopyReport{}
+	if err := h.copyEvents(ctx, report); err != nil {
+		return report, err
+	}
+	if h.cs != nil {
+		if err := h.copyCommands(ctx, report); err != nil {
+			return report, err
+		}
+	}
+	return report, nil
+}
+
+func (h *HotCopy) copyEvents(ctx context.Context, report *HotCopyReport) error {
+	seq, err := h.offset(ctx, h.es.db, "events")
+	if err != nil {
+		return fmt.Errorf("'%s' failed to copy events - %w", h.es.String(), classifyError(err))
+	}
+	query := fmt.Sprintf("SELECT %s FROM events WHERE id>? ORDER BY id ASC LIMIT %d;", eventSelectColumns, h.batchSize)
+	for {
+		dbRecords, err := h.es.queryEvents(ctx, query, []any{seq})
+		if err != nil {
+			return fmt.Errorf("'%s' failed to copy events - %w", h.es.String(), classifyError(err))
+		}
+		if len(dbRecords) == 0 {
+			return nil
+		}
+		for _, dbRecord := range dbRecords {
+			evt, err := h.es.decodeEvent(ctx, dbRecord)
+			if err != nil {
+				return err
+			}
+			if err := h.copyEvent(ctx, evt, report); err != nil {
+				return fmt.Errorf("'%s' failed to copy event '%s' - %w", h.es.String(), dbRecord.Uuid, err)
+			}
+		}
+		seq = dbRecords[len(dbRecords)-1].ID.Int64
+		if err := h.commit(ctx, h.es.db, h.es.writeMu, "events", seq); err != nil {
+			return fmt.Errorf("'%s' failed to store hot copy offset - %w", h.es.String(), classifyError(err))
+		}
+	}
+}
+
+func (h *HotCopy) copyEvent(ctx context.Context, evt comby.Event, report *HotCopyReport) error {
+	known, err := h.dst.Get(ctx, comby.EventStoreGetOptionWithEventUuid(evt.GetEventUuid()))
+	if err != nil {
+		return err
+	}
+	if known == nil {
+		if err := h.dst.Create(ctx, comby.EventStoreCreateOptionWithEvent(evt)); err != nil {
+			return err
+		}
+		report.Events++
+		return nil
+	}
+	if !h.verify {
+		report.Skipped++
+		return nil
+	}
+	report.Verified++
+	if field := diffEvents(evt, known); len(field) > 0 {
+		report.Mismatches = append(report.Mismatches, HotCopyMismatch{Kind: "event", Uuid: evt.GetEventUuid(), Field: field})
+	}
+	return nil
+}
+
+// diffEvents returns the first attribute in which a and b differ.
+func diffEvents(a, b comby.Event) string {
+	switch {
+	case a.GetTenantUuid() != b.GetTenantUuid():
+		return "tenant_uuid"
+	case a.GetWorkspaceUuid() != b.GetWorkspaceUuid():
+		return "workspace_uuid"
+	case a.GetCommandUuid() != b.GetCommandUuid():
+		return "command_uuid"
+	case a.GetDomain() != b.GetDomain():
+		return "domain"
+	case a.GetAggregateUuid() != b.GetAggregateUuid():
+		return "aggregate_uuid"
+	case a.GetVersion() != b.GetVersion():
+		return "version"
+	case a.GetCreatedAt() != b.GetCreatedAt():
+		return "created_at"
+	case a.GetDomainEvtName() != b.GetDomainEvtName():
+		return "data_type"
+	case !bytes.Equal(a.GetDomainEvtBytes(), b.GetDomainEvtBytes()):
+		return "data"
+	}
+	return ""
+}
+
+func (h *HotCopy) copyCommands(ctx context.Context, report *HotCopyReport) error {
+	seq, err := h.offset(ctx, h.cs.db, "commands")
+	if err != nil {
+		return fmt.Errorf("'%s' failed to copy commands - %w", h.cs.String(), classifyError(err))
+	}
+	query := fmt.Sprintf("SELECT %s FROM commands WHERE id>? ORDER BY id ASC LIMIT %d;", commandSelectColumns, h.batchSize)
+	for {
+		dbRecords, err := queryCommandRecords(ctx, h.cs.db, query, seq)
+		if err != nil {
+			return fmt.Errorf("'%s' failed to copy commands - %w", h.cs.String(), classifyError(err))
+		}
+		if len(dbRecords) == 0 {
+			return nil
+		}
+		for _, dbRecord := range dbRecords {
+			if err := h.cs.decodeDomainData(ctx, dbRecord); err != nil {
+				return err
+			}
+			cmd, err := internal.DbCommandToBaseCommand(dbRecord)
+			if err != nil {
+				return err
+			}
+			if err := h.copyCommand(ctx, cmd, report); err != nil {
+				return fmt.Errorf("'%s' failed to copy command '%s' - %w", h.cs.String(), dbRecord.Uuid, err)
+			}
+		}
+		seq = dbRecords[len(dbRecords)-1].ID.Int64
+		if err := h.commit(ctx, h.cs.db, h.cs.writeMu, "commands", seq); err != nil {
+			return fmt.Errorf("'%s' failed to store hot copy offset - %w", h.cs.String(), classifyError(err))
+		}
+	}
+}
+
+func (h *HotCopy) copyCommand(ctx context.Context, cmd comby.Command, report *HotCopyReport) error {
+	known, err := h.dstCommands.Get(ctx, comby.CommandStoreGetOptionWithCommandUuid(cmd.GetCommandUuid()))
+	if err != nil {
+		return err
+	}
+	if known == nil {
+		if err := h.dstCommands.Create(ctx, comby.CommandStoreCreateOptionWithCommand(cmd)); err != nil {
+			return err
+		}
+		report.Commands++
+		return nil
+	}
+	if !h.verify {
+		report.Skipped++
+		return nil
+	}
+	report.Verified++
+	if field := diffCommands(cmd, known); len(field) > 0 {
+		report.Mismatches = append(report.Mismatches, HotCopyMismatch{Kind: "command", Uuid: cmd.GetCommandUuid(), Field: field})
+	}
+	return nil
+}
+
+// diffCommands returns the first attribute in which a and b differ.
+func diffCommands(a, b comby.Command) string {
+	switch {
+	case a.GetTenantUuid() != b.GetTenantUuid():
+		return "tenant_uuid"
+	case a.GetWorkspaceUuid() != b.GetWorkspaceUuid():
+		return "workspace_uuid"
+	case a.GetDomain() != b.GetDomain():
+		return "domain"
+	case a.GetCreatedAt() != b.GetCreatedAt():
+		return "created_at"
+	case a.GetDomainCmdName() != b.GetDomainCmdName():
+		return "data_type"
+	case !bytes.Equal(a.GetDomainCmdBytes(), b.GetDomainCmdBytes()):
+		return "data"
+	}
+	return ""
+}
+
+// Run polls until ctx is done. Failures are logged and retried after the
+// poll interval.
+func (h *HotCopy) Run(ctx context.Context) error {
+	logger := loggerOrDiscard(h.es.cfg().Logger)
+	ticker := time.NewTicker(h.pollInterval)
+	defer ticker.Stop()
+	for {
+		if _, err := h.Poll(ctx); err != nil && ctx.Err() == nil {
+			logger.ErrorContext(ctx, "hot copy failed", "name", h.name, "error", err)
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// Cutover switches the sources to read-only, so writes of the application
+// fail from now on, and copies the remaining records. Afterwards the
+// destination holds all records and the application can switch over. If
+// copying fails, the sources are made writable again.
+func (h *HotCopy) Cutover(ctx context.Context) (*HotCopyReport, error) {
+	restore, err := h.freezeSources()
+	if err != nil {
+		return nil, err
+	}
+	report, err := h.Poll(ctx)
+	if err != nil {
+		return report, errors.Join(err, restore())
+	}
+	return report, nil
+}
+
+// freezeSources switches the sources to read-only and returns a function
+// switching back those which were writable before.
+func (h *HotCopy) freezeSources() (func() error, error) {
+	readOnly := func(readOnly bool) func(opt *comby.EventStoreOptions) (*comby.EventStoreOptions, error) {
+		return func(opt *comby.EventStoreOptions) (*comby.EventStoreOptions, error) {
+			opt.ReadOnly = readOnly
+			return opt, nil
+		}
+	}
+	commandReadOnly := func(readOnly bool) func(opt *comby.CommandStoreOptions) (*comby.CommandStoreOptions, error) {
+		return func(opt *comby.CommandStoreOptions) (*comby.CommandStoreOptions, error) {
+			opt.ReadOnly = readOnly
+			return opt, nil
+		}
+	}
+	var restores []func() error
+	restore := func() error {
+		var errs []error
+		for _, fn := range restores {
+			errs = append(errs, fn())
+		}
+		return errors.Join(errs...)
+	}
+	if !h.es.opts().ReadOnly {
+		if err := h.es.ApplyOptions(readOnly(true)); err != nil {
+			return nil, err
+		}
+		restores = append(restores, func() error { return h.es.ApplyOptions(readOnly(false)) })
+	}
+	if h.cs != nil && !h.cs.opts().ReadOnly {
+		if err := h.cs.ApplyOptions(commandReadOnly(true)); err != nil {
+			return nil, errors.Join(err, restore())
+		}
+		restores = append(restores, func() error { return h.cs.ApplyOptions(commandReadOnly(false)) })
+	}
+	return restore, nil
+}
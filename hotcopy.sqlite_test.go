@@ -0,0 +1,119 @@
+package store_test
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	store "github.com/gradientzero/comby-store-sqlite"
+	"github.com/gradientzero/comby/v3"
+)
+
+func TestHotCopy(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	newStores := func(name string) (store.EventStoreSQLite, store.CommandStoreSQLite) {
+		eventStore := store.NewEventStoreSQLite(filepath.Join(dir, name+"-events.db"))
+		commandStore := store.NewCommandStoreSQLite(filepath.Join(dir, name+"-commands.db"))
+		if err := eventStore.Init(ctx); err != nil {
+			t.Fatal(err)
+		}
+		if err := commandStore.Init(ctx); err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() {
+			eventStore.Close(ctx)
+			commandStore.Close(ctx)
+		})
+		return eventStore, commandStore
+	}
+	srcEvents, srcCommands := newStores("src")
+	dstEvents, dstCommands := newStores("dst")
+	for i := int64(1); i <= 5; i++ {
+		evt := createTestEvent("tenant-1", "domain-1", i, i*100)
+		if err := srcEvents.Create(ctx, comby.EventStoreCreateOptionWithEvent(evt)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i := int64(1); i <= 2; i++ {
+		cmd := createTestCommand("tenant-1", "domain-1", i*100)
+		if err := srcCommands.Create(ctx, comby.CommandStoreCreateOptionWithCommand(cmd)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	hotCopy, err := store.NewHotCopy("postgres", srcEvents, dstEvents,
+		store.HotCopyWithCommands(srcCommands, dstCommands),
+		store.HotCopyWithBatchSize(2),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := hotCopy.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	lag, err := hotCopy.Lag(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lag.Events != 5 || lag.Commands != 2 {
+		t.Fatalf("unexpected lag: %+v", lag)
+	}
+	report, err := hotCopy.Poll(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Events != 5 || report.Commands != 2 || report.Skipped != 0 {
+		t.Fatalf("unexpected report: %+v", report)
+	}
+	if total := dstEvents.Total(ctx); total != 5 {
+		t.Fatalf("expected 5 copied events, got %d", total)
+	}
+
+	// the application writes to both stores, one copy differs
+	evt := createTestEvent("tenant-1", "domain-1", 6, 600)
+	if err := srcEvents.Create(ctx, comby.EventStoreCreateOptionWithEvent(evt)); err != nil {
+		t.Fatal(err)
+	}
+	evt.SetDomainEvtName("Renamed")
+	if err := dstEvents.Create(ctx, comby.EventStoreCreateOptionWithEvent(evt)); err != nil {
+		t.Fatal(err)
+	}
+	// a new instance resumes after the stored offsets
+	verifier, err := store.NewHotCopy("postgres", srcEvents, dstEvents, store.HotCopyWithDualWriteVerification())
+	if err != nil {
+		t.Fatal(err)
+	}
+	report, err = verifier.Poll(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Events != 0 || report.Verified != 1 || len(report.Mismatches) != 1 {
+		t.Fatalf("unexpected report: %+v", report)
+	}
+	if mismatch := report.Mismatches[0]; mismatch.Uuid != evt.GetEventUuid() || mismatch.Field != "data_type" {
+		t.Fatalf("unexpected mismatch: %+v", mismatch)
+	}
+
+	// cutover copies the rest and leaves the sources read-only
+	cmd := createTestCommand("tenant-1", "domain-1", 300)
+	if err := srcCommands.Create(ctx, comby.CommandStoreCreateOptionWithCommand(cmd)); err != nil {
+		t.Fatal(err)
+	}
+	report, err = hotCopy.Cutover(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Commands != 1 {
+		t.Fatalf("unexpected report: %+v", report)
+	}
+	if !srcEvents.Options().ReadOnly || !srcCommands.Options().ReadOnly {
+		t.Fatal("expected read-only sources after cutover")
+	}
+	if err := srcEvents.Create(ctx, comby.EventStoreCreateOptionWithEvent(createTestEvent("tenant-1", "domain-1", 7, 700))); err == nil {
+		t.Fatal("expected writes to the source to fail after cutover")
+	}
+	if lag, err := hotCopy.Lag(ctx); err != nil || lag.Events != 0 || lag.Commands != 0 {
+		t.Fatalf("unexpected lag after cutover: %+v, %v", lag, err)
+	}
+}
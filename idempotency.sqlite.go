@@ -0,0 +1,121 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/gradientzero/comby/v3"
+)
+
+// IdempotencyKeyAttribute is the attribute of the create options or of the
+// request context of a command holding its idempotency key. Keys are unique
+// per tenant, so a command submitted again, e.g. by the HTTP retry of a
+// client, is stored only once. The key is released when the command is deleted.
+const IdempotencyKeyAttribute = "idempotencyKey"
+
+// ErrIdempotencyKeyExists is matched by IdempotencyError.
+var ErrIdempotencyKeyExists = errors.New("idempotency key exists")
+
+// IdempotencyError is returned by Create for a command whose idempotency key
+// was used by a stored command of the same tenant.
+type IdempotencyError struct {
+	Key string
+	// uuid of the stored command
+	CommandUuid string
+}
+
+func (e *IdempotencyError) Error() string {
+	return fmt.Sprintf("%s: '%s' of command '%s'", ErrIdempotencyKeyExists, e.Key, e.CommandUuid)
+}
+
+func (e *IdempotencyError) Is(target error) bool {
+	return target == ErrIdempotencyKeyExists
+}
+
+// CommandStoreCreateOptionIdempotencyKey stores the command with the given
+// idempotency key. It takes precedence over the key of the request context.
+func CommandStoreCreateOptionIdempotencyKey(key string) comby.CommandStoreCreateOption {
+	return func(opt *comby.CommandStoreCreateOptions) (*comby.CommandStoreCreateOptions, error) {
+		if opt.Attributes == nil {
+			opt.Attributes = comby.NewAttributes()
+		}
+		opt.Attributes.Set(IdempotencyKeyAttribute, key)
+		return opt, nil
+	}
+}
+
+// idempotencyKey returns the key of the create options or else of the request
+// context of cmd, empty if there is none.
+func idempotencyKey(createOpts comby.CommandStoreCreateOptions, cmd comby.Command) string {
+	return createAttribute(createOpts, cmd, IdempotencyKeyAttribute)
+}
+
+// createAttribute returns the string attribute of the create options or else
+// of the request context of cmd, empty if there is none.
+func createAttribute(createOpts comby.CommandStoreCreateOptions, cmd comby.Command, name string) string {
+	if createOpts.Attributes != nil {
+		if value, ok := createOpts.Attributes.Get(name).(string); ok && len(value) > 0 {
+			return value
+		}
+	}
+	if reqCtx := cmd.GetReqCtx(); reqCtx != nil && reqCtx.Attributes != nil {
+		if value, ok := reqCtx.Attributes.Get(name).(string); ok {
+			return value
+		}
+	}
+	return ""
+}
+
+// idempotencyConflict returns an IdempotencyError if a command of the tenant
+// holds key, nil if none does.
+func idempotencyConflict(ctx context.Context, q queryer, tenantUuid, key string) error {
+	var commandUuid string
+	query := `SELECT uuid FROM commands WHERE tenant_uuid=? AND idempotency_key=? LIMIT 1;`
+	switch err := q.QueryRowContext(ctx, query, tenantUuid, key).Scan(&commandUuid); {
+	case err == sql.ErrNoRows:
+		return nil
+	case err != nil:
+		return err
+	}
+	return &IdempotencyError{Key: key, CommandUuid: commandUuid}
+}
+
+// CreateIdempotent creates a command like Create, unless its idempotency key
+// was used before. Then the stored command is returned instead and created
+// is false, so a retried request can answer like the first one.
+func (cs *commandStoreSQLite) CreateIdempotent(ctx context.Context, opts ...comby.CommandStoreCreateOption) (cmd comby.Command, created bool, err error) {
+	err = cs.Create(ctx, opts...)
+	var conflict *IdempotencyError
+	if !errors.As(err, &conflict) {
+		if err != nil {
+			return nil, false, err
+		}
+		return optionsOf(opts).Command, true, nil
+	}
+	cmd, err = cs.Get(ctx, comby.CommandStoreGetOptionWithCommandUuid(conflict.CommandUuid))
+	if err != nil {
+		return nil, false, err
+	}
+	if cmd == nil {
+		return nil, false, fmt.Errorf("'%s' failed to create command - command '%s' of idempotency key '%s' was deleted meanwhile", cs.String(), conflict.CommandUuid, conflict.Key)
+	}
+	return cmd, false, nil
+}
+
+// GetByIdempotencyKey returns the command of the tenant stored with key, nil
+// if there is none.
+func (cs *commandStoreSQLite) GetByIdempotencyKey(ctx context.Context, tenantUuid, key string) (comby.Command, error) {
+	if len(key) == 0 {
+		return nil, &OptionsError{Problems: []string{"idempotency key is required"}}
+	}
+	if err := checkTenant(cs.cfg().Tenant, tenantUuid); err != nil {
+		return nil, fmt.Errorf("'%s' failed to get command - %w", cs.String(), err)
+	}
+	var conflict *IdempotencyError
+	if err := idempotencyConflict(ctx, cs.db, tenantUuid, key); !errors.As(err, &conflict) {
+		return nil, classifyError(err)
+	}
+	return cs.Get(ctx, comby.CommandStoreGetOptionWithCommandUuid(conflict.CommandUuid))
+}
@@ -0,0 +1,112 @@
+package store_test
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	store "github.com/gradientzero/comby-store-sqlite"
+	"github.com/gradientzero/comby/v3"
+)
+
+func TestCommandStoreIdempotencyKey(t *testing.T) {
+	ctx := context.Background()
+	commandStore := store.NewCommandStoreSQLite(filepath.Join(t.TempDir(), "commands.db"))
+	if err := commandStore.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer commandStore.Close(ctx)
+
+	first := createTestCommand("tenant-1", "domain-1", 100)
+	cmd, created, err := commandStore.CreateIdempotent(ctx,
+		comby.CommandStoreCreateOptionWithCommand(first),
+		store.CommandStoreCreateOptionIdempotencyKey("request-1"),
+	)
+	if err != nil || !created || cmd.GetCommandUuid() != first.GetCommandUuid() {
+		t.Fatalf("expected the command to be created, got %v %t %v", cmd, created, err)
+	}
+
+	// a retry with a new command uuid returns the stored command
+	retry := createTestCommand("tenant-1", "domain-1", 200)
+	cmd, created, err = commandStore.CreateIdempotent(ctx,
+		comby.CommandStoreCreateOptionWithCommand(retry),
+		store.CommandStoreCreateOptionIdempotencyKey("request-1"),
+	)
+	if err != nil || created || cmd.GetCommandUuid() != first.GetCommandUuid() {
+		t.Fatalf("expected the stored command, got %v %t %v", cmd, created, err)
+	}
+
+	// Create rejects the key
+	err = commandStore.Create(ctx,
+		comby.CommandStoreCreateOptionWithCommand(retry),
+		store.CommandStoreCreateOptionIdempotencyKey("request-1"),
+	)
+	var idempotencyErr *store.IdempotencyError
+	if !errors.As(err, &idempotencyErr) || !errors.Is(err, store.ErrIdempotencyKeyExists) || idempotencyErr.CommandUuid != first.GetCommandUuid() {
+		t.Fatalf("expected idempotency error, got %v", err)
+	}
+	if total := commandStore.Total(ctx); total != 1 {
+		t.Fatalf("expected 1 command, got %d", total)
+	}
+
+	// keys are unique per tenant
+	other := createTestCommand("tenant-2", "domain-1", 300)
+	if err := commandStore.Create(ctx,
+		comby.CommandStoreCreateOptionWithCommand(other),
+		store.CommandStoreCreateOptionIdempotencyKey("request-1"),
+	); err != nil {
+		t.Fatal(err)
+	}
+	if cmd, err := commandStore.GetByIdempotencyKey(ctx, "tenant-2", "request-1"); err != nil || cmd == nil || cmd.GetCommandUuid() != other.GetCommandUuid() {
+		t.Fatalf("expected the command of tenant-2, got %v %v", cmd, err)
+	}
+	if cmd, err := commandStore.GetByIdempotencyKey(ctx, "tenant-1", "request-2"); err != nil || cmd != nil {
+		t.Fatalf("expected no command, got %v %v", cmd, err)
+	}
+
+	// deleting the command releases the key
+	if err := commandStore.Delete(ctx, comby.CommandStoreDeleteOptionWithCommandUuid(first.GetCommandUuid())); err != nil {
+		t.Fatal(err)
+	}
+	if _, created, err := commandStore.CreateIdempotent(ctx,
+		comby.CommandStoreCreateOptionWithCommand(retry),
+		store.CommandStoreCreateOptionIdempotencyKey("request-1"),
+	); err != nil || !created {
+		t.Fatalf("expected the command to be created, got %t %v", created, err)
+	}
+}
+
+func TestCommandStoreIdempotencyKeyFromRequestContext(t *testing.T) {
+	ctx := context.Background()
+	commandStore := store.NewCommandStoreSQLite(filepath.Join(t.TempDir(), "commands.db"))
+	if err := commandStore.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer commandStore.Close(ctx)
+
+	withKey := func(cmd comby.Command, key string) comby.Command {
+		reqCtx := &comby.RequestContext{Attributes: comby.NewAttributes()}
+		reqCtx.Attributes.Set(store.IdempotencyKeyAttribute, key)
+		cmd.SetReqCtx(reqCtx)
+		return cmd
+	}
+	first := withKey(createTestCommand("tenant-1", "domain-1", 100), "request-1")
+	if err := commandStore.Create(ctx, comby.CommandStoreCreateOptionWithCommand(first)); err != nil {
+		t.Fatal(err)
+	}
+	retry := withKey(createTestCommand("tenant-1", "domain-1", 200), "request-1")
+	if err := commandStore.Create(ctx, comby.CommandStoreCreateOptionWithCommand(retry)); !errors.Is(err, store.ErrIdempotencyKeyExists) {
+		t.Fatalf("expected idempotency error, got %v", err)
+	}
+	// the option takes precedence
+	if err := commandStore.Create(ctx,
+		comby.CommandStoreCreateOptionWithCommand(retry),
+		store.CommandStoreCreateOptionIdempotencyKey("request-2"),
+	); err != nil {
+		t.Fatal(err)
+	}
+	if cmd, err := commandStore.GetByIdempotencyKey(ctx, "tenant-1", "request-2"); err != nil || cmd == nil || cmd.GetCommandUuid() != retry.GetCommandUuid() {
+		t.Fatalf("expected the retried command, got %v %v", cmd, err)
+	}
+}
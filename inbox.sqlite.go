@@ -0,0 +1,141 @@
+package store
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/gradientzero/comby/v3"
+)
+
+// ErrInboxPayloadMismatch is returned by Inbox.Record if a known message id
+// arrives with a different payload, which usually indicates a reused id.
+var ErrInboxPayloadMismatch = errors.New("message id reused with different payload")
+
+type InboxOption func(*Inbox)
+
+// InboxWithTTL sets how long message ids are remembered. Redeliveries after
+// the TTL are recorded as new messages, 0 remembers ids until deleted.
+func InboxWithTTL(ttl time.Duration) InboxOption {
+	return func(i *Inbox) { i.ttl = ttl }
+}
+
+// Inbox deduplicates incoming messages (e.g. from a broker with at-least-once
+// delivery) before they are dispatched as commands. Message ids are recorded in
+// the inbox_messages table of the command store.
+type Inbox struct {
+	cs  *commandStoreSQLite
+	ttl time.Duration
+}
+
+func NewInbox(commandStore comby.CommandStore, opts ...InboxOption) (*Inbox, error) {
+	cs, ok := commandStore.(*commandStoreSQLite)
+	if !ok {
+		return nil, fmt.Errorf("inbox requires a sqlite command store")
+	}
+	i := &Inbox{
+		cs:  cs,
+		ttl: 7 * 24 * time.Hour,
+	}
+	for _, opt := range opts {
+		opt(i)
+	}
+	if i.ttl < 0 {
+		return nil, fmt.Errorf("'%s' failed to create inbox - invalid ttl", cs.String())
+	}
+	return i, nil
+}
+
+var inboxTables = []strictTable{
+	{
+		name: "inbox_messages",
+		columns: `message_id TEXT NOT NULL PRIMARY KEY,
+		first_seen INTEGER NOT NULL,
+		payload_hash TEXT NOT NULL,
+		expires_at INTEGER NOT NULL`,
+		copyColumns: `message_id, first_seen, payload_hash, expires_at`,
+	},
+}
+
+// Init creates the inbox table. The command store must be initialized before.
+func (i *Inbox) Init(ctx context.Context) error {
+	if i.cs.db == nil {
+		return fmt.Errorf("'%s' failed to init inbox - command store is not initialized", i.cs.String())
+	}
+	return migrateTx(ctx, i.cs.db, func(tx *sql.Tx) error {
+		if err := migrateStrictTables(ctx, tx, loggerOrDiscard(i.cs.cfg().Logger), inboxTables...); err != nil {
+			return err
+		}
+		_, err := tx.ExecContext(ctx, `CREATE INDEX IF NOT EXISTS "inbox_messages_expires_at_index" ON "inbox_messages" ("expires_at" ASC);`)
+		return err
+	})
+}
+
+// Record checks and records a message id in one step. It returns true if the
+// message is new and should be dispatched, false if it was seen before.
+func (i *Inbox) Record(ctx context.Context, messageId string, payload []byte) (bool, error) {
+	if i.cs.opts().ReadOnly {
+		return false, fmt.Errorf("'%s' failed to record message - instance is readonly", i.cs.String())
+	}
+	if len(messageId) == 0 {
+		return false, fmt.Errorf("'%s' failed to record message - message id is required", i.cs.String())
+	}
+	sum := sha256.Sum256(payload)
+	hash := hex.EncodeToString(sum[:])
+	now := time.Now().UnixNano()
+	var expiresAt int64
+	if i.ttl > 0 {
+		expiresAt = now + i.ttl.Nanoseconds()
+	}
+
+	// the inbox is bookkeeping of the adapter, it bypasses the write limits
+	i.cs.writeMu.Lock()
+	defer i.cs.writeMu.Unlock()
+
+	// an expired entry is replaced as if the message was new
+	query := `INSERT INTO inbox_messages (message_id, first_seen, payload_hash, expires_at) VALUES (?, ?, ?, ?)
+		ON CONFLICT(message_id) DO UPDATE SET first_seen=excluded.first_seen, payload_hash=excluded.payload_hash,
+		expires_at=excluded.expires_at WHERE inbox_messages.expires_at > 0 AND inbox_messages.expires_at <= ?;`
+	res, err := i.cs.db.ExecContext(ctx, query, messageId, now, hash, expiresAt, now)
+	if err != nil {
+		return false, classifyError(err)
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return false, err
+	} else if n > 0 {
+		return true, nil
+	}
+
+	var knownHash string
+	if err := i.cs.db.QueryRowContext(ctx, "SELECT payload_hash FROM inbox_messages WHERE message_id=?;", messageId).Scan(&knownHash); err != nil {
+		return false, classifyError(err)
+	}
+	if knownHash != hash {
+		return false, fmt.Errorf("'%s' failed to record message '%s' - %w", i.cs.String(), messageId, ErrInboxPayloadMismatch)
+	}
+	return false, nil
+}
+
+// Forget deletes a message id, e.g. if dispatching the recorded message failed
+// and a redelivery should be processed.
+func (i *Inbox) Forget(ctx context.Context, messageId string) error {
+	i.cs.writeMu.Lock()
+	defer i.cs.writeMu.Unlock()
+	_, err := i.cs.db.ExecContext(ctx, "DELETE FROM inbox_messages WHERE message_id=?;", messageId)
+	return classifyError(err)
+}
+
+// Prune deletes expired message ids and returns their number.
+func (i *Inbox) Prune(ctx context.Context) (int64, error) {
+	i.cs.writeMu.Lock()
+	defer i.cs.writeMu.Unlock()
+	res, err := i.cs.db.ExecContext(ctx, "DELETE FROM inbox_messages WHERE expires_at > 0 AND expires_at <= ?;", time.Now().UnixNano())
+	if err != nil {
+		return 0, classifyError(err)
+	}
+	return res.RowsAffected()
+}
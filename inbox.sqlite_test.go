@@ -0,0 +1,97 @@
+package store_test
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	store "github.com/gradientzero/comby-store-sqlite"
+)
+
+func TestInbox(t *testing.T) {
+	ctx := context.Background()
+	commandStore := store.NewCommandStoreSQLite(filepath.Join(t.TempDir(), "commandStore-inbox.db"))
+	if err := commandStore.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer commandStore.Close(ctx)
+
+	inbox, err := store.NewInbox(commandStore)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := inbox.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// concurrent redeliveries, exactly one is new
+	var fresh atomic.Int64
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ok, err := inbox.Record(ctx, "msg-1", []byte("payload"))
+			if err != nil {
+				t.Error(err)
+			}
+			if ok {
+				fresh.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+	if fresh.Load() != 1 {
+		t.Fatalf("expected one new message, got %d", fresh.Load())
+	}
+	if _, err := inbox.Record(ctx, "msg-1", []byte("other")); !errors.Is(err, store.ErrInboxPayloadMismatch) {
+		t.Fatalf("expected payload mismatch, got %v", err)
+	}
+
+	// forgotten ids are recorded again
+	if err := inbox.Forget(ctx, "msg-1"); err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := inbox.Record(ctx, "msg-1", []byte("payload")); err != nil || !ok {
+		t.Fatalf("expected forgotten message to be new: %v %v", ok, err)
+	}
+}
+
+func TestInboxTTL(t *testing.T) {
+	ctx := context.Background()
+	commandStore := store.NewCommandStoreSQLite(filepath.Join(t.TempDir(), "commandStore-inbox-ttl.db"))
+	if err := commandStore.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer commandStore.Close(ctx)
+
+	inbox, err := store.NewInbox(commandStore, store.InboxWithTTL(50*time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := inbox.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []string{"msg-1", "msg-2"} {
+		if _, err := inbox.Record(ctx, id, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	// an expired id counts as new, the other one is pruned
+	if ok, err := inbox.Record(ctx, "msg-1", nil); err != nil || !ok {
+		t.Fatalf("expected expired message to be new: %v %v", ok, err)
+	}
+	pruned, err := inbox.Prune(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pruned != 1 {
+		t.Fatalf("expected 1 pruned message, got %d", pruned)
+	}
+}
@@ -0,0 +1,85 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+
+	"github.com/gradientzero/comby-store-sqlite/internal"
+)
+
+// Encryption modes of a stored payload as reported by Inspect.
+const (
+	EncryptionNone   = "none"
+	EncryptionFull   = "full"
+	EncryptionFields = "fields"
+)
+
+// EventInspection is a complete picture of a single stored event for support tooling.
+type EventInspection struct {
+	// position of the event in the store
+	Seq int64
+	// the database record as JSON, with the payload as stored (base64)
+	Record json.RawMessage
+	// decoded payload, indented if it is JSON
+	Payload string
+	// set instead of Payload if the payload can not be decoded
+	DecodeError string
+	Encryption  string
+	// bytes of the payload as stored and decoded
+	StoredSize  int
+	PayloadSize int
+	Checksum    string
+	// whether the decoded payload matches its checksum, false if it has none
+	ChecksumValid bool
+}
+
+// Inspect returns the stored record of an event together with its decoded
+// payload, nil if the event does not exist. Decoding failures are reported in
+// the inspection instead of failing, so broken records can be examined.
+func (es *eventStoreSQLite) Inspect(ctx context.Context, eventUuid string) (*EventInspection, error) {
+	query := fmt.Sprintf("SELECT %s FROM events WHERE uuid=? LIMIT 1;", eventSelectColumns)
+	var dbRecord internal.Event
+	err := scanEvent(es.db.QueryRowContext(ctx, query, eventUuid), &dbRecord)
+	switch {
+	case err == sql.ErrNoRows:
+		return nil, nil
+	case err != nil:
+		return nil, classifyError(err)
+	}
+
+	record, err := json.Marshal(&dbRecord)
+	if err != nil {
+		return nil, err
+	}
+	inspection := &EventInspection{
+		Seq:        dbRecord.ID.Int64,
+		Record:     record,
+		Encryption: EncryptionNone,
+		StoredSize: len(dbRecord.DataBytes),
+		Checksum:   dbRecord.Checksum,
+	}
+	if isEncrypted(dbRecord.IsEncrypted, es.cipher() != nil) {
+		inspection.Encryption = EncryptionFull
+		if paths := es.cfg().FieldEncryption[dbRecord.DataType]; len(paths) > 0 && json.Valid(dbRecord.DataBytes) {
+			inspection.Encryption = EncryptionFields
+		}
+		if err := es.decryptDomainData(ctx, &dbRecord); err != nil {
+			inspection.DecodeError = err.Error()
+			return inspection, nil
+		}
+	}
+
+	payload := dbRecord.DataBytes
+	inspection.PayloadSize = len(payload)
+	inspection.ChecksumValid = len(dbRecord.Checksum) > 0 && es.verifyChecksum(&dbRecord) == nil
+	var indented bytes.Buffer
+	if json.Indent(&indented, payload, "", "  ") == nil {
+		inspection.Payload = indented.String()
+	} else {
+		inspection.Payload = string(payload)
+	}
+	return inspection, nil
+}
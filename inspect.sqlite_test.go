@@ -0,0 +1,61 @@
+package store_test
+
+import (
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	store "github.com/gradientzero/comby-store-sqlite"
+	"github.com/gradientzero/comby/v3"
+)
+
+func TestEventStoreInspect(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "eventStore-inspect.db")
+	cryptoService, _ := comby.NewCryptoService([]byte("12345678901234567890123456789012"))
+	eventStore := store.NewEventStoreSQLite(path)
+	if err := eventStore.Init(ctx, comby.EventStoreOptionWithCryptoService(cryptoService)); err != nil {
+		t.Fatal(err)
+	}
+	defer eventStore.Close(ctx)
+
+	evt := createTestEvent("tenant-1", "domain-1", 1, 100)
+	evt.SetDomainEvtBytes([]byte(`{"name":"test","count":1}`))
+	if err := eventStore.Create(ctx, comby.EventStoreCreateOptionWithEvent(evt)); err != nil {
+		t.Fatal(err)
+	}
+
+	inspection, err := eventStore.Inspect(ctx, evt.GetEventUuid())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if inspection.Encryption != store.EncryptionFull || !inspection.ChecksumValid || len(inspection.DecodeError) > 0 {
+		t.Fatalf("unexpected inspection: %+v", inspection)
+	}
+	if inspection.Payload != "{\n  \"name\": \"test\",\n  \"count\": 1\n}" || inspection.PayloadSize != 25 || inspection.StoredSize <= inspection.PayloadSize {
+		t.Fatalf("unexpected payload: %q (%d of %d bytes)", inspection.Payload, inspection.PayloadSize, inspection.StoredSize)
+	}
+	var record map[string]any
+	if err := json.Unmarshal(inspection.Record, &record); err != nil {
+		t.Fatal(err)
+	}
+	if record["uuid"] != evt.GetEventUuid() || strings.Contains(record["data_bytes"].(string), "test") {
+		t.Fatalf("unexpected record: %v", record)
+	}
+
+	// a store without the key still shows the record
+	reader := store.NewEventStoreSQLite(path)
+	wrongKey, _ := comby.NewCryptoService([]byte("abcdefghijklmnopqrstuvwxyz012345"))
+	if err := reader.Init(ctx, comby.EventStoreOptionWithCryptoService(wrongKey)); err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Close(ctx)
+	if inspection, err = reader.Inspect(ctx, evt.GetEventUuid()); err != nil || len(inspection.DecodeError) == 0 {
+		t.Fatalf("expected decode error: %+v %v", inspection, err)
+	}
+	if inspection, err = reader.Inspect(ctx, "missing"); err != nil || inspection != nil {
+		t.Fatalf("expected no inspection for unknown event: %+v %v", inspection, err)
+	}
+}
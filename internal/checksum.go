@@ -0,0 +1,35 @@
+package internal
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash/crc32"
+	"strings"
+)
+
+// Checksum returns the checksum of dataBytes prefixed by its algorithm, e.g. "sha256:<hex>".
+func Checksum(algorithm string, dataBytes []byte) (string, error) {
+	switch algorithm {
+	case "sha256", "":
+		sum := sha256.Sum256(dataBytes)
+		return "sha256:" + hex.EncodeToString(sum[:]), nil
+	case "crc32":
+		return fmt.Sprintf("crc32:%08x", crc32.ChecksumIEEE(dataBytes)), nil
+	default:
+		return "", fmt.Errorf("unknown checksum algorithm '%s'", algorithm)
+	}
+}
+
+// VerifyChecksum reports whether dataBytes matches a checksum created by Checksum.
+func VerifyChecksum(checksum string, dataBytes []byte) (bool, error) {
+	algorithm, _, ok := strings.Cut(checksum, ":")
+	if !ok {
+		return false, fmt.Errorf("invalid checksum '%s'", checksum)
+	}
+	expected, err := Checksum(algorithm, dataBytes)
+	if err != nil {
+		return false, err
+	}
+	return expected == checksum, nil
+}
@@ -0,0 +1,135 @@
+package internal
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"strings"
+)
+
+// encrypted field values are stored as JSON strings with this prefix
+const encryptedFieldPrefix = "enc:"
+
+// ErrNotJSONObject is returned for payloads which are not a JSON object, e.g.
+// payloads encrypted as a whole before field encryption was enabled.
+var ErrNotJSONObject = errors.New("payload is not a json object")
+
+// EncryptFields replaces the values at the given dot separated paths of a JSON
+// object with the hex encoded result of fn applied to their raw JSON. Missing
+// paths are skipped, all other bytes are kept as they are.
+func EncryptFields(dataBytes []byte, paths []string, fn func([]byte) ([]byte, error)) ([]byte, error) {
+	return transformFields(dataBytes, paths, func(raw []byte) ([]byte, error) {
+		encrypted, err := fn(raw)
+		if err != nil {
+			return nil, err
+		}
+		return json.Marshal(encryptedFieldPrefix + hex.EncodeToString(encrypted))
+	})
+}
+
+// DecryptFields reverts EncryptFields. Values which are not encrypted, or do
+// not decrypt (plaintext which happens to start with the prefix), are left untouched.
+func DecryptFields(dataBytes []byte, paths []string, fn func([]byte) ([]byte, error)) ([]byte, error) {
+	return transformFields(dataBytes, paths, func(raw []byte) ([]byte, error) {
+		var value string
+		if err := json.Unmarshal(raw, &value); err != nil || !strings.HasPrefix(value, encryptedFieldPrefix) {
+			return raw, nil
+		}
+		encrypted, err := hex.DecodeString(strings.TrimPrefix(value, encryptedFieldPrefix))
+		if err != nil {
+			return raw, nil
+		}
+		decrypted, err := fn(encrypted)
+		if err != nil {
+			return raw, nil
+		}
+		return decrypted, nil
+	})
+}
+
+// PseudonymizeFields replaces the values at the given paths with the JSON
+// string fn returns for them. Strings are passed unquoted, other values as raw
+// JSON. Missing paths and null values are skipped.
+func PseudonymizeFields(dataBytes []byte, paths []string, fn func(value string) string) ([]byte, error) {
+	return transformFields(dataBytes, paths, func(raw []byte) ([]byte, error) {
+		if string(raw) == "null" {
+			return raw, nil
+		}
+		value := string(raw)
+		if raw[0] == '"' {
+			if err := json.Unmarshal(raw, &value); err != nil {
+				return nil, err
+			}
+		}
+		return json.Marshal(fn(value))
+	})
+}
+
+// transformFields replaces the values at the given paths in place, so that
+// key order, whitespace and escaping of the remaining payload are preserved.
+func transformFields(dataBytes []byte, paths []string, fn func([]byte) ([]byte, error)) ([]byte, error) {
+	if trimmed := bytes.TrimLeft(dataBytes, " \t\r\n"); len(trimmed) == 0 || trimmed[0] != '{' || !json.Valid(dataBytes) {
+		return nil, ErrNotJSONObject
+	}
+	for _, path := range paths {
+		start, end, ok, err := fieldSpan(dataBytes, strings.Split(path, "."))
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			continue
+		}
+		value, err := fn(dataBytes[start:end])
+		if err != nil {
+			return nil, err
+		}
+		replaced := make([]byte, 0, len(dataBytes)-(end-start)+len(value))
+		replaced = append(replaced, dataBytes[:start]...)
+		replaced = append(replaced, value...)
+		replaced = append(replaced, dataBytes[end:]...)
+		dataBytes = replaced
+	}
+	return dataBytes, nil
+}
+
+// fieldSpan returns the byte range of the value at keys within the JSON object data.
+func fieldSpan(data []byte, keys []string) (int, int, bool, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	tok, err := dec.Token()
+	if err != nil {
+		return 0, 0, false, err
+	}
+	if tok != json.Delim('{') {
+		return 0, 0, false, ErrNotJSONObject
+	}
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return 0, 0, false, err
+		}
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err != nil {
+			return 0, 0, false, err
+		}
+		if tok != keys[0] {
+			continue
+		}
+		end := int(dec.InputOffset())
+		start := end - len(raw)
+		if len(keys) == 1 {
+			return start, end, true, nil
+		}
+
+		// descend into nested object, anything else can not contain the path
+		childStart, childEnd, ok, err := fieldSpan(raw, keys[1:])
+		if errors.Is(err, ErrNotJSONObject) {
+			return 0, 0, false, nil
+		}
+		if err != nil || !ok {
+			return 0, 0, false, err
+		}
+		return start + childStart, start + childEnd, true, nil
+	}
+	return 0, 0, false, nil
+}
@@ -2,11 +2,13 @@ package internal
 
 import (
 	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
 )
 
 type Command struct {
 	// system fields
-	ID sql.NullInt64
+	ID sql.NullInt64 `json:"-"`
 
 	// fields
 	InstanceId    int64  `json:"instance_id"`
@@ -16,13 +18,16 @@ type Command struct {
 	Domain        string `json:"domain"`
 	CreatedAt     int64  `json:"created_at"`
 	DataType      string `json:"data_type"`
-	DataBytes     string `json:"data_bytes"`
+	DataBytes     []byte `json:"data_bytes"`
 	ReqCtx        string `json:"req_ctx"`
+	Checksum      string `json:"checksum"`
+	// whether DataBytes is encrypted, NULL for records written before this was tracked
+	IsEncrypted NullBool `json:"is_encrypted"`
 }
 
 type Event struct {
 	// system fields
-	ID sql.NullInt64
+	ID sql.NullInt64 `json:"-"`
 
 	// fields
 	InstanceId    int64  `json:"instance_id"`
@@ -35,6 +40,40 @@ type Event struct {
 	Version       int64  `json:"version"`
 	CreatedAt     int64  `json:"created_at"`
 	DataType      string `json:"data_type"`
-	DataBytes     string `json:"data_bytes"`
+	DataBytes     []byte `json:"data_bytes"`
 	ReqCtx        string `json:"req_ctx"`
+	Checksum      string `json:"checksum"`
+	// whether DataBytes is encrypted, NULL for records written before this was tracked
+	IsEncrypted NullBool `json:"is_encrypted"`
+}
+
+// NullBool is a sql.NullBool which is encoded as true, false or null in JSON,
+// so records written to archive segments or bundles keep their state.
+type NullBool sql.NullBool
+
+func (n *NullBool) Scan(value any) error {
+	return (*sql.NullBool)(n).Scan(value)
+}
+
+func (n NullBool) Value() (driver.Value, error) {
+	return sql.NullBool(n).Value()
+}
+
+func (n NullBool) MarshalJSON() ([]byte, error) {
+	if !n.Valid {
+		return []byte("null"), nil
+	}
+	return json.Marshal(n.Bool)
+}
+
+func (n *NullBool) UnmarshalJSON(data []byte) error {
+	var value *bool
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+	*n = NullBool{}
+	if value != nil {
+		*n = NullBool{Bool: *value, Valid: true}
+	}
+	return nil
 }
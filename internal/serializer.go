@@ -58,8 +58,9 @@ func BaseEventToDbEvent(evt comby.Event) (*Event, error) {
 		Version:       evt.GetVersion(),
 		CreatedAt:     evt.GetCreatedAt(),
 		DataType:      dataType,
-		DataBytes:     string(evtDataBytes),
+		DataBytes:     evtDataBytes,
 		ReqCtx:        reqCtxStr,
+		IsEncrypted:   NullBool{Valid: true},
 	}
 	return dbEvent, nil
 }
@@ -86,7 +87,7 @@ func DbEventToBaseEvent(dbEvent *Event) (comby.Event, error) {
 		AggregateUuid:  dbEvent.AggregateUuid,
 		Version:        dbEvent.Version,
 		DomainEvtName:  dbEvent.DataType,
-		DomainEvtBytes: []byte(dbEvent.DataBytes),
+		DomainEvtBytes: dbEvent.DataBytes,
 		DomainEvt:      nil,
 		CreatedAt:      dbEvent.CreatedAt,
 		ReqCtx:         reqCtx,
@@ -137,8 +138,9 @@ func BaseCommandToDbCommand(cmd comby.Command) (*Command, error) {
 		Domain:        cmd.GetDomain(),
 		CreatedAt:     cmd.GetCreatedAt(),
 		DataType:      dataType,
-		DataBytes:     string(cmdDataBytes),
+		DataBytes:     cmdDataBytes,
 		ReqCtx:        string(reqCtxBytes),
+		IsEncrypted:   NullBool{Valid: true},
 	}
 	return dbCmd, nil
 }
@@ -162,7 +164,7 @@ func DbCommandToBaseCommand(dbCmd *Command) (comby.Command, error) {
 		WorkspaceUuid:  dbCmd.WorkspaceUuid,
 		Domain:         dbCmd.Domain,
 		DomainCmdName:  dbCmd.DataType,
-		DomainCmdBytes: []byte(dbCmd.DataBytes),
+		DomainCmdBytes: dbCmd.DataBytes,
 		DomainCmd:      nil,
 		CreatedAt:      dbCmd.CreatedAt,
 		ReqCtx:         &reqCtx,
@@ -0,0 +1,119 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+)
+
+// A connection keeps reading the file it opened, even after the path was
+// replaced, e.g. by restoring a file system snapshot or remounting a volume
+// after a container restart. The keepalive validates the pool periodically
+// and drops its idle connections once the file changed or a connection
+// failed, so the next operation reconnects to the current file.
+
+// keepaliveQuery touches the first page of the database on a pooled connection.
+const keepaliveQuery = "SELECT COUNT(*) FROM sqlite_master;"
+
+// EventStoreSQLiteWithKeepalive validates the connections of the store every
+// interval and reconnects after the database file was replaced.
+func EventStoreSQLiteWithKeepalive(interval time.Duration) EventStoreSQLiteOption {
+	return func(c *eventStoreSQLiteConfig) { c.Keepalive = interval }
+}
+
+// CommandStoreSQLiteWithKeepalive validates the connections of the store every
+// interval, see EventStoreSQLiteWithKeepalive.
+func CommandStoreSQLiteWithKeepalive(interval time.Duration) CommandStoreSQLiteOption {
+	return func(c *commandStoreSQLiteConfig) { c.Keepalive = interval }
+}
+
+// SnapshotStoreSQLiteWithKeepalive validates the connections of the store
+// every interval, see EventStoreSQLiteWithKeepalive.
+func SnapshotStoreSQLiteWithKeepalive(interval time.Duration) SnapshotStoreSQLiteOption {
+	return func(c *snapshotStoreSQLiteConfig) { c.Keepalive = interval }
+}
+
+// WithKeepalive validates the connections of the shared pool every interval.
+// Keepalives of the single stores are ignored, as they do not own the pool.
+func WithKeepalive(interval time.Duration) OpenOption {
+	return func(c *openConfig) { c.Keepalive = interval }
+}
+
+// databaseFile returns the file of the database at path, empty for in-memory databases.
+func databaseFile(path string) string {
+	file, query, _ := strings.Cut(strings.TrimPrefix(path, "file:"), "?")
+	if len(file) == 0 || file == ":memory:" || strings.Contains(query, "mode=memory") {
+		return ""
+	}
+	return file
+}
+
+// startKeepalive (re)starts the keepalive of db. Idle connections are dropped
+// by lowering the idle limit to zero and restoring the one of maxIdleConns,
+// which may change after Init.
+func startKeepalive(db *sql.DB, path string, interval time.Duration, maxIdleConns func() int, logger *slog.Logger, loop *maintenanceLoop) *maintenanceLoop {
+	loop.stop()
+	if interval <= 0 {
+		return nil
+	}
+	file := databaseFile(path)
+	var opened os.FileInfo
+	if len(file) > 0 {
+		opened, _ = os.Stat(file)
+	}
+	return startMaintenance(interval, logger, "keepalive", func(ctx context.Context) error {
+		var reason string
+		if err := db.QueryRowContext(ctx, keepaliveQuery).Scan(new(int64)); err != nil {
+			if ctx.Err() != nil {
+				return err
+			}
+			reason = err.Error()
+		} else if len(file) > 0 {
+			// a missing file is left to the next query, it may be replaced right now
+			if info, err := os.Stat(file); err == nil {
+				if opened != nil && !os.SameFile(opened, info) {
+					reason = "database file was replaced"
+				}
+				opened = info
+			}
+		}
+		if len(reason) == 0 {
+			return nil
+		}
+		logger.WarnContext(ctx, "reconnecting to database", "path", path, "reason", reason)
+		db.SetMaxIdleConns(0)
+		if n := maxIdleConns(); n > 0 {
+			db.SetMaxIdleConns(n)
+		} else {
+			// the database/sql default
+			db.SetMaxIdleConns(2)
+		}
+		return classifyError(db.QueryRowContext(ctx, keepaliveQuery).Scan(new(int64)))
+	})
+}
+
+func (es *eventStoreSQLite) initKeepalive() {
+	if es.shared {
+		return
+	}
+	config := es.cfg()
+	es.keepalive = startKeepalive(es.db, es.path, config.Keepalive, func() int { return es.opts().MaxIdleConns }, loggerOrDiscard(config.Logger), es.keepalive)
+}
+
+func (cs *commandStoreSQLite) initKeepalive() {
+	if cs.shared {
+		return
+	}
+	config := cs.cfg()
+	cs.keepalive = startKeepalive(cs.db, cs.path, config.Keepalive, func() int { return cs.opts().MaxIdleConns }, loggerOrDiscard(config.Logger), cs.keepalive)
+}
+
+func (s *snapshotStoreSQLite) initKeepalive() {
+	if s.shared {
+		return
+	}
+	s.keepalive = startKeepalive(s.db, s.path, s.config.Keepalive, func() int { return s.config.MaxIdleConns }, loggerOrDiscard(s.config.Logger), s.keepalive)
+}
@@ -0,0 +1,115 @@
+package store_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	store "github.com/gradientzero/comby-store-sqlite"
+	"github.com/gradientzero/comby/v3"
+)
+
+func TestEventStoreKeepaliveReplacedFile(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	path := filepath.Join(dir, "events.db")
+
+	eventStore := store.NewEventStoreSQLite(path)
+	eventStore.Configure(store.EventStoreSQLiteWithKeepalive(10 * time.Millisecond))
+	if err := eventStore.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer eventStore.Close(ctx)
+	before := createTestEvent("tenant-1", "domain-1", 1, 100)
+	if err := eventStore.Create(ctx, comby.EventStoreCreateOptionWithEvent(before)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := eventStore.Checkpoint(ctx, store.CheckpointTruncate); err != nil {
+		t.Fatal(err)
+	}
+
+	// restore another database over the file, like a file system snapshot
+	restored := store.NewEventStoreSQLite(filepath.Join(dir, "restored.db"))
+	if err := restored.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	after := createTestEvent("tenant-1", "domain-1", 1, 200)
+	if err := restored.Create(ctx, comby.EventStoreCreateOptionWithEvent(after)); err != nil {
+		t.Fatal(err)
+	}
+	if err := restored.Close(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(filepath.Join(dir, "restored.db"), path); err != nil {
+		t.Fatal(err)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		evt, err := eventStore.Get(ctx, comby.EventStoreGetOptionWithEventUuid(after.GetEventUuid()))
+		if err == nil && evt != nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected the restored event after the keepalive, got %v", err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if evt, _ := eventStore.Get(ctx, comby.EventStoreGetOptionWithEventUuid(before.GetEventUuid())); evt != nil {
+		t.Fatal("expected the replaced event to be gone")
+	}
+}
+
+func TestOpenConnectionOptions(t *testing.T) {
+	ctx := context.Background()
+	stores, err := store.Open(filepath.Join(t.TempDir(), "store.db"),
+		store.WithEventStore(),
+		store.WithSnapshotStore(),
+		store.WithMaxIdleConns(1),
+		store.WithConnMaxIdleTime(time.Second),
+		store.WithConnMaxLifetime(20*time.Millisecond),
+		store.WithKeepalive(5*time.Millisecond),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := stores.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	for i := range 5 {
+		evt := createTestEvent("tenant-1", "domain-1", int64(i+1), int64(100+i))
+		if err := stores.EventStore.Create(ctx, comby.EventStoreCreateOptionWithEvent(evt)); err != nil {
+			t.Fatal(err)
+		}
+		// connections expire in between
+		time.Sleep(10 * time.Millisecond)
+	}
+	if total := stores.EventStore.Total(ctx); total != 5 {
+		t.Fatalf("expected 5 events, got %d", total)
+	}
+	if err := stores.Close(ctx); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSnapshotStoreConnectionOptions(t *testing.T) {
+	ctx := context.Background()
+	snapshotStore := store.NewSnapshotStoreSQLite(filepath.Join(t.TempDir(), "snapshots.db"),
+		store.SnapshotStoreSQLiteWithMaxIdleConns(1),
+		store.SnapshotStoreSQLiteWithConnMaxLifetime(20*time.Millisecond),
+		store.SnapshotStoreSQLiteWithKeepalive(5*time.Millisecond),
+	)
+	if err := snapshotStore.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(30 * time.Millisecond)
+	model := &comby.SnapshotStoreModel{AggregateUuid: "aggregate-1", Domain: "domain-1", Version: 1, Data: []byte("snapshot"), CreatedAt: 100}
+	if err := snapshotStore.Save(ctx, model); err != nil {
+		t.Fatal(err)
+	}
+	if err := snapshotStore.Close(ctx); err != nil {
+		t.Fatal(err)
+	}
+}
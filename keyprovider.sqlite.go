@@ -0,0 +1,220 @@
+package store
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/gradientzero/comby/v3"
+)
+
+// KeyProvider supplies the keys payloads are encrypted with, e.g. data keys
+// of an external KMS or HSM, so keys do not have to be configured as raw
+// bytes. Keys must fit the encryption algorithm of the store, 16, 24 or 32
+// bytes for AES-GCM and 32 bytes for ChaCha20-Poly1305. Both methods receive
+// the context of the store operation.
+type KeyProvider interface {
+	// CurrentKey returns the key new payloads are encrypted with and its id,
+	// which is stored with every payload.
+	CurrentKey(ctx context.Context) (keyId string, key []byte, err error)
+	// Key returns the key with the given id to decrypt payloads.
+	Key(ctx context.Context, keyId string) ([]byte, error)
+}
+
+// EventStoreSQLiteWithKeyProvider encrypts payloads with the keys of provider.
+// It takes precedence over a crypto service.
+func EventStoreSQLiteWithKeyProvider(provider KeyProvider) EventStoreSQLiteOption {
+	return func(c *eventStoreSQLiteConfig) { c.KeyProvider = provider }
+}
+
+// CommandStoreSQLiteWithKeyProvider encrypts payloads with the keys of provider.
+// It takes precedence over a crypto service.
+func CommandStoreSQLiteWithKeyProvider(provider KeyProvider) CommandStoreSQLiteOption {
+	return func(c *commandStoreSQLiteConfig) { c.KeyProvider = provider }
+}
+
+// payloadCipher encrypts and decrypts single payloads (or fields of them).
+type payloadCipher interface {
+	Encrypt(ctx context.Context, data []byte) ([]byte, error)
+	Decrypt(ctx context.Context, data []byte) ([]byte, error)
+}
+
+// newPayloadCipher returns the cipher of a store, nil if it does not encrypt.
+func newPayloadCipher(provider KeyProvider, algorithm string, cryptoService *comby.CryptoService) payloadCipher {
+	switch {
+	case provider != nil:
+		return keyProviderCipher{provider: provider, algorithm: algorithm}
+	case cryptoService != nil:
+		return cryptoServiceCipher{cryptoService: cryptoService}
+	}
+	return nil
+}
+
+type cryptoServiceCipher struct {
+	cryptoService *comby.CryptoService
+}
+
+func (c cryptoServiceCipher) Encrypt(ctx context.Context, data []byte) ([]byte, error) {
+	return c.cryptoService.Encrypt(data)
+}
+
+func (c cryptoServiceCipher) Decrypt(ctx context.Context, data []byte) ([]byte, error) {
+	return c.cryptoService.Decrypt(data)
+}
+
+// payload envelopes of a key provider, a version byte followed by
+//
+//	v1: length of the key id, key id, nonce and AES-GCM sealed data
+//	v2: algorithm id, length of the key id, key id, nonce and sealed data
+//
+// v1 payloads stay readable, new payloads are written as v2.
+const (
+	keyEnvelopeV1 = 1
+	keyEnvelopeV2 = 2
+)
+
+type keyProviderCipher struct {
+	provider  KeyProvider
+	algorithm string
+}
+
+func (c keyProviderCipher) Encrypt(ctx context.Context, data []byte) ([]byte, error) {
+	id, err := algorithmId(c.algorithm)
+	if err != nil {
+		return nil, err
+	}
+	keyId, key, err := c.provider.CurrentKey(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get current key - %w", err)
+	}
+	if len(keyId) == 0 || len(keyId) > 255 {
+		return nil, fmt.Errorf("invalid key id '%s'", keyId)
+	}
+	aead, err := newAEAD(id, key)
+	if err != nil {
+		return nil, err
+	}
+	envelope := append([]byte{keyEnvelopeV2, id, byte(len(keyId))}, keyId...)
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	envelope = append(envelope, nonce...)
+	return aead.Seal(envelope, nonce, data, nil), nil
+}
+
+func (c keyProviderCipher) Decrypt(ctx context.Context, data []byte) ([]byte, error) {
+	if len(data) == 0 {
+		return nil, errors.New("payload was not encrypted with a key provider")
+	}
+	id, rest := algorithmIdAESGCM, data[1:]
+	switch data[0] {
+	case keyEnvelopeV1:
+	case keyEnvelopeV2:
+		if len(rest) == 0 {
+			return nil, errors.New("encrypted payload is too short")
+		}
+		id, rest = rest[0], rest[1:]
+	default:
+		return nil, errors.New("payload was not encrypted with a key provider")
+	}
+	if len(rest) == 0 || len(rest) < 1+int(rest[0]) {
+		return nil, errors.New("encrypted payload is too short")
+	}
+	keyId := string(rest[1 : 1+int(rest[0])])
+	key, err := c.provider.Key(ctx, keyId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get key '%s' - %w", keyId, err)
+	}
+	aead, err := newAEAD(id, key)
+	if err != nil {
+		return nil, err
+	}
+	sealed := rest[1+int(rest[0]):]
+	if len(sealed) < aead.NonceSize() {
+		return nil, errors.New("encrypted payload is too short")
+	}
+	return aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], nil)
+}
+
+type staticKeyProvider struct {
+	keyId string
+	key   []byte
+}
+
+// NewStaticKeyProvider returns a provider of a single fixed key, e.g. to
+// select an encryption algorithm without running a KMS.
+func NewStaticKeyProvider(keyId string, key []byte) KeyProvider {
+	return staticKeyProvider{keyId: keyId, key: key}
+}
+
+func (p staticKeyProvider) CurrentKey(ctx context.Context) (string, []byte, error) {
+	return p.keyId, p.key, nil
+}
+
+func (p staticKeyProvider) Key(ctx context.Context, keyId string) ([]byte, error) {
+	if keyId != p.keyId {
+		return nil, fmt.Errorf("unknown key '%s'", keyId)
+	}
+	return p.key, nil
+}
+
+type cachedKey struct {
+	keyId   string
+	key     []byte
+	expires time.Time
+}
+
+type cachingKeyProvider struct {
+	provider KeyProvider
+	ttl      time.Duration
+
+	mu      sync.Mutex
+	current *cachedKey
+	keys    map[string]*cachedKey
+}
+
+// NewCachingKeyProvider caches the keys of provider for ttl, so e.g. a KMS is
+// not called for every payload. Expired keys are fetched again on next use.
+func NewCachingKeyProvider(provider KeyProvider, ttl time.Duration) KeyProvider {
+	return &cachingKeyProvider{provider: provider, ttl: ttl, keys: map[string]*cachedKey{}}
+}
+
+func (p *cachingKeyProvider) CurrentKey(ctx context.Context) (string, []byte, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	now := time.Now()
+	if p.current != nil && now.Before(p.current.expires) {
+		return p.current.keyId, p.current.key, nil
+	}
+	keyId, key, err := p.provider.CurrentKey(ctx)
+	if err != nil {
+		return "", nil, err
+	}
+	p.current = &cachedKey{keyId: keyId, key: key, expires: now.Add(p.ttl)}
+	p.keys[keyId] = p.current
+	return keyId, key, nil
+}
+
+func (p *cachingKeyProvider) Key(ctx context.Context, keyId string) ([]byte, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	now := time.Now()
+	if cached, ok := p.keys[keyId]; ok && now.Before(cached.expires) {
+		return cached.key, nil
+	}
+	key, err := p.provider.Key(ctx, keyId)
+	if err != nil {
+		return nil, err
+	}
+	for id, cached := range p.keys {
+		if !now.Before(cached.expires) {
+			delete(p.keys, id)
+		}
+	}
+	p.keys[keyId] = &cachedKey{keyId: keyId, key: key, expires: now.Add(p.ttl)}
+	return key, nil
+}
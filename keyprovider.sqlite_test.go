@@ -0,0 +1,148 @@
+package store_test
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	store "github.com/gradientzero/comby-store-sqlite"
+	"github.com/gradientzero/comby/v3"
+)
+
+type ctxKeyTenant struct{}
+
+// testKeyProvider stands in for a KMS, it counts calls and records the
+// tenant found in the context of each call
+type testKeyProvider struct {
+	mu      sync.Mutex
+	current string
+	keys    map[string][]byte
+	calls   int
+	tenants []string
+}
+
+func (p *testKeyProvider) record(ctx context.Context) {
+	p.calls++
+	if tenant, ok := ctx.Value(ctxKeyTenant{}).(string); ok {
+		p.tenants = append(p.tenants, tenant)
+	}
+}
+
+func (p *testKeyProvider) CurrentKey(ctx context.Context) (string, []byte, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.record(ctx)
+	return p.current, p.keys[p.current], nil
+}
+
+func (p *testKeyProvider) Key(ctx context.Context, keyId string) ([]byte, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.record(ctx)
+	key, ok := p.keys[keyId]
+	if !ok {
+		return nil, fmt.Errorf("unknown key '%s'", keyId)
+	}
+	return key, nil
+}
+
+func (p *testKeyProvider) numCalls() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.calls
+}
+
+func TestEventStoreKeyProviderRotation(t *testing.T) {
+	ctx := context.Background()
+	provider := &testKeyProvider{current: "key-1", keys: map[string][]byte{
+		"key-1": []byte("12345678901234567890123456789012"),
+		"key-2": []byte("abcdefghijklmnopqrstuvwxyz012345"),
+	}}
+	eventStore := store.NewEventStoreSQLite(filepath.Join(t.TempDir(), "eventStore-keyprovider.db"))
+	eventStore.Configure(store.EventStoreSQLiteWithKeyProvider(provider))
+	if err := eventStore.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer eventStore.Close(ctx)
+
+	tenantCtx := context.WithValue(ctx, ctxKeyTenant{}, "tenant-1")
+	evt1 := createTestEvent("tenant-1", "domain-1", 1, 100)
+	if err := eventStore.Create(tenantCtx, comby.EventStoreCreateOptionWithEvent(evt1)); err != nil {
+		t.Fatal(err)
+	}
+	provider.mu.Lock()
+	provider.current = "key-2"
+	provider.mu.Unlock()
+	evt2 := createTestEvent("tenant-1", "domain-1", 2, 200)
+	if err := eventStore.Create(tenantCtx, comby.EventStoreCreateOptionWithEvent(evt2)); err != nil {
+		t.Fatal(err)
+	}
+
+	// payloads of both keys stay readable after the rotation
+	for i, evt := range []comby.Event{evt1, evt2} {
+		got, err := eventStore.Get(tenantCtx, comby.EventStoreGetOptionWithEventUuid(evt.GetEventUuid()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := fmt.Sprintf("test-data-%d", i+1); string(got.GetDomainEvtBytes()) != want {
+			t.Fatalf("expected %q, got %q", want, got.GetDomainEvtBytes())
+		}
+	}
+	provider.mu.Lock()
+	tenants := provider.tenants
+	provider.mu.Unlock()
+	for _, tenant := range tenants {
+		if tenant != "tenant-1" {
+			t.Fatalf("expected the context of the operation to reach the provider, got %v", tenants)
+		}
+	}
+	if len(tenants) != 4 {
+		t.Fatalf("expected 4 provider calls with context, got %d", len(tenants))
+	}
+
+	// a key which is no longer known cannot decrypt
+	provider.mu.Lock()
+	delete(provider.keys, "key-1")
+	provider.mu.Unlock()
+	if _, err := eventStore.Get(ctx, comby.EventStoreGetOptionWithEventUuid(evt1.GetEventUuid())); err == nil {
+		t.Fatal("expected an error for an unknown key")
+	}
+}
+
+func TestCommandStoreKeyProviderCaching(t *testing.T) {
+	ctx := context.Background()
+	provider := &testKeyProvider{current: "key-1", keys: map[string][]byte{
+		"key-1": []byte("12345678901234567890123456789012"),
+	}}
+	commandStore := store.NewCommandStoreSQLite(filepath.Join(t.TempDir(), "commandStore-keyprovider.db"))
+	commandStore.Configure(store.CommandStoreSQLiteWithKeyProvider(store.NewCachingKeyProvider(provider, time.Minute)))
+	if err := commandStore.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer commandStore.Close(ctx)
+
+	var cmds []comby.Command
+	for i := int64(1); i <= 5; i++ {
+		cmd := createTestCommand("tenant-1", "domain-1", i)
+		if err := commandStore.Create(ctx, comby.CommandStoreCreateOptionWithCommand(cmd)); err != nil {
+			t.Fatal(err)
+		}
+		cmds = append(cmds, cmd)
+	}
+	for i, cmd := range cmds {
+		got, err := commandStore.Get(ctx, comby.CommandStoreGetOptionWithCommandUuid(cmd.GetCommandUuid()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := fmt.Sprintf("test-data-%d", i+1); string(got.GetDomainCmdBytes()) != want {
+			t.Fatalf("expected %q, got %q", want, got.GetDomainCmdBytes())
+		}
+	}
+	// the current key is fetched once and also serves decryption
+	if calls := provider.numCalls(); calls != 1 {
+		t.Fatalf("expected 1 provider call, got %d", calls)
+	}
+}
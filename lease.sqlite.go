@@ -0,0 +1,130 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/gradientzero/comby/v3"
+)
+
+// ErrLeaseHeld is returned if a lease is held by another owner.
+var ErrLeaseHeld = errors.New("lease is held by another owner")
+
+// Lease is a named lock held by an owner until it expires.
+type Lease struct {
+	Name  string
+	Owner string
+	// unix nano
+	AcquiredAt int64
+	ExpiresAt  int64
+}
+
+// Leases coordinates single-writer background jobs (pruning, projections, ...)
+// of processes sharing the event store file. A lease is held until it is
+// released or expires, holders renew it periodically while working. Expiry
+// uses the wall clock of the processes, which should be roughly in sync.
+type Leases struct {
+	es    *eventStoreSQLite
+	owner string
+}
+
+// NewLeases returns the leases of the event store acquired on behalf of owner,
+// e.g. a hostname and process id unique among the processes.
+func NewLeases(eventStore comby.EventStore, owner string) (*Leases, error) {
+	es, ok := eventStore.(*eventStoreSQLite)
+	if !ok {
+		return nil, fmt.Errorf("leases require a sqlite event store")
+	}
+	if len(owner) == 0 {
+		return nil, fmt.Errorf("'%s' failed to create leases - owner is required", es.String())
+	}
+	return &Leases{es: es, owner: owner}, nil
+}
+
+var leaseTables = []strictTable{
+	{
+		name: "leases",
+		columns: `name TEXT NOT NULL PRIMARY KEY,
+		owner TEXT NOT NULL,
+		acquired_at INTEGER NOT NULL,
+		expires_at INTEGER NOT NULL`,
+		copyColumns: `name, owner, acquired_at, expires_at`,
+	},
+}
+
+// Init creates the leases table. The event store must be initialized before.
+func (l *Leases) Init(ctx context.Context) error {
+	if l.es.db == nil {
+		return fmt.Errorf("'%s' failed to init leases - event store is not initialized", l.es.String())
+	}
+	return migrateTx(ctx, l.es.db, func(tx *sql.Tx) error {
+		return migrateStrictTables(ctx, tx, loggerOrDiscard(l.es.cfg().Logger), leaseTables...)
+	})
+}
+
+// Acquire takes the named lease for ttl if it is free, expired or already held
+// by the owner. Otherwise it fails with ErrLeaseHeld.
+func (l *Leases) Acquire(ctx context.Context, name string, ttl time.Duration) (*Lease, error) {
+	if len(name) == 0 || ttl <= 0 {
+		return nil, fmt.Errorf("'%s' failed to acquire lease - name and positive ttl are required", l.es.String())
+	}
+	now := time.Now().UnixNano()
+	// a single statement, so concurrent processes can not both win
+	query := `INSERT INTO leases (name, owner, acquired_at, expires_at) VALUES (?, ?, ?, ?)
+		ON CONFLICT(name) DO UPDATE SET owner=excluded.owner, expires_at=excluded.expires_at,
+		acquired_at=CASE WHEN leases.owner=excluded.owner AND leases.expires_at > excluded.acquired_at THEN leases.acquired_at ELSE excluded.acquired_at END
+		WHERE leases.owner=excluded.owner OR leases.expires_at <= excluded.acquired_at;`
+	return l.exec(ctx, "acquire", name, query, name, l.owner, now, now+ttl.Nanoseconds())
+}
+
+// Renew extends a lease held by the owner to ttl from now. It fails with
+// ErrLeaseHeld if the lease was taken over by another owner meanwhile.
+func (l *Leases) Renew(ctx context.Context, name string, ttl time.Duration) (*Lease, error) {
+	if len(name) == 0 || ttl <= 0 {
+		return nil, fmt.Errorf("'%s' failed to renew lease - name and positive ttl are required", l.es.String())
+	}
+	query := `UPDATE leases SET expires_at=? WHERE name=? AND owner=?;`
+	return l.exec(ctx, "renew", name, query, time.Now().Add(ttl).UnixNano(), name, l.owner)
+}
+
+func (l *Leases) exec(ctx context.Context, op, name, query string, args ...any) (*Lease, error) {
+	// leases are bookkeeping of background jobs, they bypass the write limits
+	l.es.writeMu.Lock()
+	res, err := l.es.db.ExecContext(ctx, query, args...)
+	l.es.writeMu.Unlock()
+	if err != nil {
+		return nil, fmt.Errorf("'%s' failed to %s lease '%s' - %w", l.es.String(), op, name, classifyError(err))
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return nil, err
+	} else if n == 0 {
+		return nil, fmt.Errorf("'%s' failed to %s lease '%s' - %w", l.es.String(), op, name, ErrLeaseHeld)
+	}
+	return l.Holder(ctx, name)
+}
+
+// Release gives up a lease held by the owner. Releasing a lease which is not
+// held by the owner is a no-op.
+func (l *Leases) Release(ctx context.Context, name string) error {
+	l.es.writeMu.Lock()
+	defer l.es.writeMu.Unlock()
+	_, err := l.es.db.ExecContext(ctx, "DELETE FROM leases WHERE name=? AND owner=?;", name, l.owner)
+	return classifyError(err)
+}
+
+// Holder returns the current lease, nil if it is free or expired.
+func (l *Leases) Holder(ctx context.Context, name string) (*Lease, error) {
+	var lease Lease
+	query := `SELECT name, owner, acquired_at, expires_at FROM leases WHERE name=? AND expires_at > ?;`
+	err := l.es.db.QueryRowContext(ctx, query, name, time.Now().UnixNano()).Scan(&lease.Name, &lease.Owner, &lease.AcquiredAt, &lease.ExpiresAt)
+	switch {
+	case err == sql.ErrNoRows:
+		return nil, nil
+	case err != nil:
+		return nil, classifyError(err)
+	}
+	return &lease, nil
+}
@@ -0,0 +1,79 @@
+package store_test
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	store "github.com/gradientzero/comby-store-sqlite"
+)
+
+func TestLeases(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "eventStore-leases.db")
+
+	// two stores on one file, as used by two processes
+	var leases []*store.Leases
+	for _, owner := range []string{"worker-a", "worker-b"} {
+		eventStore := store.NewEventStoreSQLite(path)
+		if err := eventStore.Init(ctx); err != nil {
+			t.Fatal(err)
+		}
+		defer eventStore.Close(ctx)
+		l, err := store.NewLeases(eventStore, owner)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := l.Init(ctx); err != nil {
+			t.Fatal(err)
+		}
+		leases = append(leases, l)
+	}
+	a, b := leases[0], leases[1]
+
+	lease, err := a.Acquire(ctx, "prune", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lease.Owner != "worker-a" {
+		t.Fatalf("unexpected lease: %+v", lease)
+	}
+	if _, err := b.Acquire(ctx, "prune", time.Minute); !errors.Is(err, store.ErrLeaseHeld) {
+		t.Fatalf("expected held lease, got %v", err)
+	}
+	if _, err := b.Renew(ctx, "prune", time.Minute); !errors.Is(err, store.ErrLeaseHeld) {
+		t.Fatalf("expected held lease, got %v", err)
+	}
+	renewed, err := a.Renew(ctx, "prune", 2*time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if renewed.ExpiresAt <= lease.ExpiresAt || renewed.AcquiredAt != lease.AcquiredAt {
+		t.Fatalf("unexpected renewed lease: %+v", renewed)
+	}
+
+	// released leases are free for others
+	if err := b.Release(ctx, "prune"); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.Release(ctx, "prune"); err != nil {
+		t.Fatal(err)
+	}
+	if holder, err := b.Holder(ctx, "prune"); err != nil || holder != nil {
+		t.Fatalf("expected free lease: %+v %v", holder, err)
+	}
+	if _, err := b.Acquire(ctx, "prune", 50*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+
+	// expired leases are taken over, the previous owner can not renew
+	time.Sleep(100 * time.Millisecond)
+	if _, err := a.Acquire(ctx, "prune", time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.Renew(ctx, "prune", time.Minute); !errors.Is(err, store.ErrLeaseHeld) {
+		t.Fatalf("expected held lease, got %v", err)
+	}
+}
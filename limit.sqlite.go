@@ -0,0 +1,30 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrListLimitExceeded is returned by lists without limit (Limit -1) when
+// more records match than the maximum list limit of the store.
+var ErrListLimitExceeded = errors.New("list limit exceeded")
+
+// EventStoreSQLiteWithMaxListLimit caps the number of events a list loads at
+// once. Lists without limit fail with ErrListLimitExceeded if more events
+// match and larger limits are rejected, so a forgotten limit can't load a
+// whole table into memory. ListEach streams and is not capped.
+func EventStoreSQLiteWithMaxListLimit(n int64) EventStoreSQLiteOption {
+	return func(c *eventStoreSQLiteConfig) { c.MaxListLimit = n }
+}
+
+// CommandStoreSQLiteWithMaxListLimit caps the number of commands a list loads
+// at once, see EventStoreSQLiteWithMaxListLimit.
+func CommandStoreSQLiteWithMaxListLimit(n int64) CommandStoreSQLiteOption {
+	return func(c *commandStoreSQLiteConfig) { c.MaxListLimit = n }
+}
+
+// pageSQL returns the LIMIT and OFFSET clause, SQLite reads a limit of -1
+// as no limit.
+func pageSQL(limit, offset int64) string {
+	return fmt.Sprintf(" LIMIT %d OFFSET %d", max(limit, -1), max(offset, 0))
+}
@@ -0,0 +1,104 @@
+package store_test
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	store "github.com/gradientzero/comby-store-sqlite"
+	"github.com/gradientzero/comby/v3"
+)
+
+func eventPage(offset, limit int64) comby.EventStoreListOption {
+	return func(opts *comby.EventStoreListOptions) (*comby.EventStoreListOptions, error) {
+		opts.Offset, opts.Limit = offset, limit
+		return opts, nil
+	}
+}
+
+func TestEventStoreListWithoutLimit(t *testing.T) {
+	ctx := context.Background()
+	eventStore := store.NewEventStoreSQLite(filepath.Join(t.TempDir(), "limit.db"))
+	if err := eventStore.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer eventStore.Close(ctx)
+	for i := int64(1); i <= 150; i++ {
+		if err := eventStore.Create(ctx, comby.EventStoreCreateOptionWithEvent(createTestEvent("tenant-1", "domain-1", i, i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// an offset is applied without limit as well
+	evts, total, err := eventStore.List(ctx, eventPage(20, -1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(evts) != 130 || total != 150 || evts[0].GetVersion() != 21 {
+		t.Fatalf("expected 130 of 150 events from version 21, got %d of %d", len(evts), total)
+	}
+	if evts, _, err := eventStore.ListPage(ctx, eventPage(0, -1)); err != nil || len(evts) != 150 {
+		t.Fatalf("expected 150 events, got %d, %v", len(evts), err)
+	}
+	if values, _, err := eventStore.UniqueList(ctx, func(opts *comby.EventStoreUniqueListOptions) (*comby.EventStoreUniqueListOptions, error) {
+		opts.DbField, opts.Offset, opts.Limit = "aggregate_uuid", 100, -1
+		return opts, nil
+	}); err != nil || len(values) != 50 {
+		t.Fatalf("expected 50 values, got %d, %v", len(values), err)
+	}
+}
+
+func TestEventStoreMaxListLimit(t *testing.T) {
+	ctx := context.Background()
+	eventStore := store.NewEventStoreSQLite(filepath.Join(t.TempDir(), "limit.db"))
+	eventStore.Configure(store.EventStoreSQLiteWithMaxListLimit(10))
+	if err := eventStore.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer eventStore.Close(ctx)
+	for i := int64(1); i <= 12; i++ {
+		if err := eventStore.Create(ctx, comby.EventStoreCreateOptionWithEvent(createTestEvent("tenant-1", "domain-1", i, i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, _, err := eventStore.List(ctx, eventPage(0, -1)); !errors.Is(err, store.ErrListLimitExceeded) {
+		t.Fatalf("expected list limit to be exceeded, got %v", err)
+	}
+	if evts, _, err := eventStore.List(ctx, eventPage(2, -1)); err != nil || len(evts) != 10 {
+		t.Fatalf("expected 10 events, got %d, %v", len(evts), err)
+	}
+	if _, _, err := eventStore.List(ctx, eventPage(0, 11)); !errors.Is(err, store.ErrInvalidOptions) {
+		t.Fatalf("expected limit above the maximum to be invalid, got %v", err)
+	}
+	if evts, hasMore, err := eventStore.ListPage(ctx, eventPage(0, 10)); err != nil || len(evts) != 10 || !hasMore {
+		t.Fatalf("expected a full page with more events, got %d, %v, %v", len(evts), hasMore, err)
+	}
+}
+
+func TestCommandStoreMaxListLimit(t *testing.T) {
+	ctx := context.Background()
+	commandStore := store.NewCommandStoreSQLite(filepath.Join(t.TempDir(), "limit.db"))
+	commandStore.Configure(store.CommandStoreSQLiteWithMaxListLimit(3))
+	if err := commandStore.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer commandStore.Close(ctx)
+	for i := int64(1); i <= 4; i++ {
+		if err := commandStore.Create(ctx, comby.CommandStoreCreateOptionWithCommand(createTestCommand("tenant-1", "domain-1", i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	unlimited := func(opts *comby.CommandStoreListOptions) (*comby.CommandStoreListOptions, error) {
+		opts.Limit = -1
+		return opts, nil
+	}
+	if _, _, err := commandStore.List(ctx, unlimited); !errors.Is(err, store.ErrListLimitExceeded) {
+		t.Fatalf("expected list limit to be exceeded, got %v", err)
+	}
+	if cmds, _, err := commandStore.List(ctx, unlimited, store.CommandStoreListOptionFromInclusive(2)); err != nil || len(cmds) != 3 {
+		t.Fatalf("expected 3 commands, got %d, %v", len(cmds), err)
+	}
+}
@@ -0,0 +1,171 @@
+package store
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/gradientzero/comby-store-sqlite/internal"
+	"github.com/gradientzero/comby/v3"
+)
+
+// Events refer to the command which caused them by command_uuid. There is no
+// foreign key since event and command store may live in different files, but
+// the column is indexed so both can be joined when they share one.
+
+func (es *eventStoreSQLite) ListEventsByCommand(ctx context.Context, commandUuid string) ([]comby.Event, error) {
+	if len(commandUuid) < 1 {
+		return nil, fmt.Errorf("'%s' failed to list events - command uuid '%s' is invalid", es.String(), commandUuid)
+	}
+	whereList, args := tenantCondition(es.cfg().Tenant, []string{"command_uuid=?"}, []any{commandUuid})
+	query := fmt.Sprintf("SELECT %s FROM events%s ORDER BY id ASC;", eventSelectColumns, whereSQL(whereList))
+	dbRecords, err := es.queryEvents(ctx, query, args)
+	if err != nil {
+		return nil, err
+	}
+	var evts []comby.Event
+	for _, dbRecord := range dbRecords {
+		evt, err := es.decodeEvent(ctx, dbRecord)
+		if err != nil {
+			return nil, err
+		}
+		if err := es.authorizeEvent(ctx, OperationGet, evt); err != nil {
+			return nil, err
+		}
+		evts = append(evts, evt)
+	}
+	return evts, nil
+}
+
+// requireEvents fails if the event store does not share the database file.
+func (cs *commandStoreSQLite) requireEvents(ctx context.Context, op string) error {
+	var count int
+	if err := cs.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM sqlite_master WHERE name='events'`).Scan(&count); err != nil {
+		return err
+	}
+	if count == 0 {
+		return fmt.Errorf("'%s' failed to %s - no event store in same database", cs.String(), op)
+	}
+	return nil
+}
+
+func (cs *commandStoreSQLite) ListCommandsWithoutEvents(ctx context.Context) ([]comby.Command, error) {
+	if err := cs.requireEvents(ctx, "list commands without events"); err != nil {
+		return nil, err
+	}
+
+	query := fmt.Sprintf(`SELECT %s FROM commands c
+		WHERE NOT EXISTS (SELECT 1 FROM events e WHERE e.command_uuid=c.uuid)
+		ORDER BY id ASC;`, commandSelectColumns)
+	rows, err := cs.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var dbRecords []*internal.Command
+	for rows.Next() {
+		var dbRecord internal.Command
+		if err := scanCommand(rows, &dbRecord); err != nil {
+			return nil, err
+		}
+		if err := cs.decodeDomainData(ctx, &dbRecord); err != nil {
+			return nil, err
+		}
+		dbRecords = append(dbRecords, &dbRecord)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return internal.DbCommandsToBaseCommands(dbRecords)
+}
+
+// OrphanReport lists records of a shared database which do not match up
+// between event and command store.
+type OrphanReport struct {
+	// events whose command uuid matches no command
+	EventsWithoutCommand []OrphanEvent
+	// uuids of commands no event refers to, e.g. rejected commands
+	CommandsWithoutEvents []string
+	// tenants with commands but no events
+	TenantsWithoutEvents []OrphanTenant
+}
+
+type OrphanEvent struct {
+	EventUuid   string
+	CommandUuid string
+	Domain      string
+	DataType    string
+}
+
+type OrphanTenant struct {
+	TenantUuid string
+	Commands   int64
+}
+
+// Empty reports whether no orphans were found.
+func (r *OrphanReport) Empty() bool {
+	return len(r.EventsWithoutCommand) == 0 && len(r.CommandsWithoutEvents) == 0 && len(r.TenantsWithoutEvents) == 0
+}
+
+func (cs *commandStoreSQLite) FindOrphans(ctx context.Context) (*OrphanReport, error) {
+	if err := cs.requireEvents(ctx, "find orphans"); err != nil {
+		return nil, err
+	}
+	report := &OrphanReport{}
+
+	// events without command uuid were not caused by a command
+	rows, err := cs.db.QueryContext(ctx, `SELECT e.uuid, e.command_uuid, e.domain, e.data_type FROM events e
+		WHERE e.command_uuid != '' AND NOT EXISTS (SELECT 1 FROM commands c WHERE c.uuid=e.command_uuid)
+		ORDER BY e.id ASC;`)
+	if err != nil {
+		return nil, classifyError(err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var orphan OrphanEvent
+		if err := rows.Scan(&orphan.EventUuid, &orphan.CommandUuid, &orphan.Domain, &orphan.DataType); err != nil {
+			return nil, classifyError(err)
+		}
+		report.EventsWithoutCommand = append(report.EventsWithoutCommand, orphan)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, classifyError(err)
+	}
+
+	rows, err = cs.db.QueryContext(ctx, `SELECT c.uuid FROM commands c
+		WHERE NOT EXISTS (SELECT 1 FROM events e WHERE e.command_uuid=c.uuid)
+		ORDER BY c.id ASC;`)
+	if err != nil {
+		return nil, classifyError(err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var commandUuid string
+		if err := rows.Scan(&commandUuid); err != nil {
+			return nil, classifyError(err)
+		}
+		report.CommandsWithoutEvents = append(report.CommandsWithoutEvents, commandUuid)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, classifyError(err)
+	}
+
+	rows, err = cs.db.QueryContext(ctx, `SELECT c.tenant_uuid, COUNT(*) FROM commands c
+		WHERE NOT EXISTS (SELECT 1 FROM events e WHERE e.tenant_uuid=c.tenant_uuid)
+		GROUP BY c.tenant_uuid ORDER BY c.tenant_uuid ASC;`)
+	if err != nil {
+		return nil, classifyError(err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var orphan OrphanTenant
+		if err := rows.Scan(&orphan.TenantUuid, &orphan.Commands); err != nil {
+			return nil, classifyError(err)
+		}
+		report.TenantsWithoutEvents = append(report.TenantsWithoutEvents, orphan)
+	}
+	return report, classifyError(rows.Err())
+}
@@ -0,0 +1,120 @@
+package store_test
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	store "github.com/gradientzero/comby-store-sqlite"
+	"github.com/gradientzero/comby/v3"
+)
+
+func TestCommandEventLink(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "store.db")
+
+	// both stores share one file
+	eventStore := store.NewEventStoreSQLite(path)
+	if err := eventStore.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer eventStore.Close(ctx)
+	commandStore := store.NewCommandStoreSQLite(path)
+	if err := commandStore.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer commandStore.Close(ctx)
+
+	handled := createTestCommand("tenant-1", "domain-1", 100)
+	lost := createTestCommand("tenant-1", "domain-1", 200)
+	for _, cmd := range []comby.Command{handled, lost} {
+		if err := commandStore.Create(ctx, comby.CommandStoreCreateOptionWithCommand(cmd)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i := int64(1); i <= 2; i++ {
+		evt := createTestEvent("tenant-1", "domain-1", i, i*100)
+		evt.SetCommandUuid(handled.GetCommandUuid())
+		if err := eventStore.Create(ctx, comby.EventStoreCreateOptionWithEvent(evt)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	evts, err := eventStore.ListEventsByCommand(ctx, handled.GetCommandUuid())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(evts) != 2 || evts[0].GetVersion() != 1 {
+		t.Fatalf("expected 2 events in order, got %d", len(evts))
+	}
+
+	cmds, err := commandStore.ListCommandsWithoutEvents(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cmds) != 1 || cmds[0].GetCommandUuid() != lost.GetCommandUuid() {
+		t.Fatalf("expected lost command only, got %d commands", len(cmds))
+	}
+}
+
+func TestListCommandsWithoutEventsSeparateFile(t *testing.T) {
+	ctx := context.Background()
+	commandStore := store.NewCommandStoreSQLite(filepath.Join(t.TempDir(), "commandStore.db"))
+	if err := commandStore.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer commandStore.Close(ctx)
+
+	if _, err := commandStore.ListCommandsWithoutEvents(ctx); err == nil {
+		t.Fatal("expected error without event store in same file")
+	}
+}
+
+func TestFindOrphans(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "store-orphans.db")
+	eventStore := store.NewEventStoreSQLite(path)
+	if err := eventStore.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer eventStore.Close(ctx)
+	commandStore := store.NewCommandStoreSQLite(path)
+	if err := commandStore.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer commandStore.Close(ctx)
+
+	handled := createTestCommand("tenant-1", "domain-1", 100)
+	lost := createTestCommand("tenant-2", "domain-1", 200)
+	for _, cmd := range []comby.Command{handled, lost} {
+		if err := commandStore.Create(ctx, comby.CommandStoreCreateOptionWithCommand(cmd)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// event 1 belongs to the handled command, event 2 to a deleted one
+	// and event 3 was not caused by a command
+	for i, commandUuid := range []string{handled.GetCommandUuid(), "deleted-command", ""} {
+		evt := createTestEvent("tenant-1", "domain-1", int64(i+1), int64(i+1)*100)
+		evt.SetCommandUuid(commandUuid)
+		if err := eventStore.Create(ctx, comby.EventStoreCreateOptionWithEvent(evt)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	report, err := commandStore.FindOrphans(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Empty() {
+		t.Fatal("expected orphans")
+	}
+	if len(report.EventsWithoutCommand) != 1 || report.EventsWithoutCommand[0].CommandUuid != "deleted-command" {
+		t.Fatalf("unexpected events without command: %+v", report.EventsWithoutCommand)
+	}
+	if len(report.CommandsWithoutEvents) != 1 || report.CommandsWithoutEvents[0] != lost.GetCommandUuid() {
+		t.Fatalf("unexpected commands without events: %+v", report.CommandsWithoutEvents)
+	}
+	if len(report.TenantsWithoutEvents) != 1 || report.TenantsWithoutEvents[0] != (store.OrphanTenant{TenantUuid: "tenant-2", Commands: 1}) {
+		t.Fatalf("unexpected tenants without events: %+v", report.TenantsWithoutEvents)
+	}
+}
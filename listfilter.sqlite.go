@@ -0,0 +1,391 @@
+package store
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gradientzero/comby/v3"
+)
+
+// listFilter holds the filters of the list options of this package, which
+// have no counterpart in comby.EventStoreListOptions and
+// comby.CommandStoreListOptions.
+type listFilter struct {
+	TenantUuids []string
+	// commands only, events filter by comby.EventStoreListOptions.Domains
+	Domains []string
+	// records of these are left out
+	ExcludeTenantUuids []string
+	ExcludeDomains     []string
+	ExcludeDataTypes   []string
+	// inclusive created_at bounds (unix nano), only applied if set
+	CreatedFrom    int64
+	HasCreatedFrom bool
+	CreatedTo      int64
+	HasCreatedTo   bool
+	// domain and data type filters ignore ASCII case
+	CaseInsensitive bool
+	// records match any prefix or GLOB pattern of a column
+	DomainPrefixes   []string
+	DataTypePrefixes []string
+	DomainGlobs      []string
+	DataTypeGlobs    []string
+}
+
+// conditions appends the where conditions of the filter.
+func (f listFilter) conditions(whereList []string, args []any) ([]string, []any) {
+	whereList, args = inCondition("tenant_uuid", "IN", f.TenantUuids, whereList, args)
+	whereList, args = inCondition(f.column("domain"), "IN", f.Domains, whereList, args)
+	whereList, args = inCondition("tenant_uuid", "NOT IN", f.ExcludeTenantUuids, whereList, args)
+	whereList, args = inCondition(f.column("domain"), "NOT IN", f.ExcludeDomains, whereList, args)
+	whereList, args = inCondition(f.column("data_type"), "NOT IN", f.ExcludeDataTypes, whereList, args)
+	whereList, args = f.patternCondition("domain", f.DomainPrefixes, f.DomainGlobs, whereList, args)
+	whereList, args = f.patternCondition("data_type", f.DataTypePrefixes, f.DataTypeGlobs, whereList, args)
+	if f.HasCreatedFrom {
+		whereList, args = append(whereList, "created_at>=?"), append(args, f.CreatedFrom)
+	}
+	if f.HasCreatedTo {
+		whereList, args = append(whereList, "created_at<=?"), append(args, f.CreatedTo)
+	}
+	return whereList, args
+}
+
+// column returns the column to compare, domain and data type with NOCASE
+// collation if the filter is case-insensitive, which their NOCASE indexes match.
+func (f listFilter) column(column string) string {
+	if f.CaseInsensitive && (column == "domain" || column == "data_type") {
+		return column + " COLLATE NOCASE"
+	}
+	return column
+}
+
+// inCondition appends "column IN (...)" or "column NOT IN (...)" unless
+// values is empty.
+func inCondition(column, operator string, values []string, whereList []string, args []any) ([]string, []any) {
+	if len(values) == 0 {
+		return whereList, args
+	}
+	placeholders := make([]string, len(values))
+	for i, value := range values {
+		placeholders[i] = "?"
+		args = append(args, value)
+	}
+	return append(whereList, fmt.Sprintf("%s %s (%s)", column, operator, strings.Join(placeholders, ","))), args
+}
+
+// listFilters maps the list options a store is applying to its filter, so
+// the options of this package can be passed as regular comby list options.
+var listFilters sync.Map
+
+// listFilterOf returns the filter of list options being applied by a sqlite
+// store, nil for other stores.
+func listFilterOf(listOpts any) *listFilter {
+	if filter, ok := listFilters.Load(listOpts); ok {
+		return filter.(*listFilter)
+	}
+	return nil
+}
+
+// sqliteListOption applies fn to the filter of a sqlite store and fails with
+// other stores, which would silently ignore it.
+func sqliteListOption(listOpts any, name string, fn func(filter *listFilter)) error {
+	filter := listFilterOf(listOpts)
+	if filter == nil {
+		return fmt.Errorf("list option '%s' requires a sqlite store", name)
+	}
+	fn(filter)
+	return nil
+}
+
+// EventStoreListOptionTenantUuids lists events of any of the given tenants.
+func EventStoreListOptionTenantUuids(tenantUuids ...string) comby.EventStoreListOption {
+	return func(opt *comby.EventStoreListOptions) (*comby.EventStoreListOptions, error) {
+		return opt, sqliteListOption(opt, "tenant uuids", func(filter *listFilter) {
+			filter.TenantUuids = append(filter.TenantUuids, tenantUuids...)
+		})
+	}
+}
+
+// CommandStoreListOptionTenantUuids lists commands of any of the given tenants.
+func CommandStoreListOptionTenantUuids(tenantUuids ...string) comby.CommandStoreListOption {
+	return func(opt *comby.CommandStoreListOptions) (*comby.CommandStoreListOptions, error) {
+		return opt, sqliteListOption(opt, "tenant uuids", func(filter *listFilter) {
+			filter.TenantUuids = append(filter.TenantUuids, tenantUuids...)
+		})
+	}
+}
+
+// CommandStoreListOptionDomains lists commands of any of the given domains,
+// like comby.EventStoreListOptions.Domains for events.
+func CommandStoreListOptionDomains(domains ...string) comby.CommandStoreListOption {
+	return func(opt *comby.CommandStoreListOptions) (*comby.CommandStoreListOptions, error) {
+		return opt, sqliteListOption(opt, "domains", func(filter *listFilter) {
+			filter.Domains = append(filter.Domains, domains...)
+		})
+	}
+}
+
+// EventStoreListOptionExcludeTenantUuids leaves out events of the given tenants.
+func EventStoreListOptionExcludeTenantUuids(tenantUuids ...string) comby.EventStoreListOption {
+	return func(opt *comby.EventStoreListOptions) (*comby.EventStoreListOptions, error) {
+		return opt, sqliteListOption(opt, "exclude tenant uuids", func(filter *listFilter) {
+			filter.ExcludeTenantUuids = append(filter.ExcludeTenantUuids, tenantUuids...)
+		})
+	}
+}
+
+// EventStoreListOptionExcludeDomains leaves out events of the given domains,
+// e.g. internal housekeeping events a projection is not interested in.
+func EventStoreListOptionExcludeDomains(domains ...string) comby.EventStoreListOption {
+	return func(opt *comby.EventStoreListOptions) (*comby.EventStoreListOptions, error) {
+		return opt, sqliteListOption(opt, "exclude domains", func(filter *listFilter) {
+			filter.ExcludeDomains = append(filter.ExcludeDomains, domains...)
+		})
+	}
+}
+
+// EventStoreListOptionExcludeDataTypes leaves out events of the given data types.
+func EventStoreListOptionExcludeDataTypes(dataTypes ...string) comby.EventStoreListOption {
+	return func(opt *comby.EventStoreListOptions) (*comby.EventStoreListOptions, error) {
+		return opt, sqliteListOption(opt, "exclude data types", func(filter *listFilter) {
+			filter.ExcludeDataTypes = append(filter.ExcludeDataTypes, dataTypes...)
+		})
+	}
+}
+
+// CommandStoreListOptionExcludeTenantUuids leaves out commands of the given tenants.
+func CommandStoreListOptionExcludeTenantUuids(tenantUuids ...string) comby.CommandStoreListOption {
+	return func(opt *comby.CommandStoreListOptions) (*comby.CommandStoreListOptions, error) {
+		return opt, sqliteListOption(opt, "exclude tenant uuids", func(filter *listFilter) {
+			filter.ExcludeTenantUuids = append(filter.ExcludeTenantUuids, tenantUuids...)
+		})
+	}
+}
+
+// CommandStoreListOptionExcludeDomains leaves out commands of the given domains.
+func CommandStoreListOptionExcludeDomains(domains ...string) comby.CommandStoreListOption {
+	return func(opt *comby.CommandStoreListOptions) (*comby.CommandStoreListOptions, error) {
+		return opt, sqliteListOption(opt, "exclude domains", func(filter *listFilter) {
+			filter.ExcludeDomains = append(filter.ExcludeDomains, domains...)
+		})
+	}
+}
+
+// CommandStoreListOptionExcludeDataTypes leaves out commands of the given data types.
+func CommandStoreListOptionExcludeDataTypes(dataTypes ...string) comby.CommandStoreListOption {
+	return func(opt *comby.CommandStoreListOptions) (*comby.CommandStoreListOptions, error) {
+		return opt, sqliteListOption(opt, "exclude data types", func(filter *listFilter) {
+			filter.ExcludeDataTypes = append(filter.ExcludeDataTypes, dataTypes...)
+		})
+	}
+}
+
+// EventStoreListOptionCaseInsensitive matches domains and data types
+// regardless of case, e.g. "OrderPlaced" also lists "orderPlaced" events. It
+// applies to the comby filters as well as the exclusions of this package.
+// Only ASCII letters are folded.
+func EventStoreListOptionCaseInsensitive() comby.EventStoreListOption {
+	return func(opt *comby.EventStoreListOptions) (*comby.EventStoreListOptions, error) {
+		return opt, sqliteListOption(opt, "case insensitive", func(filter *listFilter) {
+			filter.CaseInsensitive = true
+		})
+	}
+}
+
+// CommandStoreListOptionCaseInsensitive matches domains and data types
+// regardless of case, see EventStoreListOptionCaseInsensitive.
+func CommandStoreListOptionCaseInsensitive() comby.CommandStoreListOption {
+	return func(opt *comby.CommandStoreListOptions) (*comby.CommandStoreListOptions, error) {
+		return opt, sqliteListOption(opt, "case insensitive", func(filter *listFilter) {
+			filter.CaseInsensitive = true
+		})
+	}
+}
+
+// setTimeRange sets the inclusive bounds of from and to, a zero time leaves
+// its side open.
+func (f *listFilter) setTimeRange(from, to time.Time) {
+	if !from.IsZero() {
+		f.CreatedFrom, f.HasCreatedFrom = from.UnixNano(), true
+	}
+	if !to.IsZero() {
+		f.CreatedTo, f.HasCreatedTo = to.UnixNano(), true
+	}
+}
+
+// EventStoreListOptionFromInclusive lists events created at or after createdAt
+// (unix nano). Unlike comby's After, the bound is inclusive and 0 is a valid
+// timestamp. It may be combined with After and Before, all bounds apply.
+func EventStoreListOptionFromInclusive(createdAt int64) comby.EventStoreListOption {
+	return func(opt *comby.EventStoreListOptions) (*comby.EventStoreListOptions, error) {
+		return opt, sqliteListOption(opt, "from inclusive", func(filter *listFilter) {
+			filter.CreatedFrom, filter.HasCreatedFrom = createdAt, true
+		})
+	}
+}
+
+// EventStoreListOptionToInclusive lists events created at or before createdAt
+// (unix nano), see EventStoreListOptionFromInclusive.
+func EventStoreListOptionToInclusive(createdAt int64) comby.EventStoreListOption {
+	return func(opt *comby.EventStoreListOptions) (*comby.EventStoreListOptions, error) {
+		return opt, sqliteListOption(opt, "to inclusive", func(filter *listFilter) {
+			filter.CreatedTo, filter.HasCreatedTo = createdAt, true
+		})
+	}
+}
+
+// EventStoreListOptionTimeRange lists events created from to to, both
+// inclusive. A zero time leaves its side of the range open.
+func EventStoreListOptionTimeRange(from, to time.Time) comby.EventStoreListOption {
+	return func(opt *comby.EventStoreListOptions) (*comby.EventStoreListOptions, error) {
+		return opt, sqliteListOption(opt, "time range", func(filter *listFilter) {
+			filter.setTimeRange(from, to)
+		})
+	}
+}
+
+// CommandStoreListOptionFromInclusive lists commands created at or after
+// createdAt (unix nano), see EventStoreListOptionFromInclusive.
+func CommandStoreListOptionFromInclusive(createdAt int64) comby.CommandStoreListOption {
+	return func(opt *comby.CommandStoreListOptions) (*comby.CommandStoreListOptions, error) {
+		return opt, sqliteListOption(opt, "from inclusive", func(filter *listFilter) {
+			filter.CreatedFrom, filter.HasCreatedFrom = createdAt, true
+		})
+	}
+}
+
+// CommandStoreListOptionToInclusive lists commands created at or before
+// createdAt (unix nano), see EventStoreListOptionFromInclusive.
+func CommandStoreListOptionToInclusive(createdAt int64) comby.CommandStoreListOption {
+	return func(opt *comby.CommandStoreListOptions) (*comby.CommandStoreListOptions, error) {
+		return opt, sqliteListOption(opt, "to inclusive", func(filter *listFilter) {
+			filter.CreatedTo, filter.HasCreatedTo = createdAt, true
+		})
+	}
+}
+
+// CommandStoreListOptionTimeRange lists commands created from to to, both
+// inclusive. A zero time leaves its side of the range open.
+func CommandStoreListOptionTimeRange(from, to time.Time) comby.CommandStoreListOption {
+	return func(opt *comby.CommandStoreListOptions) (*comby.CommandStoreListOptions, error) {
+		return opt, sqliteListOption(opt, "time range", func(filter *listFilter) {
+			filter.setTimeRange(from, to)
+		})
+	}
+}
+
+// listOptions returns the list options with defaults and the filter set by opts.
+func (es *eventStoreSQLite) listOptions(opts []comby.EventStoreListOption) (comby.EventStoreListOptions, eventFilter, error) {
+	listOpts := comby.EventStoreListOptions{
+		Before:    -1,
+		After:     -1,
+		Offset:    0,
+		Limit:     100,
+		OrderBy:   "created_at",
+		Ascending: true,
+	}
+	var filter eventFilter
+	listFilters.Store(&listOpts, &filter.listFilter)
+	defer listFilters.Delete(&listOpts)
+	for _, opt := range opts {
+		if _, err := opt(&listOpts); err != nil {
+			return listOpts, filter, err
+		}
+	}
+	if err := checkEventListOptions(listOpts, filter, es.cfg().MaxListLimit); err != nil {
+		return listOpts, filter, fmt.Errorf("'%s' failed to list events - %w", es.String(), err)
+	}
+	return listOpts, filter, nil
+}
+
+// listOptions returns the list options with defaults and the filter set by opts.
+func (cs *commandStoreSQLite) listOptions(opts []comby.CommandStoreListOption) (comby.CommandStoreListOptions, commandFilter, error) {
+	listOpts := comby.CommandStoreListOptions{
+		Before:    -1,
+		After:     -1,
+		Offset:    0,
+		Limit:     100,
+		OrderBy:   "created_at",
+		Ascending: true,
+	}
+	var filter commandFilter
+	listFilters.Store(&listOpts, &filter.listFilter)
+	defer listFilters.Delete(&listOpts)
+	for _, opt := range opts {
+		if _, err := opt(&listOpts); err != nil {
+			return listOpts, filter, err
+		}
+	}
+	if err := checkCommandListOptions(listOpts, filter, cs.cfg().MaxListLimit); err != nil {
+		return listOpts, filter, fmt.Errorf("'%s' failed to list commands - %w", cs.String(), err)
+	}
+	return listOpts, filter, nil
+}
+
+// describeEventList returns the list options and filters which are set as
+// key=value, e.g. for an OpError.
+func (es *eventStoreSQLite) describeEventList(opts []comby.EventStoreListOption) []string {
+	listOpts, filter, _ := es.listOptions(opts)
+	var details []string
+	details = describeValue(details, "tenant", listOpts.TenantUuid)
+	details = describeValue(details, "aggregate", listOpts.AggregateUuid)
+	details = describeValue(details, "domains", strings.Join(listOpts.Domains, ","))
+	details = describeValue(details, "dataType", listOpts.DataType)
+	details = describePage(details, listOpts.Before, listOpts.After, listOpts.Offset, listOpts.Limit, listOpts.OrderBy, listOpts.Ascending)
+	return filter.describe(details)
+}
+
+// describeCommandList returns the list options and filters which are set, see describeEventList.
+func (cs *commandStoreSQLite) describeCommandList(opts []comby.CommandStoreListOption) []string {
+	listOpts, filter, _ := cs.listOptions(opts)
+	var details []string
+	details = describeValue(details, "tenant", listOpts.TenantUuid)
+	details = describeValue(details, "domain", listOpts.Domain)
+	details = describeValue(details, "dataType", listOpts.DataType)
+	details = describeValue(details, "status", filter.Status)
+	details = describePage(details, listOpts.Before, listOpts.After, listOpts.Offset, listOpts.Limit, listOpts.OrderBy, listOpts.Ascending)
+	return filter.describe(details)
+}
+
+// describe appends the filters which are set.
+func (f listFilter) describe(details []string) []string {
+	details = describeValue(details, "tenants", strings.Join(f.TenantUuids, ","))
+	details = describeValue(details, "domains", strings.Join(f.Domains, ","))
+	details = describeValue(details, "excludeTenants", strings.Join(f.ExcludeTenantUuids, ","))
+	details = describeValue(details, "excludeDomains", strings.Join(f.ExcludeDomains, ","))
+	details = describeValue(details, "excludeDataTypes", strings.Join(f.ExcludeDataTypes, ","))
+	details = describeValue(details, "domainPrefixes", strings.Join(f.DomainPrefixes, ","))
+	details = describeValue(details, "dataTypePrefixes", strings.Join(f.DataTypePrefixes, ","))
+	details = describeValue(details, "domainGlobs", strings.Join(f.DomainGlobs, ","))
+	details = describeValue(details, "dataTypeGlobs", strings.Join(f.DataTypeGlobs, ","))
+	if f.HasCreatedFrom {
+		details = append(details, fmt.Sprintf("createdFrom=%d", f.CreatedFrom))
+	}
+	if f.HasCreatedTo {
+		details = append(details, fmt.Sprintf("createdTo=%d", f.CreatedTo))
+	}
+	if f.CaseInsensitive {
+		details = append(details, "caseInsensitive=true")
+	}
+	return details
+}
+
+func describeValue(details []string, key, value string) []string {
+	if len(value) == 0 {
+		return details
+	}
+	return append(details, key+"="+value)
+}
+
+// describePage appends the time bounds, if set, and the page of a list.
+func describePage(details []string, before, after, offset, limit int64, orderBy string, ascending bool) []string {
+	if before >= 0 {
+		details = append(details, fmt.Sprintf("before=%d", before))
+	}
+	if after >= 0 {
+		details = append(details, fmt.Sprintf("after=%d", after))
+	}
+	return append(details, fmt.Sprintf("offset=%d", offset), fmt.Sprintf("limit=%d", limit), fmt.Sprintf("orderBy=%s", orderBy), fmt.Sprintf("ascending=%t", ascending))
+}
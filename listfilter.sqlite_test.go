@@ -0,0 +1,289 @@
+package store_test
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	store "github.com/gradientzero/comby-store-sqlite"
+	"github.com/gradientzero/comby/v3"
+)
+
+func TestEventStoreListTenantUuids(t *testing.T) {
+	ctx := context.Background()
+	eventStore := store.NewEventStoreSQLite(filepath.Join(t.TempDir(), "filter.db"))
+	if err := eventStore.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer eventStore.Close(ctx)
+
+	for i, tenantUuid := range []string{"tenant-1", "tenant-2", "tenant-3", "tenant-1"} {
+		evt := createTestEvent(tenantUuid, "domain-1", 1, int64(i+1))
+		if err := eventStore.Create(ctx, comby.EventStoreCreateOptionWithEvent(evt)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	evts, total, err := eventStore.List(ctx, store.EventStoreListOptionTenantUuids("tenant-1", "tenant-3"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(evts) != 3 || total != 3 {
+		t.Fatalf("expected 3 events, got %d (total %d)", len(evts), total)
+	}
+	for _, evt := range evts {
+		if evt.GetTenantUuid() == "tenant-2" {
+			t.Fatalf("unexpected event of tenant-2")
+		}
+	}
+	// the filter applies to the other list functions as well
+	if evts, _, err := eventStore.ListPage(ctx, store.EventStoreListOptionTenantUuids("tenant-2")); err != nil || len(evts) != 1 {
+		t.Fatalf("expected 1 event, got %d, %v", len(evts), err)
+	}
+}
+
+func TestCommandStoreListDomainsAndTenantUuids(t *testing.T) {
+	ctx := context.Background()
+	commandStore := store.NewCommandStoreSQLite(filepath.Join(t.TempDir(), "filter.db"))
+	if err := commandStore.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer commandStore.Close(ctx)
+
+	for i, tc := range []struct{ tenantUuid, domain string }{
+		{"tenant-1", "orders"},
+		{"tenant-1", "billing"},
+		{"tenant-2", "orders"},
+		{"tenant-2", "internal"},
+	} {
+		cmd := createTestCommand(tc.tenantUuid, tc.domain, int64(i+1))
+		if err := commandStore.Create(ctx, comby.CommandStoreCreateOptionWithCommand(cmd)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if cmds, total, err := commandStore.List(ctx, store.CommandStoreListOptionDomains("orders", "billing")); err != nil || len(cmds) != 3 || total != 3 {
+		t.Fatalf("expected 3 commands, got %d (total %d), %v", len(cmds), total, err)
+	}
+	cmds, _, err := commandStore.List(ctx,
+		store.CommandStoreListOptionDomains("orders", "internal"),
+		store.CommandStoreListOptionTenantUuids("tenant-2"),
+	)
+	if err != nil || len(cmds) != 2 {
+		t.Fatalf("expected 2 commands, got %d, %v", len(cmds), err)
+	}
+}
+
+func TestEventStoreListExclusions(t *testing.T) {
+	ctx := context.Background()
+	eventStore := store.NewEventStoreSQLite(filepath.Join(t.TempDir(), "exclude.db"))
+	if err := eventStore.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer eventStore.Close(ctx)
+
+	for i, tc := range []struct{ tenantUuid, domain, dataType string }{
+		{"tenant-1", "orders", "OrderPlaced"},
+		{"tenant-1", "housekeeping", "Heartbeat"},
+		{"tenant-2", "orders", "OrderPlaced"},
+		{"tenant-2", "orders", "OrderAudited"},
+	} {
+		evt := createTestEvent(tc.tenantUuid, tc.domain, 1, int64(i+1))
+		evt.SetDomainEvtName(tc.dataType)
+		if err := eventStore.Create(ctx, comby.EventStoreCreateOptionWithEvent(evt)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for name, tc := range map[string]struct {
+		opts []comby.EventStoreListOption
+		want int
+	}{
+		"domains":    {[]comby.EventStoreListOption{store.EventStoreListOptionExcludeDomains("housekeeping")}, 3},
+		"data types": {[]comby.EventStoreListOption{store.EventStoreListOptionExcludeDataTypes("Heartbeat", "OrderAudited")}, 2},
+		"tenants":    {[]comby.EventStoreListOption{store.EventStoreListOptionExcludeTenantUuids("tenant-2")}, 2},
+		"combined": {[]comby.EventStoreListOption{
+			store.EventStoreListOptionExcludeDomains("housekeeping"),
+			store.EventStoreListOptionExcludeDataTypes("OrderAudited"),
+		}, 2},
+	} {
+		evts, total, err := eventStore.List(ctx, tc.opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(evts) != tc.want || total != int64(tc.want) {
+			t.Fatalf("%s: expected %d events, got %d (total %d)", name, tc.want, len(evts), total)
+		}
+	}
+}
+
+func TestCommandStoreListExclusions(t *testing.T) {
+	ctx := context.Background()
+	commandStore := store.NewCommandStoreSQLite(filepath.Join(t.TempDir(), "exclude.db"))
+	if err := commandStore.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer commandStore.Close(ctx)
+
+	for i, domain := range []string{"orders", "housekeeping", "orders"} {
+		cmd := createTestCommand("tenant-1", domain, int64(i+1))
+		if err := commandStore.Create(ctx, comby.CommandStoreCreateOptionWithCommand(cmd)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if cmds, total, err := commandStore.List(ctx, store.CommandStoreListOptionExcludeDomains("housekeeping")); err != nil || len(cmds) != 2 || total != 2 {
+		t.Fatalf("expected 2 commands, got %d (total %d), %v", len(cmds), total, err)
+	}
+	if cmds, _, err := commandStore.List(ctx, store.CommandStoreListOptionExcludeTenantUuids("tenant-1")); err != nil || len(cmds) != 0 {
+		t.Fatalf("expected no commands, got %d, %v", len(cmds), err)
+	}
+}
+
+func TestEventStoreListInclusiveTimeRange(t *testing.T) {
+	ctx := context.Background()
+	eventStore := store.NewEventStoreSQLite(filepath.Join(t.TempDir(), "range.db"))
+	if err := eventStore.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer eventStore.Close(ctx)
+
+	// created at 0 (the epoch) up to 3 seconds after it
+	for i := int64(0); i <= 3; i++ {
+		evt := createTestEvent("tenant-1", "domain-1", i+1, i*int64(time.Second))
+		if err := eventStore.Create(ctx, comby.EventStoreCreateOptionWithEvent(evt)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for name, tc := range map[string]struct {
+		opts []comby.EventStoreListOption
+		want int
+	}{
+		"from zero":   {[]comby.EventStoreListOption{store.EventStoreListOptionFromInclusive(0)}, 4},
+		"to zero":     {[]comby.EventStoreListOption{store.EventStoreListOptionToInclusive(0)}, 1},
+		"both bounds": {[]comby.EventStoreListOption{store.EventStoreListOptionFromInclusive(int64(time.Second)), store.EventStoreListOptionToInclusive(2 * int64(time.Second))}, 2},
+		"time range":  {[]comby.EventStoreListOption{store.EventStoreListOptionTimeRange(time.Unix(1, 0), time.Unix(3, 0))}, 3},
+		"open start":  {[]comby.EventStoreListOption{store.EventStoreListOptionTimeRange(time.Time{}, time.Unix(1, 0))}, 2},
+	} {
+		evts, total, err := eventStore.List(ctx, tc.opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(evts) != tc.want || total != int64(tc.want) {
+			t.Fatalf("%s: expected %d events, got %d (total %d)", name, tc.want, len(evts), total)
+		}
+	}
+}
+
+func TestCommandStoreListInclusiveTimeRange(t *testing.T) {
+	ctx := context.Background()
+	commandStore := store.NewCommandStoreSQLite(filepath.Join(t.TempDir(), "range.db"))
+	if err := commandStore.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer commandStore.Close(ctx)
+
+	for i := int64(0); i <= 2; i++ {
+		if err := commandStore.Create(ctx, comby.CommandStoreCreateOptionWithCommand(createTestCommand("tenant-1", "domain-1", i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cmds, _, err := commandStore.List(ctx, store.CommandStoreListOptionFromInclusive(0), store.CommandStoreListOptionToInclusive(1))
+	if err != nil || len(cmds) != 2 {
+		t.Fatalf("expected 2 commands, got %d, %v", len(cmds), err)
+	}
+	cmds, _, err = commandStore.List(ctx, store.CommandStoreListOptionTimeRange(time.Unix(0, 2), time.Time{}))
+	if err != nil || len(cmds) != 1 {
+		t.Fatalf("expected 1 command, got %d, %v", len(cmds), err)
+	}
+}
+
+func TestListOptionRequiresSQLiteStore(t *testing.T) {
+	// other stores would ignore the filter, so the option fails instead
+	var listOpts comby.EventStoreListOptions
+	if _, err := store.EventStoreListOptionTenantUuids("tenant-1")(&listOpts); err == nil {
+		t.Fatal("expected option to fail outside of a sqlite store")
+	}
+}
+
+func TestEventStoreListCaseInsensitive(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "nocase.db")
+	eventStore := store.NewEventStoreSQLite(path)
+	if err := eventStore.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer eventStore.Close(ctx)
+
+	for i, domain := range []string{"Orders", "orders", "ORDERS", "billing"} {
+		evt := createTestEvent("tenant-1", domain, 1, int64(i+1))
+		evt.SetDomainEvtName("OrderPlaced")
+		if i == 1 {
+			evt.SetDomainEvtName("orderplaced")
+		}
+		if err := eventStore.Create(ctx, comby.EventStoreCreateOptionWithEvent(evt)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	domains := func(opts *comby.EventStoreListOptions) (*comby.EventStoreListOptions, error) {
+		opts.Domains = []string{"orders"}
+		return opts, nil
+	}
+	if evts, _, err := eventStore.List(ctx, domains); err != nil || len(evts) != 1 {
+		t.Fatalf("expected 1 exact match, got %d, %v", len(evts), err)
+	}
+	if evts, _, err := eventStore.List(ctx, domains, store.EventStoreListOptionCaseInsensitive()); err != nil || len(evts) != 3 {
+		t.Fatalf("expected 3 events, got %d, %v", len(evts), err)
+	}
+	dataType := func(opts *comby.EventStoreListOptions) (*comby.EventStoreListOptions, error) {
+		opts.DataType = "ORDERPLACED"
+		return opts, nil
+	}
+	if evts, _, err := eventStore.List(ctx, dataType, store.EventStoreListOptionCaseInsensitive()); err != nil || len(evts) != 4 {
+		t.Fatalf("expected 4 events, got %d, %v", len(evts), err)
+	}
+	if evts, _, err := eventStore.List(ctx, store.EventStoreListOptionExcludeDomains("ORDERS"), store.EventStoreListOptionCaseInsensitive()); err != nil || len(evts) != 1 {
+		t.Fatalf("expected 1 event, got %d, %v", len(evts), err)
+	}
+
+	// the filter is answered by the NOCASE index
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if plan := queryPlan(t, db, "SELECT id FROM events WHERE data_type COLLATE NOCASE=?;", "orderplaced"); !strings.Contains(plan, "event_records_data_type_nocase_index") {
+		t.Fatalf("expected the nocase index to be used, got %s", plan)
+	}
+}
+
+func TestCommandStoreListCaseInsensitive(t *testing.T) {
+	ctx := context.Background()
+	commandStore := store.NewCommandStoreSQLite(filepath.Join(t.TempDir(), "nocase.db"))
+	if err := commandStore.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer commandStore.Close(ctx)
+
+	for i, domain := range []string{"Orders", "orders", "billing"} {
+		if err := commandStore.Create(ctx, comby.CommandStoreCreateOptionWithCommand(createTestCommand("tenant-1", domain, int64(i+1)))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	domain := func(opts *comby.CommandStoreListOptions) (*comby.CommandStoreListOptions, error) {
+		opts.Domain = "ORDERS"
+		return opts, nil
+	}
+	if cmds, _, err := commandStore.List(ctx, domain); err != nil || len(cmds) != 0 {
+		t.Fatalf("expected no exact match, got %d, %v", len(cmds), err)
+	}
+	if cmds, _, err := commandStore.List(ctx, domain, store.CommandStoreListOptionCaseInsensitive()); err != nil || len(cmds) != 2 {
+		t.Fatalf("expected 2 commands, got %d, %v", len(cmds), err)
+	}
+	if cmds, _, err := commandStore.List(ctx, store.CommandStoreListOptionDomains("ORDERS", "Billing"), store.CommandStoreListOptionCaseInsensitive()); err != nil || len(cmds) != 3 {
+		t.Fatalf("expected 3 commands, got %d, %v", len(cmds), err)
+	}
+	// conflicting filters are compared case-insensitively as well
+	if _, _, err := commandStore.List(ctx, domain, store.CommandStoreListOptionExcludeDomains("orders"), store.CommandStoreListOptionCaseInsensitive()); err == nil {
+		t.Fatal("expected conflicting filters to fail")
+	}
+}
@@ -0,0 +1,68 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/gradientzero/comby/v3"
+)
+
+// LoadAggregate returns the latest snapshot of an aggregate (nil if there is
+// none) and the events after its version in version order, i.e. everything
+// needed to rehydrate the aggregate. Both are read within one transaction,
+// so they match up even while events are appended. It requires the event and
+// the snapshot store.
+func (s *Stores) LoadAggregate(ctx context.Context, aggregateUuid string) (*comby.SnapshotStoreModel, []comby.Event, error) {
+	es, ok := s.EventStore.(*eventStoreSQLite)
+	if !ok {
+		return nil, nil, fmt.Errorf("'%s' failed to load aggregate - event store is required", s.String())
+	}
+	ss, ok := s.SnapshotStore.(*snapshotStoreSQLite)
+	if !ok {
+		return nil, nil, fmt.Errorf("'%s' failed to load aggregate - snapshot store is required", s.String())
+	}
+	if len(aggregateUuid) == 0 {
+		return nil, nil, fmt.Errorf("'%s' failed to load aggregate - aggregate uuid is required", s.String())
+	}
+	ctx, cancel := es.cfg().Timeouts.read(ctx)
+	defer cancel()
+	if err := es.authorize(ctx, AccessRequest{Operation: OperationList, AggregateUuid: aggregateUuid}); err != nil {
+		return nil, nil, err
+	}
+
+	tx, err := beginReadSnapshot(ctx, s.db)
+	if err != nil {
+		return nil, nil, fmt.Errorf("'%s' failed to load aggregate - %w", s.String(), err)
+	}
+	defer tx.Rollback()
+
+	snapshot, err := ss.getLatest(ctx, tx, aggregateUuid)
+	if err != nil {
+		return nil, nil, fmt.Errorf("'%s' failed to load aggregate - %w", s.String(), classifyError(err))
+	}
+	// snapshots of other tenants do not exist for a bound store
+	if snapshot != nil && checkTenant(es.cfg().Tenant, snapshot.TenantUuid) != nil {
+		snapshot = nil
+	}
+	var version int64
+	if snapshot != nil {
+		version = snapshot.Version
+	}
+
+	whereList, args := tenantCondition(es.cfg().Tenant, []string{"aggregate_uuid=?", "version>?"}, []any{aggregateUuid, version})
+	query := fmt.Sprintf("SELECT %s FROM events WHERE %s ORDER BY version ASC, id ASC;", eventSelectColumns, strings.Join(whereList, " AND "))
+	dbRecords, err := queryEventRecords(ctx, tx, query, args)
+	if err != nil {
+		return nil, nil, fmt.Errorf("'%s' failed to load aggregate - %w", s.String(), classifyError(err))
+	}
+	evts := make([]comby.Event, 0, len(dbRecords))
+	for _, dbRecord := range dbRecords {
+		evt, err := es.decodeEvent(ctx, dbRecord)
+		if err != nil {
+			return nil, nil, err
+		}
+		evts = append(evts, evt)
+	}
+	return snapshot, evts, nil
+}
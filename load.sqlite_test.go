@@ -0,0 +1,63 @@
+package store_test
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/gradientzero/comby/v3"
+)
+
+func TestLoadAggregate(t *testing.T) {
+	ctx := context.Background()
+	stores := openTestStores(t, filepath.Join(t.TempDir(), "load.db"), "12345678901234567890123456789012")
+
+	for version := int64(1); version <= 5; version++ {
+		evt := createTestEvent("tenant-1", "domain-1", version, version*100)
+		evt.SetAggregateUuid("aggregate-1")
+		if err := stores.EventStore.Create(ctx, comby.EventStoreCreateOptionWithEvent(evt)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// without snapshot all events are returned
+	snapshot, evts, err := stores.LoadAggregate(ctx, "aggregate-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if snapshot != nil || len(evts) != 5 {
+		t.Fatalf("expected no snapshot and 5 events, got %v and %d", snapshot, len(evts))
+	}
+
+	if err := stores.SnapshotStore.Save(ctx, &comby.SnapshotStoreModel{
+		AggregateUuid: "aggregate-1",
+		TenantUuid:    "tenant-1",
+		Domain:        "domain-1",
+		Version:       3,
+		Data:          []byte("snapshot"),
+		CreatedAt:     300,
+	}); err != nil {
+		t.Fatal(err)
+	}
+	snapshot, evts, err = stores.LoadAggregate(ctx, "aggregate-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if snapshot == nil || snapshot.Version != 3 {
+		t.Fatalf("expected snapshot at version 3, got %v", snapshot)
+	}
+	if len(evts) != 2 || evts[0].GetVersion() != 4 || evts[1].GetVersion() != 5 {
+		t.Fatalf("expected events 4 and 5 after the snapshot, got %d", len(evts))
+	}
+	if string(evts[0].GetDomainEvtBytes()) != "test-data-4" {
+		t.Fatalf("unexpected event data: %s", evts[0].GetDomainEvtBytes())
+	}
+
+	snapshot, evts, err = stores.LoadAggregate(ctx, "unknown")
+	if err != nil || snapshot != nil || len(evts) != 0 {
+		t.Fatalf("expected empty result for unknown aggregate, got %v, %d, %v", snapshot, len(evts), err)
+	}
+	if _, _, err := stores.LoadAggregate(ctx, ""); err == nil {
+		t.Fatal("expected error for empty aggregate uuid")
+	}
+}
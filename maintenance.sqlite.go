@@ -0,0 +1,214 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// StorageReport is the result of MaintainStorage.
+type StorageReport struct {
+	// pages returned to the file system by incremental_vacuum
+	FreedPages int64
+	// pages still in the WAL and the number of them written back to the database
+	WalPages          int64
+	CheckpointedPages int64
+	// the checkpoint could not complete because of concurrent readers or writers
+	Busy bool
+}
+
+// enableIncrementalVacuum switches the database to auto_vacuum=INCREMENTAL.
+// Existing databases need a one-time VACUUM for the mode to take effect.
+func enableIncrementalVacuum(ctx context.Context, db *sql.DB, logger *slog.Logger) error {
+	// the pending mode is per connection until VACUUM ran on it
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	var mode int
+	if err := conn.QueryRowContext(ctx, "PRAGMA auto_vacuum;").Scan(&mode); err != nil {
+		return err
+	}
+	// 0=NONE, 1=FULL, 2=INCREMENTAL
+	if mode == 2 {
+		return nil
+	}
+	if _, err := conn.ExecContext(ctx, "PRAGMA auto_vacuum=INCREMENTAL;"); err != nil {
+		return err
+	}
+	start := time.Now()
+	if _, err := conn.ExecContext(ctx, "VACUUM;"); err != nil {
+		return err
+	}
+	logger.InfoContext(ctx, "enabled incremental vacuum", "duration", time.Since(start))
+	return nil
+}
+
+// maintainStorage reclaims free pages and truncates the WAL. Writes of this
+// process are blocked meanwhile, readers are not.
+func maintainStorage(ctx context.Context, db *sql.DB, writeMu *sync.Mutex) (*StorageReport, error) {
+	writeMu.Lock()
+	defer writeMu.Unlock()
+
+	report := &StorageReport{}
+	var before, after int64
+	if err := db.QueryRowContext(ctx, "PRAGMA freelist_count;").Scan(&before); err != nil {
+		return nil, err
+	}
+	// no-op unless auto_vacuum=INCREMENTAL, frees one page per step
+	rows, err := db.QueryContext(ctx, "PRAGMA incremental_vacuum;")
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if err := db.QueryRowContext(ctx, "PRAGMA freelist_count;").Scan(&after); err != nil {
+		return nil, err
+	}
+	report.FreedPages = before - after
+
+	var busy int
+	if err := db.QueryRowContext(ctx, "PRAGMA wal_checkpoint(TRUNCATE);").Scan(&busy, &report.WalPages, &report.CheckpointedPages); err != nil {
+		return nil, err
+	}
+	report.Busy = busy != 0
+	return report, nil
+}
+
+// maintenanceLoop runs a maintenance func periodically until stopped.
+type maintenanceLoop struct {
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+func startMaintenance(interval time.Duration, logger *slog.Logger, name string, fn func(ctx context.Context) error) *maintenanceLoop {
+	ctx, cancel := context.WithCancel(context.Background())
+	loop := &maintenanceLoop{cancel: cancel, done: make(chan struct{})}
+	go func() {
+		defer close(loop.done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := fn(ctx); err != nil && ctx.Err() == nil {
+					logger.ErrorContext(ctx, name+" failed", "error", err)
+				}
+			}
+		}
+	}()
+	return loop
+}
+
+func (l *maintenanceLoop) stop() {
+	if l == nil {
+		return
+	}
+	l.cancel()
+	<-l.done
+}
+
+// storageMaintenance configures MaintainStorage of a store.
+type storageMaintenance struct {
+	// use auto_vacuum=INCREMENTAL
+	IncrementalVacuum bool
+	// run MaintainStorage periodically, 0 disables it
+	Interval time.Duration
+}
+
+// start prepares the database and (re)starts the periodic maintenance of fn.
+func (m storageMaintenance) start(ctx context.Context, db *sql.DB, logger *slog.Logger, loop *maintenanceLoop, fn func(ctx context.Context) (*StorageReport, error)) (*maintenanceLoop, error) {
+	if m.IncrementalVacuum {
+		if err := enableIncrementalVacuum(ctx, db, logger); err != nil {
+			return loop, err
+		}
+	}
+	if m.Interval <= 0 {
+		return loop, nil
+	}
+	loop.stop()
+	return startMaintenance(m.Interval, logger, "storage maintenance", func(ctx context.Context) error {
+		report, err := fn(ctx)
+		if err != nil {
+			return err
+		}
+		logger.DebugContext(ctx, "storage maintenance", "freedPages", report.FreedPages, "checkpointedPages", report.CheckpointedPages, "busy", report.Busy)
+		return nil
+	}), nil
+}
+
+func (es *eventStoreSQLite) initMaintenance(ctx context.Context) (err error) {
+	config := es.cfg()
+	es.maintenance, err = config.Maintenance.start(ctx, es.db, loggerOrDiscard(config.Logger), es.maintenance, es.MaintainStorage)
+	return err
+}
+
+func (es *eventStoreSQLite) MaintainStorage(ctx context.Context) (*StorageReport, error) {
+	return maintainStorage(ctx, es.db, es.writeMu)
+}
+
+func (cs *commandStoreSQLite) initMaintenance(ctx context.Context) (err error) {
+	config := cs.cfg()
+	cs.maintenance, err = config.Maintenance.start(ctx, cs.db, loggerOrDiscard(config.Logger), cs.maintenance, cs.MaintainStorage)
+	return err
+}
+
+func (cs *commandStoreSQLite) MaintainStorage(ctx context.Context) (*StorageReport, error) {
+	return maintainStorage(ctx, cs.db, cs.writeMu)
+}
+
+// startLoops (re)starts the background loops of a writable store.
+func (es *eventStoreSQLite) startLoops(ctx context.Context) error {
+	if err := es.initMaintenance(ctx); err != nil {
+		return err
+	}
+	es.initCheckpointer()
+	es.initChangePolling()
+	es.initKeepalive()
+	es.initMetrics()
+	return nil
+}
+
+// stopLoops stops all background loops and waits for them. They use the
+// database handle and take writeMu, so they are stopped before either changes.
+func (es *eventStoreSQLite) stopLoops() {
+	es.maintenance.stop()
+	es.checkpointer.stop()
+	es.changePoller.stop()
+	es.keepalive.stop()
+	es.replica.stop()
+	es.metricsFlusher.stop()
+}
+
+// startLoops (re)starts the background loops of a writable store.
+func (cs *commandStoreSQLite) startLoops(ctx context.Context) error {
+	if err := cs.initMaintenance(ctx); err != nil {
+		return err
+	}
+	cs.initCheckpointer()
+	cs.initChangePolling()
+	cs.initKeepalive()
+	cs.initMetrics()
+	return nil
+}
+
+// stopLoops stops all background loops, see the event store.
+func (cs *commandStoreSQLite) stopLoops() {
+	cs.maintenance.stop()
+	cs.checkpointer.stop()
+	cs.changePoller.stop()
+	cs.keepalive.stop()
+	cs.metricsFlusher.stop()
+}
@@ -0,0 +1,79 @@
+package store_test
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	store "github.com/gradientzero/comby-store-sqlite"
+	"github.com/gradientzero/comby/v3"
+)
+
+func TestEventStoreMaintainStorage(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "eventStore-vacuum.db")
+
+	// existing database without incremental vacuum
+	eventStore := store.NewEventStoreSQLite(path)
+	if err := eventStore.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	eventStore.Close(ctx)
+
+	eventStore = store.NewEventStoreSQLite(path)
+	eventStore.Configure(
+		store.EventStoreSQLiteWithIncrementalVacuum(),
+		store.EventStoreSQLiteWithMaintenanceInterval(time.Hour),
+	)
+	if err := eventStore.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer eventStore.Close(ctx)
+
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	var mode int
+	if err := db.QueryRowContext(ctx, "PRAGMA auto_vacuum;").Scan(&mode); err != nil {
+		t.Fatal(err)
+	}
+	if mode != 2 {
+		t.Fatalf("expected auto_vacuum=INCREMENTAL, got %d", mode)
+	}
+
+	// large payloads leave free pages behind once deleted
+	var evts []comby.Event
+	for i := int64(1); i <= 20; i++ {
+		evt := createTestEvent("tenant-1", "domain-1", i, i*100)
+		evt.SetDomainEvtBytes([]byte(strings.Repeat("x", 16*1024)))
+		if err := eventStore.Create(ctx, comby.EventStoreCreateOptionWithEvent(evt)); err != nil {
+			t.Fatal(err)
+		}
+		evts = append(evts, evt)
+	}
+	for _, evt := range evts {
+		if err := eventStore.Delete(ctx, comby.EventStoreDeleteOptionWithEventUuid(evt.GetEventUuid())); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	report, err := eventStore.MaintainStorage(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.FreedPages == 0 {
+		t.Fatalf("expected freed pages: %+v", report)
+	}
+	var freePages int64
+	if err := db.QueryRowContext(ctx, "PRAGMA freelist_count;").Scan(&freePages); err != nil {
+		t.Fatal(err)
+	}
+	if freePages != 0 {
+		t.Fatalf("expected no free pages left, got %d", freePages)
+	}
+}
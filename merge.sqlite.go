@@ -0,0 +1,223 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+
+	"github.com/gradientzero/comby-store-sqlite/internal"
+	"github.com/gradientzero/comby/v3"
+)
+
+// MergeConflict is an event of a source which collides with the destination.
+type MergeConflict struct {
+	Source        string
+	EventUuid     string
+	AggregateUuid string
+	Version       int64
+	Reason        string
+	// the event was not merged, the destination keeps its event
+	Skipped bool
+}
+
+// MergeReport is the result of MergeStores.
+type MergeReport struct {
+	// events written to the destination
+	Merged int64
+	// events already in the destination with identical content
+	Duplicates int64
+	Conflicts  []MergeConflict
+}
+
+// MergeStores merges the events of several SQLite event store files (e.g. from
+// edge devices) into dst, which must be an initialized sqlite event store.
+// Events are appended in created_at order across all sources, ties keep the
+// order of the sources and their sequence within each source.
+//
+// Events are deduplicated by uuid. An event whose uuid is already known with a
+// different content is skipped and reported as conflict, an event taking an
+// aggregate version already used by another event is merged and reported.
+// Payloads are copied as stored, so encrypted sources must use the crypto key
+// of the destination. The merge runs in a single transaction.
+func MergeStores(ctx context.Context, dst comby.EventStore, srcs ...string) (*MergeReport, error) {
+	es, ok := dst.(*eventStoreSQLite)
+	if !ok {
+		return nil, fmt.Errorf("merge requires a sqlite event store")
+	}
+	if es.db == nil {
+		return nil, fmt.Errorf("'%s' failed to merge - event store is not initialized", es.String())
+	}
+	if es.opts().ReadOnly {
+		return nil, fmt.Errorf("'%s' failed to merge - instance is readonly", es.String())
+	}
+
+	cursors := make([]*mergeCursor, 0, len(srcs))
+	defer func() {
+		for _, c := range cursors {
+			c.close(ctx)
+		}
+	}()
+	for _, src := range srcs {
+		c, err := openMergeCursor(ctx, src)
+		if err != nil {
+			return nil, fmt.Errorf("'%s' failed to merge '%s' - %w", es.String(), src, err)
+		}
+		cursors = append(cursors, c)
+	}
+
+	if err := es.cfg().Quota.check(ctx, es.db); err != nil {
+		return nil, fmt.Errorf("'%s' failed to merge - %w", es.String(), err)
+	}
+	done, err := es.beginWrite(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	report := &MergeReport{}
+	err = runTx(ctx, es.db, func(tx *sql.Tx) error {
+		for {
+			// next event in created_at order, the first source wins on ties
+			var next *mergeCursor
+			for _, c := range cursors {
+				if c.next != nil && (next == nil || c.next.CreatedAt < next.next.CreatedAt) {
+					next = c
+				}
+			}
+			if next == nil {
+				return nil
+			}
+			if err := mergeEvent(ctx, tx, next.source, next.next, report); err != nil {
+				return err
+			}
+			if err := next.advance(); err != nil {
+				return fmt.Errorf("'%s' failed to merge '%s' - %w", es.String(), next.source, err)
+			}
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+func mergeEvent(ctx context.Context, tx *sql.Tx, source string, dbRecord *internal.Event, report *MergeReport) error {
+	conflict := MergeConflict{
+		Source:        source,
+		EventUuid:     dbRecord.Uuid,
+		AggregateUuid: dbRecord.AggregateUuid,
+		Version:       dbRecord.Version,
+	}
+
+	var existing internal.Event
+	query := fmt.Sprintf("SELECT %s FROM events WHERE uuid=? LIMIT 1;", eventSelectColumns)
+	err := scanEvent(tx.QueryRowContext(ctx, query, dbRecord.Uuid), &existing)
+	switch {
+	case err == sql.ErrNoRows:
+	case err != nil:
+		return err
+	case sameEventRecord(&existing, dbRecord):
+		report.Duplicates++
+		return nil
+	default:
+		conflict.Reason = "event uuid exists with different content"
+		conflict.Skipped = true
+		report.Conflicts = append(report.Conflicts, conflict)
+		return nil
+	}
+
+	var other string
+	query = "SELECT uuid FROM event_records WHERE aggregate_uuid=? AND version=? LIMIT 1;"
+	err = tx.QueryRowContext(ctx, query, dbRecord.AggregateUuid, dbRecord.Version).Scan(&other)
+	switch {
+	case err == sql.ErrNoRows:
+	case err != nil:
+		return err
+	default:
+		conflict.Reason = fmt.Sprintf("aggregate version is used by event '%s'", other)
+		report.Conflicts = append(report.Conflicts, conflict)
+	}
+
+	if err := insertEventRecord(ctx, tx, sql.NullInt64{}, dbRecord); err != nil {
+		return err
+	}
+	report.Merged++
+	return nil
+}
+
+// sameEventRecord compares two records ignoring their ids.
+func sameEventRecord(a, b *internal.Event) bool {
+	return a.InstanceId == b.InstanceId &&
+		a.TenantUuid == b.TenantUuid &&
+		a.WorkspaceUuid == b.WorkspaceUuid &&
+		a.CommandUuid == b.CommandUuid &&
+		a.Domain == b.Domain &&
+		a.AggregateUuid == b.AggregateUuid &&
+		a.Version == b.Version &&
+		a.CreatedAt == b.CreatedAt &&
+		a.DataType == b.DataType &&
+		bytes.Equal(a.DataBytes, b.DataBytes) &&
+		a.Checksum == b.Checksum
+}
+
+// mergeCursor iterates the events of a source file in created_at order.
+type mergeCursor struct {
+	source string
+	es     *eventStoreSQLite
+	rows   *sql.Rows
+	next   *internal.Event
+}
+
+func openMergeCursor(ctx context.Context, source string) (*mergeCursor, error) {
+	// do not create missing sources
+	if _, err := os.Stat(source); err != nil {
+		return nil, err
+	}
+	es := NewEventStoreSQLite(source).(*eventStoreSQLite)
+	readOnly := func(opt *comby.EventStoreOptions) (*comby.EventStoreOptions, error) {
+		opt.ReadOnly = true
+		return opt, nil
+	}
+	if err := es.Init(ctx, readOnly); err != nil {
+		if es.db != nil {
+			es.db.Close()
+		}
+		return nil, err
+	}
+	c := &mergeCursor{source: source, es: es}
+
+	query := fmt.Sprintf("SELECT %s FROM events ORDER BY created_at ASC, id ASC;", eventSelectColumns)
+	rows, err := es.db.QueryContext(ctx, query)
+	if err != nil {
+		c.close(ctx)
+		return nil, err
+	}
+	c.rows = rows
+	if err := c.advance(); err != nil {
+		c.close(ctx)
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *mergeCursor) advance() error {
+	c.next = nil
+	if !c.rows.Next() {
+		return c.rows.Err()
+	}
+	var dbRecord internal.Event
+	if err := scanEvent(c.rows, &dbRecord); err != nil {
+		return err
+	}
+	c.next = &dbRecord
+	return nil
+}
+
+func (c *mergeCursor) close(ctx context.Context) {
+	if c.rows != nil {
+		c.rows.Close()
+	}
+	c.es.Close(ctx)
+}
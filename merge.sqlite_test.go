@@ -0,0 +1,98 @@
+package store_test
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	store "github.com/gradientzero/comby-store-sqlite"
+	"github.com/gradientzero/comby/v3"
+)
+
+func TestMergeStores(t *testing.T) {
+	ctx := context.Background()
+	tmpDir := t.TempDir()
+
+	shared := createTestEvent("tenant-1", "domain-1", 1, 100)
+	conflicting := createTestEvent("tenant-1", "domain-1", 9, 900)
+	sources := map[string][]comby.Event{
+		"edge-1.db": {
+			shared,
+			createTestEvent("tenant-1", "domain-1", 3, 300),
+		},
+		"edge-2.db": {
+			createTestEvent("tenant-1", "domain-1", 2, 200),
+			shared,
+			createTestEvent("tenant-1", "domain-1", 4, 400),
+		},
+	}
+	var srcs []string
+	for _, name := range []string{"edge-1.db", "edge-2.db"} {
+		path := filepath.Join(tmpDir, name)
+		srcStore := store.NewEventStoreSQLite(path)
+		if err := srcStore.Init(ctx); err != nil {
+			t.Fatal(err)
+		}
+		for _, evt := range sources[name] {
+			if err := srcStore.Create(ctx, comby.EventStoreCreateOptionWithEvent(evt)); err != nil {
+				t.Fatal(err)
+			}
+		}
+		srcStore.Close(ctx)
+		srcs = append(srcs, path)
+	}
+
+	// the destination knows the conflicting uuid with a different payload
+	dstStore := store.NewEventStoreSQLite(filepath.Join(tmpDir, "central.db"))
+	if err := dstStore.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer dstStore.Close(ctx)
+	if err := dstStore.Create(ctx, comby.EventStoreCreateOptionWithEvent(conflicting)); err != nil {
+		t.Fatal(err)
+	}
+	edge3 := store.NewEventStoreSQLite(filepath.Join(tmpDir, "edge-3.db"))
+	if err := edge3.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	conflicting.SetDomainEvtBytes([]byte("other-data"))
+	if err := edge3.Create(ctx, comby.EventStoreCreateOptionWithEvent(conflicting)); err != nil {
+		t.Fatal(err)
+	}
+	edge3.Close(ctx)
+	srcs = append(srcs, filepath.Join(tmpDir, "edge-3.db"))
+
+	report, err := store.MergeStores(ctx, dstStore, srcs...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Merged != 4 || report.Duplicates != 1 {
+		t.Fatalf("unexpected report: %+v", report)
+	}
+	if len(report.Conflicts) != 1 || report.Conflicts[0].EventUuid != conflicting.GetEventUuid() || !report.Conflicts[0].Skipped {
+		t.Fatalf("expected conflict for %s, got %+v", conflicting.GetEventUuid(), report.Conflicts)
+	}
+
+	// merged events follow created_at in the store order
+	var versions []int64
+	if _, err := dstStore.(store.EventStoreSQLite).Replay(ctx, func(ctx context.Context, seq int64, evt comby.Event) error {
+		versions = append(versions, evt.GetVersion())
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	want := []int64{9, 1, 2, 3, 4}
+	if len(versions) != len(want) {
+		t.Fatalf("expected versions %v, got %v", want, versions)
+	}
+	for i := range want {
+		if versions[i] != want[i] {
+			t.Fatalf("expected versions %v, got %v", want, versions)
+		}
+	}
+
+	// missing sources are not created
+	if _, err := store.MergeStores(ctx, dstStore, filepath.Join(tmpDir, "missing.db")); err == nil {
+		t.Fatal("expected error for missing source")
+	}
+}
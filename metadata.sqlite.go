@@ -0,0 +1,34 @@
+package store
+
+import (
+	"context"
+
+	"github.com/gradientzero/comby-store-sqlite/internal"
+	"github.com/gradientzero/comby/v3"
+)
+
+// ListMetadata accepts the same options as List and returns the same events,
+// but without reading their payloads: GetDomainEvtBytes is nil and nothing is
+// decrypted or verified. It suits listings and sync planning which only look
+// at uuids, types, versions and timestamps. Events are read from the live
+// store only, neither the cache nor an archive read-through is used.
+func (es *eventStoreSQLite) ListMetadata(ctx context.Context, opts ...comby.EventStoreListOption) ([]comby.Event, int64, error) {
+	ctx, cancel := es.cfg().Timeouts.read(ctx)
+	defer cancel()
+	listOpts, filter, err := es.listOptions(opts)
+	if err != nil {
+		return nil, 0, err
+	}
+	if err := es.authorizeList(ctx, listOpts, filter); err != nil {
+		return nil, 0, err
+	}
+	dbRecords, total, err := es.queryRecords(ctx, es.db, "events", eventMetadataColumns, listOpts, filter)
+	if err != nil {
+		return nil, 0, classifyError(err)
+	}
+	evts, err := internal.DbEventsToBaseEvents(dbRecords)
+	if err != nil {
+		return nil, 0, err
+	}
+	return evts, total, nil
+}
@@ -0,0 +1,59 @@
+package store_test
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	store "github.com/gradientzero/comby-store-sqlite"
+	"github.com/gradientzero/comby/v3"
+)
+
+func TestEventStoreListMetadata(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "metadata.db")
+
+	cryptoService, _ := comby.NewCryptoService([]byte("12345678901234567890123456789012"))
+	eventStore := store.NewEventStoreSQLite(path)
+	if err := eventStore.Init(ctx, comby.EventStoreOptionWithCryptoService(cryptoService)); err != nil {
+		t.Fatal(err)
+	}
+	defer eventStore.Close(ctx)
+	for i := int64(1); i <= 3; i++ {
+		evt := createTestEvent("tenant-1", "domain-1", i, i*100)
+		if err := eventStore.Create(ctx, comby.EventStoreCreateOptionWithEvent(evt)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// a store with another key can not decrypt payloads, but list their metadata
+	otherService, _ := comby.NewCryptoService([]byte("abcdefghijklmnopqrstuvwxyz123456"))
+	otherStore := store.NewEventStoreSQLite(path)
+	if err := otherStore.Init(ctx, comby.EventStoreOptionWithCryptoService(otherService)); err != nil {
+		t.Fatal(err)
+	}
+	defer otherStore.Close(ctx)
+	if _, _, err := otherStore.List(ctx); err == nil {
+		t.Fatal("expected list with wrong key to fail")
+	}
+
+	evts, total, err := otherStore.ListMetadata(ctx, func(opts *comby.EventStoreListOptions) (*comby.EventStoreListOptions, error) {
+		opts.After = 100
+		return opts, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 2 || len(evts) != 2 {
+		t.Fatalf("unexpected metadata listing: %d of %d", len(evts), total)
+	}
+	for i, evt := range evts {
+		if evt.GetVersion() != int64(i+2) || evt.GetTenantUuid() != "tenant-1" || evt.GetDomainEvtName() != fmt.Sprintf("TestEvent_%d", i+2) {
+			t.Fatalf("unexpected event metadata: %+v", evt)
+		}
+		if evt.GetDomainEvtBytes() != nil {
+			t.Fatalf("expected no payload, got %q", evt.GetDomainEvtBytes())
+		}
+	}
+}
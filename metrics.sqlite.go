@@ -0,0 +1,232 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// interval of writing the counters of a store to the metrics table
+const metricsFlushInterval = 10 * time.Second
+
+// MetricsMinute counts the operations of a store within one minute.
+type MetricsMinute struct {
+	// start of the minute
+	Minute time.Time
+	// Create, Update and Delete
+	Writes int64
+	// Get, List and UniqueList
+	Reads int64
+	// failed operations, also counted as writes or reads
+	Errors int64
+}
+
+// EventStoreSQLiteWithMetrics counts reads and writes per minute in the
+// ops_metrics table, which keeps the last window of minutes like a ring
+// buffer, see Metrics. Counters are written every 10 seconds and on Close;
+// processes sharing the file add up. Read-only stores only read the table.
+func EventStoreSQLiteWithMetrics(window time.Duration) EventStoreSQLiteOption {
+	return func(c *eventStoreSQLiteConfig) { c.Metrics = newOpsMetrics(window) }
+}
+
+// CommandStoreSQLiteWithMetrics counts reads and writes of the command store
+// per minute, see EventStoreSQLiteWithMetrics.
+func CommandStoreSQLiteWithMetrics(window time.Duration) CommandStoreSQLiteOption {
+	return func(c *commandStoreSQLiteConfig) { c.Metrics = newOpsMetrics(window) }
+}
+
+var metricsTables = []strictTable{
+	{
+		name: "ops_metrics",
+		columns: `store TEXT NOT NULL,
+		slot INTEGER NOT NULL,
+		minute INTEGER NOT NULL,
+		writes INTEGER NOT NULL,
+		reads INTEGER NOT NULL,
+		errors INTEGER NOT NULL,
+		PRIMARY KEY (store, slot)`,
+		copyColumns: `store, slot, minute, writes, reads, errors`,
+	},
+}
+
+// opsMetrics collects the counters of the current minutes until they are
+// flushed. A nil value counts nothing.
+type opsMetrics struct {
+	// number of minutes kept, the slots of the ring
+	Minutes int64
+
+	mu sync.Mutex
+	// unflushed counters by unix minute
+	pending map[int64]*MetricsMinute
+}
+
+func newOpsMetrics(window time.Duration) *opsMetrics {
+	if window <= 0 {
+		return nil
+	}
+	return &opsMetrics{Minutes: int64((window + time.Minute - 1) / time.Minute)}
+}
+
+// record counts an operation, see watch for the names.
+func (m *opsMetrics) record(op string, err error) {
+	if m == nil {
+		return
+	}
+	minute := time.Now().Unix() / 60
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.pending == nil {
+		m.pending = map[int64]*MetricsMinute{}
+	}
+	counts := m.pending[minute]
+	if counts == nil {
+		counts = &MetricsMinute{}
+		m.pending[minute] = counts
+	}
+	switch op {
+	case "create", "update", "delete":
+		counts.Writes++
+	default:
+		counts.Reads++
+	}
+	if err != nil {
+		counts.Errors++
+	}
+}
+
+// flush adds the pending counters to the rows of their slots. Rows of older
+// minutes in the same slot are replaced. Counters which could not be written
+// are kept for the next flush.
+func (m *opsMetrics) flush(ctx context.Context, db *sql.DB, writeMu *sync.Mutex, store string) error {
+	if m == nil {
+		return nil
+	}
+	m.mu.Lock()
+	pending := m.pending
+	m.pending = nil
+	m.mu.Unlock()
+	if len(pending) == 0 {
+		return nil
+	}
+
+	writeMu.Lock()
+	defer writeMu.Unlock()
+	err := runTx(ctx, db, func(tx *sql.Tx) error {
+		query := `INSERT INTO ops_metrics (store, slot, minute, writes, reads, errors) VALUES (?, ?, ?, ?, ?, ?)
+			ON CONFLICT(store, slot) DO UPDATE SET
+				writes=CASE WHEN ops_metrics.minute=excluded.minute THEN ops_metrics.writes+excluded.writes ELSE excluded.writes END,
+				reads=CASE WHEN ops_metrics.minute=excluded.minute THEN ops_metrics.reads+excluded.reads ELSE excluded.reads END,
+				errors=CASE WHEN ops_metrics.minute=excluded.minute THEN ops_metrics.errors+excluded.errors ELSE excluded.errors END,
+				minute=excluded.minute
+			WHERE ops_metrics.minute<=excluded.minute;`
+		for minute, counts := range pending {
+			if _, err := tx.ExecContext(ctx, query, store, minute%m.Minutes, minute, counts.Writes, counts.Reads, counts.Errors); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		if m.pending == nil {
+			m.pending = map[int64]*MetricsMinute{}
+		}
+		for minute, counts := range pending {
+			if current := m.pending[minute]; current != nil {
+				current.Writes += counts.Writes
+				current.Reads += counts.Reads
+				current.Errors += counts.Errors
+			} else {
+				m.pending[minute] = counts
+			}
+		}
+	}
+	return err
+}
+
+// history returns one entry per minute of the window up to the current one,
+// oldest first. Minutes without operations have zero counters.
+func (m *opsMetrics) history(ctx context.Context, q queryer, store string) ([]MetricsMinute, error) {
+	now := time.Now().Unix() / 60
+	first := now - m.Minutes + 1
+	rows, err := q.QueryContext(ctx, `SELECT minute, SUM(writes), SUM(reads), SUM(errors) FROM ops_metrics
+		WHERE store=? AND minute>=? AND minute<=? GROUP BY minute;`, store, first, now)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	history := make([]MetricsMinute, m.Minutes)
+	for i := range history {
+		history[i].Minute = time.Unix((first+int64(i))*60, 0)
+	}
+	for rows.Next() {
+		var minute int64
+		var counts MetricsMinute
+		if err := rows.Scan(&minute, &counts.Writes, &counts.Reads, &counts.Errors); err != nil {
+			return nil, err
+		}
+		counts.Minute = history[minute-first].Minute
+		history[minute-first] = counts
+	}
+	return history, rows.Err()
+}
+
+func (es *eventStoreSQLite) initMetrics() {
+	if es.cfg().Metrics == nil {
+		return
+	}
+	es.metricsFlusher.stop()
+	es.metricsFlusher = startMaintenance(metricsFlushInterval, loggerOrDiscard(es.cfg().Logger), "metrics flush", func(ctx context.Context) error {
+		return es.cfg().Metrics.flush(ctx, es.db, es.writeMu, "events")
+	})
+}
+
+// Metrics returns the reads and writes per minute of the metrics window, see
+// EventStoreSQLiteWithMetrics.
+func (es *eventStoreSQLite) Metrics(ctx context.Context) ([]MetricsMinute, error) {
+	metrics := es.cfg().Metrics
+	if metrics == nil {
+		return nil, fmt.Errorf("'%s' failed to read metrics - metrics are not enabled", es.String())
+	}
+	if !es.opts().ReadOnly {
+		if err := metrics.flush(ctx, es.db, es.writeMu, "events"); err != nil {
+			return nil, fmt.Errorf("'%s' failed to flush metrics - %w", es.String(), classifyError(err))
+		}
+	}
+	history, err := metrics.history(ctx, es.db, "events")
+	if err != nil {
+		return nil, fmt.Errorf("'%s' failed to read metrics - %w", es.String(), classifyError(err))
+	}
+	return history, nil
+}
+
+func (cs *commandStoreSQLite) initMetrics() {
+	if cs.cfg().Metrics == nil {
+		return
+	}
+	cs.metricsFlusher.stop()
+	cs.metricsFlusher = startMaintenance(metricsFlushInterval, loggerOrDiscard(cs.cfg().Logger), "metrics flush", func(ctx context.Context) error {
+		return cs.cfg().Metrics.flush(ctx, cs.db, cs.writeMu, "commands")
+	})
+}
+
+// Metrics returns the reads and writes per minute of the command store.
+func (cs *commandStoreSQLite) Metrics(ctx context.Context) ([]MetricsMinute, error) {
+	metrics := cs.cfg().Metrics
+	if metrics == nil {
+		return nil, fmt.Errorf("'%s' failed to read metrics - metrics are not enabled", cs.String())
+	}
+	if !cs.opts().ReadOnly {
+		if err := metrics.flush(ctx, cs.db, cs.writeMu, "commands"); err != nil {
+			return nil, fmt.Errorf("'%s' failed to flush metrics - %w", cs.String(), classifyError(err))
+		}
+	}
+	history, err := metrics.history(ctx, cs.db, "commands")
+	if err != nil {
+		return nil, fmt.Errorf("'%s' failed to read metrics - %w", cs.String(), classifyError(err))
+	}
+	return history, nil
+}
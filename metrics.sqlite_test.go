@@ -0,0 +1,131 @@
+package store_test
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	store "github.com/gradientzero/comby-store-sqlite"
+	"github.com/gradientzero/comby/v3"
+)
+
+// sumMetrics adds up the minutes, a test may cross a minute boundary.
+func sumMetrics(history []store.MetricsMinute) (writes, reads, failed int64) {
+	for _, minute := range history {
+		writes += minute.Writes
+		reads += minute.Reads
+		failed += minute.Errors
+	}
+	return writes, reads, failed
+}
+
+func TestEventStoreMetrics(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "events.db")
+	eventStore := store.NewEventStoreSQLite(path)
+	eventStore.Configure(
+		store.EventStoreSQLiteWithMetrics(time.Hour),
+		store.EventStoreSQLiteWithAuthorizer(func(ctx context.Context, req store.AccessRequest) error {
+			if req.Operation == store.OperationDelete {
+				return errors.New("deletes are not allowed")
+			}
+			return nil
+		}),
+	)
+	if err := eventStore.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	for i := range 3 {
+		evt := createTestEvent("tenant-1", "domain-1", int64(i+1), 100)
+		if err := eventStore.Create(ctx, comby.EventStoreCreateOptionWithEvent(evt)); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := eventStore.Get(ctx, comby.EventStoreGetOptionWithEventUuid(evt.GetEventUuid())); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, _, err := eventStore.List(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := eventStore.Delete(ctx, comby.EventStoreDeleteOptionWithEventUuid("unknown")); err == nil {
+		t.Fatal("expected the delete to be denied")
+	}
+
+	history, err := eventStore.Metrics(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 60 {
+		t.Fatalf("expected 60 minutes, got %d", len(history))
+	}
+	for i := 1; i < len(history); i++ {
+		if history[i].Minute.Sub(history[i-1].Minute) != time.Minute {
+			t.Fatalf("expected consecutive minutes, got %s and %s", history[i-1].Minute, history[i].Minute)
+		}
+	}
+	if last := history[len(history)-1].Minute; time.Since(last) < 0 || time.Since(last) >= time.Minute {
+		t.Fatalf("expected the current minute last, got %s", last)
+	}
+	if writes, reads, failed := sumMetrics(history); writes != 4 || reads != 4 || failed != 1 {
+		t.Fatalf("expected 4 writes, 4 reads and 1 error, got %d %d %d", writes, reads, failed)
+	}
+
+	// counters are flushed on Close and add up across processes
+	evt := createTestEvent("tenant-1", "domain-1", 4, 100)
+	if err := eventStore.Create(ctx, comby.EventStoreCreateOptionWithEvent(evt)); err != nil {
+		t.Fatal(err)
+	}
+	eventStore.Close(ctx)
+	eventStore = store.NewEventStoreSQLite(path)
+	eventStore.Configure(store.EventStoreSQLiteWithMetrics(time.Hour))
+	if err := eventStore.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer eventStore.Close(ctx)
+	history, err = eventStore.Metrics(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if writes, reads, _ := sumMetrics(history); writes != 5 || reads != 4 {
+		t.Fatalf("expected 5 writes and 4 reads, got %d %d", writes, reads)
+	}
+}
+
+func TestCommandStoreMetrics(t *testing.T) {
+	ctx := context.Background()
+	commandStore := store.NewCommandStoreSQLite(filepath.Join(t.TempDir(), "commands.db"))
+	commandStore.Configure(store.CommandStoreSQLiteWithMetrics(90 * time.Second))
+	if err := commandStore.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer commandStore.Close(ctx)
+	cmd := createTestCommand("tenant-1", "domain-1", 100)
+	if err := commandStore.Create(ctx, comby.CommandStoreCreateOptionWithCommand(cmd)); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := commandStore.List(ctx); err != nil {
+		t.Fatal(err)
+	}
+	history, err := commandStore.Metrics(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the window is rounded up to whole minutes
+	if len(history) != 2 {
+		t.Fatalf("expected 2 minutes, got %d", len(history))
+	}
+	if writes, reads, failed := sumMetrics(history); writes != 1 || reads != 1 || failed != 0 {
+		t.Fatalf("expected 1 write and 1 read, got %d %d %d", writes, reads, failed)
+	}
+
+	other := store.NewCommandStoreSQLite(filepath.Join(t.TempDir(), "other.db"))
+	if err := other.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer other.Close(ctx)
+	if _, err := other.Metrics(ctx); err == nil {
+		t.Fatal("expected an error without metrics")
+	}
+}
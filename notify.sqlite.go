@@ -0,0 +1,107 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// The sqlite driver has no update hook, so writes are signalled by the store
+// after they finished. Writes of other processes are only seen by polling,
+// see EventStoreSQLiteWithChangePolling.
+
+// changeNotifier signals subscribers after writes. Signals are coalesced, a
+// subscriber which did not receive the previous one yet gets no second one.
+type changeNotifier struct {
+	mu          sync.Mutex
+	subscribers map[chan struct{}]struct{}
+}
+
+func (n *changeNotifier) subscribe() (<-chan struct{}, func()) {
+	ch := make(chan struct{}, 1)
+	n.mu.Lock()
+	if n.subscribers == nil {
+		n.subscribers = map[chan struct{}]struct{}{}
+	}
+	n.subscribers[ch] = struct{}{}
+	n.mu.Unlock()
+	return ch, func() {
+		n.mu.Lock()
+		delete(n.subscribers, ch)
+		n.mu.Unlock()
+	}
+}
+
+func (n *changeNotifier) notify() {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	for ch := range n.subscribers {
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// pollChanges starts a loop signalling n when the highest id of table changed,
+// e.g. by inserts of another process.
+func pollChanges(db *sql.DB, table string, interval time.Duration, logger *slog.Logger, loop *maintenanceLoop, n *changeNotifier) *maintenanceLoop {
+	loop.stop()
+	if interval <= 0 {
+		return nil
+	}
+	query := fmt.Sprintf("SELECT COALESCE(MAX(id), 0) FROM %s;", table)
+	last := int64(-1)
+	return startMaintenance(interval, logger, "change polling", func(ctx context.Context) error {
+		var seq int64
+		if err := db.QueryRowContext(ctx, query).Scan(&seq); err != nil {
+			return classifyError(err)
+		}
+		if last >= 0 && seq != last {
+			n.notify()
+		}
+		last = seq
+		return nil
+	})
+}
+
+// EventStoreSQLiteWithChangePolling checks for events inserted by other
+// processes every interval and signals them to change subscribers. Writes
+// of the store itself are signalled right away without polling.
+func EventStoreSQLiteWithChangePolling(interval time.Duration) EventStoreSQLiteOption {
+	return func(c *eventStoreSQLiteConfig) { c.ChangePolling = interval }
+}
+
+// CommandStoreSQLiteWithChangePolling checks for commands inserted by other
+// processes every interval, see EventStoreSQLiteWithChangePolling.
+func CommandStoreSQLiteWithChangePolling(interval time.Duration) CommandStoreSQLiteOption {
+	return func(c *commandStoreSQLiteConfig) { c.ChangePolling = interval }
+}
+
+// SubscribeChanges returns a channel which is signalled after events were
+// written and a func to unsubscribe. It lets in-process consumers, like a
+// Projector, react right away instead of waiting for their poll interval.
+// Signals carry no data, are coalesced and may be spurious (e.g. for a
+// failed write), so subscribers read the store to find what changed.
+func (es *eventStoreSQLite) SubscribeChanges() (<-chan struct{}, func()) {
+	return es.changes.subscribe()
+}
+
+func (es *eventStoreSQLite) initChangePolling() {
+	config := es.cfg()
+	es.changePoller = pollChanges(es.db, "event_records", config.ChangePolling, loggerOrDiscard(config.Logger), es.changePoller, &es.changes)
+}
+
+// SubscribeChanges returns a channel which is signalled after commands were
+// written, see SubscribeChanges of the event store.
+func (cs *commandStoreSQLite) SubscribeChanges() (<-chan struct{}, func()) {
+	return cs.changes.subscribe()
+}
+
+func (cs *commandStoreSQLite) initChangePolling() {
+	config := cs.cfg()
+	cs.changePoller = pollChanges(cs.db, "commands", config.ChangePolling, loggerOrDiscard(config.Logger), cs.changePoller, &cs.changes)
+}
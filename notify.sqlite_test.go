@@ -0,0 +1,129 @@
+package store_test
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	store "github.com/gradientzero/comby-store-sqlite"
+	"github.com/gradientzero/comby/v3"
+)
+
+func expectSignal(t *testing.T, changes <-chan struct{}, timeout time.Duration) {
+	t.Helper()
+	select {
+	case <-changes:
+	case <-time.After(timeout):
+		t.Fatal("expected a change signal")
+	}
+}
+
+func TestEventStoreSubscribeChanges(t *testing.T) {
+	ctx := context.Background()
+	eventStore := store.NewEventStoreSQLite(filepath.Join(t.TempDir(), "notify.db"))
+	if err := eventStore.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer eventStore.Close(ctx)
+
+	changes, unsubscribe := eventStore.SubscribeChanges()
+	if err := eventStore.Create(ctx, comby.EventStoreCreateOptionWithEvent(createTestEvent("tenant-1", "domain-1", 1, 1))); err != nil {
+		t.Fatal(err)
+	}
+	expectSignal(t, changes, time.Second)
+
+	// signals are coalesced
+	for i := int64(2); i <= 3; i++ {
+		if err := eventStore.Create(ctx, comby.EventStoreCreateOptionWithEvent(createTestEvent("tenant-1", "domain-1", i, i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	expectSignal(t, changes, time.Second)
+	select {
+	case <-changes:
+		t.Fatal("expected signals to be coalesced")
+	default:
+	}
+
+	unsubscribe()
+	if err := eventStore.Create(ctx, comby.EventStoreCreateOptionWithEvent(createTestEvent("tenant-1", "domain-1", 4, 4))); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-changes:
+		t.Fatal("expected no signal after unsubscribe")
+	default:
+	}
+}
+
+func TestCommandStoreChangePolling(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "notify.db")
+	commandStore := store.NewCommandStoreSQLite(path)
+	commandStore.Configure(store.CommandStoreSQLiteWithChangePolling(10 * time.Millisecond))
+	if err := commandStore.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer commandStore.Close(ctx)
+	changes, unsubscribe := commandStore.SubscribeChanges()
+	defer unsubscribe()
+
+	// another process inserts behind the store's back
+	writer := store.NewCommandStoreSQLite(path)
+	if err := writer.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer writer.Close(ctx)
+	time.Sleep(30 * time.Millisecond)
+	if err := writer.Create(ctx, comby.CommandStoreCreateOptionWithCommand(createTestCommand("tenant-1", "domain-1", 1))); err != nil {
+		t.Fatal(err)
+	}
+	expectSignal(t, changes, time.Second)
+}
+
+func TestProjectorRunOnChanges(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	path := filepath.Join(t.TempDir(), "notify.db")
+	eventStore := store.NewEventStoreSQLite(path)
+	if err := eventStore.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer eventStore.Close(ctx)
+
+	var applied atomic.Int64
+	projector, err := store.NewProjector(eventStore, store.Projection{
+		Name: "count",
+		Apply: func(ctx context.Context, tx *sql.Tx, evt comby.Event) error {
+			applied.Add(1)
+			return nil
+		},
+	}, store.ProjectorWithPollInterval(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := projector.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		projector.Run(ctx)
+	}()
+
+	if err := eventStore.Create(ctx, comby.EventStoreCreateOptionWithEvent(createTestEvent("tenant-1", "domain-1", 1, 1))); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(time.Second)
+	for applied.Load() != 1 {
+		if time.Now().After(deadline) {
+			t.Fatal("expected the event to be applied without waiting for the poll interval")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	cancel()
+	<-done
+}
@@ -0,0 +1,223 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/gradientzero/comby/v3"
+)
+
+// Stores holds the stores created by Open. Stores which were not requested are nil.
+// All stores share one database connection pool which is closed by Close.
+type Stores struct {
+	EventStore    EventStoreSQLite
+	CommandStore  CommandStoreSQLite
+	SnapshotStore comby.SnapshotStore
+
+	db      *sql.DB
+	writeMu *sync.Mutex
+	path    string
+	// periodic validation of the shared pool, if configured
+	keepalive *maintenanceLoop
+}
+
+// OpenOption configures Open.
+type OpenOption func(*openConfig)
+
+type openConfig struct {
+	EventStore    bool
+	EventOpts     []EventStoreSQLiteOption
+	CommandStore  bool
+	CommandOpts   []CommandStoreSQLiteOption
+	SnapshotStore bool
+	SnapshotOpts  []SnapshotStoreSQLiteOption
+	CryptoService *comby.CryptoService
+	KeyProvider   KeyProvider
+	Logger        *slog.Logger
+	MaxOpenConns  int
+	// idle limits and lifetime of the connections of the shared pool
+	MaxIdleConns    int
+	ConnMaxIdleTime time.Duration
+	ConnMaxLifetime time.Duration
+	Keepalive       time.Duration
+	DriverName      string
+	// read-only stores with the analytic profile, see OpenAnalytic
+	Analytic bool
+}
+
+// WithEventStore creates an event store.
+func WithEventStore(opts ...EventStoreSQLiteOption) OpenOption {
+	return func(c *openConfig) {
+		c.EventStore = true
+		c.EventOpts = append(c.EventOpts, opts...)
+	}
+}
+
+// WithCommandStore creates a command store.
+func WithCommandStore(opts ...CommandStoreSQLiteOption) OpenOption {
+	return func(c *openConfig) {
+		c.CommandStore = true
+		c.CommandOpts = append(c.CommandOpts, opts...)
+	}
+}
+
+// WithSnapshotStore creates a snapshot store.
+func WithSnapshotStore(opts ...SnapshotStoreSQLiteOption) OpenOption {
+	return func(c *openConfig) {
+		c.SnapshotStore = true
+		c.SnapshotOpts = append(c.SnapshotOpts, opts...)
+	}
+}
+
+// WithCryptoService encrypts payloads of event and command store with the same crypto service.
+func WithCryptoService(cryptoService *comby.CryptoService) OpenOption {
+	return func(c *openConfig) { c.CryptoService = cryptoService }
+}
+
+// WithKeyProvider encrypts payloads of event and command store with the keys of provider.
+func WithKeyProvider(provider KeyProvider) OpenOption {
+	return func(c *openConfig) { c.KeyProvider = provider }
+}
+
+// WithLogger sets the logger of all stores.
+func WithLogger(logger *slog.Logger) OpenOption {
+	return func(c *openConfig) { c.Logger = logger }
+}
+
+// WithMaxOpenConns sets the maximum number of open connections of the shared pool.
+func WithMaxOpenConns(n int) OpenOption {
+	return func(c *openConfig) { c.MaxOpenConns = n }
+}
+
+// WithMaxIdleConns sets the maximum number of idle connections of the shared pool.
+func WithMaxIdleConns(n int) OpenOption {
+	return func(c *openConfig) { c.MaxIdleConns = n }
+}
+
+// WithConnMaxIdleTime closes connections of the shared pool which were idle for d.
+func WithConnMaxIdleTime(d time.Duration) OpenOption {
+	return func(c *openConfig) { c.ConnMaxIdleTime = d }
+}
+
+// WithConnMaxLifetime closes connections of the shared pool after d, so long
+// running services reopen the database file from time to time.
+func WithConnMaxLifetime(d time.Duration) OpenOption {
+	return func(c *openConfig) { c.ConnMaxLifetime = d }
+}
+
+// Open creates the requested stores on top of one shared connection to the
+// database at path. The stores still have to be initialized, either by
+// comby or by calling Init.
+func Open(path string, opts ...OpenOption) (*Stores, error) {
+	config := openConfig{}
+	for _, opt := range opts {
+		opt(&config)
+	}
+	if !config.EventStore && !config.CommandStore && !config.SnapshotStore {
+		return nil, fmt.Errorf("'sqlite - %s' failed to open - no store requested", path)
+	}
+
+	// writes of all stores are serialized by one mutex as they share the database lock
+	writeMu := &sync.Mutex{}
+
+	// connection settings follow the event store, which has the highest demands
+	es := &eventStoreSQLite{path: path, sharedDB: sharedDB{writeMu: writeMu}}
+	es.options.MaxOpenConns = config.MaxOpenConns
+	es.options.MaxIdleConns = config.MaxIdleConns
+	es.options.ConnMaxIdleTime = config.ConnMaxIdleTime
+	es.options.ConnMaxLifetime = config.ConnMaxLifetime
+	es.config.DriverName = config.DriverName
+	if config.Analytic {
+		es.options.ReadOnly = true
+		es.config.ReadProfile = analyticProfile
+	}
+	es.Configure(config.EventOpts...)
+	db, err := es.connect(context.Background())
+	if err != nil {
+		return nil, err
+	}
+
+	stores := &Stores{db: db, writeMu: writeMu, path: path}
+	maxIdleConns := func() int { return config.MaxIdleConns }
+	stores.keepalive = startKeepalive(db, path, config.Keepalive, maxIdleConns, loggerOrDiscard(config.Logger), nil)
+	if config.EventStore {
+		es.db = db
+		es.shared = true
+		es.options.CryptoService = config.CryptoService
+		if es.config.KeyProvider == nil {
+			es.config.KeyProvider = config.KeyProvider
+		}
+		if es.config.Logger == nil {
+			es.config.Logger = config.Logger
+		}
+		stores.EventStore = es
+	}
+	if config.CommandStore {
+		cs := &commandStoreSQLite{path: path, db: db, sharedDB: sharedDB{writeMu: writeMu, shared: true}}
+		cs.options.MaxOpenConns = config.MaxOpenConns
+		cs.options.CryptoService = config.CryptoService
+		cs.config.KeyProvider = config.KeyProvider
+		cs.config.Logger = config.Logger
+		if config.Analytic {
+			cs.options.ReadOnly = true
+			cs.config.ReadProfile = analyticProfile
+		}
+		cs.Configure(config.CommandOpts...)
+		stores.CommandStore = cs
+	}
+	if config.SnapshotStore {
+		ss := &snapshotStoreSQLite{path: path, db: db, sharedDB: sharedDB{writeMu: writeMu, shared: true}}
+		ss.config.Logger = config.Logger
+		for _, opt := range config.SnapshotOpts {
+			opt(&ss.config)
+		}
+		stores.SnapshotStore = ss
+	}
+	return stores, nil
+}
+
+// Init initializes all stores.
+func (s *Stores) Init(ctx context.Context) error {
+	if s.EventStore != nil {
+		if err := s.EventStore.Init(ctx); err != nil {
+			return err
+		}
+	}
+	if s.CommandStore != nil {
+		if err := s.CommandStore.Init(ctx); err != nil {
+			return err
+		}
+	}
+	if s.SnapshotStore != nil {
+		if err := s.SnapshotStore.Init(ctx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close closes all stores and the shared connection.
+func (s *Stores) Close(ctx context.Context) error {
+	var errs []error
+	if s.EventStore != nil {
+		errs = append(errs, s.EventStore.Close(ctx))
+	}
+	if s.CommandStore != nil {
+		errs = append(errs, s.CommandStore.Close(ctx))
+	}
+	if s.SnapshotStore != nil {
+		errs = append(errs, s.SnapshotStore.Close(ctx))
+	}
+	s.keepalive.stop()
+	errs = append(errs, s.db.Close())
+	return errors.Join(errs...)
+}
+
+func (s *Stores) String() string {
+	return fmt.Sprintf("sqlite - %s", s.path)
+}
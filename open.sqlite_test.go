@@ -0,0 +1,69 @@
+package store_test
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	store "github.com/gradientzero/comby-store-sqlite"
+	"github.com/gradientzero/comby/v3"
+)
+
+func TestOpen(t *testing.T) {
+	ctx := context.Background()
+
+	key := []byte("12345678901234567890123456789012")
+	cryptoService, _ := comby.NewCryptoService(key)
+
+	stores, err := store.Open(filepath.Join(t.TempDir(), "store.db"),
+		store.WithEventStore(store.EventStoreSQLiteWithChecksumVerification(true)),
+		store.WithCommandStore(),
+		store.WithSnapshotStore(),
+		store.WithCryptoService(cryptoService),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := stores.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	if stores.EventStore.Options().CryptoService == nil || stores.CommandStore.Options().CryptoService == nil {
+		t.Fatal("expected shared crypto service")
+	}
+	evt := createTestEvent("tenant-1", "domain-1", 1, 100)
+	if err := stores.EventStore.Create(ctx, comby.EventStoreCreateOptionWithEvent(evt)); err != nil {
+		t.Fatal(err)
+	}
+	cmd := createTestCommand("tenant-1", "domain-1", 100)
+	if err := stores.CommandStore.Create(ctx, comby.CommandStoreCreateOptionWithCommand(cmd)); err != nil {
+		t.Fatal(err)
+	}
+	if err := stores.SnapshotStore.Save(ctx, &comby.SnapshotStoreModel{
+		AggregateUuid: evt.GetAggregateUuid(),
+		Domain:        "domain-1",
+		Version:       1,
+		Data:          []byte("snapshot"),
+		CreatedAt:     100,
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	// closing a single store keeps the shared connection usable
+	if err := stores.CommandStore.Close(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := stores.EventStore.Get(ctx, comby.EventStoreGetOptionWithEventUuid(evt.GetEventUuid())); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := stores.Close(ctx); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestOpenWithoutStores(t *testing.T) {
+	if _, err := store.Open(filepath.Join(t.TempDir(), "store.db")); err == nil {
+		t.Fatal("expected error without requested stores")
+	}
+}
@@ -0,0 +1,70 @@
+package store
+
+import (
+	"context"
+
+	"github.com/gradientzero/comby-store-sqlite/internal"
+	"github.com/gradientzero/comby/v3"
+)
+
+// ListPage lists events like List but without counting all matching events,
+// which roughly halves the cost of a page for consumers that only page
+// forward. One more event than the limit is fetched to report whether more
+// events follow. Reads bypass the query cache and the archive read-through.
+func (es *eventStoreSQLite) ListPage(ctx context.Context, opts ...comby.EventStoreListOption) ([]comby.Event, bool, error) {
+	ctx, cancel := es.cfg().Timeouts.read(ctx)
+	defer cancel()
+	listOpts, filter, err := es.listOptions(opts)
+	if err != nil {
+		return nil, false, err
+	}
+	if err := es.authorizeList(ctx, listOpts, filter); err != nil {
+		return nil, false, err
+	}
+	filter.SkipTotal = true
+	limit := listOpts.Limit
+	if limit >= 0 {
+		listOpts.Limit = limit + 1
+	}
+	dbRecords, _, err := es.listRecords(ctx, es.db, "events", listOpts, filter)
+	if err != nil {
+		return nil, false, classifyError(err)
+	}
+	hasMore := limit >= 0 && int64(len(dbRecords)) > limit
+	if hasMore {
+		dbRecords = dbRecords[:limit]
+	}
+	evts, err := internal.DbEventsToBaseEvents(dbRecords)
+	if err != nil {
+		return nil, false, err
+	}
+	return evts, hasMore, nil
+}
+
+// ListPage lists commands like List but without counting all matching
+// commands, see ListPage of the event store.
+func (cs *commandStoreSQLite) ListPage(ctx context.Context, opts ...comby.CommandStoreListOption) ([]comby.Command, bool, error) {
+	ctx, cancel := cs.cfg().Timeouts.read(ctx)
+	defer cancel()
+	listOpts, filter, err := cs.listOptions(opts)
+	if err != nil {
+		return nil, false, err
+	}
+	if err := cs.authorizeList(ctx, listOpts, filter); err != nil {
+		return nil, false, err
+	}
+	filter.SkipTotal = true
+	limit := listOpts.Limit
+	if limit >= 0 {
+		listOpts.Limit = limit + 1
+	}
+	cmds, _, err := cs.list(ctx, cs.db, listOpts, filter)
+	if err != nil {
+		return nil, false, classifyError(err)
+	}
+	hasMore := limit >= 0 && int64(len(cmds)) > limit
+	if hasMore {
+		cmds = cmds[:limit]
+	}
+	return cmds, hasMore, nil
+}
@@ -0,0 +1,87 @@
+package store_test
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	store "github.com/gradientzero/comby-store-sqlite"
+	"github.com/gradientzero/comby/v3"
+)
+
+func TestEventStoreListPage(t *testing.T) {
+	ctx := context.Background()
+	eventStore := store.NewEventStoreSQLite(filepath.Join(t.TempDir(), "page.db"))
+	if err := eventStore.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer eventStore.Close(ctx)
+
+	for i := int64(1); i <= 5; i++ {
+		evt := createTestEvent("tenant-1", "domain-1", i, i)
+		if err := eventStore.Create(ctx, comby.EventStoreCreateOptionWithEvent(evt)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	page := func(offset, limit int64) comby.EventStoreListOption {
+		return func(opts *comby.EventStoreListOptions) (*comby.EventStoreListOptions, error) {
+			opts.Offset, opts.Limit = offset, limit
+			return opts, nil
+		}
+	}
+	for _, tc := range []struct {
+		offset, limit int64
+		want          int
+		hasMore       bool
+	}{
+		{0, 2, 2, true},
+		{2, 2, 2, true},
+		{4, 2, 1, false},
+		{3, 2, 2, false},
+	} {
+		evts, hasMore, err := eventStore.ListPage(ctx, page(tc.offset, tc.limit))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(evts) != tc.want || hasMore != tc.hasMore {
+			t.Fatalf("offset %d limit %d: expected %d events (more %v), got %d (more %v)", tc.offset, tc.limit, tc.want, tc.hasMore, len(evts), hasMore)
+		}
+	}
+
+	evts, _, err := eventStore.ListPage(ctx, page(1, 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if evts[0].GetCreatedAt() != 2 {
+		t.Fatalf("expected second event, got created at %d", evts[0].GetCreatedAt())
+	}
+}
+
+func TestCommandStoreListPage(t *testing.T) {
+	ctx := context.Background()
+	commandStore := store.NewCommandStoreSQLite(filepath.Join(t.TempDir(), "page.db"))
+	if err := commandStore.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer commandStore.Close(ctx)
+
+	for i := int64(1); i <= 3; i++ {
+		cmd := createTestCommand("tenant-1", "domain-1", i)
+		if err := commandStore.Create(ctx, comby.CommandStoreCreateOptionWithCommand(cmd)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	limit := func(n int64) comby.CommandStoreListOption {
+		return func(opts *comby.CommandStoreListOptions) (*comby.CommandStoreListOptions, error) {
+			opts.Limit = n
+			return opts, nil
+		}
+	}
+	if cmds, hasMore, err := commandStore.ListPage(ctx, limit(2)); err != nil || len(cmds) != 2 || !hasMore {
+		t.Fatalf("expected 2 commands and more, got %d (more %v), %v", len(cmds), hasMore, err)
+	}
+	if cmds, hasMore, err := commandStore.ListPage(ctx, limit(3)); err != nil || len(cmds) != 3 || hasMore {
+		t.Fatalf("expected 3 commands and no more, got %d (more %v), %v", len(cmds), hasMore, err)
+	}
+}
@@ -0,0 +1,122 @@
+package store
+
+import (
+	"strings"
+
+	"github.com/gradientzero/comby/v3"
+)
+
+// EventStoreListOptionDataTypePrefix lists events whose data type starts with
+// any of the given prefixes, e.g. "Order" for all order events. Prefixes are
+// matched literally and use the data type index, case-insensitively with
+// EventStoreListOptionCaseInsensitive.
+func EventStoreListOptionDataTypePrefix(prefixes ...string) comby.EventStoreListOption {
+	return func(opt *comby.EventStoreListOptions) (*comby.EventStoreListOptions, error) {
+		return opt, sqliteListOption(opt, "data type prefix", func(filter *listFilter) {
+			filter.DataTypePrefixes = append(filter.DataTypePrefixes, prefixes...)
+		})
+	}
+}
+
+// EventStoreListOptionDomainPrefix lists events whose domain starts with any
+// of the given prefixes, see EventStoreListOptionDataTypePrefix.
+func EventStoreListOptionDomainPrefix(prefixes ...string) comby.EventStoreListOption {
+	return func(opt *comby.EventStoreListOptions) (*comby.EventStoreListOptions, error) {
+		return opt, sqliteListOption(opt, "domain prefix", func(filter *listFilter) {
+			filter.DomainPrefixes = append(filter.DomainPrefixes, prefixes...)
+		})
+	}
+}
+
+// EventStoreListOptionDataTypeGlob lists events whose data type matches any of
+// the given GLOB patterns, e.g. "Order*Failed". Patterns are case-sensitive,
+// only a literal prefix before the first wildcard narrows the index scan.
+func EventStoreListOptionDataTypeGlob(patterns ...string) comby.EventStoreListOption {
+	return func(opt *comby.EventStoreListOptions) (*comby.EventStoreListOptions, error) {
+		return opt, sqliteListOption(opt, "data type glob", func(filter *listFilter) {
+			filter.DataTypeGlobs = append(filter.DataTypeGlobs, patterns...)
+		})
+	}
+}
+
+// EventStoreListOptionDomainGlob lists events whose domain matches any of the
+// given GLOB patterns, see EventStoreListOptionDataTypeGlob.
+func EventStoreListOptionDomainGlob(patterns ...string) comby.EventStoreListOption {
+	return func(opt *comby.EventStoreListOptions) (*comby.EventStoreListOptions, error) {
+		return opt, sqliteListOption(opt, "domain glob", func(filter *listFilter) {
+			filter.DomainGlobs = append(filter.DomainGlobs, patterns...)
+		})
+	}
+}
+
+// CommandStoreListOptionDataTypePrefix lists commands whose data type starts
+// with any of the given prefixes, see EventStoreListOptionDataTypePrefix.
+func CommandStoreListOptionDataTypePrefix(prefixes ...string) comby.CommandStoreListOption {
+	return func(opt *comby.CommandStoreListOptions) (*comby.CommandStoreListOptions, error) {
+		return opt, sqliteListOption(opt, "data type prefix", func(filter *listFilter) {
+			filter.DataTypePrefixes = append(filter.DataTypePrefixes, prefixes...)
+		})
+	}
+}
+
+// CommandStoreListOptionDomainPrefix lists commands whose domain starts with
+// any of the given prefixes, see EventStoreListOptionDataTypePrefix.
+func CommandStoreListOptionDomainPrefix(prefixes ...string) comby.CommandStoreListOption {
+	return func(opt *comby.CommandStoreListOptions) (*comby.CommandStoreListOptions, error) {
+		return opt, sqliteListOption(opt, "domain prefix", func(filter *listFilter) {
+			filter.DomainPrefixes = append(filter.DomainPrefixes, prefixes...)
+		})
+	}
+}
+
+// CommandStoreListOptionDataTypeGlob lists commands whose data type matches
+// any of the given GLOB patterns, see EventStoreListOptionDataTypeGlob.
+func CommandStoreListOptionDataTypeGlob(patterns ...string) comby.CommandStoreListOption {
+	return func(opt *comby.CommandStoreListOptions) (*comby.CommandStoreListOptions, error) {
+		return opt, sqliteListOption(opt, "data type glob", func(filter *listFilter) {
+			filter.DataTypeGlobs = append(filter.DataTypeGlobs, patterns...)
+		})
+	}
+}
+
+// CommandStoreListOptionDomainGlob lists commands whose domain matches any of
+// the given GLOB patterns, see EventStoreListOptionDataTypeGlob.
+func CommandStoreListOptionDomainGlob(patterns ...string) comby.CommandStoreListOption {
+	return func(opt *comby.CommandStoreListOptions) (*comby.CommandStoreListOptions, error) {
+		return opt, sqliteListOption(opt, "domain glob", func(filter *listFilter) {
+			filter.DomainGlobs = append(filter.DomainGlobs, patterns...)
+		})
+	}
+}
+
+var (
+	// wildcards of GLOB are escaped as character classes
+	globEscaper = strings.NewReplacer("[", "[[]", "*", "[*]", "?", "[?]")
+	// wildcards of LIKE are escaped with a backslash
+	likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+)
+
+// patternCondition appends a condition matching column against any of the
+// prefixes and GLOB patterns, unless both are empty. Case-sensitive prefixes
+// use GLOB and the BINARY index of the column, case-insensitive ones LIKE and
+// its NOCASE index.
+func (f listFilter) patternCondition(column string, prefixes, globs []string, whereList []string, args []any) ([]string, []any) {
+	var conditions []string
+	for _, prefix := range prefixes {
+		if f.CaseInsensitive {
+			conditions = append(conditions, column+` LIKE ? ESCAPE '\'`)
+			args = append(args, likeEscaper.Replace(prefix)+"%")
+		} else {
+			conditions = append(conditions, column+" GLOB ?")
+			args = append(args, globEscaper.Replace(prefix)+"*")
+		}
+	}
+	for _, glob := range globs {
+		conditions = append(conditions, column+" GLOB ?")
+		args = append(args, glob)
+	}
+	if len(conditions) == 0 {
+		return whereList, args
+	}
+	return append(whereList, "("+strings.Join(conditions, " OR ")+")"), args
+}
@@ -0,0 +1,115 @@
+package store_test
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	store "github.com/gradientzero/comby-store-sqlite"
+	"github.com/gradientzero/comby/v3"
+)
+
+func queryPlan(t *testing.T, db *sql.DB, query string, args ...any) string {
+	t.Helper()
+	rows, err := db.Query("EXPLAIN QUERY PLAN "+query, args...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	var plan []string
+	for rows.Next() {
+		var id, parent, notused int
+		var detail string
+		if err := rows.Scan(&id, &parent, &notused, &detail); err != nil {
+			t.Fatal(err)
+		}
+		plan = append(plan, detail)
+	}
+	return strings.Join(plan, "\n")
+}
+
+func TestEventStoreListPrefixAndGlob(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "pattern.db")
+	eventStore := store.NewEventStoreSQLite(path)
+	if err := eventStore.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer eventStore.Close(ctx)
+
+	for i, tc := range []struct{ domain, dataType string }{
+		{"orders", "OrderPlaced"},
+		{"orders", "OrderFailed"},
+		{"orders", "orderShipped"},
+		{"order_archive", "Order*Imported"},
+		{"billing", "InvoiceFailed"},
+	} {
+		evt := createTestEvent("tenant-1", tc.domain, 1, int64(i+1))
+		evt.SetDomainEvtName(tc.dataType)
+		if err := eventStore.Create(ctx, comby.EventStoreCreateOptionWithEvent(evt)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, tc := range []struct {
+		name     string
+		opts     []comby.EventStoreListOption
+		expected int
+	}{
+		{"prefix", []comby.EventStoreListOption{store.EventStoreListOptionDataTypePrefix("Order")}, 3},
+		{"prefixes", []comby.EventStoreListOption{store.EventStoreListOptionDataTypePrefix("Order", "Invoice")}, 4},
+		{"literal wildcard", []comby.EventStoreListOption{store.EventStoreListOptionDataTypePrefix("Order*")}, 1},
+		{"case-insensitive", []comby.EventStoreListOption{store.EventStoreListOptionDataTypePrefix("order"), store.EventStoreListOptionCaseInsensitive()}, 4},
+		{"literal underscore", []comby.EventStoreListOption{store.EventStoreListOptionDomainPrefix("order_"), store.EventStoreListOptionCaseInsensitive()}, 1},
+		{"domain prefix", []comby.EventStoreListOption{store.EventStoreListOptionDomainPrefix("order")}, 4},
+		{"glob", []comby.EventStoreListOption{store.EventStoreListOptionDataTypeGlob("*Failed")}, 2},
+		{"domain glob", []comby.EventStoreListOption{store.EventStoreListOptionDomainGlob("order?")}, 3},
+		{"combined", []comby.EventStoreListOption{store.EventStoreListOptionDomainPrefix("order"), store.EventStoreListOptionDataTypeGlob("*Failed")}, 1},
+	} {
+		evts, total, err := eventStore.List(ctx, tc.opts...)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		if len(evts) != tc.expected || total != int64(tc.expected) {
+			t.Fatalf("%s: expected %d events, got %d (total %d)", tc.name, tc.expected, len(evts), total)
+		}
+	}
+
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if plan := queryPlan(t, db, "SELECT id FROM events WHERE (data_type GLOB ?);", "Order*"); !strings.Contains(plan, "event_records_data_type_index") {
+		t.Fatalf("expected prefix to use the data type index, got %s", plan)
+	}
+	if plan := queryPlan(t, db, `SELECT id FROM events WHERE (data_type LIKE ? ESCAPE '\');`, "order%"); !strings.Contains(plan, "event_records_data_type_nocase_index") {
+		t.Fatalf("expected case-insensitive prefix to use the nocase index, got %s", plan)
+	}
+}
+
+func TestCommandStoreListPrefixAndGlob(t *testing.T) {
+	ctx := context.Background()
+	commandStore := store.NewCommandStoreSQLite(filepath.Join(t.TempDir(), "pattern.db"))
+	if err := commandStore.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer commandStore.Close(ctx)
+
+	for i, domain := range []string{"orders", "order-archive", "billing"} {
+		if err := commandStore.Create(ctx, comby.CommandStoreCreateOptionWithCommand(createTestCommand("tenant-1", domain, int64(i+1)))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if cmds, _, err := commandStore.List(ctx, store.CommandStoreListOptionDomainPrefix("order")); err != nil || len(cmds) != 2 {
+		t.Fatalf("expected 2 commands, got %d, %v", len(cmds), err)
+	}
+	if cmds, _, err := commandStore.List(ctx, store.CommandStoreListOptionDomainGlob("*ing")); err != nil || len(cmds) != 1 {
+		t.Fatalf("expected 1 command, got %d, %v", len(cmds), err)
+	}
+	if cmds, _, err := commandStore.List(ctx, store.CommandStoreListOptionDataTypePrefix("Nope")); err != nil || len(cmds) != 0 {
+		t.Fatalf("expected no commands, got %d, %v", len(cmds), err)
+	}
+}
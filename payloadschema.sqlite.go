@@ -0,0 +1,431 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gradientzero/comby/v3"
+)
+
+// ErrPayloadInvalid is matched by every PayloadSchemaError.
+var ErrPayloadInvalid = errors.New("payload does not match its schema")
+
+// PayloadSchemaError is returned by Create for an event whose payload does not
+// match the latest schema registered for its data type. It lists all problems
+// found, e.g. "$.amount: expected number, got string".
+type PayloadSchemaError struct {
+	DataType string
+	Version  int
+	Problems []string
+}
+
+func (e *PayloadSchemaError) Error() string {
+	return fmt.Sprintf("%s: '%s' version %d: %s", ErrPayloadInvalid, e.DataType, e.Version, strings.Join(e.Problems, "; "))
+}
+
+func (e *PayloadSchemaError) Is(target error) bool {
+	return target == ErrPayloadInvalid
+}
+
+// PayloadSchema is a registered version of the JSON schema of a data type.
+type PayloadSchema struct {
+	DataType string
+	// versions start at 1 and increase with every changed schema
+	Version int
+	Schema  json.RawMessage
+	// unix nano
+	CreatedAt int64
+}
+
+// EventStoreSQLiteWithSchemaValidation validates the payload of created
+// events against the latest schema registered for their data type, see
+// SchemaRegistry. Events of data types without a schema are not validated.
+// Schemas are cached by the store, schemas registered by other processes
+// apply after a restart.
+func EventStoreSQLiteWithSchemaValidation() EventStoreSQLiteOption {
+	return func(c *eventStoreSQLiteConfig) { c.SchemaValidation = true }
+}
+
+// SchemaRegistry keeps versioned JSON schemas of event payloads in the event
+// store file. It supports the keywords type, properties, required,
+// additionalProperties, items, enum, minimum, maximum, minLength, maxLength,
+// minItems and maxItems; other keywords like $schema or description are
+// ignored.
+type SchemaRegistry struct {
+	es *eventStoreSQLite
+}
+
+// NewSchemaRegistry returns the schema registry of the event store.
+func NewSchemaRegistry(eventStore comby.EventStore) (*SchemaRegistry, error) {
+	es, ok := eventStore.(*eventStoreSQLite)
+	if !ok {
+		return nil, fmt.Errorf("schema registry requires a sqlite event store")
+	}
+	return &SchemaRegistry{es: es}, nil
+}
+
+var payloadSchemaTables = []strictTable{
+	{
+		name: "payload_schemas",
+		columns: `data_type TEXT NOT NULL,
+		version INTEGER NOT NULL,
+		schema TEXT NOT NULL,
+		created_at INTEGER NOT NULL,
+		PRIMARY KEY (data_type, version)`,
+		copyColumns: `data_type, version, schema, created_at`,
+	},
+}
+
+// Init creates the registry table. The event store must be initialized
+// before. Stores with schema validation create it on Init themselves.
+func (r *SchemaRegistry) Init(ctx context.Context) error {
+	if r.es.db == nil {
+		return fmt.Errorf("'%s' failed to init schema registry - event store is not initialized", r.es.String())
+	}
+	return migrateTx(ctx, r.es.db, func(tx *sql.Tx) error {
+		return migrateStrictTables(ctx, tx, loggerOrDiscard(r.es.cfg().Logger), payloadSchemaTables...)
+	})
+}
+
+// Register adds schema as the next version of the data type and returns it.
+// A schema equal to the latest version is not added again.
+func (r *SchemaRegistry) Register(ctx context.Context, dataType string, schema []byte) (*PayloadSchema, error) {
+	if len(dataType) == 0 {
+		return nil, fmt.Errorf("'%s' failed to register schema - data type is required", r.es.String())
+	}
+	if _, err := compilePayloadSchema(schema); err != nil {
+		return nil, fmt.Errorf("'%s' failed to register schema of '%s' - %w", r.es.String(), dataType, err)
+	}
+	var compacted bytes.Buffer
+	if err := json.Compact(&compacted, schema); err != nil {
+		return nil, err
+	}
+
+	r.es.writeMu.Lock()
+	defer r.es.writeMu.Unlock()
+	latest, err := getPayloadSchema(ctx, r.es.db, dataType, 0)
+	if err != nil {
+		return nil, fmt.Errorf("'%s' failed to register schema of '%s' - %w", r.es.String(), dataType, classifyError(err))
+	}
+	if latest != nil && bytes.Equal(latest.Schema, compacted.Bytes()) {
+		return latest, nil
+	}
+	registered := &PayloadSchema{DataType: dataType, Version: 1, Schema: compacted.Bytes(), CreatedAt: time.Now().UnixNano()}
+	if latest != nil {
+		registered.Version = latest.Version + 1
+	}
+	query := `INSERT INTO payload_schemas (data_type, version, schema, created_at) VALUES (?, ?, ?, ?);`
+	if _, err := r.es.db.ExecContext(ctx, query, registered.DataType, registered.Version, string(registered.Schema), registered.CreatedAt); err != nil {
+		return nil, fmt.Errorf("'%s' failed to register schema of '%s' - %w", r.es.String(), dataType, classifyError(err))
+	}
+	r.es.payloadSchemas.forget(dataType)
+	return registered, nil
+}
+
+// Get returns a version of the schema of the data type, the latest one if
+// version is 0. It returns nil if there is none.
+func (r *SchemaRegistry) Get(ctx context.Context, dataType string, version int) (*PayloadSchema, error) {
+	schema, err := getPayloadSchema(ctx, r.es.db, dataType, version)
+	if err != nil {
+		return nil, fmt.Errorf("'%s' failed to get schema of '%s' - %w", r.es.String(), dataType, classifyError(err))
+	}
+	return schema, nil
+}
+
+// List returns all versions of all schemas, ordered by data type and version.
+func (r *SchemaRegistry) List(ctx context.Context) ([]PayloadSchema, error) {
+	rows, err := r.es.db.QueryContext(ctx, `SELECT data_type, version, schema, created_at FROM payload_schemas ORDER BY data_type ASC, version ASC;`)
+	if err != nil {
+		return nil, fmt.Errorf("'%s' failed to list schemas - %w", r.es.String(), classifyError(err))
+	}
+	defer rows.Close()
+	var schemas []PayloadSchema
+	for rows.Next() {
+		var schema PayloadSchema
+		var raw string
+		if err := rows.Scan(&schema.DataType, &schema.Version, &raw, &schema.CreatedAt); err != nil {
+			return nil, classifyError(err)
+		}
+		schema.Schema = json.RawMessage(raw)
+		schemas = append(schemas, schema)
+	}
+	return schemas, classifyError(rows.Err())
+}
+
+// Validate checks payload against the latest schema of the data type, like
+// Create does with schema validation. Payloads of data types without a schema
+// are valid.
+func (r *SchemaRegistry) Validate(ctx context.Context, dataType string, payload []byte) error {
+	return r.es.validatePayload(ctx, r.es.db, dataType, payload)
+}
+
+func getPayloadSchema(ctx context.Context, q queryer, dataType string, version int) (*PayloadSchema, error) {
+	query := `SELECT version, schema, created_at FROM payload_schemas WHERE data_type=? ORDER BY version DESC LIMIT 1;`
+	args := []any{dataType}
+	if version > 0 {
+		query = `SELECT version, schema, created_at FROM payload_schemas WHERE data_type=? AND version=?;`
+		args = append(args, version)
+	}
+	schema := &PayloadSchema{DataType: dataType}
+	var raw string
+	switch err := q.QueryRowContext(ctx, query, args...).Scan(&schema.Version, &raw, &schema.CreatedAt); {
+	case err == sql.ErrNoRows:
+		return nil, nil
+	case err != nil:
+		return nil, err
+	}
+	schema.Schema = json.RawMessage(raw)
+	return schema, nil
+}
+
+// payloadSchemaCache holds the compiled latest schema per data type, a nil
+// schema for data types without one.
+type payloadSchemaCache struct {
+	mu      sync.RWMutex
+	schemas map[string]*cachedPayloadSchema
+}
+
+type cachedPayloadSchema struct {
+	version int
+	schema  *jsonSchema
+}
+
+func (c *payloadSchemaCache) get(dataType string) (*cachedPayloadSchema, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	cached, ok := c.schemas[dataType]
+	return cached, ok
+}
+
+func (c *payloadSchemaCache) put(dataType string, cached *cachedPayloadSchema) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.schemas == nil {
+		c.schemas = map[string]*cachedPayloadSchema{}
+	}
+	c.schemas[dataType] = cached
+}
+
+func (c *payloadSchemaCache) forget(dataType string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.schemas, dataType)
+}
+
+// validatePayload checks payload against the latest schema of the data type.
+func (es *eventStoreSQLite) validatePayload(ctx context.Context, q queryer, dataType string, payload []byte) error {
+	cached, ok := es.payloadSchemas.get(dataType)
+	if !ok {
+		latest, err := getPayloadSchema(ctx, q, dataType, 0)
+		if err != nil {
+			return classifyError(err)
+		}
+		cached = &cachedPayloadSchema{}
+		if latest != nil {
+			if cached.schema, err = compilePayloadSchema(latest.Schema); err != nil {
+				return fmt.Errorf("schema version %d of '%s' is invalid - %w", latest.Version, dataType, err)
+			}
+			cached.version = latest.Version
+		}
+		es.payloadSchemas.put(dataType, cached)
+	}
+	if cached.schema == nil {
+		return nil
+	}
+	var problems []string
+	decoder := json.NewDecoder(bytes.NewReader(payload))
+	decoder.UseNumber()
+	var value any
+	if err := decoder.Decode(&value); err != nil {
+		problems = append(problems, fmt.Sprintf("$: payload is no JSON - %s", err))
+	} else {
+		cached.schema.validate(value, "$", &problems)
+	}
+	if len(problems) > 0 {
+		return &PayloadSchemaError{DataType: dataType, Version: cached.version, Problems: problems}
+	}
+	return nil
+}
+
+// jsonSchema is the supported subset of JSON schema.
+type jsonSchema struct {
+	Type                 schemaTypes            `json:"type"`
+	Properties           map[string]*jsonSchema `json:"properties"`
+	Required             []string               `json:"required"`
+	AdditionalProperties *bool                  `json:"additionalProperties"`
+	Items                *jsonSchema            `json:"items"`
+	Enum                 []any                  `json:"enum"`
+	Minimum              *float64               `json:"minimum"`
+	Maximum              *float64               `json:"maximum"`
+	MinLength            *int                   `json:"minLength"`
+	MaxLength            *int                   `json:"maxLength"`
+	MinItems             *int                   `json:"minItems"`
+	MaxItems             *int                   `json:"maxItems"`
+}
+
+// schemaTypes is the type keyword, a single type or a list of types.
+type schemaTypes []string
+
+func (t *schemaTypes) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*t = schemaTypes{single}
+		return nil
+	}
+	return json.Unmarshal(data, (*[]string)(t))
+}
+
+var schemaTypeNames = []string{"array", "boolean", "integer", "null", "number", "object", "string"}
+
+func compilePayloadSchema(data []byte) (*jsonSchema, error) {
+	var schema jsonSchema
+	if err := json.Unmarshal(data, &schema); err != nil {
+		return nil, fmt.Errorf("schema is invalid - %w", err)
+	}
+	if err := schema.check("$"); err != nil {
+		return nil, err
+	}
+	return &schema, nil
+}
+
+// check rejects unknown types, which would fail every payload.
+func (s *jsonSchema) check(path string) error {
+	for _, name := range s.Type {
+		if !slices.Contains(schemaTypeNames, name) {
+			return fmt.Errorf("schema is invalid - %s: unknown type '%s'", path, name)
+		}
+	}
+	for name, property := range s.Properties {
+		if property == nil {
+			continue
+		}
+		if err := property.check(path + "." + name); err != nil {
+			return err
+		}
+	}
+	if s.Items != nil {
+		return s.Items.check(path + "[]")
+	}
+	return nil
+}
+
+func (s *jsonSchema) validate(value any, path string, problems *[]string) {
+	addf := func(format string, args ...any) {
+		*problems = append(*problems, path+": "+fmt.Sprintf(format, args...))
+	}
+	if len(s.Type) > 0 && !slices.ContainsFunc(s.Type, func(name string) bool { return schemaTypeMatches(name, value) }) {
+		addf("expected %s, got %s", strings.Join(s.Type, " or "), schemaTypeOf(value))
+		return
+	}
+	if len(s.Enum) > 0 && !slices.ContainsFunc(s.Enum, func(allowed any) bool { return schemaEqual(allowed, value) }) {
+		addf("value is not one of the allowed values")
+	}
+
+	switch v := value.(type) {
+	case map[string]any:
+		for _, name := range s.Required {
+			if _, ok := v[name]; !ok {
+				addf("missing required field '%s'", name)
+			}
+		}
+		names := make([]string, 0, len(v))
+		for name := range v {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if property, ok := s.Properties[name]; ok {
+				if property != nil {
+					property.validate(v[name], path+"."+name, problems)
+				}
+			} else if s.AdditionalProperties != nil && !*s.AdditionalProperties {
+				addf("unexpected field '%s'", name)
+			}
+		}
+	case []any:
+		if s.MinItems != nil && len(v) < *s.MinItems {
+			addf("expected at least %d items, got %d", *s.MinItems, len(v))
+		}
+		if s.MaxItems != nil && len(v) > *s.MaxItems {
+			addf("expected at most %d items, got %d", *s.MaxItems, len(v))
+		}
+		if s.Items != nil {
+			for i, item := range v {
+				s.Items.validate(item, fmt.Sprintf("%s[%d]", path, i), problems)
+			}
+		}
+	case string:
+		length := utf8.RuneCountInString(v)
+		if s.MinLength != nil && length < *s.MinLength {
+			addf("expected at least %d characters, got %d", *s.MinLength, length)
+		}
+		if s.MaxLength != nil && length > *s.MaxLength {
+			addf("expected at most %d characters, got %d", *s.MaxLength, length)
+		}
+	case json.Number:
+		f, _ := v.Float64()
+		if s.Minimum != nil && f < *s.Minimum {
+			addf("%s is less than the minimum %v", v, *s.Minimum)
+		}
+		if s.Maximum != nil && f > *s.Maximum {
+			addf("%s is greater than the maximum %v", v, *s.Maximum)
+		}
+	}
+}
+
+func schemaTypeMatches(name string, value any) bool {
+	switch name {
+	case "integer":
+		n, ok := value.(json.Number)
+		if !ok {
+			return false
+		}
+		f, err := n.Float64()
+		return err == nil && f == math.Trunc(f)
+	case "number":
+		_, ok := value.(json.Number)
+		return ok
+	}
+	return schemaTypeOf(value) == name
+}
+
+func schemaTypeOf(value any) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case json.Number, float64:
+		return "number"
+	case string:
+		return "string"
+	case []any:
+		return "array"
+	case map[string]any:
+		return "object"
+	}
+	return fmt.Sprintf("%T", value)
+}
+
+// schemaEqual compares an enum value of the schema with a payload value,
+// numbers by their value.
+func schemaEqual(allowed, value any) bool {
+	if n, ok := value.(json.Number); ok {
+		f, err := n.Float64()
+		a, isNumber := allowed.(float64)
+		return err == nil && isNumber && a == f
+	}
+	return reflect.DeepEqual(allowed, value)
+}
@@ -0,0 +1,131 @@
+package store_test
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	store "github.com/gradientzero/comby-store-sqlite"
+	"github.com/gradientzero/comby/v3"
+)
+
+const orderPlacedSchema = `{
+	"$schema": "https://json-schema.org/draft/2020-12/schema",
+	"type": "object",
+	"required": ["orderId", "amount"],
+	"additionalProperties": false,
+	"properties": {
+		"orderId": {"type": "string", "minLength": 1},
+		"amount": {"type": "number", "minimum": 0},
+		"status": {"enum": ["open", "paid"]},
+		"items": {"type": "array", "items": {"type": "integer"}, "maxItems": 3}
+	}
+}`
+
+func TestEventStoreSchemaValidation(t *testing.T) {
+	ctx := context.Background()
+	eventStore := store.NewEventStoreSQLite(filepath.Join(t.TempDir(), "events.db"))
+	eventStore.Configure(store.EventStoreSQLiteWithSchemaValidation())
+	if err := eventStore.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer eventStore.Close(ctx)
+	registry, err := store.NewSchemaRegistry(eventStore)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	create := func(dataType, payload string) error {
+		evt := createTestEvent("tenant-1", "domain-1", 1, 100)
+		evt.SetDomainEvtName(dataType)
+		evt.SetDomainEvtBytes([]byte(payload))
+		return eventStore.Create(ctx, comby.EventStoreCreateOptionWithEvent(evt))
+	}
+	// payloads are not validated before a schema is registered
+	if err := create("OrderPlaced", `{"orderId": 1}`); err != nil {
+		t.Fatal(err)
+	}
+	schema, err := registry.Register(ctx, "OrderPlaced", []byte(orderPlacedSchema))
+	if err != nil || schema.Version != 1 {
+		t.Fatalf("expected version 1, got %v %v", schema, err)
+	}
+
+	if err := create("OrderPlaced", `{"orderId": "o-1", "amount": 12.5, "status": "paid", "items": [1, 2]}`); err != nil {
+		t.Fatal(err)
+	}
+	err = create("OrderPlaced", `{"orderId": 1, "status": "cancelled", "items": [1, 2.5, 3, 4], "note": "x"}`)
+	var schemaErr *store.PayloadSchemaError
+	if !errors.Is(err, store.ErrPayloadInvalid) || !errors.As(err, &schemaErr) {
+		t.Fatalf("expected payload schema error, got %v", err)
+	}
+	want := []string{
+		"$: missing required field 'amount'",
+		"$.items: expected at most 3 items, got 4",
+		"$.items[1]: expected integer, got number",
+		"$: unexpected field 'note'",
+		"$.orderId: expected string, got number",
+		"$.status: value is not one of the allowed values",
+	}
+	if schemaErr.DataType != "OrderPlaced" || schemaErr.Version != 1 || strings.Join(schemaErr.Problems, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("unexpected problems %q", schemaErr.Problems)
+	}
+	if err := create("OrderPlaced", `not json`); !errors.Is(err, store.ErrPayloadInvalid) {
+		t.Fatalf("expected payload schema error, got %v", err)
+	}
+	// other data types are not validated
+	if err := create("OrderShipped", `{}`); err != nil {
+		t.Fatal(err)
+	}
+
+	// a new version applies to the next Create
+	schema, err = registry.Register(ctx, "OrderPlaced", []byte(`{"type": "object", "required": ["orderId"]}`))
+	if err != nil || schema.Version != 2 {
+		t.Fatalf("expected version 2, got %v %v", schema, err)
+	}
+	if err := create("OrderPlaced", `{"orderId": 1}`); err != nil {
+		t.Fatal(err)
+	}
+	if err := registry.Validate(ctx, "OrderPlaced", []byte(`{}`)); !errors.Is(err, store.ErrPayloadInvalid) {
+		t.Fatalf("expected payload schema error, got %v", err)
+	}
+	// registering the latest schema again keeps its version
+	if schema, err := registry.Register(ctx, "OrderPlaced", []byte(`{"type":"object","required":["orderId"]}`)); err != nil || schema.Version != 2 {
+		t.Fatalf("expected version 2, got %v %v", schema, err)
+	}
+	if schema, err := registry.Get(ctx, "OrderPlaced", 1); err != nil || schema == nil || schema.Version != 1 {
+		t.Fatalf("expected version 1, got %v %v", schema, err)
+	}
+	if schemas, err := registry.List(ctx); err != nil || len(schemas) != 2 {
+		t.Fatalf("expected 2 schemas, got %v %v", schemas, err)
+	}
+	if total := eventStore.Total(ctx); total != 4 {
+		t.Fatalf("expected 4 events, got %d", total)
+	}
+}
+
+func TestSchemaRegistryRejectsInvalidSchemas(t *testing.T) {
+	ctx := context.Background()
+	eventStore := store.NewEventStoreSQLite(filepath.Join(t.TempDir(), "events.db"))
+	if err := eventStore.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer eventStore.Close(ctx)
+	registry, err := store.NewSchemaRegistry(eventStore)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// without schema validation the table is created by the registry
+	if err := registry.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	for _, schema := range []string{`not json`, `{"type": "text"}`, `{"properties": {"a": {"type": ["string", "date"]}}}`} {
+		if _, err := registry.Register(ctx, "OrderPlaced", []byte(schema)); err == nil {
+			t.Fatalf("expected %s to be rejected", schema)
+		}
+	}
+	if schema, err := registry.Get(ctx, "OrderPlaced", 0); err != nil || schema != nil {
+		t.Fatalf("expected no schema, got %v %v", schema, err)
+	}
+}
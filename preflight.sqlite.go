@@ -0,0 +1,189 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// PreflightReport is the result of Preflight.
+type PreflightReport struct {
+	// tables and views read successfully
+	Tables []string
+	// approximate number of pages read to warm the cache
+	WarmedPages int64
+	Duration    time.Duration
+}
+
+type preflightConfig struct {
+	Enabled bool
+	// pages of the newest records read after the checks
+	WarmPages int
+}
+
+// EventStoreSQLiteWithPreflight runs Preflight on Init and fails Init if it
+// fails, so a broken database is noticed at deploy time and not by the first
+// request. warmPages of the newest events are read into the cache afterwards.
+func EventStoreSQLiteWithPreflight(warmPages int) EventStoreSQLiteOption {
+	return func(c *eventStoreSQLiteConfig) { c.Preflight = preflightConfig{Enabled: true, WarmPages: warmPages} }
+}
+
+// CommandStoreSQLiteWithPreflight runs Preflight on Init, see EventStoreSQLiteWithPreflight.
+func CommandStoreSQLiteWithPreflight(warmPages int) CommandStoreSQLiteOption {
+	return func(c *commandStoreSQLiteConfig) { c.Preflight = preflightConfig{Enabled: true, WarmPages: warmPages} }
+}
+
+var indexNamePattern = regexp.MustCompile(`CREATE (?:UNIQUE )?INDEX IF NOT EXISTS "([^"]+)"`)
+
+// preflight replays a pending journal, runs quick_check, verifies that the
+// indexes of indexSchema exist, reads a row of each table and finally reads
+// the newest rows of warmTable until about warmPages pages were touched.
+func preflight(ctx context.Context, db *sql.DB, tables []string, indexSchema, warmTable string, warmPages int) (*PreflightReport, error) {
+	start := time.Now()
+	report := &PreflightReport{}
+
+	// the first read recovers a hot journal or rebuilds the WAL index
+	var pageSize int64
+	if err := db.QueryRowContext(ctx, "PRAGMA page_size;").Scan(&pageSize); err != nil {
+		return nil, classifyError(err)
+	}
+
+	var result string
+	if err := db.QueryRowContext(ctx, "PRAGMA quick_check(1);").Scan(&result); err != nil {
+		return nil, classifyError(err)
+	}
+	if result != "ok" {
+		return nil, fmt.Errorf("quick check failed - %s: %w", result, ErrCorrupt)
+	}
+
+	var missing []string
+	for _, match := range indexNamePattern.FindAllStringSubmatch(indexSchema, -1) {
+		var count int
+		if err := db.QueryRowContext(ctx, "SELECT COUNT(*) FROM sqlite_master WHERE type='index' AND name=?;", match[1]).Scan(&count); err != nil {
+			return nil, classifyError(err)
+		}
+		if count == 0 {
+			missing = append(missing, match[1])
+		}
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("missing indexes %s", strings.Join(missing, ", "))
+	}
+
+	for _, table := range tables {
+		var one int
+		err := db.QueryRowContext(ctx, fmt.Sprintf(`SELECT 1 FROM "%s" LIMIT 1;`, table)).Scan(&one)
+		if err != nil && err != sql.ErrNoRows {
+			return nil, fmt.Errorf("failed to read table '%s' - %w", table, classifyError(err))
+		}
+		report.Tables = append(report.Tables, table)
+	}
+
+	if warmPages > 0 {
+		warmed, err := warmCache(ctx, db, warmTable, pageSize, int64(warmPages))
+		if err != nil {
+			return nil, fmt.Errorf("failed to warm cache - %w", classifyError(err))
+		}
+		report.WarmedPages = warmed
+	}
+	report.Duration = time.Since(start)
+	return report, nil
+}
+
+// warmCache reads the newest rows of table, which are stored at the end of its
+// b-tree, until about pages pages were read. It returns the number of pages.
+func warmCache(ctx context.Context, db *sql.DB, table string, pageSize, pages int64) (int64, error) {
+	rows, err := db.QueryContext(ctx, fmt.Sprintf(`SELECT * FROM "%s" ORDER BY id DESC;`, table))
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+	columns, err := rows.Columns()
+	if err != nil {
+		return 0, err
+	}
+	values := make([]sql.RawBytes, len(columns))
+	dest := make([]any, len(columns))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+	var size int64
+	for size < pages*pageSize && rows.Next() {
+		if err := rows.Scan(dest...); err != nil {
+			return 0, err
+		}
+		for _, value := range values {
+			size += int64(len(value))
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+	return min(pages, (size+pageSize-1)/pageSize), nil
+}
+
+func strictTableNames(tables ...[]strictTable) []string {
+	var names []string
+	for _, list := range tables {
+		for _, table := range list {
+			names = append(names, table.name)
+		}
+	}
+	return names
+}
+
+// Preflight checks that the database is intact and readable and warms the
+// page cache with warmPages pages of the newest events.
+func (es *eventStoreSQLite) Preflight(ctx context.Context, warmPages int) (*PreflightReport, error) {
+	if es.db == nil {
+		return nil, fmt.Errorf("'%s' failed preflight - store is not initialized", es.String())
+	}
+	tables := append(strictTableNames(eventTables, auditTables, eventCounterTables), "events")
+	report, err := preflight(ctx, es.db, tables, eventIndexSchema, "event_records", warmPages)
+	if err != nil {
+		return nil, fmt.Errorf("'%s' failed preflight - %w", es.String(), err)
+	}
+	return report, nil
+}
+
+func (es *eventStoreSQLite) initPreflight(ctx context.Context) error {
+	config := es.cfg().Preflight
+	if !config.Enabled {
+		return nil
+	}
+	report, err := es.Preflight(ctx, config.WarmPages)
+	if err != nil {
+		return err
+	}
+	loggerOrDiscard(es.cfg().Logger).InfoContext(ctx, "preflight passed", "tables", len(report.Tables), "warmedPages", report.WarmedPages, "duration", report.Duration)
+	return nil
+}
+
+// Preflight checks that the database is intact and readable and warms the
+// page cache with warmPages pages of the newest commands.
+func (cs *commandStoreSQLite) Preflight(ctx context.Context, warmPages int) (*PreflightReport, error) {
+	if cs.db == nil {
+		return nil, fmt.Errorf("'%s' failed preflight - store is not initialized", cs.String())
+	}
+	report, err := preflight(ctx, cs.db, strictTableNames(commandTables, auditTables, commandReplayTables), commandIndexSchema, "commands", warmPages)
+	if err != nil {
+		return nil, fmt.Errorf("'%s' failed preflight - %w", cs.String(), err)
+	}
+	return report, nil
+}
+
+func (cs *commandStoreSQLite) initPreflight(ctx context.Context) error {
+	config := cs.cfg().Preflight
+	if !config.Enabled {
+		return nil
+	}
+	report, err := cs.Preflight(ctx, config.WarmPages)
+	if err != nil {
+		return err
+	}
+	loggerOrDiscard(cs.cfg().Logger).InfoContext(ctx, "preflight passed", "tables", len(report.Tables), "warmedPages", report.WarmedPages, "duration", report.Duration)
+	return nil
+}
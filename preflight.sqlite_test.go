@@ -0,0 +1,80 @@
+package store_test
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	store "github.com/gradientzero/comby-store-sqlite"
+	"github.com/gradientzero/comby/v3"
+)
+
+func TestEventStorePreflight(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "eventStore-preflight.db")
+
+	eventStore := store.NewEventStoreSQLite(path)
+	eventStore.Configure(store.EventStoreSQLiteWithPreflight(4))
+	if err := eventStore.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	for i := int64(1); i <= 50; i++ {
+		evt := createTestEvent("tenant-1", "domain-1", i, i*100)
+		evt.SetDomainEvtBytes([]byte(strings.Repeat("x", 1000)))
+		if err := eventStore.Create(ctx, comby.EventStoreCreateOptionWithEvent(evt)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	report, err := eventStore.Preflight(ctx, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.WarmedPages != 4 {
+		t.Fatalf("expected 4 warmed pages, got %d", report.WarmedPages)
+	}
+	if len(report.Tables) == 0 || report.Tables[len(report.Tables)-1] != "events" {
+		t.Fatalf("expected the events view to be checked, got %v", report.Tables)
+	}
+	eventStore.Close(ctx)
+
+	// a lost index fails Init of a read-only store, which can not recreate it
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if _, err := db.ExecContext(ctx, `DROP INDEX event_records_created_at_index;`); err != nil {
+		t.Fatal(err)
+	}
+	readOnly := func(opt *comby.EventStoreOptions) (*comby.EventStoreOptions, error) {
+		opt.ReadOnly = true
+		return opt, nil
+	}
+	eventStore = store.NewEventStoreSQLite(path)
+	eventStore.Configure(store.EventStoreSQLiteWithPreflight(0))
+	defer eventStore.Close(ctx)
+	if err := eventStore.Init(ctx, readOnly); err == nil || !strings.Contains(err.Error(), "event_records_created_at_index") {
+		t.Fatalf("expected missing index error, got %v", err)
+	}
+}
+
+func TestCommandStorePreflight(t *testing.T) {
+	ctx := context.Background()
+	commandStore := store.NewCommandStoreSQLite(filepath.Join(t.TempDir(), "commandStore-preflight.db"))
+	commandStore.Configure(store.CommandStoreSQLiteWithPreflight(16))
+	if err := commandStore.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer commandStore.Close(ctx)
+
+	// an empty database has nothing to warm
+	report, err := commandStore.Preflight(ctx, 16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.WarmedPages != 0 {
+		t.Fatalf("expected no warmed pages, got %d", report.WarmedPages)
+	}
+}
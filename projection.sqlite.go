@@ -0,0 +1,247 @@
+package store
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gradientzero/comby/v3"
+)
+
+// ProjectionApply updates the tables of a projection for one event. It must
+// only use tx, which also advances the checkpoint of the projection.
+type ProjectionApply func(ctx context.Context, tx *sql.Tx, evt comby.Event) error
+
+// Projection is a read model kept in the database file of the event store.
+type Projection struct {
+	Name string
+	// statements creating the tables, changing them rebuilds the projection
+	Schema string
+	// tables created by Schema, dropped by Rebuild
+	Tables []string
+	// domains of the applied events, empty applies all events
+	Domains []string
+	Apply   ProjectionApply
+}
+
+type ProjectorOption func(*Projector)
+
+// ProjectorWithBatchSize sets the number of events applied per transaction.
+func ProjectorWithBatchSize(n int) ProjectorOption {
+	return func(p *Projector) { p.batchSize = n }
+}
+
+// ProjectorWithPollInterval sets how often Run looks for new events.
+func ProjectorWithPollInterval(interval time.Duration) ProjectorOption {
+	return func(p *Projector) { p.pollInterval = interval }
+}
+
+// Projector maintains a Projection: it creates its tables, applies new events
+// and rebuilds it from all events when its schema changes. Events are applied
+// in the same transaction which stores the checkpoint in the
+// projection_checkpoints table, so each event is applied exactly once.
+type Projector struct {
+	es           *eventStoreSQLite
+	projection   Projection
+	batchSize    int
+	pollInterval time.Duration
+}
+
+func NewProjector(eventStore comby.EventStore, projection Projection, opts ...ProjectorOption) (*Projector, error) {
+	es, ok := eventStore.(*eventStoreSQLite)
+	if !ok {
+		return nil, fmt.Errorf("projector requires a sqlite event store")
+	}
+	if len(projection.Name) == 0 {
+		return nil, fmt.Errorf("'%s' failed to create projector - name is required", es.String())
+	}
+	if projection.Apply == nil {
+		return nil, fmt.Errorf("'%s' failed to create projector - apply is nil", es.String())
+	}
+	p := &Projector{
+		es:           es,
+		projection:   projection,
+		batchSize:    1000,
+		pollInterval: time.Second,
+	}
+	for _, opt := range opts {
+		opt(p)
+	}
+	if p.batchSize < 1 || p.pollInterval <= 0 {
+		return nil, fmt.Errorf("'%s' failed to create projector - invalid batch size or poll interval", es.String())
+	}
+	return p, nil
+}
+
+var projectionTables = []strictTable{
+	{
+		name: "projection_checkpoints",
+		columns: `name TEXT NOT NULL PRIMARY KEY,
+		seq INTEGER NOT NULL,
+		schema_checksum TEXT NOT NULL,
+		updated_at INTEGER NOT NULL`,
+		copyColumns: `name, seq, schema_checksum, updated_at`,
+	},
+}
+
+func (p *Projector) schemaChecksum() string {
+	sum := sha256.Sum256([]byte(p.projection.Schema))
+	return hex.EncodeToString(sum[:])
+}
+
+// Init creates the checkpoints table and the tables of the projection. If the
+// schema changed since the last Init, the projection is rebuilt. The event
+// store must be initialized before.
+func (p *Projector) Init(ctx context.Context) error {
+	if p.es.db == nil {
+		return fmt.Errorf("'%s' failed to init projection - event store is not initialized", p.es.String())
+	}
+	if err := migrateTx(ctx, p.es.db, func(tx *sql.Tx) error {
+		return migrateStrictTables(ctx, tx, loggerOrDiscard(p.es.cfg().Logger), projectionTables...)
+	}); err != nil {
+		return classifyError(err)
+	}
+
+	var checksum string
+	err := p.es.db.QueryRowContext(ctx, "SELECT schema_checksum FROM projection_checkpoints WHERE name=?;", p.projection.Name).Scan(&checksum)
+	switch {
+	case err == sql.ErrNoRows:
+		return p.reset(ctx, false)
+	case err != nil:
+		return classifyError(err)
+	case checksum != p.schemaChecksum():
+		loggerOrDiscard(p.es.cfg().Logger).InfoContext(ctx, "projection schema changed, rebuilding", "name", p.projection.Name)
+		_, err := p.Rebuild(ctx)
+		return err
+	}
+	return nil
+}
+
+// reset (re)creates the tables of the projection and its checkpoint at 0.
+func (p *Projector) reset(ctx context.Context, drop bool) error {
+	p.es.writeMu.Lock()
+	defer p.es.writeMu.Unlock()
+	return runTx(ctx, p.es.db, func(tx *sql.Tx) error {
+		if drop {
+			for _, table := range p.projection.Tables {
+				if _, err := tx.ExecContext(ctx, fmt.Sprintf(`DROP TABLE IF EXISTS "%s";`, strings.ReplaceAll(table, `"`, `""`))); err != nil {
+					return fmt.Errorf("'%s' failed to drop projection table '%s' - %w", p.es.String(), table, err)
+				}
+			}
+		}
+		if len(p.projection.Schema) > 0 {
+			if _, err := tx.ExecContext(ctx, p.projection.Schema); err != nil {
+				return fmt.Errorf("'%s' failed to create projection '%s' - %w", p.es.String(), p.projection.Name, err)
+			}
+		}
+		query := `INSERT INTO projection_checkpoints (name, seq, schema_checksum, updated_at) VALUES (?, 0, ?, ?)
+			ON CONFLICT(name) DO UPDATE SET seq=0, schema_checksum=excluded.schema_checksum, updated_at=excluded.updated_at;`
+		_, err := tx.ExecContext(ctx, query, p.projection.Name, p.schemaChecksum(), time.Now().UnixNano())
+		return err
+	})
+}
+
+// Checkpoint returns the sequence of the last applied event.
+func (p *Projector) Checkpoint(ctx context.Context) (int64, error) {
+	var seq int64
+	err := p.es.db.QueryRowContext(ctx, "SELECT seq FROM projection_checkpoints WHERE name=?;", p.projection.Name).Scan(&seq)
+	if err == sql.ErrNoRows {
+		return 0, fmt.Errorf("'%s' failed to read checkpoint - projection '%s' is not initialized", p.es.String(), p.projection.Name)
+	}
+	return seq, classifyError(err)
+}
+
+// Poll applies all events after the checkpoint and returns their number.
+// If apply fails, the events of the failed batch are applied again by the
+// next call.
+func (p *Projector) Poll(ctx context.Context) (int64, error) {
+	var applied int64
+	for {
+		n, err := p.applyBatch(ctx)
+		applied += n
+		if err != nil || n < int64(p.batchSize) {
+			return applied, err
+		}
+	}
+}
+
+func (p *Projector) applyBatch(ctx context.Context) (int64, error) {
+	// projections are bookkeeping of the event store, they bypass the write limits
+	p.es.writeMu.Lock()
+	defer p.es.writeMu.Unlock()
+
+	var applied int64
+	err := runTx(ctx, p.es.db, func(tx *sql.Tx) error {
+		var seq int64
+		if err := tx.QueryRowContext(ctx, "SELECT seq FROM projection_checkpoints WHERE name=?;", p.projection.Name).Scan(&seq); err != nil {
+			if err == sql.ErrNoRows {
+				return fmt.Errorf("'%s' failed to apply projection - projection '%s' is not initialized", p.es.String(), p.projection.Name)
+			}
+			return err
+		}
+
+		whereList := []string{"id>?"}
+		args := []any{seq}
+		whereList, args = inCondition("domain", "IN", p.projection.Domains, whereList, args)
+		whereList, args = tenantCondition(p.es.cfg().Tenant, whereList, args)
+		query := fmt.Sprintf("SELECT %s FROM events WHERE %s ORDER BY id ASC LIMIT %d;", eventSelectColumns, strings.Join(whereList, " AND "), p.batchSize)
+		dbRecords, err := queryEventRecords(ctx, tx, query, args)
+		if err != nil || len(dbRecords) == 0 {
+			return err
+		}
+		for _, dbRecord := range dbRecords {
+			evt, err := p.es.decodeEvent(ctx, dbRecord)
+			if err != nil {
+				return err
+			}
+			if err := p.projection.Apply(ctx, tx, evt); err != nil {
+				return fmt.Errorf("'%s' failed to apply event '%s' to projection '%s' - %w", p.es.String(), evt.GetEventUuid(), p.projection.Name, err)
+			}
+		}
+		seq = dbRecords[len(dbRecords)-1].ID.Int64
+		if _, err := tx.ExecContext(ctx, "UPDATE projection_checkpoints SET seq=?, updated_at=? WHERE name=?;", seq, time.Now().UnixNano(), p.projection.Name); err != nil {
+			return err
+		}
+		applied = int64(len(dbRecords))
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return applied, nil
+}
+
+// Rebuild drops and recreates the tables of the projection and applies all
+// events again. It returns the number of applied events.
+func (p *Projector) Rebuild(ctx context.Context) (int64, error) {
+	if err := p.reset(ctx, true); err != nil {
+		return 0, err
+	}
+	return p.Poll(ctx)
+}
+
+// Run polls until ctx is done, after every poll interval and right after
+// events were written by the event store. Failures are logged and retried
+// after the poll interval.
+func (p *Projector) Run(ctx context.Context) error {
+	logger := loggerOrDiscard(p.es.cfg().Logger)
+	changes, unsubscribe := p.es.SubscribeChanges()
+	defer unsubscribe()
+	ticker := time.NewTicker(p.pollInterval)
+	defer ticker.Stop()
+	for {
+		if _, err := p.Poll(ctx); err != nil && ctx.Err() == nil {
+			logger.ErrorContext(ctx, "projection failed", "name", p.projection.Name, "error", err)
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		case <-changes:
+		}
+	}
+}
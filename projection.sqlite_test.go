@@ -0,0 +1,105 @@
+package store_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	store "github.com/gradientzero/comby-store-sqlite"
+	"github.com/gradientzero/comby/v3"
+)
+
+func TestProjector(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "eventStore-projection.db")
+	eventStore := store.NewEventStoreSQLite(path)
+	if err := eventStore.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer eventStore.Close(ctx)
+	for i := int64(1); i <= 5; i++ {
+		domain := "domain-1"
+		if i%2 == 0 {
+			domain = "domain-2"
+		}
+		if err := eventStore.Create(ctx, comby.EventStoreCreateOptionWithEvent(createTestEvent("tenant-1", domain, i, i*100))); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var failing bool
+	projection := store.Projection{
+		Name:    "aggregates",
+		Schema:  `CREATE TABLE IF NOT EXISTS aggregates (uuid TEXT PRIMARY KEY, version INTEGER NOT NULL);`,
+		Tables:  []string{"aggregates"},
+		Domains: []string{"domain-1"},
+		Apply: func(ctx context.Context, tx *sql.Tx, evt comby.Event) error {
+			if failing {
+				return errors.New("apply failed")
+			}
+			_, err := tx.ExecContext(ctx, `INSERT INTO aggregates (uuid, version) VALUES (?, ?)`, evt.GetAggregateUuid(), evt.GetVersion())
+			return err
+		},
+	}
+	projector, err := store.NewProjector(eventStore, projection, store.ProjectorWithBatchSize(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := projector.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := projector.Poll(ctx); err != nil || n != 3 {
+		t.Fatalf("expected 3 applied events, got %d, %v", n, err)
+	}
+
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	countRows := func() int {
+		var count int
+		if err := db.QueryRow("SELECT COUNT(*) FROM aggregates").Scan(&count); err != nil {
+			t.Fatal(err)
+		}
+		return count
+	}
+	if count := countRows(); count != 3 {
+		t.Fatalf("expected 3 rows, got %d", count)
+	}
+
+	// a failing apply rolls back the batch and keeps the checkpoint
+	seq, _ := projector.Checkpoint(ctx)
+	if err := eventStore.Create(ctx, comby.EventStoreCreateOptionWithEvent(createTestEvent("tenant-1", "domain-1", 7, 700))); err != nil {
+		t.Fatal(err)
+	}
+	failing = true
+	if _, err := projector.Poll(ctx); err == nil {
+		t.Fatal("expected apply error")
+	}
+	if after, _ := projector.Checkpoint(ctx); after != seq {
+		t.Fatalf("expected checkpoint %d, got %d", seq, after)
+	}
+	failing = false
+	if n, err := projector.Poll(ctx); err != nil || n != 1 {
+		t.Fatalf("expected 1 applied event, got %d, %v", n, err)
+	}
+
+	// a changed schema rebuilds the projection from all events
+	projection.Schema = `CREATE TABLE IF NOT EXISTS aggregates (uuid TEXT PRIMARY KEY, version INTEGER NOT NULL, note TEXT);`
+	rebuilt, err := store.NewProjector(eventStore, projection)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := rebuilt.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if count := countRows(); count != 4 {
+		t.Fatalf("expected 4 rows after rebuild, got %d", count)
+	}
+	if n, err := rebuilt.Rebuild(ctx); err != nil || n != 4 {
+		t.Fatalf("expected 4 applied events, got %d, %v", n, err)
+	}
+}
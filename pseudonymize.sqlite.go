@@ -0,0 +1,131 @@
+package store
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"fmt"
+	"strings"
+
+	"github.com/gradientzero/comby-store-sqlite/internal"
+)
+
+// ExportOption configures ExportEvents, ExportCommands and ExportTenant.
+type ExportOption func(*exportConfig)
+
+type exportConfig struct {
+	// key of the salted hash, required for pseudonymization
+	Salt []byte
+	// replace tenant uuids of records, snapshots and request contexts
+	Tenants bool
+	// replace sender identity and account uuids of request contexts
+	Identities bool
+	// json paths per data type replaced in payloads
+	Paths map[string][]string
+}
+
+// ExportWithSalt sets the secret salt of pseudonyms. The same salt maps a
+// value to the same pseudonym, so records still relate to each other.
+func ExportWithSalt(salt []byte) ExportOption {
+	return func(c *exportConfig) { c.Salt = salt }
+}
+
+// ExportPseudonymizeTenants replaces tenant uuids with pseudonyms.
+func ExportPseudonymizeTenants() ExportOption {
+	return func(c *exportConfig) { c.Tenants = true }
+}
+
+// ExportPseudonymizeIdentities replaces the sender identity and account uuids
+// of request contexts with pseudonyms.
+func ExportPseudonymizeIdentities() ExportOption {
+	return func(c *exportConfig) { c.Identities = true }
+}
+
+// ExportPseudonymizePaths replaces the values at the given dot separated JSON
+// paths of payloads with the given data type (events and commands) with pseudonyms.
+func ExportPseudonymizePaths(dataType string, paths ...string) ExportOption {
+	return func(c *exportConfig) {
+		if c.Paths == nil {
+			c.Paths = map[string][]string{}
+		}
+		c.Paths[dataType] = append(c.Paths[dataType], paths...)
+	}
+}
+
+func newExportConfig(opts ...ExportOption) (exportConfig, error) {
+	config := exportConfig{}
+	for _, opt := range opts {
+		opt(&config)
+	}
+	if config.pseudonymize() && len(config.Salt) == 0 {
+		return config, fmt.Errorf("pseudonymization requires a salt")
+	}
+	return config, nil
+}
+
+func (c exportConfig) pseudonymize() bool {
+	return c.Tenants || c.Identities || len(c.Paths) > 0
+}
+
+// pseudonym is a salted hash of value shaped like a uuid (version 8), so it
+// fits wherever uuids are expected. Empty values stay empty.
+func (c exportConfig) pseudonym(value string) string {
+	if len(value) == 0 {
+		return value
+	}
+	mac := hmac.New(sha256.New, c.Salt)
+	mac.Write([]byte(value))
+	sum := mac.Sum(nil)
+	sum[6] = sum[6]&0x0f | 0x80
+	sum[8] = sum[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", sum[0:4], sum[4:6], sum[6:8], sum[8:10], sum[10:16])
+}
+
+// pseudonymizeRecord replaces the configured fields of a decrypted record.
+// A replaced payload gets a new checksum of the same algorithm.
+func (c exportConfig) pseudonymizeRecord(tenantUuid, reqCtx *string, dataType string, dataBytes *[]byte, checksum *string) error {
+	if c.Tenants {
+		*tenantUuid = c.pseudonym(*tenantUuid)
+	}
+	var reqCtxPaths []string
+	if c.Tenants {
+		reqCtxPaths = append(reqCtxPaths, "senderTenantUuid", "targetTenantUuid")
+	}
+	if c.Identities {
+		reqCtxPaths = append(reqCtxPaths, "senderIdentityUuid", "senderAccountUuid")
+	}
+	if len(reqCtxPaths) > 0 && len(*reqCtx) > 0 {
+		replaced, err := internal.PseudonymizeFields([]byte(*reqCtx), reqCtxPaths, c.pseudonym)
+		if err != nil {
+			return fmt.Errorf("failed to pseudonymize request context - %w", err)
+		}
+		*reqCtx = string(replaced)
+	}
+	if paths := c.Paths[dataType]; len(paths) > 0 {
+		replaced, err := internal.PseudonymizeFields(*dataBytes, paths, c.pseudonym)
+		if err != nil {
+			return fmt.Errorf("failed to pseudonymize payload of type '%s' - %w", dataType, err)
+		}
+		*dataBytes = replaced
+		if len(*checksum) > 0 {
+			algorithm, _, _ := strings.Cut(*checksum, ":")
+			if *checksum, err = internal.Checksum(algorithm, replaced); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (c exportConfig) pseudonymizeEvent(dbRecord *internal.Event) error {
+	if !c.pseudonymize() {
+		return nil
+	}
+	return c.pseudonymizeRecord(&dbRecord.TenantUuid, &dbRecord.ReqCtx, dbRecord.DataType, &dbRecord.DataBytes, &dbRecord.Checksum)
+}
+
+func (c exportConfig) pseudonymizeCommand(dbRecord *internal.Command) error {
+	if !c.pseudonymize() {
+		return nil
+	}
+	return c.pseudonymizeRecord(&dbRecord.TenantUuid, &dbRecord.ReqCtx, dbRecord.DataType, &dbRecord.DataBytes, &dbRecord.Checksum)
+}
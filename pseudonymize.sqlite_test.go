@@ -0,0 +1,83 @@
+package store_test
+
+import (
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"regexp"
+	"testing"
+
+	store "github.com/gradientzero/comby-store-sqlite"
+	"github.com/gradientzero/comby/v3"
+)
+
+func TestExportPseudonymized(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	src := store.NewEventStoreSQLite(filepath.Join(dir, "production.db"))
+	if err := src.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer src.Close(ctx)
+	dst := store.NewEventStoreSQLite(filepath.Join(dir, "staging.db"))
+	dst.Configure(store.EventStoreSQLiteWithChecksumVerification(true))
+	if err := dst.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer dst.Close(ctx)
+
+	for i := int64(1); i <= 2; i++ {
+		evt := createTestEvent("tenant-1", "user-domain", i, i*100)
+		evt.SetDomainEvtName("UserCreated")
+		evt.SetDomainEvtBytes([]byte(`{"email":"jane@example.com","profile":{"name":"Jane"},"plan":"pro"}`))
+		evt.SetReqCtx(&comby.RequestContext{SenderTenantUuid: "tenant-1", SenderIdentityUuid: "identity-1", TargetAggregateUuid: "aggregate-1"})
+		if err := src.Create(ctx, comby.EventStoreCreateOptionWithEvent(evt)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, err := store.ExportEvents(ctx, src, dst, store.ExportPseudonymizeTenants()); err == nil {
+		t.Fatal("expected pseudonymization without salt to fail")
+	}
+	_, err := store.ExportEvents(ctx, src, dst,
+		store.ExportWithSalt([]byte("secret")),
+		store.ExportPseudonymizeTenants(),
+		store.ExportPseudonymizeIdentities(),
+		store.ExportPseudonymizePaths("UserCreated", "email", "profile.name"),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	evts, _, err := dst.List(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(evts) != 2 {
+		t.Fatalf("expected 2 exported events, got %d", len(evts))
+	}
+	uuidPattern := regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-8[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+	tenantUuid := evts[0].GetTenantUuid()
+	if !uuidPattern.MatchString(tenantUuid) || evts[1].GetTenantUuid() != tenantUuid {
+		t.Fatalf("expected stable uuid shaped pseudonym, got %q and %q", tenantUuid, evts[1].GetTenantUuid())
+	}
+
+	reqCtx := evts[0].GetReqCtx()
+	if reqCtx.SenderTenantUuid != tenantUuid || !uuidPattern.MatchString(reqCtx.SenderIdentityUuid) || reqCtx.TargetAggregateUuid != "aggregate-1" {
+		t.Fatalf("unexpected request context: %+v", reqCtx)
+	}
+
+	var payload struct {
+		Email   string `json:"email"`
+		Profile struct {
+			Name string `json:"name"`
+		} `json:"profile"`
+		Plan string `json:"plan"`
+	}
+	if err := json.Unmarshal(evts[0].GetDomainEvtBytes(), &payload); err != nil {
+		t.Fatal(err)
+	}
+	if !uuidPattern.MatchString(payload.Email) || !uuidPattern.MatchString(payload.Profile.Name) || payload.Plan != "pro" {
+		t.Fatalf("unexpected payload: %s", evts[0].GetDomainEvtBytes())
+	}
+}
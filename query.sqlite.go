@@ -0,0 +1,125 @@
+package store
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gradientzero/comby/v3"
+)
+
+// listQuery holds the statements of a list call. Filter values are only
+// passed as args, the statements are made of column names and the validated
+// order and page options, so the same options with other values build the
+// same statements.
+type listQuery struct {
+	selectSQL string
+	countSQL  string
+	args      []any
+}
+
+// eventListQuery builds the list query of the events in source, selecting
+// columns. boundTenant restricts it to the tenant of the store.
+func eventListQuery(source, columns string, listOpts comby.EventStoreListOptions, filter eventFilter, boundTenant string) listQuery {
+	var whereList []string
+	var args []any
+	if len(listOpts.TenantUuid) > 0 {
+		whereList = append(whereList, "tenant_uuid=?")
+		args = append(args, listOpts.TenantUuid)
+	}
+	if len(listOpts.AggregateUuid) > 0 {
+		whereList = append(whereList, "aggregate_uuid=?")
+		args = append(args, listOpts.AggregateUuid)
+	}
+	if len(listOpts.DataType) > 0 {
+		whereList = append(whereList, filter.column("data_type")+"=?")
+		args = append(args, listOpts.DataType)
+	}
+	whereList, args = inCondition(filter.column("domain"), "IN", listOpts.Domains, whereList, args)
+	whereList, args = timeConditions(listOpts.Before, listOpts.After, whereList, args)
+	whereList, args = afterUuidCondition(filter.AfterUuid, listOpts.Ascending, whereList, args)
+	whereList, args = filter.conditions(whereList, args)
+	whereList, args = tenantCondition(boundTenant, whereList, args)
+
+	where := whereSQL(whereList)
+	return listQuery{
+		selectSQL: fmt.Sprintf("SELECT %s FROM %s%s%s%s;", columns, source, where, orderBySQL(listOpts.OrderBy, listOpts.Ascending), pageSQL(listOpts.Limit, listOpts.Offset)),
+		countSQL:  fmt.Sprintf("SELECT COUNT(id) FROM %s%s;", source, where),
+		args:      args,
+	}
+}
+
+// commandListQuery builds the list query of commands.
+func commandListQuery(listOpts comby.CommandStoreListOptions, filter commandFilter, boundTenant string) listQuery {
+	var whereList []string
+	var args []any
+	if len(filter.Status) > 0 {
+		whereList = append(whereList, "status=?")
+		args = append(args, filter.Status)
+	}
+	if len(listOpts.TenantUuid) > 0 {
+		whereList = append(whereList, "tenant_uuid=?")
+		args = append(args, listOpts.TenantUuid)
+	}
+	if len(listOpts.Domain) > 0 {
+		whereList = append(whereList, filter.column("domain")+"=?")
+		args = append(args, listOpts.Domain)
+	}
+	if len(listOpts.DataType) > 0 {
+		whereList = append(whereList, filter.column("data_type")+"=?")
+		args = append(args, listOpts.DataType)
+	}
+	whereList, args = timeConditions(listOpts.Before, listOpts.After, whereList, args)
+	whereList, args = afterUuidCondition(filter.AfterUuid, listOpts.Ascending, whereList, args)
+	whereList, args = filter.conditions(whereList, args)
+	whereList, args = tenantCondition(boundTenant, whereList, args)
+
+	where := whereSQL(whereList)
+	return listQuery{
+		selectSQL: fmt.Sprintf("SELECT %s FROM commands%s%s%s;", commandSelectColumns, where, orderBySQL(listOpts.OrderBy, listOpts.Ascending), pageSQL(listOpts.Limit, listOpts.Offset)),
+		countSQL:  fmt.Sprintf("SELECT COUNT(id) FROM commands%s;", where),
+		args:      args,
+	}
+}
+
+// timeConditions appends the exclusive created_at bounds, -1 leaves a side open.
+func timeConditions(before, after int64, whereList []string, args []any) ([]string, []any) {
+	if before >= 0 {
+		whereList, args = append(whereList, "created_at<?"), append(args, before)
+	}
+	if after >= 0 {
+		whereList, args = append(whereList, "created_at>?"), append(args, after)
+	}
+	return whereList, args
+}
+
+// afterUuidCondition appends the keyset condition, uuids after (or before,
+// if descending) afterUuid.
+func afterUuidCondition(afterUuid string, ascending bool, whereList []string, args []any) ([]string, []any) {
+	if len(afterUuid) == 0 {
+		return whereList, args
+	}
+	if ascending {
+		return append(whereList, "uuid>?"), append(args, afterUuid)
+	}
+	return append(whereList, "uuid<?"), append(args, afterUuid)
+}
+
+// whereSQL joins the conditions, note the leading space.
+func whereSQL(whereList []string) string {
+	if len(whereList) == 0 {
+		return ""
+	}
+	return " WHERE " + strings.Join(whereList, " AND ")
+}
+
+// orderBySQL returns the ORDER BY clause, orderBy was checked against the
+// columns of the store.
+func orderBySQL(orderBy string, ascending bool) string {
+	switch {
+	case len(orderBy) == 0:
+		return ""
+	case ascending:
+		return fmt.Sprintf(" ORDER BY %s ASC", orderBy)
+	}
+	return fmt.Sprintf(" ORDER BY %s DESC", orderBy)
+}
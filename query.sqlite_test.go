@@ -0,0 +1,188 @@
+package store_test
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	store "github.com/gradientzero/comby-store-sqlite"
+	"github.com/gradientzero/comby/v3"
+)
+
+// filter values of the seed corpus and the stored records
+var fuzzValues = []string{
+	"tenant-1", "domain-1", "it's", `say "hi"`, "a%b_c", `back\slash`, "[x]*?", "ünïcödé", "日本語",
+	"tab\there", "new\nline", "\x01\x1f", "'; DROP TABLE events; --", "", "\xff\xfe",
+}
+
+func FuzzEventListQuery(f *testing.F) {
+	for i, value := range fuzzValues {
+		f.Add(value, fuzzValues[(i+1)%len(fuzzValues)], fuzzValues[(i+2)%len(fuzzValues)], value, value, i%2 == 0)
+	}
+	ctx := context.Background()
+	eventStore := store.NewEventStoreSQLite(filepath.Join(f.TempDir(), "fuzz.db"))
+	if err := eventStore.Init(ctx); err != nil {
+		f.Fatal(err)
+	}
+	defer eventStore.Close(ctx)
+	for i, value := range fuzzValues {
+		if len(value) == 0 {
+			continue
+		}
+		evt := createTestEvent(value, value, int64(i+1), int64(i+1)*100)
+		evt.SetDomainEvtName(value)
+		if err := eventStore.Create(ctx, comby.EventStoreCreateOptionWithEvent(evt)); err != nil {
+			f.Fatal(err)
+		}
+	}
+
+	f.Fuzz(func(t *testing.T, tenant, domain, dataType, prefix, glob string, caseInsensitive bool) {
+		options := func(tenant, domain, dataType, prefix, glob string) []comby.EventStoreListOption {
+			opts := []comby.EventStoreListOption{func(opts *comby.EventStoreListOptions) (*comby.EventStoreListOptions, error) {
+				opts.Limit, opts.TenantUuid, opts.DataType = -1, tenant, dataType
+				if len(domain) > 0 {
+					opts.Domains = []string{domain}
+				}
+				return opts, nil
+			}}
+			if len(prefix) > 0 {
+				opts = append(opts, store.EventStoreListOptionDomainPrefix(prefix))
+			}
+			if len(glob) > 0 {
+				opts = append(opts, store.EventStoreListOptionDataTypeGlob(glob))
+			}
+			if caseInsensitive {
+				opts = append(opts, store.EventStoreListOptionCaseInsensitive())
+			}
+			return opts
+		}
+
+		// values never end up in the statements
+		query, countQuery, args, err := store.EventListQuery(eventStore, options(tenant, domain, dataType, prefix, glob)...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		neutralQuery, neutralCountQuery, _, err := store.EventListQuery(eventStore, options(neutral(tenant, "tenant"), neutral(domain, "domain"), neutral(dataType, "dataType"), neutral(prefix, "prefix"), neutral(glob, "glob"))...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if query != neutralQuery || countQuery != neutralCountQuery {
+			t.Fatalf("statements depend on values:\n%s\n%s", query, neutralQuery)
+		}
+		if n := strings.Count(query, "?"); n != len(args) || strings.Count(countQuery, "?") != len(args) {
+			t.Fatalf("%d placeholders for %d args in %s", n, len(args), query)
+		}
+
+		evts, total, err := eventStore.List(ctx, options(tenant, domain, dataType, prefix, glob)...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if total != int64(len(evts)) {
+			t.Fatalf("total %d does not match %d events", total, len(evts))
+		}
+		equal := func(a, b string) bool { return a == b || caseInsensitive && asciiLower(a) == asciiLower(b) }
+		for _, evt := range evts {
+			if (len(tenant) > 0 && evt.GetTenantUuid() != tenant) ||
+				(len(domain) > 0 && !equal(evt.GetDomain(), domain)) ||
+				(len(dataType) > 0 && !equal(evt.GetDomainEvtName(), dataType)) ||
+				(len(prefix) > 0 && !equal(evt.GetDomain()[:min(len(prefix), len(evt.GetDomain()))], prefix)) {
+				t.Fatalf("event %+v does not match the filters", evt)
+			}
+		}
+	})
+}
+
+func FuzzCommandListQuery(f *testing.F) {
+	for i, value := range fuzzValues {
+		f.Add(value, fuzzValues[(i+1)%len(fuzzValues)], fuzzValues[(i+2)%len(fuzzValues)], value, i%2 == 0)
+	}
+	ctx := context.Background()
+	commandStore := store.NewCommandStoreSQLite(filepath.Join(f.TempDir(), "fuzz.db"))
+	if err := commandStore.Init(ctx); err != nil {
+		f.Fatal(err)
+	}
+	defer commandStore.Close(ctx)
+	for i, value := range fuzzValues {
+		if len(value) == 0 {
+			continue
+		}
+		cmd := createTestCommand(value, value, int64(i+1)*100)
+		cmd.SetDomainCmdName(value)
+		if err := commandStore.Create(ctx, comby.CommandStoreCreateOptionWithCommand(cmd)); err != nil {
+			f.Fatal(err)
+		}
+	}
+
+	f.Fuzz(func(t *testing.T, tenant, domain, dataType, exclude string, caseInsensitive bool) {
+		options := func(tenant, domain, dataType, exclude string) []comby.CommandStoreListOption {
+			opts := []comby.CommandStoreListOption{func(opts *comby.CommandStoreListOptions) (*comby.CommandStoreListOptions, error) {
+				opts.Limit, opts.TenantUuid, opts.Domain, opts.DataType = -1, tenant, domain, dataType
+				return opts, nil
+			}}
+			if len(exclude) > 0 {
+				opts = append(opts, store.CommandStoreListOptionExcludeTenantUuids(exclude))
+			}
+			if caseInsensitive {
+				opts = append(opts, store.CommandStoreListOptionCaseInsensitive())
+			}
+			return opts
+		}
+
+		query, countQuery, args, err := store.CommandListQuery(commandStore, options(tenant, domain, dataType, exclude)...)
+		if errors.Is(err, store.ErrInvalidOptions) {
+			// e.g. the tenant is excluded
+			return
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n := strings.Count(query, "?"); n != len(args) || strings.Count(countQuery, "?") != len(args) {
+			t.Fatalf("%d placeholders for %d args in %s", n, len(args), query)
+		}
+		neutralQuery, neutralCountQuery, _, err := store.CommandListQuery(commandStore, options(neutral(tenant, "tenant"), neutral(domain, "domain"), neutral(dataType, "dataType"), neutral(exclude, "exclude"))...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if query != neutralQuery || countQuery != neutralCountQuery {
+			t.Fatalf("statements depend on values:\n%s\n%s", query, neutralQuery)
+		}
+
+		cmds, total, err := commandStore.List(ctx, options(tenant, domain, dataType, exclude)...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if total != int64(len(cmds)) {
+			t.Fatalf("total %d does not match %d commands", total, len(cmds))
+		}
+		equal := func(a, b string) bool { return a == b || caseInsensitive && asciiLower(a) == asciiLower(b) }
+		for _, cmd := range cmds {
+			if (len(tenant) > 0 && cmd.GetTenantUuid() != tenant) ||
+				(len(domain) > 0 && !equal(cmd.GetDomain(), domain)) ||
+				(len(dataType) > 0 && !equal(cmd.GetDomainCmdName(), dataType)) ||
+				(len(exclude) > 0 && cmd.GetTenantUuid() == exclude) {
+				t.Fatalf("command %+v does not match the filters", cmd)
+			}
+		}
+	})
+}
+
+// neutral replaces a filter value by a plain one, an empty value leaves the
+// filter unset.
+func neutral(value, plain string) string {
+	if len(value) == 0 {
+		return ""
+	}
+	return plain
+}
+
+func asciiLower(s string) string {
+	b := []byte(s)
+	for i, c := range b {
+		if 'A' <= c && c <= 'Z' {
+			b[i] = c + 'a' - 'A'
+		}
+	}
+	return string(b)
+}
@@ -0,0 +1,66 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+// ErrQuotaExceeded is matched by QuotaError.
+var ErrQuotaExceeded = errors.New("database size quota exceeded")
+
+// QuotaError is returned by writes while the database is larger than its quota.
+type QuotaError struct {
+	// bytes used by the database
+	Size    int64
+	MaxSize int64
+}
+
+func (e *QuotaError) Error() string {
+	return fmt.Sprintf("%s (%d of %d bytes)", ErrQuotaExceeded, e.Size, e.MaxSize)
+}
+
+func (e *QuotaError) Is(target error) bool {
+	return target == ErrQuotaExceeded
+}
+
+// sizeQuota limits the size of the database.
+type sizeQuota struct {
+	// bytes, 0 disables the quota
+	MaxSize int64
+	// optionally called to free space (e.g. by pruning archived events)
+	// before a write is rejected
+	OnExceeded func(ctx context.Context) error
+}
+
+// usedSize returns the bytes of all pages in use. Free pages are not counted,
+// as they are reused by later writes.
+func usedSize(ctx context.Context, db *sql.DB) (int64, error) {
+	var size int64
+	query := `SELECT (p.page_count - f.freelist_count) * s.page_size FROM pragma_page_count() p, pragma_freelist_count() f, pragma_page_size() s;`
+	if err := db.QueryRowContext(ctx, query).Scan(&size); err != nil {
+		return 0, err
+	}
+	return size, nil
+}
+
+// check returns a QuotaError if the database exceeds the quota, even after OnExceeded ran.
+func (q sizeQuota) check(ctx context.Context, db *sql.DB) error {
+	if q.MaxSize <= 0 {
+		return nil
+	}
+	size, err := usedSize(ctx, db)
+	if err != nil || size <= q.MaxSize {
+		return err
+	}
+	if q.OnExceeded != nil {
+		if err := q.OnExceeded(ctx); err != nil {
+			return err
+		}
+		if size, err = usedSize(ctx, db); err != nil || size <= q.MaxSize {
+			return err
+		}
+	}
+	return &QuotaError{Size: size, MaxSize: q.MaxSize}
+}
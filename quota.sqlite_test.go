@@ -0,0 +1,72 @@
+package store_test
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	store "github.com/gradientzero/comby-store-sqlite"
+	"github.com/gradientzero/comby/v3"
+)
+
+func TestEventStoreMaxSize(t *testing.T) {
+	ctx := context.Background()
+	eventStore := store.NewEventStoreSQLite(filepath.Join(t.TempDir(), "eventStore-quota.db"))
+	eventStore.Configure(store.EventStoreSQLiteWithMaxSize(256*1024, nil))
+	if err := eventStore.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer eventStore.Close(ctx)
+
+	var err error
+	for i := int64(1); i <= 100 && err == nil; i++ {
+		evt := createTestEvent("tenant-1", "domain-1", i, i*100)
+		evt.SetDomainEvtBytes([]byte(strings.Repeat("x", 16*1024)))
+		err = eventStore.Create(ctx, comby.EventStoreCreateOptionWithEvent(evt))
+	}
+	if !errors.Is(err, store.ErrQuotaExceeded) {
+		t.Fatalf("expected quota error, got %v", err)
+	}
+	var quotaErr *store.QuotaError
+	if !errors.As(err, &quotaErr) || quotaErr.Size <= quotaErr.MaxSize {
+		t.Fatalf("unexpected quota error: %v", err)
+	}
+}
+
+func TestEventStoreMaxSizeOnExceeded(t *testing.T) {
+	ctx := context.Background()
+	eventStore := store.NewEventStoreSQLite(filepath.Join(t.TempDir(), "eventStore-quota.db"))
+	if err := eventStore.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer eventStore.Close(ctx)
+
+	// frees space by deleting the oldest events
+	var pruned int
+	eventStore.Configure(store.EventStoreSQLiteWithMaxSize(256*1024, func(ctx context.Context) error {
+		evts, _, err := eventStore.List(ctx)
+		if err != nil {
+			return err
+		}
+		for _, evt := range evts[:min(10, len(evts))] {
+			if err := eventStore.Delete(ctx, comby.EventStoreDeleteOptionWithEventUuid(evt.GetEventUuid())); err != nil {
+				return err
+			}
+			pruned++
+		}
+		return nil
+	}))
+
+	for i := int64(1); i <= 100; i++ {
+		evt := createTestEvent("tenant-1", "domain-1", i, i*100)
+		evt.SetDomainEvtBytes([]byte(strings.Repeat("x", 16*1024)))
+		if err := eventStore.Create(ctx, comby.EventStoreCreateOptionWithEvent(evt)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if pruned == 0 {
+		t.Fatal("expected events to be pruned")
+	}
+}
@@ -0,0 +1,198 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrWriteBackpressure is returned by writes when more writes than configured
+// are already pending. Callers should back off and retry later.
+var ErrWriteBackpressure = errors.New("too many pending writes")
+
+// WriteStats describes the write load of a store.
+type WriteStats struct {
+	// writes waiting for the rate limiter or the write lock
+	Pending int64
+	// writes delayed by the rate limiter
+	Throttled uint64
+	// writes rejected with ErrWriteBackpressure
+	Rejected uint64
+}
+
+// writeGate limits writes with a token bucket and tracks pending writes.
+// The zero value is ready to use.
+type writeGate struct {
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+
+	pending   atomic.Int64
+	throttled atomic.Uint64
+	rejected  atomic.Uint64
+}
+
+// enter registers a pending write and waits until the rate limit allows it.
+// Every successful enter must be followed by leave.
+func (g *writeGate) enter(ctx context.Context, limit writeLimit) error {
+	if pending := g.pending.Add(1); limit.MaxPending > 0 && pending > int64(limit.MaxPending) {
+		g.pending.Add(-1)
+		g.rejected.Add(1)
+		return ErrWriteBackpressure
+	}
+	if err := g.wait(ctx, limit.PerSecond, limit.Burst); err != nil {
+		g.pending.Add(-1)
+		return err
+	}
+	return nil
+}
+
+func (g *writeGate) leave() {
+	g.pending.Add(-1)
+}
+
+// wait reserves a token and sleeps until it becomes available
+func (g *writeGate) wait(ctx context.Context, perSecond float64, burst int) error {
+	if perSecond <= 0 {
+		return nil
+	}
+	if burst < 1 {
+		burst = 1
+	}
+
+	g.mu.Lock()
+	now := time.Now()
+	if g.last.IsZero() {
+		g.tokens = float64(burst)
+	} else {
+		g.tokens = min(float64(burst), g.tokens+now.Sub(g.last).Seconds()*perSecond)
+	}
+	g.last = now
+	g.tokens--
+	delay := time.Duration(-g.tokens / perSecond * float64(time.Second))
+	g.mu.Unlock()
+
+	if delay <= 0 {
+		return nil
+	}
+	g.throttled.Add(1)
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		// hand back the reserved token
+		g.mu.Lock()
+		g.tokens++
+		g.mu.Unlock()
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+func (g *writeGate) stats() WriteStats {
+	return WriteStats{
+		Pending:   g.pending.Load(),
+		Throttled: g.throttled.Load(),
+		Rejected:  g.rejected.Load(),
+	}
+}
+
+// writeLimit configures the writeGate of a store.
+type writeLimit struct {
+	// token bucket limiting writes per second, 0 disables the limiter
+	PerSecond float64
+	Burst     int
+	// writes beyond this number of pending ones fail with ErrWriteBackpressure, 0 disables the check
+	MaxPending int
+}
+
+// begin waits for the rate limiter and then locks mu, the returned func
+// releases both.
+func (g *writeGate) begin(ctx context.Context, mu *sync.Mutex, limit writeLimit) (func(), error) {
+	if err := g.enter(ctx, limit); err != nil {
+		return nil, err
+	}
+	if err := lockContext(ctx, mu); err != nil {
+		g.leave()
+		return nil, err
+	}
+	return func() {
+		mu.Unlock()
+		g.leave()
+	}, nil
+}
+
+// lockContext locks mu unless ctx is done first.
+func lockContext(ctx context.Context, mu *sync.Mutex) error {
+	if ctx.Done() == nil {
+		mu.Lock()
+		return nil
+	}
+	if mu.TryLock() {
+		return nil
+	}
+	locked := make(chan struct{})
+	go func() {
+		mu.Lock()
+		close(locked)
+	}()
+	select {
+	case <-locked:
+		return nil
+	case <-ctx.Done():
+		// hand the lock back as soon as the waiting goroutine gets it
+		go func() {
+			<-locked
+			mu.Unlock()
+		}()
+		return ctx.Err()
+	}
+}
+
+func (es *eventStoreSQLite) beginWrite(ctx context.Context) (func(), error) {
+	done, err := es.gate.begin(ctx, es.writeMu, es.cfg().WriteLimit)
+	if err != nil {
+		return nil, err
+	}
+	// invalidate before other writers may start
+	return func() {
+		es.invalidateCache()
+		done()
+		es.changes.notify()
+	}, nil
+}
+
+func (es *eventStoreSQLite) WriteStats() WriteStats {
+	return es.gate.stats()
+}
+
+func (cs *commandStoreSQLite) beginWrite(ctx context.Context) (func(), error) {
+	done, err := cs.gate.begin(ctx, cs.writeMu, cs.cfg().WriteLimit)
+	if err != nil {
+		return nil, err
+	}
+	return func() {
+		done()
+		cs.changes.notify()
+	}, nil
+}
+
+func (cs *commandStoreSQLite) WriteStats() WriteStats {
+	return cs.gate.stats()
+}
+
+// beginWrite goes through the limiter of the event store, or the command
+// store if no event store was requested.
+func (s *Stores) beginWrite(ctx context.Context) (func(), error) {
+	if es, ok := s.EventStore.(*eventStoreSQLite); ok {
+		return es.beginWrite(ctx)
+	}
+	if cs, ok := s.CommandStore.(*commandStoreSQLite); ok {
+		return cs.beginWrite(ctx)
+	}
+	s.writeMu.Lock()
+	return s.writeMu.Unlock, nil
+}
@@ -0,0 +1,106 @@
+package store_test
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	store "github.com/gradientzero/comby-store-sqlite"
+	"github.com/gradientzero/comby/v3"
+)
+
+func TestEventStoreWriteRateLimit(t *testing.T) {
+	ctx := context.Background()
+	eventStore := store.NewEventStoreSQLite(filepath.Join(t.TempDir(), "eventStore-ratelimit.db"))
+	eventStore.Configure(store.EventStoreSQLiteWithWriteRateLimit(20, 1))
+	if err := eventStore.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer eventStore.Close(ctx)
+
+	// first write uses the burst, the remaining four wait 50ms each
+	start := time.Now()
+	for i := int64(1); i <= 5; i++ {
+		evt := createTestEvent("tenant-1", "domain-1", i, i*100)
+		if err := eventStore.Create(ctx, comby.EventStoreCreateOptionWithEvent(evt)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 150*time.Millisecond {
+		t.Fatalf("expected writes to be throttled, took %s", elapsed)
+	}
+	stats := eventStore.WriteStats()
+	if stats.Throttled < 3 {
+		t.Fatalf("expected at least 3 throttled writes, got %d", stats.Throttled)
+	}
+	if stats.Pending != 0 {
+		t.Fatalf("expected no pending writes, got %d", stats.Pending)
+	}
+
+	// the bucket is empty, waiting for the next token respects the context
+	cancelCtx, cancel := context.WithTimeout(ctx, 5*time.Millisecond)
+	defer cancel()
+	eventStore.Configure(store.EventStoreSQLiteWithWriteRateLimit(0.1, 1))
+	evt := createTestEvent("tenant-1", "domain-1", 6, 600)
+	if err := eventStore.Create(cancelCtx, comby.EventStoreCreateOptionWithEvent(evt)); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected deadline exceeded, got %v", err)
+	}
+}
+
+func TestEventStoreWriteBackpressure(t *testing.T) {
+	ctx := context.Background()
+	eventStore := store.NewEventStoreSQLite(filepath.Join(t.TempDir(), "eventStore-backpressure.db"))
+	eventStore.Configure(store.EventStoreSQLiteWithMaxPendingWrites(1))
+	if err := eventStore.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer eventStore.Close(ctx)
+
+	// the open transaction is the one pending write, further writes are rejected
+	if err := eventStore.WithTx(ctx, func(tx store.EventStoreTx) error {
+		if stats := eventStore.WriteStats(); stats.Pending != 1 {
+			t.Errorf("expected 1 pending write, got %d", stats.Pending)
+		}
+		evt := createTestEvent("tenant-1", "domain-1", 1, 100)
+		if err := eventStore.Create(ctx, comby.EventStoreCreateOptionWithEvent(evt)); !errors.Is(err, store.ErrWriteBackpressure) {
+			t.Errorf("expected backpressure error, got %v", err)
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	stats := eventStore.WriteStats()
+	if stats.Rejected != 1 || stats.Pending != 0 {
+		t.Fatalf("unexpected stats: %+v", stats)
+	}
+	evt := createTestEvent("tenant-1", "domain-1", 1, 100)
+	if err := eventStore.Create(ctx, comby.EventStoreCreateOptionWithEvent(evt)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCommandStoreWriteBackpressure(t *testing.T) {
+	ctx := context.Background()
+	commandStore := store.NewCommandStoreSQLite(filepath.Join(t.TempDir(), "commandStore-backpressure.db"))
+	commandStore.Configure(store.CommandStoreSQLiteWithMaxPendingWrites(1))
+	if err := commandStore.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer commandStore.Close(ctx)
+
+	if err := commandStore.WithTx(ctx, func(tx store.CommandStoreTx) error {
+		cmd := createTestCommand("tenant-1", "domain-1", 100)
+		if err := commandStore.Create(ctx, comby.CommandStoreCreateOptionWithCommand(cmd)); !errors.Is(err, store.ErrWriteBackpressure) {
+			t.Errorf("expected backpressure error, got %v", err)
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if stats := commandStore.WriteStats(); stats.Rejected != 1 {
+		t.Fatalf("expected 1 rejected write, got %d", stats.Rejected)
+	}
+}
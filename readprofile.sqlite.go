@@ -0,0 +1,70 @@
+package store
+
+import "fmt"
+
+// settings of the read-optimized profile
+const (
+	readOptimizedMmapSize  = 256 << 20
+	readOptimizedCacheSize = 64 << 20
+)
+
+// readProfile holds connection pragmas tuning reads, zero values keep the
+// SQLite defaults.
+type readProfile struct {
+	// bytes of the database file accessed through memory-mapped I/O
+	MmapSize int64
+	// bytes of the page cache per connection
+	CacheSize int64
+	// temporary tables and indexes (e.g. of ORDER BY) in memory
+	TempStoreMemory bool
+	// reject any change of the database file
+	QueryOnly bool
+}
+
+func (p readProfile) pragmas() []string {
+	var pragmas []string
+	if p.MmapSize > 0 {
+		pragmas = append(pragmas, fmt.Sprintf("mmap_size(%d)", p.MmapSize))
+	}
+	if p.CacheSize > 0 {
+		// negative values are KiB instead of pages
+		pragmas = append(pragmas, fmt.Sprintf("cache_size(%d)", -p.CacheSize/1024))
+	}
+	if p.TempStoreMemory {
+		pragmas = append(pragmas, "temp_store(memory)")
+	}
+	if p.QueryOnly {
+		pragmas = append(pragmas, "query_only(1)")
+	}
+	return pragmas
+}
+
+var readOptimizedProfile = readProfile{
+	MmapSize:        readOptimizedMmapSize,
+	CacheSize:       readOptimizedCacheSize,
+	TempStoreMemory: true,
+}
+
+// EventStoreSQLiteWithMmapSize reads up to bytes of the database file through
+// memory-mapped I/O, which saves copying pages on large sequential reads.
+// Takes effect on Init.
+func EventStoreSQLiteWithMmapSize(bytes int64) EventStoreSQLiteOption {
+	return func(c *eventStoreSQLiteConfig) { c.ReadProfile.MmapSize = bytes }
+}
+
+// EventStoreSQLiteWithReadOptimizedProfile tunes connections for read heavy
+// workloads like full-history replays: 256 MiB mmap, a 64 MiB page cache per
+// connection and temporary storage in memory. Takes effect on Init.
+func EventStoreSQLiteWithReadOptimizedProfile() EventStoreSQLiteOption {
+	return func(c *eventStoreSQLiteConfig) { c.ReadProfile = readOptimizedProfile }
+}
+
+// CommandStoreSQLiteWithMmapSize is EventStoreSQLiteWithMmapSize for the command store.
+func CommandStoreSQLiteWithMmapSize(bytes int64) CommandStoreSQLiteOption {
+	return func(c *commandStoreSQLiteConfig) { c.ReadProfile.MmapSize = bytes }
+}
+
+// CommandStoreSQLiteWithReadOptimizedProfile is EventStoreSQLiteWithReadOptimizedProfile for the command store.
+func CommandStoreSQLiteWithReadOptimizedProfile() CommandStoreSQLiteOption {
+	return func(c *commandStoreSQLiteConfig) { c.ReadProfile = readOptimizedProfile }
+}
@@ -0,0 +1,67 @@
+package store_test
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	store "github.com/gradientzero/comby-store-sqlite"
+	"github.com/gradientzero/comby/v3"
+)
+
+func TestEventStoreReadOptimizedProfile(t *testing.T) {
+	ctx := context.Background()
+	eventStore := store.NewEventStoreSQLite(filepath.Join(t.TempDir(), "eventStore-readprofile.db"))
+	eventStore.Configure(store.EventStoreSQLiteWithReadOptimizedProfile())
+	if err := eventStore.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer eventStore.Close(ctx)
+	for i := int64(1); i <= 20; i++ {
+		if err := eventStore.Create(ctx, comby.EventStoreCreateOptionWithEvent(createTestEvent("tenant-1", "domain-1", i, i*100))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var numEvents int
+	if err := eventStore.ListBatches(ctx, 7, func(evts []comby.Event) error {
+		numEvents += len(evts)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if numEvents != 20 {
+		t.Fatalf("expected 20 events, got %d", numEvents)
+	}
+}
+
+func BenchmarkEventStoreReplay(b *testing.B) {
+	ctx := context.Background()
+	for _, bc := range []struct {
+		name string
+		opts []store.EventStoreSQLiteOption
+	}{
+		{"default", nil},
+		{"read-optimized", []store.EventStoreSQLiteOption{store.EventStoreSQLiteWithReadOptimizedProfile()}},
+	} {
+		b.Run(bc.name, func(b *testing.B) {
+			eventStore := store.NewEventStoreSQLite(filepath.Join(b.TempDir(), "eventStore-replay.db"))
+			eventStore.Configure(bc.opts...)
+			if err := eventStore.Init(ctx); err != nil {
+				b.Fatal(err)
+			}
+			defer eventStore.Close(ctx)
+			for i := int64(1); i <= 5000; i++ {
+				if err := eventStore.Create(ctx, comby.EventStoreCreateOptionWithEvent(createTestEvent("tenant-1", "domain-1", i, i))); err != nil {
+					b.Fatal(err)
+				}
+			}
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if err := eventStore.ListBatches(ctx, 1000, func(evts []comby.Event) error { return nil }); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,180 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/gradientzero/comby/v3"
+)
+
+// EventStoreReadSnapshot reads the event store as of the moment it was
+// opened, e.g. to fetch a page and its total count consistently while writers
+// are appending. Reads bypass the query cache and the archive read-through.
+type EventStoreReadSnapshot interface {
+	Get(ctx context.Context, opts ...comby.EventStoreGetOption) (comby.Event, error)
+	List(ctx context.Context, opts ...comby.EventStoreListOption) ([]comby.Event, int64, error)
+	Total(ctx context.Context) int64
+	// Close releases the snapshot. It must be called once the reads are done.
+	Close() error
+}
+
+// CommandStoreReadSnapshot reads the command store as of the moment it was
+// opened, see EventStoreReadSnapshot.
+type CommandStoreReadSnapshot interface {
+	Get(ctx context.Context, opts ...comby.CommandStoreGetOption) (comby.Command, error)
+	List(ctx context.Context, opts ...comby.CommandStoreListOption) ([]comby.Command, int64, error)
+	Total(ctx context.Context) int64
+	// Close releases the snapshot. It must be called once the reads are done.
+	Close() error
+}
+
+// beginReadSnapshot starts a read transaction and pins its snapshot. SQLite
+// takes the snapshot with the first read, not with BEGIN, so the schema is
+// read right away.
+//
+// The transaction holds a pooled connection until it is closed and, in WAL
+// mode, keeps checkpoints from truncating the log, so snapshots should be
+// short-lived. It is rolled back when ctx is done.
+func beginReadSnapshot(ctx context.Context, db *sql.DB) (*sql.Tx, error) {
+	tx, err := db.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return nil, classifyError(err)
+	}
+	var n int64
+	if err := tx.QueryRowContext(ctx, "SELECT COUNT(*) FROM sqlite_master;").Scan(&n); err != nil {
+		tx.Rollback()
+		return nil, classifyError(err)
+	}
+	return tx, nil
+}
+
+// ReadSnapshot opens a consistent read view of the event store, which stays
+// valid until Close is called or ctx is done.
+func (es *eventStoreSQLite) ReadSnapshot(ctx context.Context) (EventStoreReadSnapshot, error) {
+	if es.db == nil {
+		return nil, fmt.Errorf("'%s' failed to open read snapshot - store is not initialized", es.String())
+	}
+	tx, err := beginReadSnapshot(ctx, es.db)
+	if err != nil {
+		return nil, fmt.Errorf("'%s' failed to open read snapshot - %w", es.String(), err)
+	}
+	return &eventStoreReadSnapshot{es: es, tx: tx}, nil
+}
+
+// ReadSnapshot opens a consistent read view of the command store, which stays
+// valid until Close is called or ctx is done.
+func (cs *commandStoreSQLite) ReadSnapshot(ctx context.Context) (CommandStoreReadSnapshot, error) {
+	if cs.db == nil {
+		return nil, fmt.Errorf("'%s' failed to open read snapshot - store is not initialized", cs.String())
+	}
+	tx, err := beginReadSnapshot(ctx, cs.db)
+	if err != nil {
+		return nil, fmt.Errorf("'%s' failed to open read snapshot - %w", cs.String(), err)
+	}
+	return &commandStoreReadSnapshot{cs: cs, tx: tx}, nil
+}
+
+type eventStoreReadSnapshot struct {
+	es *eventStoreSQLite
+	tx *sql.Tx
+}
+
+func (s *eventStoreReadSnapshot) Get(ctx context.Context, opts ...comby.EventStoreGetOption) (comby.Event, error) {
+	ctx, cancel := s.es.cfg().Timeouts.read(ctx)
+	defer cancel()
+	getOpts := comby.EventStoreGetOptions{}
+	for _, opt := range opts {
+		if _, err := opt(&getOpts); err != nil {
+			return nil, err
+		}
+	}
+	if len(getOpts.EventUuid) == 0 {
+		return nil, fmt.Errorf("'%s' failed to get event - event uuid is required", s.es.String())
+	}
+	evt, err := s.es.get(ctx, s.tx, "events", getOpts.EventUuid)
+	if err != nil {
+		return nil, classifyError(err)
+	}
+	if err := s.es.authorizeGet(ctx, getOpts.EventUuid, evt); err != nil {
+		return nil, err
+	}
+	return evt, nil
+}
+
+func (s *eventStoreReadSnapshot) List(ctx context.Context, opts ...comby.EventStoreListOption) ([]comby.Event, int64, error) {
+	ctx, cancel := s.es.cfg().Timeouts.read(ctx)
+	defer cancel()
+	listOpts, filter, err := s.es.listOptions(opts)
+	if err != nil {
+		return nil, 0, err
+	}
+	if err := s.es.authorizeList(ctx, listOpts, filter); err != nil {
+		return nil, 0, err
+	}
+	evts, total, err := s.es.list(ctx, s.tx, "events", listOpts, filter)
+	return evts, total, classifyError(err)
+}
+
+func (s *eventStoreReadSnapshot) Total(ctx context.Context) int64 {
+	return countRows(ctx, s.tx, "events", s.es.cfg().Tenant)
+}
+
+func (s *eventStoreReadSnapshot) Close() error {
+	if err := s.tx.Rollback(); err != nil && err != sql.ErrTxDone {
+		return classifyError(err)
+	}
+	return nil
+}
+
+type commandStoreReadSnapshot struct {
+	cs *commandStoreSQLite
+	tx *sql.Tx
+}
+
+func (s *commandStoreReadSnapshot) Get(ctx context.Context, opts ...comby.CommandStoreGetOption) (comby.Command, error) {
+	ctx, cancel := s.cs.cfg().Timeouts.read(ctx)
+	defer cancel()
+	getOpts := comby.CommandStoreGetOptions{}
+	for _, opt := range opts {
+		if _, err := opt(&getOpts); err != nil {
+			return nil, err
+		}
+	}
+	if len(getOpts.CommandUuid) == 0 {
+		return nil, fmt.Errorf("'%s' failed to get command - command uuid is required", s.cs.String())
+	}
+	cmd, err := s.cs.get(ctx, s.tx, getOpts.CommandUuid)
+	if err != nil {
+		return nil, classifyError(err)
+	}
+	if err := s.cs.authorizeGet(ctx, getOpts.CommandUuid, cmd); err != nil {
+		return nil, err
+	}
+	return cmd, nil
+}
+
+func (s *commandStoreReadSnapshot) List(ctx context.Context, opts ...comby.CommandStoreListOption) ([]comby.Command, int64, error) {
+	ctx, cancel := s.cs.cfg().Timeouts.read(ctx)
+	defer cancel()
+	listOpts, filter, err := s.cs.listOptions(opts)
+	if err != nil {
+		return nil, 0, err
+	}
+	if err := s.cs.authorizeList(ctx, listOpts, filter); err != nil {
+		return nil, 0, err
+	}
+	cmds, total, err := s.cs.list(ctx, s.tx, listOpts, filter)
+	return cmds, total, classifyError(err)
+}
+
+func (s *commandStoreReadSnapshot) Total(ctx context.Context) int64 {
+	return countRows(ctx, s.tx, "commands", s.cs.cfg().Tenant)
+}
+
+func (s *commandStoreReadSnapshot) Close() error {
+	if err := s.tx.Rollback(); err != nil && err != sql.ErrTxDone {
+		return classifyError(err)
+	}
+	return nil
+}
@@ -0,0 +1,108 @@
+package store_test
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	store "github.com/gradientzero/comby-store-sqlite"
+	"github.com/gradientzero/comby/v3"
+)
+
+func TestEventStoreReadSnapshot(t *testing.T) {
+	ctx := context.Background()
+	eventStore := store.NewEventStoreSQLite(filepath.Join(t.TempDir(), "snapshot.db"))
+	if err := eventStore.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer eventStore.Close(ctx)
+
+	create := func(version int64) comby.Event {
+		evt := createTestEvent("tenant-1", "domain-1", version, version)
+		if err := eventStore.Create(ctx, comby.EventStoreCreateOptionWithEvent(evt)); err != nil {
+			t.Fatal(err)
+		}
+		return evt
+	}
+	for i := int64(1); i <= 3; i++ {
+		create(i)
+	}
+
+	snapshot, err := eventStore.ReadSnapshot(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer snapshot.Close()
+
+	// writers are not blocked by the snapshot
+	later := create(4)
+
+	if total := snapshot.Total(ctx); total != 3 {
+		t.Fatalf("expected total 3 in snapshot, got %d", total)
+	}
+	evts, total, err := snapshot.List(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(evts) != 3 || total != 3 {
+		t.Fatalf("expected 3 events in snapshot, got %d (total %d)", len(evts), total)
+	}
+	if evt, err := snapshot.Get(ctx, comby.EventStoreGetOptionWithEventUuid(later.GetEventUuid())); err != nil || evt != nil {
+		t.Fatalf("expected later event to be invisible in snapshot, got %v, %v", evt, err)
+	}
+	if evt, err := snapshot.Get(ctx, comby.EventStoreGetOptionWithEventUuid(evts[0].GetEventUuid())); err != nil || evt == nil {
+		t.Fatalf("expected event in snapshot, got %v, %v", evt, err)
+	}
+	if total := eventStore.Total(ctx); total != 4 {
+		t.Fatalf("expected total 4 in store, got %d", total)
+	}
+
+	if err := snapshot.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := snapshot.Close(); err != nil {
+		t.Fatalf("expected second close to succeed, got %v", err)
+	}
+	if _, _, err := snapshot.List(ctx); err == nil {
+		t.Fatal("expected list on closed snapshot to fail")
+	}
+}
+
+func TestCommandStoreReadSnapshot(t *testing.T) {
+	ctx := context.Background()
+	commandStore := store.NewCommandStoreSQLite(filepath.Join(t.TempDir(), "snapshot.db"))
+	if err := commandStore.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer commandStore.Close(ctx)
+
+	create := func(createdAt int64) comby.Command {
+		cmd := createTestCommand("tenant-1", "domain-1", createdAt)
+		if err := commandStore.Create(ctx, comby.CommandStoreCreateOptionWithCommand(cmd)); err != nil {
+			t.Fatal(err)
+		}
+		return cmd
+	}
+	first := create(1)
+
+	snapshot, err := commandStore.ReadSnapshot(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer snapshot.Close()
+	create(2)
+
+	cmds, total, err := snapshot.List(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cmds) != 1 || total != 1 || snapshot.Total(ctx) != 1 {
+		t.Fatalf("expected 1 command in snapshot, got %d (total %d)", len(cmds), total)
+	}
+	if cmd, err := snapshot.Get(ctx, comby.CommandStoreGetOptionWithCommandUuid(first.GetCommandUuid())); err != nil || cmd == nil {
+		t.Fatalf("expected command in snapshot, got %v, %v", cmd, err)
+	}
+	if total := commandStore.Total(ctx); total != 2 {
+		t.Fatalf("expected total 2 in store, got %d", total)
+	}
+}
@@ -0,0 +1,487 @@
+package remote
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	store "github.com/gradientzero/comby-store-sqlite"
+	"github.com/gradientzero/comby/v3"
+)
+
+type ClientOption func(*client)
+
+// ClientWithHTTPClient sets the http client, e.g. for TLS or timeouts.
+func ClientWithHTTPClient(httpClient *http.Client) ClientOption {
+	return func(c *client) {
+		c.httpClient = httpClient
+	}
+}
+
+// ClientWithHeader adds a header to all requests, e.g. for authentication.
+func ClientWithHeader(key, value string) ClientOption {
+	return func(c *client) {
+		c.header.Add(key, value)
+	}
+}
+
+// client sends requests to a Server.
+type client struct {
+	baseURL    string
+	httpClient *http.Client
+	header     http.Header
+}
+
+func newClient(baseURL string, opts ...ClientOption) client {
+	c := client{
+		baseURL:    strings.TrimSuffix(baseURL, "/"),
+		httpClient: http.DefaultClient,
+		header:     http.Header{},
+	}
+	for _, opt := range opts {
+		opt(&c)
+	}
+	return c
+}
+
+// do sends a request and returns the response if it has the wanted status.
+func (c *client) do(ctx context.Context, method, path string, query url.Values, body any, status int) (*http.Response, error) {
+	var r io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		r = bytes.NewReader(b)
+	}
+	u := c.baseURL + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u, r)
+	if err != nil {
+		return nil, err
+	}
+	for key, values := range c.header {
+		req.Header[key] = values
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	res, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode != status {
+		defer res.Body.Close()
+		var resErr errorJSON
+		if err := json.NewDecoder(res.Body).Decode(&resErr); err != nil || len(resErr.Error) == 0 {
+			resErr.Error = res.Status
+		}
+		return res, fmt.Errorf("%s", resErr.Error)
+	}
+	return res, nil
+}
+
+// get decodes the response of a GET request into v.
+func (c *client) get(ctx context.Context, path string, query url.Values, v any) error {
+	res, err := c.do(ctx, http.MethodGet, path, query, nil, http.StatusOK)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	return json.NewDecoder(res.Body).Decode(v)
+}
+
+func (c *client) post(ctx context.Context, path string, body any) error {
+	res, err := c.do(ctx, http.MethodPost, path, nil, body, http.StatusCreated)
+	if err != nil {
+		return err
+	}
+	return res.Body.Close()
+}
+
+// EventStoreClient is a comby.EventStore backed by a remote Server. Update,
+// Delete and Reset are not available remotely.
+type EventStoreClient interface {
+	comby.EventStore
+	// Subscribe calls handler for all events after the given sequence and
+	// for new events as they arrive, until ctx is done or the connection
+	// drops. It returns the last delivered sequence to resume from.
+	Subscribe(ctx context.Context, after int64, handler store.ReplayHandler) (int64, error)
+}
+
+type eventStoreClient struct {
+	client
+	options comby.EventStoreOptions
+}
+
+func NewEventStoreClient(baseURL string, opts ...ClientOption) EventStoreClient {
+	return &eventStoreClient{client: newClient(baseURL, opts...)}
+}
+
+func (c *eventStoreClient) Init(ctx context.Context, opts ...comby.EventStoreOption) error {
+	for _, opt := range opts {
+		if _, err := opt(&c.options); err != nil {
+			return err
+		}
+	}
+	// server must be reachable
+	_, err := c.Info(ctx)
+	return err
+}
+
+func (c *eventStoreClient) Create(ctx context.Context, opts ...comby.EventStoreCreateOption) error {
+	createOpts := comby.EventStoreCreateOptions{}
+	for _, opt := range opts {
+		if _, err := opt(&createOpts); err != nil {
+			return err
+		}
+	}
+	if createOpts.Event == nil {
+		return fmt.Errorf("'%s' failed to create event - event is nil", c.String())
+	}
+	body, err := encodeEvent(createOpts.Event)
+	if err != nil {
+		return err
+	}
+	if err := c.post(ctx, "/events", body); err != nil {
+		return fmt.Errorf("'%s' failed to create event - %w", c.String(), err)
+	}
+	return nil
+}
+
+func (c *eventStoreClient) Get(ctx context.Context, opts ...comby.EventStoreGetOption) (comby.Event, error) {
+	getOpts := comby.EventStoreGetOptions{}
+	for _, opt := range opts {
+		if _, err := opt(&getOpts); err != nil {
+			return nil, err
+		}
+	}
+	if len(getOpts.EventUuid) == 0 {
+		return nil, fmt.Errorf("'%s' failed to get event - event uuid is required", c.String())
+	}
+	res, err := c.do(ctx, http.MethodGet, "/events/"+url.PathEscape(getOpts.EventUuid), nil, nil, http.StatusOK)
+	if res != nil && res.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("'%s' failed to get event - %w", c.String(), err)
+	}
+	defer res.Body.Close()
+	var item eventJSON
+	if err := json.NewDecoder(res.Body).Decode(&item); err != nil {
+		return nil, err
+	}
+	return item.decode()
+}
+
+func (c *eventStoreClient) List(ctx context.Context, opts ...comby.EventStoreListOption) ([]comby.Event, int64, error) {
+	listOpts := comby.EventStoreListOptions{
+		Before:    -1,
+		After:     -1,
+		Offset:    0,
+		Limit:     100,
+		OrderBy:   "created_at",
+		Ascending: true,
+	}
+	for _, opt := range opts {
+		if _, err := opt(&listOpts); err != nil {
+			return nil, 0, err
+		}
+	}
+	query := url.Values{}
+	listParams{
+		Before:    listOpts.Before,
+		After:     listOpts.After,
+		Offset:    listOpts.Offset,
+		Limit:     listOpts.Limit,
+		OrderBy:   listOpts.OrderBy,
+		Ascending: listOpts.Ascending,
+	}.encode(query)
+	setIfNotEmpty(query, "tenant_uuid", listOpts.TenantUuid)
+	setIfNotEmpty(query, "aggregate_uuid", listOpts.AggregateUuid)
+	setIfNotEmpty(query, "data_type", listOpts.DataType)
+	for _, domain := range listOpts.Domains {
+		query.Add("domain", domain)
+	}
+
+	var res listJSON[*eventJSON]
+	if err := c.get(ctx, "/events", query, &res); err != nil {
+		return nil, 0, fmt.Errorf("'%s' failed to list events - %w", c.String(), err)
+	}
+	evts := make([]comby.Event, 0, len(res.Items))
+	for _, item := range res.Items {
+		evt, err := item.decode()
+		if err != nil {
+			return nil, 0, err
+		}
+		evts = append(evts, evt)
+	}
+	return evts, res.Total, nil
+}
+
+func (c *eventStoreClient) Update(ctx context.Context, opts ...comby.EventStoreUpdateOption) error {
+	return fmt.Errorf("'%s' failed to update event - not supported by remote store", c.String())
+}
+
+func (c *eventStoreClient) Delete(ctx context.Context, opts ...comby.EventStoreDeleteOption) error {
+	return fmt.Errorf("'%s' failed to delete event - not supported by remote store", c.String())
+}
+
+func (c *eventStoreClient) Total(ctx context.Context) int64 {
+	info, err := c.Info(ctx)
+	if err != nil {
+		return 0
+	}
+	return info.NumItems
+}
+
+func (c *eventStoreClient) UniqueList(ctx context.Context, opts ...comby.EventStoreUniqueListOption) ([]string, int64, error) {
+	listOpts := comby.EventStoreUniqueListOptions{
+		DbField:   "tenant_uuid",
+		Offset:    0,
+		Limit:     100,
+		Ascending: true,
+	}
+	for _, opt := range opts {
+		if _, err := opt(&listOpts); err != nil {
+			return nil, 0, err
+		}
+	}
+	query := url.Values{}
+	query.Set("db_field", listOpts.DbField)
+	query.Set("offset", strconv.FormatInt(listOpts.Offset, 10))
+	query.Set("limit", strconv.FormatInt(listOpts.Limit, 10))
+	query.Set("ascending", strconv.FormatBool(listOpts.Ascending))
+	setIfNotEmpty(query, "tenant_uuid", listOpts.TenantUuid)
+	setIfNotEmpty(query, "domain", listOpts.Domain)
+
+	var res listJSON[string]
+	if err := c.get(ctx, "/events/unique", query, &res); err != nil {
+		return nil, 0, fmt.Errorf("'%s' failed to list unique values - %w", c.String(), err)
+	}
+	return res.Items, res.Total, nil
+}
+
+func (c *eventStoreClient) Close(ctx context.Context) error {
+	c.httpClient.CloseIdleConnections()
+	return nil
+}
+
+func (c *eventStoreClient) Options() comby.EventStoreOptions {
+	return c.options
+}
+
+func (c *eventStoreClient) String() string {
+	return fmt.Sprintf("remote - %s", c.baseURL)
+}
+
+func (c *eventStoreClient) Info(ctx context.Context) (*comby.EventStoreInfoModel, error) {
+	var info comby.EventStoreInfoModel
+	if err := c.get(ctx, "/events/info", nil, &info); err != nil {
+		return nil, fmt.Errorf("'%s' failed to get info - %w", c.String(), err)
+	}
+	return &info, nil
+}
+
+func (c *eventStoreClient) Reset(ctx context.Context) error {
+	return fmt.Errorf("'%s' failed to reset - not supported by remote store", c.String())
+}
+
+func (c *eventStoreClient) Subscribe(ctx context.Context, after int64, handler store.ReplayHandler) (int64, error) {
+	if handler == nil {
+		return after, fmt.Errorf("'%s' failed to subscribe - handler is nil", c.String())
+	}
+	query := url.Values{}
+	query.Set("after", strconv.FormatInt(after, 10))
+	res, err := c.do(ctx, http.MethodGet, "/events/subscribe", query, nil, http.StatusOK)
+	if err != nil {
+		return after, fmt.Errorf("'%s' failed to subscribe - %w", c.String(), err)
+	}
+	defer res.Body.Close()
+
+	scanner := bufio.NewScanner(res.Body)
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	for scanner.Scan() {
+		var line subscriptionJSON
+		if err := json.Unmarshal(scanner.Bytes(), &line); err != nil {
+			return after, err
+		}
+		evt, err := line.Event.decode()
+		if err != nil {
+			return after, err
+		}
+		if err := handler(ctx, line.Seq, evt); err != nil {
+			return after, err
+		}
+		after = line.Seq
+	}
+	if ctx.Err() != nil {
+		return after, ctx.Err()
+	}
+	return after, scanner.Err()
+}
+
+type commandStoreClient struct {
+	client
+	options comby.CommandStoreOptions
+}
+
+// NewCommandStoreClient returns a comby.CommandStore backed by a remote
+// Server. Update, Delete and Reset are not available remotely.
+func NewCommandStoreClient(baseURL string, opts ...ClientOption) comby.CommandStore {
+	return &commandStoreClient{client: newClient(baseURL, opts...)}
+}
+
+func (c *commandStoreClient) Init(ctx context.Context, opts ...comby.CommandStoreOption) error {
+	for _, opt := range opts {
+		if _, err := opt(&c.options); err != nil {
+			return err
+		}
+	}
+	// server must be reachable
+	_, err := c.Info(ctx)
+	return err
+}
+
+func (c *commandStoreClient) Create(ctx context.Context, opts ...comby.CommandStoreCreateOption) error {
+	createOpts := comby.CommandStoreCreateOptions{}
+	for _, opt := range opts {
+		if _, err := opt(&createOpts); err != nil {
+			return err
+		}
+	}
+	if createOpts.Command == nil {
+		return fmt.Errorf("'%s' failed to create command - command is nil", c.String())
+	}
+	body, err := encodeCommand(createOpts.Command)
+	if err != nil {
+		return err
+	}
+	if err := c.post(ctx, "/commands", body); err != nil {
+		return fmt.Errorf("'%s' failed to create command - %w", c.String(), err)
+	}
+	return nil
+}
+
+func (c *commandStoreClient) Get(ctx context.Context, opts ...comby.CommandStoreGetOption) (comby.Command, error) {
+	getOpts := comby.CommandStoreGetOptions{}
+	for _, opt := range opts {
+		if _, err := opt(&getOpts); err != nil {
+			return nil, err
+		}
+	}
+	if len(getOpts.CommandUuid) == 0 {
+		return nil, fmt.Errorf("'%s' failed to get command - command uuid is required", c.String())
+	}
+	res, err := c.do(ctx, http.MethodGet, "/commands/"+url.PathEscape(getOpts.CommandUuid), nil, nil, http.StatusOK)
+	if res != nil && res.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("'%s' failed to get command - %w", c.String(), err)
+	}
+	defer res.Body.Close()
+	var item commandJSON
+	if err := json.NewDecoder(res.Body).Decode(&item); err != nil {
+		return nil, err
+	}
+	return item.decode()
+}
+
+func (c *commandStoreClient) List(ctx context.Context, opts ...comby.CommandStoreListOption) ([]comby.Command, int64, error) {
+	listOpts := comby.CommandStoreListOptions{
+		Before:    -1,
+		After:     -1,
+		Offset:    0,
+		Limit:     100,
+		OrderBy:   "created_at",
+		Ascending: true,
+	}
+	for _, opt := range opts {
+		if _, err := opt(&listOpts); err != nil {
+			return nil, 0, err
+		}
+	}
+	query := url.Values{}
+	listParams{
+		Before:    listOpts.Before,
+		After:     listOpts.After,
+		Offset:    listOpts.Offset,
+		Limit:     listOpts.Limit,
+		OrderBy:   listOpts.OrderBy,
+		Ascending: listOpts.Ascending,
+	}.encode(query)
+	setIfNotEmpty(query, "tenant_uuid", listOpts.TenantUuid)
+	setIfNotEmpty(query, "domain", listOpts.Domain)
+	setIfNotEmpty(query, "data_type", listOpts.DataType)
+
+	var res listJSON[*commandJSON]
+	if err := c.get(ctx, "/commands", query, &res); err != nil {
+		return nil, 0, fmt.Errorf("'%s' failed to list commands - %w", c.String(), err)
+	}
+	cmds := make([]comby.Command, 0, len(res.Items))
+	for _, item := range res.Items {
+		cmd, err := item.decode()
+		if err != nil {
+			return nil, 0, err
+		}
+		cmds = append(cmds, cmd)
+	}
+	return cmds, res.Total, nil
+}
+
+func (c *commandStoreClient) Update(ctx context.Context, opts ...comby.CommandStoreUpdateOption) error {
+	return fmt.Errorf("'%s' failed to update command - not supported by remote store", c.String())
+}
+
+func (c *commandStoreClient) Delete(ctx context.Context, opts ...comby.CommandStoreDeleteOption) error {
+	return fmt.Errorf("'%s' failed to delete command - not supported by remote store", c.String())
+}
+
+func (c *commandStoreClient) Total(ctx context.Context) int64 {
+	info, err := c.Info(ctx)
+	if err != nil {
+		return 0
+	}
+	return info.NumItems
+}
+
+func (c *commandStoreClient) Close(ctx context.Context) error {
+	c.httpClient.CloseIdleConnections()
+	return nil
+}
+
+func (c *commandStoreClient) Options() comby.CommandStoreOptions {
+	return c.options
+}
+
+func (c *commandStoreClient) String() string {
+	return fmt.Sprintf("remote - %s", c.baseURL)
+}
+
+func (c *commandStoreClient) Info(ctx context.Context) (*comby.CommandStoreInfoModel, error) {
+	var info comby.CommandStoreInfoModel
+	if err := c.get(ctx, "/commands/info", nil, &info); err != nil {
+		return nil, fmt.Errorf("'%s' failed to get info - %w", c.String(), err)
+	}
+	return &info, nil
+}
+
+func (c *commandStoreClient) Reset(ctx context.Context) error {
+	return fmt.Errorf("'%s' failed to reset - not supported by remote store", c.String())
+}
+
+func setIfNotEmpty(query url.Values, key, value string) {
+	if len(value) > 0 {
+		query.Set(key, value)
+	}
+}
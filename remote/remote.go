@@ -0,0 +1,104 @@
+// Package remote exposes a SQLite backed event and command store over
+// HTTP+JSON, so a central store can be used by remote comby instances. The
+// clients implement the comby store interfaces against a Server.
+package remote
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	store "github.com/gradientzero/comby-store-sqlite"
+	"github.com/gradientzero/comby-store-sqlite/internal"
+	"github.com/gradientzero/comby/v3"
+)
+
+// event as sent over the wire, payloads are transferred as base64 so that
+// non UTF-8 bytes survive
+type eventJSON struct {
+	*internal.Event
+}
+
+// command as sent over the wire
+type commandJSON struct {
+	*internal.Command
+}
+
+// subscription stream line
+type subscriptionJSON struct {
+	Seq   int64      `json:"seq"`
+	Event *eventJSON `json:"event"`
+}
+
+type listJSON[T any] struct {
+	Items []T   `json:"items"`
+	Total int64 `json:"total"`
+}
+
+type errorJSON struct {
+	Error string `json:"error"`
+}
+
+func encodeEvent(evt comby.Event) (*eventJSON, error) {
+	dbRecord, err := internal.BaseEventToDbEvent(evt)
+	if err != nil {
+		return nil, err
+	}
+	return &eventJSON{Event: dbRecord}, nil
+}
+
+func (e *eventJSON) decode() (comby.Event, error) {
+	if e.Event == nil {
+		e.Event = &internal.Event{}
+	}
+	return internal.DbEventToBaseEvent(e.Event)
+}
+
+func encodeCommand(cmd comby.Command) (*commandJSON, error) {
+	dbRecord, err := internal.BaseCommandToDbCommand(cmd)
+	if err != nil {
+		return nil, err
+	}
+	return &commandJSON{Command: dbRecord}, nil
+}
+
+func (c *commandJSON) decode() (comby.Command, error) {
+	if c.Command == nil {
+		c.Command = &internal.Command{}
+	}
+	return internal.DbCommandToBaseCommand(c.Command)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, &errorJSON{Error: err.Error()})
+}
+
+// writeStoreError writes an error of a store with the status telling the
+// client whether it made a mistake or the server failed.
+func writeStoreError(w http.ResponseWriter, err error) {
+	writeError(w, storeErrorStatus(err), err)
+}
+
+func storeErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, store.ErrInvalidOptions),
+		errors.Is(err, store.ErrInvalidEvent),
+		errors.Is(err, store.ErrValidationFailed),
+		errors.Is(err, store.ErrPayloadInvalid),
+		errors.Is(err, store.ErrListLimitExceeded):
+		return http.StatusBadRequest
+	case errors.Is(err, store.ErrAccessDenied),
+		errors.Is(err, store.ErrTenantMismatch):
+		return http.StatusForbidden
+	case errors.Is(err, store.ErrNotFound):
+		return http.StatusNotFound
+	default:
+		return http.StatusInternalServerError
+	}
+}
@@ -0,0 +1,271 @@
+package remote_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	store "github.com/gradientzero/comby-store-sqlite"
+	"github.com/gradientzero/comby-store-sqlite/remote"
+	"github.com/gradientzero/comby/v3"
+)
+
+func createTestEvent(version int64) comby.Event {
+	evt := comby.NewBaseEvent()
+	evt.SetInstanceId(1)
+	evt.SetTenantUuid("tenant-1")
+	evt.SetCommandUuid(fmt.Sprintf("command-%d", version))
+	evt.SetDomain("domain-1")
+	evt.SetAggregateUuid("aggregate-1")
+	evt.SetVersion(version)
+	evt.SetDomainEvtName("TestEvent")
+	evt.SetDomainEvtBytes([]byte{0xff, 0x00, byte(version)})
+	evt.SetCreatedAt(version * 100)
+	return evt
+}
+
+func newTestServer(t *testing.T) (*httptest.Server, comby.EventStore, comby.CommandStore) {
+	ctx := context.Background()
+	eventStore := store.NewEventStoreSQLite(filepath.Join(t.TempDir(), "eventStore.db"))
+	if err := eventStore.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	commandStore := store.NewCommandStoreSQLite(filepath.Join(t.TempDir(), "commandStore.db"))
+	if err := commandStore.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(remote.NewServer(eventStore, commandStore, remote.ServerWithPollInterval(10*time.Millisecond)))
+	t.Cleanup(func() {
+		server.Close()
+		eventStore.Close(ctx)
+		commandStore.Close(ctx)
+	})
+	return server, eventStore, commandStore
+}
+
+func TestEventStoreClient(t *testing.T) {
+	ctx := context.Background()
+	server, eventStore, _ := newTestServer(t)
+
+	client := remote.NewEventStoreClient(server.URL)
+	if err := client.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close(ctx)
+
+	evt := createTestEvent(1)
+	if err := client.Create(ctx, comby.EventStoreCreateOptionWithEvent(evt)); err != nil {
+		t.Fatal(err)
+	}
+	if eventStore.Total(ctx) != 1 || client.Total(ctx) != 1 {
+		t.Fatalf("expected 1 event, got %d", eventStore.Total(ctx))
+	}
+
+	got, err := client.Get(ctx, comby.EventStoreGetOptionWithEventUuid(evt.GetEventUuid()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got == nil || string(got.GetDomainEvtBytes()) != string(evt.GetDomainEvtBytes()) || got.GetVersion() != 1 {
+		t.Fatalf("unexpected event: %+v", got)
+	}
+	if missing, err := client.Get(ctx, comby.EventStoreGetOptionWithEventUuid("missing")); err != nil || missing != nil {
+		t.Fatalf("expected no event, got %v, %v", missing, err)
+	}
+
+	for version := int64(2); version <= 3; version++ {
+		if err := eventStore.Create(ctx, comby.EventStoreCreateOptionWithEvent(createTestEvent(version))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	evts, total, err := client.List(ctx, comby.EventStoreListOptionAscending(false))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 3 || len(evts) != 3 || evts[0].GetVersion() != 3 {
+		t.Fatalf("unexpected list: total %d, %d events", total, len(evts))
+	}
+	values, _, err := client.UniqueList(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(values) != 1 || values[0] != "tenant-1" {
+		t.Fatalf("unexpected unique values: %v", values)
+	}
+	if err := client.Delete(ctx, comby.EventStoreDeleteOptionWithEventUuid(evt.GetEventUuid())); err == nil {
+		t.Fatal("expected delete to be unsupported")
+	}
+}
+
+func TestEventStoreClientSubscribe(t *testing.T) {
+	ctx := context.Background()
+	server, eventStore, _ := newTestServer(t)
+	client := remote.NewEventStoreClient(server.URL)
+
+	if err := eventStore.Create(ctx, comby.EventStoreCreateOptionWithEvent(createTestEvent(1))); err != nil {
+		t.Fatal(err)
+	}
+
+	// first event is replayed, the second arrives while subscribed
+	subCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	var versions []int64
+	lastSeq, err := client.(remote.EventStoreClient).Subscribe(subCtx, 0, func(ctx context.Context, seq int64, evt comby.Event) error {
+		versions = append(versions, evt.GetVersion())
+		switch len(versions) {
+		case 1:
+			return eventStore.Create(ctx, comby.EventStoreCreateOptionWithEvent(createTestEvent(2)))
+		case 2:
+			cancel()
+		}
+		return nil
+	})
+	if len(versions) != 2 || versions[1] != 2 {
+		t.Fatalf("expected versions [1 2], got %v (%v)", versions, err)
+	}
+	if lastSeq != 2 {
+		t.Fatalf("expected last sequence 2, got %d", lastSeq)
+	}
+}
+
+func TestCommandStoreClient(t *testing.T) {
+	ctx := context.Background()
+	server, _, commandStore := newTestServer(t)
+
+	client := remote.NewCommandStoreClient(server.URL)
+	if err := client.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	cmd := comby.NewBaseCommand()
+	cmd.SetTenantUuid("tenant-1")
+	cmd.SetDomain("domain-1")
+	cmd.SetDomainCmdName("TestCommand")
+	cmd.SetDomainCmdBytes([]byte(`{"name":"test"}`))
+	cmd.SetCreatedAt(100)
+	if err := client.Create(ctx, comby.CommandStoreCreateOptionWithCommand(cmd)); err != nil {
+		t.Fatal(err)
+	}
+	if commandStore.Total(ctx) != 1 {
+		t.Fatalf("expected 1 command, got %d", commandStore.Total(ctx))
+	}
+	got, err := client.Get(ctx, comby.CommandStoreGetOptionWithCommandUuid(cmd.GetCommandUuid()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got == nil || string(got.GetDomainCmdBytes()) != `{"name":"test"}` {
+		t.Fatalf("unexpected command: %+v", got)
+	}
+	cmds, total, err := client.List(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 1 || len(cmds) != 1 {
+		t.Fatalf("unexpected list: total %d, %d commands", total, len(cmds))
+	}
+}
+
+func TestServerSchema(t *testing.T) {
+	server, _, _ := newTestServer(t)
+	for _, path := range []string{"/events/schema", "/commands/schema"} {
+		res, err := http.Get(server.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var info store.SchemaInfo
+		err = json.NewDecoder(res.Body).Decode(&info)
+		res.Body.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.StatusCode != http.StatusOK || info.FormatVersion != store.StoreFormatVersion || len(info.Objects) == 0 {
+			t.Fatalf("unexpected schema of %s: %d %+v", path, res.StatusCode, info)
+		}
+	}
+}
+
+func TestServerRejectsUnknownColumns(t *testing.T) {
+	server, _, _ := newTestServer(t)
+	for _, path := range []string{
+		"/events?order_by=created_at;DROP",
+		"/commands?order_by=unknown",
+		"/events/unique?db_field=data",
+		"/events?limit=-1",
+		"/commands?limit=0",
+		"/events/unique?limit=-1",
+		"/events?offset=-1",
+	} {
+		res, err := http.Get(server.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+		if res.StatusCode != http.StatusBadRequest {
+			t.Fatalf("expected status %d of %s, got %d", http.StatusBadRequest, path, res.StatusCode)
+		}
+	}
+	res, err := http.Get(server.URL + "/events?order_by=version")
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("expected status %d of a known column, got %d", http.StatusOK, res.StatusCode)
+	}
+}
+
+func TestServerErrorStatus(t *testing.T) {
+	ctx := context.Background()
+	errDenied := errors.New("denied")
+	eventStore := store.NewEventStoreSQLite(filepath.Join(t.TempDir(), "eventStore.db"))
+	eventStore.Configure(store.EventStoreSQLiteWithAuthorizer(func(ctx context.Context, req store.AccessRequest) error {
+		if req.Operation == store.OperationGet {
+			return errDenied
+		}
+		return nil
+	}))
+	if err := eventStore.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	commandStore := store.NewCommandStoreSQLite(filepath.Join(t.TempDir(), "commandStore.db"))
+	commandStore.Configure(store.CommandStoreSQLiteWithTenant("tenant-1"))
+	if err := commandStore.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(remote.NewServer(eventStore, commandStore))
+	defer func() {
+		server.Close()
+		eventStore.Close(ctx)
+		commandStore.Close(ctx)
+	}()
+
+	evt := createTestEvent(1)
+	if err := eventStore.Create(ctx, comby.EventStoreCreateOptionWithEvent(evt)); err != nil {
+		t.Fatal(err)
+	}
+	res, err := http.Get(server.URL + "/events/" + evt.GetEventUuid())
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusForbidden {
+		t.Fatalf("expected status %d of a denied get, got %d", http.StatusForbidden, res.StatusCode)
+	}
+
+	// commands of another tenant than the bound one are rejected
+	body := `{"uuid":"command-1","tenant_uuid":"tenant-2","domain":"domain-1","created_at":1,"data_type":"TestCommand","data_bytes":"AQI="}`
+	res, err = http.Post(server.URL+"/commands", "application/json", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusForbidden {
+		t.Fatalf("expected status %d of a tenant mismatch, got %d", http.StatusForbidden, res.StatusCode)
+	}
+}
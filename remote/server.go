@@ -0,0 +1,457 @@
+package remote
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	store "github.com/gradientzero/comby-store-sqlite"
+	"github.com/gradientzero/comby/v3"
+)
+
+type ServerOption func(*Server)
+
+// ServerWithPollInterval sets how often subscriptions look for new events.
+func ServerWithPollInterval(interval time.Duration) ServerOption {
+	return func(s *Server) {
+		s.pollInterval = interval
+	}
+}
+
+// Server serves an event store and a command store, either may be nil.
+//
+//	POST /events                 create event
+//	GET  /events                 list events
+//	GET  /events/{uuid}          get event
+//	GET  /events/unique          unique values of a column
+//	GET  /events/info            store info
+//	GET  /events/schema          DDL, pragmas and format version
+//	GET  /events/subscribe       stream events after a sequence as NDJSON
+//	POST /commands               create command
+//	GET  /commands               list commands
+//	GET  /commands/{uuid}        get command
+//	GET  /commands/info          store info
+//	GET  /commands/schema        DDL, pragmas and format version
+//
+// Payloads are sent decrypted, so the server must be placed behind TLS and
+// authentication (e.g. as middleware).
+type Server struct {
+	eventStore   comby.EventStore
+	commandStore comby.CommandStore
+	pollInterval time.Duration
+	mux          *http.ServeMux
+}
+
+func NewServer(eventStore comby.EventStore, commandStore comby.CommandStore, opts ...ServerOption) *Server {
+	s := &Server{
+		eventStore:   eventStore,
+		commandStore: commandStore,
+		pollInterval: time.Second,
+		mux:          http.NewServeMux(),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	if eventStore != nil {
+		s.mux.HandleFunc("POST /events", s.createEvent)
+		s.mux.HandleFunc("GET /events", s.listEvents)
+		s.mux.HandleFunc("GET /events/{uuid}", s.getEvent)
+		s.mux.HandleFunc("GET /events/unique", s.uniqueListEvents)
+		s.mux.HandleFunc("GET /events/info", s.eventStoreInfo)
+		s.mux.HandleFunc("GET /events/schema", s.eventStoreSchema)
+		s.mux.HandleFunc("GET /events/subscribe", s.subscribeEvents)
+	}
+	if commandStore != nil {
+		s.mux.HandleFunc("POST /commands", s.createCommand)
+		s.mux.HandleFunc("GET /commands", s.listCommands)
+		s.mux.HandleFunc("GET /commands/{uuid}", s.getCommand)
+		s.mux.HandleFunc("GET /commands/info", s.commandStoreInfo)
+		s.mux.HandleFunc("GET /commands/schema", s.commandStoreSchema)
+	}
+	return s
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+func (s *Server) createEvent(w http.ResponseWriter, r *http.Request) {
+	var body eventJSON
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	evt, err := body.decode()
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if err := s.eventStore.Create(r.Context(), comby.EventStoreCreateOptionWithEvent(evt)); err != nil {
+		writeStoreError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+}
+
+func (s *Server) getEvent(w http.ResponseWriter, r *http.Request) {
+	evt, err := s.eventStore.Get(r.Context(), comby.EventStoreGetOptionWithEventUuid(r.PathValue("uuid")))
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+	if evt == nil {
+		writeError(w, http.StatusNotFound, fmt.Errorf("event not found"))
+		return
+	}
+	item, err := encodeEvent(evt)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, item)
+}
+
+func (s *Server) listEvents(w http.ResponseWriter, r *http.Request) {
+	listOpts, err := parseEventListOptions(r.URL.Query())
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	evts, total, err := s.eventStore.List(r.Context(), func(opt *comby.EventStoreListOptions) (*comby.EventStoreListOptions, error) {
+		*opt = listOpts
+		return opt, nil
+	})
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+	res := listJSON[*eventJSON]{Items: make([]*eventJSON, 0, len(evts)), Total: total}
+	for _, evt := range evts {
+		item, err := encodeEvent(evt)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		res.Items = append(res.Items, item)
+	}
+	writeJSON(w, http.StatusOK, &res)
+}
+
+func (s *Server) uniqueListEvents(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	uniqueOpts := comby.EventStoreUniqueListOptions{
+		DbField:    query.Get("db_field"),
+		TenantUuid: query.Get("tenant_uuid"),
+		Domain:     query.Get("domain"),
+	}
+	if len(uniqueOpts.DbField) == 0 {
+		uniqueOpts.DbField = "tenant_uuid"
+	}
+	if !store.IsUniqueListField(uniqueOpts.DbField) {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid db_field '%s'", uniqueOpts.DbField))
+		return
+	}
+	var err error
+	if uniqueOpts.Offset, err = parseInt(query, "offset", 0); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if uniqueOpts.Limit, err = parseLimit(query); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if uniqueOpts.Ascending, err = parseBool(query, "ascending", true); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	values, total, err := s.eventStore.UniqueList(r.Context(), func(opt *comby.EventStoreUniqueListOptions) (*comby.EventStoreUniqueListOptions, error) {
+		*opt = uniqueOpts
+		return opt, nil
+	})
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+	if values == nil {
+		values = []string{}
+	}
+	writeJSON(w, http.StatusOK, &listJSON[string]{Items: values, Total: total})
+}
+
+func (s *Server) eventStoreInfo(w http.ResponseWriter, r *http.Request) {
+	info, err := s.eventStore.Info(r.Context())
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, info)
+}
+
+func (s *Server) eventStoreSchema(w http.ResponseWriter, r *http.Request) {
+	es, ok := s.eventStore.(store.EventStoreSQLite)
+	if !ok {
+		writeError(w, http.StatusNotImplemented, fmt.Errorf("event store does not support schema introspection"))
+		return
+	}
+	info, err := es.SchemaInfo(r.Context())
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, info)
+}
+
+// subscribeEvents streams all events after the given sequence and keeps
+// polling for new ones until the client disconnects.
+func (s *Server) subscribeEvents(w http.ResponseWriter, r *http.Request) {
+	es, ok := s.eventStore.(store.EventStoreSQLite)
+	if !ok {
+		writeError(w, http.StatusNotImplemented, fmt.Errorf("event store does not support subscriptions"))
+		return
+	}
+	after, err := parseInt(r.URL.Query(), "after", 0)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.WriteHeader(http.StatusOK)
+	flusher, _ := w.(http.Flusher)
+	enc := json.NewEncoder(w)
+	ctx := r.Context()
+	ticker := time.NewTicker(s.pollInterval)
+	defer ticker.Stop()
+	for {
+		after, err = es.Replay(ctx, func(ctx context.Context, seq int64, evt comby.Event) error {
+			item, err := encodeEvent(evt)
+			if err != nil {
+				return err
+			}
+			return enc.Encode(&subscriptionJSON{Seq: seq, Event: item})
+		}, store.ReplayFromSequence(after))
+		if err != nil {
+			// the status is sent already, the client sees a truncated stream
+			return
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *Server) createCommand(w http.ResponseWriter, r *http.Request) {
+	var body commandJSON
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	cmd, err := body.decode()
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if err := s.commandStore.Create(r.Context(), comby.CommandStoreCreateOptionWithCommand(cmd)); err != nil {
+		writeStoreError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+}
+
+func (s *Server) getCommand(w http.ResponseWriter, r *http.Request) {
+	cmd, err := s.commandStore.Get(r.Context(), comby.CommandStoreGetOptionWithCommandUuid(r.PathValue("uuid")))
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+	if cmd == nil {
+		writeError(w, http.StatusNotFound, fmt.Errorf("command not found"))
+		return
+	}
+	item, err := encodeCommand(cmd)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, item)
+}
+
+func (s *Server) listCommands(w http.ResponseWriter, r *http.Request) {
+	listOpts, err := parseCommandListOptions(r.URL.Query())
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	cmds, total, err := s.commandStore.List(r.Context(), func(opt *comby.CommandStoreListOptions) (*comby.CommandStoreListOptions, error) {
+		*opt = listOpts
+		return opt, nil
+	})
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+	res := listJSON[*commandJSON]{Items: make([]*commandJSON, 0, len(cmds)), Total: total}
+	for _, cmd := range cmds {
+		item, err := encodeCommand(cmd)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		res.Items = append(res.Items, item)
+	}
+	writeJSON(w, http.StatusOK, &res)
+}
+
+func (s *Server) commandStoreInfo(w http.ResponseWriter, r *http.Request) {
+	info, err := s.commandStore.Info(r.Context())
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, info)
+}
+
+func (s *Server) commandStoreSchema(w http.ResponseWriter, r *http.Request) {
+	cs, ok := s.commandStore.(store.CommandStoreSQLite)
+	if !ok {
+		writeError(w, http.StatusNotImplemented, fmt.Errorf("command store does not support schema introspection"))
+		return
+	}
+	info, err := cs.SchemaInfo(r.Context())
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, info)
+}
+
+// parseInt returns the integer query parameter key or def if missing.
+func parseInt(query url.Values, key string, def int64) (int64, error) {
+	value := query.Get(key)
+	if len(value) == 0 {
+		return def, nil
+	}
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s '%s'", key, value)
+	}
+	return n, nil
+}
+
+// parseLimit returns the limit query parameter, 100 if missing. Lists
+// without limit are not served, a page has at least one item.
+func parseLimit(query url.Values) (int64, error) {
+	limit, err := parseInt(query, "limit", 100)
+	if err != nil {
+		return 0, err
+	}
+	if limit < 1 {
+		return 0, fmt.Errorf("invalid limit '%d'", limit)
+	}
+	return limit, nil
+}
+
+func parseBool(query url.Values, key string, def bool) (bool, error) {
+	value := query.Get(key)
+	if len(value) == 0 {
+		return def, nil
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("invalid %s '%s'", key, value)
+	}
+	return b, nil
+}
+
+// listParams holds the paging and range parameters shared by both lists,
+// missing parameters default to the defaults of the sqlite stores.
+type listParams struct {
+	Before, After, Offset, Limit int64
+	OrderBy                      string
+	Ascending                    bool
+}
+
+func parseListParams(query url.Values) (listParams, error) {
+	params := listParams{OrderBy: "created_at"}
+	var err error
+	if params.Before, err = parseInt(query, "before", -1); err != nil {
+		return params, err
+	}
+	if params.After, err = parseInt(query, "after", -1); err != nil {
+		return params, err
+	}
+	if params.Offset, err = parseInt(query, "offset", 0); err != nil {
+		return params, err
+	}
+	if params.Limit, err = parseLimit(query); err != nil {
+		return params, err
+	}
+	if params.Ascending, err = parseBool(query, "ascending", true); err != nil {
+		return params, err
+	}
+	if orderBy := query.Get("order_by"); len(orderBy) > 0 {
+		params.OrderBy = orderBy
+	}
+	return params, nil
+}
+
+func (p listParams) encode(query url.Values) {
+	query.Set("before", strconv.FormatInt(p.Before, 10))
+	query.Set("after", strconv.FormatInt(p.After, 10))
+	query.Set("offset", strconv.FormatInt(p.Offset, 10))
+	query.Set("limit", strconv.FormatInt(p.Limit, 10))
+	query.Set("order_by", p.OrderBy)
+	query.Set("ascending", strconv.FormatBool(p.Ascending))
+}
+
+func parseEventListOptions(query url.Values) (comby.EventStoreListOptions, error) {
+	params, err := parseListParams(query)
+	if err != nil {
+		return comby.EventStoreListOptions{}, err
+	}
+	if !store.IsEventOrderByColumn(params.OrderBy) {
+		return comby.EventStoreListOptions{}, fmt.Errorf("invalid order_by '%s'", params.OrderBy)
+	}
+	return comby.EventStoreListOptions{
+		TenantUuid:    query.Get("tenant_uuid"),
+		AggregateUuid: query.Get("aggregate_uuid"),
+		Domains:       query["domain"],
+		DataType:      query.Get("data_type"),
+		Before:        params.Before,
+		After:         params.After,
+		Offset:        params.Offset,
+		Limit:         params.Limit,
+		OrderBy:       params.OrderBy,
+		Ascending:     params.Ascending,
+	}, nil
+}
+
+func parseCommandListOptions(query url.Values) (comby.CommandStoreListOptions, error) {
+	params, err := parseListParams(query)
+	if err != nil {
+		return comby.CommandStoreListOptions{}, err
+	}
+	if !store.IsCommandOrderByColumn(params.OrderBy) {
+		return comby.CommandStoreListOptions{}, fmt.Errorf("invalid order_by '%s'", params.OrderBy)
+	}
+	return comby.CommandStoreListOptions{
+		TenantUuid: query.Get("tenant_uuid"),
+		Domain:     query.Get("domain"),
+		DataType:   query.Get("data_type"),
+		Before:     params.Before,
+		After:      params.After,
+		Offset:     params.Offset,
+		Limit:      params.Limit,
+		OrderBy:    params.OrderBy,
+		Ascending:  params.Ascending,
+	}, nil
+}
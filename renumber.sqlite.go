@@ -0,0 +1,112 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// RenumberReport is the result of RenumberAggregate.
+type RenumberReport struct {
+	AggregateUuid string
+	NumEvents     int64
+	// events whose version was rewritten
+	NumChanged int64
+	// version of the last event afterwards
+	Version int64
+}
+
+var renumberTables = []strictTable{
+	{
+		name: "event_renumberings",
+		columns: `id INTEGER PRIMARY KEY,
+		aggregate_uuid TEXT NOT NULL,
+		event_uuid TEXT NOT NULL,
+		old_version INTEGER NOT NULL,
+		new_version INTEGER NOT NULL,
+		renumbered_at INTEGER NOT NULL`,
+		copyColumns: `id, aggregate_uuid, event_uuid, old_version, new_version, renumbered_at`,
+	},
+}
+
+// RenumberAggregate rewrites the versions of an aggregate to 1, 2, 3, ... in
+// their current order (version, then insertion), e.g. to close gaps left by
+// partial deletes. Events sharing a version are numbered in insertion order.
+// The original version of each changed event is kept in the
+// event_renumberings table. Snapshots of the aggregate refer to the old
+// versions and should be discarded afterwards.
+func (es *eventStoreSQLite) RenumberAggregate(ctx context.Context, aggregateUuid string) (*RenumberReport, error) {
+	ctx, cancel := es.cfg().Timeouts.write(ctx)
+	defer cancel()
+	if es.opts().ReadOnly {
+		return nil, fmt.Errorf("'%s' failed to renumber aggregate - instance is readonly", es.String())
+	}
+	if len(aggregateUuid) == 0 {
+		return nil, fmt.Errorf("'%s' failed to renumber aggregate - aggregate uuid is required", es.String())
+	}
+	done, err := es.beginWrite(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	report := &RenumberReport{AggregateUuid: aggregateUuid}
+	err = runTx(ctx, es.db, func(tx *sql.Tx) error {
+		for _, table := range renumberTables {
+			if _, err := tx.ExecContext(ctx, table.create(table.name)); err != nil {
+				return err
+			}
+		}
+
+		type record struct {
+			id      int64
+			uuid    string
+			version int64
+		}
+		rows, err := tx.QueryContext(ctx, `SELECT id, uuid, version FROM event_records WHERE aggregate_uuid=? ORDER BY version ASC, id ASC;`, aggregateUuid)
+		if err != nil {
+			return err
+		}
+		var records []record
+		for rows.Next() {
+			var r record
+			if err := rows.Scan(&r.id, &r.uuid, &r.version); err != nil {
+				rows.Close()
+				return err
+			}
+			records = append(records, r)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		now := time.Now().UnixNano()
+		for i, r := range records {
+			version := int64(i + 1)
+			report.NumEvents++
+			report.Version = version
+			if r.version == version {
+				continue
+			}
+			if _, err := tx.ExecContext(ctx, `UPDATE event_records SET version=? WHERE id=?;`, version, r.id); err != nil {
+				return err
+			}
+			query := `INSERT INTO event_renumberings (aggregate_uuid, event_uuid, old_version, new_version, renumbered_at)
+				VALUES (?, ?, ?, ?, ?);`
+			if _, err := tx.ExecContext(ctx, query, aggregateUuid, r.uuid, r.version, version, now); err != nil {
+				return err
+			}
+			report.NumChanged++
+		}
+		return insertAuditEntry(ctx, tx, newAuditEntry(ctx, "events", AdminOperationRenumber, aggregateUuid, report.NumChanged))
+	})
+	if err != nil {
+		return nil, fmt.Errorf("'%s' failed to renumber aggregate - %w", es.String(), err)
+	}
+	if report.NumChanged > 0 {
+		loggerOrDiscard(es.cfg().Logger).InfoContext(ctx, "renumbered aggregate", "aggregate", aggregateUuid, "changed", report.NumChanged)
+	}
+	return report, nil
+}
@@ -0,0 +1,74 @@
+package store_test
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"testing"
+
+	store "github.com/gradientzero/comby-store-sqlite"
+	"github.com/gradientzero/comby/v3"
+)
+
+func TestRenumberAggregate(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "renumber.db")
+	eventStore := store.NewEventStoreSQLite(path)
+	if err := eventStore.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer eventStore.Close(ctx)
+
+	var evts []comby.Event
+	for i, version := range []int64{1, 3, 3, 7} {
+		evt := createTestEvent("tenant-1", "domain-1", version, int64(i+1)*100)
+		evt.SetAggregateUuid("aggregate-1")
+		if err := eventStore.Create(ctx, comby.EventStoreCreateOptionWithEvent(evt)); err != nil {
+			t.Fatal(err)
+		}
+		evts = append(evts, evt)
+	}
+
+	report, err := eventStore.RenumberAggregate(ctx, "aggregate-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *report != (store.RenumberReport{AggregateUuid: "aggregate-1", NumEvents: 4, NumChanged: 2, Version: 4}) {
+		t.Fatalf("unexpected renumber report: %+v", report)
+	}
+	for i, evt := range evts {
+		got, err := eventStore.Get(ctx, comby.EventStoreGetOptionWithEventUuid(evt.GetEventUuid()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.GetVersion() != int64(i+1) {
+			t.Fatalf("expected version %d of event %d, got %d", i+1, i, got.GetVersion())
+		}
+	}
+	if consistency, err := eventStore.VerifyAggregateConsistency(ctx, "aggregate-1"); err != nil {
+		t.Fatal(err)
+	} else if !consistency.Consistent() {
+		t.Fatalf("expected consistent aggregate: %+v", consistency)
+	}
+
+	// original versions are kept
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	var oldVersion, newVersion int64
+	if err := db.QueryRowContext(ctx, `SELECT old_version, new_version FROM event_renumberings WHERE event_uuid=?`, evts[3].GetEventUuid()).Scan(&oldVersion, &newVersion); err != nil {
+		t.Fatal(err)
+	}
+	if oldVersion != 7 || newVersion != 4 {
+		t.Fatalf("unexpected audit entry: %d -> %d", oldVersion, newVersion)
+	}
+
+	// renumbering a contiguous aggregate changes nothing
+	if report, err := eventStore.RenumberAggregate(ctx, "aggregate-1"); err != nil {
+		t.Fatal(err)
+	} else if report.NumChanged != 0 {
+		t.Fatalf("unexpected second renumber report: %+v", report)
+	}
+}
@@ -0,0 +1,307 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gradientzero/comby-store-sqlite/internal"
+	"github.com/gradientzero/comby/v3"
+)
+
+// ReplayHandler receives replayed events in store order. seq is the position of
+// the event in the store and can be used to resume a replay (see ReplayFromSequence).
+type ReplayHandler func(ctx context.Context, seq int64, evt comby.Event) error
+
+// ReplayOption configures a replay.
+type ReplayOption func(*replayOptions)
+
+type replayOptions struct {
+	TenantUuid    string
+	AggregateUuid string
+	Domains       []string
+	After         int64
+	Before        int64
+	FromSequence  int64
+	RatePerSecond float64
+	BatchSize     int
+	Workers       int
+}
+
+// ReplayWithTenantUuid only replays events of the given tenant.
+func ReplayWithTenantUuid(tenantUuid string) ReplayOption {
+	return func(o *replayOptions) { o.TenantUuid = tenantUuid }
+}
+
+// ReplayWithAggregateUuid only replays events of the given aggregate.
+func ReplayWithAggregateUuid(aggregateUuid string) ReplayOption {
+	return func(o *replayOptions) { o.AggregateUuid = aggregateUuid }
+}
+
+// ReplayWithDomains only replays events of the given domains.
+func ReplayWithDomains(domains ...string) ReplayOption {
+	return func(o *replayOptions) { o.Domains = domains }
+}
+
+// ReplayWithTimeRange only replays events with after < created_at < before (-1 disables a bound).
+func ReplayWithTimeRange(after, before int64) ReplayOption {
+	return func(o *replayOptions) {
+		o.After = after
+		o.Before = before
+	}
+}
+
+// ReplayFromSequence resumes a replay after the given sequence.
+func ReplayFromSequence(seq int64) ReplayOption {
+	return func(o *replayOptions) { o.FromSequence = seq }
+}
+
+// ReplayWithRateLimit limits delivery to the given number of events per second.
+func ReplayWithRateLimit(perSecond float64) ReplayOption {
+	return func(o *replayOptions) { o.RatePerSecond = perSecond }
+}
+
+// ReplayWithBatchSize sets the number of events loaded per query.
+func ReplayWithBatchSize(n int) ReplayOption {
+	return func(o *replayOptions) { o.BatchSize = n }
+}
+
+// ReplayWithWorkers delivers events concurrently using n workers. Events are
+// partitioned by aggregate uuid, so the order within an aggregate is preserved
+// while different aggregates are handled in parallel.
+func ReplayWithWorkers(n int) ReplayOption {
+	return func(o *replayOptions) { o.Workers = n }
+}
+
+// Replay streams all matching events in store order to handler and returns the
+// sequence of the last delivered event. Replay stops at the first handler error.
+// With multiple workers the returned sequence only advances once a whole batch
+// has been handled, so resuming from it never skips an event.
+func (es *eventStoreSQLite) Replay(ctx context.Context, handler ReplayHandler, opts ...ReplayOption) (int64, error) {
+	replayOpts := replayOptions{
+		After:     -1,
+		Before:    -1,
+		BatchSize: 1000,
+		Workers:   1,
+	}
+	for _, opt := range opts {
+		opt(&replayOpts)
+	}
+	if handler == nil {
+		return 0, fmt.Errorf("'%s' failed to replay - handler is nil", es.String())
+	}
+	if replayOpts.BatchSize < 1 {
+		return 0, fmt.Errorf("'%s' failed to replay - invalid batch size %d", es.String(), replayOpts.BatchSize)
+	}
+	if replayOpts.Workers < 1 {
+		return 0, fmt.Errorf("'%s' failed to replay - invalid number of workers %d", es.String(), replayOpts.Workers)
+	}
+	if replayOpts.RatePerSecond < 0 || math.IsNaN(replayOpts.RatePerSecond) || math.IsInf(replayOpts.RatePerSecond, 0) {
+		return 0, fmt.Errorf("'%s' failed to replay - invalid rate limit %v", es.String(), replayOpts.RatePerSecond)
+	}
+	if err := es.authorize(ctx, AccessRequest{Operation: OperationList, TenantUuid: replayOpts.TenantUuid, Domains: replayOpts.Domains, AggregateUuid: replayOpts.AggregateUuid}); err != nil {
+		return 0, err
+	}
+
+	// prepare where
+	var whereList []string = []string{"id>?"}
+	var args []any
+	if len(replayOpts.TenantUuid) > 0 {
+		whereList = append(whereList, "tenant_uuid=?")
+		args = append(args, replayOpts.TenantUuid)
+	}
+	if len(replayOpts.AggregateUuid) > 0 {
+		whereList = append(whereList, "aggregate_uuid=?")
+		args = append(args, replayOpts.AggregateUuid)
+	}
+	if len(replayOpts.Domains) > 0 {
+		placeholders := make([]string, len(replayOpts.Domains))
+		for i, d := range replayOpts.Domains {
+			placeholders[i] = "?"
+			args = append(args, d)
+		}
+		whereList = append(whereList, fmt.Sprintf("domain IN (%s)", strings.Join(placeholders, ",")))
+	}
+	if replayOpts.Before >= 0 {
+		whereList = append(whereList, "created_at<?")
+		args = append(args, replayOpts.Before)
+	}
+	if replayOpts.After >= 0 {
+		whereList = append(whereList, "created_at>?")
+		args = append(args, replayOpts.After)
+	}
+	whereList, args = tenantCondition(es.cfg().Tenant, whereList, args)
+	query := fmt.Sprintf("SELECT %s FROM events WHERE %s ORDER BY id ASC LIMIT %d;", eventSelectColumns, strings.Join(whereList, " AND "), replayOpts.BatchSize)
+
+	limiter := newReplayLimiter(replayOpts.RatePerSecond)
+	defer limiter.stop()
+
+	lastSeq := replayOpts.FromSequence
+	for {
+		dbRecords, err := es.queryEvents(ctx, query, append([]any{lastSeq}, args...))
+		if err != nil {
+			return lastSeq, err
+		}
+		if len(dbRecords) == 0 {
+			return lastSeq, nil
+		}
+		if replayOpts.Workers > 1 {
+			if err := es.replayParallel(ctx, handler, limiter, dbRecords, replayOpts.Workers); err != nil {
+				return lastSeq, err
+			}
+			lastSeq = dbRecords[len(dbRecords)-1].ID.Int64
+			continue
+		}
+		for _, dbRecord := range dbRecords {
+			evt, err := es.decodeEvent(ctx, dbRecord)
+			if err != nil {
+				return lastSeq, err
+			}
+			if err := limiter.wait(ctx); err != nil {
+				return lastSeq, err
+			}
+			if err := handler(ctx, dbRecord.ID.Int64, evt); err != nil {
+				return lastSeq, err
+			}
+			lastSeq = dbRecord.ID.Int64
+		}
+	}
+}
+
+// replayParallel delivers one batch to workers partitioned by aggregate uuid
+// and waits until all of them are done.
+func (es *eventStoreSQLite) replayParallel(ctx context.Context, handler ReplayHandler, limiter *replayLimiter, dbRecords []*internal.Event, workers int) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type replayItem struct {
+		seq int64
+		evt comby.Event
+	}
+	var wg sync.WaitGroup
+	var once sync.Once
+	var firstErr error
+	fail := func(err error) {
+		once.Do(func() {
+			firstErr = err
+			cancel()
+		})
+	}
+
+	queues := make([]chan replayItem, workers)
+	for i := range queues {
+		queues[i] = make(chan replayItem, 64)
+		wg.Add(1)
+		go func(queue chan replayItem) {
+			defer wg.Done()
+			for item := range queue {
+				if ctx.Err() != nil {
+					continue
+				}
+				if err := handler(ctx, item.seq, item.evt); err != nil {
+					fail(err)
+				}
+			}
+		}(queues[i])
+	}
+
+	for _, dbRecord := range dbRecords {
+		evt, err := es.decodeEvent(ctx, dbRecord)
+		if err != nil {
+			fail(err)
+			break
+		}
+		if err := limiter.wait(ctx); err != nil {
+			fail(err)
+			break
+		}
+		h := fnv.New32a()
+		h.Write([]byte(dbRecord.AggregateUuid))
+		select {
+		case queues[h.Sum32()%uint32(workers)] <- replayItem{seq: dbRecord.ID.Int64, evt: evt}:
+		case <-ctx.Done():
+			fail(ctx.Err())
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	for _, queue := range queues {
+		close(queue)
+	}
+	wg.Wait()
+	return firstErr
+}
+
+func (es *eventStoreSQLite) decodeEvent(ctx context.Context, dbRecord *internal.Event) (comby.Event, error) {
+	if err := es.decodeDomainData(ctx, dbRecord); err != nil {
+		return nil, err
+	}
+	return internal.DbEventToBaseEvent(dbRecord)
+}
+
+func (es *eventStoreSQLite) queryEvents(ctx context.Context, query string, args []any) ([]*internal.Event, error) {
+	return queryEventRecords(ctx, es.db, query, args)
+}
+
+// queryEventRecords returns the records selected by query, which must select eventSelectColumns.
+func queryEventRecords(ctx context.Context, q queryer, query string, args []any) ([]*internal.Event, error) {
+	rows, err := q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var dbRecords []*internal.Event
+	scanner := newPayloadScanner(rows)
+	for rows.Next() {
+		var dbRecord internal.Event
+		if err := scanEvent(scanner, &dbRecord); err != nil {
+			return nil, err
+		}
+		dbRecords = append(dbRecords, &dbRecord)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return dbRecords, nil
+}
+
+// replayLimiter spaces deliveries evenly, a nil ticker means unlimited
+type replayLimiter struct {
+	ticker *time.Ticker
+}
+
+func newReplayLimiter(perSecond float64) *replayLimiter {
+	if perSecond <= 0 {
+		return &replayLimiter{}
+	}
+	// rates above one event per nanosecond are as good as unlimited
+	interval := max(time.Duration(float64(time.Second)/perSecond), time.Nanosecond)
+	return &replayLimiter{ticker: time.NewTicker(interval)}
+}
+
+func (l *replayLimiter) wait(ctx context.Context) error {
+	if l.ticker == nil {
+		return ctx.Err()
+	}
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-l.ticker.C:
+		return nil
+	}
+}
+
+func (l *replayLimiter) stop() {
+	if l.ticker != nil {
+		l.ticker.Stop()
+	}
+}
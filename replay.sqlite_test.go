@@ -0,0 +1,204 @@
+package store_test
+
+import (
+	"context"
+	"errors"
+	"math"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	store "github.com/gradientzero/comby-store-sqlite"
+	"github.com/gradientzero/comby/v3"
+)
+
+func TestEventStoreReplay(t *testing.T) {
+	ctx := context.Background()
+	eventStore := store.NewEventStoreSQLite(filepath.Join(t.TempDir(), "eventStore-replay.db"))
+	if err := eventStore.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer eventStore.Close(ctx)
+
+	for i := int64(1); i <= 10; i++ {
+		domain := "domain-a"
+		if i%2 == 0 {
+			domain = "domain-b"
+		}
+		if err := eventStore.Create(ctx,
+			comby.EventStoreCreateOptionWithEvent(createTestEvent("tenant-1", domain, i, i*100)),
+		); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// replay everything in order
+	var versions []int64
+	lastSeq, err := eventStore.Replay(ctx, func(ctx context.Context, seq int64, evt comby.Event) error {
+		versions = append(versions, evt.GetVersion())
+		return nil
+	}, store.ReplayWithBatchSize(3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(versions) != 10 {
+		t.Fatalf("expected 10 events, got %d", len(versions))
+	}
+	for i, version := range versions {
+		if version != int64(i+1) {
+			t.Fatalf("wrong order: %v", versions)
+		}
+	}
+
+	// filtered by domain and time
+	var count int
+	if _, err := eventStore.Replay(ctx, func(ctx context.Context, seq int64, evt comby.Event) error {
+		if evt.GetDomain() != "domain-b" {
+			t.Fatalf("unexpected domain %s", evt.GetDomain())
+		}
+		count++
+		return nil
+	}, store.ReplayWithDomains("domain-b"), store.ReplayWithTimeRange(200, -1)); err != nil {
+		t.Fatal(err)
+	}
+	if count != 4 {
+		t.Fatalf("expected 4 events, got %d", count)
+	}
+
+	// stop on handler error and resume
+	errStop := errors.New("stop")
+	var delivered int
+	stoppedAt, err := eventStore.Replay(ctx, func(ctx context.Context, seq int64, evt comby.Event) error {
+		if delivered == 4 {
+			return errStop
+		}
+		delivered++
+		return nil
+	})
+	if !errors.Is(err, errStop) {
+		t.Fatalf("expected stop error, got %v", err)
+	}
+	var resumed int
+	if _, err := eventStore.Replay(ctx, func(ctx context.Context, seq int64, evt comby.Event) error {
+		resumed++
+		return nil
+	}, store.ReplayFromSequence(stoppedAt)); err != nil {
+		t.Fatal(err)
+	}
+	if delivered+resumed != 10 {
+		t.Fatalf("expected 10 events after resume, got %d", delivered+resumed)
+	}
+
+	// nothing left after last sequence
+	if seq, err := eventStore.Replay(ctx, func(ctx context.Context, seq int64, evt comby.Event) error {
+		t.Fatalf("unexpected event")
+		return nil
+	}, store.ReplayFromSequence(lastSeq)); err != nil || seq != lastSeq {
+		t.Fatalf("unexpected result: %d %v", seq, err)
+	}
+}
+
+func TestEventStoreReplayRateLimit(t *testing.T) {
+	ctx := context.Background()
+	eventStore := store.NewEventStoreSQLite(filepath.Join(t.TempDir(), "eventStore-replay.db"))
+	if err := eventStore.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer eventStore.Close(ctx)
+
+	for i := int64(1); i <= 5; i++ {
+		if err := eventStore.Create(ctx,
+			comby.EventStoreCreateOptionWithEvent(createTestEvent("tenant-1", "domain", i, i*100)),
+		); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	start := time.Now()
+	if _, err := eventStore.Replay(ctx, func(ctx context.Context, seq int64, evt comby.Event) error {
+		return nil
+	}, store.ReplayWithRateLimit(50)); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < 80*time.Millisecond {
+		t.Fatalf("replay was not rate limited: %v", elapsed)
+	}
+}
+
+func TestEventStoreReplayInvalidRateLimit(t *testing.T) {
+	ctx := context.Background()
+	eventStore := store.NewEventStoreSQLite(filepath.Join(t.TempDir(), "eventStore-replay.db"))
+	if err := eventStore.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer eventStore.Close(ctx)
+	if err := eventStore.Create(ctx,
+		comby.EventStoreCreateOptionWithEvent(createTestEvent("tenant-1", "domain", 1, 100)),
+	); err != nil {
+		t.Fatal(err)
+	}
+
+	handler := func(ctx context.Context, seq int64, evt comby.Event) error { return nil }
+	for _, rate := range []float64{-1, math.NaN(), math.Inf(1)} {
+		if _, err := eventStore.Replay(ctx, handler, store.ReplayWithRateLimit(rate)); err == nil {
+			t.Fatalf("expected error for rate %v", rate)
+		}
+	}
+	// rates beyond the timer resolution do not panic
+	if _, err := eventStore.Replay(ctx, handler, store.ReplayWithRateLimit(2e9)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestEventStoreReplayParallel(t *testing.T) {
+	ctx := context.Background()
+	eventStore := store.NewEventStoreSQLite(filepath.Join(t.TempDir(), "eventStore-replay.db"))
+	if err := eventStore.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer eventStore.Close(ctx)
+
+	// interleave events of several aggregates
+	aggregateUuids := []string{"aggregate-a", "aggregate-b", "aggregate-c", "aggregate-d"}
+	for v := int64(1); v <= 25; v++ {
+		for _, aggregateUuid := range aggregateUuids {
+			evt := createTestEvent("tenant-1", "domain", v, v*100)
+			evt.SetAggregateUuid(aggregateUuid)
+			if err := eventStore.Create(ctx, comby.EventStoreCreateOptionWithEvent(evt)); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	var mu sync.Mutex
+	lastVersion := map[string]int64{}
+	var count int
+	lastSeq, err := eventStore.Replay(ctx, func(ctx context.Context, seq int64, evt comby.Event) error {
+		mu.Lock()
+		defer mu.Unlock()
+		if evt.GetVersion() != lastVersion[evt.GetAggregateUuid()]+1 {
+			t.Errorf("out of order event for aggregate %s: %d", evt.GetAggregateUuid(), evt.GetVersion())
+		}
+		lastVersion[evt.GetAggregateUuid()] = evt.GetVersion()
+		count++
+		return nil
+	}, store.ReplayWithWorkers(4), store.ReplayWithBatchSize(7))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 100 || lastSeq != 100 {
+		t.Fatalf("unexpected result: count=%d lastSeq=%d", count, lastSeq)
+	}
+
+	// handler error stops all workers
+	errStop := errors.New("stop")
+	if _, err := eventStore.Replay(ctx, func(ctx context.Context, seq int64, evt comby.Event) error {
+		if seq == 50 {
+			return errStop
+		}
+		return nil
+	}, store.ReplayWithWorkers(4)); !errors.Is(err, errStop) {
+		t.Fatalf("expected stop error, got %v", err)
+	}
+}
@@ -0,0 +1,111 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// replicaConfig makes an event store a read replica of another database file.
+type replicaConfig struct {
+	PrimaryPath string
+	// refresh periodically, 0 refreshes only on Init and RefreshReplica
+	Interval time.Duration
+}
+
+// EventStoreSQLiteWithReplicaOf turns the store into a read-only replica of the
+// event store database at primaryPath, e.g. to serve heavy List traffic outside
+// of the writer process. The replica is refreshed on Init, every interval and
+// on RefreshReplica.
+//
+// Each refresh copies the complete primary within one transaction, so readers
+// see the previous snapshot until it commits. The copy takes time proportional
+// to the size of the primary, the interval should be chosen accordingly.
+func EventStoreSQLiteWithReplicaOf(primaryPath string, interval time.Duration) EventStoreSQLiteOption {
+	return func(c *eventStoreSQLiteConfig) {
+		c.Replica = replicaConfig{PrimaryPath: primaryPath, Interval: interval}
+	}
+}
+
+// initReplica prepares the replica schema, switches the store into read-only
+// mode and takes the first snapshot of the primary.
+func (es *eventStoreSQLite) initReplica(ctx context.Context) error {
+	config := es.cfg()
+	if es.path == config.Replica.PrimaryPath {
+		return fmt.Errorf("'%s' failed to init replica - replica and primary are the same file", es.String())
+	}
+	if err := es.migrate(ctx); err != nil {
+		return classifyError(err)
+	}
+	es.mu.Lock()
+	es.options.ReadOnly = true
+	es.mu.Unlock()
+
+	if err := es.RefreshReplica(ctx); err != nil {
+		return err
+	}
+	if config.Replica.Interval > 0 {
+		es.replica.stop()
+		es.replica = startMaintenance(config.Replica.Interval, loggerOrDiscard(config.Logger), "replica refresh", es.RefreshReplica)
+	}
+	return nil
+}
+
+func (es *eventStoreSQLite) RefreshReplica(ctx context.Context) error {
+	primaryPath := es.cfg().Replica.PrimaryPath
+	if len(primaryPath) == 0 {
+		return fmt.Errorf("'%s' failed to refresh replica - store is not a replica", es.String())
+	}
+	es.writeMu.Lock()
+	defer es.writeMu.Unlock()
+
+	conn, err := es.db.Conn(ctx)
+	if err != nil {
+		return classifyError(err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, "ATTACH DATABASE ? AS primary_db;", primaryPath); err != nil {
+		return fmt.Errorf("'%s' failed to refresh replica - %w", es.String(), classifyError(err))
+	}
+	defer conn.ExecContext(context.Background(), "DETACH DATABASE primary_db;")
+
+	var tables int
+	if err := conn.QueryRowContext(ctx, `SELECT COUNT(*) FROM primary_db.sqlite_master WHERE type='table' AND name='event_records'`).Scan(&tables); err != nil {
+		return classifyError(err)
+	}
+	if tables == 0 {
+		return fmt.Errorf("'%s' failed to refresh replica - '%s' is no migrated event store", es.String(), primaryPath)
+	}
+
+	start := time.Now()
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return classifyError(err)
+	}
+	defer tx.Rollback()
+
+	// ids of tenants and domains are copied, so records reference them unchanged
+	query := `
+	DELETE FROM main.event_records;
+	DELETE FROM main.event_tenants;
+	DELETE FROM main.event_domains;
+	INSERT INTO main.event_tenants (id, uuid) SELECT id, uuid FROM primary_db.event_tenants;
+	INSERT INTO main.event_domains (id, name) SELECT id, name FROM primary_db.event_domains;
+	INSERT INTO main.event_records (id, instance_id, uuid, tenant_id, workspace_uuid, command_uuid, domain_id,
+		aggregate_uuid, version, created_at, data_type, data_bytes, req_ctx, checksum, data_size, is_encrypted)
+	SELECT id, instance_id, uuid, tenant_id, workspace_uuid, command_uuid, domain_id,
+		aggregate_uuid, version, created_at, data_type, data_bytes, req_ctx, checksum, length(data_bytes), is_encrypted
+	FROM primary_db.event_records;
+	`
+	if _, err := tx.ExecContext(ctx, query); err != nil {
+		return fmt.Errorf("'%s' failed to refresh replica - %w", es.String(), classifyError(err))
+	}
+	if err := tx.Commit(); err != nil {
+		return classifyError(err)
+	}
+	es.invalidateCache()
+	es.changes.notify()
+	loggerOrDiscard(es.cfg().Logger).DebugContext(ctx, "refreshed replica", "primary", primaryPath, "duration", time.Since(start))
+	return nil
+}
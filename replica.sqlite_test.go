@@ -0,0 +1,75 @@
+package store_test
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	store "github.com/gradientzero/comby-store-sqlite"
+	"github.com/gradientzero/comby/v3"
+)
+
+func TestEventStoreReplica(t *testing.T) {
+	ctx := context.Background()
+	primaryPath := filepath.Join(t.TempDir(), "eventStore-primary.db")
+	primary := store.NewEventStoreSQLite(primaryPath)
+	if err := primary.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer primary.Close(ctx)
+	for i := int64(1); i <= 3; i++ {
+		evt := createTestEvent("tenant-1", "domain-1", i, i*100)
+		if err := primary.Create(ctx, comby.EventStoreCreateOptionWithEvent(evt)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	replica := store.NewEventStoreSQLite(filepath.Join(t.TempDir(), "eventStore-replica.db"))
+	replica.Configure(store.EventStoreSQLiteWithReplicaOf(primaryPath, 10*time.Millisecond))
+	if err := replica.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer replica.Close(ctx)
+
+	if total := replica.Total(ctx); total != 3 {
+		t.Fatalf("expected 3 events in replica, got %d", total)
+	}
+	evt := createTestEvent("tenant-2", "domain-2", 4, 400)
+	if err := replica.Create(ctx, comby.EventStoreCreateOptionWithEvent(evt)); err == nil {
+		t.Fatal("expected replica to be read-only")
+	}
+
+	// new and deleted events of the primary show up after the next refresh
+	if err := primary.Create(ctx, comby.EventStoreCreateOptionWithEvent(evt)); err != nil {
+		t.Fatal(err)
+	}
+	evts, _, err := primary.List(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := primary.Delete(ctx, comby.EventStoreDeleteOptionWithEventUuid(evts[0].GetEventUuid())); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		got, err := replica.Get(ctx, comby.EventStoreGetOptionWithEventUuid(evt.GetEventUuid()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != nil && replica.Total(ctx) == 3 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("replica was not refreshed")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if got, _ := replica.Get(ctx, comby.EventStoreGetOptionWithEventUuid(evt.GetEventUuid())); got.GetTenantUuid() != "tenant-2" || got.GetDomain() != "domain-2" {
+		t.Fatalf("unexpected replicated event: %+v", got)
+	}
+
+	if err := primary.(store.EventStoreSQLite).RefreshReplica(ctx); err == nil {
+		t.Fatal("expected error refreshing a store which is no replica")
+	}
+}
@@ -0,0 +1,331 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// resetTarget is a database emptied by ResetAll and the stores using it.
+type resetTarget struct {
+	db        *sql.DB
+	writeMus  []*sync.Mutex
+	events    []*eventStoreSQLite
+	commands  []*commandStoreSQLite
+	snapshots bool
+}
+
+// rank orders the targets, derived data first: snapshots, events, commands.
+func (t *resetTarget) rank() int {
+	switch {
+	case t.snapshots:
+		return 0
+	case len(t.events) > 0:
+		return 1
+	}
+	return 2
+}
+
+// ResetAll empties the given stores, e.g. between tests. Unlike Reset of a
+// single store the database files are kept, so the stores stay initialized
+// and usable. Stores sharing a connection pool, like those of Open, are
+// emptied in one transaction. Other databases are emptied one after another,
+// snapshot stores first, then event and command stores, so a failure leaves
+// no snapshot of removed events behind.
+//
+// All tables of a database are emptied, including projections and other
+// bookkeeping, except the admin audit log, which records the reset. It accepts
+// *Stores and the stores of this package.
+func ResetAll(ctx context.Context, stores ...any) error {
+	var targets []*resetTarget
+	target := func(db *sql.DB, writeMu *sync.Mutex) *resetTarget {
+		for _, t := range targets {
+			if t.db == db {
+				if !slices.Contains(t.writeMus, writeMu) {
+					t.writeMus = append(t.writeMus, writeMu)
+				}
+				return t
+			}
+		}
+		t := &resetTarget{db: db, writeMus: []*sync.Mutex{writeMu}}
+		targets = append(targets, t)
+		return t
+	}
+	var add func(store any) error
+	add = func(store any) error {
+		switch s := store.(type) {
+		case *Stores:
+			for _, store := range []any{s.EventStore, s.CommandStore, s.SnapshotStore} {
+				if store != nil {
+					if err := add(store); err != nil {
+						return err
+					}
+				}
+			}
+		case *eventStoreSQLite:
+			if s.db == nil || s.opts().ReadOnly {
+				return fmt.Errorf("'%s' failed to reset - instance is not initialized or readonly", s.String())
+			}
+			t := target(s.db, s.writeMu)
+			t.events = append(t.events, s)
+		case *commandStoreSQLite:
+			if s.db == nil || s.opts().ReadOnly {
+				return fmt.Errorf("'%s' failed to reset - instance is not initialized or readonly", s.String())
+			}
+			t := target(s.db, s.writeMu)
+			t.commands = append(t.commands, s)
+		case *snapshotStoreSQLite:
+			if s.db == nil {
+				return fmt.Errorf("snapshot store '%s' failed to reset - instance is not initialized", s.path)
+			}
+			target(s.db, s.writeMu).snapshots = true
+		default:
+			return fmt.Errorf("failed to reset stores - %T is not a sqlite store", store)
+		}
+		return nil
+	}
+	for _, store := range stores {
+		if err := add(store); err != nil {
+			return err
+		}
+	}
+	slices.SortStableFunc(targets, func(a, b *resetTarget) int { return a.rank() - b.rank() })
+
+	for _, t := range targets {
+		if err := t.reset(ctx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (t *resetTarget) reset(ctx context.Context) error {
+	// resets are administrative, they bypass the write limits
+	for _, writeMu := range t.writeMus {
+		writeMu.Lock()
+		defer writeMu.Unlock()
+	}
+	defer func() {
+		for _, es := range t.events {
+			es.invalidateCache()
+			es.changes.notify()
+		}
+		for _, cs := range t.commands {
+			cs.changes.notify()
+		}
+	}()
+
+	err := runTx(ctx, t.db, func(tx *sql.Tx) error {
+		var numEvents, numCommands int64
+		if len(t.events) > 0 {
+			numEvents = countRows(ctx, tx, "event_records", "")
+		}
+		if len(t.commands) > 0 {
+			numCommands = countRows(ctx, tx, "commands", "")
+		}
+
+		rows, err := tx.QueryContext(ctx, `SELECT name FROM sqlite_master WHERE type='table' AND name NOT LIKE 'sqlite_%' AND name NOT IN ('admin_audit', 'store_formats', 'payload_schemas') ORDER BY name;`)
+		if err != nil {
+			return err
+		}
+		var tables []string
+		for rows.Next() {
+			var table string
+			if err := rows.Scan(&table); err != nil {
+				rows.Close()
+				return err
+			}
+			tables = append(tables, table)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		// tables reference each other, they are consistent again once all are empty
+		if _, err := tx.ExecContext(ctx, "PRAGMA defer_foreign_keys=ON;"); err != nil {
+			return err
+		}
+		// triggers may write into tables emptied before, e.g. the event counters
+		for pass := 0; ; pass++ {
+			var deleted int64
+			for _, table := range tables {
+				res, err := tx.ExecContext(ctx, fmt.Sprintf(`DELETE FROM "%s";`, strings.ReplaceAll(table, `"`, `""`)))
+				if err != nil {
+					return fmt.Errorf("failed to empty table '%s' - %w", table, err)
+				}
+				n, _ := res.RowsAffected()
+				deleted += n
+			}
+			if deleted == 0 || pass == len(tables) {
+				break
+			}
+		}
+
+		if len(t.events) > 0 {
+			if err := insertAuditEntry(ctx, tx, newAuditEntry(ctx, "events", AdminOperationReset, "", numEvents)); err != nil {
+				return err
+			}
+		}
+		if len(t.commands) > 0 {
+			return insertAuditEntry(ctx, tx, newAuditEntry(ctx, "commands", AdminOperationReset, "", numCommands))
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to reset stores - %w", classifyError(err))
+	}
+	return nil
+}
+
+// ResetAndReinit empties the store like Reset, but the store stays
+// initialized. A migrated database is created next to the file and renamed
+// over it, so the file always holds a complete schema, also if the process
+// dies meanwhile. Writes of this process wait and background loops are
+// stopped until the new database is in place; like Reset it must not run
+// concurrently with reads. Stores sharing a database use ResetAll instead.
+func (es *eventStoreSQLite) ResetAndReinit(ctx context.Context) (err error) {
+	if es.db == nil || es.opts().ReadOnly {
+		return fmt.Errorf("'%s' failed to reset - instance is not initialized or readonly", es.String())
+	}
+	if es.shared {
+		return fmt.Errorf("'%s' failed to reset - database is shared with other stores, use ResetAll", es.String())
+	}
+	// background loops use the handle, they restart with the new one once
+	// writeMu is released
+	es.stopLoops()
+	defer func() {
+		if loopErr := es.startLoops(context.WithoutCancel(ctx)); loopErr != nil && err == nil {
+			err = fmt.Errorf("'%s' failed to restart background loops - %w", es.String(), loopErr)
+		}
+	}()
+	es.writeMu.Lock()
+	defer es.writeMu.Unlock()
+	defer es.changes.notify()
+	defer es.invalidateCache()
+
+	es.pendingAudit.Store(newAuditEntry(ctx, "events", AdminOperationReset, "", countRows(ctx, es.db, "events", "")))
+	db, err := reinitDatabase(ctx, es.path, es.db, es.connectTo, es.migrateDB)
+	if db != nil {
+		es.mu.Lock()
+		es.db = db
+		es.mu.Unlock()
+	}
+	if err != nil {
+		return fmt.Errorf("'%s' failed to reset - %w", es.String(), classifyError(err))
+	}
+	return nil
+}
+
+// ResetAndReinit empties the command store, see the event store.
+func (cs *commandStoreSQLite) ResetAndReinit(ctx context.Context) (err error) {
+	if cs.db == nil || cs.opts().ReadOnly {
+		return fmt.Errorf("'%s' failed to reset - instance is not initialized or readonly", cs.String())
+	}
+	if cs.shared {
+		return fmt.Errorf("'%s' failed to reset - database is shared with other stores, use ResetAll", cs.String())
+	}
+	cs.stopLoops()
+	defer func() {
+		if loopErr := cs.startLoops(context.WithoutCancel(ctx)); loopErr != nil && err == nil {
+			err = fmt.Errorf("'%s' failed to restart background loops - %w", cs.String(), loopErr)
+		}
+	}()
+	cs.writeMu.Lock()
+	defer cs.writeMu.Unlock()
+	defer cs.changes.notify()
+
+	cs.pendingAudit.Store(newAuditEntry(ctx, "commands", AdminOperationReset, "", countRows(ctx, cs.db, "commands", "")))
+	db, err := reinitDatabase(ctx, cs.path, cs.db, cs.connectTo, cs.migrateDB)
+	if db != nil {
+		cs.mu.Lock()
+		cs.db = db
+		cs.mu.Unlock()
+	}
+	if err != nil {
+		return fmt.Errorf("'%s' failed to reset - %w", cs.String(), classifyError(err))
+	}
+	return nil
+}
+
+// reinitDatabase migrates a new database next to the file at path and renames
+// it over the file. The old database is closed before, so its WAL can be
+// removed and is not applied to the new file. It returns the handle the store
+// continues with, also the reopened old file if the rename fails, and nil if
+// the old handle is still open.
+func reinitDatabase(ctx context.Context, path string, old *sql.DB, connectTo func(context.Context, string) (*sql.DB, error), migrate func(context.Context, *sql.DB) error) (*sql.DB, error) {
+	file := databaseFile(path)
+	if len(file) == 0 {
+		return nil, fmt.Errorf("in-memory databases can not be recreated")
+	}
+	tmp := file + ".reinit"
+	if err := removeDatabaseFiles(tmp); err != nil {
+		return nil, err
+	}
+	db, err := connectTo(ctx, tmp)
+	if err != nil {
+		return nil, err
+	}
+	err = migrate(ctx, db)
+	if err == nil {
+		// the new file must be complete without its WAL
+		_, err = db.ExecContext(ctx, "PRAGMA wal_checkpoint(TRUNCATE);")
+	}
+	if closeErr := db.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return nil, errors.Join(err, removeDatabaseFiles(tmp))
+	}
+
+	// from here on the old handle is gone, the store continues with the file at path
+	reopen := func(err error) (*sql.DB, error) {
+		db, connectErr := connectTo(ctx, path)
+		return db, errors.Join(err, connectErr)
+	}
+	if err := old.Close(); err != nil {
+		return reopen(err)
+	}
+	for _, suffix := range []string{"-wal", "-shm"} {
+		if err := removeFile(file + suffix); err != nil {
+			return reopen(err)
+		}
+		if err := removeFile(tmp + suffix); err != nil {
+			return reopen(err)
+		}
+	}
+	if err := os.Rename(tmp, file); err != nil {
+		return reopen(err)
+	}
+	// persist the rename itself
+	if dir, err := os.Open(filepath.Dir(file)); err == nil {
+		dir.Sync()
+		dir.Close()
+	}
+	return connectTo(ctx, path)
+}
+
+// removeDatabaseFiles removes the database file at path and the files sqlite
+// creates next to it.
+func removeDatabaseFiles(path string) error {
+	var errs []error
+	for _, suffix := range []string{"", "-wal", "-shm"} {
+		errs = append(errs, removeFile(path+suffix))
+	}
+	return errors.Join(errs...)
+}
+
+// removeFile removes the file at path if it exists.
+func removeFile(path string) error {
+	if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,256 @@
+package store_test
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	store "github.com/gradientzero/comby-store-sqlite"
+	"github.com/gradientzero/comby/v3"
+)
+
+func TestResetAllSharedStores(t *testing.T) {
+	ctx := context.Background()
+	stores := openTestStores(t, filepath.Join(t.TempDir(), "reset.db"), "12345678901234567890123456789012")
+
+	for version := int64(1); version <= 3; version++ {
+		evt := createTestEvent("tenant-1", "domain-1", version, version*100)
+		evt.SetAggregateUuid("aggregate-1")
+		if err := stores.EventStore.Create(ctx, comby.EventStoreCreateOptionWithEvent(evt)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := stores.CommandStore.Create(ctx, comby.CommandStoreCreateOptionWithCommand(createTestCommand("tenant-1", "domain-1", 100))); err != nil {
+		t.Fatal(err)
+	}
+	if err := stores.SnapshotStore.Save(ctx, &comby.SnapshotStoreModel{
+		AggregateUuid: "aggregate-1",
+		TenantUuid:    "tenant-1",
+		Domain:        "domain-1",
+		Version:       3,
+		Data:          []byte("snapshot"),
+		CreatedAt:     300,
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := store.ResetAll(store.WithAuditActor(ctx, "ops"), stores); err != nil {
+		t.Fatal(err)
+	}
+	if total := stores.EventStore.Total(ctx); total != 0 {
+		t.Fatalf("expected no events, got %d", total)
+	}
+	if total := stores.CommandStore.Total(ctx); total != 0 {
+		t.Fatalf("expected no commands, got %d", total)
+	}
+	if snapshot, err := stores.SnapshotStore.GetLatest(ctx, "aggregate-1"); err != nil || snapshot != nil {
+		t.Fatalf("expected no snapshot, got %v, %v", snapshot, err)
+	}
+	entries, err := stores.EventStore.(store.EventStoreSQLite).ListAdminAudit(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Operation != store.AdminOperationReset || entries[0].Rows != 3 || entries[0].Actor != "ops" {
+		t.Fatalf("unexpected audit entries: %+v", entries)
+	}
+	entries, err = stores.CommandStore.(store.CommandStoreSQLite).ListAdminAudit(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Operation != store.AdminOperationReset || entries[0].Rows != 1 {
+		t.Fatalf("unexpected audit entries: %+v", entries)
+	}
+
+	// the stores stay usable and derived tables start from scratch
+	if err := stores.EventStore.Create(ctx, comby.EventStoreCreateOptionWithEvent(createTestEvent("tenant-1", "domain-1", 1, 500))); err != nil {
+		t.Fatal(err)
+	}
+	counters, err := stores.EventStore.(store.EventStoreSQLite).DomainCounters(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(counters) != 1 || counters[0].Count != 1 {
+		t.Fatalf("unexpected counters: %+v", counters)
+	}
+}
+
+func TestResetAllSeparateStores(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	eventStore := store.NewEventStoreSQLite(filepath.Join(dir, "events.db"))
+	if err := eventStore.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer eventStore.Close(ctx)
+	commandStore := store.NewCommandStoreSQLite(filepath.Join(dir, "commands.db"))
+	if err := commandStore.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer commandStore.Close(ctx)
+
+	if err := eventStore.Create(ctx, comby.EventStoreCreateOptionWithEvent(createTestEvent("tenant-1", "domain-1", 1, 100))); err != nil {
+		t.Fatal(err)
+	}
+	if err := commandStore.Create(ctx, comby.CommandStoreCreateOptionWithCommand(createTestCommand("tenant-1", "domain-1", 100))); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.ResetAll(ctx, eventStore, commandStore); err != nil {
+		t.Fatal(err)
+	}
+	if eventStore.Total(ctx) != 0 || commandStore.Total(ctx) != 0 {
+		t.Fatalf("expected empty stores, got %d events and %d commands", eventStore.Total(ctx), commandStore.Total(ctx))
+	}
+
+	if err := store.ResetAll(ctx, eventStore, "events.db"); err == nil {
+		t.Fatal("expected an error for an unsupported store")
+	}
+	if err := store.ResetAll(ctx, store.NewEventStoreSQLite(filepath.Join(dir, "other.db"))); err == nil {
+		t.Fatal("expected an error for an uninitialized store")
+	}
+}
+
+func TestResetAndReinit(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	path := filepath.Join(dir, "events.db")
+	eventStore := store.NewEventStoreSQLite(path)
+	if err := eventStore.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer eventStore.Close(ctx)
+	for i := int64(1); i <= 3; i++ {
+		if err := eventStore.Create(ctx, comby.EventStoreCreateOptionWithEvent(createTestEvent("tenant-1", "domain-1", i, i*100))); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := eventStore.ResetAndReinit(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if total := eventStore.Total(ctx); total != 0 {
+		t.Fatalf("expected an empty store, got %d events", total)
+	}
+	// the store stays usable without Init
+	if err := eventStore.Create(ctx, comby.EventStoreCreateOptionWithEvent(createTestEvent("tenant-1", "domain-1", 1, 500))); err != nil {
+		t.Fatal(err)
+	}
+	if total := eventStore.Total(ctx); total != 1 {
+		t.Fatalf("expected 1 event, got %d", total)
+	}
+	entries, err := eventStore.ListAdminAudit(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Operation != store.AdminOperationReset || entries[0].Rows != 3 {
+		t.Fatalf("unexpected audit entries: %+v", entries)
+	}
+	if leftovers, _ := filepath.Glob(path + ".reinit*"); len(leftovers) > 0 {
+		t.Fatalf("unexpected leftovers: %v", leftovers)
+	}
+
+	// other processes see the new database
+	other := store.NewEventStoreSQLite(path)
+	if err := other.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer other.Close(ctx)
+	if total := other.Total(ctx); total != 1 {
+		t.Fatalf("expected 1 event in the file, got %d", total)
+	}
+}
+
+func TestResetAndReinitCommandStore(t *testing.T) {
+	ctx := context.Background()
+	commandStore := store.NewCommandStoreSQLite(filepath.Join(t.TempDir(), "commands.db"))
+	if err := commandStore.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer commandStore.Close(ctx)
+	if err := commandStore.Create(ctx, comby.CommandStoreCreateOptionWithCommand(createTestCommand("tenant-1", "domain-1", 100))); err != nil {
+		t.Fatal(err)
+	}
+	if err := commandStore.ResetAndReinit(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if total := commandStore.Total(ctx); total != 0 {
+		t.Fatalf("expected an empty store, got %d commands", total)
+	}
+	if err := commandStore.Create(ctx, comby.CommandStoreCreateOptionWithCommand(createTestCommand("tenant-1", "domain-1", 200))); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// syncWriter collects log output of background loops.
+type syncWriter struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (w *syncWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.buf.Write(p)
+}
+
+func (w *syncWriter) String() string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.buf.String()
+}
+
+func TestResetAndReinitWithBackgroundLoops(t *testing.T) {
+	ctx := context.Background()
+	logs := &syncWriter{}
+	eventStore := store.NewEventStoreSQLite(filepath.Join(t.TempDir(), "events.db"))
+	eventStore.Configure(
+		store.EventStoreSQLiteWithLogger(slog.New(slog.NewTextHandler(logs, nil))),
+		store.EventStoreSQLiteWithIncrementalVacuum(),
+		store.EventStoreSQLiteWithMaintenanceInterval(time.Millisecond),
+		store.EventStoreSQLiteWithCheckpointer(time.Millisecond, 0, store.CheckpointPassive),
+		store.EventStoreSQLiteWithChangePolling(time.Millisecond),
+		store.EventStoreSQLiteWithKeepalive(time.Millisecond),
+		store.EventStoreSQLiteWithMetrics(time.Minute),
+	)
+	if err := eventStore.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer eventStore.Close(ctx)
+
+	// the loops tick between and during the resets, run with -race
+	for i := int64(1); i <= 5; i++ {
+		if err := eventStore.Create(ctx, comby.EventStoreCreateOptionWithEvent(createTestEvent("tenant-1", "domain-1", i, i*100))); err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(5 * time.Millisecond)
+		if err := eventStore.ResetAndReinit(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	time.Sleep(20 * time.Millisecond)
+	if _, err := eventStore.Metrics(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(logs.String(), "failed") {
+		t.Fatalf("expected background loops to use the new database, got:\n%s", logs.String())
+	}
+}
+
+func TestResetAndReinitSharedStore(t *testing.T) {
+	ctx := context.Background()
+	stores, err := store.Open(filepath.Join(t.TempDir(), "store.db"), store.WithEventStore(), store.WithCommandStore())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := stores.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer stores.Close(ctx)
+	if err := stores.EventStore.ResetAndReinit(ctx); err == nil {
+		t.Fatal("expected reset of a shared store to fail")
+	}
+}
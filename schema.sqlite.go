@@ -0,0 +1,221 @@
+package store
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"time"
+)
+
+// StoreFormatVersion is the version of the database layout written by this
+// package. It changes whenever migrations change tables, views, indexes or
+// triggers. Migrations stamp it as user_version of the database.
+const StoreFormatVersion = 2
+
+// ErrSchemaTooNew is matched by SchemaVersionError.
+var ErrSchemaTooNew = errors.New("schema version is not supported")
+
+// SchemaVersionError is returned by Init for a database stamped with a format
+// version newer than StoreFormatVersion, i.e. written by a newer release of
+// this package. Its tables are neither migrated nor read, as their columns
+// may have changed in ways this release does not know.
+type SchemaVersionError struct {
+	// format version stamped in the database
+	Version int
+	// first release of this package which stamped Version, empty if unknown
+	Library string
+}
+
+func (e *SchemaVersionError) Error() string {
+	library := e.Library
+	if len(library) == 0 {
+		library = "a newer release"
+	}
+	return fmt.Sprintf("schema version %d requires library >= %s, this release supports up to %d", e.Version, library, StoreFormatVersion)
+}
+
+func (e *SchemaVersionError) Is(target error) bool {
+	return target == ErrSchemaTooNew
+}
+
+// store_formats records the release which stamped a format version first, so
+// older releases can name the release they would have to be upgraded to
+var formatTables = []strictTable{
+	{
+		name: "store_formats",
+		columns: `version INTEGER PRIMARY KEY,
+		library TEXT NOT NULL,
+		stamped_at INTEGER NOT NULL`,
+		copyColumns: `version, library, stamped_at`,
+	},
+}
+
+// module path of this package, looked up in the build info
+const modulePath = "github.com/gradientzero/comby-store-sqlite"
+
+// libraryVersion returns the module version of this package as built into the
+// binary, empty if it is unknown, e.g. in its own tests.
+func libraryVersion() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return ""
+	}
+	version := ""
+	if info.Main.Path == modulePath {
+		version = info.Main.Version
+	}
+	for _, dep := range info.Deps {
+		if dep.Path == modulePath {
+			version = dep.Version
+			if dep.Replace != nil {
+				version = dep.Replace.Version
+			}
+		}
+	}
+	if version == "(devel)" {
+		return ""
+	}
+	return version
+}
+
+// checkFormatVersion fails with a SchemaVersionError if the database was
+// stamped by a newer release.
+func checkFormatVersion(ctx context.Context, q queryer) error {
+	var version int
+	if err := q.QueryRowContext(ctx, "PRAGMA user_version;").Scan(&version); err != nil {
+		return err
+	}
+	if version <= StoreFormatVersion {
+		return nil
+	}
+	schemaErr := &SchemaVersionError{Version: version}
+	// the release is unknown if it did not record itself
+	var tables int
+	if err := q.QueryRowContext(ctx, `SELECT COUNT(*) FROM sqlite_master WHERE type='table' AND name='store_formats'`).Scan(&tables); err != nil {
+		return err
+	}
+	if tables == 0 {
+		return schemaErr
+	}
+	err := q.QueryRowContext(ctx, `SELECT library FROM store_formats WHERE version=?;`, version).Scan(&schemaErr.Library)
+	if err != nil && err != sql.ErrNoRows {
+		return err
+	}
+	return schemaErr
+}
+
+// stampFormatVersion stamps StoreFormatVersion as user_version and records
+// this release for it. It is part of the migration of every store, so stores
+// sharing a file stamp it once.
+func stampFormatVersion(ctx context.Context, tx *sql.Tx) error {
+	if err := checkFormatVersion(ctx, tx); err != nil {
+		return err
+	}
+	if err := migrateStrictTables(ctx, tx, discardLogger, formatTables...); err != nil {
+		return err
+	}
+	// the release is filled in once a build knowing it migrates
+	query := `INSERT INTO store_formats (version, library, stamped_at) VALUES (?, ?, ?)
+		ON CONFLICT(version) DO UPDATE SET library=excluded.library WHERE library='';`
+	if _, err := tx.ExecContext(ctx, query, StoreFormatVersion, libraryVersion(), time.Now().UnixNano()); err != nil {
+		return err
+	}
+	_, err := tx.ExecContext(ctx, fmt.Sprintf("PRAGMA user_version=%d;", StoreFormatVersion))
+	return err
+}
+
+// SchemaObject is a table, view, index or trigger of the database.
+type SchemaObject struct {
+	// "table", "view", "index" or "trigger"
+	Type string
+	Name string
+	// table an index or trigger belongs to, the name itself for tables and views
+	TableName string
+	// DDL as stored by SQLite
+	SQL string
+}
+
+// SchemaInfo describes the database of a store, so deployments can be
+// compared. Two databases with the same Checksum have the same DDL.
+type SchemaInfo struct {
+	FormatVersion int
+	// ordered by type and name, internal objects of SQLite are omitted
+	Objects []SchemaObject
+	// pragmas in effect on a connection of the store
+	Pragmas map[string]string
+	// sha256 of the type, name and DDL of all objects
+	Checksum string
+}
+
+// pragmas reported by SchemaInfo
+var schemaPragmas = []string{
+	"application_id", "auto_vacuum", "busy_timeout", "cache_size", "encoding", "foreign_keys",
+	"journal_mode", "mmap_size", "page_size", "synchronous", "temp_store", "user_version",
+}
+
+func schemaInfo(ctx context.Context, db *sql.DB) (*SchemaInfo, error) {
+	// pragmas like busy_timeout are per connection, all of them are read from one
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	info := &SchemaInfo{FormatVersion: StoreFormatVersion, Pragmas: map[string]string{}}
+	query := `SELECT type, name, tbl_name, sql FROM sqlite_master
+		WHERE name NOT LIKE 'sqlite_%' AND sql IS NOT NULL ORDER BY type ASC, name ASC;`
+	rows, err := conn.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	hash := sha256.New()
+	for rows.Next() {
+		var object SchemaObject
+		if err := rows.Scan(&object.Type, &object.Name, &object.TableName, &object.SQL); err != nil {
+			return nil, err
+		}
+		fmt.Fprintf(hash, "%s\x00%s\x00%s\x00", object.Type, object.Name, object.SQL)
+		info.Objects = append(info.Objects, object)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	info.Checksum = hex.EncodeToString(hash.Sum(nil))
+
+	for _, pragma := range schemaPragmas {
+		var value string
+		if err := conn.QueryRowContext(ctx, fmt.Sprintf("PRAGMA %s;", pragma)).Scan(&value); err != nil {
+			return nil, fmt.Errorf("failed to read pragma %s - %w", pragma, err)
+		}
+		info.Pragmas[pragma] = value
+	}
+	return info, nil
+}
+
+// SchemaInfo returns the DDL, indexes and pragmas of the database and the
+// format version of the store.
+func (es *eventStoreSQLite) SchemaInfo(ctx context.Context) (*SchemaInfo, error) {
+	info, err := schemaInfo(ctx, es.db)
+	if err != nil {
+		return nil, fmt.Errorf("'%s' failed to read schema - %w", es.String(), classifyError(err))
+	}
+	return info, nil
+}
+
+// SchemaInfo returns the DDL, indexes and pragmas of the database and the
+// format version of the store.
+func (cs *commandStoreSQLite) SchemaInfo(ctx context.Context) (*SchemaInfo, error) {
+	info, err := schemaInfo(ctx, cs.db)
+	if err != nil {
+		return nil, fmt.Errorf("'%s' failed to read schema - %w", cs.String(), classifyError(err))
+	}
+	return info, nil
+}
@@ -0,0 +1,117 @@
+package store_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	store "github.com/gradientzero/comby-store-sqlite"
+	"github.com/gradientzero/comby/v3"
+)
+
+func TestSchemaInfo(t *testing.T) {
+	ctx := context.Background()
+	var infos []*store.SchemaInfo
+	for _, name := range []string{"eventStore-schema-1.db", "eventStore-schema-2.db"} {
+		eventStore := store.NewEventStoreSQLite(filepath.Join(t.TempDir(), name))
+		if err := eventStore.Init(ctx); err != nil {
+			t.Fatal(err)
+		}
+		defer eventStore.Close(ctx)
+		info, err := eventStore.SchemaInfo(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		infos = append(infos, info)
+	}
+
+	info := infos[0]
+	if info.FormatVersion != store.StoreFormatVersion || info.Pragmas["journal_mode"] != "wal" || info.Pragmas["busy_timeout"] != "5000" {
+		t.Fatalf("unexpected schema info: %d %v", info.FormatVersion, info.Pragmas)
+	}
+	found := map[string]bool{}
+	for _, object := range info.Objects {
+		found[object.Type+" "+object.Name] = len(object.SQL) > 0
+	}
+	for _, want := range []string{"table event_records", "view events", "index event_records_uuid_index", "trigger event_counters_insert"} {
+		if !found[want] {
+			t.Fatalf("expected %s with its DDL, got %v", want, found)
+		}
+	}
+	// deployments with the same schema have the same checksum
+	if infos[0].Checksum != infos[1].Checksum {
+		t.Fatalf("expected equal checksums, got %s and %s", infos[0].Checksum, infos[1].Checksum)
+	}
+
+	commandStore := store.NewCommandStoreSQLite(filepath.Join(t.TempDir(), "commandStore-schema.db"))
+	if err := commandStore.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer commandStore.Close(ctx)
+	commandInfo, err := commandStore.SchemaInfo(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if commandInfo.Checksum == info.Checksum {
+		t.Fatal("expected different checksums of event and command store")
+	}
+}
+
+func TestSchemaVersionGuard(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "store.db")
+	eventStore := store.NewEventStoreSQLite(path)
+	if err := eventStore.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	info, err := eventStore.SchemaInfo(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Pragmas["user_version"] != strconv.Itoa(store.StoreFormatVersion) {
+		t.Fatalf("expected user_version %d, got %s", store.StoreFormatVersion, info.Pragmas["user_version"])
+	}
+	eventStore.Close(ctx)
+
+	// stands in for a newer release migrating the database
+	newer := store.StoreFormatVersion + 1
+	db, err := sql.Open(store.DefaultDriverName(), path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	query := fmt.Sprintf(`INSERT INTO store_formats (version, library, stamped_at) VALUES (%d, 'v9.0.0', 0);
+		PRAGMA user_version=%d;`, newer, newer)
+	if _, err := db.ExecContext(ctx, query); err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+
+	want := fmt.Sprintf("schema version %d requires library >= v9.0.0", newer)
+	check := func(err error) {
+		t.Helper()
+		var schemaErr *store.SchemaVersionError
+		if !errors.Is(err, store.ErrSchemaTooNew) || !errors.As(err, &schemaErr) || schemaErr.Version != newer {
+			t.Fatalf("expected schema version error, got %v", err)
+		}
+		if !strings.Contains(err.Error(), want) {
+			t.Fatalf("expected %q in %q", want, err.Error())
+		}
+	}
+	eventStore = store.NewEventStoreSQLite(path)
+	check(eventStore.Init(ctx))
+	eventStore.Close(ctx)
+	commandStore := store.NewCommandStoreSQLite(path)
+	check(commandStore.Init(ctx, func(opt *comby.CommandStoreOptions) (*comby.CommandStoreOptions, error) {
+		opt.ReadOnly = true
+		return opt, nil
+	}))
+	commandStore.Close(ctx)
+	snapshotStore := store.NewSnapshotStoreSQLite(path)
+	check(snapshotStore.Init(ctx))
+	snapshotStore.Close(ctx)
+}
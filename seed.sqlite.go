@@ -0,0 +1,143 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"time"
+
+	"github.com/gradientzero/comby/v3"
+)
+
+// SeedSpec describes the events generated by Seed. The same spec always
+// generates the same events, including their uuids and payloads.
+type SeedSpec struct {
+	// number of events to generate
+	Events int
+	// number of tenants, domains, aggregates and data types the events are
+	// spread over, 1 if unset
+	Tenants    int
+	Domains    int
+	Aggregates int
+	DataTypes  int
+	// approximate size of the JSON payloads in bytes, 64 if unset
+	PayloadSize int
+	// created_at of the first event, unix nano, 2024-01-01 UTC if unset
+	StartAt int64
+	// time between two events, one second if unset
+	Interval time.Duration
+	// seed of the pseudo-random generator
+	Seed uint64
+	// number of events per bulk transaction if the store is a BulkWriter
+	BatchSize int
+}
+
+// seed start of specs without StartAt, fixed to keep them reproducible
+var defaultSeedStartAt = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC).UnixNano()
+
+func (spec SeedSpec) withDefaults() (SeedSpec, error) {
+	if spec.Events < 0 || spec.Tenants < 0 || spec.Domains < 0 || spec.Aggregates < 0 ||
+		spec.DataTypes < 0 || spec.PayloadSize < 0 || spec.StartAt < 0 || spec.Interval < 0 {
+		return spec, fmt.Errorf("failed to seed events - spec has negative values")
+	}
+	spec.Tenants = max(spec.Tenants, 1)
+	spec.Domains = max(spec.Domains, 1)
+	spec.Aggregates = max(spec.Aggregates, 1)
+	spec.DataTypes = max(spec.DataTypes, 1)
+	if spec.PayloadSize == 0 {
+		spec.PayloadSize = 64
+	}
+	if spec.StartAt == 0 {
+		spec.StartAt = defaultSeedStartAt
+	}
+	if spec.Interval == 0 {
+		spec.Interval = time.Second
+	}
+	return spec, nil
+}
+
+// Seed writes the events described by spec to eventStore, e.g. to fill a
+// store for load tests, benchmarks or demos. Aggregates are spread evenly over
+// tenants and domains, every event belongs to a random aggregate and carries
+// the next version of it. Writes run within bulk transactions if eventStore
+// is a BulkWriter. It returns the number of written events, events written
+// before a failure are kept.
+func Seed(ctx context.Context, eventStore comby.EventStore, spec SeedSpec) (n int64, err error) {
+	if spec, err = spec.withDefaults(); err != nil {
+		return 0, err
+	}
+	rng := rand.New(rand.NewPCG(spec.Seed, 0x5eed))
+	type aggregate struct {
+		uuid, tenantUuid, domain string
+		version                  int64
+	}
+	tenantUuids := make([]string, spec.Tenants)
+	for i := range tenantUuids {
+		tenantUuids[i] = seedUuid(rng)
+	}
+	aggregates := make([]aggregate, spec.Aggregates)
+	for i := range aggregates {
+		aggregates[i] = aggregate{
+			uuid:       seedUuid(rng),
+			tenantUuid: tenantUuids[i%spec.Tenants],
+			domain:     fmt.Sprintf("domain-%d", (i/spec.Tenants)%spec.Domains+1),
+		}
+	}
+
+	if bulk, ok := eventStore.(BulkWriter); ok {
+		if err := bulk.BeginBulk(ctx, spec.BatchSize); err != nil {
+			return 0, err
+		}
+		defer func() {
+			if endErr := bulk.EndBulk(ctx); endErr != nil && err == nil {
+				err = endErr
+			}
+		}()
+	}
+	for ; n < int64(spec.Events); n++ {
+		agg := &aggregates[rng.IntN(len(aggregates))]
+		agg.version++
+		evt := comby.NewBaseEvent()
+		evt.SetEventUuid(seedUuid(rng))
+		evt.SetInstanceId(1)
+		evt.SetTenantUuid(agg.tenantUuid)
+		evt.SetCommandUuid(seedUuid(rng))
+		evt.SetDomain(agg.domain)
+		evt.SetAggregateUuid(agg.uuid)
+		evt.SetVersion(agg.version)
+		evt.SetCreatedAt(spec.StartAt + n*int64(spec.Interval))
+		evt.SetDomainEvtName(fmt.Sprintf("SeedEvent_%d", rng.IntN(spec.DataTypes)+1))
+		evt.SetDomainEvtBytes(seedPayload(rng, n, spec.PayloadSize))
+		if err := eventStore.Create(ctx, comby.EventStoreCreateOptionWithEvent(evt)); err != nil {
+			return n, err
+		}
+	}
+	return n, nil
+}
+
+// seedUuid returns a version 4 UUID drawn from rng.
+func seedUuid(rng *rand.Rand) string {
+	var b [16]byte
+	for i := 0; i < len(b); i += 8 {
+		v := rng.Uint64()
+		for j := 0; j < 8; j++ {
+			b[i+j] = byte(v >> (8 * j))
+		}
+	}
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
+
+// lowercase letters of seeded payload values
+const seedAlphabet = "abcdefghijklmnopqrstuvwxyz"
+
+// seedPayload returns a JSON object of about size bytes.
+func seedPayload(rng *rand.Rand, n int64, size int) []byte {
+	prefix := fmt.Sprintf(`{"n":%d,"value":"`, n)
+	value := make([]byte, max(size-len(prefix)-2, 0))
+	for i := range value {
+		value[i] = seedAlphabet[rng.IntN(len(seedAlphabet))]
+	}
+	return []byte(prefix + string(value) + `"}`)
+}
@@ -0,0 +1,88 @@
+package store_test
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	store "github.com/gradientzero/comby-store-sqlite"
+	"github.com/gradientzero/comby/v3"
+)
+
+func TestSeed(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	spec := store.SeedSpec{
+		Events:      200,
+		Tenants:     2,
+		Domains:     3,
+		Aggregates:  12,
+		DataTypes:   4,
+		PayloadSize: 100,
+		Interval:    time.Millisecond,
+		Seed:        42,
+	}
+	seeded := func(name string, spec store.SeedSpec) []comby.Event {
+		eventStore := store.NewEventStoreSQLite(filepath.Join(dir, name))
+		if err := eventStore.Init(ctx); err != nil {
+			t.Fatal(err)
+		}
+		defer eventStore.Close(ctx)
+		n, err := store.Seed(ctx, eventStore, spec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n != int64(spec.Events) {
+			t.Fatalf("expected %d seeded events, got %d", spec.Events, n)
+		}
+		all := func(opts *comby.EventStoreListOptions) (*comby.EventStoreListOptions, error) {
+			opts.Limit = -1
+			return opts, nil
+		}
+		evts, _, err := eventStore.List(ctx, all, comby.EventStoreListOptionOrderBy("created_at"), comby.EventStoreListOptionAscending(true))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return evts
+	}
+
+	evts := seeded("first.db", spec)
+	if len(evts) != 200 {
+		t.Fatalf("expected 200 events, got %d", len(evts))
+	}
+	tenants, domains, dataTypes := map[string]bool{}, map[string]bool{}, map[string]bool{}
+	versions := map[string]int64{}
+	for _, evt := range evts {
+		tenants[evt.GetTenantUuid()] = true
+		domains[evt.GetDomain()] = true
+		dataTypes[evt.GetDomainEvtName()] = true
+		if evt.GetVersion() != versions[evt.GetAggregateUuid()]+1 {
+			t.Fatalf("expected contiguous versions, got %d after %d", evt.GetVersion(), versions[evt.GetAggregateUuid()])
+		}
+		versions[evt.GetAggregateUuid()] = evt.GetVersion()
+		if size := len(evt.GetDomainEvtBytes()); size != 100 {
+			t.Fatalf("expected payloads of 100 bytes, got %d", size)
+		}
+	}
+	if len(tenants) != 2 || len(domains) != 3 || len(dataTypes) != 4 || len(versions) != 12 {
+		t.Fatalf("unexpected spread: %d tenants, %d domains, %d data types, %d aggregates", len(tenants), len(domains), len(dataTypes), len(versions))
+	}
+	if last := evts[len(evts)-1].GetCreatedAt() - evts[0].GetCreatedAt(); last != int64(199*time.Millisecond) {
+		t.Fatalf("unexpected time span %d", last)
+	}
+
+	// the same spec generates the same events, another seed different ones
+	if again := seeded("second.db", spec); !reflect.DeepEqual(again, evts) {
+		t.Fatal("expected the same events for the same spec")
+	}
+	spec.Seed = 43
+	if other := seeded("third.db", spec); other[0].GetEventUuid() == evts[0].GetEventUuid() {
+		t.Fatal("expected different events for another seed")
+	}
+
+	if _, err := store.Seed(ctx, store.NewEventStoreSQLite(filepath.Join(dir, "invalid.db")), store.SeedSpec{Events: -1}); err == nil {
+		t.Fatal("expected an error for a negative number of events")
+	}
+}
@@ -0,0 +1,108 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// vacuumInto writes the database of db into a new file at path within one
+// read transaction, so the copy is consistent while writes continue.
+func vacuumInto(ctx context.Context, db *sql.DB, path string) error {
+	if _, err := os.Stat(path); err == nil {
+		return fmt.Errorf("'%s' exists", path)
+	}
+	_, err := db.ExecContext(ctx, "VACUUM INTO ?;", path)
+	return err
+}
+
+// snapshotBytes returns the bytes of a copy of the database of db.
+func snapshotBytes(ctx context.Context, db *sql.DB) ([]byte, error) {
+	dir, err := os.MkdirTemp("", "comby-snapshot-*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "snapshot.db")
+	if err := vacuumInto(ctx, db, path); err != nil {
+		return nil, err
+	}
+	return os.ReadFile(path)
+}
+
+// Snapshot returns the current state of the database as the content of a
+// database file, e.g. to capture it for debugging or as golden file of a
+// test. It is taken within one read transaction, so it is consistent while
+// writes continue, and contains all tables of the file. Large databases
+// should be written with SnapshotFile instead.
+func (es *eventStoreSQLite) Snapshot(ctx context.Context) ([]byte, error) {
+	if err := es.checkSnapshot(); err != nil {
+		return nil, err
+	}
+	data, err := snapshotBytes(ctx, es.db)
+	if err != nil {
+		return nil, fmt.Errorf("'%s' failed to take snapshot - %w", es.String(), classifyError(err))
+	}
+	return data, nil
+}
+
+// SnapshotFile writes the current state of the database into a new file at
+// path, see Snapshot.
+func (es *eventStoreSQLite) SnapshotFile(ctx context.Context, path string) error {
+	if err := es.checkSnapshot(); err != nil {
+		return err
+	}
+	if err := vacuumInto(ctx, es.db, path); err != nil {
+		return fmt.Errorf("'%s' failed to take snapshot - %w", es.String(), classifyError(err))
+	}
+	return nil
+}
+
+// checkSnapshot refuses snapshots of uninitialized stores and of stores bound
+// to a tenant, as the snapshot contains all tenants.
+func (es *eventStoreSQLite) checkSnapshot() error {
+	if es.db == nil {
+		return fmt.Errorf("'%s' failed to take snapshot - instance is not initialized", es.String())
+	}
+	if len(es.cfg().Tenant) > 0 {
+		return fmt.Errorf("'%s' failed to take snapshot - %w", es.String(), ErrTenantMismatch)
+	}
+	return nil
+}
+
+// Snapshot returns the current state of the database of the command store,
+// see the event store.
+func (cs *commandStoreSQLite) Snapshot(ctx context.Context) ([]byte, error) {
+	if err := cs.checkSnapshot(); err != nil {
+		return nil, err
+	}
+	data, err := snapshotBytes(ctx, cs.db)
+	if err != nil {
+		return nil, fmt.Errorf("'%s' failed to take snapshot - %w", cs.String(), classifyError(err))
+	}
+	return data, nil
+}
+
+// SnapshotFile writes the current state of the database into a new file at
+// path.
+func (cs *commandStoreSQLite) SnapshotFile(ctx context.Context, path string) error {
+	if err := cs.checkSnapshot(); err != nil {
+		return err
+	}
+	if err := vacuumInto(ctx, cs.db, path); err != nil {
+		return fmt.Errorf("'%s' failed to take snapshot - %w", cs.String(), classifyError(err))
+	}
+	return nil
+}
+
+func (cs *commandStoreSQLite) checkSnapshot() error {
+	if cs.db == nil {
+		return fmt.Errorf("'%s' failed to take snapshot - instance is not initialized", cs.String())
+	}
+	if len(cs.cfg().Tenant) > 0 {
+		return fmt.Errorf("'%s' failed to take snapshot - %w", cs.String(), ErrTenantMismatch)
+	}
+	return nil
+}
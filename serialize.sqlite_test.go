@@ -0,0 +1,88 @@
+package store_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	store "github.com/gradientzero/comby-store-sqlite"
+	"github.com/gradientzero/comby-store-sqlite/storetest"
+	"github.com/gradientzero/comby/v3"
+)
+
+func TestEventStoreSnapshot(t *testing.T) {
+	ctx := context.Background()
+	eventStore := storetest.NewMemoryEventStore(t)
+	evt := createTestEvent("tenant-1", "domain-1", 1, 100)
+	if err := eventStore.Create(ctx, comby.EventStoreCreateOptionWithEvent(evt)); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := eventStore.Snapshot(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(data, []byte("SQLite format 3\x00")) {
+		t.Fatal("expected the content of a database file")
+	}
+	// later writes are not part of the snapshot
+	if err := eventStore.Create(ctx, comby.EventStoreCreateOptionWithEvent(createTestEvent("tenant-1", "domain-1", 2, 200))); err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(t.TempDir(), "snapshot.db")
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	restored := store.NewEventStoreSQLite(path)
+	if err := restored.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer restored.Close(ctx)
+	if total := restored.Total(ctx); total != 1 {
+		t.Fatalf("expected 1 event in the snapshot, got %d", total)
+	}
+	if got, err := restored.Get(ctx, comby.EventStoreGetOptionWithEventUuid(evt.GetEventUuid())); err != nil || got == nil {
+		t.Fatalf("expected event in the snapshot: %v", err)
+	}
+
+	filePath := filepath.Join(t.TempDir(), "snapshot-file.db")
+	if err := eventStore.SnapshotFile(ctx, filePath); err != nil {
+		t.Fatal(err)
+	}
+	if err := eventStore.SnapshotFile(ctx, filePath); err == nil {
+		t.Fatal("expected snapshot into an existing file to fail")
+	}
+
+	bound := storetest.NewMemoryEventStore(t, store.EventStoreSQLiteWithTenant("tenant-1"))
+	if _, err := bound.Snapshot(ctx); !errors.Is(err, store.ErrTenantMismatch) {
+		t.Fatalf("expected tenant mismatch, got %v", err)
+	}
+}
+
+func TestCommandStoreSnapshot(t *testing.T) {
+	ctx := context.Background()
+	commandStore := store.NewCommandStoreSQLite(filepath.Join(t.TempDir(), "commands.db"))
+	if err := commandStore.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer commandStore.Close(ctx)
+	if err := commandStore.Create(ctx, comby.CommandStoreCreateOptionWithCommand(createTestCommand("tenant-1", "domain-1", 100))); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "snapshot.db")
+	if err := commandStore.SnapshotFile(ctx, path); err != nil {
+		t.Fatal(err)
+	}
+	restored := store.NewCommandStoreSQLite(path)
+	if err := restored.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer restored.Close(ctx)
+	if total := restored.Total(ctx); total != 1 {
+		t.Fatalf("expected 1 command in the snapshot, got %d", total)
+	}
+}
@@ -0,0 +1,111 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"math/bits"
+)
+
+// EventSize is the stored payload size of an event, encrypted payloads are
+// counted as stored (hex encoded).
+type EventSize struct {
+	EventUuid     string
+	Domain        string
+	DataType      string
+	AggregateUuid string
+	CreatedAt     int64
+	Size          int64
+}
+
+// DataTypeSize sums up the payload sizes of one data type.
+type DataTypeSize struct {
+	Domain    string
+	DataType  string
+	Count     int64
+	TotalSize int64
+	MaxSize   int64
+}
+
+// SizeBucket counts the events with a payload size in [MinSize, MaxSize).
+type SizeBucket struct {
+	MinSize   int64
+	MaxSize   int64
+	Count     int64
+	TotalSize int64
+}
+
+// TopBySize returns the n events with the largest payloads, largest first.
+func (es *eventStoreSQLite) TopBySize(ctx context.Context, n int) ([]EventSize, error) {
+	if n < 1 {
+		return nil, fmt.Errorf("'%s' failed to list largest events - n must be positive", es.String())
+	}
+	query := `SELECT uuid, domain, data_type, aggregate_uuid, created_at, data_size
+		FROM events ORDER BY data_size DESC, id ASC LIMIT ?;`
+	rows, err := es.db.QueryContext(ctx, query, n)
+	if err != nil {
+		return nil, classifyError(err)
+	}
+	defer rows.Close()
+
+	var sizes []EventSize
+	for rows.Next() {
+		var size EventSize
+		if err := rows.Scan(&size.EventUuid, &size.Domain, &size.DataType, &size.AggregateUuid, &size.CreatedAt, &size.Size); err != nil {
+			return nil, classifyError(err)
+		}
+		sizes = append(sizes, size)
+	}
+	return sizes, classifyError(rows.Err())
+}
+
+// SizeByDataType returns the payload sizes per domain and data type, the data
+// types taking the most space first.
+func (es *eventStoreSQLite) SizeByDataType(ctx context.Context) ([]DataTypeSize, error) {
+	query := `SELECT domain, data_type, COUNT(*), SUM(data_size), MAX(data_size)
+		FROM events GROUP BY domain, data_type ORDER BY SUM(data_size) DESC, domain ASC, data_type ASC;`
+	rows, err := es.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, classifyError(err)
+	}
+	defer rows.Close()
+
+	var sizes []DataTypeSize
+	for rows.Next() {
+		var size DataTypeSize
+		if err := rows.Scan(&size.Domain, &size.DataType, &size.Count, &size.TotalSize, &size.MaxSize); err != nil {
+			return nil, classifyError(err)
+		}
+		sizes = append(sizes, size)
+	}
+	return sizes, classifyError(rows.Err())
+}
+
+// SizeHistogram counts the events per payload size in power of two buckets
+// (0, 1, 2-3, 4-7, ...). Empty buckets are omitted.
+func (es *eventStoreSQLite) SizeHistogram(ctx context.Context) ([]SizeBucket, error) {
+	rows, err := es.db.QueryContext(ctx, `SELECT data_size, COUNT(*) FROM event_records GROUP BY data_size ORDER BY data_size ASC;`)
+	if err != nil {
+		return nil, classifyError(err)
+	}
+	defer rows.Close()
+
+	var buckets []SizeBucket
+	for rows.Next() {
+		var size, count int64
+		if err := rows.Scan(&size, &count); err != nil {
+			return nil, classifyError(err)
+		}
+		minSize, maxSize := int64(0), int64(1)
+		if size > 0 {
+			n := bits.Len64(uint64(size))
+			minSize, maxSize = int64(1)<<(n-1), int64(1)<<n
+		}
+		if len(buckets) == 0 || buckets[len(buckets)-1].MinSize != minSize {
+			buckets = append(buckets, SizeBucket{MinSize: minSize, MaxSize: maxSize})
+		}
+		bucket := &buckets[len(buckets)-1]
+		bucket.Count += count
+		bucket.TotalSize += size * count
+	}
+	return buckets, classifyError(rows.Err())
+}
@@ -0,0 +1,122 @@
+package store_test
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	store "github.com/gradientzero/comby-store-sqlite"
+	"github.com/gradientzero/comby/v3"
+)
+
+func TestEventStorePayloadSizes(t *testing.T) {
+	ctx := context.Background()
+	eventStore := store.NewEventStoreSQLite(filepath.Join(t.TempDir(), "sizes.db"))
+	if err := eventStore.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer eventStore.Close(ctx)
+
+	var evts []comby.Event
+	for i, size := range []int{1, 3, 100, 5} {
+		evt := createTestEvent("tenant-1", "domain-1", int64(i+1), int64(i+1))
+		evt.SetDomainEvtName("Small")
+		if size == 100 {
+			evt.SetDomainEvtName("Large")
+		}
+		evt.SetDomainEvtBytes([]byte(strings.Repeat("x", size)))
+		if err := eventStore.Create(ctx, comby.EventStoreCreateOptionWithEvent(evt)); err != nil {
+			t.Fatal(err)
+		}
+		evts = append(evts, evt)
+	}
+
+	top, err := eventStore.TopBySize(ctx, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(top) != 2 || top[0].EventUuid != evts[2].GetEventUuid() || top[0].Size != 100 || top[1].Size != 5 {
+		t.Fatalf("unexpected largest events: %+v", top)
+	}
+
+	byType, err := eventStore.SizeByDataType(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []store.DataTypeSize{
+		{Domain: "domain-1", DataType: "Large", Count: 1, TotalSize: 100, MaxSize: 100},
+		{Domain: "domain-1", DataType: "Small", Count: 3, TotalSize: 9, MaxSize: 5},
+	}
+	if len(byType) != 2 || byType[0] != want[0] || byType[1] != want[1] {
+		t.Fatalf("unexpected sizes by data type: %+v", byType)
+	}
+
+	histogram, err := eventStore.SizeHistogram(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantBuckets := []store.SizeBucket{
+		{MinSize: 1, MaxSize: 2, Count: 1, TotalSize: 1},
+		{MinSize: 2, MaxSize: 4, Count: 1, TotalSize: 3},
+		{MinSize: 4, MaxSize: 8, Count: 1, TotalSize: 5},
+		{MinSize: 64, MaxSize: 128, Count: 1, TotalSize: 100},
+	}
+	if fmt.Sprint(histogram) != fmt.Sprint(wantBuckets) {
+		t.Fatalf("unexpected histogram: %+v", histogram)
+	}
+
+	// updates maintain the size
+	evts[2].SetDomainEvtBytes([]byte("x"))
+	if err := eventStore.Update(ctx, comby.EventStoreUpdateOptionWithEvent(evts[2])); err != nil {
+		t.Fatal(err)
+	}
+	if top, err := eventStore.TopBySize(ctx, 1); err != nil {
+		t.Fatal(err)
+	} else if top[0].Size != 5 {
+		t.Fatalf("unexpected largest event after update: %+v", top)
+	}
+}
+
+func TestEventStorePayloadSizeMigration(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "sizes-migration.db")
+	eventStore := store.NewEventStoreSQLite(path)
+	if err := eventStore.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	evt := createTestEvent("tenant-1", "domain-1", 1, 100)
+	if err := eventStore.Create(ctx, comby.EventStoreCreateOptionWithEvent(evt)); err != nil {
+		t.Fatal(err)
+	}
+	eventStore.Close(ctx)
+
+	// database written before payload sizes were tracked
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if _, err := db.ExecContext(ctx, `
+	DROP VIEW events;
+	DROP INDEX event_records_data_size_index;
+	ALTER TABLE event_records DROP COLUMN data_size;
+	`); err != nil {
+		t.Fatal(err)
+	}
+
+	eventStore = store.NewEventStoreSQLite(path)
+	if err := eventStore.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer eventStore.Close(ctx)
+	top, err := eventStore.TopBySize(ctx, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(top) != 1 || top[0].Size != int64(len(evt.GetDomainEvtBytes())) {
+		t.Fatalf("unexpected backfilled size: %+v", top)
+	}
+}
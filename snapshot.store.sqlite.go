@@ -4,10 +4,11 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"log/slog"
+	"sync"
 	"time"
 
 	"github.com/gradientzero/comby/v3"
-	_ "modernc.org/sqlite"
 )
 
 // SnapshotStoreSQLiteOption configures the SQLite snapshot store.
@@ -15,7 +16,14 @@ type SnapshotStoreSQLiteOption func(*snapshotStoreSQLiteConfig)
 
 type snapshotStoreSQLiteConfig struct {
 	MaxOpenConns    int
+	MaxIdleConns    int
 	ConnMaxIdleTime time.Duration
+	ConnMaxLifetime time.Duration
+	Logger          *slog.Logger
+	// database/sql driver the store connects with, the default driver if empty
+	DriverName string
+	// interval of validating pooled connections, 0 disables it
+	Keepalive time.Duration
 }
 
 // SnapshotStoreSQLiteWithMaxOpenConns sets the maximum number of open connections.
@@ -28,6 +36,22 @@ func SnapshotStoreSQLiteWithConnMaxIdleTime(d time.Duration) SnapshotStoreSQLite
 	return func(c *snapshotStoreSQLiteConfig) { c.ConnMaxIdleTime = d }
 }
 
+// SnapshotStoreSQLiteWithMaxIdleConns sets the maximum number of idle connections.
+func SnapshotStoreSQLiteWithMaxIdleConns(n int) SnapshotStoreSQLiteOption {
+	return func(c *snapshotStoreSQLiteConfig) { c.MaxIdleConns = n }
+}
+
+// SnapshotStoreSQLiteWithConnMaxLifetime closes connections after d, so
+// long running services reopen the database file from time to time.
+func SnapshotStoreSQLiteWithConnMaxLifetime(d time.Duration) SnapshotStoreSQLiteOption {
+	return func(c *snapshotStoreSQLiteConfig) { c.ConnMaxLifetime = d }
+}
+
+// SnapshotStoreSQLiteWithLogger sets the logger used for migrations.
+func SnapshotStoreSQLiteWithLogger(logger *slog.Logger) SnapshotStoreSQLiteOption {
+	return func(c *snapshotStoreSQLiteConfig) { c.Logger = logger }
+}
+
 // Make sure it implements interfaces
 var _ comby.SnapshotStore = (*snapshotStoreSQLite)(nil)
 
@@ -35,11 +59,15 @@ type snapshotStoreSQLite struct {
 	db     *sql.DB
 	config snapshotStoreSQLiteConfig
 	path   string
+	sharedDB
+	// periodic validation of pooled connections, if configured
+	keepalive *maintenanceLoop
 }
 
 func NewSnapshotStoreSQLite(path string, opts ...SnapshotStoreSQLiteOption) comby.SnapshotStore {
 	s := &snapshotStoreSQLite{
-		path: path,
+		path:     path,
+		sharedDB: sharedDB{writeMu: &sync.Mutex{}},
 	}
 	for _, opt := range opts {
 		opt(&s.config)
@@ -48,7 +76,7 @@ func NewSnapshotStoreSQLite(path string, opts ...SnapshotStoreSQLiteOption) comb
 }
 
 func (s *snapshotStoreSQLite) connect(ctx context.Context) (*sql.DB, error) {
-	db, err := sql.Open("sqlite", s.path)
+	db, err := sql.Open(driverName(s.config.DriverName), opTimeouts{}.dsn(s.path))
 	if err != nil {
 		return nil, err
 	}
@@ -59,16 +87,23 @@ func (s *snapshotStoreSQLite) connect(ctx context.Context) (*sql.DB, error) {
 	}
 	db.SetMaxOpenConns(maxOpenConns)
 
+	if s.config.MaxIdleConns > 0 {
+		db.SetMaxIdleConns(s.config.MaxIdleConns)
+	}
+
 	if s.config.ConnMaxIdleTime > 0 {
 		db.SetConnMaxIdleTime(s.config.ConnMaxIdleTime)
 	} else {
 		db.SetConnMaxIdleTime(5 * time.Minute)
 	}
 
+	if s.config.ConnMaxLifetime > 0 {
+		db.SetConnMaxLifetime(s.config.ConnMaxLifetime)
+	}
+
 	query := `
 	PRAGMA journal_mode=WAL;
 	PRAGMA synchronous=NORMAL;
-	PRAGMA busy_timeout=5000;
 	`
 	if _, err := db.ExecContext(ctx, query); err != nil {
 		return nil, err
@@ -76,52 +111,91 @@ func (s *snapshotStoreSQLite) connect(ctx context.Context) (*sql.DB, error) {
 	return db, nil
 }
 
-func (s *snapshotStoreSQLite) migrate(ctx context.Context) error {
-	query := `
-	CREATE TABLE IF NOT EXISTS snapshots (
-		aggregate_uuid TEXT PRIMARY KEY,
+var snapshotTables = []strictTable{
+	{
+		name: "snapshots",
+		columns: `aggregate_uuid TEXT NOT NULL PRIMARY KEY,
 		tenant_uuid TEXT,
 		workspace_uuid TEXT,
 		domain TEXT NOT NULL,
-		version INTEGER NOT NULL,
+		version INTEGER NOT NULL CHECK (version >= 0),
 		data BLOB NOT NULL,
-		created_at INTEGER NOT NULL
-	);
-	CREATE INDEX IF NOT EXISTS "snapshots_tenant_index" ON "snapshots" ("tenant_uuid" ASC);
-	CREATE INDEX IF NOT EXISTS "snapshots_workspace_index" ON "snapshots" ("workspace_uuid" ASC);
-	`
-	if _, err := s.db.ExecContext(ctx, query); err != nil {
-		return err
-	}
-	// migrate existing databases: add tenant_uuid + workspace_uuid columns if they don't exist
-	for _, col := range []string{"tenant_uuid", "workspace_uuid"} {
-		var count int
-		if err := s.db.QueryRowContext(ctx, fmt.Sprintf(`SELECT COUNT(*) FROM pragma_table_info('snapshots') WHERE name='%s'`, col)).Scan(&count); err != nil {
+		created_at INTEGER NOT NULL`,
+		copyColumns: `aggregate_uuid, tenant_uuid, workspace_uuid, domain, version, CAST(data AS BLOB), created_at`,
+	},
+}
+
+func (s *snapshotStoreSQLite) migrate(ctx context.Context) error {
+	return migrateTx(ctx, s.db, func(tx *sql.Tx) error {
+		if err := stampFormatVersion(ctx, tx); err != nil {
 			return err
 		}
-		if count == 0 {
-			if _, err := s.db.ExecContext(ctx, fmt.Sprintf(`ALTER TABLE snapshots ADD COLUMN %s TEXT`, col)); err != nil {
+		// migrate existing databases: add tenant_uuid + workspace_uuid columns if they don't exist
+		var exists int
+		if err := tx.QueryRowContext(ctx, `SELECT COUNT(*) FROM sqlite_master WHERE type='table' AND name='snapshots'`).Scan(&exists); err != nil {
+			return err
+		}
+		for _, col := range []string{"tenant_uuid", "workspace_uuid"} {
+			if exists == 0 {
+				break
+			}
+			var count int
+			if err := tx.QueryRowContext(ctx, fmt.Sprintf(`SELECT COUNT(*) FROM pragma_table_info('snapshots') WHERE name='%s'`, col)).Scan(&count); err != nil {
 				return err
 			}
+			if count == 0 {
+				if _, err := tx.ExecContext(ctx, fmt.Sprintf(`ALTER TABLE snapshots ADD COLUMN %s TEXT`, col)); err != nil {
+					return err
+				}
+			}
 		}
-	}
-	return nil
+
+		if err := migrateStrictTables(ctx, tx, loggerOrDiscard(s.config.Logger), snapshotTables...); err != nil {
+			return err
+		}
+		query := `
+		CREATE INDEX IF NOT EXISTS "snapshots_tenant_index" ON "snapshots" ("tenant_uuid" ASC);
+		CREATE INDEX IF NOT EXISTS "snapshots_workspace_index" ON "snapshots" ("workspace_uuid" ASC);
+		`
+		_, err := tx.ExecContext(ctx, query)
+		return err
+	})
 }
 
 func (s *snapshotStoreSQLite) Init(ctx context.Context) error {
-	db, err := s.connect(ctx)
-	if err != nil {
-		return err
+	if !s.shared {
+		db, err := s.connect(ctx)
+		if err != nil {
+			return err
+		}
+		s.db = db
+	}
+
+	// databases of newer releases are neither migrated nor read
+	if err := checkFormatVersion(ctx, s.db); err != nil {
+		return fmt.Errorf("'sqlite - %s' failed to init - %w", s.path, classifyError(err))
 	}
-	s.db = db
 
 	if err := s.migrate(ctx); err != nil {
-		return err
+		return classifyError(err)
 	}
+	s.initKeepalive()
 	return nil
 }
 
-func (s *snapshotStoreSQLite) Save(ctx context.Context, model *comby.SnapshotStoreModel) error {
+func (s *snapshotStoreSQLite) Save(ctx context.Context, model *comby.SnapshotStoreModel) (err error) {
+	defer wrapOpError(&err, "save", "snapshots", time.Now(), func() (string, []string) {
+		if model == nil {
+			return "", nil
+		}
+		return model.AggregateUuid, nil
+	})
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	return s.save(ctx, s.db, model)
+}
+
+func (s *snapshotStoreSQLite) save(ctx context.Context, q queryer, model *comby.SnapshotStoreModel) error {
 	if model == nil {
 		return fmt.Errorf("snapshot model is nil")
 	}
@@ -136,7 +210,7 @@ func (s *snapshotStoreSQLite) Save(ctx context.Context, model *comby.SnapshotSto
 			data=excluded.data,
 			created_at=excluded.created_at;`
 
-	_, err := s.db.ExecContext(ctx, query,
+	_, err := q.ExecContext(ctx, query,
 		model.AggregateUuid,
 		model.TenantUuid,
 		model.WorkspaceUuid,
@@ -148,11 +222,18 @@ func (s *snapshotStoreSQLite) Save(ctx context.Context, model *comby.SnapshotSto
 	return err
 }
 
-func (s *snapshotStoreSQLite) GetLatest(ctx context.Context, aggregateUuid string) (*comby.SnapshotStoreModel, error) {
+func (s *snapshotStoreSQLite) GetLatest(ctx context.Context, aggregateUuid string) (_ *comby.SnapshotStoreModel, err error) {
+	defer wrapOpError(&err, "get latest", "snapshots", time.Now(), func() (string, []string) {
+		return aggregateUuid, nil
+	})
+	return s.getLatest(ctx, s.db, aggregateUuid)
+}
+
+func (s *snapshotStoreSQLite) getLatest(ctx context.Context, q queryer, aggregateUuid string) (*comby.SnapshotStoreModel, error) {
 	query := `SELECT aggregate_uuid, COALESCE(tenant_uuid, ''), COALESCE(workspace_uuid, ''), domain, version, data, created_at
 		FROM snapshots WHERE aggregate_uuid=? LIMIT 1;`
 
-	row := s.db.QueryRowContext(ctx, query, aggregateUuid)
+	row := q.QueryRowContext(ctx, query, aggregateUuid)
 
 	var model comby.SnapshotStoreModel
 	if err := row.Scan(
@@ -172,14 +253,24 @@ func (s *snapshotStoreSQLite) GetLatest(ctx context.Context, aggregateUuid strin
 	return &model, nil
 }
 
-func (s *snapshotStoreSQLite) Delete(ctx context.Context, aggregateUuid string) error {
+func (s *snapshotStoreSQLite) Delete(ctx context.Context, aggregateUuid string) (err error) {
+	defer wrapOpError(&err, "delete", "snapshots", time.Now(), func() (string, []string) {
+		return aggregateUuid, nil
+	})
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	return s.delete(ctx, s.db, aggregateUuid)
+}
+
+func (s *snapshotStoreSQLite) delete(ctx context.Context, q queryer, aggregateUuid string) error {
 	query := `DELETE FROM snapshots WHERE aggregate_uuid=?;`
-	_, err := s.db.ExecContext(ctx, query, aggregateUuid)
+	_, err := q.ExecContext(ctx, query, aggregateUuid)
 	return err
 }
 
 func (s *snapshotStoreSQLite) Close(ctx context.Context) error {
-	if s.db != nil {
+	s.keepalive.stop()
+	if s.db != nil && !s.shared {
 		return s.db.Close()
 	}
 	return nil
@@ -0,0 +1,209 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/gradientzero/comby/v3"
+)
+
+// SnapshotReducer folds the events after snapshot (nil for the first one) into
+// a new snapshot. Empty aggregate uuid, tenant, domain, version and creation
+// time are taken from the last event. Returning nil skips the aggregate.
+type SnapshotReducer func(ctx context.Context, snapshot *comby.SnapshotStoreModel, evts []comby.Event) (*comby.SnapshotStoreModel, error)
+
+type AutoSnapshotOption func(*AutoSnapshotter)
+
+// AutoSnapshotEveryEvents snapshots an aggregate once n events were appended
+// after its latest snapshot.
+func AutoSnapshotEveryEvents(n int64) AutoSnapshotOption {
+	return func(a *AutoSnapshotter) { a.everyEvents = n }
+}
+
+// AutoSnapshotPayloadBytes snapshots an aggregate once the payloads appended
+// after its latest snapshot reach n bytes.
+func AutoSnapshotPayloadBytes(n int64) AutoSnapshotOption {
+	return func(a *AutoSnapshotter) { a.payloadBytes = n }
+}
+
+// AutoSnapshotWithPollInterval sets how often Run looks for appended events.
+func AutoSnapshotWithPollInterval(interval time.Duration) AutoSnapshotOption {
+	return func(a *AutoSnapshotter) { a.pollInterval = interval }
+}
+
+// AutoSnapshotter keeps rehydration times bounded by snapshotting aggregates
+// in the background. After events were appended it checks the policies of the
+// affected aggregates and, if one is exceeded, loads the aggregate with
+// LoadAggregate, reduces it and saves the result to the snapshot store.
+type AutoSnapshotter struct {
+	stores       *Stores
+	reducer      SnapshotReducer
+	everyEvents  int64
+	payloadBytes int64
+	pollInterval time.Duration
+	logger       *slog.Logger
+
+	mu sync.Mutex
+	// sequence of the last checked event, kept in memory only
+	offset int64
+}
+
+func NewAutoSnapshotter(stores *Stores, reducer SnapshotReducer, opts ...AutoSnapshotOption) (*AutoSnapshotter, error) {
+	if stores == nil || stores.EventStore == nil || stores.SnapshotStore == nil {
+		return nil, fmt.Errorf("auto snapshotter requires an event and a snapshot store")
+	}
+	es, ok := stores.EventStore.(*eventStoreSQLite)
+	if !ok {
+		return nil, fmt.Errorf("auto snapshotter requires a sqlite event store")
+	}
+	if reducer == nil {
+		return nil, fmt.Errorf("'%s' failed to create auto snapshotter - reducer is nil", stores.String())
+	}
+	a := &AutoSnapshotter{
+		stores:       stores,
+		reducer:      reducer,
+		pollInterval: time.Second,
+		logger:       loggerOrDiscard(es.cfg().Logger),
+	}
+	for _, opt := range opts {
+		opt(a)
+	}
+	if a.everyEvents < 0 || a.payloadBytes < 0 || a.pollInterval <= 0 {
+		return nil, fmt.Errorf("'%s' failed to create auto snapshotter - invalid policy or poll interval", stores.String())
+	}
+	if a.everyEvents == 0 && a.payloadBytes == 0 {
+		return nil, fmt.Errorf("'%s' failed to create auto snapshotter - no policy configured", stores.String())
+	}
+	return a, nil
+}
+
+// Poll checks all aggregates with events appended since the last call and
+// returns the number of saved snapshots. The first call checks all aggregates.
+// If an aggregate fails, the next call checks the same events again.
+func (a *AutoSnapshotter) Poll(ctx context.Context) (int, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	query := `SELECT aggregate_uuid, MAX(id) FROM events WHERE id>? AND aggregate_uuid<>'' GROUP BY aggregate_uuid ORDER BY MAX(id) ASC;`
+	rows, err := a.stores.db.QueryContext(ctx, query, a.offset)
+	if err != nil {
+		return 0, classifyError(err)
+	}
+	var aggregateUuids []string
+	offset := a.offset
+	for rows.Next() {
+		var aggregateUuid string
+		var seq int64
+		if err := rows.Scan(&aggregateUuid, &seq); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		aggregateUuids = append(aggregateUuids, aggregateUuid)
+		offset = max(offset, seq)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, classifyError(err)
+	}
+
+	var saved int
+	var errs []error
+	for _, aggregateUuid := range aggregateUuids {
+		ok, err := a.snapshot(ctx, aggregateUuid)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if ok {
+			saved++
+		}
+	}
+	if len(errs) == 0 {
+		a.offset = offset
+	}
+	return saved, errors.Join(errs...)
+}
+
+// due reports whether the events after version exceed one of the policies.
+func (a *AutoSnapshotter) due(ctx context.Context, aggregateUuid string, version int64) (bool, error) {
+	var count, size int64
+	query := `SELECT COUNT(*), COALESCE(SUM(data_size), 0) FROM events WHERE aggregate_uuid=? AND version>?;`
+	if err := a.stores.db.QueryRowContext(ctx, query, aggregateUuid, version).Scan(&count, &size); err != nil {
+		return false, classifyError(err)
+	}
+	if count == 0 {
+		return false, nil
+	}
+	return (a.everyEvents > 0 && count >= a.everyEvents) || (a.payloadBytes > 0 && size >= a.payloadBytes), nil
+}
+
+func (a *AutoSnapshotter) snapshot(ctx context.Context, aggregateUuid string) (bool, error) {
+	var version int64
+	latest, err := a.stores.SnapshotStore.GetLatest(ctx, aggregateUuid)
+	if err != nil {
+		return false, err
+	}
+	if latest != nil {
+		version = latest.Version
+	}
+	if ok, err := a.due(ctx, aggregateUuid, version); err != nil || !ok {
+		return false, err
+	}
+
+	snapshot, evts, err := a.stores.LoadAggregate(ctx, aggregateUuid)
+	if err != nil || len(evts) == 0 {
+		return false, err
+	}
+	model, err := a.reducer(ctx, snapshot, evts)
+	if err != nil {
+		return false, fmt.Errorf("'%s' failed to reduce aggregate '%s' - %w", a.stores.String(), aggregateUuid, err)
+	}
+	if model == nil {
+		return false, nil
+	}
+	last := evts[len(evts)-1]
+	if len(model.AggregateUuid) == 0 {
+		model.AggregateUuid = aggregateUuid
+	}
+	if len(model.TenantUuid) == 0 {
+		model.TenantUuid = last.GetTenantUuid()
+	}
+	if len(model.Domain) == 0 {
+		model.Domain = last.GetDomain()
+	}
+	if model.Version == 0 {
+		model.Version = last.GetVersion()
+	}
+	if model.CreatedAt == 0 {
+		model.CreatedAt = time.Now().UnixNano()
+	}
+	if err := a.stores.SnapshotStore.Save(ctx, model); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// Run polls until ctx is done, after every poll interval and right after
+// events were written. Failures are logged and retried after the poll
+// interval.
+func (a *AutoSnapshotter) Run(ctx context.Context) error {
+	changes, unsubscribe := a.stores.EventStore.(*eventStoreSQLite).SubscribeChanges()
+	defer unsubscribe()
+	ticker := time.NewTicker(a.pollInterval)
+	defer ticker.Stop()
+	for {
+		if _, err := a.Poll(ctx); err != nil && ctx.Err() == nil {
+			a.logger.ErrorContext(ctx, "auto snapshot failed", "error", err)
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		case <-changes:
+		}
+	}
+}
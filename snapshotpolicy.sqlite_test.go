@@ -0,0 +1,97 @@
+package store_test
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	store "github.com/gradientzero/comby-store-sqlite"
+	"github.com/gradientzero/comby/v3"
+)
+
+func TestAutoSnapshotter(t *testing.T) {
+	ctx := context.Background()
+	stores := openTestStores(t, filepath.Join(t.TempDir(), "autosnapshot.db"), "12345678901234567890123456789012")
+
+	// the snapshot data counts the events folded into it
+	reducer := func(ctx context.Context, snapshot *comby.SnapshotStoreModel, evts []comby.Event) (*comby.SnapshotStoreModel, error) {
+		var folded int
+		if snapshot != nil {
+			fmt.Sscanf(string(snapshot.Data), "%d", &folded)
+		}
+		return &comby.SnapshotStoreModel{Data: []byte(fmt.Sprintf("%d", folded+len(evts)))}, nil
+	}
+	if _, err := store.NewAutoSnapshotter(stores, reducer); err == nil {
+		t.Fatal("expected error without policy")
+	}
+	snapshotter, err := store.NewAutoSnapshotter(stores, reducer, store.AutoSnapshotEveryEvents(3))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	appendEvents := func(aggregateUuid string, from, to int64) {
+		for version := from; version <= to; version++ {
+			evt := createTestEvent("tenant-1", "domain-1", version, version*100)
+			evt.SetAggregateUuid(aggregateUuid)
+			if err := stores.EventStore.Create(ctx, comby.EventStoreCreateOptionWithEvent(evt)); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	appendEvents("aggregate-1", 1, 4)
+	appendEvents("aggregate-2", 1, 2)
+
+	saved, err := snapshotter.Poll(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if saved != 1 {
+		t.Fatalf("expected 1 snapshot, got %d", saved)
+	}
+	snapshot, _ := stores.SnapshotStore.GetLatest(ctx, "aggregate-1")
+	if snapshot == nil || snapshot.Version != 4 || string(snapshot.Data) != "4" || snapshot.TenantUuid != "tenant-1" {
+		t.Fatalf("unexpected snapshot: %+v", snapshot)
+	}
+	if snapshot, _ := stores.SnapshotStore.GetLatest(ctx, "aggregate-2"); snapshot != nil {
+		t.Fatal("expected no snapshot below the policy")
+	}
+
+	// only events after the snapshot count towards the policy
+	appendEvents("aggregate-1", 5, 6)
+	appendEvents("aggregate-2", 3, 3)
+	if saved, err = snapshotter.Poll(ctx); err != nil || saved != 1 {
+		t.Fatalf("expected 1 snapshot, got %d, %v", saved, err)
+	}
+	if snapshot, _ := stores.SnapshotStore.GetLatest(ctx, "aggregate-1"); snapshot.Version != 4 {
+		t.Fatalf("expected snapshot to stay at version 4, got %d", snapshot.Version)
+	}
+	appendEvents("aggregate-1", 7, 7)
+	if saved, err = snapshotter.Poll(ctx); err != nil || saved != 1 {
+		t.Fatalf("expected 1 snapshot, got %d, %v", saved, err)
+	}
+	snapshot, _ = stores.SnapshotStore.GetLatest(ctx, "aggregate-1")
+	if snapshot.Version != 7 || string(snapshot.Data) != "7" {
+		t.Fatalf("unexpected snapshot: %+v", snapshot)
+	}
+}
+
+func TestAutoSnapshotterPayloadBytes(t *testing.T) {
+	ctx := context.Background()
+	stores := openTestStores(t, filepath.Join(t.TempDir(), "autosnapshot.db"), "12345678901234567890123456789012")
+
+	reducer := func(ctx context.Context, snapshot *comby.SnapshotStoreModel, evts []comby.Event) (*comby.SnapshotStoreModel, error) {
+		return &comby.SnapshotStoreModel{Data: []byte("snapshot")}, nil
+	}
+	snapshotter, err := store.NewAutoSnapshotter(stores, reducer, store.AutoSnapshotPayloadBytes(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	evt := createTestEvent("tenant-1", "domain-1", 1, 100)
+	if err := stores.EventStore.Create(ctx, comby.EventStoreCreateOptionWithEvent(evt)); err != nil {
+		t.Fatal(err)
+	}
+	if saved, err := snapshotter.Poll(ctx); err != nil || saved != 1 {
+		t.Fatalf("expected 1 snapshot, got %d, %v", saved, err)
+	}
+}
@@ -0,0 +1,218 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// queryer is implemented by *sql.DB, *sql.Conn and *sql.Tx
+type queryer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// rowScanner is implemented by *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+// countRows returns the number of rows of a table or view, only those of
+// tenantUuid if it is set, 0 on errors.
+func countRows(ctx context.Context, q queryer, table, tenantUuid string) int64 {
+	var total int64
+	var err error
+	if len(tenantUuid) > 0 {
+		err = q.QueryRowContext(ctx, fmt.Sprintf("SELECT COUNT(id) FROM %s WHERE tenant_uuid=?;", table), tenantUuid).Scan(&total)
+	} else {
+		// run query (no args to not using prepared statement)
+		err = q.QueryRowContext(ctx, fmt.Sprintf("SELECT COUNT(id) FROM %s;", table)).Scan(&total)
+	}
+	if err != nil {
+		return 0
+	}
+	return total
+}
+
+// payloadScanner scans rows of a result set with the payload (the first
+// *[]byte destination) read without an intermediate copy and packed into
+// shared blocks, so a page of records needs a few allocations instead of one
+// per payload. A block stays in memory as long as one of its payloads is
+// referenced.
+type payloadScanner struct {
+	rows  *sql.Rows
+	args  []any
+	raw   sql.RawBytes
+	block []byte
+}
+
+// payloads of at least a quarter of this size get their own allocation
+const payloadBlockSize = 64 << 10
+
+func newPayloadScanner(rows *sql.Rows) *payloadScanner {
+	return &payloadScanner{rows: rows}
+}
+
+func (s *payloadScanner) Scan(dest ...any) error {
+	var payload *[]byte
+	s.args = append(s.args[:0], dest...)
+	for i, d := range s.args {
+		if b, ok := d.(*[]byte); ok {
+			payload = b
+			s.args[i] = &s.raw
+			break
+		}
+	}
+	if err := s.rows.Scan(s.args...); err != nil {
+		return err
+	}
+	if payload != nil {
+		// raw is only valid until the next call to Next or Scan
+		*payload = s.pack(s.raw)
+	}
+	return nil
+}
+
+func (s *payloadScanner) pack(raw []byte) []byte {
+	if raw == nil {
+		return nil
+	}
+	if len(raw) >= payloadBlockSize/4 {
+		return append([]byte(nil), raw...)
+	}
+	if len(raw) > cap(s.block)-len(s.block) {
+		s.block = make([]byte, 0, payloadBlockSize)
+	}
+	start := len(s.block)
+	s.block = append(s.block, raw...)
+	// full slice expression: appending to a payload must not overwrite the next one
+	return s.block[start:len(s.block):len(s.block)]
+}
+
+// sharedDB is embedded by all stores. Stores created via Open share one
+// connection pool and one write mutex.
+type sharedDB struct {
+	// serializes writes of this process, so concurrent writers never compete
+	// for the database lock
+	writeMu *sync.Mutex
+	// the pool is owned by Stores and must not be opened or closed by the store
+	shared bool
+}
+
+// hasColumn reports whether a table or view has the given column.
+func hasColumn(ctx context.Context, q queryer, table, column string) (bool, error) {
+	var count int
+	if err := q.QueryRowContext(ctx, `SELECT COUNT(*) FROM pragma_table_info(?) WHERE name=?`, table, column).Scan(&count); err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// strictTable describes a table created as STRICT. Tables created by earlier
+// versions without STRICT are rebuilt and their rows copied using copyColumns,
+// which must select one expression per column (e.g. to replace legacy NULLs).
+type strictTable struct {
+	name        string
+	columns     string
+	copyColumns string
+}
+
+func (t strictTable) create(name string) string {
+	return fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (%s) STRICT;", name, t.columns)
+}
+
+// migrateStrictTables creates missing tables and rebuilds non STRICT ones.
+// Indexes of rebuilt tables are dropped and have to be recreated by the caller,
+// views or triggers referencing them have to be dropped beforehand.
+func migrateStrictTables(ctx context.Context, tx *sql.Tx, logger *slog.Logger, tables ...strictTable) error {
+	for _, table := range tables {
+		var strict int
+		err := tx.QueryRowContext(ctx, `SELECT strict FROM pragma_table_list WHERE schema='main' AND type='table' AND name=?`, table.name).Scan(&strict)
+		switch {
+		case err == sql.ErrNoRows:
+			// created below
+		case err != nil:
+			return err
+		case strict == 0:
+			tmpName := table.name + "_strict"
+			query := fmt.Sprintf(`%s
+			INSERT INTO %s SELECT %s FROM %s;
+			DROP TABLE %s;
+			ALTER TABLE %s RENAME TO %s;`,
+				table.create(tmpName),
+				tmpName, table.copyColumns, table.name,
+				table.name,
+				tmpName, table.name)
+			if _, err := tx.ExecContext(ctx, query); err != nil {
+				return fmt.Errorf("failed to rebuild table '%s' - %w", table.name, err)
+			}
+			logger.InfoContext(ctx, "rebuilt table as strict", "table", table.name)
+		}
+		if _, err := tx.ExecContext(ctx, table.create(table.name)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// migrateTx runs fn in a transaction on a dedicated connection with foreign
+// key enforcement disabled, which is required to rebuild referenced tables.
+func migrateTx(ctx context.Context, db *sql.DB, fn func(tx *sql.Tx) error) error {
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, "PRAGMA foreign_keys=0;"); err != nil {
+		return err
+	}
+	defer conn.ExecContext(context.Background(), "PRAGMA foreign_keys=1;")
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// discardLogger is used by stores without a configured logger
+var discardLogger = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+func loggerOrDiscard(logger *slog.Logger) *slog.Logger {
+	if logger == nil {
+		return discardLogger
+	}
+	return logger
+}
+
+// configurePool applies connection pool limits, zero values keep the defaults.
+func configurePool(db *sql.DB, maxOpenConns, maxIdleConns int, connMaxIdleTime, connMaxLifetime time.Duration) {
+	if maxOpenConns <= 0 {
+		maxOpenConns = 10
+	}
+	db.SetMaxOpenConns(maxOpenConns)
+
+	if maxIdleConns > 0 {
+		db.SetMaxIdleConns(maxIdleConns)
+	}
+
+	if connMaxIdleTime <= 0 {
+		connMaxIdleTime = 5 * time.Minute
+	}
+	db.SetConnMaxIdleTime(connMaxIdleTime)
+
+	if connMaxLifetime > 0 {
+		db.SetConnMaxLifetime(connMaxLifetime)
+	}
+}
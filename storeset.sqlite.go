@@ -0,0 +1,310 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/gradientzero/comby/v3"
+)
+
+// file names of the stores of a StoreSet
+const (
+	storeSetEventsFile    = "events.db"
+	storeSetCommandsFile  = "commands.db"
+	storeSetSnapshotsFile = "snapshots.db"
+)
+
+// StoreSet manages the store files of one comby node in a directory:
+// events.db, commands.db and snapshots.db. Bookkeeping like the webhook
+// outbox or projection checkpoints lives in the file of its store. Unlike
+// Open, every store has its own file and connection pool, so the stores do
+// not wait for each other's writes.
+type StoreSet struct {
+	EventStore    EventStoreSQLite
+	CommandStore  CommandStoreSQLite
+	SnapshotStore comby.SnapshotStore
+
+	dir string
+	es  *eventStoreSQLite
+	cs  *commandStoreSQLite
+	ss  *snapshotStoreSQLite
+}
+
+// StoreFileInfo describes a store file of a StoreSet.
+type StoreFileInfo struct {
+	// "events", "commands" or "snapshots"
+	Store string
+	Path  string
+	// records of the store and created_at of the newest one, unix nano
+	NumItems          int64
+	LastItemCreatedAt int64
+	// bytes of the database file and of its write-ahead log
+	Size    int64
+	WalSize int64
+}
+
+// StoreSetInfo aggregates the Info of the stores of a StoreSet.
+type StoreSetInfo struct {
+	Dir   string
+	Files []StoreFileInfo
+	// sum of Size and WalSize of all files
+	TotalSize int64
+}
+
+// StoreSetBackup reports a backup of a StoreSet.
+type StoreSetBackup struct {
+	Dir   string
+	Files []string
+	// bytes written
+	Size    int64
+	Elapsed time.Duration
+}
+
+// OpenStoreSet creates the stores of the set in dir, which is created if it
+// does not exist. The event, command and snapshot store are opened unless
+// opts request some of them. Options of the stores, the crypto service, key
+// provider, logger, driver and connection settings apply as for Open. The
+// stores must be initialized with Init.
+func OpenStoreSet(dir string, opts ...OpenOption) (*StoreSet, error) {
+	config := openConfig{}
+	for _, opt := range opts {
+		opt(&config)
+	}
+	if config.Analytic {
+		return nil, fmt.Errorf("'sqlite - %s' failed to open store set - analytic stores are not supported", dir)
+	}
+	if !config.EventStore && !config.CommandStore && !config.SnapshotStore {
+		config.EventStore, config.CommandStore, config.SnapshotStore = true, true, true
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("'sqlite - %s' failed to open store set - %w", dir, err)
+	}
+
+	set := &StoreSet{dir: dir}
+	if config.EventStore {
+		es := &eventStoreSQLite{path: filepath.Join(dir, storeSetEventsFile), sharedDB: sharedDB{writeMu: &sync.Mutex{}}}
+		es.options.CryptoService = config.CryptoService
+		es.options.MaxOpenConns = config.MaxOpenConns
+		es.options.MaxIdleConns = config.MaxIdleConns
+		es.options.ConnMaxIdleTime = config.ConnMaxIdleTime
+		es.options.ConnMaxLifetime = config.ConnMaxLifetime
+		es.config.DriverName = config.DriverName
+		es.config.KeyProvider = config.KeyProvider
+		es.config.Logger = config.Logger
+		es.config.Keepalive = config.Keepalive
+		es.Configure(config.EventOpts...)
+		set.es, set.EventStore = es, es
+	}
+	if config.CommandStore {
+		cs := &commandStoreSQLite{path: filepath.Join(dir, storeSetCommandsFile), sharedDB: sharedDB{writeMu: &sync.Mutex{}}}
+		cs.options.CryptoService = config.CryptoService
+		cs.options.MaxOpenConns = config.MaxOpenConns
+		cs.options.MaxIdleConns = config.MaxIdleConns
+		cs.options.ConnMaxIdleTime = config.ConnMaxIdleTime
+		cs.options.ConnMaxLifetime = config.ConnMaxLifetime
+		cs.config.DriverName = config.DriverName
+		cs.config.KeyProvider = config.KeyProvider
+		cs.config.Logger = config.Logger
+		cs.config.Keepalive = config.Keepalive
+		cs.Configure(config.CommandOpts...)
+		set.cs, set.CommandStore = cs, cs
+	}
+	if config.SnapshotStore {
+		ss := &snapshotStoreSQLite{path: filepath.Join(dir, storeSetSnapshotsFile), sharedDB: sharedDB{writeMu: &sync.Mutex{}}}
+		ss.config.DriverName = config.DriverName
+		ss.config.Logger = config.Logger
+		ss.config.MaxOpenConns = config.MaxOpenConns
+		ss.config.MaxIdleConns = config.MaxIdleConns
+		ss.config.ConnMaxIdleTime = config.ConnMaxIdleTime
+		ss.config.ConnMaxLifetime = config.ConnMaxLifetime
+		ss.config.Keepalive = config.Keepalive
+		for _, opt := range config.SnapshotOpts {
+			opt(&ss.config)
+		}
+		set.ss, set.SnapshotStore = ss, ss
+	}
+	return set, nil
+}
+
+// Dir returns the directory of the set.
+func (s *StoreSet) Dir() string {
+	return s.dir
+}
+
+func (s *StoreSet) String() string {
+	return fmt.Sprintf("sqlite - %s", s.dir)
+}
+
+// Init initializes all stores. Stores initialized before a failure are
+// closed again.
+func (s *StoreSet) Init(ctx context.Context) error {
+	var initialized []func(context.Context) error
+	fail := func(err error) error {
+		for _, closeStore := range initialized {
+			closeStore(ctx)
+		}
+		return err
+	}
+	if s.es != nil {
+		if err := s.es.Init(ctx); err != nil {
+			return fail(err)
+		}
+		initialized = append(initialized, s.es.Close)
+	}
+	if s.cs != nil {
+		if err := s.cs.Init(ctx); err != nil {
+			return fail(err)
+		}
+		initialized = append(initialized, s.cs.Close)
+	}
+	if s.ss != nil {
+		if err := s.ss.Init(ctx); err != nil {
+			return fail(err)
+		}
+	}
+	return nil
+}
+
+// Close closes all stores, also if closing one of them fails.
+func (s *StoreSet) Close(ctx context.Context) error {
+	var errs []error
+	if s.es != nil {
+		errs = append(errs, s.es.Close(ctx))
+	}
+	if s.cs != nil {
+		errs = append(errs, s.cs.Close(ctx))
+	}
+	if s.ss != nil {
+		errs = append(errs, s.ss.Close(ctx))
+	}
+	return errors.Join(errs...)
+}
+
+// Reset empties all stores of the set, see ResetAll. The files are kept and
+// the stores stay initialized.
+func (s *StoreSet) Reset(ctx context.Context) error {
+	var stores []any
+	for _, store := range []any{s.es, s.cs, s.ss} {
+		switch st := store.(type) {
+		case *eventStoreSQLite:
+			if st != nil {
+				stores = append(stores, st)
+			}
+		case *commandStoreSQLite:
+			if st != nil {
+				stores = append(stores, st)
+			}
+		case *snapshotStoreSQLite:
+			if st != nil {
+				stores = append(stores, st)
+			}
+		}
+	}
+	return ResetAll(ctx, stores...)
+}
+
+// Info returns the number of records and the file sizes of all stores.
+// Stores bound to a tenant only count its records.
+func (s *StoreSet) Info(ctx context.Context) (*StoreSetInfo, error) {
+	info := &StoreSetInfo{Dir: s.dir}
+	add := func(store, path string, db *sql.DB, boundTenant string) error {
+		if db == nil {
+			return fmt.Errorf("'%s' failed to read info of %s - instance is not initialized", s.String(), store)
+		}
+		whereList, args := tenantCondition(boundTenant, nil, nil)
+		query := fmt.Sprintf(`SELECT COUNT(*), COALESCE(MAX(created_at), 0) FROM %s%s;`, store, whereSQL(whereList))
+		file := StoreFileInfo{Store: store, Path: path}
+		if err := db.QueryRowContext(ctx, query, args...).Scan(&file.NumItems, &file.LastItemCreatedAt); err != nil {
+			return fmt.Errorf("'%s' failed to read info of %s - %w", s.String(), store, classifyError(err))
+		}
+		file.Size, file.WalSize = fileSize(path), walSize(path)
+		info.Files = append(info.Files, file)
+		info.TotalSize += file.Size + file.WalSize
+		return nil
+	}
+	if s.es != nil {
+		if err := add("events", s.es.path, s.es.db, s.es.cfg().Tenant); err != nil {
+			return nil, err
+		}
+	}
+	if s.cs != nil {
+		if err := add("commands", s.cs.path, s.cs.db, s.cs.cfg().Tenant); err != nil {
+			return nil, err
+		}
+	}
+	if s.ss != nil {
+		if err := add("snapshots", s.ss.path, s.ss.db, ""); err != nil {
+			return nil, err
+		}
+	}
+	return info, nil
+}
+
+// fileSize returns the size of the file at path, 0 if it does not exist.
+func fileSize(path string) int64 {
+	stat, err := os.Stat(path)
+	if err != nil {
+		return 0
+	}
+	return stat.Size()
+}
+
+// Backup copies all stores into dir with VACUUM INTO, which is created if it
+// does not exist and must not contain store files yet. Writes of all stores
+// wait while the files are copied, so the backup is consistent across them,
+// e.g. no snapshot is newer than the events it was built from. Writes of other
+// processes are not held back. The backup can be opened with OpenStoreSet.
+func (s *StoreSet) Backup(ctx context.Context, dir string) (*StoreSetBackup, error) {
+	start := time.Now()
+	type source struct {
+		db      *sql.DB
+		writeMu *sync.Mutex
+		file    string
+	}
+	var sources []source
+	if s.es != nil {
+		sources = append(sources, source{s.es.db, s.es.writeMu, storeSetEventsFile})
+	}
+	if s.cs != nil {
+		sources = append(sources, source{s.cs.db, s.cs.writeMu, storeSetCommandsFile})
+	}
+	if s.ss != nil {
+		sources = append(sources, source{s.ss.db, s.ss.writeMu, storeSetSnapshotsFile})
+	}
+	for _, src := range sources {
+		if src.db == nil {
+			return nil, fmt.Errorf("'%s' failed to back up %s - instance is not initialized", s.String(), src.file)
+		}
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("'%s' failed to back up - %w", s.String(), err)
+	}
+	for _, src := range sources {
+		if _, err := os.Stat(filepath.Join(dir, src.file)); err == nil {
+			return nil, fmt.Errorf("'%s' failed to back up - '%s' exists in '%s'", s.String(), src.file, dir)
+		}
+	}
+
+	// locks are always taken in the order of the stores
+	for _, src := range sources {
+		src.writeMu.Lock()
+		defer src.writeMu.Unlock()
+	}
+	backup := &StoreSetBackup{Dir: dir}
+	for _, src := range sources {
+		path := filepath.Join(dir, src.file)
+		if err := vacuumInto(ctx, src.db, path); err != nil {
+			return nil, fmt.Errorf("'%s' failed to back up %s - %w", s.String(), src.file, classifyError(err))
+		}
+		backup.Files = append(backup.Files, path)
+		backup.Size += fileSize(path)
+	}
+	backup.Elapsed = time.Since(start)
+	return backup, nil
+}
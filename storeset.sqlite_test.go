@@ -0,0 +1,173 @@
+package store_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	store "github.com/gradientzero/comby-store-sqlite"
+	"github.com/gradientzero/comby/v3"
+)
+
+func TestStoreSet(t *testing.T) {
+	ctx := context.Background()
+	dir := filepath.Join(t.TempDir(), "node")
+
+	set, err := store.OpenStoreSet(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := set.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer set.Close(ctx)
+	for _, name := range []string{"events.db", "commands.db", "snapshots.db"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			t.Fatalf("expected %s: %v", name, err)
+		}
+	}
+
+	evt := createTestEvent("tenant-1", "domain-1", 1, 100)
+	if err := set.EventStore.Create(ctx, comby.EventStoreCreateOptionWithEvent(evt)); err != nil {
+		t.Fatal(err)
+	}
+	cmd := createTestCommand("tenant-1", "domain-1", 200)
+	if err := set.CommandStore.Create(ctx, comby.CommandStoreCreateOptionWithCommand(cmd)); err != nil {
+		t.Fatal(err)
+	}
+	if err := set.SnapshotStore.Save(ctx, &comby.SnapshotStoreModel{
+		AggregateUuid: evt.GetAggregateUuid(),
+		Domain:        "domain-1",
+		Version:       1,
+		Data:          []byte("snapshot"),
+		CreatedAt:     300,
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := set.Info(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(info.Files) != 3 || info.TotalSize == 0 {
+		t.Fatalf("unexpected info: %+v", info)
+	}
+	for i, want := range []int64{100, 200, 300} {
+		if file := info.Files[i]; file.NumItems != 1 || file.LastItemCreatedAt != want || file.Size == 0 {
+			t.Fatalf("unexpected file info: %+v", file)
+		}
+	}
+
+	// the backup is a store set of its own
+	backupDir := filepath.Join(t.TempDir(), "backup")
+	backup, err := set.Backup(ctx, backupDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(backup.Files) != 3 || backup.Size == 0 {
+		t.Fatalf("unexpected backup: %+v", backup)
+	}
+	if _, err := set.Backup(ctx, backupDir); err == nil {
+		t.Fatal("expected backup into an existing set to fail")
+	}
+	restored, err := store.OpenStoreSet(backupDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := restored.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer restored.Close(ctx)
+	if got, err := restored.EventStore.Get(ctx, comby.EventStoreGetOptionWithEventUuid(evt.GetEventUuid())); err != nil || got == nil {
+		t.Fatalf("expected event in backup: %v", err)
+	}
+	if got, err := restored.CommandStore.Get(ctx, comby.CommandStoreGetOptionWithCommandUuid(cmd.GetCommandUuid())); err != nil || got == nil {
+		t.Fatalf("expected command in backup: %v", err)
+	}
+
+	if err := set.Reset(ctx); err != nil {
+		t.Fatal(err)
+	}
+	info, err = set.Info(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, file := range info.Files {
+		if file.NumItems != 0 {
+			t.Fatalf("expected %s to be empty: %+v", file.Store, file)
+		}
+	}
+	if err := set.Close(ctx); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestStoreSetSelectedStores(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	set, err := store.OpenStoreSet(dir, store.WithEventStore())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := set.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer set.Close(ctx)
+	if set.CommandStore != nil || set.SnapshotStore != nil {
+		t.Fatal("expected only the event store")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "commands.db")); !os.IsNotExist(err) {
+		t.Fatalf("expected no command store file: %v", err)
+	}
+	info, err := set.Info(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(info.Files) != 1 || info.Files[0].Store != "events" {
+		t.Fatalf("unexpected info: %+v", info)
+	}
+}
+
+func TestStoreSetInfoBoundTenant(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	// an event of another tenant is written besides the set
+	other := store.NewEventStoreSQLite(filepath.Join(dir, "events.db"))
+	if err := other.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := other.Create(ctx, comby.EventStoreCreateOptionWithEvent(createTestEvent("tenant-2", "domain-1", 1, 500))); err != nil {
+		t.Fatal(err)
+	}
+	if err := other.Close(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	set, err := store.OpenStoreSet(dir, store.WithEventStore(store.EventStoreSQLiteWithTenant("tenant-1")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := set.Info(ctx); err == nil {
+		t.Fatal("expected info of an uninitialized set to fail")
+	}
+	if _, err := set.Backup(ctx, filepath.Join(t.TempDir(), "backup")); err == nil {
+		t.Fatal("expected backup of an uninitialized set to fail")
+	}
+	if err := set.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer set.Close(ctx)
+	if err := set.EventStore.Create(ctx, comby.EventStoreCreateOptionWithEvent(createTestEvent("tenant-1", "domain-1", 1, 100))); err != nil {
+		t.Fatal(err)
+	}
+	info, err := set.Info(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if file := info.Files[0]; file.NumItems != 1 || file.LastItemCreatedAt != 100 {
+		t.Fatalf("expected only the event of tenant-1, got %+v", file)
+	}
+}
@@ -0,0 +1,476 @@
+package storetest
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"slices"
+	"testing"
+
+	"github.com/gradientzero/comby/v3"
+)
+
+// RunEventStoreConformance runs the behavior every comby event store is
+// expected to show against the stores of newStore: create, get, update and
+// delete, list filters, ordering and paging, and a lossless sync into another
+// store. newStore returns an initialized, empty store and closes it when the
+// test ends. Run it once per configuration, e.g. with encryption enabled, to
+// check that payloads round-trip.
+func RunEventStoreConformance(t *testing.T, newStore func(t *testing.T) comby.EventStore) {
+	t.Run("CreateGet", func(t *testing.T) {
+		ctx := context.Background()
+		eventStore := newStore(t)
+		evts := conformanceEvents()
+		CreateEvents(t, eventStore, evts...)
+		if total := eventStore.Total(ctx); total != int64(len(evts)) {
+			t.Fatalf("expected total %d, got %d", len(evts), total)
+		}
+		for _, evt := range evts {
+			got, err := eventStore.Get(ctx, comby.EventStoreGetOptionWithEventUuid(evt.GetEventUuid()))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := diffEvent(evt, got); len(diff) > 0 {
+				t.Fatalf("event '%s' differs: %s", evt.GetEventUuid(), diff)
+			}
+		}
+		if got, err := eventStore.Get(ctx, comby.EventStoreGetOptionWithEventUuid(comby.NewUuid())); err == nil && got != nil {
+			t.Fatalf("expected no event for an unknown uuid, got %+v", got)
+		}
+	})
+
+	t.Run("UpdateDelete", func(t *testing.T) {
+		ctx := context.Background()
+		eventStore := newStore(t)
+		evts := conformanceEvents()
+		CreateEvents(t, eventStore, evts...)
+
+		updated := evts[0]
+		updated.SetDomainEvtName("Renamed")
+		updated.SetDomainEvtBytes([]byte(`{"updated":true}`))
+		if err := eventStore.Update(ctx, comby.EventStoreUpdateOptionWithEvent(updated)); err != nil {
+			t.Fatal(err)
+		}
+		got, err := eventStore.Get(ctx, comby.EventStoreGetOptionWithEventUuid(updated.GetEventUuid()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if diff := diffEvent(updated, got); len(diff) > 0 {
+			t.Fatalf("updated event differs: %s", diff)
+		}
+
+		if err := eventStore.Delete(ctx, comby.EventStoreDeleteOptionWithEventUuid(evts[1].GetEventUuid())); err != nil {
+			t.Fatal(err)
+		}
+		if got, err := eventStore.Get(ctx, comby.EventStoreGetOptionWithEventUuid(evts[1].GetEventUuid())); err == nil && got != nil {
+			t.Fatal("expected the deleted event to be gone")
+		}
+		if total := eventStore.Total(ctx); total != int64(len(evts)-1) {
+			t.Fatalf("expected total %d after delete, got %d", len(evts)-1, total)
+		}
+	})
+
+	t.Run("Filters", func(t *testing.T) {
+		ctx := context.Background()
+		eventStore := newStore(t)
+		evts := conformanceEvents()
+		CreateEvents(t, eventStore, evts...)
+
+		cases := []struct {
+			name  string
+			set   func(opts *comby.EventStoreListOptions)
+			match func(evt comby.Event) bool
+		}{
+			{"tenant", func(o *comby.EventStoreListOptions) { o.TenantUuid = "tenant-b" },
+				func(e comby.Event) bool { return e.GetTenantUuid() == "tenant-b" }},
+			{"domains", func(o *comby.EventStoreListOptions) { o.Domains = []string{"orders", "billing"} },
+				func(e comby.Event) bool { return e.GetDomain() == "orders" || e.GetDomain() == "billing" }},
+			{"data type", func(o *comby.EventStoreListOptions) { o.DataType = "Shipped" },
+				func(e comby.Event) bool { return e.GetDomainEvtName() == "Shipped" }},
+			{"aggregate", func(o *comby.EventStoreListOptions) { o.AggregateUuid = evts[0].GetAggregateUuid() },
+				func(e comby.Event) bool { return e.GetAggregateUuid() == evts[0].GetAggregateUuid() }},
+			{"time range", func(o *comby.EventStoreListOptions) { o.After, o.Before = 200, 700 },
+				func(e comby.Event) bool { return e.GetCreatedAt() > 200 && e.GetCreatedAt() < 700 }},
+			{"combined", func(o *comby.EventStoreListOptions) { o.TenantUuid, o.Domains = "tenant-a", []string{"orders"} },
+				func(e comby.Event) bool { return e.GetTenantUuid() == "tenant-a" && e.GetDomain() == "orders" }},
+		}
+		for _, c := range cases {
+			got, total, err := eventStore.List(ctx, func(opts *comby.EventStoreListOptions) (*comby.EventStoreListOptions, error) {
+				c.set(opts)
+				return opts, nil
+			})
+			if err != nil {
+				t.Fatalf("%s: %v", c.name, err)
+			}
+			var expected []string
+			for _, evt := range evts {
+				if c.match(evt) {
+					expected = append(expected, evt.GetEventUuid())
+				}
+			}
+			if len(expected) == 0 {
+				t.Fatalf("%s: fixtures match no events", c.name)
+			}
+			if total != int64(len(expected)) || !sameUuids(eventUuids(got), expected) {
+				t.Fatalf("%s: expected %d events, got %d of total %d", c.name, len(expected), len(got), total)
+			}
+		}
+	})
+
+	t.Run("Ordering", func(t *testing.T) {
+		ctx := context.Background()
+		eventStore := newStore(t)
+		evts := conformanceEvents()
+		// created in another order than their creation times
+		slices.Reverse(evts)
+		CreateEvents(t, eventStore, evts...)
+		slices.SortFunc(evts, func(a, b comby.Event) int { return int(a.GetCreatedAt() - b.GetCreatedAt()) })
+		expected := eventUuids(evts)
+
+		got, _, err := eventStore.List(ctx, comby.EventStoreListOptionOrderBy("created_at"), comby.EventStoreListOptionAscending(true))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !slices.Equal(eventUuids(got), expected) {
+			t.Fatal("expected events in ascending creation order")
+		}
+		got, _, err = eventStore.List(ctx, comby.EventStoreListOptionOrderBy("created_at"), comby.EventStoreListOptionAscending(false))
+		if err != nil {
+			t.Fatal(err)
+		}
+		descending := slices.Clone(expected)
+		slices.Reverse(descending)
+		if !slices.Equal(eventUuids(got), descending) {
+			t.Fatal("expected events in descending creation order")
+		}
+
+		page := func(opts *comby.EventStoreListOptions) (*comby.EventStoreListOptions, error) {
+			opts.Offset, opts.Limit = 2, 3
+			return opts, nil
+		}
+		got, total, err := eventStore.List(ctx, page, comby.EventStoreListOptionOrderBy("created_at"), comby.EventStoreListOptionAscending(true))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if total != int64(len(evts)) || !slices.Equal(eventUuids(got), expected[2:5]) {
+			t.Fatalf("unexpected page of %d events and total %d", len(got), total)
+		}
+	})
+
+	t.Run("Sync", func(t *testing.T) {
+		ctx := context.Background()
+		src, dst := newStore(t), newStore(t)
+		evts := conformanceEvents()
+		CreateEvents(t, src, evts...)
+		if err := comby.SyncEventStore(ctx, src, dst); err != nil {
+			t.Fatal(err)
+		}
+		if total := dst.Total(ctx); total != int64(len(evts)) {
+			t.Fatalf("expected %d synced events, got %d", len(evts), total)
+		}
+		for _, evt := range evts {
+			got, err := dst.Get(ctx, comby.EventStoreGetOptionWithEventUuid(evt.GetEventUuid()))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := diffEvent(evt, got); len(diff) > 0 {
+				t.Fatalf("synced event '%s' differs: %s", evt.GetEventUuid(), diff)
+			}
+		}
+	})
+}
+
+// RunCommandStoreConformance runs the behavior every comby command store is
+// expected to show against the stores of newStore, see
+// RunEventStoreConformance.
+func RunCommandStoreConformance(t *testing.T, newStore func(t *testing.T) comby.CommandStore) {
+	t.Run("CreateGet", func(t *testing.T) {
+		ctx := context.Background()
+		commandStore := newStore(t)
+		cmds := conformanceCommands()
+		CreateCommands(t, commandStore, cmds...)
+		if total := commandStore.Total(ctx); total != int64(len(cmds)) {
+			t.Fatalf("expected total %d, got %d", len(cmds), total)
+		}
+		for _, cmd := range cmds {
+			got, err := commandStore.Get(ctx, comby.CommandStoreGetOptionWithCommandUuid(cmd.GetCommandUuid()))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := diffCommand(cmd, got); len(diff) > 0 {
+				t.Fatalf("command '%s' differs: %s", cmd.GetCommandUuid(), diff)
+			}
+		}
+		if got, err := commandStore.Get(ctx, comby.CommandStoreGetOptionWithCommandUuid(comby.NewUuid())); err == nil && got != nil {
+			t.Fatalf("expected no command for an unknown uuid, got %+v", got)
+		}
+	})
+
+	t.Run("UpdateDelete", func(t *testing.T) {
+		ctx := context.Background()
+		commandStore := newStore(t)
+		cmds := conformanceCommands()
+		CreateCommands(t, commandStore, cmds...)
+
+		updated := cmds[0]
+		updated.SetDomainCmdName("Renamed")
+		updated.SetDomainCmdBytes([]byte(`{"updated":true}`))
+		if err := commandStore.Update(ctx, comby.CommandStoreUpdateOptionWithCommand(updated)); err != nil {
+			t.Fatal(err)
+		}
+		got, err := commandStore.Get(ctx, comby.CommandStoreGetOptionWithCommandUuid(updated.GetCommandUuid()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if diff := diffCommand(updated, got); len(diff) > 0 {
+			t.Fatalf("updated command differs: %s", diff)
+		}
+
+		if err := commandStore.Delete(ctx, comby.CommandStoreDeleteOptionWithCommandUuid(cmds[1].GetCommandUuid())); err != nil {
+			t.Fatal(err)
+		}
+		if got, err := commandStore.Get(ctx, comby.CommandStoreGetOptionWithCommandUuid(cmds[1].GetCommandUuid())); err == nil && got != nil {
+			t.Fatal("expected the deleted command to be gone")
+		}
+		if total := commandStore.Total(ctx); total != int64(len(cmds)-1) {
+			t.Fatalf("expected total %d after delete, got %d", len(cmds)-1, total)
+		}
+	})
+
+	t.Run("Filters", func(t *testing.T) {
+		ctx := context.Background()
+		commandStore := newStore(t)
+		cmds := conformanceCommands()
+		CreateCommands(t, commandStore, cmds...)
+
+		cases := []struct {
+			name  string
+			set   func(opts *comby.CommandStoreListOptions)
+			match func(cmd comby.Command) bool
+		}{
+			{"tenant", func(o *comby.CommandStoreListOptions) { o.TenantUuid = "tenant-b" },
+				func(c comby.Command) bool { return c.GetTenantUuid() == "tenant-b" }},
+			{"domain", func(o *comby.CommandStoreListOptions) { o.Domain = "orders" },
+				func(c comby.Command) bool { return c.GetDomain() == "orders" }},
+			{"data type", func(o *comby.CommandStoreListOptions) { o.DataType = "Ship" },
+				func(c comby.Command) bool { return c.GetDomainCmdName() == "Ship" }},
+			{"time range", func(o *comby.CommandStoreListOptions) { o.After, o.Before = 200, 700 },
+				func(c comby.Command) bool { return c.GetCreatedAt() > 200 && c.GetCreatedAt() < 700 }},
+			{"combined", func(o *comby.CommandStoreListOptions) { o.TenantUuid, o.Domain = "tenant-a", "orders" },
+				func(c comby.Command) bool { return c.GetTenantUuid() == "tenant-a" && c.GetDomain() == "orders" }},
+		}
+		for _, c := range cases {
+			got, total, err := commandStore.List(ctx, func(opts *comby.CommandStoreListOptions) (*comby.CommandStoreListOptions, error) {
+				c.set(opts)
+				return opts, nil
+			})
+			if err != nil {
+				t.Fatalf("%s: %v", c.name, err)
+			}
+			var expected []string
+			for _, cmd := range cmds {
+				if c.match(cmd) {
+					expected = append(expected, cmd.GetCommandUuid())
+				}
+			}
+			if len(expected) == 0 {
+				t.Fatalf("%s: fixtures match no commands", c.name)
+			}
+			if total != int64(len(expected)) || !sameUuids(commandUuids(got), expected) {
+				t.Fatalf("%s: expected %d commands, got %d of total %d", c.name, len(expected), len(got), total)
+			}
+		}
+	})
+
+	t.Run("Ordering", func(t *testing.T) {
+		ctx := context.Background()
+		commandStore := newStore(t)
+		cmds := conformanceCommands()
+		slices.Reverse(cmds)
+		CreateCommands(t, commandStore, cmds...)
+		slices.SortFunc(cmds, func(a, b comby.Command) int { return int(a.GetCreatedAt() - b.GetCreatedAt()) })
+		expected := commandUuids(cmds)
+
+		got, _, err := commandStore.List(ctx, comby.CommandStoreListOptionOrderBy("created_at"), comby.CommandStoreListOptionAscending(true))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !slices.Equal(commandUuids(got), expected) {
+			t.Fatal("expected commands in ascending creation order")
+		}
+		got, _, err = commandStore.List(ctx, comby.CommandStoreListOptionOrderBy("created_at"), comby.CommandStoreListOptionAscending(false))
+		if err != nil {
+			t.Fatal(err)
+		}
+		descending := slices.Clone(expected)
+		slices.Reverse(descending)
+		if !slices.Equal(commandUuids(got), descending) {
+			t.Fatal("expected commands in descending creation order")
+		}
+
+		page := func(opts *comby.CommandStoreListOptions) (*comby.CommandStoreListOptions, error) {
+			opts.Offset, opts.Limit = 2, 3
+			return opts, nil
+		}
+		got, total, err := commandStore.List(ctx, page, comby.CommandStoreListOptionOrderBy("created_at"), comby.CommandStoreListOptionAscending(true))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if total != int64(len(cmds)) || !slices.Equal(commandUuids(got), expected[2:5]) {
+			t.Fatalf("unexpected page of %d commands and total %d", len(got), total)
+		}
+	})
+
+	t.Run("Sync", func(t *testing.T) {
+		ctx := context.Background()
+		src, dst := newStore(t), newStore(t)
+		cmds := conformanceCommands()
+		CreateCommands(t, src, cmds...)
+		if err := comby.SyncCommandStore(ctx, src, dst); err != nil {
+			t.Fatal(err)
+		}
+		if total := dst.Total(ctx); total != int64(len(cmds)) {
+			t.Fatalf("expected %d synced commands, got %d", len(cmds), total)
+		}
+		for _, cmd := range cmds {
+			got, err := dst.Get(ctx, comby.CommandStoreGetOptionWithCommandUuid(cmd.GetCommandUuid()))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := diffCommand(cmd, got); len(diff) > 0 {
+				t.Fatalf("synced command '%s' differs: %s", cmd.GetCommandUuid(), diff)
+			}
+		}
+	})
+}
+
+// conformanceEvents returns events of two tenants, three domains and two
+// aggregates, created at 100 to 800, with text and binary payloads.
+func conformanceEvents() []comby.Event {
+	orders, billing := comby.NewUuid(), comby.NewUuid()
+	specs := []struct {
+		tenant, domain, aggregate, dataType string
+		version                             int64
+		data                                []byte
+	}{
+		{"tenant-a", "orders", orders, "Placed", 1, []byte(`{"id":1}`)},
+		{"tenant-a", "orders", orders, "Shipped", 2, []byte{0x00, 0xff, 0xfe, '\'', '"'}},
+		{"tenant-a", "billing", billing, "Invoiced", 1, []byte("ünïcödé")},
+		{"tenant-b", "orders", comby.NewUuid(), "Placed", 1, []byte{0x00}},
+		{"tenant-b", "billing", billing, "Paid", 2, []byte(`{"amount":10}`)},
+		{"tenant-b", "shipping", comby.NewUuid(), "Shipped", 1, []byte("x")},
+		{"tenant-a", "shipping", comby.NewUuid(), "Shipped", 1, bytes.Repeat([]byte("payload"), 100)},
+		{"tenant-b", "orders", comby.NewUuid(), "Cancelled", 1, []byte(`{}`)},
+	}
+	evts := make([]comby.Event, len(specs))
+	for i, spec := range specs {
+		evts[i] = NewEvent(
+			EventTenant(spec.tenant),
+			EventDomain(spec.domain),
+			EventAggregate(spec.aggregate, spec.version),
+			EventData(spec.dataType, spec.data),
+			EventCreatedAt(int64(i+1)*100),
+		)
+		evts[i].SetWorkspaceUuid(fmt.Sprintf("workspace-%d", i%2))
+	}
+	return evts
+}
+
+// conformanceCommands returns commands like conformanceEvents.
+func conformanceCommands() []comby.Command {
+	specs := []struct {
+		tenant, domain, dataType string
+		data                     []byte
+	}{
+		{"tenant-a", "orders", "Place", []byte(`{"id":1}`)},
+		{"tenant-a", "orders", "Ship", []byte{0x00, 0xff, 0xfe, '\'', '"'}},
+		{"tenant-a", "billing", "Invoice", []byte("ünïcödé")},
+		{"tenant-b", "orders", "Place", []byte{0x00}},
+		{"tenant-b", "billing", "Pay", []byte(`{"amount":10}`)},
+		{"tenant-b", "shipping", "Ship", []byte("x")},
+		{"tenant-a", "shipping", "Ship", bytes.Repeat([]byte("payload"), 100)},
+		{"tenant-b", "orders", "Cancel", []byte(`{}`)},
+	}
+	cmds := make([]comby.Command, len(specs))
+	for i, spec := range specs {
+		cmds[i] = NewCommand(
+			CommandTenant(spec.tenant),
+			CommandDomain(spec.domain),
+			CommandData(spec.dataType, spec.data),
+			CommandCreatedAt(int64(i+1)*100),
+		)
+		cmds[i].SetWorkspaceUuid(fmt.Sprintf("workspace-%d", i%2))
+	}
+	return cmds
+}
+
+// diffEvent lists the fields of got which differ from want.
+func diffEvent(want, got comby.Event) []string {
+	if got == nil {
+		return []string{"event is missing"}
+	}
+	var diff []string
+	check := func(field string, equal bool, want, got any) {
+		if !equal {
+			diff = append(diff, fmt.Sprintf("%s is %v, expected %v", field, got, want))
+		}
+	}
+	check("instance id", want.GetInstanceId() == got.GetInstanceId(), want.GetInstanceId(), got.GetInstanceId())
+	check("uuid", want.GetEventUuid() == got.GetEventUuid(), want.GetEventUuid(), got.GetEventUuid())
+	check("tenant", want.GetTenantUuid() == got.GetTenantUuid(), want.GetTenantUuid(), got.GetTenantUuid())
+	check("workspace", want.GetWorkspaceUuid() == got.GetWorkspaceUuid(), want.GetWorkspaceUuid(), got.GetWorkspaceUuid())
+	check("command", want.GetCommandUuid() == got.GetCommandUuid(), want.GetCommandUuid(), got.GetCommandUuid())
+	check("domain", want.GetDomain() == got.GetDomain(), want.GetDomain(), got.GetDomain())
+	check("aggregate", want.GetAggregateUuid() == got.GetAggregateUuid(), want.GetAggregateUuid(), got.GetAggregateUuid())
+	check("version", want.GetVersion() == got.GetVersion(), want.GetVersion(), got.GetVersion())
+	check("created at", want.GetCreatedAt() == got.GetCreatedAt(), want.GetCreatedAt(), got.GetCreatedAt())
+	check("data type", want.GetDomainEvtName() == got.GetDomainEvtName(), want.GetDomainEvtName(), got.GetDomainEvtName())
+	check("data", bytes.Equal(want.GetDomainEvtBytes(), got.GetDomainEvtBytes()), want.GetDomainEvtBytes(), got.GetDomainEvtBytes())
+	return diff
+}
+
+// diffCommand lists the fields of got which differ from want.
+func diffCommand(want, got comby.Command) []string {
+	if got == nil {
+		return []string{"command is missing"}
+	}
+	var diff []string
+	check := func(field string, equal bool, want, got any) {
+		if !equal {
+			diff = append(diff, fmt.Sprintf("%s is %v, expected %v", field, got, want))
+		}
+	}
+	check("instance id", want.GetInstanceId() == got.GetInstanceId(), want.GetInstanceId(), got.GetInstanceId())
+	check("uuid", want.GetCommandUuid() == got.GetCommandUuid(), want.GetCommandUuid(), got.GetCommandUuid())
+	check("tenant", want.GetTenantUuid() == got.GetTenantUuid(), want.GetTenantUuid(), got.GetTenantUuid())
+	check("workspace", want.GetWorkspaceUuid() == got.GetWorkspaceUuid(), want.GetWorkspaceUuid(), got.GetWorkspaceUuid())
+	check("domain", want.GetDomain() == got.GetDomain(), want.GetDomain(), got.GetDomain())
+	check("created at", want.GetCreatedAt() == got.GetCreatedAt(), want.GetCreatedAt(), got.GetCreatedAt())
+	check("data type", want.GetDomainCmdName() == got.GetDomainCmdName(), want.GetDomainCmdName(), got.GetDomainCmdName())
+	check("data", bytes.Equal(want.GetDomainCmdBytes(), got.GetDomainCmdBytes()), want.GetDomainCmdBytes(), got.GetDomainCmdBytes())
+	return diff
+}
+
+func eventUuids(evts []comby.Event) []string {
+	uuids := make([]string, len(evts))
+	for i, evt := range evts {
+		uuids[i] = evt.GetEventUuid()
+	}
+	return uuids
+}
+
+func commandUuids(cmds []comby.Command) []string {
+	uuids := make([]string, len(cmds))
+	for i, cmd := range cmds {
+		uuids[i] = cmd.GetCommandUuid()
+	}
+	return uuids
+}
+
+// sameUuids reports whether a and b hold the same uuids in any order.
+func sameUuids(a, b []string) bool {
+	a, b = slices.Clone(a), slices.Clone(b)
+	slices.Sort(a)
+	slices.Sort(b)
+	return slices.Equal(a, b)
+}
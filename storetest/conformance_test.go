@@ -0,0 +1,67 @@
+package storetest_test
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	store "github.com/gradientzero/comby-store-sqlite"
+	"github.com/gradientzero/comby-store-sqlite/storetest"
+	"github.com/gradientzero/comby/v3"
+)
+
+func TestEventStoreConformance(t *testing.T) {
+	cryptoService, _ := comby.NewCryptoService([]byte("12345678901234567890123456789012"))
+	configs := map[string][]store.EventStoreSQLiteOption{
+		"plain":        nil,
+		"key provider": {store.EventStoreSQLiteWithKeyProvider(store.NewStaticKeyProvider("key-1", []byte("12345678901234567890123456789012")))},
+		"cache":        {store.EventStoreSQLiteWithCache(100, time.Minute)},
+	}
+	for name, opts := range configs {
+		t.Run(name, func(t *testing.T) {
+			storetest.RunEventStoreConformance(t, func(t *testing.T) comby.EventStore {
+				return storetest.NewTempEventStore(t, opts...)
+			})
+		})
+	}
+	t.Run("encrypted", func(t *testing.T) {
+		storetest.RunEventStoreConformance(t, func(t *testing.T) comby.EventStore {
+			eventStore := store.NewEventStoreSQLite(filepath.Join(t.TempDir(), "events.db"), comby.EventStoreOptionWithCryptoService(cryptoService))
+			if err := eventStore.Init(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+			t.Cleanup(func() { eventStore.Close(context.Background()) })
+			return eventStore
+		})
+	})
+	t.Run("memory", func(t *testing.T) {
+		storetest.RunEventStoreConformance(t, func(t *testing.T) comby.EventStore {
+			return storetest.NewMemoryEventStore(t)
+		})
+	})
+}
+
+func TestCommandStoreConformance(t *testing.T) {
+	cryptoService, _ := comby.NewCryptoService([]byte("12345678901234567890123456789012"))
+	t.Run("plain", func(t *testing.T) {
+		storetest.RunCommandStoreConformance(t, func(t *testing.T) comby.CommandStore {
+			return storetest.NewTempCommandStore(t)
+		})
+	})
+	t.Run("encrypted", func(t *testing.T) {
+		storetest.RunCommandStoreConformance(t, func(t *testing.T) comby.CommandStore {
+			commandStore := store.NewCommandStoreSQLite(filepath.Join(t.TempDir(), "commands.db"), comby.CommandStoreOptionWithCryptoService(cryptoService))
+			if err := commandStore.Init(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+			t.Cleanup(func() { commandStore.Close(context.Background()) })
+			return commandStore
+		})
+	})
+	t.Run("memory", func(t *testing.T) {
+		storetest.RunCommandStoreConformance(t, func(t *testing.T) comby.CommandStore {
+			return storetest.NewMemoryCommandStore(t)
+		})
+	})
+}
@@ -0,0 +1,209 @@
+package storetest
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	store "github.com/gradientzero/comby-store-sqlite"
+)
+
+// FaultError is an injected SQLite error. The stores classify it by its
+// result code like errors of the SQLite driver, e.g. as store.ErrLocked.
+type FaultError struct {
+	code int
+	msg  string
+}
+
+// NewFaultError returns an error with the given SQLite result code.
+func NewFaultError(code int, msg string) *FaultError {
+	return &FaultError{code: code, msg: msg}
+}
+
+func (e *FaultError) Error() string { return fmt.Sprintf("%s (%d, injected)", e.msg, e.code) }
+
+func (e *FaultError) Code() int { return e.code }
+
+// Injected SQLite errors, SQLITE_BUSY, SQLITE_FULL and SQLITE_IOERR.
+var (
+	ErrBusy     = NewFaultError(5, "database is locked")
+	ErrDiskFull = NewFaultError(13, "database or disk is full")
+	ErrIO       = NewFaultError(10, "disk I/O error")
+)
+
+// fault fails matching statements, or commits, a number of times.
+type fault struct {
+	match  string
+	commit bool
+	err    error
+	// remaining failures, negative until cleared
+	remaining int
+}
+
+// FaultDriver is a database/sql driver wrapping the default SQLite driver of
+// the stores, which fails statements and commits on demand. Stores use it with
+// store.EventStoreSQLiteWithDriverName(driver.Name()):
+//
+//	faults := storetest.NewFaultDriver()
+//	eventStore := storetest.NewTempEventStore(t, store.EventStoreSQLiteWithDriverName(faults.Name()))
+//	faults.Fail("INSERT INTO events", storetest.ErrBusy, 1)
+//
+// Statements run by Init, like migrations, fail as well if they match.
+type FaultDriver struct {
+	name   string
+	sqlite driver.Driver
+
+	mu       sync.Mutex
+	faults   []*fault
+	injected int
+}
+
+var numFaultDrivers atomic.Int64
+
+// NewFaultDriver registers a new fault driver under a unique name. Drivers can
+// not be unregistered, so tests should create one per test at most.
+func NewFaultDriver() *FaultDriver {
+	db, _ := sql.Open(store.DefaultDriverName(), "")
+	defer db.Close()
+	d := &FaultDriver{
+		name:   fmt.Sprintf("sqlite-fault-%d", numFaultDrivers.Add(1)),
+		sqlite: db.Driver(),
+	}
+	sql.Register(d.name, d)
+	return d
+}
+
+// Name returns the name the driver is registered as.
+func (d *FaultDriver) Name() string {
+	return d.name
+}
+
+// Fail makes the next times statements containing match fail with err, all
+// of them until Clear if times is negative. An empty match fails every
+// statement.
+func (d *FaultDriver) Fail(match string, err error, times int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.faults = append(d.faults, &fault{match: match, err: err, remaining: times})
+}
+
+// FailCommit makes the next times commits fail with err after the statements
+// of the transaction ran, the transaction is rolled back.
+func (d *FaultDriver) FailCommit(err error, times int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.faults = append(d.faults, &fault{commit: true, err: err, remaining: times})
+}
+
+// Clear removes all faults.
+func (d *FaultDriver) Clear() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.faults = nil
+}
+
+// Injected returns the number of failures injected so far.
+func (d *FaultDriver) Injected() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.injected
+}
+
+// check returns the error of the first fault matching a statement or commit.
+func (d *FaultDriver) check(query string, commit bool) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, f := range d.faults {
+		if f.remaining == 0 || f.commit != commit || !strings.Contains(query, f.match) {
+			continue
+		}
+		f.remaining--
+		d.injected++
+		return f.err
+	}
+	return nil
+}
+
+func (d *FaultDriver) Open(name string) (driver.Conn, error) {
+	c, err := d.sqlite.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return &faultConn{conn: c, driver: d}, nil
+}
+
+// faultConn checks statements before passing them to the SQLite connection,
+// which implements all optional interfaces used below with both drivers.
+type faultConn struct {
+	conn   driver.Conn
+	driver *FaultDriver
+}
+
+func (c *faultConn) Prepare(query string) (driver.Stmt, error) {
+	return c.PrepareContext(context.Background(), query)
+}
+
+func (c *faultConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	if err := c.driver.check(query, false); err != nil {
+		return nil, err
+	}
+	return c.conn.(driver.ConnPrepareContext).PrepareContext(ctx, query)
+}
+
+func (c *faultConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	if err := c.driver.check(query, false); err != nil {
+		return nil, err
+	}
+	return c.conn.(driver.ExecerContext).ExecContext(ctx, query, args)
+}
+
+func (c *faultConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	if err := c.driver.check(query, false); err != nil {
+		return nil, err
+	}
+	return c.conn.(driver.QueryerContext).QueryContext(ctx, query, args)
+}
+
+func (c *faultConn) Begin() (driver.Tx, error) {
+	return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+func (c *faultConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if err := c.driver.check("BEGIN", false); err != nil {
+		return nil, err
+	}
+	tx, err := c.conn.(driver.ConnBeginTx).BeginTx(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	return &faultTx{tx: tx, driver: c.driver}, nil
+}
+
+func (c *faultConn) Ping(ctx context.Context) error {
+	return c.conn.(driver.Pinger).Ping(ctx)
+}
+
+func (c *faultConn) Close() error {
+	return c.conn.Close()
+}
+
+type faultTx struct {
+	tx     driver.Tx
+	driver *FaultDriver
+}
+
+func (t *faultTx) Commit() error {
+	if err := t.driver.check("", true); err != nil {
+		t.tx.Rollback()
+		return err
+	}
+	return t.tx.Commit()
+}
+
+func (t *faultTx) Rollback() error {
+	return t.tx.Rollback()
+}
@@ -0,0 +1,78 @@
+package storetest_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	store "github.com/gradientzero/comby-store-sqlite"
+	"github.com/gradientzero/comby-store-sqlite/storetest"
+	"github.com/gradientzero/comby/v3"
+)
+
+func TestFaultDriver(t *testing.T) {
+	ctx := context.Background()
+	faults := storetest.NewFaultDriver()
+	eventStore := storetest.NewTempEventStore(t, store.EventStoreSQLiteWithDriverName(faults.Name()))
+
+	// busy statements are classified and pass on retry
+	faults.Fail("INSERT INTO events", storetest.ErrBusy, 1)
+	evt := storetest.NewEvent()
+	err := eventStore.Create(ctx, comby.EventStoreCreateOptionWithEvent(evt))
+	if !errors.Is(err, store.ErrLocked) || !errors.Is(err, storetest.ErrBusy) {
+		t.Fatalf("expected locked error, got %v", err)
+	}
+	if err := eventStore.Create(ctx, comby.EventStoreCreateOptionWithEvent(evt)); err != nil {
+		t.Fatal(err)
+	}
+
+	// failed commits roll back
+	faults.FailCommit(storetest.ErrDiskFull, 1)
+	if err := eventStore.Create(ctx, comby.EventStoreCreateOptionWithEvent(storetest.NewEvent())); !errors.Is(err, store.ErrDiskFull) {
+		t.Fatalf("expected disk full error, got %v", err)
+	}
+
+	// a failure in the middle of a transaction rolls back its earlier writes
+	err = eventStore.WithTx(ctx, func(tx store.EventStoreTx) error {
+		if err := tx.Create(ctx, comby.EventStoreCreateOptionWithEvent(storetest.NewEvent())); err != nil {
+			return err
+		}
+		faults.Fail("INSERT INTO events", storetest.ErrIO, 1)
+		return tx.Create(ctx, comby.EventStoreCreateOptionWithEvent(storetest.NewEvent()))
+	})
+	if !errors.Is(err, storetest.ErrIO) {
+		t.Fatalf("expected I/O error, got %v", err)
+	}
+	if total := eventStore.Total(ctx); total != 1 {
+		t.Fatalf("expected 1 event, got %d", total)
+	}
+	if injected := faults.Injected(); injected != 3 {
+		t.Fatalf("expected 3 injected failures, got %d", injected)
+	}
+
+	// reads fail until cleared
+	faults.Fail("FROM events", storetest.ErrIO, -1)
+	for i := 0; i < 2; i++ {
+		if _, _, err := eventStore.List(ctx); !errors.Is(err, storetest.ErrIO) {
+			t.Fatalf("expected I/O error, got %v", err)
+		}
+	}
+	faults.Clear()
+	if _, _, err := eventStore.List(ctx); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFaultDriverCommandStore(t *testing.T) {
+	ctx := context.Background()
+	faults := storetest.NewFaultDriver()
+	commandStore := storetest.NewTempCommandStore(t, store.CommandStoreSQLiteWithDriverName(faults.Name()))
+
+	faults.Fail("", storetest.ErrDiskFull, 1)
+	if err := commandStore.Create(ctx, comby.CommandStoreCreateOptionWithCommand(storetest.NewCommand())); !errors.Is(err, store.ErrDiskFull) {
+		t.Fatalf("expected disk full error, got %v", err)
+	}
+	if total := commandStore.Total(ctx); total != 0 {
+		t.Fatalf("expected no commands, got %d", total)
+	}
+}
@@ -0,0 +1,139 @@
+package storetest
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/gradientzero/comby/v3"
+)
+
+// EventFixture changes an event built by NewEvent.
+type EventFixture func(evt comby.Event)
+
+// EventTenant sets the tenant of the event.
+func EventTenant(tenantUuid string) EventFixture {
+	return func(evt comby.Event) { evt.SetTenantUuid(tenantUuid) }
+}
+
+// EventDomain sets the domain of the event.
+func EventDomain(domain string) EventFixture {
+	return func(evt comby.Event) { evt.SetDomain(domain) }
+}
+
+// EventAggregate sets the aggregate and version of the event.
+func EventAggregate(aggregateUuid string, version int64) EventFixture {
+	return func(evt comby.Event) {
+		evt.SetAggregateUuid(aggregateUuid)
+		evt.SetVersion(version)
+	}
+}
+
+// EventCreatedAt sets the creation time of the event, unix nano.
+func EventCreatedAt(createdAt int64) EventFixture {
+	return func(evt comby.Event) { evt.SetCreatedAt(createdAt) }
+}
+
+// EventData sets the data type and payload of the event.
+func EventData(dataType string, data []byte) EventFixture {
+	return func(evt comby.Event) {
+		evt.SetDomainEvtName(dataType)
+		evt.SetDomainEvtBytes(data)
+	}
+}
+
+// NewEvent returns an event of "tenant-1" in "domain-1", version 1 of a new
+// aggregate with a small payload, changed by fixtures.
+func NewEvent(fixtures ...EventFixture) comby.Event {
+	evt := comby.NewBaseEvent()
+	evt.SetInstanceId(1)
+	evt.SetTenantUuid("tenant-1")
+	evt.SetCommandUuid(comby.NewUuid())
+	evt.SetDomain("domain-1")
+	evt.SetAggregateUuid(comby.NewUuid())
+	evt.SetVersion(1)
+	evt.SetDomainEvtName("TestEvent")
+	evt.SetDomainEvtBytes([]byte(fmt.Sprintf("test-data-%s", evt.GetEventUuid())))
+	for _, fixture := range fixtures {
+		fixture(evt)
+	}
+	return evt
+}
+
+// NewAggregateEvents returns versions 1 to n of one new aggregate, created
+// one millisecond apart. Fixtures apply to every event, before the version
+// and creation time are set.
+func NewAggregateEvents(n int, fixtures ...EventFixture) []comby.Event {
+	aggregateUuid := comby.NewUuid()
+	createdAt := time.Now().UnixNano()
+	evts := make([]comby.Event, n)
+	for i := range evts {
+		evts[i] = NewEvent(fixtures...)
+		evts[i].SetAggregateUuid(aggregateUuid)
+		evts[i].SetVersion(int64(i + 1))
+		evts[i].SetCreatedAt(createdAt + int64(i)*int64(time.Millisecond))
+	}
+	return evts
+}
+
+// CreateEvents writes evts to eventStore and fails the test on error.
+func CreateEvents(t testing.TB, eventStore comby.EventStore, evts ...comby.Event) {
+	t.Helper()
+	for _, evt := range evts {
+		if err := eventStore.Create(context.Background(), comby.EventStoreCreateOptionWithEvent(evt)); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// CommandFixture changes a command built by NewCommand.
+type CommandFixture func(cmd comby.Command)
+
+// CommandTenant sets the tenant of the command.
+func CommandTenant(tenantUuid string) CommandFixture {
+	return func(cmd comby.Command) { cmd.SetTenantUuid(tenantUuid) }
+}
+
+// CommandDomain sets the domain of the command.
+func CommandDomain(domain string) CommandFixture {
+	return func(cmd comby.Command) { cmd.SetDomain(domain) }
+}
+
+// CommandCreatedAt sets the creation time of the command, unix nano.
+func CommandCreatedAt(createdAt int64) CommandFixture {
+	return func(cmd comby.Command) { cmd.SetCreatedAt(createdAt) }
+}
+
+// CommandData sets the data type and payload of the command.
+func CommandData(dataType string, data []byte) CommandFixture {
+	return func(cmd comby.Command) {
+		cmd.SetDomainCmdName(dataType)
+		cmd.SetDomainCmdBytes(data)
+	}
+}
+
+// NewCommand returns a command of "tenant-1" in "domain-1" with a small
+// payload, changed by fixtures.
+func NewCommand(fixtures ...CommandFixture) comby.Command {
+	cmd := comby.NewBaseCommand()
+	cmd.SetInstanceId(1)
+	cmd.SetTenantUuid("tenant-1")
+	cmd.SetDomain("domain-1")
+	cmd.SetDomainCmdName("TestCommand")
+	cmd.SetDomainCmdBytes([]byte(fmt.Sprintf("test-data-%s", cmd.GetCommandUuid())))
+	for _, fixture := range fixtures {
+		fixture(cmd)
+	}
+	return cmd
+}
+
+// CreateCommands writes cmds to commandStore and fails the test on error.
+func CreateCommands(t testing.TB, commandStore comby.CommandStore, cmds ...comby.Command) {
+	t.Helper()
+	for _, cmd := range cmds {
+		if err := commandStore.Create(context.Background(), comby.CommandStoreCreateOptionWithCommand(cmd)); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,308 @@
+package storetest
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/gradientzero/comby/v3"
+)
+
+// GoldenUpdateEnv is the environment variable which makes AssertGolden write
+// the golden files instead of comparing them, e.g.
+// STORETEST_UPDATE_GOLDEN=1 go test ./...
+const GoldenUpdateEnv = "STORETEST_UPDATE_GOLDEN"
+
+// goldenEvent fixes the field order of events in golden files.
+type goldenEvent struct {
+	InstanceId    int64           `json:"instance_id"`
+	Uuid          string          `json:"uuid"`
+	TenantUuid    string          `json:"tenant_uuid"`
+	WorkspaceUuid string          `json:"workspace_uuid"`
+	CommandUuid   string          `json:"command_uuid"`
+	Domain        string          `json:"domain"`
+	AggregateUuid string          `json:"aggregate_uuid"`
+	Version       int64           `json:"version"`
+	CreatedAt     int64           `json:"created_at"`
+	DataType      string          `json:"data_type"`
+	Data          json.RawMessage `json:"data"`
+	ReqCtx        json.RawMessage `json:"req_ctx"`
+}
+
+// goldenCommand fixes the field order of commands in golden files.
+type goldenCommand struct {
+	InstanceId    int64           `json:"instance_id"`
+	Uuid          string          `json:"uuid"`
+	TenantUuid    string          `json:"tenant_uuid"`
+	WorkspaceUuid string          `json:"workspace_uuid"`
+	Domain        string          `json:"domain"`
+	CreatedAt     int64           `json:"created_at"`
+	DataType      string          `json:"data_type"`
+	Data          json.RawMessage `json:"data"`
+	ReqCtx        json.RawMessage `json:"req_ctx"`
+}
+
+// CanonicalEventJSON renders events as indented JSON with a stable field
+// order for golden files. JSON payloads are embedded with sorted keys, other
+// payloads as a string prefixed with "base64:", a missing payload is null.
+func CanonicalEventJSON(evts ...comby.Event) ([]byte, error) {
+	records := make([]goldenEvent, len(evts))
+	for i, evt := range evts {
+		data, err := canonicalPayload(evt.GetDomainEvtBytes())
+		if err != nil {
+			return nil, fmt.Errorf("event '%s': %w", evt.GetEventUuid(), err)
+		}
+		reqCtx, err := canonicalReqCtx(evt.GetReqCtx())
+		if err != nil {
+			return nil, fmt.Errorf("event '%s': %w", evt.GetEventUuid(), err)
+		}
+		records[i] = goldenEvent{
+			InstanceId:    evt.GetInstanceId(),
+			Uuid:          evt.GetEventUuid(),
+			TenantUuid:    evt.GetTenantUuid(),
+			WorkspaceUuid: evt.GetWorkspaceUuid(),
+			CommandUuid:   evt.GetCommandUuid(),
+			Domain:        evt.GetDomain(),
+			AggregateUuid: evt.GetAggregateUuid(),
+			Version:       evt.GetVersion(),
+			CreatedAt:     evt.GetCreatedAt(),
+			DataType:      evt.GetDomainEvtName(),
+			Data:          data,
+			ReqCtx:        reqCtx,
+		}
+	}
+	return marshalGolden(records)
+}
+
+// CanonicalCommandJSON renders commands like CanonicalEventJSON.
+func CanonicalCommandJSON(cmds ...comby.Command) ([]byte, error) {
+	records := make([]goldenCommand, len(cmds))
+	for i, cmd := range cmds {
+		data, err := canonicalPayload(cmd.GetDomainCmdBytes())
+		if err != nil {
+			return nil, fmt.Errorf("command '%s': %w", cmd.GetCommandUuid(), err)
+		}
+		reqCtx, err := canonicalReqCtx(cmd.GetReqCtx())
+		if err != nil {
+			return nil, fmt.Errorf("command '%s': %w", cmd.GetCommandUuid(), err)
+		}
+		records[i] = goldenCommand{
+			InstanceId:    cmd.GetInstanceId(),
+			Uuid:          cmd.GetCommandUuid(),
+			TenantUuid:    cmd.GetTenantUuid(),
+			WorkspaceUuid: cmd.GetWorkspaceUuid(),
+			Domain:        cmd.GetDomain(),
+			CreatedAt:     cmd.GetCreatedAt(),
+			DataType:      cmd.GetDomainCmdName(),
+			Data:          data,
+			ReqCtx:        reqCtx,
+		}
+	}
+	return marshalGolden(records)
+}
+
+func marshalGolden(records any) ([]byte, error) {
+	data, err := json.MarshalIndent(records, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
+
+// canonicalPayload returns data as JSON with sorted keys.
+func canonicalPayload(data []byte) (json.RawMessage, error) {
+	if len(data) == 0 {
+		return json.RawMessage("null"), nil
+	}
+	value, err := decodeGolden(data)
+	if err != nil {
+		return json.Marshal("base64:" + base64.StdEncoding.EncodeToString(data))
+	}
+	return json.Marshal(value)
+}
+
+func canonicalReqCtx(reqCtx *comby.RequestContext) (json.RawMessage, error) {
+	if reqCtx == nil {
+		return json.RawMessage("null"), nil
+	}
+	data, err := json.Marshal(reqCtx)
+	if err != nil {
+		return nil, err
+	}
+	return canonicalPayload(data)
+}
+
+// decodeGolden decodes JSON keeping numbers as written.
+func decodeGolden(data []byte) (any, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var value any
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+	if decoder.More() {
+		return nil, fmt.Errorf("trailing data after JSON value")
+	}
+	return value, nil
+}
+
+// CompareGolden compares two documents of CanonicalEventJSON or
+// CanonicalCommandJSON and describes each difference, e.g.
+// "$[0].data.name is "b", expected "a"". Volatile fields like timestamps are
+// ignored; they are dot separated paths within a record, e.g. "created_at" or
+// "data.updatedAt", where "*" matches any key or array index.
+func CompareGolden(want, got []byte, volatile ...string) ([]string, error) {
+	wantValue, err := decodeGolden(want)
+	if err != nil {
+		return nil, fmt.Errorf("expected document is invalid: %w", err)
+	}
+	gotValue, err := decodeGolden(got)
+	if err != nil {
+		return nil, fmt.Errorf("document is invalid: %w", err)
+	}
+	patterns := make([][]string, len(volatile))
+	for i, path := range volatile {
+		// paths are relative to the records of the document
+		patterns[i] = append([]string{"*"}, strings.Split(path, ".")...)
+	}
+	var diff []string
+	compareGolden(wantValue, gotValue, nil, patterns, &diff)
+	return diff, nil
+}
+
+func compareGolden(want, got any, path []string, volatile [][]string, diff *[]string) {
+	if isVolatile(path, volatile) {
+		return
+	}
+	location := "$" + formatGoldenPath(path)
+	switch w := want.(type) {
+	case map[string]any:
+		g, ok := got.(map[string]any)
+		if !ok {
+			*diff = append(*diff, fmt.Sprintf("%s is %s, expected %s", location, goldenString(got), goldenString(want)))
+			return
+		}
+		keys := make([]string, 0, len(w)+len(g))
+		for key := range w {
+			keys = append(keys, key)
+		}
+		for key := range g {
+			if _, ok := w[key]; !ok {
+				keys = append(keys, key)
+			}
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			wantField, inWant := w[key]
+			gotField, inGot := g[key]
+			child := append(slices.Clone(path), key)
+			switch {
+			case !inGot:
+				if !isVolatile(child, volatile) {
+					*diff = append(*diff, fmt.Sprintf("$%s is missing", formatGoldenPath(child)))
+				}
+			case !inWant:
+				if !isVolatile(child, volatile) {
+					*diff = append(*diff, fmt.Sprintf("$%s is unexpected", formatGoldenPath(child)))
+				}
+			default:
+				compareGolden(wantField, gotField, child, volatile, diff)
+			}
+		}
+	case []any:
+		g, ok := got.([]any)
+		if !ok {
+			*diff = append(*diff, fmt.Sprintf("%s is %s, expected %s", location, goldenString(got), goldenString(want)))
+			return
+		}
+		if len(w) != len(g) {
+			*diff = append(*diff, fmt.Sprintf("%s has %d elements, expected %d", location, len(g), len(w)))
+		}
+		for i := 0; i < min(len(w), len(g)); i++ {
+			compareGolden(w[i], g[i], append(slices.Clone(path), fmt.Sprint(i)), volatile, diff)
+		}
+	default:
+		if goldenString(want) != goldenString(got) {
+			*diff = append(*diff, fmt.Sprintf("%s is %s, expected %s", location, goldenString(got), goldenString(want)))
+		}
+	}
+}
+
+func isVolatile(path []string, volatile [][]string) bool {
+	for _, pattern := range volatile {
+		if matchGoldenPath(pattern, path) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchGoldenPath reports whether path lies within the field of pattern.
+func matchGoldenPath(pattern, path []string) bool {
+	if len(path) < len(pattern) {
+		return false
+	}
+	for i, segment := range pattern {
+		if segment != "*" && segment != path[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// formatGoldenPath renders path like "[0].data.name".
+func formatGoldenPath(path []string) string {
+	var b strings.Builder
+	for i, segment := range path {
+		// the top level is the array of records
+		if i == 0 {
+			fmt.Fprintf(&b, "[%s]", segment)
+			continue
+		}
+		b.WriteString(".")
+		b.WriteString(segment)
+	}
+	return b.String()
+}
+
+func goldenString(value any) string {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return string(data)
+}
+
+// AssertGolden compares got, a document of CanonicalEventJSON or
+// CanonicalCommandJSON, with the golden file at path and reports each
+// difference. With GoldenUpdateEnv set, the file is written instead.
+func AssertGolden(t testing.TB, path string, got []byte, volatile ...string) {
+	t.Helper()
+	if len(os.Getenv(GoldenUpdateEnv)) > 0 {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, got, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read golden file, run with %s=1 to create it: %v", GoldenUpdateEnv, err)
+	}
+	diff, err := CompareGolden(want, got, volatile...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, d := range diff {
+		t.Errorf("%s: %s", path, d)
+	}
+}
@@ -0,0 +1,110 @@
+package storetest_test
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gradientzero/comby-store-sqlite/storetest"
+	"github.com/gradientzero/comby/v3"
+)
+
+func TestCanonicalEventJSON(t *testing.T) {
+	evt := storetest.NewEvent(storetest.EventData("OrderPlaced", []byte(`{"total":12.50,"id":"o-1"}`)))
+	reordered := storetest.NewEvent(storetest.EventData("OrderPlaced", []byte(`{"id":"o-1","total":12.50}`)))
+	reordered.SetEventUuid(evt.GetEventUuid())
+	reordered.SetCommandUuid(evt.GetCommandUuid())
+	reordered.SetAggregateUuid(evt.GetAggregateUuid())
+	reordered.SetCreatedAt(evt.GetCreatedAt())
+
+	a, err := storetest.CanonicalEventJSON(evt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := storetest.CanonicalEventJSON(reordered)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(a) != string(b) {
+		t.Fatalf("expected the same document for reordered payloads:\n%s\n%s", a, b)
+	}
+	if !strings.Contains(string(a), `"data": {`) || !strings.Contains(string(a), `"total": 12.50`) {
+		t.Fatalf("expected the payload as JSON with numbers as written:\n%s", a)
+	}
+
+	binary, err := storetest.CanonicalEventJSON(storetest.NewEvent(storetest.EventData("Blob", []byte{0xff, 0x00})))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(binary), `"data": "base64:/wA="`) {
+		t.Fatalf("expected a base64 payload:\n%s", binary)
+	}
+}
+
+func TestCompareGolden(t *testing.T) {
+	evt := storetest.NewEvent(storetest.EventData("OrderPlaced", []byte(`{"id":"o-1","updatedAt":1}`)))
+	want, err := storetest.CanonicalEventJSON(evt)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	changed := storetest.NewEvent(storetest.EventData("OrderPlaced", []byte(`{"id":"o-1","updatedAt":2}`)))
+	changed.SetEventUuid(evt.GetEventUuid())
+	changed.SetCommandUuid(evt.GetCommandUuid())
+	changed.SetAggregateUuid(evt.GetAggregateUuid())
+	changed.SetCreatedAt(evt.GetCreatedAt() + 1)
+	got, err := storetest.CanonicalEventJSON(changed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	diff, err := storetest.CompareGolden(want, got, "created_at", "data.updatedAt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(diff) != 0 {
+		t.Fatalf("expected volatile fields to be ignored: %v", diff)
+	}
+	diff, err = storetest.CompareGolden(want, got, "created_at")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(diff) != 1 || diff[0] != `$[0].data.updatedAt is 2, expected 1` {
+		t.Fatalf("unexpected differences: %v", diff)
+	}
+
+	// a payload lost on the way through the store
+	changed.SetDomainEvtBytes(nil)
+	got, err = storetest.CanonicalEventJSON(changed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	diff, err = storetest.CompareGolden(want, got, "created_at")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(diff) != 1 || !strings.HasPrefix(diff[0], "$[0].data is null") {
+		t.Fatalf("unexpected differences: %v", diff)
+	}
+}
+
+func TestAssertGolden(t *testing.T) {
+	ctx := context.Background()
+	eventStore := storetest.NewMemoryEventStore(t)
+	evts := storetest.NewAggregateEvents(2, storetest.EventData("OrderPlaced", []byte(`{"id":"o-1"}`)))
+	storetest.CreateEvents(t, eventStore, evts...)
+	stored, _, err := eventStore.List(ctx, comby.EventStoreListOptionOrderBy("version"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := storetest.CanonicalEventJSON(stored...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(t.TempDir(), "testdata", "events.golden")
+	t.Setenv(storetest.GoldenUpdateEnv, "1")
+	storetest.AssertGolden(t, path, got)
+	t.Setenv(storetest.GoldenUpdateEnv, "")
+	storetest.AssertGolden(t, path, got)
+}
@@ -0,0 +1,95 @@
+// Package storetest provides stores and fixtures for tests of code using the
+// SQLite stores. Stores are created in t.TempDir() or in memory, initialized
+// and closed again when the test ends, so tests only contain what they test:
+//
+//	eventStore := storetest.NewTempEventStore(t)
+//	storetest.CreateEvents(t, eventStore, storetest.NewAggregateEvents(3)...)
+package storetest
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	store "github.com/gradientzero/comby-store-sqlite"
+)
+
+// NewTempEventStore returns an initialized event store in t.TempDir(), which
+// is closed and removed when the test ends.
+func NewTempEventStore(t testing.TB, opts ...store.EventStoreSQLiteOption) store.EventStoreSQLite {
+	t.Helper()
+	return initEventStore(t, filepath.Join(t.TempDir(), "events.db"), opts)
+}
+
+// NewMemoryEventStore returns an initialized event store that only lives in
+// memory until the test ends. Stores of different tests are independent.
+func NewMemoryEventStore(t testing.TB, opts ...store.EventStoreSQLiteOption) store.EventStoreSQLite {
+	t.Helper()
+	return initEventStore(t, memoryPath(t), opts)
+}
+
+// NewTempCommandStore returns an initialized command store in t.TempDir(),
+// which is closed and removed when the test ends.
+func NewTempCommandStore(t testing.TB, opts ...store.CommandStoreSQLiteOption) store.CommandStoreSQLite {
+	t.Helper()
+	return initCommandStore(t, filepath.Join(t.TempDir(), "commands.db"), opts)
+}
+
+// NewMemoryCommandStore returns an initialized command store that only lives
+// in memory until the test ends.
+func NewMemoryCommandStore(t testing.TB, opts ...store.CommandStoreSQLiteOption) store.CommandStoreSQLite {
+	t.Helper()
+	return initCommandStore(t, memoryPath(t), opts)
+}
+
+// NewTempStores opens the stores selected by opts on one file in t.TempDir(),
+// see store.Open. Without options all three stores are opened.
+func NewTempStores(t testing.TB, opts ...store.OpenOption) *store.Stores {
+	t.Helper()
+	if len(opts) == 0 {
+		opts = []store.OpenOption{store.WithEventStore(), store.WithCommandStore(), store.WithSnapshotStore()}
+	}
+	stores, err := store.Open(filepath.Join(t.TempDir(), "store.db"), opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := stores.Init(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { stores.Close(context.Background()) })
+	return stores
+}
+
+func initEventStore(t testing.TB, path string, opts []store.EventStoreSQLiteOption) store.EventStoreSQLite {
+	t.Helper()
+	eventStore := store.NewEventStoreSQLite(path)
+	eventStore.Configure(opts...)
+	if err := eventStore.Init(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { eventStore.Close(context.Background()) })
+	return eventStore
+}
+
+func initCommandStore(t testing.TB, path string, opts []store.CommandStoreSQLiteOption) store.CommandStoreSQLite {
+	t.Helper()
+	commandStore := store.NewCommandStoreSQLite(path)
+	commandStore.Configure(opts...)
+	if err := commandStore.Init(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { commandStore.Close(context.Background()) })
+	return commandStore
+}
+
+var numMemoryStores atomic.Int64
+
+// memoryPath names a shared cache in-memory database, so all connections of
+// a store see the same data. The database is dropped with the last connection.
+func memoryPath(t testing.TB) string {
+	name := strings.NewReplacer("/", "_", " ", "_", "?", "_", "&", "_", "#", "_").Replace(t.Name())
+	return fmt.Sprintf("file:%s_%d?mode=memory&cache=shared", name, numMemoryStores.Add(1))
+}
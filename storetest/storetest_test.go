@@ -0,0 +1,59 @@
+package storetest_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/gradientzero/comby-store-sqlite/storetest"
+	"github.com/gradientzero/comby/v3"
+)
+
+func TestTempEventStore(t *testing.T) {
+	ctx := context.Background()
+	for name, eventStore := range map[string]comby.EventStore{
+		"temp":   storetest.NewTempEventStore(t),
+		"memory": storetest.NewMemoryEventStore(t),
+	} {
+		evts := storetest.NewAggregateEvents(3, storetest.EventTenant("tenant-2"), storetest.EventDomain("orders"))
+		storetest.CreateEvents(t, eventStore, evts...)
+		storetest.CreateEvents(t, eventStore, storetest.NewEvent())
+
+		got, total, err := eventStore.List(ctx, comby.EventStoreListOptionOrderBy("version"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if total != 4 || len(got) != 4 {
+			t.Fatalf("%s: expected 4 events, got %d", name, total)
+		}
+		stored, err := eventStore.Get(ctx, comby.EventStoreGetOptionWithEventUuid(evts[2].GetEventUuid()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if stored.GetVersion() != 3 || stored.GetTenantUuid() != "tenant-2" || stored.GetDomain() != "orders" ||
+			stored.GetAggregateUuid() != evts[0].GetAggregateUuid() || stored.GetCreatedAt() <= evts[1].GetCreatedAt() {
+			t.Fatalf("%s: unexpected event %+v", name, stored)
+		}
+	}
+}
+
+func TestMemoryStoresAreIndependent(t *testing.T) {
+	ctx := context.Background()
+	first := storetest.NewMemoryCommandStore(t)
+	second := storetest.NewMemoryCommandStore(t)
+	storetest.CreateCommands(t, first, storetest.NewCommand(storetest.CommandDomain("orders"), storetest.CommandCreatedAt(100)))
+	if first.Total(ctx) != 1 || second.Total(ctx) != 0 {
+		t.Fatalf("expected separate databases, got %d and %d commands", first.Total(ctx), second.Total(ctx))
+	}
+	storetest.CreateCommands(t, storetest.NewTempCommandStore(t), storetest.NewCommand())
+}
+
+func TestTempStores(t *testing.T) {
+	stores := storetest.NewTempStores(t)
+	if stores.EventStore == nil || stores.CommandStore == nil || stores.SnapshotStore == nil {
+		t.Fatal("expected all stores to be opened")
+	}
+	storetest.CreateEvents(t, stores.EventStore, storetest.NewEvent(storetest.EventData("Created", []byte("{}"))))
+	if total := stores.EventStore.Total(context.Background()); total != 1 {
+		t.Fatalf("expected 1 event, got %d", total)
+	}
+}
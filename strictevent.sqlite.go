@@ -0,0 +1,54 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/gradientzero/comby/v3"
+)
+
+// ErrInvalidEvent is matched by every InvalidEventError.
+var ErrInvalidEvent = errors.New("invalid event")
+
+// InvalidEventError is returned by Create of a store with strict validation
+// for an event which would break its stream. It lists all problems found.
+type InvalidEventError struct {
+	EventUuid string
+	Problems  []string
+}
+
+func (e *InvalidEventError) Error() string {
+	return fmt.Sprintf("%s '%s': %s", ErrInvalidEvent, e.EventUuid, strings.Join(e.Problems, "; "))
+}
+
+func (e *InvalidEventError) Is(target error) bool {
+	return target == ErrInvalidEvent
+}
+
+// EventStoreSQLiteWithStrictValidation rejects events on Create with a
+// version of 0 or below, without aggregate uuid or with a created_at of 0,
+// which are stored otherwise and only surface while replaying the stream.
+// The error is an InvalidEventError.
+func EventStoreSQLiteWithStrictValidation() EventStoreSQLiteOption {
+	return func(c *eventStoreSQLiteConfig) { c.StrictValidation = true }
+}
+
+// checkStrict returns an InvalidEventError if evt, stored with version, is no
+// valid part of a stream.
+func checkStrict(evt comby.Event, version int64) error {
+	var problems []string
+	if version <= 0 {
+		problems = append(problems, fmt.Sprintf("version %d is not positive", version))
+	}
+	if len(evt.GetAggregateUuid()) == 0 {
+		problems = append(problems, "aggregate uuid is missing")
+	}
+	if evt.GetCreatedAt() == 0 {
+		problems = append(problems, "created at is missing")
+	}
+	if len(problems) == 0 {
+		return nil
+	}
+	return &InvalidEventError{EventUuid: evt.GetEventUuid(), Problems: problems}
+}
@@ -0,0 +1,55 @@
+package store_test
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	store "github.com/gradientzero/comby-store-sqlite"
+	"github.com/gradientzero/comby/v3"
+)
+
+func TestEventStoreStrictValidation(t *testing.T) {
+	ctx := context.Background()
+	eventStore := store.NewEventStoreSQLite(filepath.Join(t.TempDir(), "strict.db"))
+	eventStore.Configure(store.EventStoreSQLiteWithStrictValidation())
+	if err := eventStore.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer eventStore.Close(ctx)
+
+	if err := eventStore.Create(ctx, comby.EventStoreCreateOptionWithEvent(createTestEvent("tenant-1", "domain-1", 1, 1))); err != nil {
+		t.Fatal(err)
+	}
+
+	broken := createTestEvent("tenant-1", "domain-1", 0, 0)
+	broken.SetAggregateUuid("")
+	err := eventStore.Create(ctx, comby.EventStoreCreateOptionWithEvent(broken))
+	if !errors.Is(err, store.ErrInvalidEvent) {
+		t.Fatalf("expected invalid event, got %v", err)
+	}
+	var invalid *store.InvalidEventError
+	if !errors.As(err, &invalid) || invalid.EventUuid != broken.GetEventUuid() || len(invalid.Problems) != 3 {
+		t.Fatalf("expected all problems of the event, got %+v", invalid)
+	}
+	for _, version := range []int64{-1, 0} {
+		evt := createTestEvent("tenant-1", "domain-1", version, 2)
+		if err := eventStore.Create(ctx, comby.EventStoreCreateOptionWithEvent(evt)); !errors.Is(err, store.ErrInvalidEvent) {
+			t.Fatalf("expected version %d to be rejected, got %v", version, err)
+		}
+	}
+	if total := eventStore.Total(ctx); total != 1 {
+		t.Fatalf("expected rejected events not to be stored, got %d events", total)
+	}
+
+	// without strict validation such events are stored
+	lenient := store.NewEventStoreSQLite(filepath.Join(t.TempDir(), "lenient.db"))
+	if err := lenient.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer lenient.Close(ctx)
+	if err := lenient.Create(ctx, comby.EventStoreCreateOptionWithEvent(createTestEvent("tenant-1", "domain-1", 0, 0))); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,173 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/gradientzero/comby-store-sqlite/internal"
+	"github.com/gradientzero/comby/v3"
+)
+
+// SyncReport is the result of SyncStores.
+type SyncReport struct {
+	// events new to the peer and to the local store
+	Sent     int64
+	Received int64
+	// sequences exchanged so far, stored in the sync_state table
+	LastSent     int64
+	LastReceived int64
+}
+
+var syncTables = []strictTable{
+	{
+		name: "sync_state",
+		columns: `peer TEXT NOT NULL PRIMARY KEY,
+		last_sent INTEGER NOT NULL,
+		last_received INTEGER NOT NULL,
+		synced_at INTEGER NOT NULL`,
+		copyColumns: `peer, last_sent, last_received, synced_at`,
+	},
+}
+
+// SyncStores exchanges new events between two SQLite event stores in both
+// directions, e.g. an intermittently connected device and its server. The
+// local store keeps the last exchanged sequence of each direction per peer in
+// its sync_state table, so each call only transfers events written since the
+// previous one. Events are deduplicated by uuid.
+//
+// Writes of both stores are blocked during the sync. Payloads are copied as
+// stored, so encrypted stores must share the crypto key.
+func SyncStores(ctx context.Context, local, remote comby.EventStore, peer string) (*SyncReport, error) {
+	les, ok := local.(*eventStoreSQLite)
+	if !ok {
+		return nil, fmt.Errorf("sync requires sqlite event stores")
+	}
+	res, ok := remote.(*eventStoreSQLite)
+	if !ok {
+		return nil, fmt.Errorf("'%s' failed to sync - peer is not a sqlite event store", les.String())
+	}
+	if len(peer) == 0 {
+		return nil, fmt.Errorf("'%s' failed to sync - peer name is required", les.String())
+	}
+	for _, es := range []*eventStoreSQLite{les, res} {
+		if es.db == nil {
+			return nil, fmt.Errorf("'%s' failed to sync - event store is not initialized", es.String())
+		}
+		if es.opts().ReadOnly {
+			return nil, fmt.Errorf("'%s' failed to sync - instance is readonly", es.String())
+		}
+		if err := es.cfg().Quota.check(ctx, es.db); err != nil {
+			return nil, fmt.Errorf("'%s' failed to sync - %w", es.String(), err)
+		}
+	}
+	if les.writeMu == res.writeMu {
+		return nil, fmt.Errorf("'%s' failed to sync - stores share one database", les.String())
+	}
+
+	// the write locks of both stores are taken in the same order by every
+	// sync, so syncs of a pair in opposite directions do not deadlock
+	first, second := les, res
+	if syncLockOrder(res, les) {
+		first, second = res, les
+	}
+	doneFirst, err := first.beginWrite(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer doneFirst()
+	doneSecond, err := second.beginWrite(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer doneSecond()
+
+	if err := migrateTx(ctx, les.db, func(tx *sql.Tx) error {
+		return migrateStrictTables(ctx, tx, loggerOrDiscard(les.cfg().Logger), syncTables...)
+	}); err != nil {
+		return nil, err
+	}
+
+	report := &SyncReport{}
+	query := `SELECT last_sent, last_received FROM sync_state WHERE peer=?;`
+	err = les.db.QueryRowContext(ctx, query, peer).Scan(&report.LastSent, &report.LastReceived)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+
+	// push first: everything the peer returns afterwards (including the events
+	// just pushed) is known locally once pulled
+	report.Sent, report.LastSent, err = copyEvents(ctx, les, res, report.LastSent)
+	if err != nil {
+		return nil, err
+	}
+	report.Received, report.LastReceived, err = copyEvents(ctx, res, les, report.LastReceived)
+	if err != nil {
+		return nil, err
+	}
+	// pulled events got local sequences, but the peer knows them already
+	if err := les.db.QueryRowContext(ctx, "SELECT COALESCE(MAX(id), 0) FROM event_records;").Scan(&report.LastSent); err != nil {
+		return nil, err
+	}
+
+	query = `INSERT INTO sync_state (peer, last_sent, last_received, synced_at)
+		VALUES (?, ?, ?, ?)
+		ON CONFLICT(peer) DO UPDATE SET
+			last_sent=excluded.last_sent,
+			last_received=excluded.last_received,
+			synced_at=excluded.synced_at;`
+	if _, err := les.db.ExecContext(ctx, query, peer, report.LastSent, report.LastReceived, time.Now().UnixNano()); err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// syncLockOrder reports whether the write lock of a is taken before the one
+// of b, by database path and, for two instances of one file, by lock address.
+func syncLockOrder(a, b *eventStoreSQLite) bool {
+	if a.path != b.path {
+		return a.path < b.path
+	}
+	return reflect.ValueOf(a.writeMu).Pointer() < reflect.ValueOf(b.writeMu).Pointer()
+}
+
+// copyEvents appends the events with a sequence after the given one to the
+// other store, skipping known uuids. It returns the number of new events and
+// the last sequence read. The caller holds the write lock of the target.
+func copyEvents(ctx context.Context, from, to *eventStoreSQLite, after int64) (int64, int64, error) {
+	query := fmt.Sprintf("SELECT %s FROM events WHERE id>? ORDER BY id ASC;", eventSelectColumns)
+	rows, err := from.db.QueryContext(ctx, query, after)
+	if err != nil {
+		return 0, after, err
+	}
+	defer rows.Close()
+
+	var numItems int64
+	lastSeq := after
+	err = runTx(ctx, to.db, func(tx *sql.Tx) error {
+		for rows.Next() {
+			var dbRecord internal.Event
+			if err := scanEvent(rows, &dbRecord); err != nil {
+				return err
+			}
+			var known int
+			if err := tx.QueryRowContext(ctx, "SELECT COUNT(*) FROM event_records WHERE uuid=?;", dbRecord.Uuid).Scan(&known); err != nil {
+				return err
+			}
+			if known == 0 {
+				if err := insertEventRecord(ctx, tx, sql.NullInt64{}, &dbRecord); err != nil {
+					return err
+				}
+				numItems++
+			}
+			lastSeq = dbRecord.ID.Int64
+		}
+		return rows.Err()
+	})
+	if err != nil {
+		return 0, after, err
+	}
+	return numItems, lastSeq, nil
+}
@@ -0,0 +1,128 @@
+package store_test
+
+import (
+	"context"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	store "github.com/gradientzero/comby-store-sqlite"
+	"github.com/gradientzero/comby/v3"
+)
+
+func TestSyncStores(t *testing.T) {
+	ctx := context.Background()
+	tmpDir := t.TempDir()
+
+	device := store.NewEventStoreSQLite(filepath.Join(tmpDir, "device.db"))
+	server := store.NewEventStoreSQLite(filepath.Join(tmpDir, "server.db"))
+	for _, es := range []comby.EventStore{device, server} {
+		if err := es.Init(ctx); err != nil {
+			t.Fatal(err)
+		}
+		defer es.Close(ctx)
+	}
+	create := func(es comby.EventStore, version int64) {
+		evt := createTestEvent("tenant-1", "domain-1", version, version*100)
+		if err := es.Create(ctx, comby.EventStoreCreateOptionWithEvent(evt)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	create(device, 1)
+	create(device, 2)
+	create(server, 3)
+
+	report, err := store.SyncStores(ctx, device, server, "server")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Sent != 2 || report.Received != 1 {
+		t.Fatalf("unexpected first sync: %+v", report)
+	}
+	if device.Total(ctx) != 3 || server.Total(ctx) != 3 {
+		t.Fatalf("expected 3 events on both sides, got %d and %d", device.Total(ctx), server.Total(ctx))
+	}
+
+	// nothing new, nothing is exchanged
+	report, err = store.SyncStores(ctx, device, server, "server")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Sent != 0 || report.Received != 0 {
+		t.Fatalf("unexpected idle sync: %+v", report)
+	}
+
+	create(server, 4)
+	create(device, 5)
+	report, err = store.SyncStores(ctx, device, server, "server")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Sent != 1 || report.Received != 1 {
+		t.Fatalf("unexpected incremental sync: %+v", report)
+	}
+	if device.Total(ctx) != 5 || server.Total(ctx) != 5 {
+		t.Fatalf("expected 5 events on both sides, got %d and %d", device.Total(ctx), server.Total(ctx))
+	}
+
+	if _, err := store.SyncStores(ctx, device, server, ""); err == nil {
+		t.Fatal("expected error for missing peer name")
+	}
+}
+
+func TestSyncStoresBothDirections(t *testing.T) {
+	ctx := context.Background()
+	tmpDir := t.TempDir()
+
+	a := store.NewEventStoreSQLite(filepath.Join(tmpDir, "a.db"))
+	b := store.NewEventStoreSQLite(filepath.Join(tmpDir, "b.db"))
+	for i, es := range []comby.EventStore{a, b} {
+		if err := es.Init(ctx); err != nil {
+			t.Fatal(err)
+		}
+		defer es.Close(ctx)
+		for version := int64(1); version <= 5; version++ {
+			evt := createTestEvent("tenant-1", "domain-1", version, int64(i)*1000+version)
+			if err := es.Create(ctx, comby.EventStoreCreateOptionWithEvent(evt)); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	// syncs of the pair in opposite directions run at the same time
+	var wg sync.WaitGroup
+	errs := make(chan error, 20)
+	for i := 0; i < 10; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			_, err := store.SyncStores(ctx, a, b, "b")
+			errs <- err
+		}()
+		go func() {
+			defer wg.Done()
+			_, err := store.SyncStores(ctx, b, a, "a")
+			errs <- err
+		}()
+	}
+	finished := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(finished)
+	}()
+	select {
+	case <-finished:
+	case <-time.After(30 * time.Second):
+		t.Fatal("syncs in opposite directions deadlocked")
+	}
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if a.Total(ctx) != 10 || b.Total(ctx) != 10 {
+		t.Fatalf("expected 10 events on both sides, got %d and %d", a.Total(ctx), b.Total(ctx))
+	}
+}
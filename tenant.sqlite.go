@@ -0,0 +1,63 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+// ErrTenantMismatch is returned when a store bound to a tenant is asked to
+// write a record of another tenant.
+var ErrTenantMismatch = errors.New("tenant does not match the tenant of the store")
+
+// EventStoreSQLiteWithTenant binds the store to one tenant, as defense in
+// depth for services creating a store per tenant. Get, List, Total, Info,
+// UniqueList, ListBatches, ListDataTypes, ListEventsByCommand, AggregateAsOf,
+// Replay and EventArchiver.Archive only see events of the tenant, writes of
+// events of another tenant fail with ErrTenantMismatch. Diagnostics,
+// maintenance and export functions still operate on the whole database.
+func EventStoreSQLiteWithTenant(tenantUuid string) EventStoreSQLiteOption {
+	return func(c *eventStoreSQLiteConfig) { c.Tenant = tenantUuid }
+}
+
+// CommandStoreSQLiteWithTenant binds the store to one tenant like
+// EventStoreSQLiteWithTenant. Get, List, Total, Info, ListBatches,
+// ListDataTypes, GetStatus, MarkProcessed and MarkFailed only see commands of
+// the tenant.
+func CommandStoreSQLiteWithTenant(tenantUuid string) CommandStoreSQLiteOption {
+	return func(c *commandStoreSQLiteConfig) { c.Tenant = tenantUuid }
+}
+
+// checkTenant fails if the store is bound to a tenant other than tenantUuid.
+func checkTenant(boundTenant, tenantUuid string) error {
+	if len(boundTenant) > 0 && tenantUuid != boundTenant {
+		return fmt.Errorf("%w: '%s'", ErrTenantMismatch, tenantUuid)
+	}
+	return nil
+}
+
+// tenantCondition appends the filter of the bound tenant, if any, to a where
+// clause and its arguments.
+func tenantCondition(boundTenant string, whereList []string, args []any) ([]string, []any) {
+	if len(boundTenant) == 0 {
+		return whereList, args
+	}
+	return append(whereList, "tenant_uuid=?"), append(args, boundTenant)
+}
+
+// checkStoredTenant fails if the record with uuid in table belongs to a tenant
+// other than the bound one. Unknown records pass, they are not touched anyway.
+func checkStoredTenant(ctx context.Context, q queryer, boundTenant, table, uuid string) error {
+	if len(boundTenant) == 0 {
+		return nil
+	}
+	var tenantUuid string
+	err := q.QueryRowContext(ctx, fmt.Sprintf("SELECT tenant_uuid FROM %s WHERE uuid=? LIMIT 1;", table), uuid).Scan(&tenantUuid)
+	if err == sql.ErrNoRows {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	return checkTenant(boundTenant, tenantUuid)
+}
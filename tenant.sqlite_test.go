@@ -0,0 +1,150 @@
+package store_test
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	store "github.com/gradientzero/comby-store-sqlite"
+	"github.com/gradientzero/comby/v3"
+)
+
+func TestEventStoreTenantGuard(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "tenant.db")
+
+	// unbound store writes events of two tenants
+	shared := store.NewEventStoreSQLite(path)
+	if err := shared.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer shared.Close(ctx)
+	own := createTestEvent("tenant-1", "domain-1", 1, 1)
+	foreign := createTestEvent("tenant-2", "domain-1", 1, 2)
+	for _, evt := range []comby.Event{own, foreign} {
+		if err := shared.Create(ctx, comby.EventStoreCreateOptionWithEvent(evt)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	bound := store.NewEventStoreSQLite(path)
+	bound.Configure(store.EventStoreSQLiteWithTenant("tenant-1"))
+	if err := bound.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer bound.Close(ctx)
+
+	if evt, err := bound.Get(ctx, comby.EventStoreGetOptionWithEventUuid(foreign.GetEventUuid())); err != nil || evt != nil {
+		t.Fatalf("expected foreign event to be invisible, got %v, %v", evt, err)
+	}
+	if evt, err := bound.Get(ctx, comby.EventStoreGetOptionWithEventUuid(own.GetEventUuid())); err != nil || evt == nil {
+		t.Fatalf("expected own event, got %v, %v", evt, err)
+	}
+	// an explicit filter for another tenant does not widen the view
+	evts, total, err := bound.List(ctx, func(opts *comby.EventStoreListOptions) (*comby.EventStoreListOptions, error) {
+		opts.TenantUuid = "tenant-2"
+		return opts, nil
+	})
+	if err != nil || len(evts) != 0 || total != 0 {
+		t.Fatalf("expected no events of tenant-2, got %d (total %d), %v", len(evts), total, err)
+	}
+	if evts, total, err := bound.List(ctx); err != nil || len(evts) != 1 || total != 1 {
+		t.Fatalf("expected 1 event, got %d (total %d), %v", len(evts), total, err)
+	}
+	if total := bound.Total(ctx); total != 1 {
+		t.Fatalf("expected total 1, got %d", total)
+	}
+	tenants, _, err := bound.UniqueList(ctx)
+	if err != nil || len(tenants) != 1 || tenants[0] != "tenant-1" {
+		t.Fatalf("expected only tenant-1, got %v, %v", tenants, err)
+	}
+	var replayed int
+	if _, err := bound.Replay(ctx, func(ctx context.Context, seq int64, evt comby.Event) error {
+		replayed++
+		return nil
+	}); err != nil || replayed != 1 {
+		t.Fatalf("expected 1 replayed event, got %d, %v", replayed, err)
+	}
+	if info, err := bound.Info(ctx); err != nil || info.NumItems != 1 || info.LastItemCreatedAt != own.GetCreatedAt() {
+		t.Fatalf("expected info of tenant-1 only, got %+v, %v", info, err)
+	}
+	var applied int
+	if _, err := bound.AggregateAsOf(ctx, foreign.GetAggregateUuid(), foreign.GetCreatedAt(), func(evt comby.Event) error {
+		applied++
+		return nil
+	}); err != nil || applied != 1 {
+		t.Fatalf("expected 1 applied event, got %d, %v", applied, err)
+	}
+	if evts, err := bound.ListEventsByCommand(ctx, foreign.GetCommandUuid()); err != nil || len(evts) != 1 || evts[0].GetTenantUuid() != "tenant-1" {
+		t.Fatalf("expected 1 event of tenant-1 by command, got %v, %v", evts, err)
+	}
+	if infos, err := bound.ListDataTypes(ctx); err != nil || len(infos) != 1 || infos[0].Count != 1 {
+		t.Fatalf("expected 1 data type with 1 event, got %+v, %v", infos, err)
+	}
+
+	// writes of other tenants are rejected
+	err = bound.Create(ctx, comby.EventStoreCreateOptionWithEvent(createTestEvent("tenant-2", "domain-1", 2, 3)))
+	if !errors.Is(err, store.ErrTenantMismatch) {
+		t.Fatalf("expected ErrTenantMismatch on create, got %v", err)
+	}
+	foreign.SetTenantUuid("tenant-1")
+	if err := bound.Update(ctx, comby.EventStoreUpdateOptionWithEvent(foreign)); !errors.Is(err, store.ErrTenantMismatch) {
+		t.Fatalf("expected ErrTenantMismatch on update of a foreign event, got %v", err)
+	}
+	if err := bound.Delete(ctx, comby.EventStoreDeleteOptionWithEventUuid(foreign.GetEventUuid())); !errors.Is(err, store.ErrTenantMismatch) {
+		t.Fatalf("expected ErrTenantMismatch on delete, got %v", err)
+	}
+	if total := shared.Total(ctx); total != 2 {
+		t.Fatalf("expected both events to remain, got %d", total)
+	}
+	if err := bound.Delete(ctx, comby.EventStoreDeleteOptionWithEventUuid(own.GetEventUuid())); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCommandStoreTenantGuard(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "tenant.db")
+
+	// unbound store writes a command of another tenant
+	shared := store.NewCommandStoreSQLite(path)
+	if err := shared.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer shared.Close(ctx)
+	foreign := createTestCommand("tenant-2", "domain-1", 3)
+	if err := shared.Create(ctx, comby.CommandStoreCreateOptionWithCommand(foreign)); err != nil {
+		t.Fatal(err)
+	}
+
+	commandStore := store.NewCommandStoreSQLite(path)
+	commandStore.Configure(store.CommandStoreSQLiteWithTenant("tenant-1"))
+	if err := commandStore.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer commandStore.Close(ctx)
+
+	if err := commandStore.Create(ctx, comby.CommandStoreCreateOptionWithCommand(createTestCommand("tenant-1", "domain-1", 1))); err != nil {
+		t.Fatal(err)
+	}
+	err := commandStore.Create(ctx, comby.CommandStoreCreateOptionWithCommand(createTestCommand("tenant-2", "domain-1", 2)))
+	if !errors.Is(err, store.ErrTenantMismatch) {
+		t.Fatalf("expected ErrTenantMismatch, got %v", err)
+	}
+	if cmds, total, err := commandStore.List(ctx); err != nil || len(cmds) != 1 || total != 1 {
+		t.Fatalf("expected 1 command, got %d (total %d), %v", len(cmds), total, err)
+	}
+	if info, err := commandStore.Info(ctx); err != nil || info.NumItems != 1 || info.LastItemCreatedAt != 1 {
+		t.Fatalf("expected info of tenant-1 only, got %+v, %v", info, err)
+	}
+	if status, err := commandStore.GetStatus(ctx, foreign.GetCommandUuid()); err != nil || status != nil {
+		t.Fatalf("expected status of a foreign command to be invisible, got %+v, %v", status, err)
+	}
+	if err := commandStore.MarkProcessed(ctx, foreign.GetCommandUuid()); err == nil {
+		t.Fatal("expected marking a foreign command to fail")
+	}
+	if status, err := shared.GetStatus(ctx, foreign.GetCommandUuid()); err != nil || status.Status != store.CommandStatusPending {
+		t.Fatalf("expected foreign command to stay pending, got %+v, %v", status, err)
+	}
+}
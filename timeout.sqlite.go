@@ -0,0 +1,85 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// busy_timeout of connections unless configured otherwise
+const defaultBusyTimeout = 5 * time.Second
+
+// opTimeouts bounds lock waits and the duration of store operations.
+type opTimeouts struct {
+	// how long a statement waits for a lock held by another connection or process
+	Busy time.Duration
+	// deadlines of reads (Get, List, UniqueList) and writes (Create, Update,
+	// Delete, WithTx including the wait for the write lock), 0 disables them
+	Read  time.Duration
+	Write time.Duration
+}
+
+// EventStoreSQLiteWithBusyTimeout sets how long statements wait for locks held
+// by other connections before failing with ErrLocked (default 5s). Takes
+// effect on Init.
+func EventStoreSQLiteWithBusyTimeout(timeout time.Duration) EventStoreSQLiteOption {
+	return func(c *eventStoreSQLiteConfig) { c.Timeouts.Busy = timeout }
+}
+
+// EventStoreSQLiteWithTimeouts bounds reads and writes independently, e.g. to
+// fail writes waiting for the lock quickly while allowing long analytical
+// reads. Operations exceeding their deadline fail with a context error.
+func EventStoreSQLiteWithTimeouts(read, write time.Duration) EventStoreSQLiteOption {
+	return func(c *eventStoreSQLiteConfig) {
+		c.Timeouts.Read = read
+		c.Timeouts.Write = write
+	}
+}
+
+// CommandStoreSQLiteWithBusyTimeout is EventStoreSQLiteWithBusyTimeout for the command store.
+func CommandStoreSQLiteWithBusyTimeout(timeout time.Duration) CommandStoreSQLiteOption {
+	return func(c *commandStoreSQLiteConfig) { c.Timeouts.Busy = timeout }
+}
+
+// CommandStoreSQLiteWithTimeouts is EventStoreSQLiteWithTimeouts for the command store.
+func CommandStoreSQLiteWithTimeouts(read, write time.Duration) CommandStoreSQLiteOption {
+	return func(c *commandStoreSQLiteConfig) {
+		c.Timeouts.Read = read
+		c.Timeouts.Write = write
+	}
+}
+
+func (t opTimeouts) read(ctx context.Context) (context.Context, context.CancelFunc) {
+	return withTimeout(ctx, t.Read)
+}
+
+func (t opTimeouts) write(ctx context.Context) (context.Context, context.CancelFunc) {
+	return withTimeout(ctx, t.Write)
+}
+
+func withTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+// dsn adds the busy timeout and further pragmas to the data source name, so
+// that they are applied to every connection of the pool. A PRAGMA executed on
+// the pool only reaches one of them.
+func (t opTimeouts) dsn(path string, pragmas ...string) string {
+	busy := t.Busy
+	if busy <= 0 {
+		busy = defaultBusyTimeout
+	}
+	sep := "?"
+	if strings.Contains(path, "?") {
+		sep = "&"
+	}
+	dsn := fmt.Sprintf("%s%s_pragma=busy_timeout(%d)", path, sep, busy.Milliseconds())
+	for _, pragma := range pragmas {
+		dsn += "&_pragma=" + pragma
+	}
+	return dsn
+}
@@ -0,0 +1,79 @@
+package store_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	store "github.com/gradientzero/comby-store-sqlite"
+	"github.com/gradientzero/comby/v3"
+)
+
+func TestEventStoreBusyTimeout(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "eventStore-busy.db")
+	eventStore := store.NewEventStoreSQLite(path)
+	eventStore.Configure(store.EventStoreSQLiteWithBusyTimeout(100 * time.Millisecond))
+	if err := eventStore.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer eventStore.Close(ctx)
+
+	// another process holds the write lock
+	db, err := sql.Open(store.DefaultDriverName(), path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if _, err := conn.ExecContext(ctx, "BEGIN IMMEDIATE;"); err != nil {
+		t.Fatal(err)
+	}
+	defer conn.ExecContext(ctx, "ROLLBACK;")
+
+	start := time.Now()
+	err = eventStore.Create(ctx, comby.EventStoreCreateOptionWithEvent(createTestEvent("tenant-1", "domain-1", 1, 100)))
+	if !errors.Is(err, store.ErrLocked) {
+		t.Fatalf("expected locked database, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 100*time.Millisecond || elapsed > 2*time.Second {
+		t.Fatalf("unexpected lock wait: %s", elapsed)
+	}
+}
+
+func TestEventStoreWriteTimeout(t *testing.T) {
+	ctx := context.Background()
+	eventStore := store.NewEventStoreSQLite(filepath.Join(t.TempDir(), "eventStore-timeout.db"))
+	eventStore.Configure(store.EventStoreSQLiteWithTimeouts(0, 50*time.Millisecond))
+	if err := eventStore.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer eventStore.Close(ctx)
+
+	// a long transaction holds the write lock of the store
+	started := make(chan struct{})
+	release := make(chan struct{})
+	go eventStore.WithTx(context.Background(), func(tx store.EventStoreTx) error {
+		close(started)
+		<-release
+		return nil
+	})
+	<-started
+	defer close(release)
+
+	err := eventStore.Create(ctx, comby.EventStoreCreateOptionWithEvent(createTestEvent("tenant-1", "domain-1", 1, 100)))
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected deadline exceeded, got %v", err)
+	}
+	// reads are not bounded by the write timeout
+	if _, _, err := eventStore.List(ctx); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,244 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/gradientzero/comby/v3"
+)
+
+// EventStoreTx offers the mutations of the event store within one transaction.
+// Get reads through the transaction and sees its uncommitted writes.
+type EventStoreTx interface {
+	Get(ctx context.Context, opts ...comby.EventStoreGetOption) (comby.Event, error)
+	Create(ctx context.Context, opts ...comby.EventStoreCreateOption) error
+	Update(ctx context.Context, opts ...comby.EventStoreUpdateOption) error
+	Delete(ctx context.Context, opts ...comby.EventStoreDeleteOption) error
+}
+
+// CommandStoreTx offers the mutations of the command store within one transaction.
+// Get reads through the transaction and sees its uncommitted writes.
+type CommandStoreTx interface {
+	Get(ctx context.Context, opts ...comby.CommandStoreGetOption) (comby.Command, error)
+	Create(ctx context.Context, opts ...comby.CommandStoreCreateOption) error
+	Update(ctx context.Context, opts ...comby.CommandStoreUpdateOption) error
+	Delete(ctx context.Context, opts ...comby.CommandStoreDeleteOption) error
+}
+
+// SnapshotStoreTx offers the mutations of the snapshot store within one transaction.
+// GetLatest reads through the transaction and sees its uncommitted writes.
+type SnapshotStoreTx interface {
+	GetLatest(ctx context.Context, aggregateUuid string) (*comby.SnapshotStoreModel, error)
+	Save(ctx context.Context, model *comby.SnapshotStoreModel) error
+	Delete(ctx context.Context, aggregateUuid string) error
+}
+
+// StoreTx spans all stores created by Open. Accessors of stores which were
+// not requested return nil.
+type StoreTx interface {
+	Events() EventStoreTx
+	Commands() CommandStoreTx
+	Snapshots() SnapshotStoreTx
+}
+
+type eventStoreTx struct {
+	es *eventStoreSQLite
+	tx *sql.Tx
+	// versions assigned by Create, set once tx is committed
+	versions versionAssignments
+}
+
+func (t *eventStoreTx) Get(ctx context.Context, opts ...comby.EventStoreGetOption) (comby.Event, error) {
+	getOpts := comby.EventStoreGetOptions{}
+	for _, opt := range opts {
+		if _, err := opt(&getOpts); err != nil {
+			return nil, err
+		}
+	}
+	evt, err := t.es.get(ctx, t.tx, "events", getOpts.EventUuid)
+	if err != nil {
+		return nil, err
+	}
+	if err := t.es.authorizeGet(ctx, getOpts.EventUuid, evt); err != nil {
+		return nil, err
+	}
+	return evt, nil
+}
+
+func (t *eventStoreTx) Create(ctx context.Context, opts ...comby.EventStoreCreateOption) error {
+	return t.es.create(ctx, t.tx, &t.versions, opts...)
+}
+
+func (t *eventStoreTx) Update(ctx context.Context, opts ...comby.EventStoreUpdateOption) error {
+	return t.es.update(ctx, t.tx, opts...)
+}
+
+func (t *eventStoreTx) Delete(ctx context.Context, opts ...comby.EventStoreDeleteOption) error {
+	return t.es.delete(ctx, t.tx, opts...)
+}
+
+type commandStoreTx struct {
+	cs *commandStoreSQLite
+	tx *sql.Tx
+}
+
+func (t *commandStoreTx) Get(ctx context.Context, opts ...comby.CommandStoreGetOption) (comby.Command, error) {
+	getOpts := comby.CommandStoreGetOptions{}
+	for _, opt := range opts {
+		if _, err := opt(&getOpts); err != nil {
+			return nil, err
+		}
+	}
+	cmd, err := t.cs.get(ctx, t.tx, getOpts.CommandUuid)
+	if err != nil {
+		return nil, err
+	}
+	if err := t.cs.authorizeGet(ctx, getOpts.CommandUuid, cmd); err != nil {
+		return nil, err
+	}
+	return cmd, nil
+}
+
+func (t *commandStoreTx) Create(ctx context.Context, opts ...comby.CommandStoreCreateOption) error {
+	return t.cs.create(ctx, t.tx, opts...)
+}
+
+func (t *commandStoreTx) Update(ctx context.Context, opts ...comby.CommandStoreUpdateOption) error {
+	return t.cs.update(ctx, t.tx, opts...)
+}
+
+func (t *commandStoreTx) Delete(ctx context.Context, opts ...comby.CommandStoreDeleteOption) error {
+	return t.cs.delete(ctx, t.tx, opts...)
+}
+
+type snapshotStoreTx struct {
+	s  *snapshotStoreSQLite
+	tx *sql.Tx
+}
+
+func (t *snapshotStoreTx) GetLatest(ctx context.Context, aggregateUuid string) (*comby.SnapshotStoreModel, error) {
+	return t.s.getLatest(ctx, t.tx, aggregateUuid)
+}
+
+func (t *snapshotStoreTx) Save(ctx context.Context, model *comby.SnapshotStoreModel) error {
+	return t.s.save(ctx, t.tx, model)
+}
+
+func (t *snapshotStoreTx) Delete(ctx context.Context, aggregateUuid string) error {
+	return t.s.delete(ctx, t.tx, aggregateUuid)
+}
+
+type storeTx struct {
+	events    EventStoreTx
+	commands  CommandStoreTx
+	snapshots SnapshotStoreTx
+}
+
+func (t *storeTx) Events() EventStoreTx       { return t.events }
+func (t *storeTx) Commands() CommandStoreTx   { return t.commands }
+func (t *storeTx) Snapshots() SnapshotStoreTx { return t.snapshots }
+
+// runTx runs fn in a transaction which is committed if fn succeeds and rolled back otherwise.
+// Driver errors are classified, see classifyError.
+func runTx(ctx context.Context, db *sql.DB, fn func(tx *sql.Tx) error) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return classifyError(err)
+	}
+	defer tx.Rollback()
+
+	if err := fn(tx); err != nil {
+		return classifyError(err)
+	}
+	return classifyError(tx.Commit())
+}
+
+// WithTx runs fn in one transaction. All mutations are committed together if fn
+// returns nil and rolled back otherwise. Other writers wait until fn returns.
+//
+// fn must only use tx: writes of the store itself wait for fn and would
+// deadlock, and its reads do not see the uncommitted writes of tx.
+func (es *eventStoreSQLite) WithTx(ctx context.Context, fn func(tx EventStoreTx) error) error {
+	ctx, cancel := es.cfg().Timeouts.write(ctx)
+	defer cancel()
+	if es.opts().ReadOnly {
+		return fmt.Errorf("'%s' failed to run transaction - instance is readonly", es.String())
+	}
+	if err := es.cfg().Quota.check(ctx, es.db); err != nil {
+		return fmt.Errorf("'%s' failed to run transaction - %w", es.String(), err)
+	}
+	done, err := es.beginWrite(ctx)
+	if err != nil {
+		return err
+	}
+	defer done()
+	t := &eventStoreTx{es: es}
+	err = runTx(ctx, es.db, func(tx *sql.Tx) error {
+		t.tx = tx
+		return fn(t)
+	})
+	t.versions.apply(err == nil)
+	return err
+}
+
+// WithTx runs fn in one transaction. All mutations are committed together if fn
+// returns nil and rolled back otherwise. Other writers wait until fn returns.
+//
+// fn must only use tx: writes of the store itself wait for fn and would
+// deadlock, and its reads do not see the uncommitted writes of tx.
+func (cs *commandStoreSQLite) WithTx(ctx context.Context, fn func(tx CommandStoreTx) error) error {
+	ctx, cancel := cs.cfg().Timeouts.write(ctx)
+	defer cancel()
+	if cs.opts().ReadOnly {
+		return fmt.Errorf("'%s' failed to run transaction - instance is readonly", cs.String())
+	}
+	if err := cs.cfg().Quota.check(ctx, cs.db); err != nil {
+		return fmt.Errorf("'%s' failed to run transaction - %w", cs.String(), err)
+	}
+	done, err := cs.beginWrite(ctx)
+	if err != nil {
+		return err
+	}
+	defer done()
+	return runTx(ctx, cs.db, func(tx *sql.Tx) error {
+		return fn(&commandStoreTx{cs: cs, tx: tx})
+	})
+}
+
+// WithTx runs fn in one transaction spanning all stores, e.g. to delete an old
+// snapshot and append an event atomically.
+//
+// fn must only use tx: writes of the store itself wait for fn and would
+// deadlock, and its reads do not see the uncommitted writes of tx.
+func (s *Stores) WithTx(ctx context.Context, fn func(tx StoreTx) error) error {
+	if es, ok := s.EventStore.(*eventStoreSQLite); ok {
+		if err := es.cfg().Quota.check(ctx, s.db); err != nil {
+			return fmt.Errorf("'%s' failed to run transaction - %w", s.String(), err)
+		}
+	}
+	done, err := s.beginWrite(ctx)
+	if err != nil {
+		return err
+	}
+	defer done()
+	var events *eventStoreTx
+	err = runTx(ctx, s.db, func(tx *sql.Tx) error {
+		t := &storeTx{}
+		if es, ok := s.EventStore.(*eventStoreSQLite); ok {
+			events = &eventStoreTx{es: es, tx: tx}
+			t.events = events
+		}
+		if cs, ok := s.CommandStore.(*commandStoreSQLite); ok {
+			t.commands = &commandStoreTx{cs: cs, tx: tx}
+		}
+		if ss, ok := s.SnapshotStore.(*snapshotStoreSQLite); ok {
+			t.snapshots = &snapshotStoreTx{s: ss, tx: tx}
+		}
+		return fn(t)
+	})
+	if events != nil {
+		events.versions.apply(err == nil)
+	}
+	return err
+}
@@ -0,0 +1,149 @@
+package store_test
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	store "github.com/gradientzero/comby-store-sqlite"
+	"github.com/gradientzero/comby/v3"
+)
+
+func TestEventStoreWithTx(t *testing.T) {
+	ctx := context.Background()
+	eventStore := store.NewEventStoreSQLite(filepath.Join(t.TempDir(), "eventStore-tx.db"))
+	if err := eventStore.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer eventStore.Close(ctx)
+
+	// committed together
+	if err := eventStore.WithTx(ctx, func(tx store.EventStoreTx) error {
+		for i := int64(1); i <= 3; i++ {
+			if err := tx.Create(ctx, comby.EventStoreCreateOptionWithEvent(createTestEvent("tenant-1", "domain-1", i, i*100))); err != nil {
+				return err
+			}
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if total := eventStore.Total(ctx); total != 3 {
+		t.Fatalf("expected 3 events, got %d", total)
+	}
+
+	// rolled back together
+	errAbort := errors.New("abort")
+	evt := createTestEvent("tenant-1", "domain-1", 4, 400)
+	if err := eventStore.WithTx(ctx, func(tx store.EventStoreTx) error {
+		if err := tx.Create(ctx, comby.EventStoreCreateOptionWithEvent(evt)); err != nil {
+			return err
+		}
+		// uncommitted writes are only visible through tx
+		if _evt, err := tx.Get(ctx, comby.EventStoreGetOptionWithEventUuid(evt.GetEventUuid())); err != nil || _evt == nil {
+			t.Errorf("expected event within tx: %v", err)
+		}
+		if _evt, err := eventStore.Get(ctx, comby.EventStoreGetOptionWithEventUuid(evt.GetEventUuid())); err != nil || _evt != nil {
+			t.Errorf("expected event to be invisible outside tx: %v", err)
+		}
+		return errAbort
+	}); !errors.Is(err, errAbort) {
+		t.Fatalf("expected abort error, got %v", err)
+	}
+	if total := eventStore.Total(ctx); total != 3 {
+		t.Fatalf("expected 3 events after rollback, got %d", total)
+	}
+
+	// a failing mutation rolls back previous ones
+	if err := eventStore.WithTx(ctx, func(tx store.EventStoreTx) error {
+		if err := tx.Create(ctx, comby.EventStoreCreateOptionWithEvent(evt)); err != nil {
+			return err
+		}
+		return tx.Create(ctx, comby.EventStoreCreateOptionWithEvent(evt))
+	}); err == nil {
+		t.Fatal("expected duplicate uuid error")
+	}
+	if total := eventStore.Total(ctx); total != 3 {
+		t.Fatalf("expected 3 events after failed mutation, got %d", total)
+	}
+}
+
+func TestCommandStoreWithTx(t *testing.T) {
+	ctx := context.Background()
+	commandStore := store.NewCommandStoreSQLite(filepath.Join(t.TempDir(), "commandStore-tx.db"))
+	if err := commandStore.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer commandStore.Close(ctx)
+
+	cmd := createTestCommand("tenant-1", "domain-1", 100)
+	if err := commandStore.Create(ctx, comby.CommandStoreCreateOptionWithCommand(cmd)); err != nil {
+		t.Fatal(err)
+	}
+	// replace a command atomically
+	replacement := createTestCommand("tenant-1", "domain-1", 200)
+	if err := commandStore.WithTx(ctx, func(tx store.CommandStoreTx) error {
+		if err := tx.Delete(ctx, comby.CommandStoreDeleteOptionWithCommandUuid(cmd.GetCommandUuid())); err != nil {
+			return err
+		}
+		if _cmd, err := tx.Get(ctx, comby.CommandStoreGetOptionWithCommandUuid(cmd.GetCommandUuid())); err != nil || _cmd != nil {
+			t.Errorf("expected command to be deleted within tx: %v", err)
+		}
+		return tx.Create(ctx, comby.CommandStoreCreateOptionWithCommand(replacement))
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if total := commandStore.Total(ctx); total != 1 {
+		t.Fatalf("expected 1 command, got %d", total)
+	}
+}
+
+func TestStoresWithTx(t *testing.T) {
+	ctx := context.Background()
+	stores, err := store.Open(filepath.Join(t.TempDir(), "store.db"),
+		store.WithEventStore(),
+		store.WithSnapshotStore(),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := stores.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer stores.Close(ctx)
+
+	evt := createTestEvent("tenant-1", "domain-1", 1, 100)
+	snapshot := &comby.SnapshotStoreModel{
+		AggregateUuid: evt.GetAggregateUuid(),
+		Domain:        "domain-1",
+		Version:       1,
+		Data:          []byte("snapshot"),
+		CreatedAt:     100,
+	}
+	if err := stores.SnapshotStore.Save(ctx, snapshot); err != nil {
+		t.Fatal(err)
+	}
+
+	// delete old snapshot and append event atomically
+	if err := stores.WithTx(ctx, func(tx store.StoreTx) error {
+		if tx.Commands() != nil {
+			t.Fatal("command store was not requested")
+		}
+		if err := tx.Snapshots().Delete(ctx, snapshot.AggregateUuid); err != nil {
+			return err
+		}
+		if model, err := tx.Snapshots().GetLatest(ctx, snapshot.AggregateUuid); err != nil || model != nil {
+			t.Errorf("expected snapshot to be deleted within tx: %v", err)
+		}
+		return tx.Events().Create(ctx, comby.EventStoreCreateOptionWithEvent(evt))
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if model, err := stores.SnapshotStore.GetLatest(ctx, snapshot.AggregateUuid); err != nil || model != nil {
+		t.Fatalf("expected snapshot to be deleted: %v", err)
+	}
+	if total := stores.EventStore.Total(ctx); total != 1 {
+		t.Fatalf("expected 1 event, got %d", total)
+	}
+}
@@ -0,0 +1,296 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gradientzero/comby/v3"
+)
+
+// event columns UniqueList and UniqueListFields may select, field names are
+// interpolated into the query, so anything else is rejected
+var uniqueListFields = map[string]bool{
+	"uuid":           true,
+	"tenant_uuid":    true,
+	"workspace_uuid": true,
+	"command_uuid":   true,
+	"domain":         true,
+	"aggregate_uuid": true,
+	"data_type":      true,
+}
+
+// IsUniqueListField reports whether UniqueList can select the event column field.
+func IsUniqueListField(field string) bool {
+	return uniqueListFields[field]
+}
+
+func (es *eventStoreSQLite) validateUniqueListField(field string) error {
+	if !uniqueListFields[field] {
+		return fmt.Errorf("'%s' failed to list unique values - field '%s' is not supported", es.String(), field)
+	}
+	return nil
+}
+
+// uniqueFilter holds the unique list options of this package, which have no
+// counterpart in comby.EventStoreUniqueListOptions.
+type uniqueFilter struct {
+	Prefix    string
+	HasPrefix bool
+	After     string
+	HasAfter  bool
+}
+
+// uniqueFilters maps the unique list options a store is applying to its
+// filter, like listFilters.
+var uniqueFilters sync.Map
+
+// sqliteUniqueListOption applies fn to the filter of a sqlite store and fails
+// with other stores.
+func sqliteUniqueListOption(listOpts any, name string, fn func(filter *uniqueFilter)) error {
+	filter, ok := uniqueFilters.Load(listOpts)
+	if !ok {
+		return fmt.Errorf("unique list option '%s' requires a sqlite store", name)
+	}
+	fn(filter.(*uniqueFilter))
+	return nil
+}
+
+// EventStoreUniqueListOptionPrefix lists the values starting with prefix,
+// e.g. what a user typed into a tenant picker. The match is case-sensitive
+// and uses the index of the field. The total counts the matching values.
+func EventStoreUniqueListOptionPrefix(prefix string) comby.EventStoreUniqueListOption {
+	return func(opt *comby.EventStoreUniqueListOptions) (*comby.EventStoreUniqueListOptions, error) {
+		return opt, sqliteUniqueListOption(opt, "prefix", func(filter *uniqueFilter) {
+			filter.Prefix, filter.HasPrefix = prefix, true
+		})
+	}
+}
+
+// EventStoreUniqueListOptionAfter lists the values after value in the order
+// of the list (keyset pagination), value is usually the last one of the
+// previous page. Unlike Offset, the cost of a page does not grow with its
+// position. The total still counts all values matching the filters.
+func EventStoreUniqueListOptionAfter(value string) comby.EventStoreUniqueListOption {
+	return func(opt *comby.EventStoreUniqueListOptions) (*comby.EventStoreUniqueListOptions, error) {
+		return opt, sqliteUniqueListOption(opt, "after", func(filter *uniqueFilter) {
+			filter.After, filter.HasAfter = value, true
+		})
+	}
+}
+
+// afterCondition appends the cursor condition of field unless no cursor is set.
+func (f uniqueFilter) afterCondition(field string, ascending bool, whereList []string, args []any) ([]string, []any) {
+	if !f.HasAfter {
+		return whereList, args
+	}
+	if ascending {
+		return append(whereList, field+">?"), append(args, f.After)
+	}
+	return append(whereList, field+"<?"), append(args, f.After)
+}
+
+// prepareUniqueList returns the unique list options with defaults and the
+// filter set by opts, checked and authorized.
+func (es *eventStoreSQLite) prepareUniqueList(ctx context.Context, opts []comby.EventStoreUniqueListOption) (comby.EventStoreUniqueListOptions, uniqueFilter, error) {
+	listOpts := comby.EventStoreUniqueListOptions{
+		DbField:   "tenant_uuid",
+		Offset:    0,
+		Limit:     100,
+		Ascending: true,
+	}
+	var filter uniqueFilter
+	uniqueFilters.Store(&listOpts, &filter)
+	defer uniqueFilters.Delete(&listOpts)
+	for _, opt := range opts {
+		if _, err := opt(&listOpts); err != nil {
+			return listOpts, filter, err
+		}
+	}
+
+	var check optionsCheck
+	if !uniqueListFields[listOpts.DbField] {
+		check.addf("field '%s' is not supported", listOpts.DbField)
+	}
+	check.page(listOpts.Offset, listOpts.Limit, 0)
+	if err := check.err(); err != nil {
+		return listOpts, filter, fmt.Errorf("'%s' failed to list unique values - %w", es.String(), err)
+	}
+	if err := es.authorizeUniqueList(ctx, listOpts); err != nil {
+		return listOpts, filter, err
+	}
+	return listOpts, filter, nil
+}
+
+func (es *eventStoreSQLite) authorizeUniqueList(ctx context.Context, listOpts comby.EventStoreUniqueListOptions) error {
+	req := AccessRequest{Operation: OperationList, TenantUuid: listOpts.TenantUuid}
+	if len(listOpts.Domain) > 0 {
+		req.Domains = []string{listOpts.Domain}
+	}
+	return es.authorize(ctx, req)
+}
+
+// UniqueValue is a distinct value of a field and the number of events having it.
+type UniqueValue struct {
+	Value string
+	Count int64
+}
+
+// UniqueListCounts lists the distinct values of the field of opts like
+// UniqueList, each with its number of events. The prefix and cursor options
+// of this package apply as well, so large sets of values, e.g. tens of
+// thousands of tenants, can be searched and paged through.
+func (es *eventStoreSQLite) UniqueListCounts(ctx context.Context, opts ...comby.EventStoreUniqueListOption) (_ []UniqueValue, _ int64, err error) {
+	defer wrapOpError(&err, "unique list", "events", time.Now(), func() (string, []string) {
+		return "", []string{"field=" + optionsOf(opts).DbField}
+	})
+	ctx, cancel := es.cfg().Timeouts.read(ctx)
+	defer cancel()
+	ctx, finish := es.watch(ctx, "unique list")
+	defer finish(&err)
+	listOpts, filter, err := es.prepareUniqueList(ctx, opts)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	field := listOpts.DbField
+	whereList, args := uniqueListWhere(listOpts, filter, es.cfg().Tenant)
+	pageWhere, pageArgs := filter.afterCondition(field, listOpts.Ascending, slices.Clone(whereList), slices.Clone(args))
+	direction := "ASC"
+	if !listOpts.Ascending {
+		direction = "DESC"
+	}
+	query := fmt.Sprintf("SELECT %s, COUNT(*) FROM events%s GROUP BY %s ORDER BY %s %s%s;",
+		field, whereSQL(pageWhere), field, field, direction, pageSQL(listOpts.Limit, listOpts.Offset))
+	rows, err := es.db.QueryContext(ctx, query, pageArgs...)
+	if err != nil {
+		return nil, 0, classifyError(err)
+	}
+	defer rows.Close()
+
+	var values []UniqueValue
+	for rows.Next() {
+		var value UniqueValue
+		if err := rows.Scan(&value.Value, &value.Count); err != nil {
+			return nil, 0, classifyError(err)
+		}
+		values = append(values, value)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, classifyError(err)
+	}
+
+	var total int64
+	totalQuery := fmt.Sprintf("SELECT COUNT(DISTINCT %s) FROM events%s;", field, whereSQL(whereList))
+	if err := es.db.QueryRowContext(ctx, totalQuery, args...).Scan(&total); err != nil {
+		return nil, 0, classifyError(err)
+	}
+	return values, total, nil
+}
+
+// UniqueRow is a distinct combination of values and the number of events having it.
+type UniqueRow struct {
+	Values []string
+	Count  int64
+}
+
+// UniqueListFields returns the distinct combinations of the given fields (e.g.
+// tenant_uuid and domain) with their number of events, ordered by the fields.
+// The filters, paging and order of opts apply, DbField is ignored, the
+// prefix and cursor options of this package are not supported. The total is
+// the number of distinct combinations.
+func (es *eventStoreSQLite) UniqueListFields(ctx context.Context, fields []string, opts ...comby.EventStoreUniqueListOption) ([]UniqueRow, int64, error) {
+	ctx, cancel := es.cfg().Timeouts.read(ctx)
+	defer cancel()
+	listOpts := comby.EventStoreUniqueListOptions{
+		Offset:    0,
+		Limit:     100,
+		Ascending: true,
+	}
+	var filter uniqueFilter
+	uniqueFilters.Store(&listOpts, &filter)
+	defer uniqueFilters.Delete(&listOpts)
+	for _, opt := range opts {
+		if _, err := opt(&listOpts); err != nil {
+			return nil, 0, err
+		}
+	}
+	if filter.HasPrefix || filter.HasAfter {
+		return nil, 0, fmt.Errorf("'%s' failed to list unique values - prefix and cursor require a single field", es.String())
+	}
+	if len(fields) == 0 {
+		return nil, 0, fmt.Errorf("'%s' failed to list unique values - no fields given", es.String())
+	}
+	for _, field := range fields {
+		if err := es.validateUniqueListField(field); err != nil {
+			return nil, 0, err
+		}
+	}
+	if err := es.authorizeUniqueList(ctx, listOpts); err != nil {
+		return nil, 0, err
+	}
+
+	whereList, args := uniqueListWhere(listOpts, filter, es.cfg().Tenant)
+	groupBySQL := strings.Join(fields, ", ")
+	direction := "ASC"
+	if !listOpts.Ascending {
+		direction = "DESC"
+	}
+	orderBy := make([]string, len(fields))
+	for i, field := range fields {
+		orderBy[i] = fmt.Sprintf("%s %s", field, direction)
+	}
+	query := fmt.Sprintf("SELECT %s, COUNT(*) FROM events%s GROUP BY %s ORDER BY %s LIMIT %d OFFSET %d;",
+		groupBySQL, whereSQL(whereList), groupBySQL, strings.Join(orderBy, ", "), listOpts.Limit, listOpts.Offset)
+	rows, err := es.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, 0, classifyError(err)
+	}
+	defer rows.Close()
+
+	var uniqueRows []UniqueRow
+	for rows.Next() {
+		row := UniqueRow{Values: make([]string, len(fields))}
+		dest := make([]any, 0, len(fields)+1)
+		for i := range row.Values {
+			dest = append(dest, &row.Values[i])
+		}
+		if err := rows.Scan(append(dest, &row.Count)...); err != nil {
+			return nil, 0, classifyError(err)
+		}
+		uniqueRows = append(uniqueRows, row)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, classifyError(err)
+	}
+
+	var total int64
+	totalQuery := fmt.Sprintf("SELECT COUNT(*) FROM (SELECT 1 FROM events%s GROUP BY %s);", whereSQL(whereList), groupBySQL)
+	if err := es.db.QueryRowContext(ctx, totalQuery, args...).Scan(&total); err != nil {
+		return nil, 0, classifyError(err)
+	}
+	return uniqueRows, total, nil
+}
+
+// uniqueListWhere returns the conditions of the unique list filters of field
+// DbField, except for the cursor.
+func uniqueListWhere(listOpts comby.EventStoreUniqueListOptions, filter uniqueFilter, boundTenant string) ([]string, []any) {
+	var whereList []string
+	var args []any
+	if len(listOpts.TenantUuid) > 0 {
+		whereList = append(whereList, "tenant_uuid=?")
+		args = append(args, listOpts.TenantUuid)
+	}
+	if len(listOpts.Domain) > 0 {
+		whereList = append(whereList, "domain=?")
+		args = append(args, listOpts.Domain)
+	}
+	if filter.HasPrefix && len(filter.Prefix) > 0 {
+		whereList = append(whereList, listOpts.DbField+" GLOB ?")
+		args = append(args, globEscaper.Replace(filter.Prefix)+"*")
+	}
+	return tenantCondition(boundTenant, whereList, args)
+}
@@ -0,0 +1,151 @@
+package store_test
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	store "github.com/gradientzero/comby-store-sqlite"
+	"github.com/gradientzero/comby/v3"
+)
+
+func TestUniqueListFields(t *testing.T) {
+	ctx := context.Background()
+	eventStore := store.NewEventStoreSQLite(filepath.Join(t.TempDir(), "unique.db"))
+	if err := eventStore.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer eventStore.Close(ctx)
+
+	for i, target := range [][2]string{
+		{"tenant-1", "domain-1"},
+		{"tenant-1", "domain-1"},
+		{"tenant-1", "domain-2"},
+		{"tenant-2", "domain-1"},
+	} {
+		evt := createTestEvent(target[0], target[1], int64(i+1), int64(i+1))
+		if err := eventStore.Create(ctx, comby.EventStoreCreateOptionWithEvent(evt)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	rows, total, err := eventStore.UniqueListFields(ctx, []string{"tenant_uuid", "domain"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []store.UniqueRow{
+		{Values: []string{"tenant-1", "domain-1"}, Count: 2},
+		{Values: []string{"tenant-1", "domain-2"}, Count: 1},
+		{Values: []string{"tenant-2", "domain-1"}, Count: 1},
+	}
+	if total != 3 || !reflect.DeepEqual(rows, want) {
+		t.Fatalf("unexpected unique rows (%d): %+v", total, rows)
+	}
+
+	rows, total, err = eventStore.UniqueListFields(ctx, []string{"domain"}, func(opts *comby.EventStoreUniqueListOptions) (*comby.EventStoreUniqueListOptions, error) {
+		opts.TenantUuid = "tenant-1"
+		opts.Ascending = false
+		opts.Limit = 1
+		return opts, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 2 || len(rows) != 1 || rows[0].Values[0] != "domain-2" {
+		t.Fatalf("unexpected filtered rows (%d): %+v", total, rows)
+	}
+
+	if _, _, err := eventStore.UniqueListFields(ctx, []string{"domain", "data_bytes"}); err == nil {
+		t.Fatal("expected unsupported field to fail")
+	}
+	_, _, err = eventStore.UniqueList(ctx, func(opts *comby.EventStoreUniqueListOptions) (*comby.EventStoreUniqueListOptions, error) {
+		opts.DbField = "domain FROM events; --"
+		return opts, nil
+	})
+	if err == nil {
+		t.Fatal("expected unique list with raw sql field to fail")
+	}
+}
+
+func TestUniqueListCounts(t *testing.T) {
+	ctx := context.Background()
+	eventStore := store.NewEventStoreSQLite(filepath.Join(t.TempDir(), "unique.db"))
+	if err := eventStore.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer eventStore.Close(ctx)
+
+	for i, tenantUuid := range []string{"acme-1", "acme-2", "acme-2", "beta-1", "acme_3", "acme*"} {
+		evt := createTestEvent(tenantUuid, "domain-1", int64(i+1), int64(i+1))
+		if err := eventStore.Create(ctx, comby.EventStoreCreateOptionWithEvent(evt)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	limit := func(limit int64) comby.EventStoreUniqueListOption {
+		return func(opts *comby.EventStoreUniqueListOptions) (*comby.EventStoreUniqueListOptions, error) {
+			opts.Limit = limit
+			return opts, nil
+		}
+	}
+
+	values, total, err := eventStore.UniqueListCounts(ctx, store.EventStoreUniqueListOptionPrefix("acme-"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []store.UniqueValue{{Value: "acme-1", Count: 1}, {Value: "acme-2", Count: 2}}
+	if total != 2 || !reflect.DeepEqual(values, want) {
+		t.Fatalf("unexpected values (%d): %+v", total, values)
+	}
+
+	// wildcards in the prefix match literally
+	values, total, err = eventStore.UniqueListCounts(ctx, store.EventStoreUniqueListOptionPrefix("acme*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 1 || len(values) != 1 || values[0].Value != "acme*" {
+		t.Fatalf("unexpected values with wildcard prefix (%d): %+v", total, values)
+	}
+
+	// page through all values with the cursor
+	var pages []string
+	var after []comby.EventStoreUniqueListOption
+	for {
+		values, total, err := eventStore.UniqueListCounts(ctx, append(after, limit(2))...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if total != 5 {
+			t.Fatalf("expected total of all values, got %d", total)
+		}
+		if len(values) == 0 {
+			break
+		}
+		for _, value := range values {
+			pages = append(pages, value.Value)
+		}
+		after = []comby.EventStoreUniqueListOption{store.EventStoreUniqueListOptionAfter(values[len(values)-1].Value)}
+	}
+	if want := []string{"acme*", "acme-1", "acme-2", "acme_3", "beta-1"}; !reflect.DeepEqual(pages, want) {
+		t.Fatalf("unexpected pages: %v", pages)
+	}
+
+	// UniqueList takes the same options, descending cursors go backwards
+	tenants, total, err := eventStore.UniqueList(ctx,
+		store.EventStoreUniqueListOptionPrefix("acme"),
+		store.EventStoreUniqueListOptionAfter("acme-2"),
+		func(opts *comby.EventStoreUniqueListOptions) (*comby.EventStoreUniqueListOptions, error) {
+			opts.Ascending = false
+			return opts, nil
+		})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 4 || !reflect.DeepEqual(tenants, []string{"acme-1", "acme*"}) {
+		t.Fatalf("unexpected tenants (%d): %v", total, tenants)
+	}
+
+	if _, _, err := eventStore.UniqueListFields(ctx, []string{"tenant_uuid"}, store.EventStoreUniqueListOptionPrefix("acme")); err == nil {
+		t.Fatal("expected prefix with unique list fields to fail")
+	}
+}
@@ -0,0 +1,138 @@
+package store
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/gradientzero/comby-store-sqlite/internal"
+	"github.com/gradientzero/comby/v3"
+)
+
+// UuidGenerator returns a new unique identifier, e.g. NewUuidV7 or NewULID.
+type UuidGenerator func() string
+
+// EventStoreSQLiteWithUuidGenerator assigns a uuid from gen to events created
+// without one. With time-sortable identifiers, ListAfterUuid pages through
+// events in creation order even when created_at values collide.
+func EventStoreSQLiteWithUuidGenerator(gen UuidGenerator) EventStoreSQLiteOption {
+	return func(c *eventStoreSQLiteConfig) { c.UuidGenerator = gen }
+}
+
+// CommandStoreSQLiteWithUuidGenerator is EventStoreSQLiteWithUuidGenerator for the command store.
+func CommandStoreSQLiteWithUuidGenerator(gen UuidGenerator) CommandStoreSQLiteOption {
+	return func(c *commandStoreSQLiteConfig) { c.UuidGenerator = gen }
+}
+
+// monotonicClock keeps the identifiers of one generator increasing within one
+// process, even within one millisecond.
+type monotonicClock struct {
+	mu  sync.Mutex
+	ms  int64
+	seq uint64
+}
+
+var uuidV7Clock, ulidClock monotonicClock
+
+// next returns the millisecond timestamp and counter of the next identifier.
+// The counter is random at the start of each millisecond and limited to bits.
+func (c *monotonicClock) next(bits uint) (int64, uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	limit := uint64(1)<<bits - 1
+	ms := time.Now().UnixMilli()
+	if ms > c.ms {
+		var b [8]byte
+		rand.Read(b[:])
+		// leave room for increments within the millisecond
+		c.ms, c.seq = ms, binary.BigEndian.Uint64(b[:])&(limit>>1)
+	} else if c.seq++; c.seq > limit {
+		c.ms, c.seq = c.ms+1, 0
+	}
+	return c.ms, c.seq
+}
+
+// NewUuidV7 returns a time-ordered UUID (RFC 9562 version 7). Identifiers
+// generated by one process sort lexicographically in generation order.
+func NewUuidV7() string {
+	ms, seq := uuidV7Clock.next(12)
+	var b [16]byte
+	rand.Read(b[8:])
+	b[0], b[1], b[2], b[3], b[4], b[5] = byte(ms>>40), byte(ms>>32), byte(ms>>24), byte(ms>>16), byte(ms>>8), byte(ms)
+	b[6] = 0x70 | byte(seq>>8)
+	b[7] = byte(seq)
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
+
+// crockford base32 alphabet of ULIDs
+const ulidAlphabet = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// NewULID returns a ULID, 26 characters which sort lexicographically in
+// generation order within one process.
+func NewULID() string {
+	ms, seq := ulidClock.next(32)
+	var b [16]byte
+	rand.Read(b[10:])
+	b[0], b[1], b[2], b[3], b[4], b[5] = byte(ms>>40), byte(ms>>32), byte(ms>>24), byte(ms>>16), byte(ms>>8), byte(ms)
+	binary.BigEndian.PutUint32(b[6:10], uint32(seq))
+
+	// 130 bits of output for 128 bits of input, the first character holds 3 bits
+	hi, lo := binary.BigEndian.Uint64(b[:8]), binary.BigEndian.Uint64(b[8:])
+	var out [26]byte
+	for i := len(out) - 1; i >= 0; i-- {
+		out[i] = ulidAlphabet[lo&31]
+		lo = lo>>5 | hi<<59
+		hi >>= 5
+	}
+	return string(out[:])
+}
+
+// ListAfterUuid lists events ordered by uuid, starting after afterUuid (from
+// the first event if empty). Pass the uuid of the last event of a page to get
+// the next one, descending lists continue before it. Offset, OrderBy and
+// Before/After filters of opts apply as in List, the total counts the events
+// remaining after the cursor.
+func (es *eventStoreSQLite) ListAfterUuid(ctx context.Context, afterUuid string, opts ...comby.EventStoreListOption) ([]comby.Event, int64, error) {
+	ctx, cancel := es.cfg().Timeouts.read(ctx)
+	defer cancel()
+	listOpts, filter, err := es.listOptions(opts)
+	if err != nil {
+		return nil, 0, err
+	}
+	if err := es.authorizeList(ctx, listOpts, filter); err != nil {
+		return nil, 0, err
+	}
+	listOpts.OrderBy = "uuid"
+	filter.AfterUuid = afterUuid
+	dbRecords, total, err := es.listRecords(ctx, es.db, "events", listOpts, filter)
+	if err != nil {
+		return nil, 0, classifyError(err)
+	}
+	evts, err := internal.DbEventsToBaseEvents(dbRecords)
+	if err != nil {
+		return nil, 0, err
+	}
+	return evts, total, nil
+}
+
+// ListAfterUuid lists commands ordered by uuid, starting after afterUuid, like
+// ListAfterUuid of the event store.
+func (cs *commandStoreSQLite) ListAfterUuid(ctx context.Context, afterUuid string, opts ...comby.CommandStoreListOption) ([]comby.Command, int64, error) {
+	ctx, cancel := cs.cfg().Timeouts.read(ctx)
+	defer cancel()
+	listOpts, filter, err := cs.listOptions(opts)
+	if err != nil {
+		return nil, 0, err
+	}
+	if err := cs.authorizeList(ctx, listOpts, filter); err != nil {
+		return nil, 0, err
+	}
+	listOpts.OrderBy = "uuid"
+	filter.AfterUuid = afterUuid
+	cmds, total, err := cs.list(ctx, cs.db, listOpts, filter)
+	return cmds, total, classifyError(err)
+}
@@ -0,0 +1,127 @@
+package store_test
+
+import (
+	"context"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"testing"
+
+	store "github.com/gradientzero/comby-store-sqlite"
+	"github.com/gradientzero/comby/v3"
+)
+
+func TestUuidGenerators(t *testing.T) {
+	for name, tc := range map[string]struct {
+		gen     store.UuidGenerator
+		pattern *regexp.Regexp
+	}{
+		"uuidv7": {store.NewUuidV7, regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-7[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)},
+		"ulid":   {store.NewULID, regexp.MustCompile(`^[0-7][0-9A-HJKMNP-TV-Z]{25}$`)},
+	} {
+		ids := make([]string, 10000)
+		for i := range ids {
+			ids[i] = tc.gen()
+			if !tc.pattern.MatchString(ids[i]) {
+				t.Fatalf("%s: malformed identifier %q", name, ids[i])
+			}
+			if i > 0 && ids[i] <= ids[i-1] {
+				t.Fatalf("%s: %q does not sort after %q", name, ids[i], ids[i-1])
+			}
+		}
+	}
+}
+
+func TestEventStoreListAfterUuid(t *testing.T) {
+	ctx := context.Background()
+	eventStore := store.NewEventStoreSQLite(filepath.Join(t.TempDir(), "uuid.db"))
+	eventStore.Configure(store.EventStoreSQLiteWithUuidGenerator(store.NewUuidV7))
+	if err := eventStore.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer eventStore.Close(ctx)
+
+	var uuids []string
+	for i := int64(1); i <= 5; i++ {
+		// equal timestamps, only the uuid orders the events
+		evt := createTestEvent("tenant-1", "domain-1", i, 100)
+		evt.SetEventUuid("")
+		if err := eventStore.Create(ctx, comby.EventStoreCreateOptionWithEvent(evt)); err != nil {
+			t.Fatal(err)
+		}
+		uuids = append(uuids, evt.GetEventUuid())
+	}
+	if !sort.StringsAreSorted(uuids) {
+		t.Fatalf("expected generated uuids in creation order: %v", uuids)
+	}
+
+	page := func(opts *comby.EventStoreListOptions) (*comby.EventStoreListOptions, error) {
+		opts.Limit = 2
+		return opts, nil
+	}
+	var listed []string
+	cursor := ""
+	for {
+		evts, total, err := eventStore.ListAfterUuid(ctx, cursor, page)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if total != int64(len(uuids)-len(listed)) {
+			t.Fatalf("expected %d remaining events, got %d", len(uuids)-len(listed), total)
+		}
+		if len(evts) == 0 {
+			break
+		}
+		for _, evt := range evts {
+			listed = append(listed, evt.GetEventUuid())
+		}
+		cursor = listed[len(listed)-1]
+	}
+	if len(listed) != len(uuids) {
+		t.Fatalf("expected %d listed events, got %d", len(uuids), len(listed))
+	}
+	for i := range uuids {
+		if listed[i] != uuids[i] {
+			t.Fatalf("unexpected order: %v", listed)
+		}
+	}
+
+	// descending lists continue before the cursor
+	evts, _, err := eventStore.ListAfterUuid(ctx, uuids[2], func(opts *comby.EventStoreListOptions) (*comby.EventStoreListOptions, error) {
+		opts.Ascending = false
+		return opts, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(evts) != 2 || evts[0].GetEventUuid() != uuids[1] || evts[1].GetEventUuid() != uuids[0] {
+		t.Fatalf("unexpected descending page: %v", evts)
+	}
+}
+
+func TestCommandStoreListAfterUuid(t *testing.T) {
+	ctx := context.Background()
+	commandStore := store.NewCommandStoreSQLite(filepath.Join(t.TempDir(), "uuid.db"))
+	commandStore.Configure(store.CommandStoreSQLiteWithUuidGenerator(store.NewULID))
+	if err := commandStore.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer commandStore.Close(ctx)
+
+	var uuids []string
+	for i := int64(1); i <= 3; i++ {
+		cmd := createTestCommand("tenant-1", "domain-1", 100)
+		cmd.SetCommandUuid("")
+		if err := commandStore.Create(ctx, comby.CommandStoreCreateOptionWithCommand(cmd)); err != nil {
+			t.Fatal(err)
+		}
+		uuids = append(uuids, cmd.GetCommandUuid())
+	}
+	cmds, total, err := commandStore.ListAfterUuid(ctx, uuids[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 2 || len(cmds) != 2 || cmds[0].GetCommandUuid() != uuids[1] || cmds[1].GetCommandUuid() != uuids[2] {
+		t.Fatalf("unexpected page after %s: total %d, %v", uuids[0], total, cmds)
+	}
+}
@@ -0,0 +1,179 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/gradientzero/comby/v3"
+)
+
+// ErrInvalidOptions is matched by every OptionsError.
+var ErrInvalidOptions = errors.New("invalid options")
+
+// OptionsError is returned when the options of a list, get or delete call are
+// invalid, before the database is queried. It lists all problems found, so
+// they can be fixed at once instead of one per call.
+type OptionsError struct {
+	Problems []string
+}
+
+func (e *OptionsError) Error() string {
+	return fmt.Sprintf("%s: %s", ErrInvalidOptions, strings.Join(e.Problems, "; "))
+}
+
+func (e *OptionsError) Is(target error) bool {
+	return target == ErrInvalidOptions
+}
+
+// optionsCheck collects the problems of options.
+type optionsCheck []string
+
+func (c *optionsCheck) addf(format string, args ...any) {
+	*c = append(*c, fmt.Sprintf(format, args...))
+}
+
+// err returns an OptionsError if problems were found, otherwise nil.
+func (c optionsCheck) err() error {
+	if len(c) == 0 {
+		return nil
+	}
+	return &OptionsError{Problems: c}
+}
+
+// page checks offset and limit, a limit of -1 lists all records. Limits above
+// maxLimit are rejected unless it is 0.
+func (c *optionsCheck) page(offset, limit, maxLimit int64) {
+	if offset < 0 {
+		c.addf("offset %d is negative", offset)
+	}
+	if limit < -1 {
+		c.addf("limit %d is negative", limit)
+	}
+	if maxLimit > 0 && limit > maxLimit {
+		c.addf("limit %d exceeds the maximum of %d", limit, maxLimit)
+	}
+}
+
+// timeRange checks the exclusive created_at bounds, -1 leaves a side open.
+func (c *optionsCheck) timeRange(before, after int64) {
+	if before < -1 {
+		c.addf("before %d is negative", before)
+	}
+	if after < -1 {
+		c.addf("after %d is negative", after)
+	}
+	if before >= 0 && after >= 0 && before-after <= 1 {
+		c.addf("after %d and before %d match no records", after, before)
+	}
+}
+
+func (c *optionsCheck) orderBy(orderBy string, columns []string) {
+	if len(orderBy) > 0 && !slices.Contains(columns, orderBy) {
+		c.addf("order by '%s' is not one of %s", orderBy, strings.Join(columns, ", "))
+	}
+}
+
+// filter checks the filter against itself and the tenant, domain and data
+// type of the list options.
+func (c *optionsCheck) filter(f listFilter, tenantUuid string, domains []string, dataType string) {
+	if f.HasCreatedFrom && f.HasCreatedTo && f.CreatedFrom > f.CreatedTo {
+		c.addf("time range from %d is after to %d", f.CreatedFrom, f.CreatedTo)
+	}
+	if len(tenantUuid) > 0 {
+		if len(f.TenantUuids) > 0 && !slices.Contains(f.TenantUuids, tenantUuid) {
+			c.addf("tenant '%s' is not one of the filtered tenants", tenantUuid)
+		}
+		if slices.Contains(f.ExcludeTenantUuids, tenantUuid) {
+			c.addf("tenant '%s' is excluded", tenantUuid)
+		}
+	}
+	for _, tenant := range f.TenantUuids {
+		if slices.Contains(f.ExcludeTenantUuids, tenant) {
+			c.addf("tenant '%s' is both filtered and excluded", tenant)
+		}
+	}
+	for _, domain := range append(slices.Clone(domains), f.Domains...) {
+		if f.contains(f.ExcludeDomains, domain) {
+			c.addf("domain '%s' is both filtered and excluded", domain)
+		}
+	}
+	if len(dataType) > 0 && f.contains(f.ExcludeDataTypes, dataType) {
+		c.addf("data type '%s' is excluded", dataType)
+	}
+}
+
+// contains reports whether values contain the domain or data type value, as
+// compared by the filter.
+func (f listFilter) contains(values []string, value string) bool {
+	return slices.ContainsFunc(values, func(v string) bool {
+		return v == value || f.CaseInsensitive && asciiLower(v) == asciiLower(value)
+	})
+}
+
+// asciiLower lowers ASCII letters only, like the NOCASE collation of SQLite.
+func asciiLower(s string) string {
+	b := []byte(s)
+	for i, c := range b {
+		if 'A' <= c && c <= 'Z' {
+			b[i] = c + 'a' - 'A'
+		}
+	}
+	return string(b)
+}
+
+// columns events can be ordered by
+var eventOrderByColumns = []string{
+	"id", "instance_id", "uuid", "tenant_uuid", "workspace_uuid", "command_uuid", "domain",
+	"aggregate_uuid", "version", "created_at", "data_type", "data_size",
+}
+
+// columns commands can be ordered by
+var commandOrderByColumns = []string{
+	"id", "instance_id", "uuid", "tenant_uuid", "workspace_uuid", "domain",
+	"created_at", "data_type", "status", "processed_at",
+}
+
+// IsEventOrderByColumn reports whether events can be ordered by column.
+func IsEventOrderByColumn(column string) bool {
+	return slices.Contains(eventOrderByColumns, column)
+}
+
+// IsCommandOrderByColumn reports whether commands can be ordered by column.
+func IsCommandOrderByColumn(column string) bool {
+	return slices.Contains(commandOrderByColumns, column)
+}
+
+func checkEventListOptions(listOpts comby.EventStoreListOptions, filter eventFilter, maxLimit int64) error {
+	var check optionsCheck
+	check.orderBy(listOpts.OrderBy, eventOrderByColumns)
+	check.page(listOpts.Offset, listOpts.Limit, maxLimit)
+	check.timeRange(listOpts.Before, listOpts.After)
+	check.filter(filter.listFilter, listOpts.TenantUuid, listOpts.Domains, listOpts.DataType)
+	return check.err()
+}
+
+func checkCommandListOptions(listOpts comby.CommandStoreListOptions, filter commandFilter, maxLimit int64) error {
+	var check optionsCheck
+	check.orderBy(listOpts.OrderBy, commandOrderByColumns)
+	check.page(listOpts.Offset, listOpts.Limit, maxLimit)
+	check.timeRange(listOpts.Before, listOpts.After)
+	var domains []string
+	if len(listOpts.Domain) > 0 {
+		domains = []string{listOpts.Domain}
+		if len(filter.Domains) > 0 && !filter.contains(filter.Domains, listOpts.Domain) {
+			check.addf("domain '%s' is not one of the filtered domains", listOpts.Domain)
+		}
+	}
+	check.filter(filter.listFilter, listOpts.TenantUuid, domains, listOpts.DataType)
+	return check.err()
+}
+
+// requireUuid returns an OptionsError if uuid is empty.
+func requireUuid(kind, uuid string) error {
+	if len(uuid) == 0 {
+		return &OptionsError{Problems: []string{kind + " uuid is required"}}
+	}
+	return nil
+}
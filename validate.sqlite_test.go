@@ -0,0 +1,116 @@
+package store_test
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	store "github.com/gradientzero/comby-store-sqlite"
+	"github.com/gradientzero/comby/v3"
+)
+
+func TestEventStoreListInvalidOptions(t *testing.T) {
+	ctx := context.Background()
+	eventStore := store.NewEventStoreSQLite(filepath.Join(t.TempDir(), "validate.db"))
+	if err := eventStore.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer eventStore.Close(ctx)
+
+	invalid := func(opts *comby.EventStoreListOptions) (*comby.EventStoreListOptions, error) {
+		opts.Limit, opts.Offset = -5, -1
+		opts.After, opts.Before = 20, 10
+		opts.TenantUuid = "tenant-1"
+		return opts, nil
+	}
+	_, _, err := eventStore.List(ctx,
+		invalid,
+		comby.EventStoreListOptionOrderBy("created_at; DROP TABLE events"),
+		store.EventStoreListOptionExcludeTenantUuids("tenant-1"),
+	)
+	var optionsErr *store.OptionsError
+	if !errors.As(err, &optionsErr) || !errors.Is(err, store.ErrInvalidOptions) {
+		t.Fatalf("expected options error, got %v", err)
+	}
+	if len(optionsErr.Problems) != 5 {
+		t.Fatalf("expected 5 problems, got %q", optionsErr.Problems)
+	}
+	if !strings.Contains(err.Error(), "order by") || !strings.Contains(err.Error(), "tenant 'tenant-1' is excluded") {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	if _, _, err := eventStore.List(ctx, comby.EventStoreListOptionOrderBy("version")); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCommandStoreListInvalidOptions(t *testing.T) {
+	ctx := context.Background()
+	commandStore := store.NewCommandStoreSQLite(filepath.Join(t.TempDir(), "validate.db"))
+	if err := commandStore.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer commandStore.Close(ctx)
+
+	domain := func(opts *comby.CommandStoreListOptions) (*comby.CommandStoreListOptions, error) {
+		opts.Domain = "orders"
+		return opts, nil
+	}
+	_, _, err := commandStore.List(ctx,
+		domain,
+		store.CommandStoreListOptionDomains("billing"),
+		store.CommandStoreListOptionExcludeDomains("orders"),
+		store.CommandStoreListOptionFromInclusive(20),
+		store.CommandStoreListOptionToInclusive(10),
+	)
+	var optionsErr *store.OptionsError
+	if !errors.As(err, &optionsErr) {
+		t.Fatalf("expected options error, got %v", err)
+	}
+	if len(optionsErr.Problems) != 3 {
+		t.Fatalf("expected 3 problems, got %q", optionsErr.Problems)
+	}
+	// the same checks apply to the other list functions
+	if _, _, err := commandStore.ListPage(ctx, comby.CommandStoreListOptionOrderBy("data_bytes")); !errors.Is(err, store.ErrInvalidOptions) {
+		t.Fatalf("expected options error, got %v", err)
+	}
+}
+
+func TestStoreGetDeleteWithoutUuid(t *testing.T) {
+	ctx := context.Background()
+	eventStore := store.NewEventStoreSQLite(filepath.Join(t.TempDir(), "events.db"))
+	if err := eventStore.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer eventStore.Close(ctx)
+	commandStore := store.NewCommandStoreSQLite(filepath.Join(t.TempDir(), "commands.db"))
+	if err := commandStore.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer commandStore.Close(ctx)
+
+	if _, err := eventStore.Get(ctx); !errors.Is(err, store.ErrInvalidOptions) {
+		t.Fatalf("expected options error, got %v", err)
+	}
+	if err := eventStore.Delete(ctx); !errors.Is(err, store.ErrInvalidOptions) {
+		t.Fatalf("expected options error, got %v", err)
+	}
+	if _, err := commandStore.Get(ctx); !errors.Is(err, store.ErrInvalidOptions) {
+		t.Fatalf("expected options error, got %v", err)
+	}
+	if err := commandStore.Delete(ctx); !errors.Is(err, store.ErrInvalidOptions) {
+		t.Fatalf("expected options error, got %v", err)
+	}
+
+	negative := func(opts *comby.EventStoreUniqueListOptions) (*comby.EventStoreUniqueListOptions, error) {
+		opts.DbField, opts.Offset = "data_bytes", -1
+		return opts, nil
+	}
+	_, _, err := eventStore.UniqueList(ctx, negative)
+	var optionsErr *store.OptionsError
+	if !errors.As(err, &optionsErr) || len(optionsErr.Problems) != 2 {
+		t.Fatalf("expected options error with 2 problems, got %v", err)
+	}
+}
@@ -0,0 +1,97 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/gradientzero/comby/v3"
+)
+
+// ErrValidationFailed is matched by every ValidationError.
+var ErrValidationFailed = errors.New("payload validation failed")
+
+// ValidationError is returned by Create for an event rejected by a payload
+// validator. Validators may return it themselves to name the offending field,
+// other errors are wrapped into one.
+type ValidationError struct {
+	// filled in by the store
+	DataType  string
+	EventUuid string
+	// field the problem was found at, empty for the payload as a whole
+	Field   string
+	Message string
+	// error returned by the validator, if it was no ValidationError
+	Err error
+}
+
+func (e *ValidationError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s: '%s' event '%s'", ErrValidationFailed, e.DataType, e.EventUuid)
+	if len(e.Field) > 0 {
+		fmt.Fprintf(&b, " field '%s'", e.Field)
+	}
+	fmt.Fprintf(&b, ": %s", e.Message)
+	return b.String()
+}
+
+func (e *ValidationError) Is(target error) bool {
+	return target == ErrValidationFailed
+}
+
+func (e *ValidationError) Unwrap() error {
+	return e.Err
+}
+
+// PayloadValidator checks an event before it is inserted. payload is the
+// domain event of evt if it is set, otherwise its JSON payload decoded into
+// maps, slices and scalars with numbers as json.Number.
+type PayloadValidator func(ctx context.Context, evt comby.Event, payload any) error
+
+// EventStoreSQLiteWithPayloadValidator invokes validator on Create for events
+// of the data type, before they are encrypted and inserted. Validators of a
+// data type run in the order they were added, the first error rejects the
+// event. Unlike schemas of the SchemaRegistry, validators can enforce
+// invariants spanning fields, e.g. that an end date follows the start date.
+func EventStoreSQLiteWithPayloadValidator(dataType string, validator PayloadValidator) EventStoreSQLiteOption {
+	return func(c *eventStoreSQLiteConfig) {
+		// stores may be configured while creating events with a copy of the config
+		validators := maps.Clone(c.PayloadValidators)
+		if validators == nil {
+			validators = map[string][]PayloadValidator{}
+		}
+		validators[dataType] = append(slices.Clone(validators[dataType]), validator)
+		c.PayloadValidators = validators
+	}
+}
+
+// runPayloadValidators runs the validators of the data type of the record.
+func runPayloadValidators(ctx context.Context, validators []PayloadValidator, evt comby.Event, dataType, eventUuid string, data []byte) error {
+	payload := evt.GetDomainEvt()
+	if payload == nil {
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.UseNumber()
+		if err := decoder.Decode(&payload); err != nil {
+			return &ValidationError{DataType: dataType, EventUuid: eventUuid, Message: "payload is no JSON", Err: err}
+		}
+	}
+	for _, validator := range validators {
+		err := validator(ctx, evt, payload)
+		if err == nil {
+			continue
+		}
+		var validationErr *ValidationError
+		if errors.As(err, &validationErr) {
+			rejected := *validationErr
+			rejected.DataType, rejected.EventUuid = dataType, eventUuid
+			return &rejected
+		}
+		return &ValidationError{DataType: dataType, EventUuid: eventUuid, Message: err.Error(), Err: err}
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+package store_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	store "github.com/gradientzero/comby-store-sqlite"
+	"github.com/gradientzero/comby/v3"
+)
+
+type orderPlaced struct {
+	Amount int `json:"amount"`
+}
+
+func TestEventStorePayloadValidator(t *testing.T) {
+	ctx := context.Background()
+	errNoTenant := errors.New("tenant is required")
+	eventStore := store.NewEventStoreSQLite(filepath.Join(t.TempDir(), "events.db"))
+	eventStore.Configure(
+		store.EventStoreSQLiteWithPayloadValidator("OrderPlaced", func(ctx context.Context, evt comby.Event, payload any) error {
+			if len(evt.GetTenantUuid()) == 0 {
+				return errNoTenant
+			}
+			return nil
+		}),
+		store.EventStoreSQLiteWithPayloadValidator("OrderPlaced", func(ctx context.Context, evt comby.Event, payload any) error {
+			var amount int64
+			switch p := payload.(type) {
+			case *orderPlaced:
+				amount = int64(p.Amount)
+			case map[string]any:
+				amount, _ = p["amount"].(json.Number).Int64()
+			}
+			if amount <= 0 {
+				return &store.ValidationError{Field: "amount", Message: "must be positive"}
+			}
+			return nil
+		}),
+	)
+	if err := eventStore.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer eventStore.Close(ctx)
+
+	create := func(tenantUuid, dataType string, domainEvt any, payload string) (comby.Event, error) {
+		evt := createTestEvent(tenantUuid, "domain-1", 1, 100)
+		evt.SetDomainEvtName(dataType)
+		evt.SetDomainEvt(domainEvt)
+		evt.SetDomainEvtBytes([]byte(payload))
+		return evt, eventStore.Create(ctx, comby.EventStoreCreateOptionWithEvent(evt))
+	}
+	if _, err := create("tenant-1", "OrderPlaced", nil, `{"amount": 5}`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := create("tenant-1", "OrderPlaced", &orderPlaced{Amount: 5}, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	evt, err := create("tenant-1", "OrderPlaced", nil, `{"amount": 0}`)
+	var validationErr *store.ValidationError
+	if !errors.Is(err, store.ErrValidationFailed) || !errors.As(err, &validationErr) {
+		t.Fatalf("expected validation error, got %v", err)
+	}
+	if validationErr.Field != "amount" || validationErr.DataType != "OrderPlaced" || validationErr.EventUuid != evt.GetEventUuid() {
+		t.Fatalf("unexpected validation error %+v", validationErr)
+	}
+	if _, err := create("tenant-1", "OrderPlaced", &orderPlaced{Amount: -1}, ""); !errors.Is(err, store.ErrValidationFailed) {
+		t.Fatalf("expected validation error, got %v", err)
+	}
+	// errors of validators are wrapped, the first failing validator rejects
+	if _, err := create("", "OrderPlaced", nil, `{"amount": 0}`); !errors.Is(err, errNoTenant) || !errors.As(err, &validationErr) {
+		t.Fatalf("expected wrapped validator error, got %v", err)
+	}
+	if _, err := create("tenant-1", "OrderPlaced", nil, `not json`); !errors.Is(err, store.ErrValidationFailed) {
+		t.Fatalf("expected validation error, got %v", err)
+	}
+	// other data types are not validated
+	if _, err := create("tenant-1", "OrderShipped", nil, `{"amount": 0}`); err != nil {
+		t.Fatal(err)
+	}
+	if total := eventStore.Total(ctx); total != 3 {
+		t.Fatalf("expected 3 events, got %d", total)
+	}
+}
@@ -0,0 +1,86 @@
+package store
+
+import (
+	"context"
+	"sync"
+
+	"github.com/gradientzero/comby/v3"
+)
+
+// EventStoreSQLiteWithAssignedVersions lets Create number the events of an
+// aggregate: events created with version 0 get the version following the
+// latest event of their aggregate, set on the passed event once the write is
+// committed, so it can be read back with GetVersion. Writes which fail or are
+// rolled back leave the version at 0. The latest version is read within the
+// insert transaction while the store holds its write lock, which removes the
+// race of reading it first in the application. Versions of events removed by
+// EventArchiver.Prune are not handed out again. Events with a version are
+// stored as given, events without aggregate uuid are not numbered.
+func EventStoreSQLiteWithAssignedVersions() EventStoreSQLiteOption {
+	return func(c *eventStoreSQLiteConfig) { c.AssignVersions = true }
+}
+
+// pruned_versions keeps the highest version of each aggregate whose events
+// were pruned, so numbering continues after them
+var prunedVersionTables = []strictTable{
+	{
+		name: "pruned_versions",
+		columns: `aggregate_uuid TEXT NOT NULL PRIMARY KEY,
+		version INTEGER NOT NULL`,
+		copyColumns: `aggregate_uuid, version`,
+	},
+}
+
+// nextVersion returns the version following the latest event of the aggregate
+// of evt, stored or pruned, and the version of evt if it has one already.
+func nextVersion(ctx context.Context, q queryer, evt comby.Event) (int64, error) {
+	if evt.GetVersion() != 0 || len(evt.GetAggregateUuid()) == 0 {
+		return evt.GetVersion(), nil
+	}
+	var version int64
+	query := `SELECT MAX(
+		COALESCE((SELECT MAX(version) FROM events WHERE aggregate_uuid=?), 0),
+		COALESCE((SELECT version FROM pruned_versions WHERE aggregate_uuid=?), 0)
+	) + 1;`
+	if err := q.QueryRowContext(ctx, query, evt.GetAggregateUuid(), evt.GetAggregateUuid()).Scan(&version); err != nil {
+		return 0, classifyError(err)
+	}
+	return version, nil
+}
+
+// markPrunedVersion raises the pruned version of the aggregate of the stored
+// event with uuid to its version. It must run before the event is deleted.
+func markPrunedVersion(ctx context.Context, q queryer, eventUuid string) error {
+	query := `INSERT INTO pruned_versions (aggregate_uuid, version)
+		SELECT aggregate_uuid, version FROM event_records WHERE uuid=? AND aggregate_uuid<>''
+		ON CONFLICT(aggregate_uuid) DO UPDATE SET version=MAX(version, excluded.version);`
+	_, err := q.ExecContext(ctx, query, eventUuid)
+	return err
+}
+
+// versionAssignments collects the versions assigned within one transaction.
+// They are set on the events once it is committed.
+type versionAssignments struct {
+	mu       sync.Mutex
+	events   []comby.Event
+	versions []int64
+}
+
+func (v *versionAssignments) add(evt comby.Event, version int64) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.events = append(v.events, evt)
+	v.versions = append(v.versions, version)
+}
+
+// apply sets the collected versions if committed is true and forgets them.
+func (v *versionAssignments) apply(committed bool) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if committed {
+		for i, evt := range v.events {
+			evt.SetVersion(v.versions[i])
+		}
+	}
+	v.events, v.versions = nil, nil
+}
@@ -0,0 +1,173 @@
+package store_test
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	store "github.com/gradientzero/comby-store-sqlite"
+	"github.com/gradientzero/comby/v3"
+)
+
+func TestEventStoreAssignedVersions(t *testing.T) {
+	ctx := context.Background()
+	eventStore := store.NewEventStoreSQLite(filepath.Join(t.TempDir(), "versions.db"))
+	eventStore.Configure(store.EventStoreSQLiteWithAssignedVersions(), store.EventStoreSQLiteWithStrictValidation())
+	if err := eventStore.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer eventStore.Close(ctx)
+
+	newEvent := func(aggregateUuid string) comby.Event {
+		evt := createTestEvent("tenant-1", "domain-1", 0, 1)
+		evt.SetAggregateUuid(aggregateUuid)
+		return evt
+	}
+
+	// concurrent writers of one aggregate get distinct versions
+	const n = 20
+	var wg sync.WaitGroup
+	versions := make(chan int64, n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			evt := newEvent("aggregate-1")
+			if err := eventStore.Create(ctx, comby.EventStoreCreateOptionWithEvent(evt)); err != nil {
+				t.Error(err)
+				return
+			}
+			versions <- evt.GetVersion()
+		}()
+	}
+	wg.Wait()
+	close(versions)
+	seen := map[int64]bool{}
+	for version := range versions {
+		if version < 1 || version > n || seen[version] {
+			t.Fatalf("unexpected version %d", version)
+		}
+		seen[version] = true
+	}
+	if len(seen) != n {
+		t.Fatalf("expected %d versions, got %d", n, len(seen))
+	}
+
+	// aggregates are numbered independently, given versions are kept
+	other := newEvent("aggregate-2")
+	if err := eventStore.Create(ctx, comby.EventStoreCreateOptionWithEvent(other)); err != nil {
+		t.Fatal(err)
+	}
+	if other.GetVersion() != 1 {
+		t.Fatalf("expected version 1 of another aggregate, got %d", other.GetVersion())
+	}
+	given := newEvent("aggregate-2")
+	given.SetVersion(5)
+	if err := eventStore.Create(ctx, comby.EventStoreCreateOptionWithEvent(given)); err != nil {
+		t.Fatal(err)
+	}
+	next := newEvent("aggregate-2")
+	if err := eventStore.Create(ctx, comby.EventStoreCreateOptionWithEvent(next)); err != nil {
+		t.Fatal(err)
+	}
+	if given.GetVersion() != 5 || next.GetVersion() != 6 {
+		t.Fatalf("unexpected versions %d and %d", given.GetVersion(), next.GetVersion())
+	}
+
+	stored, err := eventStore.Get(ctx, comby.EventStoreGetOptionWithEventUuid(next.GetEventUuid()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.GetVersion() != 6 {
+		t.Fatalf("expected stored version 6, got %d", stored.GetVersion())
+	}
+}
+
+func TestEventStoreAssignedVersionsAfterPrune(t *testing.T) {
+	ctx := context.Background()
+	eventStore := store.NewEventStoreSQLite(filepath.Join(t.TempDir(), "versions.db"))
+	eventStore.Configure(store.EventStoreSQLiteWithAssignedVersions())
+	if err := eventStore.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer eventStore.Close(ctx)
+
+	create := func(createdAt int64) comby.Event {
+		evt := createTestEvent("tenant-1", "domain-1", 0, createdAt)
+		evt.SetAggregateUuid("aggregate-1")
+		if err := eventStore.Create(ctx, comby.EventStoreCreateOptionWithEvent(evt)); err != nil {
+			t.Fatal(err)
+		}
+		return evt
+	}
+	for i := int64(1); i <= 3; i++ {
+		create(i * 100)
+	}
+
+	// all events of the aggregate are archived and pruned
+	archiver, err := store.NewEventArchiver(eventStore, newMemoryUploader())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := archiver.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	segment, err := archiver.Archive(ctx, 0, 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n, err := archiver.Prune(ctx, segment.Key); err != nil || n != 3 {
+		t.Fatalf("expected 3 pruned events, got %d, %v", n, err)
+	}
+	if evt := create(1000); evt.GetVersion() != 4 {
+		t.Fatalf("expected version 4 after the pruned events, got %d", evt.GetVersion())
+	}
+}
+
+func TestEventStoreAssignedVersionsRolledBack(t *testing.T) {
+	ctx := context.Background()
+	eventStore := store.NewEventStoreSQLite(filepath.Join(t.TempDir(), "versions.db"))
+	eventStore.Configure(store.EventStoreSQLiteWithAssignedVersions())
+	if err := eventStore.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer eventStore.Close(ctx)
+
+	// events of a rolled back transaction keep version 0
+	errAbort := errors.New("abort")
+	evt := createTestEvent("tenant-1", "domain-1", 0, 100)
+	evt.SetAggregateUuid("aggregate-1")
+	if err := eventStore.WithTx(ctx, func(tx store.EventStoreTx) error {
+		if err := tx.Create(ctx, comby.EventStoreCreateOptionWithEvent(evt)); err != nil {
+			return err
+		}
+		return errAbort
+	}); !errors.Is(err, errAbort) {
+		t.Fatalf("expected abort error, got %v", err)
+	}
+	if evt.GetVersion() != 0 {
+		t.Fatalf("expected version 0 after rollback, got %d", evt.GetVersion())
+	}
+
+	// a failed create does not number the event either
+	if err := eventStore.Create(ctx, comby.EventStoreCreateOptionWithEvent(createTestEvent("tenant-1", "domain-1", 1, 100))); err != nil {
+		t.Fatal(err)
+	}
+	duplicate := createTestEvent("tenant-1", "domain-1", 0, 200)
+	duplicate.SetAggregateUuid("aggregate-1")
+	duplicate.SetEventUuid(evt.GetEventUuid())
+	if err := eventStore.Create(ctx, comby.EventStoreCreateOptionWithEvent(evt)); err != nil {
+		t.Fatal(err)
+	}
+	if evt.GetVersion() != 2 {
+		t.Fatalf("expected version 2, got %d", evt.GetVersion())
+	}
+	if err := eventStore.Create(ctx, comby.EventStoreCreateOptionWithEvent(duplicate)); err == nil {
+		t.Fatal("expected duplicate uuid to fail")
+	}
+	if duplicate.GetVersion() != 0 {
+		t.Fatalf("expected version 0 after failed create, got %d", duplicate.GetVersion())
+	}
+}
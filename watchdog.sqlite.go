@@ -0,0 +1,150 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// ErrQueryTimeout is matched by errors of operations cancelled by the query
+// watchdog, see EventStoreSQLiteWithQueryWatchdog.
+var ErrQueryTimeout = errors.New("query exceeded the maximum runtime")
+
+// number of entries kept in the slow query log
+const slowQueryLogSize = 100
+
+// SlowQuery is an entry of the slow query log.
+type SlowQuery struct {
+	// operation and table, e.g. "list" and "events"
+	Op        string
+	Table     string
+	StartedAt time.Time
+	Elapsed   time.Duration
+	// the watchdog cancelled the operation after its maximum runtime
+	Cancelled bool
+	// error of the operation, empty if it succeeded
+	Error string
+}
+
+// EventStoreSQLiteWithQueryWatchdog cancels Get, List, UniqueList, Create,
+// Update and Delete once they run longer than maxRuntime, so one runaway scan
+// can not hold a connection, or the write lock, indefinitely. The SQLite
+// statement is interrupted and the operation fails with ErrQueryTimeout.
+// Cancelled operations and those running at least slowThreshold are recorded
+// in the slow query log, see SlowQueries. Zero disables either limit.
+func EventStoreSQLiteWithQueryWatchdog(maxRuntime, slowThreshold time.Duration) EventStoreSQLiteOption {
+	return func(c *eventStoreSQLiteConfig) {
+		c.Watchdog = &queryWatchdog{MaxRuntime: maxRuntime, SlowThreshold: slowThreshold}
+	}
+}
+
+// CommandStoreSQLiteWithQueryWatchdog cancels Get, List, Create, Update and
+// Delete running longer than maxRuntime, see EventStoreSQLiteWithQueryWatchdog.
+func CommandStoreSQLiteWithQueryWatchdog(maxRuntime, slowThreshold time.Duration) CommandStoreSQLiteOption {
+	return func(c *commandStoreSQLiteConfig) {
+		c.Watchdog = &queryWatchdog{MaxRuntime: maxRuntime, SlowThreshold: slowThreshold}
+	}
+}
+
+// queryWatchdog bounds the runtime of operations and keeps the slow query
+// log. A nil watchdog watches nothing.
+type queryWatchdog struct {
+	MaxRuntime    time.Duration
+	SlowThreshold time.Duration
+
+	mu sync.Mutex
+	// ring of the latest entries, next is the index of the oldest once full
+	log  []SlowQuery
+	next int
+}
+
+// watch starts watching an operation. The returned func must be deferred
+// with the error of the operation, it stops the watch, marks the error of a
+// cancelled operation and records slow ones.
+func (w *queryWatchdog) watch(ctx context.Context, logger *slog.Logger, op, table string) (context.Context, func(err *error)) {
+	if w == nil || (w.MaxRuntime <= 0 && w.SlowThreshold <= 0) {
+		return ctx, func(*error) {}
+	}
+	ctx, cancel := context.WithCancelCause(ctx)
+	start := time.Now()
+	var timer *time.Timer
+	if w.MaxRuntime > 0 {
+		timer = time.AfterFunc(w.MaxRuntime, func() { cancel(ErrQueryTimeout) })
+	}
+	return ctx, func(err *error) {
+		elapsed := time.Since(start)
+		cancelled := timer != nil && !timer.Stop() && errors.Is(context.Cause(ctx), ErrQueryTimeout)
+		cancel(nil)
+		if cancelled && *err != nil {
+			*err = fmt.Errorf("%w after %s - %w", ErrQueryTimeout, elapsed.Round(time.Millisecond), *err)
+		}
+		if !cancelled && (w.SlowThreshold <= 0 || elapsed < w.SlowThreshold) {
+			return
+		}
+		entry := SlowQuery{Op: op, Table: table, StartedAt: start, Elapsed: elapsed, Cancelled: cancelled}
+		if *err != nil {
+			entry.Error = (*err).Error()
+		}
+		w.record(entry)
+		logger.WarnContext(ctx, "slow query", "op", op, "table", table, "elapsed", elapsed, "cancelled", cancelled)
+	}
+}
+
+func (w *queryWatchdog) record(entry SlowQuery) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if len(w.log) < slowQueryLogSize {
+		w.log = append(w.log, entry)
+		return
+	}
+	w.log[w.next] = entry
+	w.next = (w.next + 1) % slowQueryLogSize
+}
+
+// entries returns the slow query log, oldest first.
+func (w *queryWatchdog) entries() []SlowQuery {
+	if w == nil {
+		return nil
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return append(append([]SlowQuery{}, w.log[w.next:]...), w.log[:w.next]...)
+}
+
+// SlowQueries returns the latest operations which were slow or cancelled by
+// the query watchdog, oldest first.
+func (es *eventStoreSQLite) SlowQueries() []SlowQuery {
+	return es.cfg().Watchdog.entries()
+}
+
+func (es *eventStoreSQLite) watch(ctx context.Context, op string) (context.Context, func(err *error)) {
+	config := es.cfg()
+	ctx, finish := config.Watchdog.watch(ctx, loggerOrDiscard(config.Logger), op, "events")
+	return ctx, countOp(finish, config.Metrics, es.opts().ReadOnly, op)
+}
+
+// SlowQueries returns the slow query log of the command store.
+func (cs *commandStoreSQLite) SlowQueries() []SlowQuery {
+	return cs.cfg().Watchdog.entries()
+}
+
+func (cs *commandStoreSQLite) watch(ctx context.Context, op string) (context.Context, func(err *error)) {
+	config := cs.cfg()
+	ctx, finish := config.Watchdog.watch(ctx, loggerOrDiscard(config.Logger), op, "commands")
+	return ctx, countOp(finish, config.Metrics, cs.opts().ReadOnly, op)
+}
+
+// countOp extends finish to count the operation in the metrics of writable
+// stores.
+func countOp(finish func(err *error), metrics *opsMetrics, readOnly bool, op string) func(err *error) {
+	if metrics == nil || readOnly {
+		return finish
+	}
+	return func(err *error) {
+		finish(err)
+		metrics.record(op, *err)
+	}
+}
@@ -0,0 +1,101 @@
+package store_test
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	store "github.com/gradientzero/comby-store-sqlite"
+	"github.com/gradientzero/comby/v3"
+)
+
+func TestEventStoreQueryWatchdogCancels(t *testing.T) {
+	ctx := context.Background()
+	eventStore := store.NewEventStoreSQLite(filepath.Join(t.TempDir(), "events.db"))
+	eventStore.Configure(
+		store.EventStoreSQLiteWithQueryWatchdog(20*time.Millisecond, 0),
+		// stands in for a runaway scan, it only returns once cancelled
+		store.EventStoreSQLiteWithAuthorizer(func(ctx context.Context, req store.AccessRequest) error {
+			if req.Operation != store.OperationList {
+				return nil
+			}
+			<-ctx.Done()
+			return ctx.Err()
+		}),
+	)
+	if err := eventStore.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer eventStore.Close(ctx)
+
+	start := time.Now()
+	_, _, err := eventStore.List(ctx)
+	if !errors.Is(err, store.ErrQueryTimeout) {
+		t.Fatalf("expected query timeout, got %v", err)
+	}
+	var opErr *store.OpError
+	if !errors.As(err, &opErr) || opErr.Op != "list" {
+		t.Fatalf("expected op error of the list, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Fatalf("expected the list to be cancelled quickly, took %s", elapsed)
+	}
+
+	// operations within the maximum runtime are not recorded
+	evt := createTestEvent("tenant-1", "domain-1", 1, 100)
+	if err := eventStore.Create(ctx, comby.EventStoreCreateOptionWithEvent(evt)); err != nil {
+		t.Fatal(err)
+	}
+	slow := eventStore.SlowQueries()
+	if len(slow) != 1 || !slow[0].Cancelled || slow[0].Op != "list" || slow[0].Table != "events" || len(slow[0].Error) == 0 {
+		t.Fatalf("unexpected slow query log %+v", slow)
+	}
+}
+
+func TestCommandStoreSlowQueryLog(t *testing.T) {
+	ctx := context.Background()
+	commandStore := store.NewCommandStoreSQLite(filepath.Join(t.TempDir(), "commands.db"))
+	commandStore.Configure(store.CommandStoreSQLiteWithQueryWatchdog(time.Minute, time.Nanosecond))
+	if err := commandStore.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer commandStore.Close(ctx)
+
+	cmd := createTestCommand("tenant-1", "domain-1", 100)
+	if err := commandStore.Create(ctx, comby.CommandStoreCreateOptionWithCommand(cmd)); err != nil {
+		t.Fatal(err)
+	}
+	for range 120 {
+		if _, err := commandStore.Get(ctx, comby.CommandStoreGetOptionWithCommandUuid(cmd.GetCommandUuid())); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// the log keeps the latest 100 entries
+	slow := commandStore.SlowQueries()
+	if len(slow) != 100 {
+		t.Fatalf("expected 100 entries, got %d", len(slow))
+	}
+	for i, entry := range slow {
+		if entry.Op != "get" || entry.Table != "commands" || entry.Cancelled || entry.Elapsed <= 0 {
+			t.Fatalf("unexpected entry %+v", entry)
+		}
+		if i > 0 && entry.StartedAt.Before(slow[i-1].StartedAt) {
+			t.Fatal("expected the oldest entry first")
+		}
+	}
+
+	// without a watchdog nothing is recorded
+	other := store.NewCommandStoreSQLite(filepath.Join(t.TempDir(), "other.db"))
+	if err := other.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer other.Close(ctx)
+	if _, err := other.Get(ctx, comby.CommandStoreGetOptionWithCommandUuid(cmd.GetCommandUuid())); err != nil {
+		t.Fatal(err)
+	}
+	if slow := other.SlowQueries(); len(slow) != 0 {
+		t.Fatalf("expected no entries, got %d", len(slow))
+	}
+}
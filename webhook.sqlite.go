@@ -0,0 +1,404 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gradientzero/comby/v3"
+)
+
+// Delivery states of webhook deliveries.
+const (
+	WebhookDeliveryPending   = "pending"
+	WebhookDeliveryDelivered = "delivered"
+	WebhookDeliveryFailed    = "failed"
+)
+
+// Webhook is a registered endpoint receiving new events. Empty filters match all events.
+type Webhook struct {
+	ID        int64
+	URL       string
+	Domain    string
+	DataType  string
+	CreatedAt int64
+	// sequence of the last event enqueued for delivery
+	LastSeq int64
+}
+
+// WebhookDelivery is one event sent (or to be sent) to a webhook.
+type WebhookDelivery struct {
+	ID            int64
+	WebhookID     int64
+	EventUuid     string
+	Seq           int64
+	Status        string
+	Attempts      int64
+	LastError     string
+	NextAttemptAt int64
+	DeliveredAt   int64
+}
+
+type WebhookOption func(*WebhookDispatcher)
+
+// WebhookWithHTTPClient sets the http client used for deliveries.
+func WebhookWithHTTPClient(httpClient *http.Client) WebhookOption {
+	return func(d *WebhookDispatcher) { d.httpClient = httpClient }
+}
+
+// WebhookWithRetries sets the attempts per delivery and the delay before the
+// first retry, which doubles with each further attempt.
+func WebhookWithRetries(maxAttempts int, backoff time.Duration) WebhookOption {
+	return func(d *WebhookDispatcher) {
+		d.maxAttempts = maxAttempts
+		d.backoff = backoff
+	}
+}
+
+// WebhookWithPollInterval sets how often Run looks for new events and due retries.
+func WebhookWithPollInterval(interval time.Duration) WebhookOption {
+	return func(d *WebhookDispatcher) { d.pollInterval = interval }
+}
+
+// WebhookDispatcher POSTs new events as JSON to registered webhooks. Webhooks,
+// delivery attempts and retries are persisted in the webhooks and
+// webhook_deliveries tables of the event store, so deliveries survive restarts.
+// Deliveries are sent at least once, a retried delivery may arrive after later events.
+type WebhookDispatcher struct {
+	es           *eventStoreSQLite
+	httpClient   *http.Client
+	maxAttempts  int
+	backoff      time.Duration
+	pollInterval time.Duration
+}
+
+func NewWebhookDispatcher(eventStore comby.EventStore, opts ...WebhookOption) (*WebhookDispatcher, error) {
+	es, ok := eventStore.(*eventStoreSQLite)
+	if !ok {
+		return nil, fmt.Errorf("webhook dispatcher requires a sqlite event store")
+	}
+	d := &WebhookDispatcher{
+		es:           es,
+		httpClient:   &http.Client{Timeout: 10 * time.Second},
+		maxAttempts:  5,
+		backoff:      time.Second,
+		pollInterval: time.Second,
+	}
+	for _, opt := range opts {
+		opt(d)
+	}
+	if d.maxAttempts < 1 || d.backoff < 0 || d.pollInterval <= 0 {
+		return nil, fmt.Errorf("'%s' failed to create webhook dispatcher - invalid retry or poll settings", es.String())
+	}
+	return d, nil
+}
+
+var webhookTables = []strictTable{
+	{
+		name: "webhooks",
+		columns: `id INTEGER PRIMARY KEY,
+		url TEXT NOT NULL,
+		domain TEXT NOT NULL,
+		data_type TEXT NOT NULL,
+		created_at INTEGER NOT NULL,
+		last_seq INTEGER NOT NULL`,
+		copyColumns: `id, url, domain, data_type, created_at, last_seq`,
+	},
+	{
+		name: "webhook_deliveries",
+		columns: `id INTEGER PRIMARY KEY,
+		webhook_id INTEGER NOT NULL REFERENCES webhooks(id),
+		event_uuid TEXT NOT NULL,
+		seq INTEGER NOT NULL,
+		status TEXT NOT NULL,
+		attempts INTEGER NOT NULL DEFAULT 0,
+		last_error TEXT NOT NULL DEFAULT '',
+		next_attempt_at INTEGER NOT NULL DEFAULT 0,
+		delivered_at INTEGER NOT NULL DEFAULT 0,
+		UNIQUE (webhook_id, seq)`,
+		copyColumns: `id, webhook_id, event_uuid, seq, status, attempts, last_error, next_attempt_at, delivered_at`,
+	},
+}
+
+// Init creates the webhook tables. The event store must be initialized before.
+func (d *WebhookDispatcher) Init(ctx context.Context) error {
+	if d.es.db == nil {
+		return fmt.Errorf("'%s' failed to init webhook dispatcher - event store is not initialized", d.es.String())
+	}
+	return migrateTx(ctx, d.es.db, func(tx *sql.Tx) error {
+		if err := migrateStrictTables(ctx, tx, loggerOrDiscard(d.es.cfg().Logger), webhookTables...); err != nil {
+			return err
+		}
+		query := `
+		CREATE INDEX IF NOT EXISTS "webhook_deliveries_due_index" ON "webhook_deliveries" (
+			"status" ASC,
+			"next_attempt_at" ASC
+		);
+		`
+		_, err := tx.ExecContext(ctx, query)
+		return err
+	})
+}
+
+// Register adds a webhook receiving events written from now on, optionally
+// filtered by domain and data type.
+func (d *WebhookDispatcher) Register(ctx context.Context, url, domain, dataType string) (*Webhook, error) {
+	if len(url) == 0 {
+		return nil, fmt.Errorf("'%s' failed to register webhook - url is required", d.es.String())
+	}
+	d.es.writeMu.Lock()
+	defer d.es.writeMu.Unlock()
+
+	webhook := &Webhook{URL: url, Domain: domain, DataType: dataType, CreatedAt: time.Now().UnixNano()}
+	if err := d.es.db.QueryRowContext(ctx, "SELECT COALESCE(MAX(id), 0) FROM event_records;").Scan(&webhook.LastSeq); err != nil {
+		return nil, err
+	}
+	query := `INSERT INTO webhooks (url, domain, data_type, created_at, last_seq) VALUES (?, ?, ?, ?, ?) RETURNING id;`
+	if err := d.es.db.QueryRowContext(ctx, query, webhook.URL, webhook.Domain, webhook.DataType, webhook.CreatedAt, webhook.LastSeq).Scan(&webhook.ID); err != nil {
+		return nil, err
+	}
+	return webhook, nil
+}
+
+// Unregister removes a webhook and its deliveries.
+func (d *WebhookDispatcher) Unregister(ctx context.Context, id int64) error {
+	d.es.writeMu.Lock()
+	defer d.es.writeMu.Unlock()
+	return runTx(ctx, d.es.db, func(tx *sql.Tx) error {
+		if _, err := tx.ExecContext(ctx, "DELETE FROM webhook_deliveries WHERE webhook_id=?;", id); err != nil {
+			return err
+		}
+		_, err := tx.ExecContext(ctx, "DELETE FROM webhooks WHERE id=?;", id)
+		return err
+	})
+}
+
+// Webhooks returns all registered webhooks.
+func (d *WebhookDispatcher) Webhooks(ctx context.Context) ([]*Webhook, error) {
+	rows, err := d.es.db.QueryContext(ctx, "SELECT id, url, domain, data_type, created_at, last_seq FROM webhooks ORDER BY id ASC;")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var webhooks []*Webhook
+	for rows.Next() {
+		var webhook Webhook
+		if err := rows.Scan(&webhook.ID, &webhook.URL, &webhook.Domain, &webhook.DataType, &webhook.CreatedAt, &webhook.LastSeq); err != nil {
+			return nil, err
+		}
+		webhooks = append(webhooks, &webhook)
+	}
+	return webhooks, rows.Err()
+}
+
+// Deliveries returns the deliveries of a webhook in event order.
+func (d *WebhookDispatcher) Deliveries(ctx context.Context, webhookID int64) ([]*WebhookDelivery, error) {
+	query := `SELECT id, webhook_id, event_uuid, seq, status, attempts, last_error, next_attempt_at, delivered_at
+		FROM webhook_deliveries WHERE webhook_id=? ORDER BY seq ASC;`
+	rows, err := d.es.db.QueryContext(ctx, query, webhookID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var deliveries []*WebhookDelivery
+	for rows.Next() {
+		var delivery WebhookDelivery
+		if err := rows.Scan(
+			&delivery.ID,
+			&delivery.WebhookID,
+			&delivery.EventUuid,
+			&delivery.Seq,
+			&delivery.Status,
+			&delivery.Attempts,
+			&delivery.LastError,
+			&delivery.NextAttemptAt,
+			&delivery.DeliveredAt,
+		); err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, &delivery)
+	}
+	return deliveries, rows.Err()
+}
+
+// Dispatch enqueues new events for all webhooks and sends all due deliveries.
+// It returns the number of successful deliveries.
+func (d *WebhookDispatcher) Dispatch(ctx context.Context) (int64, error) {
+	if err := d.enqueue(ctx); err != nil {
+		return 0, err
+	}
+
+	query := `SELECT w.url, wd.id, wd.event_uuid, wd.attempts FROM webhook_deliveries wd
+		JOIN webhooks w ON w.id=wd.webhook_id
+		WHERE wd.status=? AND wd.next_attempt_at<=?
+		ORDER BY wd.webhook_id ASC, wd.seq ASC;`
+	rows, err := d.es.db.QueryContext(ctx, query, WebhookDeliveryPending, time.Now().UnixNano())
+	if err != nil {
+		return 0, err
+	}
+	type due struct {
+		url       string
+		id        int64
+		eventUuid string
+		attempts  int64
+	}
+	var dues []due
+	for rows.Next() {
+		var item due
+		if err := rows.Scan(&item.url, &item.id, &item.eventUuid, &item.attempts); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		dues = append(dues, item)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	var delivered int64
+	for _, item := range dues {
+		sendErr := d.send(ctx, item.url, item.id, item.eventUuid)
+		if ctx.Err() != nil {
+			return delivered, ctx.Err()
+		}
+		if err := d.record(ctx, item.id, item.attempts+1, sendErr); err != nil {
+			return delivered, err
+		}
+		if sendErr == nil {
+			delivered++
+		}
+	}
+	return delivered, nil
+}
+
+// enqueue adds a pending delivery for each new event matching a webhook.
+func (d *WebhookDispatcher) enqueue(ctx context.Context) error {
+	d.es.writeMu.Lock()
+	defer d.es.writeMu.Unlock()
+	return runTx(ctx, d.es.db, func(tx *sql.Tx) error {
+		var lastSeq int64
+		if err := tx.QueryRowContext(ctx, "SELECT COALESCE(MAX(id), 0) FROM event_records;").Scan(&lastSeq); err != nil {
+			return err
+		}
+		query := `INSERT OR IGNORE INTO webhook_deliveries (webhook_id, event_uuid, seq, status)
+			SELECT w.id, e.uuid, e.id, ? FROM webhooks w
+			JOIN events e ON e.id>w.last_seq AND e.id<=?
+				AND (w.domain='' OR e.domain=w.domain)
+				AND (w.data_type='' OR e.data_type=w.data_type);`
+		if _, err := tx.ExecContext(ctx, query, WebhookDeliveryPending, lastSeq); err != nil {
+			return err
+		}
+		_, err := tx.ExecContext(ctx, "UPDATE webhooks SET last_seq=? WHERE last_seq<?;", lastSeq, lastSeq)
+		return err
+	})
+}
+
+// webhookEvent is the JSON body of a delivery.
+type webhookEvent struct {
+	EventUuid     string          `json:"event_uuid"`
+	TenantUuid    string          `json:"tenant_uuid"`
+	WorkspaceUuid string          `json:"workspace_uuid,omitempty"`
+	CommandUuid   string          `json:"command_uuid"`
+	Domain        string          `json:"domain"`
+	AggregateUuid string          `json:"aggregate_uuid"`
+	Version       int64           `json:"version"`
+	CreatedAt     int64           `json:"created_at"`
+	DataType      string          `json:"data_type"`
+	Data          json.RawMessage `json:"data"`
+}
+
+func (d *WebhookDispatcher) send(ctx context.Context, url string, deliveryID int64, eventUuid string) error {
+	evt, err := d.es.Get(ctx, comby.EventStoreGetOptionWithEventUuid(eventUuid))
+	if err != nil {
+		return err
+	}
+	if evt == nil {
+		return fmt.Errorf("event was deleted")
+	}
+	body := webhookEvent{
+		EventUuid:     evt.GetEventUuid(),
+		TenantUuid:    evt.GetTenantUuid(),
+		WorkspaceUuid: evt.GetWorkspaceUuid(),
+		CommandUuid:   evt.GetCommandUuid(),
+		Domain:        evt.GetDomain(),
+		AggregateUuid: evt.GetAggregateUuid(),
+		Version:       evt.GetVersion(),
+		CreatedAt:     evt.GetCreatedAt(),
+		DataType:      evt.GetDomainEvtName(),
+		Data:          evt.GetDomainEvtBytes(),
+	}
+	// payloads which are no JSON are sent as base64 string
+	if !json.Valid(body.Data) {
+		if body.Data, err = json.Marshal(evt.GetDomainEvtBytes()); err != nil {
+			return err
+		}
+	}
+	b, err := json.Marshal(&body)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	// receivers deduplicate retries by delivery id
+	req.Header.Set("X-Webhook-Delivery", strconv.FormatInt(deliveryID, 10))
+	res, err := d.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(res.Body, 64*1024))
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status %s", res.Status)
+	}
+	return nil
+}
+
+// record stores the outcome of a delivery attempt and schedules the retry.
+func (d *WebhookDispatcher) record(ctx context.Context, deliveryID, attempts int64, sendErr error) error {
+	d.es.writeMu.Lock()
+	defer d.es.writeMu.Unlock()
+
+	now := time.Now()
+	if sendErr == nil {
+		query := `UPDATE webhook_deliveries SET status=?, attempts=?, last_error='', delivered_at=? WHERE id=?;`
+		_, err := d.es.db.ExecContext(ctx, query, WebhookDeliveryDelivered, attempts, now.UnixNano(), deliveryID)
+		return err
+	}
+	status := WebhookDeliveryPending
+	if attempts >= int64(d.maxAttempts) {
+		status = WebhookDeliveryFailed
+	}
+	nextAttemptAt := now.Add(d.backoff << min(attempts-1, 20)).UnixNano()
+	query := `UPDATE webhook_deliveries SET status=?, attempts=?, last_error=?, next_attempt_at=? WHERE id=?;`
+	_, err := d.es.db.ExecContext(ctx, query, status, attempts, sendErr.Error(), nextAttemptAt, deliveryID)
+	return err
+}
+
+// Run dispatches until ctx is done. Failures are logged and retried after the
+// poll interval.
+func (d *WebhookDispatcher) Run(ctx context.Context) error {
+	logger := loggerOrDiscard(d.es.cfg().Logger)
+	ticker := time.NewTicker(d.pollInterval)
+	defer ticker.Stop()
+	for {
+		if _, err := d.Dispatch(ctx); err != nil && ctx.Err() == nil {
+			logger.ErrorContext(ctx, "webhook dispatch failed", "error", err)
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
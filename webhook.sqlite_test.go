@@ -0,0 +1,90 @@
+package store_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	store "github.com/gradientzero/comby-store-sqlite"
+	"github.com/gradientzero/comby/v3"
+)
+
+func TestWebhookDispatcher(t *testing.T) {
+	ctx := context.Background()
+	eventStore := store.NewEventStoreSQLite(filepath.Join(t.TempDir(), "eventStore-webhook.db"))
+	if err := eventStore.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer eventStore.Close(ctx)
+
+	// the receiver fails the first request
+	var mu sync.Mutex
+	var received []map[string]any
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		requests++
+		if requests == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		var body map[string]any
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Error(err)
+		}
+		received = append(received, body)
+	}))
+	defer server.Close()
+
+	dispatcher, err := store.NewWebhookDispatcher(eventStore, store.WebhookWithRetries(3, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := dispatcher.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	// events written before the registration are not delivered
+	if err := eventStore.Create(ctx, comby.EventStoreCreateOptionWithEvent(createTestEvent("tenant-1", "domain-1", 1, 100))); err != nil {
+		t.Fatal(err)
+	}
+	webhook, err := dispatcher.Register(ctx, server.URL, "domain-1", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, domain := range []string{"domain-1", "domain-2"} {
+		evt := createTestEvent("tenant-1", domain, int64(i+2), int64(i+2)*100)
+		evt.SetDomainEvtBytes([]byte(`{"name":"test"}`))
+		if err := eventStore.Create(ctx, comby.EventStoreCreateOptionWithEvent(evt)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if n, err := dispatcher.Dispatch(ctx); err != nil || n != 0 {
+		t.Fatalf("expected failed first delivery, got %d, %v", n, err)
+	}
+	deliveries, err := dispatcher.Deliveries(ctx, webhook.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(deliveries) != 1 || deliveries[0].Status != store.WebhookDeliveryPending || deliveries[0].Attempts != 1 || deliveries[0].LastError == "" {
+		t.Fatalf("unexpected deliveries: %+v", deliveries)
+	}
+
+	if n, err := dispatcher.Dispatch(ctx); err != nil || n != 1 {
+		t.Fatalf("expected retried delivery, got %d, %v", n, err)
+	}
+	deliveries, _ = dispatcher.Deliveries(ctx, webhook.ID)
+	if deliveries[0].Status != store.WebhookDeliveryDelivered || deliveries[0].Attempts != 2 {
+		t.Fatalf("unexpected delivery: %+v", deliveries[0])
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(received) != 1 || received[0]["domain"] != "domain-1" || received[0]["data"].(map[string]any)["name"] != "test" {
+		t.Fatalf("unexpected webhook body: %v", received)
+	}
+}